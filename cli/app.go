@@ -14,20 +14,31 @@
 package cli
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/aws/smithy-go/logging"
 	"github.com/mmmorris1975/aws-runas/client"
 	"github.com/mmmorris1975/aws-runas/config"
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"github.com/mmmorris1975/aws-runas/metadata"
+	"github.com/mmmorris1975/aws-runas/shared"
 	"github.com/mmmorris1975/simple-logger/logger"
 	"github.com/urfave/cli/v2"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 )
 
 var (
@@ -38,13 +49,14 @@ var (
 	cmdlineCreds  = new(config.AwsCredentials)
 
 	configResolver config.Resolver = config.DefaultResolver.WithLogger(log)
+	configWriter   config.Writer   = config.DefaultResolver
 )
 
 // App is the struct used to manage the configuration and behavior for the cli handling library.
 var App = &cli.App{
 	Usage:     "Create an environment for interacting with the AWS API using an assumed role",
 	UsageText: fmt.Sprintf("%s [global options] [subcommand] profile [arguments...]", filepath.Base(os.Args[0])),
-	Commands:  []*cli.Command{listCmd, serveCmd, ssmCmd, ecrCmd, passwordCmd, diagCmd, updateCmd},
+	Commands:  []*cli.Command{listCmd, serveCmd, ssmCmd, ecrCmd, consoleCmd, dockerCmd, batchCmd, passwordCmd, cacheCmd, statusCmd, warmCmd, hookCmd, importCmd, exportCmd, diagCmd, updateCmd},
 	Flags:     append(configFlags, append(otherFlags, shortcutFlags...)...),
 
 	UseShortOptionHandling: true,
@@ -72,7 +84,12 @@ var App = &cli.App{
 	},
 
 	Before: func(ctx *cli.Context) error {
-		opts.Logger = log
+		if err := applyFlagDefaults(ctx, "", ctx.App.Flags, flagDefaultsProfile(ctx, ctx.App.Commands)); err != nil {
+			return err
+		}
+
+		// the clients log identity provider and AWS responses, keep secrets out of the debug output
+		opts.Logger = shared.NewRedactingLogger(log)
 
 		if verbose, ok := ctx.Value(vFlag.Name).([]bool); ok {
 			if len(verbose) > 0 {
@@ -98,6 +115,20 @@ var App = &cli.App{
 		cmdlineCreds.WebIdentityPassword = password
 		opts.CommandCredentials = cmdlineCreds
 
+		if shared.RevealSecrets = ctx.Bool(revealSecretsFlag.Name); shared.RevealSecrets {
+			log.Warningf("secrets are not redacted from the debug output, do not share it")
+		}
+
+		opts.ForceRefresh = ctx.Bool(forceRefreshFlag.Name)
+		opts.AuditLog = ctx.String(auditLogFlag.Name)
+		if err := configureTransport(ctx.Bool(offlineFlag.Name)); err != nil {
+			return err
+		}
+		return startTracing(ctx.String(traceFileFlag.Name))
+	},
+
+	After: func(ctx *cli.Context) error {
+		stopTracing()
 		return nil
 	},
 
@@ -138,6 +169,9 @@ func init() {
 		Aliases: []string{"V"},
 		Usage:   "print the version",
 	}
+
+	// commands look up the defaults for their own flags in the flag defaults file
+	withFlagDefaults(App.Commands, "")
 }
 
 //nolint:funlen,gocognit,gocyclo // he's just a long boi ... you should have seen the older versions!
@@ -171,6 +205,12 @@ func execCmd(ctx *cli.Context) error {
 		return err
 	}
 
+	if ctx.Bool(verifyFlag.Name) {
+		if err = verifyCredentials(sts.NewFromConfig(c.ConfigProvider())); err != nil {
+			return err
+		}
+	}
+
 	saveStsCredentials(ctx, profile, creds)
 
 	if strings.EqualFold(ctx.String(fmtFlag.Name), "json") {
@@ -197,22 +237,53 @@ func execCmd(ctx *cli.Context) error {
 		}
 	}
 
+	if ctx.Bool(showPoliciesFlag.Name) {
+		if err = printRolePolicies(sts.NewFromConfig(c.ConfigProvider()), iam.NewFromConfig(c.ConfigProvider())); err != nil {
+			return err
+		}
+	}
+
 	cmd := ctx.Args().Slice()
 	if ctx.Args().First() == profile {
 		cmd = ctx.Args().Tail()
 	}
 
-	env := buildEnv(cfg.Region, creds)
+	if len(cmd) < 1 && useDefaultCommand(ctx) {
+		// a profile used for a single tool can set the command to run when none is given
+		if cmd, err = cfg.DefaultCommandArgs(); err != nil {
+			return err
+		}
+
+		if len(cmd) > 0 {
+			log.Debugf("running default command for profile %s: %v", cfg.ProfileName, cmd)
+		}
+	}
+
+	env, sess, err := commandEnv(cfg, creds)
+	if err != nil {
+		return err
+	}
 
 	if len(cmd) > 0 {
+		checkCredLifetime(cfg, creds, ctx.Duration(warnLifetimeFlag.Name), time.Now())
+
 		if ctx.Bool(envFlag.Name) {
 			// set credentials in environment, don't start ecs endpoint
 			for k, v := range env {
 				_ = os.Setenv(k, v)
 			}
 		} else {
+			// the session details replace the profile and role inherited from a parent aws-runas process, so check
+			// if its credential endpoint can be reused before they are exported
+			reuse := reuseEcsSvc(cfg)
+
+			// credentials (and their expiration) come from the ecs endpoint, only expose the session details
+			for k, v := range sess {
+				_ = os.Setenv(k, v)
+			}
+
 			var ch <-chan bool
-			ch, err = runEcsSvc(c, cfg)
+			ch, err = runEcsSvc(c, cfg, reuse)
 			if err != nil {
 				return err
 			}
@@ -220,24 +291,78 @@ func execCmd(ctx *cli.Context) error {
 			log.Debugf("ECS endpoint ready")
 		}
 
-		wrapped := wrapCmd(cmd)
-		c := exec.Command(wrapped[0], wrapped[1:]...) //nolint:gosec // it's sort of the whole reason this tool exists
-		c.Stdin = os.Stdin
-		c.Stdout = os.Stdout
-		c.Stderr = os.Stderr
-
 		installSignalHandler()
-		if err = c.Run(); err != nil {
+		if err = runCmd(cmd, c, cfg, ctx.Bool(envFlag.Name), ctx.Int(retryExpiredFlag.Name)); err != nil {
 			log.Debug("Error running command")
 			return err
 		}
 	} else {
-		printCreds(env)
+		write := writeCreds
+		if strings.EqualFold(ctx.String(fmtFlag.Name), "powershell") {
+			write = writePowerShellCreds
+		}
+
+		if ctx.Bool(copyFlag.Name) {
+			b := new(bytes.Buffer)
+			write(b, env)
+			return copyOutput(b.String(), ctx.Duration(copyClearFlag.Name))
+		}
+		printCreds(write, env)
 	}
 
 	return nil
 }
 
+// configureTransport sets up the HTTP transport shared by the identity provider clients, configured from the
+// environment.  In offline mode, clients only use cached credentials, and the shared transport fails every request,
+// as a safety net for anything else which would use the network.
+func configureTransport(offline bool) error {
+	if offline {
+		opts.Offline = true
+		opts.Transport = client.OfflineTransport
+		shared.SetDefaultRoundTripper(client.OfflineTransport)
+		return nil
+	}
+
+	transportOpts, err := shared.TransportOptionsFromEnv()
+	if err != nil {
+		return err
+	}
+	return shared.SetDefaultTransport(transportOpts)
+}
+
+// stopTracing flushes the spans buffered by the tracer provider registered in startTracing, and closes the file.
+var stopTracing = func() {}
+
+// startTracing appends the OpenTelemetry spans to the file at path, if set.
+func startTracing(path string) error {
+	if len(path) < 1 {
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	shutdown, err := shared.StartTracing(f)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	stopTracing = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdown(ctx); err != nil {
+			log.Debugf("error writing traces: %v", err)
+		}
+		_ = f.Close()
+	}
+	return nil
+}
+
 func buildEnv(region string, creds *credentials.Credentials) map[string]string {
 	// AWS_PROFILE and AWS_DEFAULT_PROFILE are explicitly unset in resolveConfig() if a profile
 	// was found in the environment. The env var AWSRUNAS_PROFILE is set to the profile name
@@ -249,6 +374,11 @@ func buildEnv(region string, creds *credentials.Credentials) map[string]string {
 		env["AWS_DEFAULT_REGION"] = region
 	}
 
+	// used by the aws sdks (like botocore) to know when the credentials need to be refreshed
+	if !creds.Expiration.IsZero() {
+		env["AWS_CREDENTIAL_EXPIRATION"] = creds.Expiration.UTC().Format(time.RFC3339)
+	}
+
 	// If no session token creds were returned, unset them to keep the sdk from getting confused.
 	// AFAIK, we should always have SessionTokens, since our entire process revolves around them.
 	// But always code defensively
@@ -260,7 +390,70 @@ func buildEnv(region string, creds *credentials.Credentials) map[string]string {
 	return env
 }
 
-func printCreds(env map[string]string) {
+// sessionEnv returns the env vars describing the profile and role the credentials are for, so downstream tools (like
+// shell prompts) are able to show which credentials are in use.  A role pattern is never exported, only the role it
+// matched, when the credentials know which role that is.
+func sessionEnv(cfg *config.AwsConfig, creds *credentials.Credentials) map[string]string {
+	env := make(map[string]string)
+
+	if v, ok := os.LookupEnv("AWSRUNAS_PROFILE"); ok {
+		env["AWS_RUNAS_PROFILE"] = v
+	}
+
+	role := cfg.RoleArn
+	if creds != nil && len(creds.RoleArn) > 0 {
+		role = creds.RoleArn
+	}
+
+	if len(role) > 0 && !credentials.IsRolePattern(role) {
+		env["AWS_RUNAS_ROLE_ARN"] = role
+	}
+	return env
+}
+
+// commandEnv returns the env vars for programs using the credentials, and the subset of those env vars which do not
+// contain credentials (the session and profile env vars).  The extra env vars from the profile configuration never
+// replace the credential or session env vars.
+func commandEnv(cfg *config.AwsConfig, creds *credentials.Credentials) (map[string]string, map[string]string, error) {
+	sess, err := profileEnv(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for k, v := range sessionEnv(cfg, creds) {
+		sess[k] = v
+	}
+
+	env := buildEnv(cfg.Region, creds)
+	for k, v := range sess {
+		if _, ok := env[k]; !ok {
+			env[k] = v
+		}
+	}
+	return env, sess, nil
+}
+
+// profileEnv returns the extra env vars configured for the profile using the "env" configuration attribute, so
+// switching profiles also switches any related tool settings (like KUBECONFIG).  Values are expanded using the current
+// environment, so they may reference existing env vars like $HOME.
+func profileEnv(cfg *config.AwsConfig) (map[string]string, error) {
+	env, err := cfg.ProfileEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range env {
+		env[k] = os.ExpandEnv(v)
+	}
+	return env, nil
+}
+
+func printCreds(write func(io.Writer, map[string]string), env map[string]string) {
+	write(os.Stdout, env)
+}
+
+// writeCreds writes the commands to set the credential environment variables in the current shell to w.
+func writeCreds(w io.Writer, env map[string]string) {
 	format := "%s %s='%s'\n"
 	exportToken := "export"
 
@@ -273,20 +466,69 @@ func printCreds(env map[string]string) {
 	}
 
 	for k, v := range env {
-		fmt.Printf(format, exportToken, k, v)
+		_, _ = fmt.Fprintf(w, format, exportToken, k, v)
 	}
 
 	if v, ok := os.LookupEnv("AWSRUNAS_PROFILE"); ok {
-		fmt.Printf(format, exportToken, "AWSRUNAS_PROFILE", v)
+		_, _ = fmt.Fprintf(w, format, exportToken, "AWSRUNAS_PROFILE", v)
+	}
+}
+
+// writePowerShellCreds writes PowerShell statements to w, which set the credential environment variables, and the
+// default credentials and region of the AWS Tools for PowerShell, if the module is installed.  The output is meant to
+// be evaluated in the current session, using Invoke-Expression.
+func writePowerShellCreds(w io.Writer, env map[string]string) {
+	vars := make(map[string]string, len(env)+1)
+	for k, v := range env {
+		vars[k] = v
+	}
+
+	if v, ok := os.LookupEnv("AWSRUNAS_PROFILE"); ok {
+		vars["AWSRUNAS_PROFILE"] = v
+	}
+
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(w, "$env:%s = %s\n", k, psQuote(vars[k]))
+	}
+
+	if len(env["AWS_ACCESS_KEY_ID"]) < 1 {
+		return
+	}
+
+	_, _ = fmt.Fprintln(w, "if (Get-Command Set-AWSCredential -ErrorAction SilentlyContinue) {")
+	_, _ = fmt.Fprintf(w, "  Set-AWSCredential -AccessKey %s -SecretKey %s", psQuote(env["AWS_ACCESS_KEY_ID"]),
+		psQuote(env["AWS_SECRET_ACCESS_KEY"]))
+	if v := env["AWS_SESSION_TOKEN"]; len(v) > 0 {
+		_, _ = fmt.Fprintf(w, " -SessionToken %s", psQuote(v))
 	}
+	_, _ = fmt.Fprintln(w, " -Scope Global")
+
+	if v := env["AWS_REGION"]; len(v) > 0 {
+		_, _ = fmt.Fprintf(w, "  Set-DefaultAWSRegion -Region %s -Scope Global\n", psQuote(v))
+	}
+	_, _ = fmt.Fprintln(w, "}")
+}
+
+// psQuote returns the value as a PowerShell single-quoted string.
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
 }
 
-func runEcsSvc(client client.AwsClient, cfg *config.AwsConfig) (<-chan bool, error) {
+// runEcsSvc starts the ECS credential endpoint used by the wrapped command, unless reuse is set and the endpoint
+// of the parent aws-runas process is used instead.
+func runEcsSvc(client client.AwsClient, cfg *config.AwsConfig, reuse bool) (<-chan bool, error) {
 	// modify the execution environment to force use of ECS credential URL
 	unsetEnv := []string{
 		"AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY",
 		"AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY",
 		"AWS_SESSION_TOKEN", "AWS_SECURITY_TOKEN",
+		"AWS_CREDENTIAL_EXPIRATION",
 	}
 	for _, e := range unsetEnv {
 		_ = os.Unsetenv(e)
@@ -297,11 +539,25 @@ func runEcsSvc(client client.AwsClient, cfg *config.AwsConfig) (<-chan bool, err
 		_ = os.Setenv(v, os.DevNull)
 	}
 
+	ch := make(chan bool, 1)
+	if reuse {
+		log.Debugf("using credential endpoint of parent aws-runas process")
+		ch <- true
+		return ch, nil
+	}
+
+	token, err := newAuthToken()
+	if err != nil {
+		return nil, err
+	}
+
 	in := &metadata.Options{
 		Path:        metadata.DefaultEcsCredPath,
 		Profile:     cfg.ProfileName,
-		Logger:      log,
+		Logger:      opts.Logger,
 		AwsLogLevel: opts.AwsLogLevel,
+		AuthToken:   token,
+		EcsOnly:     true,
 	}
 
 	// since this is internal consumption only, use a random port and default path.
@@ -312,11 +568,56 @@ func runEcsSvc(client client.AwsClient, cfg *config.AwsConfig) (<-chan bool, err
 
 	ep := fmt.Sprintf("http://%s%s", mcs.Addr().String(), in.Path)
 	_ = os.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", ep)
-	ch := make(chan bool, 1)
+	_ = os.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", token)
+
 	go mcs.RunNoApi(client, cfg, ch) //nolint:errcheck
 	return ch, nil
 }
 
+// reuseEcsSvc checks if aws-runas was called from a program wrapped by another aws-runas process which serves
+// credentials for the same profile and role, so the existing credential endpoint can be used by the new program.
+func reuseEcsSvc(cfg *config.AwsConfig) bool {
+	ep := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+
+	if len(ep) < 1 || len(token) < 1 || len(cfg.ProfileName) < 1 ||
+		os.Getenv("AWS_RUNAS_PROFILE") != cfg.ProfileName || os.Getenv("AWS_RUNAS_ROLE_ARN") != cfg.RoleArn {
+		return false
+	}
+
+	// only ever send the token to an endpoint on this host
+	u, err := url.Parse(ep)
+	if err != nil || u.Hostname() != "127.0.0.1" {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ep, http.NoBody)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Authorization", token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer res.Body.Close()
+
+	return res.StatusCode == http.StatusOK
+}
+
+// newAuthToken returns a random value used to authorize requests to the credential endpoint.
+func newAuthToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // If on a non-windows platform, with the SHELL environment variable set, and a call to exec.LookPath()
 // for 1st element of the command fails, run the command in a sub-shell so we can support shell aliases.
 //
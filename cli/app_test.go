@@ -14,11 +14,22 @@
 package cli
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mmmorris1975/aws-runas/client"
 	"github.com/mmmorris1975/aws-runas/config"
 	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/shared"
+	"go.opentelemetry.io/otel"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestApp_buildEnv(t *testing.T) {
@@ -43,6 +54,19 @@ func TestApp_buildEnv(t *testing.T) {
 		}
 	})
 
+	t.Run("creds with expiration", func(t *testing.T) {
+		cred := &credentials.Credentials{
+			AccessKeyId:     "mockAK",
+			SecretAccessKey: "mockSK",
+			Expiration:      time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("", -5*3600)),
+		}
+
+		env := buildEnv("", cred)
+		if env["AWS_CREDENTIAL_EXPIRATION"] != "2021-03-04T10:06:07Z" {
+			t.Errorf("invalid expiration in env: %s", env["AWS_CREDENTIAL_EXPIRATION"])
+		}
+	})
+
 	t.Run("creds no token", func(t *testing.T) {
 		_ = os.Setenv("AWS_SESSION_TOKEN", "x")
 
@@ -59,6 +83,70 @@ func TestApp_buildEnv(t *testing.T) {
 	})
 }
 
+func TestApp_sessionEnv(t *testing.T) {
+	t.Run("role profile", func(t *testing.T) {
+		t.Setenv("AWSRUNAS_PROFILE", "my-profile")
+
+		env := sessionEnv(&config.AwsConfig{RoleArn: "arn:aws:iam::123456789012:role/my-role"}, new(credentials.Credentials))
+		if env["AWS_RUNAS_PROFILE"] != "my-profile" || env["AWS_RUNAS_ROLE_ARN"] != "arn:aws:iam::123456789012:role/my-role" {
+			t.Errorf("invalid session env: %v", env)
+		}
+	})
+
+	t.Run("role pattern", func(t *testing.T) {
+		t.Setenv("AWSRUNAS_PROFILE", "my-profile")
+
+		cfg := &config.AwsConfig{RoleArn: "arn:aws:iam::*:role/Dev*"}
+		env := sessionEnv(cfg, &credentials.Credentials{RoleArn: "arn:aws:iam::123456789012:role/Developer"})
+		if env["AWS_RUNAS_ROLE_ARN"] != "arn:aws:iam::123456789012:role/Developer" {
+			t.Errorf("invalid session env: %v", env)
+		}
+
+		// the pattern itself is never exported
+		if _, ok := sessionEnv(cfg, nil)["AWS_RUNAS_ROLE_ARN"]; ok {
+			t.Error("role pattern was exported")
+		}
+	})
+
+	t.Run("no profile", func(t *testing.T) {
+		t.Setenv("AWSRUNAS_PROFILE", "")
+		_ = os.Unsetenv("AWSRUNAS_PROFILE")
+
+		if env := sessionEnv(new(config.AwsConfig), nil); len(env) > 0 {
+			t.Errorf("unexpected session env: %v", env)
+		}
+	})
+}
+
+func TestApp_profileEnv(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		t.Setenv("HOME", "/home/me")
+
+		env, err := profileEnv(&config.AwsConfig{ProfileEnv: "KUBECONFIG=$HOME/.kube/dev, TF_VAR_env=dev"})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if env["KUBECONFIG"] != "/home/me/.kube/dev" || env["TF_VAR_env"] != "dev" {
+			t.Errorf("invalid profile env: %v", env)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		env, err := profileEnv(new(config.AwsConfig))
+		if err != nil || len(env) > 0 {
+			t.Errorf("unexpected profile env: %v, %v", env, err)
+		}
+	})
+
+	t.Run("bad", func(t *testing.T) {
+		if _, err := profileEnv(&config.AwsConfig{ProfileEnv: "bad name=x"}); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
 func TestApp_runEcsSvc(t *testing.T) {
 	curEnv := os.Environ()
 	defer func() {
@@ -72,7 +160,7 @@ func TestApp_runEcsSvc(t *testing.T) {
 	_ = os.Setenv("AWS_ACCESS_KEY_ID", "mock")
 	_ = os.Setenv("AWS_SHARED_CREDENTIALS_FILE", "x")
 
-	if _, err := runEcsSvc(nil, &config.AwsConfig{ProfileName: "mock"}); err != nil {
+	if _, err := runEcsSvc(nil, &config.AwsConfig{ProfileName: "mock"}, false); err != nil {
 		t.Error(err)
 		return
 	}
@@ -90,8 +178,153 @@ func TestApp_runEcsSvc(t *testing.T) {
 	} else if !strings.HasPrefix(v, "http") {
 		t.Error("invalid container cred uri env var")
 	}
+
+	if v := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); len(v) < 64 {
+		t.Error("invalid container authorization token env var")
+	}
+
+	// the token protected listener must not be advertised as an EC2 metadata endpoint
+	if v := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"); strings.HasPrefix(v, "http://127.0.0.1:") {
+		t.Errorf("unexpected ec2 metadata endpoint env var: %s", v)
+	}
+}
+
+func TestApp_reuseEcsSvc(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := &config.AwsConfig{ProfileName: "mock", RoleArn: "arn:aws:iam::123456789012:role/mock"}
+
+	setup := func(t *testing.T, token string) {
+		t.Helper()
+		t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", srv.URL)
+		t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", token)
+		t.Setenv("AWS_RUNAS_PROFILE", cfg.ProfileName)
+		t.Setenv("AWS_RUNAS_ROLE_ARN", cfg.RoleArn)
+	}
+
+	t.Run("good", func(t *testing.T) {
+		setup(t, "s3cret")
+		if !reuseEcsSvc(cfg) {
+			t.Error("did not reuse endpoint")
+		}
+	})
+
+	t.Run("bad token", func(t *testing.T) {
+		setup(t, "bogus")
+		if reuseEcsSvc(cfg) {
+			t.Error("unexpected endpoint reuse")
+		}
+	})
+
+	t.Run("different profile", func(t *testing.T) {
+		setup(t, "s3cret")
+		t.Setenv("AWS_RUNAS_PROFILE", "other")
+		if reuseEcsSvc(cfg) {
+			t.Error("unexpected endpoint reuse")
+		}
+	})
+
+	t.Run("remote endpoint", func(t *testing.T) {
+		setup(t, "s3cret")
+		t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "http://169.254.170.2/v2/credentials")
+		if reuseEcsSvc(cfg) {
+			t.Error("unexpected endpoint reuse")
+		}
+	})
+}
+
+func TestApp_execCmd_nested(t *testing.T) {
+	curEnv := os.Environ()
+	defer func() {
+		os.Clearenv()
+		for _, e := range curEnv {
+			parts := strings.SplitN(e, `=`, 2)
+			_ = os.Setenv(parts[0], parts[1])
+		}
+	}()
+
+	// serves STS, and the credential endpoint of a parent aws-runas process serving a different profile, which must
+	// only be used for the source credentials of the new profile
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			exp := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+			_, _ = fmt.Fprintf(w, `{"AccessKeyId": "ASIAPARENT", "SecretAccessKey": "MockSecret", "Token": "MockToken", "Expiration": "%s"}`, exp)
+			return
+		}
+
+		_ = r.ParseForm()
+		switch r.PostForm.Get("Action") {
+		case "AssumeRole", "GetSessionToken":
+			exp := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+			_, _ = fmt.Fprintf(w, stsCredentialsResponse, r.PostForm.Get("Action"), exp)
+		case "GetCallerIdentity":
+			_, _ = fmt.Fprint(w, callerIdentityResponse)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	f := filepath.Join(dir, "config")
+	if err := os.WriteFile(f, []byte("[profile prod]\nrole_arn = arn:aws:iam::123456789012:role/Admin\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := configResolver
+	defer func() { configResolver = r }()
+	configResolver = config.NewResolver(config.DefaultIniLoader, false)
+
+	_ = os.Unsetenv("AWS_PROFILE")
+	_ = os.Setenv("AWS_CONFIG_FILE", f)
+	_ = os.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	_ = os.Setenv("AWS_RUNAS_CACHE_DIR", dir)
+	_ = os.Setenv("AWS_REGION", "us-east-1")
+	_ = os.Setenv("AWS_ENDPOINT_URL_STS", srv.URL)
+	_ = os.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", srv.URL)
+	_ = os.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "s3cret")
+	_ = os.Setenv("AWSRUNAS_PROFILE", "dev")
+	_ = os.Setenv("AWS_RUNAS_PROFILE", "dev")
+	_ = os.Setenv("AWS_RUNAS_ROLE_ARN", "arn:aws:iam::123456789012:role/Developer")
+
+	cmdlineCreds = new(config.AwsCredentials)
+	if err := App.Run([]string{"aws-runas", "prod", "true"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); v == srv.URL {
+		t.Error("reused credential endpoint of parent process for a different profile")
+	}
+
+	if v := os.Getenv("AWS_RUNAS_PROFILE"); v != "prod" {
+		t.Errorf("invalid session profile env var: %s", v)
+	}
 }
 
+const stsCredentialsResponse = `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+<%[1]sResult>
+<Credentials>
+<AccessKeyId>ASIAMOCK</AccessKeyId>
+<SecretAccessKey>MockSecret</SecretAccessKey>
+<SessionToken>MockToken</SessionToken>
+<Expiration>%[2]s</Expiration>
+</Credentials>
+</%[1]sResult>
+</%[1]sResponse>`
+
+const callerIdentityResponse = `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+<GetCallerIdentityResult>
+<Arn>arn:aws:iam::123456789012:user/mock</Arn>
+<UserId>AIDAMOCK</UserId>
+<Account>123456789012</Account>
+</GetCallerIdentityResult>
+</GetCallerIdentityResponse>`
+
 func TestApp_wrapCmd(t *testing.T) {
 	t.Run("nil input", func(t *testing.T) {
 		if len(wrapCmd(nil)) > 0 {
@@ -156,7 +389,7 @@ func ExampleApp_printCreds_profile() {
 		"E2": "V2",
 	}
 
-	printCreds(m)
+	printCreds(writeCreds, m)
 	// Unordered output:
 	//
 	// export E1='V1'
@@ -170,9 +403,107 @@ func ExampleApp_printCreds_no_profile() {
 		"E2": "V2",
 	}
 
-	printCreds(m)
+	printCreds(writeCreds, m)
 	// Unordered output:
 	//
 	// export E1='V1'
 	// export E2='V2'
 }
+
+func ExampleApp_printCreds_powershell() {
+	m := map[string]string{
+		"AWS_ACCESS_KEY_ID":     "mockAK",
+		"AWS_SECRET_ACCESS_KEY": "mockSK",
+		"AWS_SESSION_TOKEN":     "mockST",
+		"AWS_REGION":            "us-east-2",
+		"E1":                    "it's",
+	}
+
+	printCreds(writePowerShellCreds, m)
+	// Output:
+	// $env:AWS_ACCESS_KEY_ID = 'mockAK'
+	// $env:AWS_REGION = 'us-east-2'
+	// $env:AWS_SECRET_ACCESS_KEY = 'mockSK'
+	// $env:AWS_SESSION_TOKEN = 'mockST'
+	// $env:E1 = 'it''s'
+	// if (Get-Command Set-AWSCredential -ErrorAction SilentlyContinue) {
+	//   Set-AWSCredential -AccessKey 'mockAK' -SecretKey 'mockSK' -SessionToken 'mockST' -Scope Global
+	//   Set-DefaultAWSRegion -Region 'us-east-2' -Scope Global
+	// }
+}
+
+func TestApp_configureTransport(t *testing.T) {
+	origOpts := *opts
+	defer func() {
+		*opts = origOpts
+		_ = shared.SetDefaultTransport(shared.TransportOptions{})
+	}()
+
+	var conns int
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	s.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns++
+		}
+	}
+	s.Start()
+	defer s.Close()
+
+	if err := configureTransport(true); err != nil {
+		t.Fatal(err)
+	}
+
+	if !opts.Offline || opts.Transport != client.OfflineTransport {
+		t.Error("offline options not set")
+	}
+
+	// nothing using the shared transport may reach the network in offline mode
+	res, err := (&http.Client{Transport: shared.DefaultTransport()}).Get(s.URL)
+	if err == nil {
+		_ = res.Body.Close()
+	}
+
+	if !errors.Is(err, client.ErrOffline) || conns > 0 {
+		t.Errorf("request was not blocked: %v, %d connections", err, conns)
+	}
+}
+
+func TestApp_startTracing(t *testing.T) {
+	t.Run("unset", func(t *testing.T) {
+		if err := startTracing(""); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		tp := otel.GetTracerProvider()
+		defer func() {
+			otel.SetTracerProvider(tp)
+			stopTracing = func() {}
+		}()
+
+		f := filepath.Join(t.TempDir(), "trace.json")
+		if err := startTracing(f); err != nil {
+			t.Fatal(err)
+		}
+
+		_, span := shared.StartSpan(context.Background(), "test-span")
+		shared.EndSpan(span, nil)
+		stopTracing()
+
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(data), "test-span") {
+			t.Errorf("span was not written: %s", data)
+		}
+	})
+
+	t.Run("bad file", func(t *testing.T) {
+		if err := startTracing(filepath.Join(t.TempDir(), "missing", "trace.json")); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
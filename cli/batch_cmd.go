@@ -0,0 +1,266 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/urfave/cli/v2"
+)
+
+const batchDesc = `Assume the same role in multiple AWS accounts concurrently, and print a map of the
+credentials for each account.  The role name is taken from the --role option, or the role_arn
+of the profile.  The accounts are taken from the --account option, or are every account in the
+list of roles available to the profile (the SAML assertion, for SAML profiles) which has a role
+with that name.  Credentials are printed for all accounts which succeeded, even if some failed.`
+
+var batchCmd = &cli.Command{
+	Name:         "batch",
+	Usage:        "Assume a role in multiple accounts, and print the credentials for each account",
+	ArgsUsage:    "profile_name",
+	Description:  batchDesc,
+	Flags:        []cli.Flag{batchRoleFlag, batchAccountFlag, batchOutputFlag, batchParallelFlag},
+	BashComplete: bashCompleteProfile,
+
+	Action: func(ctx *cli.Context) error {
+		_, cfg, err := resolveConfig(ctx, 1)
+		if err != nil {
+			return err
+		}
+
+		if len(cfg.WebIdentityUrl) > 0 {
+			return errors.New("detected Web Identity profile, only IAM and SAML profiles support batch credentials")
+		}
+
+		format := ctx.String(batchOutputFlag.Name)
+		if !strings.EqualFold(format, "json") && !strings.EqualFold(format, "ini") {
+			return fmt.Errorf("invalid output format: %s", format)
+		}
+
+		name := ctx.String(batchRoleFlag.Name)
+		if len(name) < 1 {
+			if name = roleName(cfg.RoleArn); len(name) < 1 {
+				return errors.New("role name not found, set the --role option, or the role_arn of the profile")
+			}
+		}
+
+		c, err := clientFactory.Get(cfg)
+		if err != nil {
+			return err
+		}
+
+		targets, err := batchTargets(c, cfg, name, ctx.StringSlice(batchAccountFlag.Name))
+		if err != nil {
+			return err
+		}
+
+		creds, err := batchCredentials(targets, ctx.Int(batchParallelFlag.Name), func(roleArn string) (*credentials.Credentials, error) {
+			cl, cErr := clientFactory.Get(batchConfig(cfg, roleArn))
+			if cErr != nil {
+				return nil, cErr
+			}
+			return cl.Credentials()
+		})
+
+		if pErr := printBatchCredentials(os.Stdout, creds, format); pErr != nil {
+			return pErr
+		}
+		return err
+	},
+}
+
+var batchRoleFlag = &cli.StringFlag{
+	Name:    "role",
+	Aliases: []string{"r"},
+	Usage:   "the name of the role to assume in each account, defaults to the name of the profile role_arn",
+}
+
+var batchAccountFlag = &cli.StringSliceFlag{
+	Name:    "account",
+	Aliases: []string{"A"},
+	Usage:   "the AWS account ID or alias (SAML only) to assume the role in, may be repeated",
+}
+
+var batchOutputFlag = &cli.StringFlag{
+	Name:    "output",
+	Aliases: []string{"O"},
+	Usage:   "output format, valid values: json or ini",
+	Value:   "json",
+}
+
+var batchParallelFlag = &cli.IntFlag{
+	Name:    "parallel",
+	Aliases: []string{"p"},
+	Usage:   "the maximum number of accounts to get credentials for at the same time",
+	Value:   5,
+}
+
+// batchCredential is the credentials for a single account in the output of the batch command.
+type batchCredential struct {
+	RoleArn         string
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// roleName returns the name of the role (without any path) for the role ARN.  An empty string is returned if the
+// value isn't a role ARN, or is a role pattern.
+func roleName(roleArn string) string {
+	a, err := arn.Parse(roleArn)
+	if err != nil || credentials.IsRolePattern(roleArn) || !strings.HasPrefix(a.Resource, "role/") {
+		return ""
+	}
+	return a.Resource[strings.LastIndex(a.Resource, "/")+1:]
+}
+
+// batchTargets returns a map of account ID to the ARN of the named role in that account.  If all filters are account
+// IDs, the role ARNs are built from them directly.  Otherwise the roles available to the client are searched for the
+// named role in the accounts matching the filters (or all accounts, if there are no filters).
+func batchTargets(c client.AwsClient, cfg *config.AwsConfig, name string, filters []string) (map[string]string, error) {
+	targets := make(map[string]string)
+
+	if len(filters) > 0 && accountIdFilters(filters) {
+		partition := "aws"
+		if a, err := arn.Parse(cfg.RoleArn); err == nil {
+			partition = a.Partition
+		}
+
+		for _, id := range filters {
+			targets[id] = arn.ARN{Partition: partition, Service: "iam", AccountID: id, Resource: "role/" + name}.String()
+		}
+		return targets, nil
+	}
+
+	roles, err := c.Roles()
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := accountAliases(c, cfg, !accountIdFilters(filters))
+
+	for id, r := range filterAccounts(*roles, aliases, filters) {
+		for _, role := range r {
+			if roleName(role) == name {
+				targets[id] = role
+				break
+			}
+		}
+	}
+
+	if len(targets) < 1 {
+		return nil, fmt.Errorf("role %s not found in any account", name)
+	}
+	return targets, nil
+}
+
+// batchConfig returns a copy of the configuration which assumes the role.  The profile name is removed so each role
+// gets its own credential cache, the same as using a role ARN as the profile name.
+func batchConfig(cfg *config.AwsConfig, roleArn string) *config.AwsConfig {
+	c := *cfg
+	c.RoleArn = roleArn
+	c.ExpectedAccountId = ""
+
+	if len(c.SamlUrl) < 1 && len(c.SrcProfile) < 1 {
+		// the profile holds the IAM credentials used to assume the role
+		c.SetSourceProfile(&config.AwsConfig{ProfileName: cfg.ProfileName})
+	}
+	c.ProfileName = ""
+
+	return &c
+}
+
+// batchCredentials calls get for the role ARN of each account, with at most parallel calls running at once.  The first
+// account is done on its own, so any MFA prompt or identity provider login happens once, and is re-used for the other
+// accounts.  The credentials for all accounts which succeeded are returned, along with the errors for those which didn't.
+func batchCredentials(targets map[string]string, parallel int, get func(string) (*credentials.Credentials, error)) (map[string]*batchCredential, error) {
+	creds := make(map[string]*batchCredential)
+	errs := make([]error, 0)
+	mu := new(sync.Mutex)
+
+	fetch := func(id string) {
+		c, err := get(targets[id])
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("account %s: %w", id, err))
+			return
+		}
+
+		creds[id] = &batchCredential{
+			RoleArn:         targets[id],
+			AccessKeyId:     c.AccessKeyId,
+			SecretAccessKey: c.SecretAccessKey,
+			SessionToken:    c.Token,
+			Expiration:      c.Expiration,
+		}
+	}
+
+	ids := slices.Sorted(maps.Keys(targets))
+	if len(ids) < 1 {
+		return creds, nil
+	}
+
+	fetch(ids[0])
+
+	sem := make(chan struct{}, max(parallel, 1))
+	wg := new(sync.WaitGroup)
+	for _, id := range ids[1:] {
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			fetch(id)
+		})
+	}
+	wg.Wait()
+
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return creds, errors.Join(errs...)
+}
+
+// printBatchCredentials writes the credentials as a JSON object keyed by account ID, or in ini format with a section
+// for each account ID, suitable for use as an AWS credentials file.
+func printBatchCredentials(w io.Writer, creds map[string]*batchCredential, format string) error {
+	if strings.EqualFold(format, "ini") {
+		for _, id := range slices.Sorted(maps.Keys(creds)) {
+			c := creds[id]
+			_, _ = fmt.Fprintf(w, "# %s expires %s\n[%s]\n", c.RoleArn, c.Expiration.UTC().Format(time.RFC3339), id)
+			_, _ = fmt.Fprintf(w, "aws_access_key_id = %s\naws_secret_access_key = %s\n", c.AccessKeyId, c.SecretAccessKey)
+			_, _ = fmt.Fprintf(w, "aws_session_token = %s\n\n", c.SessionToken)
+		}
+		return nil
+	}
+
+	out, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "%s\n", out)
+	return nil
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+func TestRoleName(t *testing.T) {
+	tests := map[string]string{
+		"arn:aws:iam::123456789012:role/Admin":         "Admin",
+		"arn:aws:iam::123456789012:role/path/to/Admin": "Admin",
+		"arn:aws:iam::*:role/Admin":                    "",
+		"arn:aws:iam::123456789012:user/bob":           "",
+		"Admin":                                        "",
+		"":                                             "",
+	}
+
+	for k, v := range tests {
+		if n := roleName(k); n != v {
+			t.Errorf("data mismatch for %s, got %s", k, n)
+		}
+	}
+}
+
+func TestBatchTargets(t *testing.T) {
+	cl := new(mockAwsClient)
+	cfg := &config.AwsConfig{RoleArn: "arn:aws-us-gov:iam::123456789012:role/Admin"}
+
+	t.Run("account ids", func(t *testing.T) {
+		tgt, err := batchTargets(cl, cfg, "Admin", []string{"123456789012", "210987654321"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(tgt) != 2 || tgt["210987654321"] != "arn:aws-us-gov:iam::210987654321:role/Admin" {
+			t.Errorf("data mismatch: %v", tgt)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if _, err := batchTargets(cl, cfg, "Admin", nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestBatchConfig(t *testing.T) {
+	t.Run("iam", func(t *testing.T) {
+		cfg := &config.AwsConfig{ProfileName: "p", ExpectedAccountId: "123456789012"}
+		c := batchConfig(cfg, "arn:aws:iam::210987654321:role/Admin")
+
+		if len(c.ProfileName) > 0 || len(c.ExpectedAccountId) > 0 || c.SrcProfile != "p" || c.SourceProfile() == nil {
+			t.Errorf("data mismatch: %+v", c)
+		}
+
+		if cfg.ProfileName != "p" || len(cfg.RoleArn) > 0 {
+			t.Error("original configuration was modified")
+		}
+	})
+
+	t.Run("saml", func(t *testing.T) {
+		c := batchConfig(&config.AwsConfig{ProfileName: "p", SamlUrl: "https://idp"}, "arn:aws:iam::210987654321:role/Admin")
+		if len(c.ProfileName) > 0 || len(c.SrcProfile) > 0 || c.RoleArn != "arn:aws:iam::210987654321:role/Admin" {
+			t.Errorf("data mismatch: %+v", c)
+		}
+	})
+}
+
+func TestBatchCredentials(t *testing.T) {
+	targets := map[string]string{
+		"111111111111": "arn:aws:iam::111111111111:role/Admin",
+		"222222222222": "arn:aws:iam::222222222222:role/Admin",
+		"333333333333": "arn:aws:iam::333333333333:role/Admin",
+	}
+
+	calls := new(atomic.Int32)
+	creds, err := batchCredentials(targets, 2, func(r string) (*credentials.Credentials, error) {
+		calls.Add(1)
+		if strings.Contains(r, "222222222222") {
+			return nil, errors.New("access denied")
+		}
+		return &credentials.Credentials{AccessKeyId: r}, nil
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "222222222222") {
+		t.Errorf("did not receive expected error: %v", err)
+	}
+
+	if calls.Load() != 3 || len(creds) != 2 || creds["333333333333"].AccessKeyId != targets["333333333333"] {
+		t.Errorf("data mismatch: %v", creds)
+	}
+}
+
+func TestPrintBatchCredentials(t *testing.T) {
+	creds := map[string]*batchCredential{
+		"123456789012": {RoleArn: "arn:aws:iam::123456789012:role/Admin", AccessKeyId: "AKIAMOCK", SecretAccessKey: "secret", SessionToken: "token"},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		b := new(bytes.Buffer)
+		if err := printBatchCredentials(b, creds, "json"); err != nil {
+			t.Fatal(err)
+		}
+
+		m := make(map[string]*batchCredential)
+		if err := json.Unmarshal(b.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+
+		if m["123456789012"] == nil || m["123456789012"].SessionToken != "token" {
+			t.Errorf("data mismatch: %s", b)
+		}
+	})
+
+	t.Run("ini", func(t *testing.T) {
+		b := new(bytes.Buffer)
+		if err := printBatchCredentials(b, creds, "INI"); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(b.String(), "[123456789012]\naws_access_key_id = AKIAMOCK\n") {
+			t.Errorf("data mismatch: %s", b)
+		}
+	})
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/urfave/cli/v2"
+)
+
+var cacheCmd = &cli.Command{
+	Name:        "cache",
+	Usage:       "Manage cached credentials",
+	ArgsUsage:   " ", // this hides the default '[arguments...]' help text output, since we don't use command args here
+	Subcommands: []*cli.Command{cacheListCmd, cacheClearCmd},
+}
+
+var cacheFmtFlag = &cli.StringFlag{
+	Name:    "output",
+	Aliases: []string{"O"},
+	Usage:   "output format, valid values: table or json",
+	Value:   "table",
+}
+
+const cacheListDesc = `Show the credentials cached on the local system, along with the profile (and role) they
+belong to, and their expiration time.  The optional 'profile_name' is only used to find the
+cache_dir setting, if configured.  Credentials stored in shared cache backends (like redis)
+are not shown.`
+
+var cacheListCmd = &cli.Command{
+	Name:         "list",
+	Aliases:      []string{"ls"},
+	Usage:        "Show cached credentials and their expiration",
+	ArgsUsage:    "[profile_name]",
+	Description:  cacheListDesc,
+	Flags:        []cli.Flag{cacheFmtFlag},
+	BashComplete: bashCompleteProfile,
+
+	Action: func(ctx *cli.Context) error {
+		_, cfg, err := resolveConfig(ctx, 1)
+		if err != nil {
+			return err
+		}
+
+		entries, err := client.CacheEntries(cfg)
+		if err != nil {
+			return err
+		}
+
+		return printCacheEntries(os.Stdout, cacheEntryRoles(entries), ctx.String(cacheFmtFlag.Name))
+	},
+}
+
+var cacheCookiesFlag = &cli.BoolFlag{
+	Name:  "cookies",
+	Usage: "clear the identity provider session cookies",
+}
+
+var cacheIdpFlag = &cli.BoolFlag{
+	Name:  "idp",
+	Usage: "clear the SAML and Web Identity (OIDC) tokens and credentials",
+}
+
+var cacheAllFlag = &cli.BoolFlag{
+	Name:  "all",
+	Usage: "clear all cached data",
+}
+
+const cacheClearDesc = `Clear cached data.  If only 'profile_name' is provided, the cached role credentials for the
+profile are cleared.  Use the --idp flag to clear the identity token and SAML or Web Identity
+(OIDC) credentials (for the profile, if provided) and the --cookies flag to clear identity
+provider session cookies.  Without a profile, one of --idp, --cookies, or --all is required,
+and applies to every profile.`
+
+var cacheClearCmd = &cli.Command{
+	Name:         "clear",
+	Usage:        "Clear cached credentials, identity provider data, or cookies",
+	ArgsUsage:    "[profile_name]",
+	Description:  cacheClearDesc,
+	Flags:        []cli.Flag{cacheCookiesFlag, cacheIdpFlag, cacheAllFlag},
+	BashComplete: bashCompleteProfile,
+
+	Action: func(ctx *cli.Context) error {
+		profile, cfg, err := resolveConfig(ctx, 1)
+		if err != nil {
+			return err
+		}
+
+		scope := cacheClearScope(ctx)
+		if len(profile) > 0 {
+			if scope == 0 {
+				scope = client.CacheScopeCredentials
+			}
+			return clientFactory.ClearProfileCache(cfg, scope)
+		}
+
+		if scope == 0 {
+			return errors.New("profile name, or one of --idp, --cookies, or --all is required")
+		}
+		return client.ClearCache(cfg, scope)
+	},
+}
+
+func cacheClearScope(ctx *cli.Context) client.CacheScope {
+	if ctx.Bool(cacheAllFlag.Name) {
+		return client.CacheScopeAll
+	}
+
+	var scope client.CacheScope
+	if ctx.Bool(cacheCookiesFlag.Name) {
+		scope |= client.CacheScopeCookies
+	}
+
+	if ctx.Bool(cacheIdpFlag.Name) {
+		scope |= client.CacheScopeIdentityProvider
+	}
+	return scope
+}
+
+type cacheEntry struct {
+	*client.CacheEntry
+	Role    string `json:"role,omitempty"`
+	Expired bool   `json:"expired"`
+}
+
+// cacheEntryRoles maps the cache entries back to the role configured for the profile, if any.  Session token
+// credentials don't belong to a role, and entries for profiles which can't be resolved won't have a role.
+func cacheEntryRoles(entries []*client.CacheEntry) []*cacheEntry {
+	out := make([]*cacheEntry, 0, len(entries))
+
+	for _, e := range entries {
+		ce := &cacheEntry{CacheEntry: e, Expired: e.Expired()}
+		if e.Type != client.CacheTypeSession {
+			if cfg, err := configResolver.Config(e.Profile); err == nil {
+				ce.Role = cfg.RoleArn
+			}
+		}
+		out = append(out, ce)
+	}
+	return out
+}
+
+func printCacheEntries(w io.Writer, entries []*cacheEntry, format string) error {
+	if strings.EqualFold(format, "json") {
+		out, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", out)
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "PROFILE\tTYPE\tROLE\tEXPIRATION\tSTATUS")
+	for _, e := range entries {
+		status := "valid"
+		exp := e.Expiration.Local().Format(time.RFC3339)
+		switch {
+		case len(e.Error) > 0:
+			status = "unreadable"
+			exp = "-"
+		case e.Expired:
+			status = "expired"
+		default:
+			status = fmt.Sprintf("%s (%s left)", status, time.Until(e.Expiration).Round(time.Second))
+		}
+
+		role := e.Role
+		if len(role) < 1 {
+			role = "-"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Profile, e.Type, role, exp, status)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/urfave/cli/v2"
+)
+
+func TestCacheListCmd_Action(t *testing.T) {
+	configResolver = new(mockConfigResolver)
+	t.Setenv("AWS_RUNAS_CACHE_DIR", t.TempDir())
+
+	ctx := cli.NewContext(App, new(flag.FlagSet), nil)
+	if err := cacheListCmd.Run(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCacheClearCmd_Action(t *testing.T) {
+	configResolver = new(mockConfigResolver)
+
+	t.Run("no scope", func(t *testing.T) {
+		t.Setenv("AWS_RUNAS_CACHE_DIR", t.TempDir())
+
+		ctx := cli.NewContext(App, new(flag.FlagSet), nil)
+		if err := cacheClearCmd.Run(ctx); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("cookies", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("AWS_RUNAS_CACHE_DIR", dir)
+
+		f := filepath.Join(dir, ".aws_runas.cookies")
+		if err := os.WriteFile(f, []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		// command flags are parsed from the args of the parent context
+		fs := new(flag.FlagSet)
+		_ = fs.Parse([]string{"--", "clear", "--cookies"})
+
+		ctx := cli.NewContext(App, fs, nil)
+		if err := cacheClearCmd.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Error("cookie jar was not removed")
+		}
+	})
+}
+
+func TestPrintCacheEntries(t *testing.T) {
+	entries := []*cacheEntry{
+		{CacheEntry: &client.CacheEntry{Type: client.CacheTypeRole, Profile: "good", Expiration: time.Now().Add(1 * time.Hour)},
+			Role: "arn:aws:iam::123456789012:role/Admin"},
+		{CacheEntry: &client.CacheEntry{Type: client.CacheTypeSession, Profile: "old", Expiration: time.Now().Add(-1 * time.Hour)},
+			Expired: true},
+		{CacheEntry: &client.CacheEntry{Type: client.CacheTypeSaml, Profile: "bad", Error: "corrupt"}, Expired: true},
+	}
+
+	t.Run("table", func(t *testing.T) {
+		sb := new(bytes.Buffer)
+		if err := printCacheEntries(sb, entries, "table"); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+		if len(lines) != 4 {
+			t.Fatalf("unexpected output: %s", sb.String())
+		}
+
+		for i, s := range []string{"valid", "expired", "unreadable"} {
+			if !strings.Contains(lines[i+1], s) {
+				t.Errorf("missing status %s: %s", s, lines[i+1])
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		sb := new(bytes.Buffer)
+		if err := printCacheEntries(sb, entries, "json"); err != nil {
+			t.Fatal(err)
+		}
+
+		out := make([]map[string]any, 0)
+		if err := json.Unmarshal(sb.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(out) != 3 || out[0]["role"] != entries[0].Role || out[1]["expired"] != true {
+			t.Errorf("unexpected output: %s", sb.String())
+		}
+	})
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// clipboardCopyCmds are the programs which write stdin to the clipboard on each platform, in order of preference.
+var clipboardCopyCmds = map[string][][]string{
+	"darwin":  {{"pbcopy"}},
+	"windows": {{"clip"}},
+	"linux":   {{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}},
+}
+
+// clipboardPasteCmds are the programs which write the clipboard to stdout on each platform, in order of preference.
+var clipboardPasteCmds = map[string][][]string{
+	"darwin":  {{"pbpaste"}},
+	"windows": {{"powershell", "-NoProfile", "-Command", "Get-Clipboard"}},
+	"linux":   {{"wl-paste", "-n"}, {"xclip", "-selection", "clipboard", "-o"}, {"xsel", "--clipboard", "--output"}},
+}
+
+// clipboardExec runs the first available command, with in as stdin, returning the output if output is true.  The
+// output is only collected when reading the clipboard, since copy programs like xclip stay in the background to
+// serve the clipboard, which holds a stdout pipe open and keeps Output() waiting.  It's a variable so tests can avoid
+// using the real clipboard.
+var clipboardExec = func(cmds [][]string, in string, output bool) ([]byte, error) {
+	for _, c := range cmds {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			continue
+		}
+
+		cmd := exec.Command(c[0], c[1:]...) //nolint:gosec
+		cmd.Stdin = bytes.NewBufferString(in)
+		if output {
+			return cmd.Output()
+		}
+		return nil, cmd.Run()
+	}
+	return nil, errors.New("no clipboard program found")
+}
+
+// copyOutput places the output on the clipboard, instead of printing it.  If clear is greater than 0, this waits for
+// that long, then clears the clipboard (as long as it still holds the output).
+func copyOutput(out string, clear time.Duration) error {
+	if _, err := clipboardExec(clipboardCopyCmds[runtime.GOOS], out, false); err != nil {
+		return fmt.Errorf("unable to copy to clipboard: %w", err)
+	}
+
+	if clear <= 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "copied to clipboard")
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "copied to clipboard, clearing in %s\n", clear)
+	time.Sleep(clear)
+
+	// don't clear something else the user copied in the meantime, but do clear if we can't tell
+	if v, err := clipboardExec(clipboardPasteCmds[runtime.GOOS], "", true); err == nil && string(bytes.TrimSpace(v)) != string(bytes.TrimSpace([]byte(out))) {
+		log.Debugf("clipboard contents changed, not clearing")
+		return nil
+	}
+
+	if _, err := clipboardExec(clipboardCopyCmds[runtime.GOOS], "", false); err != nil {
+		return fmt.Errorf("unable to clear clipboard: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCopyOutput(t *testing.T) {
+	orig := clipboardExec
+	defer func() { clipboardExec = orig }()
+
+	var clipboard string
+	mockClipboard := func(cmds [][]string, in string, output bool) ([]byte, error) {
+		if output && reflect.DeepEqual(cmds, clipboardPasteCmds[runtime.GOOS]) {
+			return []byte(clipboard), nil
+		}
+		clipboard = in
+		return nil, nil
+	}
+
+	t.Run("copy", func(t *testing.T) {
+		clipboardExec = mockClipboard
+		if err := copyOutput("secret", 0); err != nil {
+			t.Fatal(err)
+		}
+
+		if clipboard != "secret" {
+			t.Errorf("data mismatch, got %s", clipboard)
+		}
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		clipboardExec = mockClipboard
+		if err := copyOutput("secret\n", time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(clipboard) > 0 {
+			t.Errorf("clipboard was not cleared: %s", clipboard)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		clipboardExec = func([][]string, string, bool) ([]byte, error) { return nil, errors.New("no clipboard") }
+		if err := copyOutput("secret", 0); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestClipboardExec(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+
+	t.Run("background copy", func(t *testing.T) {
+		// like xclip, leave a process in the background which inherited stdout and stderr
+		start := time.Now()
+		if _, err := clipboardExec([][]string{{"sh", "-c", "cat >/dev/null; sleep 5 &"}}, "secret", false); err != nil {
+			t.Fatal(err)
+		}
+
+		if time.Since(start) > 3*time.Second {
+			t.Error("waited for the background process")
+		}
+	})
+
+	t.Run("output", func(t *testing.T) {
+		out, err := clipboardExec([][]string{{"not-a-clipboard"}, {"cat"}}, "secret", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(out) != "secret" {
+			t.Errorf("data mismatch, got %s", out)
+		}
+	})
+}
@@ -0,0 +1,403 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/shared"
+	"github.com/skip2/go-qrcode"
+	"github.com/urfave/cli/v2"
+)
+
+const consoleDesc = `Open the AWS console in a browser, signed in with the credentials of the profile.  The
+browser is set with the --browser flag, or the console_browser profile attribute, and can be
+'chrome', 'msedge', 'chromium', 'firefox', or the path to the browser program.  The system
+default browser is used if not set.  Use --browser-profile (console_browser_profile) to open the
+console in a named browser profile, or --container (console_container) to open it in a Firefox
+Multi-Account Container, so console sessions for different profiles don't log each other out.
+Containers require the 'Open external links in a container' Firefox add-on.
+
+The optional destination is the page to open after signing in.  It can be a service name with an
+optional region (like 'ec2 us-west-2', the profile region is used if not set), a console path
+(like '/cloudwatch/home#logsV2:'), or a full console URL.
+
+The --qr flag shows the sign-in URL as a QR code in the terminal, to open the console on a phone,
+tablet, or another computer.  Anyone able to scan the code can use the console session, and the
+URL can be used to sign in for 15 minutes.
+
+The console session lasts as long as the role credentials have left, unless --console-duration
+(console_session_duration) is set.  The duration is limited to between 15 minutes and 12 hours,
+and to 1 hour for chained roles (a jump role, or a source profile using a role), since AWS limits
+chained role sessions to 1 hour.  The effective console session duration is shown after signing in.`
+
+var consoleCmd = &cli.Command{
+	Name:        "console",
+	Usage:       "Open the AWS console in a browser using the profile credentials",
+	ArgsUsage:   "profile_name [service [region] | console_path]",
+	Description: consoleDesc,
+	Flags: []cli.Flag{consolePrintFlag, consoleQrFlag, consoleBrowserFlag, consoleBrowserProfileFlag, consoleContainerFlag,
+		consoleDurationFlag, copyFlag, copyClearFlag},
+	BashComplete: bashCompleteProfile,
+
+	Action: func(ctx *cli.Context) error {
+		_, cfg, err := resolveConfig(ctx, 1)
+		if err != nil {
+			return err
+		}
+
+		signin, console := federationEndpoints(cfg.Region)
+		dest, err := consoleDestination(console, cfg.Region, ctx.Args().Tail())
+		if err != nil {
+			return err
+		}
+
+		cfg.MergeIn(&config.AwsConfig{
+			ConsoleBrowser:        ctx.String(consoleBrowserFlag.Name),
+			ConsoleBrowserProfile: ctx.String(consoleBrowserProfileFlag.Name),
+			ConsoleContainer:      ctx.String(consoleContainerFlag.Name),
+		})
+
+		c, err := clientFactory.Get(cfg)
+		if err != nil {
+			return err
+		}
+
+		creds, err := c.Credentials()
+		if err != nil {
+			return err
+		}
+
+		rt := opts.Transport
+		if rt == nil {
+			rt = shared.DefaultTransport()
+		}
+
+		d := consoleSessionDuration(cfg, creds, time.Now())
+		token, err := signinToken(ctx.Context, &http.Client{Transport: rt, Timeout: 30 * time.Second}, signin, creds, d)
+		if err != nil {
+			return err
+		}
+
+		if d > 0 {
+			log.Infof("console session valid for %s", d)
+		}
+
+		u := loginUrl(signin, dest, token)
+		if ctx.Bool(copyFlag.Name) {
+			return copyOutput(u, ctx.Duration(copyClearFlag.Name))
+		}
+
+		if ctx.Bool(consolePrintFlag.Name) {
+			fmt.Println(u)
+			return nil
+		}
+
+		if ctx.Bool(consoleQrFlag.Name) {
+			return writeQrCode(os.Stdout, u)
+		}
+
+		args, err := browserArgs(runtime.GOOS, cfg, u)
+		if err != nil {
+			return err
+		}
+
+		log.Debugf("console browser command: %s", args[0])
+		return exec.Command(args[0], args[1:]...).Start() //nolint:gosec
+	},
+}
+
+var consolePrintFlag = &cli.BoolFlag{
+	Name:    "print",
+	Aliases: []string{"p"},
+	Usage:   "print the console sign-in URL, instead of opening a browser",
+}
+
+var consoleQrFlag = &cli.BoolFlag{
+	Name:  "qr",
+	Usage: "show the console sign-in URL as a QR code, instead of opening a browser",
+}
+
+var consoleBrowserFlag = &cli.StringFlag{
+	Name:    "browser",
+	Aliases: []string{"b"},
+	Usage:   "the browser to open the console in, valid values: chrome, msedge, chromium, firefox, or a program path",
+}
+
+var consoleBrowserProfileFlag = &cli.StringFlag{
+	Name:  "browser-profile",
+	Usage: "the name of the browser profile to open the console in",
+}
+
+var consoleContainerFlag = &cli.StringFlag{
+	Name:  "container",
+	Usage: "the name of the Firefox Multi-Account Container to open the console in",
+}
+
+var consoleDurationFlag = &cli.GenericFlag{
+	Name:        "console-duration",
+	Usage:       "duration of the console session, as a duration string (8h, 45m) or number of seconds",
+	EnvVars:     []string{"CONSOLE_SESSION_DURATION"},
+	DefaultText: "the time left for the role credentials",
+	Value:       newDurationValue(&cmdlineCfg.ConsoleSessionDuration),
+}
+
+// Limits of the console session duration set using the SessionDuration parameter of the sign-in token request.
+const (
+	consoleSessionMin        = 15 * time.Minute
+	consoleSessionMax        = 12 * time.Hour // the federation endpoint limit
+	consoleSessionChainedMax = 1 * time.Hour  // AWS limits chained role sessions to 1 hour
+)
+
+// consoleSessionDuration returns the duration of the console session for the credentials of the profile.  The duration
+// set in the configuration is used, or the time left for the credentials if not set, limited to the range allowed by
+// the federation endpoint.  Roles assumed with the credentials of another role (a jump role, or a source profile using
+// a role) are chained, and limited to 1 hour.  Zero is returned for profiles which don't use a role, so the
+// SessionDuration parameter is not sent, since the federation endpoint rejects it for GetFederationToken credentials.
+func consoleSessionDuration(cfg *config.AwsConfig, creds *credentials.Credentials, now time.Time) time.Duration {
+	switch cfg.ProfileType() {
+	case config.ProfileTypeRole, config.ProfileTypeSaml, config.ProfileTypeOidc:
+	default:
+		return 0
+	}
+
+	limit := consoleSessionMax
+	if len(cfg.JumpRoleArn) > 0 || (cfg.SourceProfile() != nil && len(cfg.SourceProfile().RoleArn) > 0) {
+		limit = consoleSessionChainedMax
+	}
+
+	d := cfg.ConsoleSessionDuration
+	if d <= 0 {
+		d = consoleSessionChainedMax
+		if !creds.Expiration.IsZero() {
+			d = creds.Expiration.Sub(now)
+		}
+	} else if d > limit {
+		log.Warningf("console session duration %s is more than the %s allowed for this role, using %s", d, limit, limit)
+	}
+
+	return min(max(d, consoleSessionMin), limit).Truncate(time.Second)
+}
+
+// writeQrCode writes the content to w as a QR code drawn using unicode block characters, for a terminal with a dark
+// background.  The lowest error correction level is used, since it keeps the code for a long sign-in URL small enough
+// to fit in a terminal.
+func writeQrCode(w io.Writer, content string) error {
+	q, err := qrcode.New(content, qrcode.Low)
+	if err != nil {
+		return fmt.Errorf("unable to create QR code: %w", err)
+	}
+
+	_, err = io.WriteString(w, q.ToSmallString(false))
+	return err
+}
+
+// federationEndpoints returns the URLs of the AWS federation (sign-in) endpoint and the console for the partition of
+// the region.
+func federationEndpoints(region string) (string, string) {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return "https://signin.amazonaws-us-gov.com/federation", "https://console.amazonaws-us-gov.com/"
+	case strings.HasPrefix(region, "cn-"):
+		return "https://signin.amazonaws.cn/federation", "https://console.amazonaws.cn/"
+	default:
+		return "https://signin.aws.amazon.com/federation", "https://console.aws.amazon.com/"
+	}
+}
+
+// consoleDestination returns the console URL to open after signing in.  The args are either empty (the console home
+// page), a service name and optional region, a path on the console, or a full https URL.
+func consoleDestination(console, region string, args []string) (string, error) {
+	if len(args) > 2 {
+		return "", errors.New("too many console destination arguments")
+	}
+
+	if len(args) > 1 {
+		region = args[1]
+	}
+
+	if len(args) < 1 || len(args[0]) < 1 {
+		if len(region) > 0 {
+			return fmt.Sprintf("%sconsole/home?region=%s", console, url.QueryEscape(region)), nil
+		}
+		return console, nil
+	}
+
+	dest := args[0]
+	switch {
+	case strings.HasPrefix(dest, "https://"):
+		if len(args) > 1 {
+			return "", errors.New("a region can not be used with a console URL")
+		}
+		return dest, nil
+	case strings.HasPrefix(dest, "/"):
+		if len(args) > 1 {
+			return "", errors.New("a region can not be used with a console path")
+		}
+		return strings.TrimSuffix(console, "/") + dest, nil
+	case !consoleServiceRe.MatchString(dest):
+		return "", fmt.Errorf("invalid console service name: %s", dest)
+	}
+
+	u := console + strings.ToLower(dest) + "/home"
+	if len(region) > 0 {
+		u += "?region=" + url.QueryEscape(region)
+	}
+	return u, nil
+}
+
+var consoleServiceRe = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// signinToken exchanges the credentials for a console sign-in token with the AWS federation endpoint.  Only temporary
+// credentials from an assume role (or federation token) call can be exchanged.  If duration is greater than 0, it is
+// sent as the duration of the console session.
+func signinToken(ctx context.Context, hc *http.Client, endpoint string, creds *credentials.Credentials, duration time.Duration) (string, error) {
+	session, err := json.Marshal(map[string]string{
+		"sessionId":    creds.AccessKeyId,
+		"sessionKey":   creds.SecretAccessKey,
+		"sessionToken": creds.Token,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("Action", "getSigninToken")
+	q.Set("Session", string(session))
+	if duration > 0 {
+		q.Set("SessionDuration", strconv.FormatInt(int64(duration.Seconds()), 10))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), http.NoBody)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := hc.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("console sign-in token request failed: %s (only role credentials are supported)", res.Status)
+	}
+
+	body := struct{ SigninToken string }{}
+	if err = json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid console sign-in token response: %w", err)
+	}
+
+	if len(body.SigninToken) < 1 {
+		return "", errors.New("console sign-in token not found in response")
+	}
+	return body.SigninToken, nil
+}
+
+// loginUrl returns the URL which signs in to the console with the sign-in token, then loads the destination URL.
+func loginUrl(endpoint, destination, token string) string {
+	q := url.Values{}
+	q.Set("Action", "login")
+	q.Set("Issuer", "aws-runas")
+	q.Set("Destination", destination)
+	q.Set("SigninToken", token)
+
+	return endpoint + "?" + q.Encode()
+}
+
+// consoleBrowsers are the programs for the known browsers on each platform.  For macOS, they are application names
+// for the 'open' command.
+var consoleBrowsers = map[string]map[string]string{
+	"chrome":   {"linux": "google-chrome", "darwin": "Google Chrome", "windows": "chrome"},
+	"msedge":   {"linux": "microsoft-edge", "darwin": "Microsoft Edge", "windows": "msedge"},
+	"chromium": {"linux": "chromium", "darwin": "Chromium", "windows": "chromium"},
+	"firefox":  {"linux": "firefox", "darwin": "Firefox", "windows": "firefox"},
+}
+
+// browserArgs returns the command line to open the URL using the browser settings of the configuration.  The system
+// default browser is used if no browser, browser profile, or container is configured.  Containers are only supported
+// with Firefox, which is the default browser for them.
+func browserArgs(goos string, cfg *config.AwsConfig, u string) ([]string, error) {
+	browser := cfg.ConsoleBrowser
+	extra := make([]string, 0)
+
+	if len(cfg.ConsoleContainer) > 0 {
+		if len(browser) < 1 {
+			browser = "firefox"
+		}
+
+		q := url.Values{}
+		q.Set("name", cfg.ConsoleContainer)
+		q.Set("url", u)
+		u = "ext+container:" + q.Encode()
+	}
+
+	if len(browser) < 1 {
+		if len(cfg.ConsoleBrowserProfile) > 0 {
+			return nil, errors.New("a browser profile requires setting the browser")
+		}
+
+		switch goos {
+		case "linux":
+			return []string{"xdg-open", u}, nil
+		case "windows":
+			return []string{"rundll32", "url.dll,FileProtocolHandler", u}, nil
+		case "darwin":
+			return []string{"open", u}, nil
+		default:
+			return nil, errors.New("unsupported platform")
+		}
+	}
+
+	name := strings.ToLower(browser)
+	if len(cfg.ConsoleBrowserProfile) > 0 {
+		if strings.Contains(name, "firefox") {
+			extra = append(extra, "-P", cfg.ConsoleBrowserProfile)
+		} else {
+			extra = append(extra, "--profile-directory="+cfg.ConsoleBrowserProfile)
+		}
+	}
+
+	prog, known := consoleBrowsers[name][goos]
+	if !known {
+		prog = browser
+	}
+
+	switch {
+	case goos == "darwin" && known:
+		return append(append([]string{"open", "-na", prog, "--args"}, extra...), u), nil
+	case goos == "windows" && known:
+		// 'start' finds the browser using the App Paths registry key, since it's usually not in the PATH.  The title
+		// contains a space so it gets quoted, and the cmd special characters in the URL must be escaped
+		r := strings.NewReplacer("^", "^^", "&", "^&")
+		return append(append([]string{"cmd", "/c", "start", "aws-runas console", prog}, extra...), r.Replace(u)), nil
+	default:
+		return append(append([]string{prog}, extra...), u), nil
+	}
+}
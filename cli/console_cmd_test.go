@@ -0,0 +1,261 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+func TestFederationEndpoints(t *testing.T) {
+	tests := map[string]string{
+		"us-east-1":     "https://signin.aws.amazon.com/federation",
+		"":              "https://signin.aws.amazon.com/federation",
+		"us-gov-west-1": "https://signin.amazonaws-us-gov.com/federation",
+		"cn-north-1":    "https://signin.amazonaws.cn/federation",
+	}
+
+	for k, v := range tests {
+		if s, _ := federationEndpoints(k); s != v {
+			t.Errorf("data mismatch for %s, got %s", k, s)
+		}
+	}
+}
+
+func TestSigninToken(t *testing.T) {
+	creds := &credentials.Credentials{AccessKeyId: "ASIAMOCK", SecretAccessKey: "secret", Token: "token"}
+
+	t.Run("good", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m := make(map[string]string)
+			_ = json.Unmarshal([]byte(r.URL.Query().Get("Session")), &m)
+
+			if r.URL.Query().Get("Action") != "getSigninToken" || m["sessionToken"] != "token" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"SigninToken": "mock-token"}`))
+		}))
+		defer s.Close()
+
+		token, err := signinToken(context.Background(), s.Client(), s.URL, creds, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token != "mock-token" {
+			t.Errorf("data mismatch, got %s", token)
+		}
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		var d string
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d = r.URL.Query().Get("SessionDuration")
+			_, _ = w.Write([]byte(`{"SigninToken": "mock-token"}`))
+		}))
+		defer s.Close()
+
+		if _, err := signinToken(context.Background(), s.Client(), s.URL, creds, 4*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		if d != "14400" {
+			t.Errorf("unexpected session duration: %s", d)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "bad request", http.StatusBadRequest)
+		}))
+		defer s.Close()
+
+		if _, err := signinToken(context.Background(), s.Client(), s.URL, creds, 0); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestConsoleSessionDuration(t *testing.T) {
+	now := time.Now()
+	role := "arn:aws:iam::123456789012:role/Admin"
+	creds := &credentials.Credentials{Expiration: now.Add(8 * time.Hour)}
+
+	chained := &config.AwsConfig{RoleArn: role}
+	chained.SetSourceProfile(&config.AwsConfig{ProfileName: "jump", RoleArn: "arn:aws:iam::123456789012:role/Jump"})
+
+	tests := []struct {
+		name  string
+		cfg   *config.AwsConfig
+		creds *credentials.Credentials
+		want  time.Duration
+	}{
+		{"credential lifetime", &config.AwsConfig{RoleArn: role}, creds, 8 * time.Hour},
+		{"no expiration", &config.AwsConfig{RoleArn: role}, new(credentials.Credentials), time.Hour},
+		{"configured", &config.AwsConfig{RoleArn: role, ConsoleSessionDuration: 4 * time.Hour}, creds, 4 * time.Hour},
+		{"federation limit", &config.AwsConfig{RoleArn: role, ConsoleSessionDuration: 36 * time.Hour}, creds, 12 * time.Hour},
+		{"minimum", &config.AwsConfig{RoleArn: role, ConsoleSessionDuration: time.Minute}, creds, 15 * time.Minute},
+		{"jump role", &config.AwsConfig{SamlUrl: "https://idp.local/saml", RoleArn: role, JumpRoleArn: role}, creds, time.Hour},
+		{"chained source", chained, creds, time.Hour},
+		{"saml", &config.AwsConfig{SamlUrl: "https://idp.local/saml", ConsoleSessionDuration: 2 * time.Hour}, creds, 2 * time.Hour},
+		{"not a role", &config.AwsConfig{MfaSerial: "mfa", ConsoleSessionDuration: 2 * time.Hour}, creds, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if d := consoleSessionDuration(tc.cfg, tc.creds, now); d != tc.want {
+				t.Errorf("unexpected duration: %s", d)
+			}
+		})
+	}
+}
+
+func TestLoginUrl(t *testing.T) {
+	u, err := url.Parse(loginUrl("https://signin.aws.amazon.com/federation", "https://console.aws.amazon.com/", "tok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := u.Query()
+	if q.Get("Action") != "login" || q.Get("SigninToken") != "tok" || q.Get("Destination") != "https://console.aws.amazon.com/" {
+		t.Errorf("data mismatch: %s", u)
+	}
+}
+
+func TestWriteQrCode(t *testing.T) {
+	t.Run("sign-in url", func(t *testing.T) {
+		u := loginUrl("https://signin.aws.amazon.com/federation", "https://console.aws.amazon.com/", strings.Repeat("x", 1500))
+
+		b := new(strings.Builder)
+		if err := writeQrCode(b, u); err != nil {
+			t.Error(err)
+			return
+		}
+
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		if len(lines) < 10 || !strings.ContainsAny(b.String(), "█▀▄") {
+			t.Error("invalid QR code")
+		}
+	})
+
+	t.Run("too long", func(t *testing.T) {
+		if err := writeQrCode(new(strings.Builder), strings.Repeat("x", 5000)); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestBrowserArgs(t *testing.T) {
+	u := "https://signin.aws.amazon.com/federation?Action=login&SigninToken=tok"
+
+	t.Run("default", func(t *testing.T) {
+		args, err := browserArgs("linux", new(config.AwsConfig), u)
+		if err != nil || !slices.Equal(args, []string{"xdg-open", u}) {
+			t.Errorf("data mismatch: %v, %v", args, err)
+		}
+	})
+
+	t.Run("chrome profile", func(t *testing.T) {
+		cfg := &config.AwsConfig{ConsoleBrowser: "chrome", ConsoleBrowserProfile: "Profile 1"}
+		args, err := browserArgs("linux", cfg, u)
+		if err != nil || !slices.Equal(args, []string{"google-chrome", "--profile-directory=Profile 1", u}) {
+			t.Errorf("data mismatch: %v, %v", args, err)
+		}
+	})
+
+	t.Run("firefox profile macos", func(t *testing.T) {
+		cfg := &config.AwsConfig{ConsoleBrowser: "Firefox", ConsoleBrowserProfile: "work"}
+		args, err := browserArgs("darwin", cfg, u)
+		if err != nil || !slices.Equal(args, []string{"open", "-na", "Firefox", "--args", "-P", "work", u}) {
+			t.Errorf("data mismatch: %v, %v", args, err)
+		}
+	})
+
+	t.Run("container", func(t *testing.T) {
+		args, err := browserArgs("linux", &config.AwsConfig{ConsoleContainer: "dev"}, u)
+		if err != nil || len(args) != 2 || args[0] != "firefox" {
+			t.Fatalf("data mismatch: %v, %v", args, err)
+		}
+
+		q, _ := url.ParseQuery(strings.TrimPrefix(args[1], "ext+container:"))
+		if q.Get("name") != "dev" || q.Get("url") != u {
+			t.Errorf("data mismatch: %s", args[1])
+		}
+	})
+
+	t.Run("windows", func(t *testing.T) {
+		args, err := browserArgs("windows", &config.AwsConfig{ConsoleBrowser: "msedge"}, u)
+		if err != nil || args[4] != "msedge" || !strings.Contains(args[5], "^&SigninToken") {
+			t.Errorf("data mismatch: %v, %v", args, err)
+		}
+	})
+
+	t.Run("custom program", func(t *testing.T) {
+		args, err := browserArgs("linux", &config.AwsConfig{ConsoleBrowser: "/opt/brave/brave"}, u)
+		if err != nil || !slices.Equal(args, []string{"/opt/brave/brave", u}) {
+			t.Errorf("data mismatch: %v, %v", args, err)
+		}
+	})
+
+	t.Run("profile without browser", func(t *testing.T) {
+		if _, err := browserArgs("linux", &config.AwsConfig{ConsoleBrowserProfile: "work"}, u); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestConsoleDestination(t *testing.T) {
+	console := "https://console.aws.amazon.com/"
+
+	tests := []struct {
+		name, region, expected string
+		args                   []string
+		err                    bool
+	}{
+		{"home", "", console, nil, false},
+		{"home region", "us-east-2", console + "console/home?region=us-east-2", nil, false},
+		{"service", "us-east-2", console + "ec2/home?region=us-east-2", []string{"ec2"}, false},
+		{"service region", "us-east-2", console + "ec2/home?region=us-west-2", []string{"EC2", "us-west-2"}, false},
+		{"path", "", console + "cloudwatch/home#logsV2:", []string{"/cloudwatch/home#logsV2:"}, false},
+		{"url", "", "https://us-west-2.console.aws.amazon.com/s3/buckets", []string{"https://us-west-2.console.aws.amazon.com/s3/buckets"}, false},
+		{"url region", "", "", []string{"https://console.aws.amazon.com/", "us-west-2"}, true},
+		{"bad service", "", "", []string{"ec2?x=y"}, true},
+		{"too many", "", "", []string{"ec2", "us-west-2", "extra"}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := consoleDestination(console, tc.region, tc.args)
+			if (err != nil) != tc.err {
+				t.Errorf("unexpected error result: %v", err)
+				return
+			}
+
+			if d != tc.expected {
+				t.Errorf("data mismatch, got %s", d)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/shared"
 	"github.com/urfave/cli/v2"
 	"gopkg.in/ini.v1"
 	"math"
@@ -131,10 +132,22 @@ func checkExternalProviderConfig(cfg *config.AwsConfig) {
 	}
 
 	if len(cfg.SamlUrl) > 0 {
-		checkProvider(cfg.SamlUrl)
+		for _, u := range cfg.SamlUrls() {
+			checkProvider(u)
+		}
 	} else {
-		checkProvider(cfg.WebIdentityUrl)
-		if len(cfg.WebIdentityClientId) < 1 || len(cfg.WebIdentityRedirectUri) < 1 {
+		for _, u := range cfg.WebIdentityUrls() {
+			checkProvider(u)
+		}
+		if len(cfg.SubjectTokenFile) > 0 {
+			if len(cfg.WebIdentityClientId) < 1 {
+				log.Error("missing web_identity_client_id configuration")
+			}
+
+			if _, err := os.Stat(cfg.SubjectTokenFile); err != nil {
+				log.Errorf("web_identity_subject_token_file is not readable: %v", err)
+			}
+		} else if len(cfg.WebIdentityClientId) < 1 || len(cfg.WebIdentityRedirectUri) < 1 {
 			log.Error("missing web_identity_client_id and/or web_identity_redirect_uri configuration")
 		}
 	}
@@ -174,7 +187,8 @@ func checkProvider(url string) {
 	}
 
 	var res *http.Response
-	res, err = http.DefaultClient.Do(req)
+	// use the same transport as the identity provider clients, so proxy and CA bundle settings are checked too
+	res, err = (&http.Client{Transport: shared.DefaultTransport()}).Do(req)
 	if err != nil {
 		log.Errorf("error communicating with external provider endpoint: %v", err)
 		return
@@ -0,0 +1,220 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/metadata"
+	"github.com/urfave/cli/v2"
+)
+
+// dockerHostName is the name containers use to reach the credential service on the host, when not using a dedicated
+// docker network.
+const dockerHostName = "host.docker.internal"
+
+// dockerTokenEnv is the environment variable holding the authorization token for the credential endpoint, it is
+// passed to the container by name only, so the value never shows up in the docker command line.
+const dockerTokenEnv = "AWS_CONTAINER_AUTHORIZATION_TOKEN"
+
+const dockerDesc = `Run a docker container which gets credentials for 'profile_name' from a private ECS credential
+endpoint started by aws-runas.  The 'docker_run_args' are passed to the 'docker run' command,
+along with the environment variables and network configuration the AWS SDKs in the container
+need to find the service.  Credentials are refreshed automatically when they expire, for as
+long as the container is running.
+
+By default, the container reaches the service using the host.docker.internal name.  The
+--network flag runs the container in the named docker network instead, and the service
+listens on the gateway address of that network (only supported on Linux).`
+
+var dockerCmd = &cli.Command{
+	Name:         "docker",
+	Usage:        "Run a docker container using credentials served by aws-runas",
+	ArgsUsage:    "profile_name docker_run_args...",
+	Description:  dockerDesc,
+	Flags:        []cli.Flag{dockerNetworkFlag},
+	BashComplete: bashCompleteProfile,
+
+	Action: func(ctx *cli.Context) error {
+		profile, cfg, err := resolveConfig(ctx, 1)
+		if err != nil {
+			return err
+		}
+
+		args := ctx.Args().Slice()
+		if ctx.Args().First() == profile {
+			args = ctx.Args().Tail()
+		}
+
+		if len(args) < 1 {
+			return errors.New("docker run arguments are required")
+		}
+
+		c, err := clientFactory.Get(cfg)
+		if err != nil {
+			return err
+		}
+
+		if ctx.Bool(refreshFlag.Name) {
+			refreshCreds(c)
+		}
+
+		// handle any authentication up front, before the container starts asking for credentials
+		creds, err := c.Credentials()
+		if err != nil {
+			return err
+		}
+
+		bindHost, epHost, netArgs, err := dockerNetwork(ctx.String(dockerNetworkFlag.Name))
+		if err != nil {
+			return err
+		}
+
+		// the service listens on an address other containers can reach, so requests must provide the token
+		token, err := newAuthToken()
+		if err != nil {
+			return err
+		}
+
+		in := &metadata.Options{
+			Path:        metadata.DefaultEcsCredPath,
+			Profile:     cfg.ProfileName,
+			Logger:      opts.Logger,
+			AwsLogLevel: opts.AwsLogLevel,
+			AuthToken:   token,
+			EcsOnly:     true,
+		}
+
+		mcs, err := metadata.NewMetadataCredentialService(net.JoinHostPort(bindHost, "0"), in)
+		if err != nil {
+			return err
+		}
+
+		ch := make(chan bool, 1)
+		go mcs.RunNoApi(c, cfg, ch) //nolint:errcheck
+		<-ch
+
+		_, port, _ := net.SplitHostPort(mcs.Addr().String())
+		ep := fmt.Sprintf("http://%s%s", net.JoinHostPort(epHost, port), in.Path)
+		log.Debugf("ECS credential endpoint for container set to %s", ep)
+
+		cmd := exec.Command("docker", dockerRunArgs(ep, cfg, creds, netArgs, args)...)
+		// pass the token through the environment of the docker command, to keep it out of the process list
+		cmd.Env = append(os.Environ(), dockerTokenEnv+"="+token)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		installSignalHandler()
+		return cmd.Run()
+	},
+}
+
+var dockerNetworkFlag = &cli.StringFlag{
+	Name:    "network",
+	Aliases: []string{"n"},
+	Usage:   "docker network to run the container in, the credential service listens on the network gateway address",
+}
+
+// dockerGateway returns the gateway address of the docker network, it's a variable so tests can avoid calling docker.
+var dockerGateway = func(network string) (string, error) {
+	out, err := exec.Command("docker", "network", "inspect", "-f", //nolint:gosec // network name is passed as a single arg
+		"{{range .IPAM.Config}}{{.Gateway}} {{end}}", network).Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to inspect docker network %s: %w", network, err)
+	}
+
+	for _, gw := range strings.Fields(string(out)) {
+		if ip := net.ParseIP(gw); ip != nil && ip.To4() != nil {
+			return gw, nil
+		}
+	}
+	return "", fmt.Errorf("no IPv4 gateway found for docker network %s", network)
+}
+
+// dockerNetwork returns the address for the credential service to listen on, the address containers use to reach the
+// service, and the docker run arguments which make it possible.  Without a dedicated network, the service is reached
+// using the docker host-gateway, which is the docker0 bridge on Linux, and the host's loopback address for Docker
+// Desktop on other systems.
+func dockerNetwork(network string) (string, string, []string, error) {
+	if len(network) > 0 {
+		gw, err := dockerGateway(network)
+		if err != nil {
+			return "", "", nil, err
+		}
+		return gw, gw, []string{"--network", network}, nil
+	}
+
+	bindHost := "127.0.0.1"
+	if runtime.GOOS == "linux" {
+		if ip := interfaceIPv4("docker0"); len(ip) > 0 {
+			bindHost = ip
+		}
+	}
+	return bindHost, dockerHostName, []string{"--add-host", dockerHostName + ":host-gateway"}, nil
+}
+
+func interfaceIPv4(name string) string {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return ""
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return ""
+	}
+
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.IP.To4() != nil {
+			return n.IP.String()
+		}
+	}
+	return ""
+}
+
+func dockerRunArgs(endpoint string, cfg *config.AwsConfig, creds *credentials.Credentials, netArgs, args []string) []string {
+	env := map[string]string{"AWS_CONTAINER_CREDENTIALS_FULL_URI": endpoint}
+
+	if len(cfg.Region) > 0 {
+		env["AWS_REGION"] = cfg.Region
+		env["AWS_DEFAULT_REGION"] = cfg.Region
+	}
+
+	for k, v := range sessionEnv(cfg, creds) {
+		env[k] = v
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	runArgs := append([]string{"run"}, netArgs...)
+	for _, k := range keys {
+		runArgs = append(runArgs, "-e", fmt.Sprintf("%s=%s", k, env[k]))
+	}
+	runArgs = append(runArgs, "-e", dockerTokenEnv)
+	return append(runArgs, args...)
+}
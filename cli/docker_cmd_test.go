@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+func TestDockerNetwork(t *testing.T) {
+	defer func(f func(string) (string, error)) { dockerGateway = f }(dockerGateway)
+
+	t.Run("host gateway", func(t *testing.T) {
+		bind, ep, args, err := dockerNetwork("")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(bind) < 1 || ep != dockerHostName {
+			t.Error("invalid endpoint addresses")
+		}
+
+		if strings.Join(args, " ") != "--add-host host.docker.internal:host-gateway" {
+			t.Error("invalid network arguments")
+		}
+	})
+
+	t.Run("named network", func(t *testing.T) {
+		dockerGateway = func(string) (string, error) { return "172.20.0.1", nil }
+
+		bind, ep, args, err := dockerNetwork("mynet")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if bind != "172.20.0.1" || ep != bind {
+			t.Error("invalid endpoint addresses")
+		}
+
+		if strings.Join(args, " ") != "--network mynet" {
+			t.Error("invalid network arguments")
+		}
+	})
+
+	t.Run("bad network", func(t *testing.T) {
+		dockerGateway = func(string) (string, error) { return "", errors.New("error") }
+
+		if _, _, _, err := dockerNetwork("mynet"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestDockerRunArgs(t *testing.T) {
+	cfg := &config.AwsConfig{Region: "us-east-2", RoleArn: "arn:aws:iam::1234567890:role/Role"}
+	ep := "http://host.docker.internal:1234/credentials"
+
+	args := dockerRunArgs(ep, cfg, new(credentials.Credentials), []string{"--network", "mynet"}, []string{"-it", "alpine", "sh"})
+
+	if args[0] != "run" || strings.Join(args[1:3], " ") != "--network mynet" {
+		t.Error("invalid docker run arguments")
+	}
+
+	if strings.Join(args[len(args)-3:], " ") != "-it alpine sh" {
+		t.Error("container arguments not at end of docker run arguments")
+	}
+
+	s := strings.Join(args, " ")
+	for _, v := range []string{"AWS_CONTAINER_CREDENTIALS_FULL_URI=" + ep, "AWS_REGION=us-east-2",
+		"AWS_DEFAULT_REGION=us-east-2", "AWS_RUNAS_ROLE_ARN=" + cfg.RoleArn} {
+		if !strings.Contains(s, "-e "+v) {
+			t.Errorf("missing environment variable %s", v)
+		}
+	}
+
+	// the token value must only be passed through the environment of the docker command
+	if !strings.Contains(s, "-e "+dockerTokenEnv+" ") || strings.Contains(s, dockerTokenEnv+"=") {
+		t.Error("authorization token not passed by name")
+	}
+}
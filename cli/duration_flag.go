@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+// durationValue is a cli.Generic flag value which sets a time.Duration from either a Go duration string (like "8h"),
+// or a bare integer number of seconds.
+type durationValue struct {
+	dst *time.Duration
+}
+
+func newDurationValue(dst *time.Duration) *durationValue {
+	return &durationValue{dst: dst}
+}
+
+func (v *durationValue) Set(s string) error {
+	d, err := config.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*v.dst = d
+	return nil
+}
+
+func (v *durationValue) String() string {
+	if v.dst == nil || *v.dst == 0 {
+		return ""
+	}
+	return v.dst.String()
+}
+
+func (v *durationValue) Get() any {
+	if v.dst == nil {
+		return time.Duration(0)
+	}
+	return *v.dst
+}
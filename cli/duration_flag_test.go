@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDurationValue_Set(t *testing.T) {
+	t.Run("duration string", func(t *testing.T) {
+		var d time.Duration
+		if err := newDurationValue(&d).Set("8h"); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if d != 8*time.Hour {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("seconds", func(t *testing.T) {
+		var d time.Duration
+		v := newDurationValue(&d)
+		if err := v.Set("2700"); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if d != 45*time.Minute || v.String() != "45m0s" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var d time.Duration
+		if err := newDurationValue(&d).Set("forever"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
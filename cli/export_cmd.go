@@ -0,0 +1,130 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/ini.v1"
+)
+
+var exportCmd = &cli.Command{
+	Name:      "export",
+	Usage:     "Print profiles as standard AWS config file profiles which get credentials from aws-runas",
+	ArgsUsage: "[profile ...]",
+	Description: `Convert the aws-runas profiles to AWS config file profiles using only standard settings, with
+a credential_process setting which runs aws-runas to get the credentials.  This lets tools which
+don't know about aws-runas (like the AWS CLI and SDKs) use the same roles, without understanding
+the aws-runas specific configuration.  The profiles are printed in AWS config file format, to be
+added to the config file used by those tools.
+
+All SAML, Web Identity, role, and session token profiles are exported if no profile names are
+given.  IAM profiles without a role, and AWS SSO profiles, are already usable by other tools and
+are skipped.  The --prefix flag adds a prefix to the exported profile names, so they don't replace
+the aws-runas profiles if they're added to the same config file.`,
+	Flags: []cli.Flag{exportPrefixFlag, exportCommandFlag, profilesTagFlag},
+
+	Action: func(ctx *cli.Context) error {
+		names := ctx.Args().Slice()
+		strict := len(names) > 0
+		if !strict {
+			p, err := config.DefaultIniLoader.Profiles()
+			if err != nil {
+				return err
+			}
+
+			for k := range p {
+				names = append(names, k)
+			}
+		}
+
+		f, err := exportProfiles(names, strict, ctx.StringSlice(profilesTagFlag.Name),
+			ctx.String(exportPrefixFlag.Name), ctx.String(exportCommandFlag.Name))
+		if err != nil {
+			return err
+		}
+
+		_, err = f.WriteTo(os.Stdout)
+		return err
+	},
+}
+
+var exportPrefixFlag = &cli.StringFlag{
+	Name:    "prefix",
+	Aliases: []string{"p"},
+	Usage:   "add this prefix to the exported profile names",
+}
+
+var exportCommandFlag = &cli.StringFlag{
+	Name:    "command",
+	Aliases: []string{"c"},
+	Usage:   "the aws-runas command used in the credential_process setting",
+	Value:   "aws-runas",
+}
+
+// exportProfiles returns the AWS config file profiles for the named profiles, sorted by name, which use the aws-runas
+// command to get their credentials.  If strict is true, an error is returned for profiles which can't be resolved,
+// otherwise they're skipped.  Profiles which don't have every tag filter are skipped.
+func exportProfiles(names []string, strict bool, tags []string, prefix, command string) (*ini.File, error) {
+	f := ini.Empty()
+	for _, name := range slices.Sorted(slices.Values(names)) {
+		cfg, err := configResolver.Config(name)
+		if err != nil {
+			if strict {
+				return nil, err
+			}
+			log.Debugf("error loading profile %s: %v", name, err)
+			continue
+		}
+
+		tm, err := cfg.TagMap()
+		if err != nil || !matchTags(tm, tags) {
+			continue
+		}
+
+		switch cfg.ProfileType() {
+		case config.ProfileTypeIam, config.ProfileTypeSso:
+			if strict {
+				log.Warningf("profile %s does not need aws-runas, skipping", name)
+			}
+			continue
+		}
+
+		section := "profile " + prefix + name
+		if prefix+name == awsconfig.DefaultSharedConfigProfile {
+			section = awsconfig.DefaultSharedConfigProfile
+		}
+
+		s := f.Section(section)
+		s.Key("credential_process").SetValue(fmt.Sprintf("%s -O json %s", quoteArg(command), quoteArg(name)))
+		if len(cfg.Region) > 0 {
+			s.Key("region").SetValue(cfg.Region)
+		}
+	}
+	return f, nil
+}
+
+// quoteArg quotes a credential_process argument containing whitespace, like a command path in a directory with spaces.
+func quoteArg(s string) string {
+	if strings.ContainsAny(s, " \t") {
+		return `"` + s + `"`
+	}
+	return s
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+)
+
+func TestExportProfiles(t *testing.T) {
+	setProfilesConfig(t)
+	all := []string{"default", "dev", "prod", "legacy", "sso", "untagged"}
+
+	t.Run("all", func(t *testing.T) {
+		f, err := exportProfiles(all, false, nil, "", "aws-runas")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := f.SectionStrings()
+		if len(names) != 4 || names[1] != "profile dev" || names[2] != "profile legacy" || names[3] != "profile prod" {
+			t.Fatalf("unexpected profiles: %v", names)
+		}
+
+		s := f.Section("profile prod")
+		if s.Key("credential_process").String() != "aws-runas -O json prod" || s.Key("region").String() != "us-west-2" {
+			t.Errorf("unexpected profile: %v", s.KeysHash())
+		}
+
+		if f.Section("profile dev").HasKey("region") {
+			t.Error("unexpected region")
+		}
+	})
+
+	t.Run("prefix and command", func(t *testing.T) {
+		f, err := exportProfiles([]string{"dev"}, true, nil, "runas-", "/opt/my tools/aws-runas")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v := f.Section("profile runas-dev").Key("credential_process").String()
+		if v != `"/opt/my tools/aws-runas" -O json dev` {
+			t.Errorf("unexpected credential_process: %s", v)
+		}
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		f, err := exportProfiles(all, false, []string{"env=prod"}, "", "aws-runas")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if names := f.SectionStrings(); len(names) != 2 || names[1] != "profile prod" {
+			t.Errorf("unexpected profiles: %v", names)
+		}
+	})
+
+	t.Run("not needed", func(t *testing.T) {
+		f, err := exportProfiles([]string{"sso", "untagged"}, true, nil, "", "aws-runas")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if names := f.SectionStrings(); len(names) != 1 {
+			t.Errorf("unexpected profiles: %v", names)
+		}
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		if _, err := exportProfiles([]string{"nope"}, true, nil, "", "aws-runas"); err == nil {
+			t.Error("did not receive expected error")
+		}
+
+		if _, err := exportProfiles([]string{"nope"}, false, nil, "", "aws-runas"); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/urfave/cli/v2"
+)
+
+// applyFlagDefaults sets the flags which were not set on the command line, or by an environment variable, to the
+// value found in the flag defaults file (~/.aws_runasrc) for the profile.  The keys for the flags of a subcommand
+// are prefixed with the names in the command path, like 'console.browser', so they don't collide with the global
+// flags of the same name.
+func applyFlagDefaults(ctx *cli.Context, prefix string, flags []cli.Flag, profile string) error {
+	file := config.FlagDefaultsFile()
+	if _, err := os.Stat(file); err != nil {
+		// most people won't have the file, skip the profile alias lookup
+		return nil
+	}
+
+	defaults, err := config.LoadFlagDefaults(file, resolveProfileAlias(profile))
+	if err != nil || len(defaults) < 1 {
+		return err
+	}
+
+	for _, f := range flags {
+		v, ok := "", false
+		for _, n := range f.Names() {
+			if v, ok = defaults[prefix+n]; ok {
+				break
+			}
+		}
+
+		if !ok || flagIsSet(ctx, f) {
+			continue
+		}
+
+		name := f.Names()[0]
+		log.Debugf("setting flag %s%s from %s", prefix, name, file)
+
+		if err = setFlagDefault(ctx, f, v); err != nil {
+			return fmt.Errorf("invalid value '%s' for %s%s in %s: %w", v, prefix, name, file, err)
+		}
+	}
+	return nil
+}
+
+// flagIsSet checks all of the flag's names, since the cli library only reports the name used on the command line.
+func flagIsSet(ctx *cli.Context, f cli.Flag) bool {
+	for _, n := range f.Names() {
+		if ctx.IsSet(n) {
+			return true
+		}
+	}
+	return false
+}
+
+func setFlagDefault(ctx *cli.Context, f cli.Flag, value string) error {
+	name := f.Names()[0]
+
+	// the verbose flag is set once for each level of verbosity, accept a count in addition to a boolean value
+	if _, ok := f.(*verboseFlag); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			for range n {
+				if err = ctx.Set(name, "true"); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return ctx.Set(name, value)
+}
+
+// flagDefaultsProfile returns the profile whose flag defaults are used with the context.  This is the 1st argument,
+// unless it names a subcommand (which looks up its own profile), or the profile set in the AWS_PROFILE or
+// AWS_DEFAULT_PROFILE environment variables.
+func flagDefaultsProfile(ctx *cli.Context, subcommands []*cli.Command) string {
+	if p := ctx.Args().First(); len(p) > 0 {
+		for _, c := range subcommands {
+			if c.HasName(p) {
+				p = ""
+				break
+			}
+		}
+
+		if len(p) > 0 {
+			return p
+		}
+	}
+
+	if p := os.Getenv("AWS_PROFILE"); len(p) > 0 {
+		return p
+	}
+	return os.Getenv("AWS_DEFAULT_PROFILE")
+}
+
+// withFlagDefaults adds a Before function to the commands (and their subcommands) which applies the flag defaults to
+// the flags of the command, ahead of any Before function the command already has.
+func withFlagDefaults(cmds []*cli.Command, prefix string) {
+	for _, c := range cmds {
+		path := prefix + c.Name + "."
+		before := c.Before
+
+		c.Before = func(ctx *cli.Context) error {
+			if err := applyFlagDefaults(ctx, path, c.Flags, flagDefaultsProfile(ctx, c.Subcommands)); err != nil {
+				return err
+			}
+
+			if before != nil {
+				return before(ctx)
+			}
+			return nil
+		}
+
+		withFlagDefaults(c.Subcommands, path)
+	}
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/urfave/cli/v2"
+)
+
+func TestFlagDefaults(t *testing.T) {
+	f := filepath.Join(t.TempDir(), config.FlagDefaultsFileName)
+	data := `output = powershell
+warn-lifetime = 10m
+
+[profile prod]
+output = sh
+sub.window = 20m
+`
+	if err := os.WriteFile(f, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.FlagDefaultsFileEnvVar, f)
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_DEFAULT_PROFILE", "")
+
+	var output, subOutput string
+	var lifetime, window time.Duration
+
+	newApp := func() *cli.App {
+		sub := &cli.Command{
+			Name: "sub",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "output"},
+				&cli.DurationFlag{Name: "window", Aliases: []string{"W"}},
+			},
+			Action: func(ctx *cli.Context) error {
+				subOutput = ctx.String("output")
+				window = ctx.Duration("window")
+				return nil
+			},
+		}
+		withFlagDefaults([]*cli.Command{sub}, "")
+
+		// new flags for each run, the cli library keeps state in the flag once it's found in the environment
+		return &cli.App{
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "output", Aliases: []string{"O"}},
+				&cli.DurationFlag{Name: "warn-lifetime", EnvVars: []string{"RUNAS_WARN_LIFETIME"}},
+			},
+			Commands: []*cli.Command{sub},
+			Before: func(ctx *cli.Context) error {
+				return applyFlagDefaults(ctx, "", ctx.App.Flags, flagDefaultsProfile(ctx, ctx.App.Commands))
+			},
+			Action: func(ctx *cli.Context) error {
+				output = ctx.String("output")
+				lifetime = ctx.Duration("warn-lifetime")
+				return nil
+			},
+		}
+	}
+
+	t.Run("global", func(t *testing.T) {
+		if err := newApp().Run([]string{"runas", "dev"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if output != "powershell" || lifetime != 10*time.Minute {
+			t.Errorf("unexpected flag values: %s, %s", output, lifetime)
+		}
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		if err := newApp().Run([]string{"runas", "prod"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if output != "sh" || lifetime != 10*time.Minute {
+			t.Errorf("unexpected flag values: %s, %s", output, lifetime)
+		}
+	})
+
+	t.Run("command line wins", func(t *testing.T) {
+		if err := newApp().Run([]string{"runas", "-O", "json", "prod"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if output != "json" {
+			t.Errorf("unexpected flag value: %s", output)
+		}
+	})
+
+	t.Run("env var wins", func(t *testing.T) {
+		t.Setenv("RUNAS_WARN_LIFETIME", "1m")
+		if err := newApp().Run([]string{"runas", "prod"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if lifetime != time.Minute {
+			t.Errorf("unexpected flag value: %s", lifetime)
+		}
+	})
+
+	t.Run("subcommand", func(t *testing.T) {
+		if err := newApp().Run([]string{"runas", "sub", "prod"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// global keys do not apply to subcommand flags of the same name
+		if window != 20*time.Minute || len(subOutput) > 0 {
+			t.Errorf("unexpected flag values: %s, %s", window, subOutput)
+		}
+	})
+
+	t.Run("profile env var", func(t *testing.T) {
+		t.Setenv("AWS_PROFILE", "prod")
+		if err := newApp().Run([]string{"runas", "sub"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if window != 20*time.Minute {
+			t.Errorf("unexpected flag value: %s", window)
+		}
+	})
+
+	t.Run("bad value", func(t *testing.T) {
+		if err := os.WriteFile(f, []byte("warn-lifetime = forever\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		err := newApp().Run([]string{"runas", "dev"})
+		if err == nil || !strings.Contains(err.Error(), "warn-lifetime") {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+}
+
+func TestFlagDefaults_verbose(t *testing.T) {
+	f := filepath.Join(t.TempDir(), config.FlagDefaultsFileName)
+	if err := os.WriteFile(f, []byte("verbose = 2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.FlagDefaultsFileEnvVar, f)
+
+	v := &verboseFlag{Name: "verbose", Aliases: []string{"v"}, Value: new(boolSlice)}
+	app := &cli.App{
+		Flags: []cli.Flag{v},
+		Before: func(ctx *cli.Context) error {
+			return applyFlagDefaults(ctx, "", ctx.App.Flags, "")
+		},
+		Action: func(*cli.Context) error { return nil },
+	}
+
+	if err := app.Run([]string{"runas"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(v.Value.val) != 2 {
+		t.Errorf("unexpected verbosity: %v", v.Value.val)
+	}
+}
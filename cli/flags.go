@@ -24,7 +24,9 @@ import (
 )
 
 var shortcutFlags = []cli.Flag{mfaFlag, rolesFlag, updateFlag, diagFlag, vFlag}
-var otherFlags = []cli.Flag{envFlag, fmtFlag, sessionFlag, refreshFlag, expFlag, whoamiFlag, writeCredsFlag}
+var otherFlags = []cli.Flag{envFlag, fmtFlag, sessionFlag, refreshFlag, expFlag, whoamiFlag, showPoliciesFlag, writeCredsFlag, verifyFlag,
+	retryExpiredFlag, warnLifetimeFlag, noDefaultCmdFlag, copyFlag, copyClearFlag, offlineFlag, forceRefreshFlag, auditLogFlag,
+	traceFileFlag, revealSecretsFlag}
 var configFlags = []cli.Flag{sessionDurationFlag, roleDurationFlag, mfaCodeFlag, mfaSerialFlag, mfaTypeFlag, externalIdFlag,
 	jumpRoleFlag, samlUrlFlag, samlEntityIdFlag, oidcUrlFlag, oidcRedirectFlag, oidcClientIdFlag, usernameFlag, passwordFlag, providerFlag}
 
@@ -58,22 +60,22 @@ var diagFlag = &cli.BoolFlag{
 /*
  * Config flags - affect/override resolved configuration values.
  */
-var sessionDurationFlag = &cli.DurationFlag{
+var sessionDurationFlag = &cli.GenericFlag{
 	Name:        "duration",
 	Aliases:     []string{"d"},
-	Usage:       "duration of the retrieved session token",
+	Usage:       "duration of the retrieved session token, as a duration string (8h, 45m) or number of seconds",
 	EnvVars:     []string{"SESSION_TOKEN_DURATION"},
 	DefaultText: fmt.Sprintf("%d hours", int64(credentials.SessionTokenDurationDefault.Hours())),
-	Destination: &cmdlineCfg.SessionTokenDuration,
+	Value:       newDurationValue(&cmdlineCfg.SessionTokenDuration),
 }
 
-var roleDurationFlag = &cli.DurationFlag{
+var roleDurationFlag = &cli.GenericFlag{
 	Name:        "role-duration",
 	Aliases:     []string{"a"},
-	Usage:       "duration of the assume role credentials",
+	Usage:       "duration of the assume role credentials, as a duration string (8h, 45m) or number of seconds",
 	EnvVars:     []string{"CREDENTIALS_DURATION"},
 	DefaultText: fmt.Sprintf("%d hours", int64(credentials.AssumeRoleDurationDefault.Hours())),
-	Destination: &cmdlineCfg.CredentialsDuration,
+	Value:       newDurationValue(&cmdlineCfg.CredentialsDuration),
 }
 
 var mfaCodeFlag = &cli.StringFlag{
@@ -189,7 +191,7 @@ var providerFlag = &cli.StringFlag{
 var fmtFlag = &cli.StringFlag{
 	Name:        "output",
 	Aliases:     []string{"O"},
-	Usage:       "credential output format, valid values: env or json",
+	Usage:       "credential output format, valid values: env, json or powershell",
 	EnvVars:     []string{"RUNAS_OUTPUT_FORMAT"},
 	Value:       "env",
 	Destination: nil,
@@ -225,6 +227,11 @@ var expFlag = &cli.BoolFlag{
 	Destination: nil,
 }
 
+var showPoliciesFlag = &cli.BoolFlag{
+	Name:  "show-policies",
+	Usage: "list the managed policies and permissions boundary of the role, requires IAM read permissions",
+}
+
 var whoamiFlag = &cli.BoolFlag{
 	Name:        "whoami",
 	Aliases:     []string{"w"},
@@ -238,3 +245,71 @@ var writeCredsFlag = &cli.BoolFlag{
 	Usage:   "write credentials to the AWS credentials file in addition to the cache",
 	EnvVars: []string{"RUNAS_WRITE_CREDENTIALS"},
 }
+
+var verifyFlag = &cli.BoolFlag{
+	Name:    "verify",
+	Usage:   "verify the credentials with AWS before using them",
+	EnvVars: []string{"RUNAS_VERIFY_CREDENTIALS"},
+}
+
+var retryExpiredFlag = &cli.IntFlag{
+	Name:    "retry-expired",
+	Usage:   "refresh credentials and re-run the whole program (up to the given number of times) if it fails due to expired credentials, requires stderr to not be a terminal",
+	EnvVars: []string{"RUNAS_RETRY_EXPIRED"},
+}
+
+var warnLifetimeFlag = &cli.DurationFlag{
+	Name:    "warn-lifetime",
+	Usage:   "warn before running a program if the credentials expire in less than this amount of time",
+	EnvVars: []string{"RUNAS_WARN_LIFETIME"},
+}
+
+var noDefaultCmdFlag = &cli.BoolFlag{
+	Name:    "no-default-command",
+	Usage:   "do not run the default_command of the profile when no program is given, print the credentials instead",
+	EnvVars: []string{"RUNAS_NO_DEFAULT_COMMAND"},
+}
+
+var copyFlag = &cli.BoolFlag{
+	Name:    "copy",
+	Usage:   "copy the credential export commands (or console URL) to the clipboard, instead of printing them",
+	EnvVars: []string{"RUNAS_COPY"},
+}
+
+var copyClearFlag = &cli.DurationFlag{
+	Name:    "copy-clear",
+	Usage:   "clear the clipboard after this amount of time when using --copy, waiting until it's cleared",
+	EnvVars: []string{"RUNAS_COPY_CLEAR"},
+}
+
+var forceRefreshFlag = &cli.BoolFlag{
+	Name:    "force-refresh",
+	Usage:   "ignore the cached credentials, identity tokens and identity provider session for the profile, and fetch new credentials",
+	EnvVars: []string{"RUNAS_FORCE_REFRESH"},
+}
+
+var offlineFlag = &cli.BoolFlag{
+	Name:    "offline",
+	Usage:   "never make network requests or prompt for input, only use unexpired cached credentials",
+	EnvVars: []string{"RUNAS_OFFLINE"},
+}
+
+var auditLogFlag = &cli.StringFlag{
+	Name:      "audit-log",
+	Usage:     "append a JSON record to this file each time credentials are issued or served",
+	EnvVars:   []string{"RUNAS_AUDIT_LOG"},
+	TakesFile: true,
+}
+
+var traceFileFlag = &cli.StringFlag{
+	Name:      "trace-file",
+	Usage:     "append the OpenTelemetry spans for identity provider, STS and credential cache operations to this file",
+	EnvVars:   []string{"RUNAS_TRACE_FILE"},
+	TakesFile: true,
+}
+
+var revealSecretsFlag = &cli.BoolFlag{
+	Name:    "reveal-secrets",
+	Usage:   "include secrets, like SAML assertions and AWS credentials, in debug output instead of redacting them",
+	EnvVars: []string{"RUNAS_REVEAL_SECRETS"},
+}
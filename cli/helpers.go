@@ -15,9 +15,12 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
 	"github.com/aws/smithy-go/logging"
 	"github.com/dustin/go-humanize"
 	"github.com/mmmorris1975/aws-runas/client"
@@ -25,10 +28,13 @@ import (
 	"github.com/mmmorris1975/aws-runas/config"
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"github.com/mmmorris1975/aws-runas/identity"
+	"github.com/mmmorris1975/aws-runas/shared"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
 	"os"
 	"os/signal"
 	"slices"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -48,10 +54,30 @@ func resolveConfig(ctx *cli.Context, expectedArgs int) (string, *config.AwsConfi
 	if len(profile) < 1 {
 		profile = checkProfileEnv()
 	}
+	profile = resolveProfileAlias(profile)
 
+	cfg, err := resolveProfileConfig(ctx, profile)
+	return profile, cfg, err
+}
+
+// resolveProfileAlias returns the name of the profile using the alias argument as one of its aliases, so the profile
+// name (and not the alias) is used for things like cache file names and the AWSRUNAS_PROFILE env var.  If the alias
+// can not be resolved, it is returned as-is and configuration resolution will report any problem.
+func resolveProfileAlias(alias string) string {
+	if ar, ok := configResolver.(config.AliasResolver); ok && len(alias) > 0 && !arn.IsARN(alias) {
+		if p, err := ar.ResolveAlias(alias); err == nil {
+			return p
+		}
+	}
+	return alias
+}
+
+// resolveProfileConfig returns the resolved AwsConfig object for the named profile (or source profile, if requested),
+// with the command line settings applied.
+func resolveProfileConfig(ctx *cli.Context, profile string) (*config.AwsConfig, error) {
 	cfg, err := configResolver.Config(profile)
 	if err != nil {
-		return profile, nil, err
+		return nil, err
 	}
 
 	if len(cfg.MfaType) < 1 {
@@ -75,7 +101,12 @@ func resolveConfig(ctx *cli.Context, expectedArgs int) (string, *config.AwsConfi
 	}
 
 	cfg.MergeIn(cmdlineCfg) // I think this is a good idea??
-	return profile, cfg, nil
+
+	// commands check for a SAML profile using the auth URL, so derive it from the metadata before returning
+	if err = client.ApplySamlMetadata(ctx.Context, cfg, opts); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
 func checkProfileArgs(ctx *cli.Context, expectedArgs int) string {
@@ -140,6 +171,12 @@ func installSignalHandler() chan os.Signal {
 // for things where we don't deal with sts credentials (-l, -r, -u, -D, password sub command), or could possibly
 // deal with a lot of them (ec2 and ecs metadata services), this wouldn't make sense to use.
 func refreshCreds(c client.AwsClient) {
+	if opts.Offline {
+		// clearing the cache would only leave us without credentials
+		log.Warningf("%v, not refreshing credentials", client.ErrOffline)
+		return
+	}
+
 	if err := c.ClearCache(); err != nil {
 		log.Warningf("failed to clear cache: %v", err)
 	}
@@ -168,6 +205,29 @@ func printCredExpiration(creds *credentials.Credentials) {
 	_, _ = fmt.Fprintln(os.Stderr, msg)
 }
 
+// checkCredLifetime logs the remaining lifetime of the credentials used to run a program, and warns if it is less than
+// the warn duration.  It also warns if the role session is shorter than the configured duration because the role is
+// assumed using jump role credentials, since AWS limits these chained role sessions to 1 hour.
+func checkCredLifetime(cfg *config.AwsConfig, creds *credentials.Credentials, warn time.Duration, now time.Time) {
+	if creds.Expiration.IsZero() {
+		return
+	}
+
+	exp := creds.Expiration.Local().Format("15:04:05")
+	left := creds.Expiration.Sub(now).Round(time.Second)
+	log.Debugf("credentials expire in %s, at %s", left, exp)
+
+	if len(cfg.JumpRoleArn) > 0 && len(cfg.RoleArn) > 0 && cfg.RoleCredentialDuration() > credentials.AssumeRoleDurationDefault {
+		log.Warningf("role credentials last %s, not the configured %s, since AWS limits the duration of roles assumed "+
+			"using jump role credentials", credentials.AssumeRoleDurationDefault, cfg.RoleCredentialDuration())
+	}
+
+	if warn > 0 && left < warn {
+		log.Warningf("credentials expire in %s (at %s), which is less than %s, use --refresh to get new credentials "+
+			"before running long tasks", left, exp, warn)
+	}
+}
+
 func printCredIdentity(api identity.StsApi) error {
 	id, err := api.GetCallerIdentity(context.Background(), new(sts.GetCallerIdentityInput))
 	if err != nil {
@@ -188,6 +248,66 @@ func printCredIdentity(api identity.StsApi) error {
 	return nil
 }
 
+// verifyCredentials performs a GetCallerIdentity call using the credentials configured in the StsApi.  This API call
+// is allowed for any valid credentials, so a failure usually means the credentials themselves are unusable.  The
+// returned error will describe the likely cause.
+func verifyCredentials(api identity.StsApi) error {
+	id, err := api.GetCallerIdentity(context.Background(), new(sts.GetCallerIdentityInput))
+	if err != nil {
+		return fmt.Errorf("credential verification failed, %s: %w", diagnoseStsError(err), err)
+	}
+
+	log.Debugf("verified credentials for %s", aws.ToString(id.Arn))
+	return nil
+}
+
+// verifyServeCredentials verifies the credentials for the profile before a credential service is started, if the
+// verify flag is set.  Nothing is checked if no profile was provided, since one will be selected in the service.
+func verifyServeCredentials(ctx *cli.Context, profile string, cfg *config.AwsConfig) error {
+	if !ctx.Bool(verifyFlag.Name) || len(profile) < 1 {
+		return nil
+	}
+
+	c, err := clientFactory.Get(cfg)
+	if err != nil {
+		return err
+	}
+	return verifyCredentials(sts.NewFromConfig(c.ConfigProvider()))
+}
+
+// diagnoseStsError returns a description of the likely cause of an error returned from an AWS API call.
+func diagnoseStsError(err error) string {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return "unable to contact AWS"
+	}
+
+	msg := strings.ToLower(apiErr.ErrorMessage())
+
+	switch apiErr.ErrorCode() {
+	case "ExpiredToken", "ExpiredTokenException", "TokenRefreshRequired":
+		return "the credentials have expired, retry using the --refresh option"
+	case "InvalidClientTokenId", "UnrecognizedClientException":
+		return "the credentials are not valid, they may have been revoked or deleted"
+	case "SignatureDoesNotMatch", "RequestExpired", "InvalidSignatureException":
+		return "the request signature was rejected, check the accuracy of the local clock"
+	case "RegionDisabledException":
+		return "STS is not enabled in the configured region"
+	case "AccessDenied", "AccessDeniedException":
+		switch {
+		case strings.Contains(msg, "service control policy"):
+			return "access was denied by an organization service control policy (SCP)"
+		case strings.Contains(msg, "permissions boundary"):
+			return "access was denied by the role's permissions boundary"
+		case strings.Contains(msg, "session policy"):
+			return "access was denied by a session policy"
+		}
+		return "access was denied"
+	}
+
+	return "unexpected error"
+}
+
 func bashCompleteProfile(ctx *cli.Context) {
 	if ctx.NArg() > 0 {
 		return
@@ -205,6 +325,14 @@ func bashCompleteProfile(ctx *cli.Context) {
 		vals[i] = k
 		i++
 	}
+
+	if a, err := config.DefaultIniLoader.Aliases(); err == nil {
+		for k := range a {
+			if _, ok := p[k]; !ok {
+				vals = append(vals, k)
+			}
+		}
+	}
 	slices.Sort(vals)
 
 	for _, v := range vals {
@@ -214,13 +342,15 @@ func bashCompleteProfile(ctx *cli.Context) {
 
 var logFunc logging.LoggerFunc = func(c logging.Classification, fmt string, v ...any) {
 	if log != nil {
+		// AWS SDK request and response logging includes signatures, tokens, and cookies
+		l := shared.NewRedactingLogger(log)
 		switch c {
 		case logging.Warn:
-			log.Warningf(fmt, v...)
+			l.Warningf(fmt, v...)
 		case logging.Debug:
-			log.Debugf(fmt, v...)
+			l.Debugf(fmt, v...)
 		default:
-			log.Infof(fmt, v...)
+			l.Infof(fmt, v...)
 		}
 	}
 }
@@ -234,3 +364,30 @@ func saveStsCredentials(ctx *cli.Context, profile string, creds *credentials.Cre
 		log.Infof("Credentials written to AWS credentials file under profile: %s-awsrunas", profile)
 	}
 }
+
+// stdoutIsTerminal returns true if stdout is a terminal.  It is a variable so tests can replace it.
+var stdoutIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// stderrIsTerminal returns true if stderr is a terminal.  It is a variable so tests can replace it.
+var stderrIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// useDefaultCommand returns true if the profile's default_command should be run when no program is given.  It is
+// skipped when a flag asks for output or another action instead of running a program, or if stdout is not a terminal,
+// since the output is being captured (like eval $(aws-runas profile)) and the credentials are expected.
+func useDefaultCommand(ctx *cli.Context) bool {
+	for _, f := range []string{noDefaultCmdFlag.Name, copyFlag.Name, expFlag.Name, whoamiFlag.Name,
+		showPoliciesFlag.Name, writeCredsFlag.Name, verifyFlag.Name} {
+		if ctx.Bool(f) {
+			return false
+		}
+	}
+
+	if ctx.IsSet(fmtFlag.Name) {
+		return false
+	}
+	return stdoutIsTerminal()
+}
@@ -20,7 +20,9 @@ import (
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
 	"github.com/aws/smithy-go/logging"
+	"github.com/mmmorris1975/aws-runas/config"
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"github.com/mmmorris1975/simple-logger/logger"
 	"github.com/urfave/cli/v2"
@@ -50,6 +52,46 @@ func TestHelpers_printCredIdentity(t *testing.T) {
 	})
 }
 
+func TestHelpers_verifyCredentials(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		if err := verifyCredentials(new(mockStsApi)); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("bad", func(t *testing.T) {
+		var api mockStsApi = true
+		if err := verifyCredentials(&api); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestHelpers_diagnoseStsError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"network", errors.New("dial tcp: connection refused"), "unable to contact"},
+		{"expired", &smithy.GenericAPIError{Code: "ExpiredToken"}, "expired"},
+		{"invalid", &smithy.GenericAPIError{Code: "InvalidClientTokenId"}, "not valid"},
+		{"signature", &smithy.GenericAPIError{Code: "SignatureDoesNotMatch"}, "local clock"},
+		{"scp", &smithy.GenericAPIError{Code: "AccessDenied", Message: "with an explicit deny in a service control policy"}, "(SCP)"},
+		{"boundary", &smithy.GenericAPIError{Code: "AccessDenied", Message: "because no permissions boundary allows the action"}, "permissions boundary"},
+		{"denied", &smithy.GenericAPIError{Code: "AccessDenied"}, "access was denied"},
+		{"other", &smithy.GenericAPIError{Code: "Throttling"}, "unexpected"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := diagnoseStsError(fmt.Errorf("wrapped: %w", tc.err)); !strings.Contains(got, tc.want) {
+				t.Errorf("unexpected diagnosis for %s: %s", tc.name, got)
+			}
+		})
+	}
+}
+
 func TestHelpers_printCredExpiration(t *testing.T) {
 	// if _, err := io.Copy(os.Stdout, os.Stderr); err != nil {
 	//	t.Error(err)
@@ -83,6 +125,40 @@ func TestHelpers_refreshCreds(t *testing.T) {
 	})
 }
 
+func TestHelpers_resolveProfileAlias(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(f, []byte("[profile development]\naliases = dev\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = os.Setenv("AWS_CONFIG_FILE", f)
+	defer os.Unsetenv("AWS_CONFIG_FILE")
+
+	defer func(r config.Resolver) { configResolver = r }(configResolver)
+	configResolver = config.NewResolver(config.DefaultIniLoader, false)
+
+	t.Run("alias", func(t *testing.T) {
+		if p := resolveProfileAlias("dev"); p != "development" {
+			t.Errorf("unexpected profile: %s", p)
+		}
+	})
+
+	t.Run("not alias", func(t *testing.T) {
+		for _, a := range []string{"development", "other", "", "arn:aws:iam::012345678901:role/dev"} {
+			if p := resolveProfileAlias(a); p != a {
+				t.Errorf("unexpected profile: %s", p)
+			}
+		}
+	})
+
+	t.Run("no alias support", func(t *testing.T) {
+		configResolver = new(mockConfigResolver)
+		if p := resolveProfileAlias("dev"); p != "dev" {
+			t.Errorf("unexpected profile: %s", p)
+		}
+	})
+}
+
 func Test_logFunc(t *testing.T) {
 	sb := new(strings.Builder)
 	log = logger.NewLogger(sb, "", 0)
@@ -267,3 +343,97 @@ func (m *mockStsApi) GetCallerIdentity(context.Context, *sts.GetCallerIdentityIn
 
 	return out, nil
 }
+
+func TestHelpers_checkCredLifetime(t *testing.T) {
+	now := time.Now()
+	creds := &credentials.Credentials{AccessKeyId: "AK", SecretAccessKey: "SK", Expiration: now.Add(20 * time.Minute)}
+
+	check := func(cfg *config.AwsConfig, creds *credentials.Credentials, warn time.Duration) string {
+		sb := new(strings.Builder)
+		origLog := log
+		log = logger.NewLogger(sb, "", 0)
+		log.SetLevel(logger.WARN)
+		defer func() { log = origLog }()
+
+		checkCredLifetime(cfg, creds, warn, now)
+		return sb.String()
+	}
+
+	t.Run("long enough", func(t *testing.T) {
+		if out := check(new(config.AwsConfig), creds, 15*time.Minute); len(out) > 0 {
+			t.Errorf("unexpected warning: %s", out)
+		}
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		if out := check(new(config.AwsConfig), creds, 30*time.Minute); !strings.Contains(out, "credentials expire in 20m0s") {
+			t.Errorf("expected warning, got: %s", out)
+		}
+	})
+
+	t.Run("no expiration", func(t *testing.T) {
+		if out := check(new(config.AwsConfig), new(credentials.Credentials), time.Hour); len(out) > 0 {
+			t.Errorf("unexpected warning: %s", out)
+		}
+	})
+
+	t.Run("chained role", func(t *testing.T) {
+		cfg := &config.AwsConfig{RoleArn: "arn:aws:iam::123456789012:role/target",
+			JumpRoleArn: "arn:aws:iam::123456789012:role/jump", CredentialsDuration: 8 * time.Hour}
+		if out := check(cfg, creds, 0); !strings.Contains(out, "not the configured 8h0m0s") {
+			t.Errorf("expected warning, got: %s", out)
+		}
+
+		cfg.CredentialsDuration = time.Hour
+		if out := check(cfg, creds, 0); len(out) > 0 {
+			t.Errorf("unexpected warning: %s", out)
+		}
+	})
+}
+
+func TestHelpers_useDefaultCommand(t *testing.T) {
+	newCtx := func(t *testing.T, tty bool, set ...string) *cli.Context {
+		t.Helper()
+
+		origTerm := stdoutIsTerminal
+		stdoutIsTerminal = func() bool { return tty }
+		t.Cleanup(func() { stdoutIsTerminal = origTerm })
+
+		fs := flag.NewFlagSet(t.Name(), flag.ContinueOnError)
+		for _, f := range []string{noDefaultCmdFlag.Name, copyFlag.Name, expFlag.Name, whoamiFlag.Name,
+			showPoliciesFlag.Name, writeCredsFlag.Name, verifyFlag.Name} {
+			fs.Bool(f, false, "")
+		}
+		fs.String(fmtFlag.Name, "env", "")
+
+		for _, f := range set {
+			v := "true"
+			if f == fmtFlag.Name {
+				v = "json"
+			}
+			_ = fs.Set(f, v)
+		}
+		return cli.NewContext(App, fs, nil)
+	}
+
+	t.Run("terminal", func(t *testing.T) {
+		if !useDefaultCommand(newCtx(t, true)) {
+			t.Error("default command not used")
+		}
+	})
+
+	t.Run("not terminal", func(t *testing.T) {
+		if useDefaultCommand(newCtx(t, false)) {
+			t.Error("default command used when stdout is not a terminal")
+		}
+	})
+
+	for _, f := range []string{noDefaultCmdFlag.Name, copyFlag.Name, expFlag.Name, whoamiFlag.Name,
+		showPoliciesFlag.Name, writeCredsFlag.Name, verifyFlag.Name, fmtFlag.Name} {
+		t.Run(f, func(t *testing.T) {
+			if useDefaultCommand(newCtx(t, true, f)) {
+				t.Errorf("default command used with the %s flag", f)
+			}
+		})
+	}
+}
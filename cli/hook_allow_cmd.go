@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/urfave/cli/v2"
+)
+
+// hookAllowFile is the name of the state file holding the .aws-runas files approved for use by the shell hook.
+const hookAllowFile = ".aws_runas_hook_allow"
+
+const hookAllowDesc = `Approve the .aws-runas file in the current directory (or the file or directory given as
+'path'), so the shell hook loads the credentials for the profile it names.  The file must be
+approved again after it changes.  Files which are not approved are ignored by the hook.`
+
+var hookAllowCmd = &cli.Command{
+	Name:        "allow",
+	Usage:       "Approve a .aws-runas file for use by the shell hook",
+	ArgsUsage:   "[path]",
+	Description: hookAllowDesc,
+
+	Action: func(ctx *cli.Context) error {
+		file, err := hookFilePath(ctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		if _, err = readHookProfile(file); err != nil {
+			return err
+		}
+
+		sum, err := hookFileHash(file)
+		if err != nil {
+			return err
+		}
+
+		return updateHookAllowList(func(l map[string]string) { l[file] = sum })
+	},
+}
+
+var hookDenyCmd = &cli.Command{
+	Name:      "deny",
+	Usage:     "Revoke the approval of a .aws-runas file for use by the shell hook",
+	ArgsUsage: "[path]",
+
+	Action: func(ctx *cli.Context) error {
+		file, err := hookFilePath(ctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		return updateHookAllowList(func(l map[string]string) { delete(l, file) })
+	},
+}
+
+// hookFilePath returns the absolute path of the .aws-runas file for the path argument, which may be the file, or the
+// directory containing it.  An empty path is the current directory.
+func hookFilePath(path string) (string, error) {
+	if len(path) < 1 {
+		path = "."
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		path = filepath.Join(path, hookProfileFile)
+	}
+	return path, nil
+}
+
+// hookFileHash returns the hex encoded SHA-256 hash of the file contents.
+func hookFileHash(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// hookFileAllowed returns true if the file is in the allow list, and has not changed since it was approved.
+func hookFileAllowed(file string) bool {
+	sum, err := hookFileHash(file)
+	if err != nil {
+		return false
+	}
+
+	l, err := readHookAllowList(client.StateFile(nil, hookAllowFile))
+	return err == nil && l[file] == sum
+}
+
+// readHookAllowList returns the approved files and the hashes of their contents, keyed by path.
+func readHookAllowList(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	l := make(map[string]string)
+	if err = json.Unmarshal(data, &l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// updateHookAllowList applies fn to the allow list, and writes it to a temporary file which replaces the state file, so
+// an interrupted update never leaves a partially written allow list behind.
+func updateHookAllowList(fn func(map[string]string)) error {
+	path := client.StateFile(nil, hookAllowFile)
+
+	l, err := readHookAllowList(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		l = make(map[string]string)
+	}
+	fn(l)
+
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestHookAllowCmd(t *testing.T) {
+	t.Setenv("AWS_RUNAS_CACHE_DIR", t.TempDir())
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, hookProfileFile)
+	if err := os.WriteFile(file, []byte("dev\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("allow dir", func(t *testing.T) {
+		allowHookFile(t, dir)
+
+		if !hookFileAllowed(file) {
+			t.Error("file not allowed")
+		}
+	})
+
+	t.Run("deny file", func(t *testing.T) {
+		if err := hookDenyCmd.Action(hookArgsContext(file)); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if hookFileAllowed(file) {
+			t.Error("file still allowed")
+		}
+	})
+
+	t.Run("allow current dir", func(t *testing.T) {
+		t.Chdir(dir)
+		allowHookFile(t, "")
+
+		if !hookFileAllowed(file) {
+			t.Error("file not allowed")
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if err := hookAllowCmd.Action(hookArgsContext(t.TempDir())); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("no profile", func(t *testing.T) {
+		d := t.TempDir()
+		if err := os.WriteFile(filepath.Join(d, hookProfileFile), []byte("# nothing\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := hookAllowCmd.Action(hookArgsContext(d)); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func allowHookFile(t *testing.T, path string) {
+	t.Helper()
+
+	args := make([]string, 0)
+	if len(path) > 0 {
+		args = append(args, path)
+	}
+
+	if err := hookAllowCmd.Action(hookArgsContext(args...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func hookArgsContext(args ...string) *cli.Context {
+	fs := new(flag.FlagSet)
+	_ = fs.Parse(args)
+	return cli.NewContext(App, fs, nil)
+}
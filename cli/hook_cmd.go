@@ -0,0 +1,293 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const hookDesc = `Print the shell code which loads credentials for the profile named in a .aws-runas file when
+changing into a project directory (or any directory below it), and unloads them when leaving.
+The hook runs before each prompt, refreshing the credentials shortly before they expire, so
+the environment variables are always fresh.  Add the output to your shell startup file:
+
+  bash (~/.bashrc):                   eval "$(aws-runas hook bash)"
+  zsh (~/.zshrc):                     eval "$(aws-runas hook zsh)"
+  fish (~/.config/fish/config.fish):  aws-runas hook fish | source
+
+The .aws-runas file contains the name of the profile on its first line.  A .aws-runas file
+is only used after approving it with 'aws-runas hook allow', and must be approved again
+after it changes, so a file in a cloned repository can't select a profile without consent.`
+
+// hookProfileFile is the name of the file containing the profile for a project directory.
+const hookProfileFile = ".aws-runas"
+
+// errHookNotAllowed is returned when the .aws-runas file for a directory has not been approved for use by the hook.
+var errHookNotAllowed = errors.New("the .aws-runas file is not allowed, or changed since it was allowed")
+
+// The env vars tracking the state of the shell hook: the profile loaded, and the names of the env vars it set.
+const (
+	hookProfileEnv = "AWS_RUNAS_HOOK_PROFILE"
+	hookVarsEnv    = "AWS_RUNAS_HOOK_VARS"
+)
+
+// hookRefreshWindow is how long before the credentials expire that the shell hook will refresh them.
+const hookRefreshWindow = 5 * time.Minute
+
+var hookCmd = &cli.Command{
+	Name:        "hook",
+	Usage:       "Print shell code to load credentials for the profile of the current directory",
+	ArgsUsage:   "bash|zsh|fish",
+	Description: hookDesc,
+	Flags:       []cli.Flag{hookExportFlag},
+	Subcommands: []*cli.Command{hookAllowCmd, hookDenyCmd},
+
+	BashComplete: func(ctx *cli.Context) {
+		if ctx.NArg() < 1 {
+			for _, s := range slices.Sorted(maps.Keys(hookShells)) {
+				fmt.Println(s)
+			}
+		}
+	},
+
+	Action: func(ctx *cli.Context) error {
+		sh, ok := hookShells[ctx.Args().First()]
+		if !ok {
+			return errors.New("a shell name of bash, zsh, or fish is required")
+		}
+
+		if ctx.Bool(hookExportFlag.Name) {
+			return hookExport(ctx, os.Stdout, sh)
+		}
+
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(os.Stdout, sh.script, sh.quote(exe))
+		return err
+	},
+}
+
+// hookExportFlag is used by the shell hook to get the env var statements for the current directory.
+var hookExportFlag = &cli.BoolFlag{
+	Name:   "export",
+	Usage:  "print the statements to update the environment for the current directory",
+	Hidden: true,
+}
+
+// hookShell is the shell specific syntax used by the hook command.
+type hookShell struct {
+	script string // the hook code, taking the quoted path to the aws-runas executable as the only format argument
+	export string // format for setting an env var, taking the name and quoted value
+	unset  string // format for removing an env var, taking the name
+	quote  func(string) string
+}
+
+var hookShells = map[string]*hookShell{
+	"bash": {
+		script: `_aws_runas_hook() {
+  local rc=$?
+  eval "$(%s hook --export bash)"
+  return $rc
+}
+if [[ ";${PROMPT_COMMAND[*]:-};" != *";_aws_runas_hook;"* ]]; then
+  PROMPT_COMMAND="_aws_runas_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`,
+		export: "export %s=%s\n",
+		unset:  "unset %s\n",
+		quote:  posixQuote,
+	},
+	"zsh": {
+		script: `_aws_runas_hook() {
+  eval "$(%s hook --export zsh)"
+}
+typeset -ag precmd_functions
+if (( ! ${precmd_functions[(I)_aws_runas_hook]} )); then
+  precmd_functions=(_aws_runas_hook $precmd_functions)
+fi
+`,
+		export: "export %s=%s\n",
+		unset:  "unset %s\n",
+		quote:  posixQuote,
+	},
+	"fish": {
+		script: `function __aws_runas_hook --on-event fish_prompt
+    %s hook --export fish | source
+end
+`,
+		export: "set -gx %s %s\n",
+		unset:  "set -e %s\n",
+		quote:  fishQuote,
+	},
+}
+
+func posixQuote(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `'\''`) + `'`
+}
+
+func fishQuote(s string) string {
+	return `'` + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + `'`
+}
+
+// hookExport writes the statements to w which update the environment for the profile of the current directory.
+// Nothing is written if the environment already has fresh credentials for the profile.
+func hookExport(ctx *cli.Context, w io.Writer, sh *hookShell) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	profile, err := findHookProfile(wd)
+	if err != nil {
+		// the hook runs before every prompt, so unapproved files are reported but never fail the hook
+		log.Warningf("%v", err)
+	}
+	current := os.Getenv(hookProfileEnv)
+	prev := strings.Fields(os.Getenv(hookVarsEnv))
+
+	if len(profile) < 1 {
+		if len(current) > 0 {
+			writeHookEnv(w, sh, nil, append(prev, hookProfileEnv, hookVarsEnv))
+		}
+		return nil
+	}
+
+	if profile == current && !hookNeedsRefresh(os.Getenv("AWS_CREDENTIAL_EXPIRATION"), time.Now()) {
+		return nil
+	}
+
+	env, err := hookCredentialEnv(ctx, profile)
+	if err != nil {
+		return err
+	}
+
+	unset := make([]string, 0)
+	for _, k := range prev {
+		if _, ok := env[k]; !ok {
+			unset = append(unset, k)
+		}
+	}
+
+	env[hookVarsEnv] = strings.Join(slices.Sorted(maps.Keys(env)), " ")
+	env[hookProfileEnv] = profile
+
+	writeHookEnv(w, sh, env, unset)
+	return nil
+}
+
+// hookCredentialEnv returns the env vars for the credentials of the profile, the same as the env vars printed when
+// running aws-runas with only a profile name.
+func hookCredentialEnv(ctx *cli.Context, profile string) (map[string]string, error) {
+	cfg, err := resolveProfileConfig(ctx, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := clientFactory.Get(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := c.Credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	env, _, err := commandEnv(cfg, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	env["AWSRUNAS_PROFILE"] = profile
+	return env, nil
+}
+
+// findHookProfile returns the profile name in the nearest .aws-runas file in dir or its parents, or an empty string
+// if there is no such file.  An error is returned if the nearest file has not been approved with the hook allow command.
+func findHookProfile(dir string) (string, error) {
+	for {
+		file := filepath.Join(dir, hookProfileFile)
+		if p, err := readHookProfile(file); err == nil {
+			if !hookFileAllowed(file) {
+				return "", fmt.Errorf("%w: %s (run 'aws-runas hook allow' in %s to approve it)", errHookNotAllowed, file, dir)
+			}
+			return p, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// readHookProfile returns the first line of the file which is not empty or a comment.
+func readHookProfile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if line := strings.TrimSpace(s.Text()); len(line) > 0 && !strings.HasPrefix(line, "#") {
+			return line, nil
+		}
+	}
+
+	if err = s.Err(); err == nil {
+		err = fmt.Errorf("no profile found in %s", file)
+	}
+	return "", err
+}
+
+// hookNeedsRefresh returns true if the credential expiration time is within hookRefreshWindow of now.  Credentials
+// without an expiration time never need a refresh.
+func hookNeedsRefresh(exp string, now time.Time) bool {
+	if len(exp) < 1 {
+		return false
+	}
+
+	t, err := time.Parse(time.RFC3339, exp)
+	if err != nil {
+		return true
+	}
+	return t.Sub(now) <= hookRefreshWindow
+}
+
+// writeHookEnv writes the shell statements to remove the unset env vars, and set the env vars in set.
+func writeHookEnv(w io.Writer, sh *hookShell, set map[string]string, unset []string) {
+	for _, k := range unset {
+		_, _ = fmt.Fprintf(w, sh.unset, k)
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(set)) {
+		_, _ = fmt.Fprintf(w, sh.export, k, sh.quote(set[k]))
+	}
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHookCmd_findHookProfile(t *testing.T) {
+	t.Setenv("AWS_RUNAS_CACHE_DIR", t.TempDir())
+
+	root := t.TempDir()
+	sub := filepath.Join(root, "project", "src")
+	if err := os.MkdirAll(sub, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		if p, err := findHookProfile(sub); err != nil || len(p) > 0 {
+			t.Errorf("unexpected profile %s, %v", p, err)
+		}
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		data := []byte("# project profile\n\n  dev-admin  \nignored\n")
+		if err := os.WriteFile(filepath.Join(root, "project", hookProfileFile), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if p, err := findHookProfile(sub); !errors.Is(err, errHookNotAllowed) || len(p) > 0 {
+			t.Errorf("unexpected profile %s, %v", p, err)
+		}
+	})
+
+	t.Run("parent dir", func(t *testing.T) {
+		allowHookFile(t, filepath.Join(root, "project"))
+
+		if p, err := findHookProfile(sub); err != nil || p != "dev-admin" {
+			t.Errorf("unexpected profile %s, %v", p, err)
+		}
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(sub, hookProfileFile), []byte("# nothing\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		// keeps looking in the parent directories
+		if p, err := findHookProfile(sub); err != nil || p != "dev-admin" {
+			t.Errorf("unexpected profile %s, %v", p, err)
+		}
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(root, "project", hookProfileFile), []byte("prod-admin\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if p, err := findHookProfile(sub); !errors.Is(err, errHookNotAllowed) || len(p) > 0 {
+			t.Errorf("unexpected profile %s, %v", p, err)
+		}
+	})
+}
+
+func TestHookCmd_hookNeedsRefresh(t *testing.T) {
+	now := time.Now()
+
+	t.Run("no expiration", func(t *testing.T) {
+		if hookNeedsRefresh("", now) {
+			t.Error("unexpected refresh")
+		}
+	})
+
+	t.Run("fresh", func(t *testing.T) {
+		if hookNeedsRefresh(now.Add(time.Hour).UTC().Format(time.RFC3339), now) {
+			t.Error("unexpected refresh")
+		}
+	})
+
+	t.Run("expiring", func(t *testing.T) {
+		if !hookNeedsRefresh(now.Add(time.Minute).UTC().Format(time.RFC3339), now) {
+			t.Error("expected refresh")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if !hookNeedsRefresh("soon", now) {
+			t.Error("expected refresh")
+		}
+	})
+}
+
+func TestHookCmd_writeHookEnv(t *testing.T) {
+	set := map[string]string{"B": "it's", "A": "1"}
+
+	t.Run("posix", func(t *testing.T) {
+		b := new(bytes.Buffer)
+		writeHookEnv(b, hookShells["bash"], set, []string{"OLD"})
+
+		if b.String() != "unset OLD\nexport A='1'\nexport B='it'\\''s'\n" {
+			t.Errorf("unexpected output: %s", b.String())
+		}
+	})
+
+	t.Run("fish", func(t *testing.T) {
+		b := new(bytes.Buffer)
+		writeHookEnv(b, hookShells["fish"], set, []string{"OLD"})
+
+		if b.String() != "set -e OLD\nset -gx A '1'\nset -gx B 'it\\'s'\n" {
+			t.Errorf("unexpected output: %s", b.String())
+		}
+	})
+}
+
+func TestHookCmd_hookExport(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("AWS_RUNAS_CACHE_DIR", t.TempDir())
+
+	t.Run("no profile", func(t *testing.T) {
+		t.Setenv(hookProfileEnv, "")
+		b := new(bytes.Buffer)
+
+		if err := hookExport(nil, b, hookShells["bash"]); err != nil || b.Len() > 0 {
+			t.Errorf("unexpected output: %s, %v", b.String(), err)
+		}
+	})
+
+	t.Run("leave project", func(t *testing.T) {
+		t.Setenv(hookProfileEnv, "dev")
+		t.Setenv(hookVarsEnv, "AWS_ACCESS_KEY_ID KUBECONFIG")
+		b := new(bytes.Buffer)
+
+		if err := hookExport(nil, b, hookShells["zsh"]); err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := "unset AWS_ACCESS_KEY_ID\nunset KUBECONFIG\nunset " + hookProfileEnv + "\nunset " + hookVarsEnv + "\n"
+		if b.String() != expected {
+			t.Errorf("unexpected output: %s", b.String())
+		}
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		if err := os.WriteFile(hookProfileFile, []byte("dev\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(hookProfileEnv, "dev")
+		t.Setenv(hookVarsEnv, "")
+		b := new(bytes.Buffer)
+
+		if err := hookExport(nil, b, hookShells["bash"]); err != nil {
+			t.Error(err)
+			return
+		}
+
+		// credentials loaded before the file changed are removed
+		if b.String() != "unset "+hookProfileEnv+"\nunset "+hookVarsEnv+"\n" {
+			t.Errorf("unexpected output: %s", b.String())
+		}
+	})
+
+	t.Run("fresh credentials", func(t *testing.T) {
+		allowHookFile(t, "")
+		t.Setenv(hookProfileEnv, "dev")
+		t.Setenv("AWS_CREDENTIAL_EXPIRATION", time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		b := new(bytes.Buffer)
+
+		if err := hookExport(nil, b, hookShells["bash"]); err != nil || b.Len() > 0 {
+			t.Errorf("unexpected output: %s, %v", b.String(), err)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/mmmorris1975/aws-runas/client/external"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/urfave/cli/v2"
+)
+
+var importAzureAppsCmd = &cli.Command{
+	Name:      "azure-apps",
+	Usage:     "Generate a profile for each AWS role assigned to an Azure AD user",
+	ArgsUsage: "profile_name",
+	Description: importDesc + `
+
+The AWS enterprise applications assigned to the Azure AD user of an existing Azure AD SAML
+profile, and the roles assigned to the user in each application, are listed using the Microsoft
+Graph API.  The Graph API token is requested using the OAuth client set by the
+web_identity_client_id and web_identity_redirect_uri attributes of the profile, which must be
+allowed the User.Read and Application.Read.All delegated permissions.  An Azure AD SAML profile
+is generated for each role, using the user access URL of the application (which includes the
+tenant and application ids) as the SAML URL.  The profiles are named after the application, with
+the role name appended when the user has more than one role in the application.  A warning is
+logged if the role of the existing profile is not assigned to the user.`,
+	Flags: []cli.Flag{importPrefixFlag, importWriteFlag, importForceFlag},
+
+	BashComplete: bashCompleteProfile,
+
+	Action: func(ctx *cli.Context) error {
+		profile, cfg, err := resolveConfig(ctx, 1)
+		if err != nil {
+			return err
+		}
+
+		if len(profile) < 1 {
+			return errors.New("missing profile name")
+		}
+
+		if len(cfg.SamlUrl) < 1 {
+			return fmt.Errorf("profile %s is not configured for SAML authentication", profile)
+		}
+
+		if len(cfg.SamlProvider) > 0 && !strings.EqualFold(cfg.SamlProvider, "azuread") {
+			return fmt.Errorf("profile %s uses the %s identity provider, not azuread", profile, cfg.SamlProvider)
+		}
+
+		apps, err := clientFactory.AzureAwsApps(ctx.Context, cfg)
+		if err != nil {
+			return err
+		}
+
+		if len(cfg.RoleArn) > 0 && !azureAppsHaveRole(apps, cfg.RoleArn) {
+			log.Warningf("role %s of profile %s is not assigned to the user in any AWS application", cfg.RoleArn, profile)
+		}
+
+		_, err = importProfiles(ctx, os.Stdout, azureAppProfiles(apps, cfg.SamlUsername))
+		return err
+	},
+}
+
+// azureAppProfiles returns an Azure AD SAML profile for each role of the AWS applications.
+func azureAppProfiles(apps []*external.AadAwsApp, user string) []*config.AwsConfig {
+	cfgs := make([]*config.AwsConfig, 0)
+	for _, app := range apps {
+		name := strings.Join(strings.Fields(app.Name), "-")
+
+		for _, role := range app.Roles {
+			cfg := &config.AwsConfig{
+				ProfileName:  name,
+				SamlUrl:      app.Url,
+				SamlUsername: user,
+				SamlProvider: "azuread",
+				RoleArn:      role,
+			}
+
+			if len(app.Roles) > 1 {
+				if r, err := arn.Parse(role); err == nil {
+					cfg.ProfileName = name + "-" + r.Resource[strings.LastIndex(r.Resource, "/")+1:]
+				}
+			}
+			cfgs = append(cfgs, cfg)
+		}
+	}
+
+	slices.SortFunc(cfgs, func(a, b *config.AwsConfig) int { return strings.Compare(a.ProfileName, b.ProfileName) })
+	return cfgs
+}
+
+// azureAppsHaveRole returns true if the role is assigned to the user in any of the AWS applications.
+func azureAppsHaveRole(apps []*external.AadAwsApp, role string) bool {
+	for _, app := range apps {
+		if slices.Contains(app.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
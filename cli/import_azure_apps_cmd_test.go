@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/client/external"
+)
+
+func Test_azureAppProfiles(t *testing.T) {
+	apps := []*external.AadAwsApp{
+		{Name: "AWS Prod", Url: "https://myapps.microsoft.com/signin/AWS%20Prod/app1?tenantId=54321",
+			Roles: []string{"arn:aws:iam::123456789012:role/Admin", "arn:aws:iam::123456789012:role/ReadOnly"}},
+		{Name: "Dev", Url: "https://myapps.microsoft.com/signin/Dev/app2?tenantId=54321",
+			Roles: []string{"arn:aws:iam::210987654321:role/path/Developer"}},
+	}
+
+	t.Run("profiles", func(t *testing.T) {
+		cfgs := azureAppProfiles(apps, "mock@example.org")
+		if len(cfgs) != 3 {
+			t.Fatalf("unexpected profiles: %+v", cfgs)
+		}
+
+		names := []string{"AWS-Prod-Admin", "AWS-Prod-ReadOnly", "Dev"}
+		for i, cfg := range cfgs {
+			if cfg.ProfileName != names[i] {
+				t.Errorf("unexpected profile name: %s", cfg.ProfileName)
+			}
+
+			if cfg.SamlProvider != "azuread" || cfg.SamlUsername != "mock@example.org" || len(cfg.SamlUrl) < 1 || len(cfg.RoleArn) < 1 {
+				t.Errorf("data mismatch: %+v", cfg)
+			}
+		}
+	})
+
+	t.Run("has role", func(t *testing.T) {
+		if !azureAppsHaveRole(apps, "arn:aws:iam::123456789012:role/ReadOnly") {
+			t.Error("assigned role not found")
+		}
+
+		if azureAppsHaveRole(apps, "arn:aws:iam::123456789012:role/Other") {
+			t.Error("found unassigned role")
+		}
+	})
+}
@@ -0,0 +1,418 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/ini.v1"
+)
+
+const importDesc = `Convert the profiles configured for another tool to aws-runas profiles.  By default, the
+converted profiles are printed in AWS config file format, use the --write flag to save them to
+the AWS config file instead.  Existing profiles are not overwritten, unless the --force flag is
+used.  The --prefix flag adds a prefix to the converted profile names, to avoid conflicts with
+existing profiles.`
+
+var importCmd = &cli.Command{
+	Name:        "import",
+	Usage:       "Import profiles from the configuration of other tools",
+	ArgsUsage:   " ",
+	Description: importDesc,
+	Subcommands: []*cli.Command{importSaml2AwsCmd, importAwsVaultCmd, importGimmeAwsCredsCmd, importOktaAwsCliCmd,
+		importOktaAppsCmd, importAzureAppsCmd, importOrgCmd, importKeycloakSessionCmd},
+}
+
+var importSaml2AwsCmd = &cli.Command{
+	Name:  "saml2aws",
+	Usage: "Import the accounts configured for saml2aws",
+	Description: importDesc + `
+
+The --sessions flag copies unexpired credentials saml2aws saved in the AWS credentials file to
+the aws-runas cache for the converted profiles, so they can be used without re-authenticating.`,
+	Flags: []cli.Flag{importFileFlag, importPrefixFlag, importWriteFlag, importForceFlag, importSessionsFlag},
+
+	Action: func(ctx *cli.Context) error {
+		src := ctx.String(importFileFlag.Name)
+		if len(src) < 1 {
+			src = saml2awsConfigFile()
+		}
+
+		cfgs, err := config.ImportSaml2Aws(src)
+		if err != nil {
+			return err
+		}
+
+		names := make(map[*config.AwsConfig]string)
+		for _, cfg := range cfgs {
+			names[cfg] = cfg.ProfileName
+		}
+
+		cfgs, err = importProfiles(ctx, os.Stdout, cfgs)
+		if err != nil {
+			return err
+		}
+
+		if ctx.Bool(importSessionsFlag.Name) {
+			for _, cfg := range cfgs {
+				importSaml2AwsSession(names[cfg], cfg)
+			}
+		}
+		return nil
+	},
+}
+
+var importAwsVaultCmd = &cli.Command{
+	Name:  "aws-vault",
+	Usage: "Import the profiles configured for aws-vault",
+	Description: importDesc + `
+
+Role profiles are converted, resolving the include_profile and parent_profile settings of
+aws-vault.  The converted profiles keep the source_profile of the original profile.  Since
+aws-vault keeps the access keys of those source profiles in its own credential store, use the
+--keychain flag to copy them (using the 'aws-vault export' command) to the AWS credentials file.
+
+The --sessions flag copies the unexpired session tokens aws-vault cached for those source
+profiles to the aws-runas cache, so the converted profiles use them instead of requesting new
+sessions (and prompting for MFA again).  The aws-vault credential store must be unlocked, since
+aws-vault is not able to prompt for the passphrase.`,
+	Flags: []cli.Flag{importFileFlag, importPrefixFlag, importWriteFlag, importForceFlag, importKeychainFlag,
+		importSessionsFlag},
+
+	Action: func(ctx *cli.Context) error {
+		src := ctx.String(importFileFlag.Name)
+		if len(src) < 1 {
+			src = awsconfig.DefaultSharedConfigFilename()
+			if v, ok := os.LookupEnv("AWS_CONFIG_FILE"); ok {
+				src = v
+			}
+		}
+
+		cfgs, base, err := config.ImportAwsVault(src)
+		if err != nil {
+			return err
+		}
+
+		if _, err = importProfiles(ctx, os.Stdout, cfgs); err != nil {
+			return err
+		}
+
+		if ctx.Bool(importKeychainFlag.Name) {
+			for _, p := range base {
+				if err = importAwsVaultKeys(p); err != nil {
+					log.Warningf("unable to import aws-vault credentials for profile %s: %v", p, err)
+					continue
+				}
+				log.Infof("imported aws-vault credentials for profile %s", p)
+			}
+		}
+
+		if ctx.Bool(importSessionsFlag.Name) {
+			importAwsVaultSessions(base)
+		}
+		return nil
+	},
+}
+
+var importGimmeAwsCredsCmd = &cli.Command{
+	Name:  "gimme-aws-creds",
+	Usage: "Import the profiles configured for gimme-aws-creds",
+	Description: importDesc + `
+
+Profiles using the Okta AWS app URL are converted to Okta SAML profiles.  A profile with more
+than one role ARN in its aws_rolename setting is converted to a profile for each role.`,
+	Flags: []cli.Flag{importFileFlag, importPrefixFlag, importWriteFlag, importForceFlag},
+
+	Action: func(ctx *cli.Context) error {
+		src := ctx.String(importFileFlag.Name)
+		if len(src) < 1 {
+			src = homeConfigFile("OKTA_CONFIG", ".okta_aws_login_config")
+		}
+
+		cfgs, err := config.ImportGimmeAwsCreds(src)
+		if err != nil {
+			return err
+		}
+
+		_, err = importProfiles(ctx, os.Stdout, cfgs)
+		return err
+	},
+}
+
+var importOktaAwsCliCmd = &cli.Command{
+	Name:  "okta-aws-cli",
+	Usage: "Import the profile configured for okta-aws-cli",
+	Description: importDesc + `
+
+The Okta AWS app URL and role of the okta-aws-cli config.properties file are converted to an Okta
+SAML profile.`,
+	Flags: []cli.Flag{importFileFlag, importPrefixFlag, importWriteFlag, importForceFlag},
+
+	Action: func(ctx *cli.Context) error {
+		src := ctx.String(importFileFlag.Name)
+		if len(src) < 1 {
+			src = homeConfigFile("", filepath.Join(".okta", "config.properties"))
+		}
+
+		cfgs, err := config.ImportOktaAwsCli(src)
+		if err != nil {
+			return err
+		}
+
+		_, err = importProfiles(ctx, os.Stdout, cfgs)
+		return err
+	},
+}
+
+var importFileFlag = &cli.StringFlag{
+	Name:    "file",
+	Aliases: []string{"f"},
+	Usage:   "path to the configuration file of the other tool, instead of its default location",
+}
+
+var importPrefixFlag = &cli.StringFlag{
+	Name:  "prefix",
+	Usage: "prefix to add to the name of the imported profiles",
+}
+
+var importWriteFlag = &cli.BoolFlag{
+	Name:    "write",
+	Aliases: []string{"w"},
+	Usage:   "save the imported profiles to the AWS config file, instead of printing them",
+}
+
+var importForceFlag = &cli.BoolFlag{
+	Name:  "force",
+	Usage: "overwrite existing profiles in the AWS config file",
+}
+
+var importSessionsFlag = &cli.BoolFlag{
+	Name:  "sessions",
+	Usage: "copy unexpired session credentials of the other tool to the aws-runas cache",
+}
+
+var importKeychainFlag = &cli.BoolFlag{
+	Name:  "keychain",
+	Usage: "copy access keys from the aws-vault credential store to the AWS credentials file",
+}
+
+// awsVaultExport returns the credential_process formatted output of 'aws-vault export' for the profile, it's a variable
+// so tests can avoid calling aws-vault.
+var awsVaultExport = func(profile string) ([]byte, error) {
+	return exec.Command("aws-vault", "export", "--no-session", "--format=json", profile).Output() //nolint:gosec
+}
+
+// awsVaultList returns the output of 'aws-vault list', it's a variable so tests can avoid calling aws-vault.
+var awsVaultList = func() ([]byte, error) {
+	return exec.Command("aws-vault", "list").Output()
+}
+
+// awsVaultSession returns the credential_process formatted output of 'aws-vault export' for the profile, which is the
+// session aws-vault cached for the profile, if it's still valid.  Stdin is not connected, so aws-vault fails instead of
+// waiting for a passphrase if the credential store is locked.  It's a variable so tests can avoid calling aws-vault.
+var awsVaultSession = func(profile string) ([]byte, error) {
+	return exec.Command("aws-vault", "export", "--format=json", profile).Output() //nolint:gosec
+}
+
+func saml2awsConfigFile() string {
+	return homeConfigFile("SAML2AWS_CONFIGFILE", ".saml2aws")
+}
+
+// homeConfigFile returns the value of the env var, if set, otherwise the path of the named file in the user's home
+// directory.
+func homeConfigFile(env, name string) string {
+	if v, ok := os.LookupEnv(env); ok {
+		return v
+	}
+
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, name)
+}
+
+// importProfiles applies the prefix to the names of the imported profiles, then either prints them to w, or saves them
+// to the AWS config file.  The profiles which were printed or saved are returned.
+func importProfiles(ctx *cli.Context, w io.Writer, cfgs []*config.AwsConfig) ([]*config.AwsConfig, error) {
+	for _, cfg := range cfgs {
+		cfg.ProfileName = ctx.String(importPrefixFlag.Name) + cfg.ProfileName
+	}
+
+	if !ctx.Bool(importWriteFlag.Name) {
+		f := ini.Empty()
+		for _, cfg := range cfgs {
+			if err := f.Section("profile " + cfg.ProfileName).ReflectFrom(cfg); err != nil {
+				return nil, err
+			}
+		}
+
+		_, err := f.WriteTo(w)
+		return cfgs, err
+	}
+
+	existing, err := config.DefaultIniLoader.Profiles()
+	if err != nil {
+		return nil, err
+	}
+
+	saved := make([]*config.AwsConfig, 0)
+	for _, cfg := range cfgs {
+		if _, ok := existing[cfg.ProfileName]; ok && !ctx.Bool(importForceFlag.Name) {
+			log.Warningf("profile %s already exists, skipping", cfg.ProfileName)
+			continue
+		}
+
+		if err = config.DefaultIniLoader.SaveProfile(cfg); err != nil {
+			return saved, err
+		}
+
+		log.Infof("imported profile %s", cfg.ProfileName)
+		saved = append(saved, cfg)
+	}
+	return saved, nil
+}
+
+// importSaml2AwsSession copies the credentials saml2aws saved for the profile to the aws-runas cache of cfg.  Errors are
+// logged, since a missing or expired session should not stop the import.
+func importSaml2AwsSession(profile string, cfg *config.AwsConfig) {
+	src := awsconfig.DefaultSharedCredentialsFilename()
+	if v, ok := os.LookupEnv("AWS_SHARED_CREDENTIALS_FILE"); ok {
+		src = v
+	}
+
+	creds, err := config.Saml2AwsCredentials(src, profile)
+	if err != nil {
+		log.Debugf("no saml2aws session imported for profile %s: %v", cfg.ProfileName, err)
+		return
+	}
+
+	if creds.Expiration.Before(time.Now()) {
+		log.Debugf("saml2aws session for profile %s is expired", cfg.ProfileName)
+		return
+	}
+
+	if err = clientFactory.ImportProfileCredentials(cfg, creds); err != nil {
+		log.Warningf("unable to import saml2aws session for profile %s: %v", cfg.ProfileName, err)
+		return
+	}
+	log.Infof("imported saml2aws session for profile %s", cfg.ProfileName)
+}
+
+func importAwsVaultKeys(profile string) error {
+	out, err := awsVaultExport(profile)
+	if err != nil {
+		return err
+	}
+
+	pc := new(credentials.ProcessCredentials)
+	if err = json.Unmarshal(out, pc); err != nil {
+		return fmt.Errorf("invalid aws-vault export output: %w", err)
+	}
+
+	if len(pc.SessionToken) > 0 {
+		return errors.New("aws-vault returned session credentials, not access keys")
+	}
+
+	return config.DefaultIniLoader.SaveAccessKeys(profile, &credentials.Credentials{
+		AccessKeyId:     pc.AccessKeyId,
+		SecretAccessKey: pc.SecretAccessKey,
+	})
+}
+
+// importAwsVaultSessions copies the valid session tokens aws-vault cached for the profiles to the aws-runas session
+// token cache.  Only profiles with a cached session are exported, so aws-vault never requests a new session.  Errors
+// are logged, since a missing or expired session should not stop the import.
+func importAwsVaultSessions(profiles []string) {
+	out, err := awsVaultList()
+	if err != nil {
+		log.Warningf("unable to list aws-vault sessions: %v", err)
+		return
+	}
+
+	sessions := awsVaultSessionProfiles(out)
+	for _, p := range profiles {
+		if !sessions[p] {
+			log.Debugf("no aws-vault session found for profile %s", p)
+			continue
+		}
+
+		if err = importAwsVaultSession(p); err != nil {
+			log.Warningf("unable to import aws-vault session for profile %s: %v", p, err)
+			continue
+		}
+		log.Infof("imported aws-vault session for profile %s", p)
+	}
+}
+
+// awsVaultSessionProfiles returns the profiles which have a cached session token in the 'aws-vault list' output.  The
+// sessions column lists each cached session as the session type and its remaining lifetime, like
+// sts.GetSessionToken:59m12s, or '-' if there are none.
+func awsVaultSessionProfiles(out []byte) map[string]bool {
+	profiles := make(map[string]bool)
+	for _, l := range strings.Split(string(out), "\n") {
+		f := strings.Fields(l)
+		if len(f) < 3 || f[0] == "Profile" || strings.HasPrefix(f[0], "=") {
+			continue
+		}
+
+		for _, s := range f[2:] {
+			if strings.Contains(s, "GetSessionToken:") {
+				profiles[f[0]] = true
+			}
+		}
+	}
+	return profiles
+}
+
+func importAwsVaultSession(profile string) error {
+	out, err := awsVaultSession(profile)
+	if err != nil {
+		return err
+	}
+
+	pc := new(credentials.ProcessCredentials)
+	if err = json.Unmarshal(out, pc); err != nil {
+		return fmt.Errorf("invalid aws-vault export output: %w", err)
+	}
+
+	if len(pc.SessionToken) < 1 || pc.Expiration == nil {
+		return errors.New("aws-vault did not return session credentials")
+	}
+
+	if pc.Expiration.Before(time.Now()) {
+		return errors.New("aws-vault session is expired")
+	}
+
+	cfg, err := configResolver.Config(profile)
+	if err != nil {
+		cfg = &config.AwsConfig{ProfileName: profile}
+	}
+
+	return clientFactory.ImportProfileCredentials(cfg, &credentials.Credentials{
+		AccessKeyId:     pc.AccessKeyId,
+		SecretAccessKey: pc.SecretAccessKey,
+		Token:           pc.SessionToken,
+		Expiration:      *pc.Expiration,
+	})
+}
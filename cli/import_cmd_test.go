@@ -0,0 +1,221 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/urfave/cli/v2"
+)
+
+func importContext(t *testing.T, args ...string) *cli.Context {
+	t.Helper()
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	for _, f := range []cli.Flag{importPrefixFlag, importWriteFlag, importForceFlag} {
+		if err := f.Apply(fs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return cli.NewContext(App, fs, nil)
+}
+
+func TestImportProfiles(t *testing.T) {
+	newCfgs := func() []*config.AwsConfig {
+		return []*config.AwsConfig{
+			{ProfileName: "admin", RoleArn: "arn:aws:iam::123456789012:role/Admin", SrcProfile: "base"},
+			{ProfileName: "existing", RoleArn: "arn:aws:iam::123456789012:role/Existing", SrcProfile: "base"},
+		}
+	}
+
+	t.Run("print", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		cfgs, err := importProfiles(importContext(t, "--prefix", "v-"), out, newCfgs())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(cfgs) != 2 || !strings.Contains(out.String(), "[profile v-admin]") ||
+			!strings.Contains(out.String(), "source_profile = base") {
+			t.Errorf("unexpected output: %s", out.String())
+		}
+	})
+
+	t.Run("write", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "config")
+		if err := os.WriteFile(f, []byte("[profile existing]\nregion = us-east-1\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("AWS_CONFIG_FILE", f)
+
+		cfgs, err := importProfiles(importContext(t, "--write"), new(bytes.Buffer), newCfgs())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(cfgs) != 1 || cfgs[0].ProfileName != "admin" {
+			t.Error("existing profile was overwritten")
+		}
+
+		data, _ := os.ReadFile(f)
+		if !strings.Contains(string(data), "[profile admin]") {
+			t.Error("profile was not written")
+		}
+	})
+
+	t.Run("force", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "config")
+		if err := os.WriteFile(f, []byte("[profile existing]\nregion = us-east-1\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("AWS_CONFIG_FILE", f)
+
+		cfgs, err := importProfiles(importContext(t, "--write", "--force"), new(bytes.Buffer), newCfgs())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(cfgs) != 2 {
+			t.Error("existing profile was not overwritten")
+		}
+	})
+}
+
+func TestImportAwsVaultKeys(t *testing.T) {
+	defer func(f func(string) ([]byte, error)) { awsVaultExport = f }(awsVaultExport)
+
+	t.Run("good", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "credentials")
+		t.Setenv("AWS_SHARED_CREDENTIALS_FILE", f)
+
+		awsVaultExport = func(string) ([]byte, error) {
+			return []byte(`{"Version":1,"AccessKeyId":"AKIAMOCK","SecretAccessKey":"MockSecret"}`), nil
+		}
+
+		if err := importAwsVaultKeys("base"); err != nil {
+			t.Error(err)
+			return
+		}
+
+		data, _ := os.ReadFile(f)
+		if !strings.Contains(string(data), "[base]") || !strings.Contains(string(data), "AKIAMOCK") {
+			t.Errorf("credentials not written: %s", data)
+		}
+	})
+
+	t.Run("session credentials", func(t *testing.T) {
+		t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+		awsVaultExport = func(string) ([]byte, error) {
+			return []byte(`{"Version":1,"AccessKeyId":"ASIAMOCK","SecretAccessKey":"MockSecret","SessionToken":"token"}`), nil
+		}
+
+		if err := importAwsVaultKeys("base"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("export error", func(t *testing.T) {
+		awsVaultExport = func(string) ([]byte, error) {
+			return nil, errors.New("error")
+		}
+
+		if err := importAwsVaultKeys("base"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestAwsVaultSessionProfiles(t *testing.T) {
+	out := []byte(`Profile                  Credentials              Sessions
+=======                  ===========              ========
+default                  -                        -
+base                     base                     sts.GetSessionToken:59m12s
+other                    other                    -
+`)
+
+	p := awsVaultSessionProfiles(out)
+	if len(p) != 1 || !p["base"] {
+		t.Errorf("unexpected session profiles: %v", p)
+	}
+}
+
+func TestImportAwsVaultSession(t *testing.T) {
+	defer func(f func(string) ([]byte, error)) { awsVaultSession = f }(awsVaultSession)
+
+	t.Run("good", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("AWS_RUNAS_CACHE_DIR", dir)
+
+		exp := time.Now().Add(1 * time.Hour).UTC().Format(time.RFC3339)
+		awsVaultSession = func(string) ([]byte, error) {
+			return []byte(`{"Version":1,"AccessKeyId":"ASIAMOCK","SecretAccessKey":"MockSecret","SessionToken":"token","Expiration":"` + exp + `"}`), nil
+		}
+
+		if err := importAwsVaultSession("base"); err != nil {
+			t.Error(err)
+			return
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, ".aws_session_token_base"))
+		if err != nil || !strings.Contains(string(data), "ASIAMOCK") {
+			t.Errorf("session not cached: %s", data)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		exp := time.Now().Add(-1 * time.Hour).UTC().Format(time.RFC3339)
+		awsVaultSession = func(string) ([]byte, error) {
+			return []byte(`{"Version":1,"AccessKeyId":"ASIAMOCK","SecretAccessKey":"MockSecret","SessionToken":"token","Expiration":"` + exp + `"}`), nil
+		}
+
+		if err := importAwsVaultSession("base"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("access keys", func(t *testing.T) {
+		awsVaultSession = func(string) ([]byte, error) {
+			return []byte(`{"Version":1,"AccessKeyId":"AKIAMOCK","SecretAccessKey":"MockSecret"}`), nil
+		}
+
+		if err := importAwsVaultSession("base"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("export error", func(t *testing.T) {
+		awsVaultSession = func(string) ([]byte, error) {
+			return nil, errors.New("error")
+		}
+
+		if err := importAwsVaultSession("base"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
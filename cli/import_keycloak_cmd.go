@@ -0,0 +1,278 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const keycloakIdentityCookie = "KEYCLOAK_IDENTITY"
+
+var importKeycloakSessionCmd = &cli.Command{
+	Name:      "keycloak-session",
+	Usage:     "Import an existing Keycloak SSO session for a profile",
+	ArgsUsage: "profile_name",
+	Description: `Copy the cookies of an existing Keycloak SSO session to the aws-runas cookie jar of the profile,
+so SAML assertions and OIDC identity tokens are requested using that session instead of logging
+in with a password.  This is useful when password login to Keycloak is disabled for interactive
+use, or requires a login method aws-runas does not support.
+
+The session is either the value of the KEYCLOAK_IDENTITY cookie, provided with the --cookie
+flag (for example, copied from the developer tools of a logged in browser), or a cookie export
+file provided with the --file flag.  Both the Netscape cookies.txt format (used by curl and most
+browser export extensions) and JSON arrays of cookie objects are supported.  Only the Keycloak
+session cookies for the host of the profile's SAML or Web Identity url are imported.  Once the
+session expires, you will need to import a new session, or authenticate using a password.`,
+	Flags: []cli.Flag{importCookieFlag, importCookieFileFlag},
+
+	BashComplete: bashCompleteProfile,
+
+	Action: func(ctx *cli.Context) error {
+		profile, cfg, err := resolveConfig(ctx, 1)
+		if err != nil {
+			return err
+		}
+
+		if len(profile) < 1 {
+			return errors.New("missing profile name")
+		}
+
+		// the first of the identity provider URLs is the primary endpoint, any others are only used for failover
+		idpUrls := cfg.SamlUrls()
+		provider := cfg.SamlProvider
+		if len(idpUrls) < 1 {
+			idpUrls = cfg.WebIdentityUrls()
+			provider = cfg.WebIdentityProvider
+		}
+
+		if len(idpUrls) < 1 {
+			return fmt.Errorf("profile %s is not configured for SAML or Web Identity authentication", profile)
+		}
+		idpUrl := idpUrls[0]
+
+		if len(provider) > 0 && !strings.EqualFold(provider, "keycloak") {
+			return fmt.Errorf("profile %s uses the %s identity provider, not keycloak", profile, provider)
+		}
+
+		var cookies []*http.Cookie
+		if v := ctx.String(importCookieFlag.Name); len(v) > 0 {
+			var c *http.Cookie
+			if c, err = keycloakSessionCookie(idpUrl, v); err != nil {
+				return err
+			}
+			cookies = append(cookies, c)
+		}
+
+		if f := ctx.String(importCookieFileFlag.Name); len(f) > 0 {
+			var data []byte
+			if data, err = os.ReadFile(f); err != nil {
+				return err
+			}
+
+			var c []*http.Cookie
+			if c, err = keycloakExportCookies(idpUrl, data); err != nil {
+				return err
+			}
+			cookies = append(cookies, c...)
+		}
+
+		if len(cookies) < 1 {
+			return fmt.Errorf("no Keycloak session found, use the --%s or --%s flag", importCookieFlag.Name,
+				importCookieFileFlag.Name)
+		}
+
+		if err = clientFactory.ImportSessionCookies(cfg, cookies); err != nil {
+			return err
+		}
+		log.Infof("imported %d Keycloak session cookies for profile %s", len(cookies), profile)
+		return nil
+	},
+}
+
+var importCookieFlag = &cli.StringFlag{
+	Name:  "cookie",
+	Usage: "value of the " + keycloakIdentityCookie + " cookie of the Keycloak session",
+}
+
+var importCookieFileFlag = &cli.StringFlag{
+	Name:    "file",
+	Aliases: []string{"f"},
+	Usage:   "path to a cookie export file (Netscape cookies.txt or JSON format) containing the Keycloak session",
+}
+
+// keycloakSessionCookie returns the KEYCLOAK_IDENTITY cookie with the given value, scoped to the path of the Keycloak
+// realm found in idpUrl, which is how Keycloak sets the cookie.
+func keycloakSessionCookie(idpUrl, value string) (*http.Cookie, error) {
+	u, err := url.Parse(idpUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	i := strings.Index(u.Path, "/realms/")
+	if i < 0 {
+		return nil, fmt.Errorf("unable to find the Keycloak realm in url %s", idpUrl)
+	}
+
+	realm, _, _ := strings.Cut(u.Path[i+len("/realms/"):], "/")
+	if len(realm) < 1 {
+		return nil, fmt.Errorf("unable to find the Keycloak realm in url %s", idpUrl)
+	}
+
+	c := &http.Cookie{
+		Name:     keycloakIdentityCookie,
+		Value:    strings.TrimSpace(value),
+		Path:     u.Path[:i] + "/realms/" + realm + "/",
+		Secure:   u.Scheme == "https",
+		HttpOnly: true,
+	}
+	return c, nil
+}
+
+// keycloakExportCookies returns the Keycloak session cookies for the host of idpUrl found in the cookie export data,
+// which is either in Netscape cookies.txt format, or a JSON array of cookies.
+func keycloakExportCookies(idpUrl string, data []byte) ([]*http.Cookie, error) {
+	u, err := url.Parse(idpUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []exportedCookie
+	if data = bytes.TrimSpace(data); bytes.HasPrefix(data, []byte("[")) {
+		all, err = parseJsonCookies(data)
+	} else {
+		all, err = parseNetscapeCookies(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cookies := make([]*http.Cookie, 0)
+	for _, c := range all {
+		if !isKeycloakSessionCookie(c.Name) || !c.sentTo(u.Hostname()) {
+			continue
+		}
+
+		// host-only cookies must not have the Domain attribute set
+		if c.hostOnly {
+			c.Domain = ""
+		}
+		cookies = append(cookies, c.Cookie)
+	}
+	return cookies, nil
+}
+
+// isKeycloakSessionCookie returns true if name is one of the cookies Keycloak uses to track the SSO session.
+func isKeycloakSessionCookie(name string) bool {
+	name = strings.TrimSuffix(name, "_LEGACY")
+	return name == keycloakIdentityCookie || name == "KEYCLOAK_SESSION" || name == "AUTH_SESSION_ID"
+}
+
+// exportedCookie is a cookie read from an export file, the Domain of the cookie is always set to the domain in the file.
+type exportedCookie struct {
+	*http.Cookie
+	hostOnly bool
+}
+
+// sentTo returns true if the cookie is sent in requests to host.
+func (c exportedCookie) sentTo(host string) bool {
+	domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+	host = strings.ToLower(host)
+
+	if c.hostOnly {
+		return host == domain
+	}
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// parseNetscapeCookies parses cookies in the Netscape cookies.txt format, which has 7 tab separated fields per line:
+// domain, include subdomains, path, secure, expiration (unix time), name, and value.
+func parseNetscapeCookies(data []byte) ([]exportedCookie, error) {
+	cookies := make([]exportedCookie, 0)
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+
+		// curl marks HttpOnly cookies with this prefix, any other line starting with # is a comment
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if len(line) < 1 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		f := strings.Split(line, "\t")
+		if len(f) != 7 {
+			return nil, fmt.Errorf("invalid cookies.txt line: %s", line)
+		}
+
+		c := exportedCookie{
+			Cookie: &http.Cookie{Domain: f[0], Path: f[2], Secure: strings.EqualFold(f[3], "TRUE"), Name: f[5],
+				Value: f[6], HttpOnly: httpOnly},
+			hostOnly: !strings.EqualFold(f[1], "TRUE"),
+		}
+
+		if exp, err := strconv.ParseInt(f[4], 10, 64); err == nil && exp > 0 {
+			c.Expires = time.Unix(exp, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, s.Err()
+}
+
+// jsonCookie is the format of the cookies in JSON exports from browser extensions.
+type jsonCookie struct {
+	Name           string  `json:"name"`
+	Value          string  `json:"value"`
+	Domain         string  `json:"domain"`
+	Path           string  `json:"path"`
+	Secure         bool    `json:"secure"`
+	HttpOnly       bool    `json:"httpOnly"`
+	HostOnly       bool    `json:"hostOnly"`
+	ExpirationDate float64 `json:"expirationDate"`
+}
+
+// parseJsonCookies parses a JSON array of cookie objects, as exported by browser extensions.
+func parseJsonCookies(data []byte) ([]exportedCookie, error) {
+	jc := make([]jsonCookie, 0)
+	if err := json.Unmarshal(data, &jc); err != nil {
+		return nil, err
+	}
+
+	cookies := make([]exportedCookie, 0, len(jc))
+	for _, v := range jc {
+		c := exportedCookie{
+			Cookie: &http.Cookie{Name: v.Name, Value: v.Value, Domain: v.Domain, Path: v.Path, Secure: v.Secure,
+				HttpOnly: v.HttpOnly},
+			hostOnly: v.HostOnly,
+		}
+
+		if v.ExpirationDate > 0 {
+			c.Expires = time.Unix(int64(v.ExpirationDate), 0)
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, nil
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+)
+
+func Test_keycloakSessionCookie(t *testing.T) {
+	t.Run("saml", func(t *testing.T) {
+		c, err := keycloakSessionCookie("https://kc.example.org/realms/mock/protocol/saml/clients/aws", " token ")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.Name != keycloakIdentityCookie || c.Value != "token" || c.Path != "/realms/mock/" || !c.Secure {
+			t.Errorf("data mismatch: %+v", c)
+		}
+	})
+
+	t.Run("legacy path", func(t *testing.T) {
+		c, err := keycloakSessionCookie("http://kc.example.org/auth/realms/mock", "token")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.Path != "/auth/realms/mock/" || c.Secure {
+			t.Errorf("data mismatch: %+v", c)
+		}
+	})
+
+	t.Run("no realm", func(t *testing.T) {
+		if _, err := keycloakSessionCookie("https://kc.example.org/saml", "token"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func Test_keycloakExportCookies(t *testing.T) {
+	const idpUrl = "https://kc.example.org/realms/mock/protocol/saml/clients/aws"
+
+	t.Run("netscape", func(t *testing.T) {
+		data := "# Netscape HTTP Cookie File\n" +
+			"#HttpOnly_kc.example.org\tFALSE\t/realms/mock/\tTRUE\t0\tKEYCLOAK_IDENTITY\tidentity\n" +
+			".example.org\tTRUE\t/realms/mock/\tTRUE\t4102444800\tKEYCLOAK_SESSION\tsession\n" +
+			"kc.example.org\tFALSE\t/\tFALSE\t0\tother\tvalue\n" +
+			"other.example.org\tFALSE\t/realms/mock/\tTRUE\t0\tKEYCLOAK_IDENTITY_LEGACY\tother-host\n"
+
+		cookies, err := keycloakExportCookies(idpUrl, []byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(cookies) != 2 {
+			t.Fatalf("unexpected cookies: %v", cookies)
+		}
+
+		if c := cookies[0]; c.Name != keycloakIdentityCookie || len(c.Domain) > 0 || !c.HttpOnly || !c.Expires.IsZero() {
+			t.Errorf("data mismatch: %+v", c)
+		}
+
+		if c := cookies[1]; c.Name != "KEYCLOAK_SESSION" || c.Domain != ".example.org" || c.Expires.Unix() != 4102444800 {
+			t.Errorf("data mismatch: %+v", c)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		data := `[
+  {"name": "KEYCLOAK_IDENTITY", "value": "identity", "domain": "kc.example.org", "path": "/realms/mock/",
+   "secure": true, "httpOnly": true, "hostOnly": true},
+  {"name": "AUTH_SESSION_ID", "value": "auth", "domain": "kc.example.org", "path": "/realms/mock/",
+   "hostOnly": true, "expirationDate": 4102444800.5},
+  {"name": "KEYCLOAK_IDENTITY", "value": "other", "domain": "other.example.org", "path": "/", "hostOnly": true}
+]`
+
+		cookies, err := keycloakExportCookies(idpUrl, []byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(cookies) != 2 || cookies[0].Value != "identity" || cookies[1].Value != "auth" {
+			t.Errorf("unexpected cookies: %v", cookies)
+		}
+	})
+
+	t.Run("invalid netscape", func(t *testing.T) {
+		if _, err := keycloakExportCookies(idpUrl, []byte("kc.example.org\tFALSE\t/")); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		if _, err := keycloakExportCookies(idpUrl, []byte("[{")); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/mmmorris1975/aws-runas/client/external"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/urfave/cli/v2"
+)
+
+var importOktaAppsCmd = &cli.Command{
+	Name:  "okta-apps",
+	Usage: "Generate a profile for each AWS role assigned to an Okta user",
+	Description: importDesc + `
+
+The AWS Account Federation applications assigned to the Okta user, and the roles assigned to the
+user in each application, are listed using the Okta API at the --url of the Okta organization.
+This requires an Okta API token (set using the --token flag, or the OKTA_API_TOKEN environment
+variable) which is allowed to read users and applications, like a token created by a read-only
+administrator.  An Okta SAML profile is generated for each role, using the embed link of the
+application as the SAML URL.  The profiles are named after the label of the application, with
+the role name appended when the user has more than one role in the application.`,
+	Flags: []cli.Flag{importOktaUrlFlag, importOktaUserFlag, importOktaTokenFlag, importPrefixFlag, importWriteFlag,
+		importForceFlag},
+
+	Action: func(ctx *cli.Context) error {
+		orgUrl := ctx.String(importOktaUrlFlag.Name)
+		user := ctx.String(importOktaUserFlag.Name)
+		token := ctx.String(importOktaTokenFlag.Name)
+
+		if len(orgUrl) < 1 || len(user) < 1 || len(token) < 1 {
+			return errors.New("the Okta url, user, and API token are required")
+		}
+
+		apps, err := external.GetOktaAwsApps(ctx.Context, orgUrl, token, user)
+		if err != nil {
+			return err
+		}
+
+		_, err = importProfiles(ctx, os.Stdout, oktaAppProfiles(apps, user))
+		return err
+	},
+}
+
+var importOktaUrlFlag = &cli.StringFlag{
+	Name:    "url",
+	Aliases: []string{"u"},
+	Usage:   "the url of the Okta organization",
+}
+
+var importOktaUserFlag = &cli.StringFlag{
+	Name:  "user",
+	Usage: "the Okta login of the user, which is also the saml_username of the generated profiles",
+}
+
+var importOktaTokenFlag = &cli.StringFlag{
+	Name:    "token",
+	Usage:   "the Okta API token",
+	EnvVars: []string{"OKTA_API_TOKEN"},
+}
+
+// oktaAppProfiles returns an Okta SAML profile for each role of the AWS applications.
+func oktaAppProfiles(apps []*external.OktaAwsApp, user string) []*config.AwsConfig {
+	cfgs := make([]*config.AwsConfig, 0)
+	for _, app := range apps {
+		name := strings.Join(strings.Fields(app.Label), "-")
+
+		for _, role := range app.Roles {
+			cfg := &config.AwsConfig{
+				ProfileName:  name,
+				SamlUrl:      app.Url,
+				SamlUsername: user,
+				SamlProvider: "okta",
+				RoleArn:      role,
+			}
+
+			if len(app.Roles) > 1 {
+				if r, err := arn.Parse(role); err == nil {
+					cfg.ProfileName = name + "-" + r.Resource[strings.LastIndex(r.Resource, "/")+1:]
+				}
+			}
+			cfgs = append(cfgs, cfg)
+		}
+	}
+
+	slices.SortFunc(cfgs, func(a, b *config.AwsConfig) int { return strings.Compare(a.ProfileName, b.ProfileName) })
+	return cfgs
+}
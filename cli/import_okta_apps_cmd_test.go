@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/client/external"
+)
+
+func Test_oktaAppProfiles(t *testing.T) {
+	apps := []*external.OktaAwsApp{
+		{Label: "AWS Prod", Url: "https://mock.okta.com/home/amazon_aws/0oaprod/272",
+			Roles: []string{"arn:aws:iam::123456789012:role/Admin", "arn:aws:iam::123456789012:role/ReadOnly"}},
+		{Label: "Dev", Url: "https://mock.okta.com/home/amazon_aws/0oadev/272",
+			Roles: []string{"arn:aws:iam::210987654321:role/path/Developer"}},
+		{Label: "Empty", Url: "https://mock.okta.com/home/amazon_aws/0oaempty/272"},
+	}
+
+	cfgs := oktaAppProfiles(apps, "mock@example.org")
+	if len(cfgs) != 3 {
+		t.Fatalf("unexpected profiles: %+v", cfgs)
+	}
+
+	names := []string{"AWS-Prod-Admin", "AWS-Prod-ReadOnly", "Dev"}
+	for i, cfg := range cfgs {
+		if cfg.ProfileName != names[i] {
+			t.Errorf("unexpected profile name: %s", cfg.ProfileName)
+		}
+
+		if cfg.SamlProvider != "okta" || cfg.SamlUsername != "mock@example.org" || len(cfg.SamlUrl) < 1 || len(cfg.RoleArn) < 1 {
+			t.Errorf("data mismatch: %+v", cfg)
+		}
+	}
+
+	if cfgs[2].RoleArn != "arn:aws:iam::210987654321:role/path/Developer" {
+		t.Errorf("unexpected role: %s", cfgs[2].RoleArn)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/urfave/cli/v2"
+)
+
+var importOrgCmd = &cli.Command{
+	Name:      "organization",
+	Aliases:   []string{"org"},
+	Usage:     "Generate a profile for each account in an AWS Organization",
+	ArgsUsage: "profile_name",
+	Description: importDesc + `
+
+The accounts are listed using the credentials of 'profile_name', which must be allowed to call
+the Organizations ListAccounts API (the management account, or a delegated administrator).  A
+profile is generated for each active account, assuming the role named by the --role template,
+using the --source-profile (default: 'profile_name') credentials.  The --role and --name flags
+are Go templates, with the .Id, .Name, and .Email fields of the account available.  Run the
+command again with the --write flag to add profiles for new accounts, and with --force to also
+update the existing profiles.`,
+	Flags: []cli.Flag{importOrgRoleFlag, importOrgNameFlag, importOrgSourceFlag, importPrefixFlag, importWriteFlag,
+		importForceFlag},
+
+	BashComplete: bashCompleteProfile,
+
+	Action: func(ctx *cli.Context) error {
+		profile, cfg, err := resolveConfig(ctx, 1)
+		if err != nil {
+			return err
+		}
+
+		if len(profile) < 1 {
+			return errors.New("missing profile name")
+		}
+
+		roleTmpl, err := template.New("role").Option("missingkey=error").Parse(ctx.String(importOrgRoleFlag.Name))
+		if err != nil {
+			return fmt.Errorf("invalid role template: %w", err)
+		}
+
+		nameTmpl, err := template.New("name").Option("missingkey=error").Parse(ctx.String(importOrgNameFlag.Name))
+		if err != nil {
+			return fmt.Errorf("invalid name template: %w", err)
+		}
+
+		source := ctx.String(importOrgSourceFlag.Name)
+		if len(source) < 1 {
+			source = profile
+		}
+
+		c, err := clientFactory.Get(cfg)
+		if err != nil {
+			return err
+		}
+
+		api := organizations.NewFromConfig(c.ConfigProvider())
+		cfgs, err := orgProfiles(ctx.Context, api, roleTmpl, nameTmpl, source)
+		if err != nil {
+			return err
+		}
+
+		_, err = importProfiles(ctx, os.Stdout, cfgs)
+		return err
+	},
+}
+
+var importOrgRoleFlag = &cli.StringFlag{
+	Name:    "role",
+	Aliases: []string{"r"},
+	Usage:   "template for the name of the role to assume in each account",
+	Value:   "OrganizationAccountAccessRole",
+}
+
+var importOrgNameFlag = &cli.StringFlag{
+	Name:    "name",
+	Aliases: []string{"n"},
+	Usage:   "template for the name of the profile for each account",
+	Value:   "{{.Name}}",
+}
+
+var importOrgSourceFlag = &cli.StringFlag{
+	Name:    "source-profile",
+	Aliases: []string{"s"},
+	Usage:   "the source_profile of the generated profiles, defaults to the profile used to list the accounts",
+}
+
+// orgAccount is the account information available to the role and profile name templates.
+type orgAccount struct {
+	Id    string
+	Name  string
+	Email string
+}
+
+// orgProfiles returns a profile for each active account in the organization, sorted by profile name.  The role ARN of
+// each profile uses the partition of the account ARN, and the role name from roleTmpl.  Whitespace in the profile names
+// is replaced with '-'.
+func orgProfiles(ctx context.Context, api organizations.ListAccountsAPIClient, roleTmpl, nameTmpl *template.Template,
+	source string) ([]*config.AwsConfig, error) {
+	cfgs := make([]*config.AwsConfig, 0)
+	names := make(map[string]string)
+
+	p := organizations.NewListAccountsPaginator(api, new(organizations.ListAccountsInput))
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range page.Accounts {
+			if a.State != types.AccountStateActive {
+				continue
+			}
+
+			cfg, err := orgProfile(a, roleTmpl, nameTmpl, source)
+			if err != nil {
+				return nil, err
+			}
+
+			if id, ok := names[cfg.ProfileName]; ok {
+				return nil, fmt.Errorf("accounts %s and %s have the same profile name: %s", id, *a.Id, cfg.ProfileName)
+			}
+			names[cfg.ProfileName] = *a.Id
+
+			cfgs = append(cfgs, cfg)
+		}
+	}
+
+	slices.SortFunc(cfgs, func(a, b *config.AwsConfig) int { return strings.Compare(a.ProfileName, b.ProfileName) })
+	return cfgs, nil
+}
+
+func orgProfile(a types.Account, roleTmpl, nameTmpl *template.Template, source string) (*config.AwsConfig, error) {
+	data := orgAccount{Id: aws.ToString(a.Id), Name: aws.ToString(a.Name), Email: aws.ToString(a.Email)}
+
+	role, err := execTemplate(roleTmpl, data)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := execTemplate(nameTmpl, data)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.Join(strings.Fields(name), "-")
+	if len(name) < 1 || len(role) < 1 {
+		return nil, fmt.Errorf("empty profile or role name for account %s", data.Id)
+	}
+
+	partition := "aws"
+	if v, err := arn.Parse(aws.ToString(a.Arn)); err == nil {
+		partition = v.Partition
+	}
+
+	return &config.AwsConfig{
+		ProfileName: name,
+		RoleArn:     arn.ARN{Partition: partition, Service: "iam", AccountID: data.Id, Resource: "role/" + role}.String(),
+		SrcProfile:  source,
+	}, nil
+}
+
+func execTemplate(t *template.Template, data any) (string, error) {
+	b := new(bytes.Buffer)
+	if err := t.Execute(b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+)
+
+func TestOrgProfiles(t *testing.T) {
+	roleTmpl := template.Must(template.New("role").Parse("OrganizationAccountAccessRole"))
+	nameTmpl := template.Must(template.New("name").Parse("org-{{.Name}}"))
+
+	t.Run("good", func(t *testing.T) {
+		cfgs, err := orgProfiles(context.Background(), new(mockOrgClient), roleTmpl, nameTmpl, "mgmt")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(cfgs) != 2 {
+			t.Fatalf("unexpected profile count: %d", len(cfgs))
+		}
+
+		if cfgs[0].ProfileName != "org-Dev-Account" || cfgs[0].SrcProfile != "mgmt" ||
+			cfgs[0].RoleArn != "arn:aws-us-gov:iam::210987654321:role/OrganizationAccountAccessRole" {
+			t.Errorf("data mismatch: %+v", cfgs[0])
+		}
+
+		if cfgs[1].ProfileName != "org-Prod" || cfgs[1].RoleArn != "arn:aws:iam::123456789012:role/OrganizationAccountAccessRole" {
+			t.Errorf("data mismatch: %+v", cfgs[1])
+		}
+	})
+
+	t.Run("duplicate name", func(t *testing.T) {
+		tmpl := template.Must(template.New("name").Parse("org"))
+		if _, err := orgProfiles(context.Background(), new(mockOrgClient), roleTmpl, tmpl, "mgmt"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("bad template", func(t *testing.T) {
+		tmpl := template.Must(template.New("role").Option("missingkey=error").Parse("{{.Role}}"))
+		if _, err := orgProfiles(context.Background(), new(mockOrgClient), tmpl, nameTmpl, "mgmt"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+type mockOrgClient bool
+
+func (m *mockOrgClient) ListAccounts(_ context.Context, in *organizations.ListAccountsInput, _ ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+	if in.NextToken == nil {
+		return &organizations.ListAccountsOutput{
+			Accounts: []types.Account{
+				{Id: aws.String("123456789012"), Name: aws.String("Prod"), State: types.AccountStateActive},
+				{Id: aws.String("111111111111"), Name: aws.String("Closed"), State: types.AccountStateSuspended},
+			},
+			NextToken: aws.String("next"),
+		}, nil
+	}
+
+	return &organizations.ListAccountsOutput{
+		Accounts: []types.Account{
+			{
+				Arn:   aws.String("arn:aws-us-gov:organizations::123456789012:account/o-mock/210987654321"),
+				Id:    aws.String("210987654321"),
+				Name:  aws.String("Dev Account"),
+				State: types.AccountStateActive,
+			},
+		},
+	}, nil
+}
@@ -20,7 +20,7 @@ import (
 var listCmd = &cli.Command{
 	Name:        "list",
 	Aliases:     []string{"ls"},
-	Usage:       "Shows IAM roles or MFA device configuration",
+	Usage:       "Shows IAM roles, MFA device or profile configuration",
 	ArgsUsage:   " ", // this hides the default '[arguments...]' help text output, since we don't use command args here
-	Subcommands: []*cli.Command{mfaCmd, rolesCmd},
+	Subcommands: []*cli.Command{mfaCmd, rolesCmd, profilesCmd},
 }
@@ -0,0 +1,201 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/urfave/cli/v2"
+)
+
+var profilesCmd = &cli.Command{
+	Name:      "profiles",
+	Usage:     "Shows the profiles in the configuration file, optionally filtered by tag",
+	ArgsUsage: " ",
+	Flags:     []cli.Flag{profilesTagFlag, profilesFmtFlag},
+
+	Action: func(ctx *cli.Context) error {
+		format := strings.ToLower(ctx.String(profilesFmtFlag.Name))
+		if !slices.Contains([]string{rolesFmtList, rolesFmtTable, rolesFmtJson}, format) {
+			return fmt.Errorf("invalid output format: %s", format)
+		}
+
+		p, err := config.DefaultIniLoader.Profiles()
+		if err != nil {
+			return err
+		}
+
+		names := make([]string, 0, len(p))
+		for k := range p {
+			names = append(names, k)
+		}
+
+		profiles := filterProfiles(profileDetails(names, time.Now()), ctx.StringSlice(profilesTagFlag.Name))
+		switch format {
+		case rolesFmtJson:
+			return printProfilesJson(os.Stdout, profiles)
+		case rolesFmtTable:
+			return printProfilesTable(os.Stdout, profiles)
+		}
+
+		for _, pd := range profiles {
+			fmt.Println(pd.Name)
+		}
+		return nil
+	},
+}
+
+var profilesTagFlag = &cli.StringSliceFlag{
+	Name:    "tag",
+	Aliases: []string{"t"},
+	Usage:   "only list profiles with the tag key=value, or the tag key if no value is given, may be repeated",
+}
+
+var profilesFmtFlag = &cli.StringFlag{
+	Name:    "output",
+	Aliases: []string{"O"},
+	Usage:   "output format, valid values: list, table or json",
+	Value:   rolesFmtList,
+}
+
+// profileDetail is the information about a single profile in the table and json output formats.
+type profileDetail struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	RoleArn    string            `json:"role_arn,omitempty"`
+	Region     string            `json:"region,omitempty"`
+	Duration   int64             `json:"duration"` // seconds, the configured or default credential duration
+	Cache      string            `json:"cache"`
+	Expiration *time.Time        `json:"expiration,omitempty"`
+	Aliases    []string          `json:"aliases,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+// profileDetails resolves the configuration for each of the named profiles, and returns the details sorted by name.
+// Only the local credential cache is checked for the cache status, like the status command.  Profiles which can not be
+// resolved are skipped.
+func profileDetails(names []string, now time.Time) []*profileDetail {
+	out := make([]*profileDetail, 0, len(names))
+	for _, name := range slices.Sorted(slices.Values(names)) {
+		cfg, err := configResolver.Config(name)
+		if err != nil {
+			log.Debugf("error loading profile %s: %v", name, err)
+			continue
+		}
+
+		tags, err := cfg.TagMap()
+		if err != nil {
+			log.Warningf("profile %s: %v", name, err)
+			continue
+		}
+
+		st := profileStatus(name, cfg, now, statusExpiringFlag.Value)
+		out = append(out, &profileDetail{
+			Name:       name,
+			Type:       cfg.ProfileType(),
+			RoleArn:    cfg.RoleArn,
+			Region:     cfg.Region,
+			Duration:   int64(profileDuration(cfg).Seconds()),
+			Cache:      st.State,
+			Expiration: st.Expiration,
+			Aliases:    cfg.AliasList(),
+			Tags:       tags,
+		})
+	}
+	return out
+}
+
+// profileDuration returns the duration of the credentials for the profile, using the aws-runas default if the profile
+// doesn't configure one.
+func profileDuration(cfg *config.AwsConfig) time.Duration {
+	switch cfg.ProfileType() {
+	case config.ProfileTypeIam, config.ProfileTypeSession:
+		if cfg.SessionTokenDuration > 0 {
+			return cfg.SessionTokenDuration
+		}
+		return credentials.SessionTokenDurationDefault
+	case config.ProfileTypeSso:
+		return 0 // managed by AWS SSO, not aws-runas
+	}
+
+	if d := cfg.RoleCredentialDuration(); d > 0 {
+		return d
+	}
+	return credentials.AssumeRoleDurationDefault
+}
+
+// filterProfiles returns the profiles which have all of the tags in the filters.
+func filterProfiles(profiles []*profileDetail, filters []string) []*profileDetail {
+	out := make([]*profileDetail, 0, len(profiles))
+	for _, p := range profiles {
+		if matchTags(p.Tags, filters) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// matchTags returns true if tags has every filter.  A filter is either a key=value pair, which must match a tag key and
+// value exactly, or a key, which matches any tag with that key.
+func matchTags(tags map[string]string, filters []string) bool {
+	for _, f := range filters {
+		k, v, hasValue := strings.Cut(f, "=")
+
+		tv, ok := tags[strings.TrimSpace(k)]
+		if !ok || (hasValue && tv != strings.TrimSpace(v)) {
+			return false
+		}
+	}
+	return true
+}
+
+func printProfilesJson(w io.Writer, profiles []*profileDetail) error {
+	out, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+func printProfilesTable(w io.Writer, profiles []*profileDetail) error {
+	dash := func(s string) string {
+		if len(s) < 1 {
+			return "-"
+		}
+		return s
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "PROFILE\tTYPE\tROLE\tREGION\tDURATION\tCACHE")
+	for _, p := range profiles {
+		d := "-"
+		if p.Duration > 0 {
+			d = (time.Duration(p.Duration) * time.Second).String()
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Name, p.Type, dash(p.RoleArn), dash(p.Region), d,
+			strings.ReplaceAll(p.Cache, "_", " "))
+	}
+	return tw.Flush()
+}
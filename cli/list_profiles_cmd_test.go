@@ -0,0 +1,252 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/urfave/cli/v2"
+)
+
+func TestListProfilesCmd_Action(t *testing.T) {
+	setProfilesConfig(t)
+
+	// the command parses its flags from the arguments of the parent context
+	newContext := func(args ...string) *cli.Context {
+		fs := flag.NewFlagSet("list", flag.ContinueOnError)
+		_ = fs.Parse(append([]string{"--", "profiles"}, args...))
+		return cli.NewContext(App, fs, nil)
+	}
+
+	t.Run("good", func(t *testing.T) {
+		for _, f := range []string{"list", "table", "json"} {
+			if err := profilesCmd.Run(newContext("-O", f, "-t", "env=prod")); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	t.Run("bad format", func(t *testing.T) {
+		if err := profilesCmd.Run(newContext("-O", "yaml")); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestListProfilesCmd_profileDetails(t *testing.T) {
+	dir := setProfilesConfig(t)
+
+	creds := &credentials.Credentials{
+		AccessKeyId:     "AKIAMOCK",
+		SecretAccessKey: "MockSecret",
+		Token:           "MockToken",
+		Expiration:      time.Now().Add(time.Hour),
+	}
+
+	cfg, err := configResolver.Config("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.CacheDir = dir
+
+	if err = client.NewClientFactory(configResolver, client.DefaultOptions).ImportProfileCredentials(cfg, creds); err != nil {
+		t.Fatal(err)
+	}
+
+	p := profileDetails([]string{"untagged", "prod", "dev", "sso", "legacy"}, time.Now())
+	if len(p) != 5 {
+		t.Fatalf("unexpected profile count: %d", len(p))
+	}
+
+	t.Run("sorted", func(t *testing.T) {
+		names := make([]string, 0)
+		for _, d := range p {
+			names = append(names, d.Name)
+		}
+
+		if strings.Join(names, ",") != "dev,legacy,prod,sso,untagged" {
+			t.Errorf("unexpected profiles: %v", names)
+		}
+	})
+
+	t.Run("role", func(t *testing.T) {
+		d := p[2]
+		if d.Type != config.ProfileTypeRole || d.RoleArn != "arn:aws:iam::123456789012:role/Admin" || d.Region != "us-west-2" {
+			t.Errorf("unexpected profile: %+v", d)
+		}
+
+		if d.Duration != 7200 || d.Cache != stateValid || d.Expiration == nil {
+			t.Errorf("unexpected duration or cache status: %+v", d)
+		}
+
+		if len(d.Aliases) != 1 || d.Aliases[0] != "p" || d.Tags["team"] != "platform" {
+			t.Errorf("unexpected aliases or tags: %+v", d)
+		}
+	})
+
+	t.Run("saml", func(t *testing.T) {
+		d := p[0]
+		if d.Type != config.ProfileTypeSaml || d.Duration != 3600 || d.Cache != stateNotCached {
+			t.Errorf("unexpected profile: %+v", d)
+		}
+	})
+
+	t.Run("session", func(t *testing.T) {
+		d := p[1]
+		if d.Type != config.ProfileTypeSession || d.Duration != 43200 {
+			t.Errorf("unexpected profile: %+v", d)
+		}
+	})
+
+	t.Run("sso", func(t *testing.T) {
+		if d := p[3]; d.Type != config.ProfileTypeSso || d.Duration != 0 {
+			t.Errorf("unexpected profile: %+v", d)
+		}
+	})
+
+	t.Run("iam", func(t *testing.T) {
+		if d := p[4]; d.Type != config.ProfileTypeIam || len(d.Tags) > 0 {
+			t.Errorf("unexpected profile: %+v", d)
+		}
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		if f := filterProfiles(p, []string{"team=platform", "env=dev"}); len(f) != 1 || f[0].Name != "dev" {
+			t.Errorf("unexpected profiles: %+v", f)
+		}
+
+		if f := filterProfiles(p, []string{"team"}); len(f) != 2 {
+			t.Errorf("unexpected profiles: %+v", f)
+		}
+
+		if f := filterProfiles(p, []string{"env=qa"}); len(f) != 0 {
+			t.Errorf("unexpected profiles: %+v", f)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		if err := printProfilesJson(out, p); err != nil {
+			t.Fatal(err)
+		}
+
+		var d []*profileDetail
+		if err := json.Unmarshal(out.Bytes(), &d); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(d) != len(p) || d[2].Name != "prod" || d[2].Cache != stateValid {
+			t.Errorf("unexpected json output: %s", out.String())
+		}
+	})
+
+	t.Run("table", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		if err := printProfilesTable(out, p); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != 6 || !strings.HasPrefix(lines[0], "PROFILE") {
+			t.Fatalf("unexpected table output: %s", out.String())
+		}
+
+		if f := strings.Fields(lines[3]); strings.Join(f, " ") != "prod role arn:aws:iam::123456789012:role/Admin us-west-2 2h0m0s valid" {
+			t.Errorf("unexpected table row: %s", lines[3])
+		}
+
+		if f := strings.Fields(lines[4]); f[2] != "-" || f[4] != "-" {
+			t.Errorf("unexpected table row: %s", lines[4])
+		}
+	})
+}
+
+func TestListProfilesCmd_matchTags(t *testing.T) {
+	tags := map[string]string{"env": "prod", "legacy": ""}
+
+	t.Run("match", func(t *testing.T) {
+		for _, f := range [][]string{nil, {"env=prod"}, {"env"}, {"legacy"}, {"legacy="}, {" env = prod ", "legacy"}} {
+			if !matchTags(tags, f) {
+				t.Errorf("filter %v did not match", f)
+			}
+		}
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		for _, f := range [][]string{{"env=dev"}, {"team"}, {"legacy=x"}, {"env=prod", "team"}} {
+			if matchTags(tags, f) {
+				t.Errorf("filter %v unexpectedly matched", f)
+			}
+		}
+	})
+}
+
+// setProfilesConfig writes the test profiles to a temporary AWS config file, and configures the configResolver to
+// use it.  The cache_dir of the profiles is returned.
+func setProfilesConfig(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	f := filepath.Join(dir, "config")
+	if err := os.WriteFile(f, []byte(strings.ReplaceAll(string(tagConfig), "CACHE_DIR", dir)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = os.Setenv("AWS_CONFIG_FILE", f)
+	t.Cleanup(func() { _ = os.Unsetenv("AWS_CONFIG_FILE") })
+
+	r := configResolver
+	t.Cleanup(func() { configResolver = r })
+	configResolver = config.NewResolver(config.DefaultIniLoader, false)
+
+	return dir
+}
+
+var tagConfig = []byte(`
+[default]
+cache_dir = CACHE_DIR
+
+[profile dev]
+saml_auth_url = https://idp.local/saml
+role_arn = arn:aws:iam::123456789012:role/Developer
+tags = env=dev, team=platform
+
+[profile prod]
+role_arn = arn:aws:iam::123456789012:role/Admin
+source_profile = legacy
+region = us-west-2
+duration_seconds = 7200
+aliases = p
+tags = env=prod, team=platform
+
+[profile legacy]
+mfa_serial = arn:aws:iam::123456789012:mfa/user
+tags = env=legacy, deprecated
+
+[profile sso]
+sso_start_url = https://sso.local/start
+
+[profile untagged]
+region = us-east-1
+`)
@@ -14,12 +14,22 @@
 package cli
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
 	"slices"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/identity"
 	"github.com/urfave/cli/v2"
 )
 
@@ -27,6 +37,7 @@ var rolesCmd = &cli.Command{
 	Name:      "roles",
 	Usage:     rolesFlag.Usage,
 	ArgsUsage: "[profile_name]",
+	Flags:     []cli.Flag{rolesAccountFlag, rolesGroupFlag, rolesFmtFlag},
 
 	BashComplete: bashCompleteProfile,
 
@@ -44,6 +55,11 @@ var rolesCmd = &cli.Command{
 			return errors.New("detected Web Identity profile, only IAM and SAML profiles support role enumeration")
 		}
 
+		format := strings.ToLower(ctx.String(rolesFmtFlag.Name))
+		if !slices.Contains([]string{rolesFmtList, rolesFmtTable, rolesFmtJson}, format) {
+			return fmt.Errorf("invalid output format: %s", format)
+		}
+
 		c, err := clientFactory.Get(cfg)
 		if err != nil {
 			return err
@@ -60,17 +76,192 @@ var rolesCmd = &cli.Command{
 			return err
 		}
 
-		slices.Sort(*roles)
+		filters := ctx.StringSlice(rolesAccountFlag.Name)
+		group := ctx.Bool(rolesGroupFlag.Name)
 
-		fmt.Printf("Available role ARNs for %s\n", id.Username)
-		for _, r := range *roles {
-			// filter out wildcards roles, since they can't be used in config files
-			if strings.Contains(r, "*") {
-				continue
-			}
-			fmt.Println("  " + r)
+		// looking up aliases requires a round-trip to AWS, only do it if we're going to use them
+		aliases := accountAliases(c, cfg, group || format != rolesFmtList || !accountIdFilters(filters))
+
+		accounts := filterAccounts(*roles, aliases, filters)
+		if len(filters) > 0 && len(accounts) < 1 {
+			return fmt.Errorf("no roles found for accounts: %s", strings.Join(filters, ", "))
+		}
+
+		switch format {
+		case rolesFmtJson:
+			return printRolesJson(os.Stdout, accounts, aliases)
+		case rolesFmtTable:
+			return printRolesTable(os.Stdout, accounts, aliases)
 		}
 
+		fmt.Printf("Available role ARNs for %s\n", id.Username)
+		printRoles(os.Stdout, accounts, aliases, group)
 		return nil
 	},
 }
+
+// Supported values for the roles command output format.
+const (
+	rolesFmtList  = "list"
+	rolesFmtTable = "table"
+	rolesFmtJson  = "json"
+)
+
+var rolesAccountFlag = &cli.StringSliceFlag{
+	Name:    "account",
+	Aliases: []string{"A"},
+	Usage:   "only list roles for the AWS account ID or alias (SAML only), may be repeated",
+}
+
+var rolesGroupFlag = &cli.BoolFlag{
+	Name:    "group",
+	Aliases: []string{"g"},
+	Usage:   "group the roles by AWS account, showing account aliases for SAML profiles",
+}
+
+var rolesFmtFlag = &cli.StringFlag{
+	Name:    "output",
+	Aliases: []string{"O"},
+	Usage:   "output format, valid values: list, table or json (table and json are grouped by AWS account)",
+	Value:   rolesFmtList,
+}
+
+// accountAliases returns the account aliases from the account map file in the configuration, if set.  If saml is true,
+// the aliases for the roles in the SAML assertion of the client are also returned, with the account map file taking
+// priority.  Problems reading the account map file are not fatal, since the aliases are informational.
+func accountAliases(c client.AwsClient, cfg *config.AwsConfig, saml bool) map[string]string {
+	aliases := make(map[string]string)
+	if saml {
+		aliases = samlAccountAliases(c)
+	}
+
+	if len(cfg.AccountMapFile) > 0 {
+		m, err := config.LoadAccountMap(cfg.AccountMapFile)
+		if err != nil {
+			log.Warningf("unable to load account map file: %v", err)
+			return aliases
+		}
+		maps.Copy(aliases, m)
+	}
+	return aliases
+}
+
+// samlAccountAliases returns the account aliases for the roles in the SAML assertion of the client.  An empty map is
+// returned for non-SAML clients, or if the aliases can not be found.
+func samlAccountAliases(c client.AwsClient) map[string]string {
+	aliases := make(map[string]string)
+
+	if sc, ok := c.(client.SamlAssertionClient); ok {
+		if saml, err := sc.SamlAssertion(); err == nil {
+			if a, err := clientFactory.AccountAliases(context.Background(), saml); err == nil {
+				aliases = a
+			} else {
+				log.Debugf("error looking up account aliases: %v", err)
+			}
+		}
+	}
+	return aliases
+}
+
+// accountIdFilters returns true if all filters are AWS account IDs, meaning account aliases are not needed to filter.
+func accountIdFilters(filters []string) bool {
+	for _, f := range filters {
+		if !accountIdRe.MatchString(f) {
+			return false
+		}
+	}
+	return true
+}
+
+var accountIdRe = regexp.MustCompile(`^\d{12}$`)
+
+// filterAccounts groups the roles by account, keeping only the accounts whose ID or alias matches one of the filters.
+// All accounts are kept if there are no filters.  Wildcard roles are removed, since they can't be used in profiles.
+func filterAccounts(roles identity.Roles, aliases map[string]string, filters []string) map[string]identity.Roles {
+	accounts := make(map[string]identity.Roles)
+
+	for k, v := range roles.ByAccount() {
+		if len(filters) > 0 && !slices.ContainsFunc(filters, func(f string) bool {
+			return f == k || (len(aliases[k]) > 0 && strings.EqualFold(f, aliases[k]))
+		}) {
+			continue
+		}
+
+		v = slices.DeleteFunc(v, func(r string) bool { return strings.Contains(r, "*") })
+		if len(v) > 0 {
+			accounts[k] = v
+		}
+	}
+	return accounts
+}
+
+func printRoles(w io.Writer, accounts map[string]identity.Roles, aliases map[string]string, group bool) {
+	ids := slices.Sorted(maps.Keys(accounts))
+
+	if !group {
+		roles := make([]string, 0)
+		for _, id := range ids {
+			roles = append(roles, accounts[id]...)
+		}
+		slices.Sort(roles)
+
+		for _, r := range roles {
+			_, _ = fmt.Fprintln(w, "  "+r)
+		}
+		return
+	}
+
+	for _, id := range ids {
+		name := id
+		if a, ok := aliases[id]; ok {
+			name = fmt.Sprintf("%s (%s)", a, id)
+		}
+
+		_, _ = fmt.Fprintf(w, "  Account: %s\n", name)
+		for _, r := range accounts[id] {
+			_, _ = fmt.Fprintln(w, "    "+r)
+		}
+	}
+}
+
+// roleAccount is the roles for a single AWS account in the table and json output formats.
+type roleAccount struct {
+	AccountId string         `json:"account_id"`
+	Alias     string         `json:"alias,omitempty"`
+	Roles     identity.Roles `json:"roles"`
+}
+
+func roleAccounts(accounts map[string]identity.Roles, aliases map[string]string) []*roleAccount {
+	out := make([]*roleAccount, 0, len(accounts))
+	for _, id := range slices.Sorted(maps.Keys(accounts)) {
+		roles := slices.Clone(accounts[id])
+		slices.Sort(roles)
+		out = append(out, &roleAccount{AccountId: id, Alias: aliases[id], Roles: roles})
+	}
+	return out
+}
+
+func printRolesJson(w io.Writer, accounts map[string]identity.Roles, aliases map[string]string) error {
+	out, err := json.MarshalIndent(roleAccounts(accounts, aliases), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+func printRolesTable(w io.Writer, accounts map[string]identity.Roles, aliases map[string]string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ACCOUNT\tALIAS\tROLE")
+	for _, a := range roleAccounts(accounts, aliases) {
+		alias := a.Alias
+		if len(alias) < 1 {
+			alias = "-"
+		}
+
+		for _, r := range a.Roles {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", a.AccountId, alias, r)
+		}
+	}
+	return tw.Flush()
+}
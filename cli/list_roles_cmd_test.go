@@ -14,9 +14,14 @@
 package cli
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/identity"
 	"github.com/urfave/cli/v2"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -42,3 +47,148 @@ func TestListRolesCmd_Action(t *testing.T) {
 		}
 	})
 }
+
+func TestFilterAccounts(t *testing.T) {
+	roles := identity.Roles{
+		"arn:aws:iam::123456789012:role/Admin",
+		"arn:aws:iam::123456789012:role/ReadOnly",
+		"arn:aws:iam::210987654321:role/Dev",
+		"arn:aws:iam::210987654321:role/*",
+	}
+	aliases := map[string]string{"210987654321": "dev-account"}
+
+	t.Run("no filter", func(t *testing.T) {
+		a := filterAccounts(roles, aliases, nil)
+		if len(a) != 2 || len(a["210987654321"]) != 1 {
+			t.Errorf("data mismatch: %v", a)
+		}
+	})
+
+	t.Run("account id", func(t *testing.T) {
+		a := filterAccounts(roles, aliases, []string{"123456789012"})
+		if len(a) != 1 || len(a["123456789012"]) != 2 {
+			t.Errorf("data mismatch: %v", a)
+		}
+	})
+
+	t.Run("alias", func(t *testing.T) {
+		a := filterAccounts(roles, aliases, []string{"DEV-Account"})
+		if len(a) != 1 || len(a["210987654321"]) != 1 {
+			t.Errorf("data mismatch: %v", a)
+		}
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		if a := filterAccounts(roles, aliases, []string{"prod"}); len(a) > 0 {
+			t.Errorf("data mismatch: %v", a)
+		}
+	})
+}
+
+func TestAccountIdFilters(t *testing.T) {
+	if !accountIdFilters([]string{"123456789012"}) || !accountIdFilters(nil) || accountIdFilters([]string{"123456789012", "prod"}) {
+		t.Error("data mismatch")
+	}
+}
+
+func TestPrintRoles(t *testing.T) {
+	accounts := map[string]identity.Roles{
+		"210987654321": {"arn:aws:iam::210987654321:role/Dev"},
+		"123456789012": {"arn:aws:iam::123456789012:role/Admin"},
+	}
+	aliases := map[string]string{"210987654321": "dev-account"}
+
+	t.Run("flat", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		printRoles(out, accounts, aliases, false)
+
+		expected := "  arn:aws:iam::123456789012:role/Admin\n  arn:aws:iam::210987654321:role/Dev\n"
+		if out.String() != expected {
+			t.Errorf("unexpected output: %s", out.String())
+		}
+	})
+
+	t.Run("grouped", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		printRoles(out, accounts, aliases, true)
+
+		expected := "  Account: 123456789012\n    arn:aws:iam::123456789012:role/Admin\n" +
+			"  Account: dev-account (210987654321)\n    arn:aws:iam::210987654321:role/Dev\n"
+		if out.String() != expected {
+			t.Errorf("unexpected output: %s", out.String())
+		}
+	})
+}
+
+func TestPrintRolesTable(t *testing.T) {
+	accounts := map[string]identity.Roles{
+		"210987654321": {"arn:aws:iam::210987654321:role/Dev"},
+		"123456789012": {"arn:aws:iam::123456789012:role/Admin"},
+	}
+	aliases := map[string]string{"210987654321": "dev-account"}
+
+	out := new(bytes.Buffer)
+	if err := printRolesTable(out, accounts, aliases); err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := "ACCOUNT       ALIAS        ROLE\n" +
+		"123456789012  -            arn:aws:iam::123456789012:role/Admin\n" +
+		"210987654321  dev-account  arn:aws:iam::210987654321:role/Dev\n"
+	if out.String() != expected {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestPrintRolesJson(t *testing.T) {
+	accounts := map[string]identity.Roles{
+		"210987654321": {"arn:aws:iam::210987654321:role/Dev", "arn:aws:iam::210987654321:role/Admin"},
+		"123456789012": {"arn:aws:iam::123456789012:role/Admin"},
+	}
+	aliases := map[string]string{"210987654321": "dev-account"}
+
+	out := new(bytes.Buffer)
+	if err := printRolesJson(out, accounts, aliases); err != nil {
+		t.Error(err)
+		return
+	}
+
+	var ra []*roleAccount
+	if err := json.Unmarshal(out.Bytes(), &ra); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(ra) != 2 || ra[0].AccountId != "123456789012" || len(ra[0].Alias) > 0 || ra[1].Alias != "dev-account" ||
+		ra[1].Roles[0] != "arn:aws:iam::210987654321:role/Admin" {
+		t.Errorf("unexpected output: %s", out.String())
+	}
+}
+
+func TestAccountAliases(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "accounts")
+	if err := os.WriteFile(f, []byte("123456789012 = prod\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("map file", func(t *testing.T) {
+		a := accountAliases(new(mockAwsClient), &config.AwsConfig{AccountMapFile: f}, true)
+		if len(a) != 1 || a["123456789012"] != "prod" {
+			t.Errorf("unexpected aliases: %v", a)
+		}
+	})
+
+	t.Run("no map file", func(t *testing.T) {
+		if a := accountAliases(new(mockAwsClient), new(config.AwsConfig), true); len(a) > 0 {
+			t.Errorf("unexpected aliases: %v", a)
+		}
+	})
+
+	t.Run("bad map file", func(t *testing.T) {
+		a := accountAliases(new(mockAwsClient), &config.AwsConfig{AccountMapFile: filepath.Join(t.TempDir(), "x")}, false)
+		if len(a) > 0 {
+			t.Errorf("unexpected aliases: %v", a)
+		}
+	})
+}
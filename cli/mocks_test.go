@@ -21,6 +21,7 @@ import (
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"github.com/mmmorris1975/aws-runas/identity"
 	"strings"
+	"time"
 )
 
 type mockAwsClient bool
@@ -39,6 +40,14 @@ func (c *mockAwsClient) Roles() (*identity.Roles, error) {
 	return new(identity.Roles), nil
 }
 
+func (c *mockAwsClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return c.Identity()
+}
+
+func (c *mockAwsClient) RolesWithContext(context.Context) (*identity.Roles, error) {
+	return c.Roles()
+}
+
 func (c *mockAwsClient) Credentials() (*credentials.Credentials, error) {
 	return c.CredentialsWithContext(context.Background())
 }
@@ -61,6 +70,17 @@ func (c *mockAwsClient) ClearCache() error {
 	return nil
 }
 
+func (c *mockAwsClient) ExpiresAt() time.Time {
+	return time.Time{}
+}
+
+func (c *mockAwsClient) Refresh(ctx context.Context) (*credentials.Credentials, error) {
+	if err := c.ClearCache(); err != nil {
+		return nil, err
+	}
+	return c.CredentialsWithContext(ctx)
+}
+
 type mockConfigResolver bool
 
 func (m *mockConfigResolver) Config(profile string) (*config.AwsConfig, error) {
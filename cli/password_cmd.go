@@ -75,22 +75,31 @@ func validateInput(cfg *config.AwsConfig) (string, error) {
 }
 
 func updateCreds(cfg *config.AwsConfig, password string) error {
-	url := cfg.SamlUrl
+	// passwords are stored for the primary endpoint, if there's a list of identity provider URLs
+	urls := cfg.SamlUrls()
 	if len(cfg.WebIdentityUrl) > 0 {
-		url = cfg.WebIdentityUrl
+		urls = cfg.WebIdentityUrls()
 	}
 
-	crypt, err := helpers.NewPasswordEncoder([]byte(url)).Encode(password, 18)
+	if len(urls) < 1 {
+		return errors.New("invalid identity provider url")
+	}
+	url := urls[0]
+
+	enc := helpers.NewPasswordEncoder([]byte(url))
+	crypt, err := enc.Encode(password, 18)
 	if err != nil {
 		return err
 	}
 
-	creds := new(config.AwsCredentials)
+	if crypt, err = enc.Protect(crypt); err != nil {
+		return err
+	}
+
+	key := "saml_password"
 	if len(cfg.WebIdentityUrl) > 0 {
-		creds.WebIdentityPassword = crypt
-	} else {
-		creds.SamlPassword = crypt
+		key = "web_identity_password"
 	}
 
-	return config.DefaultIniLoader.SaveCredentials(url, creds)
+	return configWriter.SetCredentials(url, map[string]string{key: crypt})
 }
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/mmmorris1975/aws-runas/identity"
+)
+
+// rolePolicyApi is a stub interface used for mocking the IAM API calls which describe the policies of a role.
+type rolePolicyApi interface {
+	iam.ListAttachedRolePoliciesAPIClient
+	GetRole(ctx context.Context, params *iam.GetRoleInput, optFns ...func(*iam.Options)) (*iam.GetRoleOutput, error)
+}
+
+// printRolePolicies logs the managed policies attached to the role the credentials belong to, and its permissions
+// boundary, so the user can confirm they are using the expected role.  The credentials need permission to call
+// iam:ListAttachedRolePolicies and iam:GetRole, which many roles do not have, so failures calling IAM are logged as a
+// warning instead of returning an error.
+func printRolePolicies(stsApi identity.StsApi, iamApi rolePolicyApi) error {
+	id, err := stsApi.GetCallerIdentity(context.Background(), new(sts.GetCallerIdentityInput))
+	if err != nil {
+		return err
+	}
+
+	role := assumedRoleName(aws.ToString(id.Arn))
+	if len(role) < 1 {
+		log.Infof("credentials for %s do not belong to a role, no policies to show", aws.ToString(id.Arn))
+		return nil
+	}
+
+	policies := make([]string, 0)
+	pg := iam.NewListAttachedRolePoliciesPaginator(iamApi, &iam.ListAttachedRolePoliciesInput{RoleName: aws.String(role)})
+	for pg.HasMorePages() {
+		page, err := pg.NextPage(context.Background())
+		if err != nil {
+			log.Warningf("unable to list the policies attached to role %s: %v", role, err)
+			return nil
+		}
+
+		for _, p := range page.AttachedPolicies {
+			policies = append(policies, aws.ToString(p.PolicyArn))
+		}
+	}
+
+	log.Infof("Attached policies for role %s:", role)
+	for _, p := range policies {
+		log.Infof("  %s", p)
+	}
+
+	out, err := iamApi.GetRole(context.Background(), &iam.GetRoleInput{RoleName: aws.String(role)})
+	switch {
+	case err != nil:
+		log.Warningf("unable to get the permissions boundary of role %s: %v", role, err)
+	case out.Role != nil && out.Role.PermissionsBoundary != nil:
+		log.Infof("Permissions boundary: %s", aws.ToString(out.Role.PermissionsBoundary.PermissionsBoundaryArn))
+	default:
+		log.Infof("Permissions boundary: none")
+	}
+	return nil
+}
+
+// assumedRoleName returns the name of the role from an STS assumed-role ARN, or an empty string if the ARN is not for
+// an assumed role.
+func assumedRoleName(a string) string {
+	parsed, err := arn.Parse(a)
+	if err != nil || parsed.Service != "sts" {
+		return ""
+	}
+
+	parts := strings.Split(parsed.Resource, "/")
+	if len(parts) < 3 || parts[0] != "assumed-role" {
+		return ""
+	}
+	return parts[1]
+}
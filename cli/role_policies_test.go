@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+func TestPrintRolePolicies(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		api := &mockRolePolicyApi{}
+		if err := printRolePolicies(mockAssumedRoleStsApi{}, api); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if api.role != "Admin" {
+			t.Errorf("unexpected role name: %s", api.role)
+		}
+	})
+
+	t.Run("not a role", func(t *testing.T) {
+		api := &mockRolePolicyApi{}
+		if err := printRolePolicies(new(mockStsApi), api); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(api.role) > 0 {
+			t.Error("unexpected IAM call")
+		}
+	})
+
+	t.Run("access denied", func(t *testing.T) {
+		if err := printRolePolicies(mockAssumedRoleStsApi{}, &mockRolePolicyApi{fail: true}); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("sts error", func(t *testing.T) {
+		var api mockStsApi = true
+		if err := printRolePolicies(&api, new(mockRolePolicyApi)); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestAssumedRoleName(t *testing.T) {
+	tests := map[string]string{
+		"arn:aws:sts::123456789012:assumed-role/Admin/session": "Admin",
+		"arn:aws:iam::123456789012:user/Mock":                  "",
+		"arn:aws:sts::123456789012:federated-user/Mock":        "",
+		"not an arn": "",
+	}
+
+	for k, v := range tests {
+		if r := assumedRoleName(k); r != v {
+			t.Errorf("unexpected role name for %s: %s", k, r)
+		}
+	}
+}
+
+type mockAssumedRoleStsApi struct{}
+
+func (m mockAssumedRoleStsApi) GetCallerIdentity(context.Context, *sts.GetCallerIdentityInput, ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{
+		Account: aws.String("123456789012"),
+		Arn:     aws.String("arn:aws:sts::123456789012:assumed-role/Admin/session"),
+		UserId:  aws.String("AROAMOCK:session"),
+	}, nil
+}
+
+type mockRolePolicyApi struct {
+	fail bool
+	role string
+}
+
+func (m *mockRolePolicyApi) ListAttachedRolePolicies(_ context.Context, in *iam.ListAttachedRolePoliciesInput, _ ...func(*iam.Options)) (*iam.ListAttachedRolePoliciesOutput, error) {
+	m.role = aws.ToString(in.RoleName)
+	if m.fail {
+		return nil, errors.New("AccessDenied")
+	}
+
+	return &iam.ListAttachedRolePoliciesOutput{
+		AttachedPolicies: []types.AttachedPolicy{
+			{PolicyArn: aws.String("arn:aws:iam::aws:policy/AdministratorAccess"), PolicyName: aws.String("AdministratorAccess")},
+		},
+	}, nil
+}
+
+func (m *mockRolePolicyApi) GetRole(_ context.Context, in *iam.GetRoleInput, _ ...func(*iam.Options)) (*iam.GetRoleOutput, error) {
+	return &iam.GetRoleOutput{
+		Role: &types.Role{
+			RoleName: in.RoleName,
+			PermissionsBoundary: &types.AttachedPermissionsBoundary{
+				PermissionsBoundaryArn: aws.String("arn:aws:iam::123456789012:policy/Boundary"),
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+// expiredMarkers are the messages reported by the AWS SDKs (and tools built with them, like terraform) when a request
+// is made using expired credentials.
+var expiredMarkers = [][]byte{
+	[]byte("ExpiredToken"),
+	[]byte("TokenRefreshRequired"),
+	[]byte("security token included in the request is expired"),
+}
+
+// runCmd executes the wrapped command.  If retries is greater than 0, the stderr output of the command is inspected for
+// expired credential errors, and if the command fails with one, the credentials are refreshed (prompting for MFA or
+// re-authenticating, if necessary) and the whole command is run again from the start, up to 'retries' times.
+//
+// Inspecting stderr means the command is no longer connected to the terminal, which breaks interactive programs (and
+// ones which change their output when attached to a terminal), so stderr is only inspected when it is not a terminal.
+func runCmd(cmd []string, c client.AwsClient, cfg *config.AwsConfig, useEnv bool, retries int) error {
+	if retries > 0 && stderrIsTerminal() {
+		log.Warningf("stderr is a terminal, expired credential errors can not be detected and will not be retried")
+		retries = 0
+	}
+
+	for attempt := 1; ; attempt++ {
+		wrapped := wrapCmd(cmd)
+		ew := &expiredWriter{w: os.Stderr}
+
+		p := exec.Command(wrapped[0], wrapped[1:]...) //nolint:gosec // it's sort of the whole reason this tool exists
+		p.Stdin = os.Stdin
+		p.Stdout = os.Stdout
+		p.Stderr = os.Stderr
+
+		if retries > 0 {
+			p.Stderr = ew
+		}
+
+		err := p.Run()
+		if err == nil || !ew.expired || attempt > retries {
+			return err
+		}
+
+		log.Warningf("command failed with expired credentials, refreshing and retrying (%d of %d)", attempt, retries)
+		refreshCreds(c)
+
+		creds, err := c.Credentials()
+		if err != nil {
+			return err
+		}
+
+		// with the credential endpoint, the program will pick up the new credentials on its own
+		if useEnv {
+			for k, v := range buildEnv(cfg.Region, creds) {
+				_ = os.Setenv(k, v)
+			}
+		}
+	}
+}
+
+// expiredWriter passes all data through to the underlying writer, and records if any expired credential errors were
+// found in the data.
+type expiredWriter struct {
+	w       io.Writer
+	tail    []byte
+	expired bool
+}
+
+// Write implements the io.Writer interface.
+func (e *expiredWriter) Write(p []byte) (int, error) {
+	if !e.expired {
+		// include the end of the previous write, in case a message was split across writes
+		buf := append(e.tail, p...)
+
+		for _, m := range expiredMarkers {
+			if bytes.Contains(buf, m) {
+				e.expired = true
+				break
+			}
+		}
+
+		if len(buf) > 64 {
+			buf = buf[len(buf)-64:]
+		}
+		e.tail = append([]byte{}, buf...)
+	}
+	return e.w.Write(p)
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+func TestRunCmd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires a posix shell")
+	}
+
+	isTerm := stderrIsTerminal
+	defer func() { stderrIsTerminal = isTerm }()
+	stderrIsTerminal = func() bool { return false }
+
+	count := func(t *testing.T, cmd string, retries int) int {
+		f := filepath.Join(t.TempDir(), "runs")
+		_ = runCmd([]string{"sh", "-c", "echo run >> " + f + "; " + cmd}, new(mockAwsClient), new(config.AwsConfig), false, retries)
+
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bytes.Count(data, []byte("run"))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		if n := count(t, "echo ExpiredToken >&2", 2); n != 1 {
+			t.Errorf("command ran %d times", n)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		if n := count(t, "echo 'ExpiredToken: token expired' >&2; exit 1", 2); n != 3 {
+			t.Errorf("command ran %d times", n)
+		}
+	})
+
+	t.Run("other error", func(t *testing.T) {
+		if n := count(t, "echo 'AccessDenied' >&2; exit 1", 2); n != 1 {
+			t.Errorf("command ran %d times", n)
+		}
+	})
+
+	t.Run("retry disabled", func(t *testing.T) {
+		if n := count(t, "echo 'ExpiredToken' >&2; exit 1", 0); n != 1 {
+			t.Errorf("command ran %d times", n)
+		}
+	})
+
+	t.Run("whole command re-run", func(t *testing.T) {
+		// every step of the command runs again on retry, not just the one which failed
+		f := filepath.Join(t.TempDir(), "steps")
+		cmd := "echo first >> " + f + "; echo second >> " + f + "; echo 'ExpiredToken' >&2; exit 1"
+		_ = runCmd([]string{"sh", "-c", cmd}, new(mockAwsClient), new(config.AwsConfig), false, 1)
+
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != "first\nsecond\nfirst\nsecond\n" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("stderr terminal", func(t *testing.T) {
+		stderrIsTerminal = func() bool { return true }
+		defer func() { stderrIsTerminal = func() bool { return false } }()
+
+		if n := count(t, "echo 'ExpiredToken' >&2; exit 1", 2); n != 1 {
+			t.Errorf("command ran %d times", n)
+		}
+	})
+}
+
+func TestExpiredWriter_Write(t *testing.T) {
+	t.Run("split message", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := &expiredWriter{w: out}
+
+		_, _ = w.Write([]byte("error: Expired"))
+		_, _ = w.Write([]byte("Token: the token has expired\n"))
+
+		if !w.expired {
+			t.Error("expired credential message not detected")
+		}
+
+		if out.String() != "error: ExpiredToken: the token has expired\n" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("not expired", func(t *testing.T) {
+		w := &expiredWriter{w: new(bytes.Buffer)}
+		_, _ = w.Write([]byte("AccessDenied"))
+
+		if w.expired {
+			t.Error("unexpected expired credential detection")
+		}
+	})
+}
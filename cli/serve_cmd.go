@@ -20,7 +20,7 @@ var serveCmd = &cli.Command{
 	Aliases:     []string{"srv"},
 	Usage:       "Serve credentials from a listening HTTP service",
 	ArgsUsage:   " ", // this hides the default '[arguments...]' help text output, since we don't use command args here
-	Subcommands: []*cli.Command{ec2Cmd, ecsCmd},
+	Subcommands: []*cli.Command{ec2Cmd, ecsCmd, sidecarCmd, wslCmd},
 }
 
 var headlessFlag = &cli.BoolFlag{
@@ -30,3 +30,15 @@ var headlessFlag = &cli.BoolFlag{
 	Value:       false,
 	DefaultText: "",
 }
+
+var webhookUrlFlag = &cli.StringFlag{
+	Name:    "webhook-url",
+	Usage:   "Send credential and authentication events to this https `url`",
+	EnvVars: []string{"RUNAS_WEBHOOK_URL"},
+}
+
+var webhookSecretFlag = &cli.StringFlag{
+	Name:    "webhook-secret",
+	Usage:   "Sign webhook requests with an HMAC-SHA256 of the body, using this `secret` as the key",
+	EnvVars: []string{"RUNAS_WEBHOOK_SECRET"},
+}
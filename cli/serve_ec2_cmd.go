@@ -17,8 +17,10 @@ import (
 	"fmt"
 	"github.com/mmmorris1975/aws-runas/metadata"
 	"github.com/urfave/cli/v2"
+	"net"
 	"net/url"
 	"os"
+	"strconv"
 )
 
 var ec2CmdDesc = `Start a local web server which mimics the credential retrieval abilities of the EC2 instance
@@ -30,7 +32,12 @@ IMDS address and port.  Using a port number of 0 will have the service listen on
 
 If you are not using the default IMDS address and port, you will want to set the
 AWS_EC2_METADATA_SERVICE_ENDPOINT environment variable to this service's address and port so
-calling programs know the location of the endpoint.`
+calling programs know the location of the endpoint.
+
+Alternatively, the --setup-network flag (run once with administrator/root authority) configures
+the system to redirect requests for the default IMDS address to the port given with the --port
+flag, so the service can run unprivileged on that port while programs use the default endpoint.
+The --remove-network flag removes that configuration.`
 
 var ec2Cmd = &cli.Command{
 	Name:         "ec2",
@@ -39,40 +46,43 @@ var ec2Cmd = &cli.Command{
 	Description:  ec2CmdDesc,
 	BashComplete: bashCompleteProfile,
 
-	Flags: []cli.Flag{ec2PortFlag, headlessFlag},
+	Flags: []cli.Flag{ec2PortFlag, ec2BindFlag, ec2SetupNetFlag, ec2RemoveNetFlag, ec2NoIdentitySigFlag, headlessFlag,
+		webhookUrlFlag, webhookSecretFlag},
 
 	Action: func(ctx *cli.Context) error {
-		profile, _, err := resolveConfig(ctx, 0)
+		if ctx.Bool(ec2SetupNetFlag.Name) {
+			return metadata.SetupImdsRedirect(ctx.Int(ec2PortFlag.Name))
+		}
+
+		if ctx.Bool(ec2RemoveNetFlag.Name) {
+			return metadata.RemoveImdsRedirect(ctx.Int(ec2PortFlag.Name))
+		}
+
+		profile, cfg, err := resolveConfig(ctx, 0)
 		if err != nil {
 			return err
 		}
 
-		var addr string
-
-		// env var must specify the protocol, so we should parse as a URL
-		if env, ok := os.LookupEnv("AWS_EC2_METADATA_SERVICE_ENDPOINT"); ok {
-			log.Debugf("found EC2 IMDS env var")
-			var u *url.URL
-			if u, err = url.Parse(env); err == nil {
-				addr = u.Host
-			}
+		if err = verifyServeCredentials(ctx, profile, cfg); err != nil {
+			return err
 		}
 
-		if len(addr) < 1 {
-			port := ctx.Int(ec2PortFlag.Name)
-			if port < 0 {
-				addr = fmt.Sprintf("%s:80", metadata.DefaultEc2ImdsAddr)
-			} else {
-				addr = fmt.Sprintf("127.0.0.1:%d", port)
-			}
+		addr, err := ec2ListenAddr(ctx.String(ec2BindFlag.Name), ctx.Int(ec2PortFlag.Name))
+		if err != nil {
+			return err
 		}
 		log.Debugf("setting EC2 IMDS endpoint host to: %s", addr)
 
 		in := &metadata.Options{
-			Profile:     profile,
-			Logger:      log,
-			AwsLogLevel: opts.AwsLogLevel,
-			Headless:    ctx.Bool(headlessFlag.Name),
+			Profile:       profile,
+			Logger:        opts.Logger,
+			AwsLogLevel:   opts.AwsLogLevel,
+			Headless:      ctx.Bool(headlessFlag.Name),
+			WebhookUrl:    ctx.String(webhookUrlFlag.Name),
+			WebhookSecret: ctx.String(webhookSecretFlag.Name),
+			SystemdNotify: true,
+
+			NoIdentitySignature: ctx.Bool(ec2NoIdentitySigFlag.Name),
 		}
 
 		mcs, err := metadata.NewMetadataCredentialService(addr, in)
@@ -83,6 +93,61 @@ var ec2Cmd = &cli.Command{
 	},
 }
 
+// ec2ListenAddr determines the address for the EC2 metadata service.  An address set with the bind flag has priority,
+// followed by the AWS_EC2_METADATA_SERVICE_ENDPOINT env var, then the port flag.
+func ec2ListenAddr(bind string, port int) (string, error) {
+	if len(bind) > 0 {
+		if _, _, err := net.SplitHostPort(bind); err == nil {
+			return bind, nil
+		}
+
+		if net.ParseIP(bind) == nil {
+			return "", fmt.Errorf("invalid bind address: %s", bind)
+		}
+
+		if port < 0 {
+			port = 80
+		}
+		return net.JoinHostPort(bind, strconv.Itoa(port)), nil
+	}
+
+	// env var must specify the protocol, so we should parse as a URL
+	if env, ok := os.LookupEnv("AWS_EC2_METADATA_SERVICE_ENDPOINT"); ok {
+		log.Debugf("found EC2 IMDS env var")
+		if u, err := url.Parse(env); err == nil && len(u.Host) > 0 {
+			return u.Host, nil
+		}
+	}
+
+	if port < 0 {
+		return fmt.Sprintf("%s:80", metadata.DefaultEc2ImdsAddr), nil
+	}
+	return fmt.Sprintf("127.0.0.1:%d", port), nil
+}
+
+var ec2BindFlag = &cli.StringFlag{
+	Name:    "bind",
+	Aliases: []string{"b"},
+	Usage:   "Address (ip or ip:port) for the EC2 credential service to listen on, the port flag is used if no port is set",
+	EnvVars: []string{"RUNAS_EC2_BIND_ADDRESS"},
+}
+
+var ec2SetupNetFlag = &cli.BoolFlag{
+	Name:  "setup-network",
+	Usage: "Redirect requests for the default IMDS address to the --port value, then exit (requires root/admin)",
+}
+
+var ec2RemoveNetFlag = &cli.BoolFlag{
+	Name:  "remove-network",
+	Usage: "Remove the configuration done by --setup-network for the --port value, then exit (requires root/admin)",
+}
+
+var ec2NoIdentitySigFlag = &cli.BoolFlag{
+	Name:    "no-identity-signature",
+	Usage:   "Do not serve the (fake) signatures of the instance identity document, only the document itself",
+	EnvVars: []string{"RUNAS_EC2_NO_IDENTITY_SIGNATURE"},
+}
+
 var ec2PortFlag = &cli.IntFlag{
 	Name:        "port",
 	Aliases:     []string{"p"},
@@ -54,3 +54,41 @@ func TestServeEC2Cmd_Action(t *testing.T) {
 		}
 	})
 }
+
+func TestEc2ListenAddr(t *testing.T) {
+	tests := []struct {
+		name, bind, env, expected string
+		port                      int
+	}{
+		{name: "default", port: -1, expected: "169.254.169.254:80"},
+		{name: "port", port: 8000, expected: "127.0.0.1:8000"},
+		{name: "env var", port: 8000, env: "http://127.0.0.1:4321/", expected: "127.0.0.1:4321"},
+		{name: "bind with port", bind: "0.0.0.0:9000", port: 8000, env: "http://127.0.0.1:4321/", expected: "0.0.0.0:9000"},
+		{name: "bind host", bind: "192.168.1.10", port: 8000, expected: "192.168.1.10:8000"},
+		{name: "bind host default port", bind: "::1", port: -1, expected: "[::1]:80"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", tc.env)
+			if len(tc.env) < 1 {
+				_ = os.Unsetenv("AWS_EC2_METADATA_SERVICE_ENDPOINT")
+			}
+
+			addr, err := ec2ListenAddr(tc.bind, tc.port)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if addr != tc.expected {
+				t.Errorf("unexpected address: %s", addr)
+			}
+		})
+	}
+
+	t.Run("invalid bind", func(t *testing.T) {
+		if _, err := ec2ListenAddr("not an address", 0); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
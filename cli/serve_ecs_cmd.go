@@ -14,6 +14,7 @@
 package cli
 
 import (
+	"fmt"
 	"github.com/mmmorris1975/aws-runas/metadata"
 	"github.com/urfave/cli/v2"
 	"net"
@@ -29,7 +30,16 @@ credentials from AWS when it is not practical using the traditional 'wrapper' mo
 Omitting the port parameter will cause the service to listen on a random port.
 
 You will need to set the AWS_CONTAINER_CREDENTIALS_FULL_URI environment variable to this
-service's address and port so calling programs know the location of the endpoint.`
+service's address and port so calling programs know the location of the endpoint.
+
+On Windows, the --pipe flag serves the endpoint on a named pipe instead of a TCP port.  Only the
+user running the service is allowed to connect to the pipe.
+
+The --socket flag serves the endpoint on a unix domain socket instead of a TCP port.  Only the
+user running the service is allowed to connect to the socket, unless the --multi-user flag is set.
+In multi-user mode (Linux only), any local user may connect to the socket, and each user is
+identified by the peer credentials of their connection.  Every user selects their own profile and
+has a private credential cache, so a single service can be shared by several users of a host.`
 
 var ecsCmd = &cli.Command{
 	Name:         "ecs",
@@ -38,14 +48,18 @@ var ecsCmd = &cli.Command{
 	Description:  ecsCmdDesc,
 	BashComplete: bashCompleteProfile,
 
-	Flags: []cli.Flag{ecsPortFlag, headlessFlag},
+	Flags: []cli.Flag{ecsPortFlag, ecsPipeFlag, ecsSocketFlag, multiUserFlag, headlessFlag, webhookUrlFlag, webhookSecretFlag},
 
 	Action: func(ctx *cli.Context) error {
-		profile, _, err := resolveConfig(ctx, 0)
+		profile, cfg, err := resolveConfig(ctx, 0)
 		if err != nil {
 			return err
 		}
 
+		if err = verifyServeCredentials(ctx, profile, cfg); err != nil {
+			return err
+		}
+
 		var addr string
 		path := metadata.DefaultEcsCredPath
 
@@ -62,17 +76,33 @@ var ecsCmd = &cli.Command{
 			}
 		}
 
+		if pipe := ctx.String(ecsPipeFlag.Name); len(pipe) > 0 {
+			addr = pipeAddr(pipe)
+		}
+
+		if sock := ctx.String(ecsSocketFlag.Name); len(sock) > 0 {
+			addr = metadata.UnixPrefix + sock
+		}
+
+		if ctx.Bool(multiUserFlag.Name) && !metadata.IsUnixAddr(addr) {
+			return fmt.Errorf("the --%s flag requires the --%s flag", multiUserFlag.Name, ecsSocketFlag.Name)
+		}
+
 		if len(addr) < 1 {
 			addr = net.JoinHostPort("127.0.0.1", strconv.Itoa(int(ctx.Uint(ecsPortFlag.Name))))
 		}
 		log.Debugf("setting ECS credential endpoint HOST=%s, PATH=%s", addr, path)
 
 		in := &metadata.Options{
-			Path:        path,
-			Profile:     profile,
-			Logger:      log,
-			AwsLogLevel: opts.AwsLogLevel,
-			Headless:    ctx.Bool(headlessFlag.Name),
+			Path:          path,
+			Profile:       profile,
+			Logger:        opts.Logger,
+			AwsLogLevel:   opts.AwsLogLevel,
+			Headless:      ctx.Bool(headlessFlag.Name),
+			WebhookUrl:    ctx.String(webhookUrlFlag.Name),
+			WebhookSecret: ctx.String(webhookSecretFlag.Name),
+			SystemdNotify: true,
+			MultiUser:     ctx.Bool(multiUserFlag.Name),
 		}
 
 		mcs, err := metadata.NewMetadataCredentialService(addr, in)
@@ -89,3 +119,26 @@ var ecsPortFlag = &cli.UintFlag{
 	Usage:   "The listening port for the ECS credential service",
 	Value:   12319, // A (1) W (23) S (19)
 }
+
+var ecsPipeFlag = &cli.StringFlag{
+	Name:  "pipe",
+	Usage: "The Windows named pipe for the ECS credential service, used instead of a TCP port",
+}
+
+var ecsSocketFlag = &cli.StringFlag{
+	Name:  "socket",
+	Usage: "The path of a unix domain socket for the ECS credential service, used instead of a TCP port",
+}
+
+var multiUserFlag = &cli.BoolFlag{
+	Name:  "multi-user",
+	Usage: "Allow all local users to connect to the --socket, isolating the profile and credentials of each user",
+}
+
+// pipeAddr returns the full path of the named pipe, the name may be given without the \\.\pipe\ prefix.
+func pipeAddr(name string) string {
+	if metadata.IsPipeAddr(name) {
+		return name
+	}
+	return metadata.PipePrefix + name
+}
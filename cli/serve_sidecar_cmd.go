@@ -0,0 +1,130 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mmmorris1975/aws-runas/metadata"
+	"github.com/urfave/cli/v2"
+	"net"
+)
+
+var sidecarCmdDesc = `Start a credential service suitable for running as a sidecar container, to provide role
+credentials to other containers in a docker-compose stack or kubernetes pod.  Only the ECS
+credential endpoint (and a health check) is served, and the service never prompts for input, so it
+can run without a terminal.
+
+Authentication must be possible without user interaction, for example using a mounted AWS config
+file and a cache directory pre-seeded with valid session, identity token, or cookie files (see the
+cache_dir configuration attribute, or the AWS_RUNAS_CACHE_DIR environment variable).  If the
+credentials can not be retrieved during startup the service exits with an error.
+
+The service listens on 127.0.0.1 by default, which is reachable by containers sharing the network
+namespace of the sidecar (a kubernetes pod, or docker-compose 'network_mode: service:...').  Use
+the --listen flag to make the service available to other containers on the network, and the
+--auth-token flag to require the token for requests to the ECS credential endpoint.`
+
+var sidecarCmd = &cli.Command{
+	Name:         "sidecar",
+	Usage:        "Run a non-interactive credential service for use as a sidecar container",
+	ArgsUsage:    "profile_name",
+	Description:  sidecarCmdDesc,
+	BashComplete: bashCompleteProfile,
+
+	Flags: []cli.Flag{sidecarListenFlag, sidecarTokenFlag, webhookUrlFlag, webhookSecretFlag},
+
+	Action: func(ctx *cli.Context) error {
+		profile, cfg, err := resolveConfig(ctx, 0)
+		if err != nil {
+			return err
+		}
+
+		if len(profile) < 1 {
+			return errors.New("a profile is required to run the sidecar service")
+		}
+
+		// there is nobody to answer a prompt, fail instead of waiting for input which will never come
+		opts.MfaInputProvider = func() (string, error) {
+			return "", metadata.ErrInputRequired
+		}
+
+		opts.CredentialInputProvider = func(_ string, _ string) (string, string, error) {
+			return "", "", metadata.ErrInputRequired
+		}
+
+		opts.PasswordChangeProvider = func() (string, error) {
+			return "", metadata.ErrInputRequired
+		}
+
+		opts.RoleSelectionProvider = func(_ []string, _ string) (string, error) {
+			return "", metadata.ErrInputRequired
+		}
+
+		c, err := clientFactory.Get(cfg)
+		if err != nil {
+			return err
+		}
+
+		if ctx.Bool(refreshFlag.Name) {
+			refreshCreds(c)
+		}
+
+		if _, err = c.Credentials(); err != nil {
+			return fmt.Errorf("unable to retrieve credentials for profile %s: %w", profile, err)
+		}
+
+		addr := ctx.String(sidecarListenFlag.Name)
+		if _, _, err = net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid listen address: %s", addr)
+		}
+
+		in := &metadata.Options{
+			Path:           metadata.DefaultEcsCredPath,
+			Profile:        profile,
+			Logger:         opts.Logger,
+			AwsLogLevel:    opts.AwsLogLevel,
+			AuthToken:      ctx.String(sidecarTokenFlag.Name),
+			NonInteractive: true,
+			EcsOnly:        true,
+			WebhookUrl:     ctx.String(webhookUrlFlag.Name),
+			WebhookSecret:  ctx.String(webhookSecretFlag.Name),
+			SystemdNotify:  true,
+		}
+
+		mcs, err := metadata.NewMetadataCredentialService(addr, in)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("ECS credential endpoint set to http://%s%s", mcs.Addr().String(), in.Path)
+		log.Infof("Serving credentials for profile '%s'", profile)
+		return mcs.RunNoApi(c, cfg, nil)
+	},
+}
+
+var sidecarListenFlag = &cli.StringFlag{
+	Name:    "listen",
+	Aliases: []string{"L"},
+	Usage:   "The address and port the sidecar credential service listens on",
+	EnvVars: []string{"RUNAS_SIDECAR_LISTEN"},
+	Value:   "127.0.0.1:12319",
+}
+
+var sidecarTokenFlag = &cli.StringFlag{
+	Name:    "auth-token",
+	Aliases: []string{"k"},
+	Usage:   "The authorization token required for requests to the ECS credential endpoint",
+	EnvVars: []string{"AWS_CONTAINER_AUTHORIZATION_TOKEN"},
+}
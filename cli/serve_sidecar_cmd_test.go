@@ -0,0 +1,33 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+func TestServeSidecarCmd_Action(t *testing.T) {
+	t.Run("no profile", func(t *testing.T) {
+		_ = os.Unsetenv("AWS_PROFILE")
+		_ = os.Unsetenv("AWS_DEFAULT_PROFILE")
+		cmdlineCreds = new(config.AwsCredentials)
+
+		if err := App.Run([]string{"mycmd", "serve", "sidecar"}); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/mmmorris1975/aws-runas/metadata"
+	"github.com/urfave/cli/v2"
+)
+
+// wslEnvFile is the location of the environment file written in each WSL distro, relative to the user's home directory.
+const wslEnvFile = ".aws-runas/wsl.env"
+
+var wslCmdDesc = `Start an ECS credential service on Windows which provides role credentials to programs running
+in WSL2 Linux distros, so authentication happens on Windows (using the browser, security keys, or
+Windows credential store) instead of inside each distro.
+
+The service listens on the loopback address, and requires a random authorization token, which is
+generated each time the service starts.  Before serving credentials, the endpoint URL and token are
+written to the ~/` + wslEnvFile + ` file in each distro named with the --distro flag (or the default distro),
+and the user's ~/.profile is updated to load the file in new login shells.  Use the --no-profile flag
+to leave ~/.profile unchanged, and load the file manually.
+
+WSL must use mirrored networking mode (networkingMode=mirrored in the .wslconfig file), so the
+loopback address of the distro reaches the service running on Windows.`
+
+var wslCmd = &cli.Command{
+	Name:         "wsl",
+	Usage:        "Run an ECS credential endpoint on Windows to provide role credentials to WSL distros",
+	ArgsUsage:    "[profile_name]",
+	Description:  wslCmdDesc,
+	BashComplete: bashCompleteProfile,
+
+	Flags: []cli.Flag{ecsPortFlag, wslDistroFlag, wslNoProfileFlag, headlessFlag, webhookUrlFlag, webhookSecretFlag},
+
+	Action: func(ctx *cli.Context) error {
+		if runtime.GOOS != "windows" {
+			return errors.New("the wsl credential service is only supported on Windows")
+		}
+
+		profile, cfg, err := resolveConfig(ctx, 0)
+		if err != nil {
+			return err
+		}
+
+		if err = verifyServeCredentials(ctx, profile, cfg); err != nil {
+			return err
+		}
+
+		port := ctx.Uint(ecsPortFlag.Name)
+		if port < 1 {
+			return errors.New("a fixed port is required for the wsl credential service")
+		}
+
+		token, err := newAuthToken()
+		if err != nil {
+			return err
+		}
+
+		addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port)))
+		env := wslEnv(fmt.Sprintf("http://%s%s", addr, metadata.DefaultEcsCredPath), token)
+
+		if !wslMirroredNetworking() {
+			log.Warningf("WSL does not appear to use mirrored networking, distros may not be able to reach the service")
+		}
+
+		distros := ctx.StringSlice(wslDistroFlag.Name)
+		if len(distros) < 1 {
+			distros = []string{""}
+		}
+
+		for _, d := range distros {
+			if err = wslConfigure(d, env, !ctx.Bool(wslNoProfileFlag.Name)); err != nil {
+				return err
+			}
+		}
+
+		in := &metadata.Options{
+			Path:          metadata.DefaultEcsCredPath,
+			Profile:       profile,
+			Logger:        opts.Logger,
+			AwsLogLevel:   opts.AwsLogLevel,
+			AuthToken:     token,
+			Headless:      ctx.Bool(headlessFlag.Name),
+			WebhookUrl:    ctx.String(webhookUrlFlag.Name),
+			WebhookSecret: ctx.String(webhookSecretFlag.Name),
+			SystemdNotify: true,
+		}
+
+		mcs, err := metadata.NewMetadataCredentialService(addr, in)
+		if err != nil {
+			return err
+		}
+		return mcs.Run()
+	},
+}
+
+var wslDistroFlag = &cli.StringSliceFlag{
+	Name:    "distro",
+	Aliases: []string{"d"},
+	Usage:   "The WSL distro to configure, may be specified multiple times (default: the default WSL distro)",
+}
+
+var wslNoProfileFlag = &cli.BoolFlag{
+	Name:        "no-profile",
+	Usage:       "Do not update ~/.profile in the distro to load the credential environment file",
+	Value:       false,
+	DefaultText: "",
+}
+
+// wslEnv returns the content of the shell environment file which points the AWS SDKs in the distro to the service.
+func wslEnv(endpoint, token string) []byte {
+	b := new(bytes.Buffer)
+	b.WriteString("# generated by aws-runas serve wsl, changes will be overwritten\n")
+	_, _ = fmt.Fprintf(b, "export AWS_CONTAINER_CREDENTIALS_FULL_URI='%s'\n", endpoint)
+	_, _ = fmt.Fprintf(b, "export AWS_CONTAINER_AUTHORIZATION_TOKEN='%s'\n", token)
+	return b.Bytes()
+}
+
+// wslScript returns the shell commands run in the distro to write the environment file, read from stdin, and optionally
+// load the file from the user's ~/.profile.
+func wslScript(profile bool) string {
+	f := `"$HOME/` + wslEnvFile + `"`
+	s := []string{
+		"umask 077",
+		`mkdir -p "$(dirname ` + f + `)"`,
+		"cat > " + f,
+	}
+
+	if profile {
+		s = append(s, `(grep -qsF '`+wslEnvFile+`' "$HOME/.profile" || echo '[ -f `+f+` ] && . `+f+`' >> "$HOME/.profile")`)
+	}
+	return strings.Join(s, " && ")
+}
+
+// wslExec runs the shell script in the distro (or the default distro, if empty), with the provided stdin.  It's a
+// variable so tests can avoid calling wsl.exe.
+var wslExec = func(distro, script string, stdin io.Reader) error {
+	args := make([]string, 0, 6)
+	if len(distro) > 0 {
+		args = append(args, "-d", distro)
+	}
+	args = append(args, "-e", "sh", "-c", script)
+
+	cmd := exec.Command("wsl.exe", args...) //nolint:gosec // distro is passed as a single arg
+	cmd.Stdin = stdin
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// wslConfigure writes the environment file in the distro.
+func wslConfigure(distro string, env []byte, profile bool) error {
+	name := distro
+	if len(name) < 1 {
+		name = "default"
+	}
+
+	if err := wslExec(distro, wslScript(profile), bytes.NewReader(env)); err != nil {
+		return fmt.Errorf("unable to configure WSL distro %s: %w", name, err)
+	}
+	log.Infof("configured credential environment for WSL distro %s", name)
+	return nil
+}
+
+// wslMirroredNetworking returns true if the user's .wslconfig file enables mirrored networking mode.
+func wslMirroredNetworking() bool {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return false
+	}
+
+	data, err := os.ReadFile(filepath.Join(home, ".wslconfig"))
+	if err != nil {
+		return false
+	}
+	return wslConfigMirrored(data)
+}
+
+func wslConfigMirrored(data []byte) bool {
+	for _, l := range strings.Split(string(data), "\n") {
+		k, v, ok := strings.Cut(l, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(k), "networkingMode") {
+			return strings.EqualFold(strings.TrimSpace(v), "mirrored")
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestServeWslCmd_Action(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the wsl service is supported on windows")
+	}
+
+	if err := App.Run([]string{"mycmd", "serve", "wsl"}); err == nil {
+		t.Error("did not receive expected error")
+	}
+}
+
+func TestWslEnv(t *testing.T) {
+	env := string(wslEnv("http://127.0.0.1:12319/credentials", "mytoken"))
+
+	if !strings.Contains(env, "export AWS_CONTAINER_CREDENTIALS_FULL_URI='http://127.0.0.1:12319/credentials'\n") {
+		t.Error("missing credential endpoint")
+	}
+
+	if !strings.Contains(env, "export AWS_CONTAINER_AUTHORIZATION_TOKEN='mytoken'\n") {
+		t.Error("missing authorization token")
+	}
+}
+
+func TestWslScript(t *testing.T) {
+	t.Run("profile", func(t *testing.T) {
+		s := wslScript(true)
+		if !strings.HasPrefix(s, "umask 077 && ") || !strings.Contains(s, `cat > "$HOME/.aws-runas/wsl.env"`) {
+			t.Error("invalid script")
+		}
+
+		if !strings.Contains(s, `>> "$HOME/.profile"`) {
+			t.Error("missing profile update")
+		}
+	})
+
+	t.Run("no profile", func(t *testing.T) {
+		if strings.Contains(wslScript(false), ".profile") {
+			t.Error("unexpected profile update")
+		}
+	})
+}
+
+func TestWslConfigure(t *testing.T) {
+	defer func(f func(string, string, io.Reader) error) { wslExec = f }(wslExec)
+
+	t.Run("good", func(t *testing.T) {
+		var distro, env string
+		wslExec = func(d string, _ string, r io.Reader) error {
+			b, _ := io.ReadAll(r)
+			distro, env = d, string(b)
+			return nil
+		}
+
+		if err := wslConfigure("Ubuntu", []byte("env data"), true); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if distro != "Ubuntu" || env != "env data" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		wslExec = func(string, string, io.Reader) error { return errors.New("error") }
+
+		if err := wslConfigure("", nil, false); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestWslConfigMirrored(t *testing.T) {
+	t.Run("mirrored", func(t *testing.T) {
+		if !wslConfigMirrored([]byte("[wsl2]\nmemory=8GB\nnetworkingMode = Mirrored\n")) {
+			t.Error("mirrored networking not detected")
+		}
+	})
+
+	t.Run("nat", func(t *testing.T) {
+		if wslConfigMirrored([]byte("[wsl2]\nnetworkingMode=nat\n")) {
+			t.Error("unexpected mirrored networking")
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if wslConfigMirrored(nil) {
+			t.Error("unexpected mirrored networking")
+		}
+	})
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/urfave/cli/v2"
+)
+
+const statusDesc = `Show the state of the cached credentials for a profile, using only the local credential
+cache.  AWS and the identity provider are never contacted, and there are no prompts, so it's
+fast enough to call from a shell prompt.  The --porcelain flag prints a single line with the
+profile name, role ARN ('-' if none), and the number of seconds until the credentials expire
+(0 if expired or not cached).  The --json flag prints the status details as a JSON object.
+
+The exit code reflects the state of the credentials, so scripts can act on it:
+  0  valid
+  2  valid, but expiring within the --expiring time
+  3  expired
+  4  not cached
+Any other non-zero exit code is an error finding the status.`
+
+var statusCmd = &cli.Command{
+	Name:         "status",
+	Usage:        "Show the state of the cached credentials for a profile",
+	ArgsUsage:    "[profile_name]",
+	Description:  statusDesc,
+	Flags:        []cli.Flag{statusPorcelainFlag, statusJsonFlag, statusExpiringFlag},
+	BashComplete: bashCompleteProfile,
+
+	Action: func(ctx *cli.Context) error {
+		profile, cfg, err := resolveConfig(ctx, 1)
+		if err != nil {
+			return err
+		}
+
+		st := profileStatus(profile, cfg, time.Now(), ctx.Duration(statusExpiringFlag.Name))
+		switch {
+		case ctx.Bool(statusJsonFlag.Name):
+			out, err := json.MarshalIndent(st, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\n", out)
+		case ctx.Bool(statusPorcelainFlag.Name):
+			printPorcelainStatus(os.Stdout, st)
+		default:
+			printStatus(os.Stdout, st)
+		}
+
+		if code := statusExitCodes[st.State]; code != statusValid {
+			return cli.Exit("", code)
+		}
+		return nil
+	},
+}
+
+var statusPorcelainFlag = &cli.BoolFlag{
+	Name:  "porcelain",
+	Usage: "print the status in a stable, machine-readable format",
+}
+
+var statusJsonFlag = &cli.BoolFlag{
+	Name:  "json",
+	Usage: "print the status details as JSON",
+}
+
+var statusExpiringFlag = &cli.DurationFlag{
+	Name:    "expiring",
+	Aliases: []string{"x"},
+	Usage:   "report credentials expiring within this amount of time as expiring soon",
+	Value:   10 * time.Minute,
+}
+
+// The states of the cached credentials reported by the status command.
+const (
+	stateValid     = "valid"
+	stateExpiring  = "expiring"
+	stateExpired   = "expired"
+	stateNotCached = "not_cached"
+)
+
+// Exit codes of the status command.  Code 1 is left for errors, which the cli package uses by default.
+const (
+	statusValid     = 0
+	statusExpiring  = 2
+	statusExpired   = 3
+	statusNotCached = 4
+)
+
+var statusExitCodes = map[string]int{
+	stateValid:     statusValid,
+	stateExpiring:  statusExpiring,
+	stateExpired:   statusExpired,
+	stateNotCached: statusNotCached,
+}
+
+// credentialStatus is the state of the cached credentials for a profile.
+type credentialStatus struct {
+	Profile    string     `json:"profile"`
+	RoleArn    string     `json:"role_arn,omitempty"`
+	State      string     `json:"state"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	ExpiresIn  int64      `json:"expires_in"` // seconds until the credentials expire, 0 if expired or not cached
+}
+
+func profileStatus(profile string, cfg *config.AwsConfig, now time.Time, expiring time.Duration) *credentialStatus {
+	st := &credentialStatus{Profile: profile, RoleArn: cfg.RoleArn, State: stateNotCached}
+	if len(st.Profile) < 1 {
+		st.Profile = "default"
+	}
+
+	creds, err := client.CachedCredentials(cfg)
+	if err != nil {
+		log.Debugf("no cached credentials: %v", err)
+		return st
+	}
+
+	st.Expiration = &creds.Expiration
+
+	d := creds.Expiration.Sub(now)
+	switch {
+	case d <= 0:
+		st.State = stateExpired
+	case d <= expiring:
+		st.State = stateExpiring
+	default:
+		st.State = stateValid
+	}
+
+	if d > 0 {
+		st.ExpiresIn = int64(d.Seconds())
+	}
+	return st
+}
+
+func printPorcelainStatus(w io.Writer, st *credentialStatus) {
+	role := st.RoleArn
+	if len(role) < 1 {
+		role = "-"
+	}
+	_, _ = fmt.Fprintf(w, "%s %s %d\n", st.Profile, role, st.ExpiresIn)
+}
+
+func printStatus(w io.Writer, st *credentialStatus) {
+	_, _ = fmt.Fprintf(w, "Profile: %s\n", st.Profile)
+	if len(st.RoleArn) > 0 {
+		_, _ = fmt.Fprintf(w, "Role:    %s\n", st.RoleArn)
+	}
+
+	switch st.State {
+	case stateNotCached:
+		_, _ = fmt.Fprintln(w, "Status:  not cached")
+	case stateExpired:
+		_, _ = fmt.Fprintf(w, "Status:  expired at %s\n", st.Expiration.Local().Format(time.RFC3339))
+	default:
+		_, _ = fmt.Fprintf(w, "Status:  %s for %s (until %s)\n", st.State, time.Duration(st.ExpiresIn)*time.Second,
+			st.Expiration.Local().Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+func TestProfileStatus(t *testing.T) {
+	now := time.Now()
+	cfg := &config.AwsConfig{ProfileName: "p", RoleArn: "arn:aws:iam::123456789012:role/Admin", CacheDir: t.TempDir()}
+
+	t.Run("not cached", func(t *testing.T) {
+		st := profileStatus("p", cfg, now, 0)
+		if st.State != stateNotCached || st.ExpiresIn != 0 {
+			t.Errorf("data mismatch: %+v", st)
+		}
+	})
+
+	err := clientFactory.ImportProfileCredentials(cfg, &credentials.Credentials{
+		AccessKeyId:     "ASIAMOCK",
+		SecretAccessKey: "secret",
+		Expiration:      now.Add(10 * time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		st := profileStatus("p", cfg, now, 5*time.Minute)
+		if st.State != stateValid || st.ExpiresIn < 590 || st.ExpiresIn > 600 {
+			t.Errorf("data mismatch: %+v", st)
+		}
+	})
+
+	t.Run("expiring", func(t *testing.T) {
+		st := profileStatus("p", cfg, now, 15*time.Minute)
+		if st.State != stateExpiring || statusExitCodes[st.State] != statusExpiring {
+			t.Errorf("data mismatch: %+v", st)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		st := profileStatus("", cfg, now.Add(1*time.Hour), 0)
+		if st.State != stateExpired || st.ExpiresIn != 0 || st.Profile != "default" {
+			t.Errorf("data mismatch: %+v", st)
+		}
+	})
+}
+
+func TestPrintPorcelainStatus(t *testing.T) {
+	b := new(bytes.Buffer)
+
+	printPorcelainStatus(b, &credentialStatus{Profile: "p", ExpiresIn: 42})
+	if b.String() != "p - 42\n" {
+		t.Errorf("data mismatch: %s", b)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/client/external"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/metadata"
+	"github.com/urfave/cli/v2"
+)
+
+const warmDesc = `Refresh the cached credentials of profiles which expire within the --window time, so they are
+ready before they're needed.  It's intended to be called from a shell init file (like ~/.bashrc),
+so nothing is printed, and it always exits successfully.  Use the --verbose flag to see what was
+done for each profile.
+
+Only profiles with cached credentials are refreshed, all profiles are checked if none are given.
+Credentials are refreshed only if that's possible without any user interaction, using a valid
+identity provider session (cookies), identity token or cached session credentials.  Profiles which
+need a password, MFA code, or browser login are skipped, and left for the next time the profile is
+used.  If the refresh of a profile fails, the credentials which were cached are kept.`
+
+var warmCmd = &cli.Command{
+	Name:         "warm",
+	Usage:        "Refresh cached credentials which are about to expire, without prompting",
+	ArgsUsage:    "[profile_name...]",
+	Description:  warmDesc,
+	Flags:        []cli.Flag{warmWindowFlag, warmTimeoutFlag},
+	BashComplete: bashCompleteProfile,
+
+	Action: func(ctx *cli.Context) error {
+		if opts.Offline {
+			log.Debugf("%v, not refreshing credentials", client.ErrOffline)
+			return nil
+		}
+
+		names := ctx.Args().Slice()
+		if len(names) < 1 {
+			p, err := config.DefaultIniLoader.Profiles()
+			if err != nil {
+				log.Debugf("unable to load profiles: %v", err)
+				return nil
+			}
+
+			for k := range p {
+				names = append(names, k)
+			}
+		}
+
+		// there is nobody to answer a prompt, fail instead of waiting for input
+		opts.MfaInputProvider = func() (string, error) {
+			return "", metadata.ErrInputRequired
+		}
+
+		opts.CredentialInputProvider = func(_ string, _ string) (string, string, error) {
+			return "", "", metadata.ErrInputRequired
+		}
+
+		opts.PasswordChangeProvider = nil
+		opts.RoleSelectionProvider = nil
+
+		c, cancel := context.WithTimeout(context.Background(), ctx.Duration(warmTimeoutFlag.Name))
+		defer cancel()
+
+		for _, r := range warmProfiles(external.WithoutLogin(c), names, time.Now(), ctx.Duration(warmWindowFlag.Name), refreshProfile) {
+			if r.err != nil {
+				log.Debugf("profile %s: %s: %v", r.profile, r.result, r.err)
+			} else {
+				log.Debugf("profile %s: %s", r.profile, r.result)
+			}
+		}
+		return nil
+	},
+}
+
+var warmWindowFlag = &cli.DurationFlag{
+	Name:    "window",
+	Aliases: []string{"w"},
+	Usage:   "refresh cached credentials which expire within this amount of time",
+	Value:   15 * time.Minute,
+}
+
+var warmTimeoutFlag = &cli.DurationFlag{
+	Name:  "timeout",
+	Usage: "the maximum amount of time to spend refreshing credentials",
+	Value: 30 * time.Second,
+}
+
+// The results of warming the credentials of a profile.
+const (
+	warmRefreshed = "refreshed"
+	warmSkipped   = "skipped"
+	warmDeferred  = "deferred"
+	warmFailed    = "failed"
+)
+
+// warmResult is the outcome of warming the credentials of a single profile.
+type warmResult struct {
+	profile string
+	result  string
+	err     error
+}
+
+// warmProfiles refreshes the cached credentials of the named profiles which expire within the window, using the refresh
+// function.  Profiles are done in name order, so profiles sharing the same cached credentials (like a source profile)
+// see the credentials refreshed by an earlier profile as valid.  Credentials which are still valid are put back in the
+// cache if the refresh fails, since refreshing a profile removes its cached credentials.
+func warmProfiles(ctx context.Context, names []string, now time.Time, window time.Duration,
+	refresh func(context.Context, *config.AwsConfig) (*credentials.Credentials, error)) []*warmResult {
+	out := make([]*warmResult, 0, len(names))
+	for _, name := range slices.Sorted(slices.Values(names)) {
+		r := &warmResult{profile: name}
+		out = append(out, r)
+
+		cfg, err := configResolver.Config(name)
+		if err != nil {
+			r.result, r.err = warmFailed, err
+			continue
+		}
+
+		st := profileStatus(name, cfg, now, window)
+		if st.State == stateValid || st.State == stateNotCached {
+			r.result = warmSkipped
+			continue
+		}
+
+		if ctx.Err() != nil {
+			r.result, r.err = warmDeferred, ctx.Err()
+			continue
+		}
+
+		cached, _ := client.CachedCredentials(cfg)
+		if _, err = refresh(ctx, cfg); err != nil {
+			r.result, r.err = warmFailed, err
+			if errors.Is(err, external.ErrLoginRequired) || errors.Is(err, metadata.ErrInputRequired) {
+				r.result = warmDeferred
+			}
+
+			if st.State == stateExpiring && cached != nil {
+				if err = clientFactory.ImportProfileCredentials(cfg, cached); err != nil {
+					log.Debugf("unable to restore cached credentials for profile %s: %v", name, err)
+				}
+			}
+			continue
+		}
+		r.result = warmRefreshed
+	}
+	return out
+}
+
+// refreshProfile gets new credentials for the profile, ignoring the credentials in the cache.
+func refreshProfile(ctx context.Context, cfg *config.AwsConfig) (*credentials.Credentials, error) {
+	c, err := clientFactory.Get(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return c.Refresh(ctx)
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/client/external"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+func TestWarmCmd_warmProfiles(t *testing.T) {
+	dir := setProfilesConfig(t)
+	now := time.Now()
+
+	cache := func(t *testing.T, profile string, exp time.Time) *config.AwsConfig {
+		t.Helper()
+
+		cfg, err := configResolver.Config(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.CacheDir = dir
+
+		creds := &credentials.Credentials{AccessKeyId: "AKIAMOCK", SecretAccessKey: "MockSecret", Token: "MockToken",
+			Expiration: exp}
+		if err = clientFactory.ImportProfileCredentials(cfg, creds); err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+
+	t.Run("refreshed", func(t *testing.T) {
+		cache(t, "prod", now.Add(5*time.Minute))
+
+		var refreshed []string
+		refresh := func(_ context.Context, cfg *config.AwsConfig) (*credentials.Credentials, error) {
+			refreshed = append(refreshed, cfg.ProfileName)
+			return new(credentials.Credentials), nil
+		}
+
+		res := warmProfiles(context.Background(), []string{"prod", "dev"}, now, 15*time.Minute, refresh)
+		if len(res) != 2 || res[0].result != warmSkipped || res[1].result != warmRefreshed {
+			t.Errorf("unexpected results: %+v, %+v", res[0], res[1])
+		}
+
+		if len(refreshed) != 1 || refreshed[0] != "prod" {
+			t.Errorf("unexpected profiles refreshed: %v", refreshed)
+		}
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		cache(t, "prod", now.Add(time.Hour))
+
+		refresh := func(context.Context, *config.AwsConfig) (*credentials.Credentials, error) {
+			t.Error("valid credentials were refreshed")
+			return nil, nil
+		}
+
+		if res := warmProfiles(context.Background(), []string{"prod"}, now, 15*time.Minute, refresh); res[0].result != warmSkipped {
+			t.Errorf("unexpected result: %+v", res[0])
+		}
+	})
+
+	t.Run("deferred", func(t *testing.T) {
+		exp := now.Add(5 * time.Minute).Truncate(time.Second)
+		cfg := cache(t, "prod", exp)
+
+		refresh := func(_ context.Context, cfg *config.AwsConfig) (*credentials.Credentials, error) {
+			// a refresh always removes the cached credentials
+			if err := client.ClearCache(cfg, client.CacheScopeCredentials); err != nil {
+				t.Error(err)
+			}
+			return nil, external.ErrLoginRequired
+		}
+
+		res := warmProfiles(context.Background(), []string{"prod"}, now, 15*time.Minute, refresh)
+		if res[0].result != warmDeferred || !errors.Is(res[0].err, external.ErrLoginRequired) {
+			t.Errorf("unexpected result: %+v", res[0])
+		}
+
+		creds, err := client.CachedCredentials(cfg)
+		if err != nil || !creds.Expiration.Equal(exp) {
+			t.Errorf("cached credentials were not restored: %v", err)
+		}
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		cache(t, "prod", now.Add(-time.Minute))
+
+		refresh := func(context.Context, *config.AwsConfig) (*credentials.Credentials, error) {
+			return nil, errors.New("error")
+		}
+
+		res := warmProfiles(context.Background(), []string{"prod", "missing"}, now, 15*time.Minute, refresh)
+		if res[0].profile != "missing" || res[0].result != warmFailed || res[1].result != warmFailed {
+			t.Errorf("unexpected results: %+v, %+v", res[0], res[1])
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		cache(t, "prod", now.Add(5*time.Minute))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		refresh := func(context.Context, *config.AwsConfig) (*credentials.Credentials, error) {
+			t.Error("credentials were refreshed after the timeout")
+			return nil, nil
+		}
+
+		if res := warmProfiles(ctx, []string{"prod"}, now, 15*time.Minute, refresh); res[0].result != warmDeferred {
+			t.Errorf("unexpected result: %+v", res[0])
+		}
+	})
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+// ErrAccountMismatch is returned when the credentials retrieved by a client do not belong to the account configured
+// in the expected_account_id setting.
+var ErrAccountMismatch = errors.New("credentials do not belong to the expected account")
+
+var accountIdRe = regexp.MustCompile(`^\d{12}$`)
+
+// accountGuardClient wraps an AwsClient, refusing to return credentials which are not for the expected account.
+type accountGuardClient struct {
+	AwsClient
+	account string
+	roleArn string
+	// callerAccount looks up the account for a set of credentials, only used if the role ARN has no single account
+	callerAccount func(ctx context.Context, creds *credentials.Credentials) (string, error)
+	mu            sync.Mutex
+	verified      string // access key id of the last verified credentials
+}
+
+// samlAccountGuardClient is an accountGuardClient which retains the SamlAssertionClient behavior of the wrapped client.
+type samlAccountGuardClient struct {
+	*accountGuardClient
+	saml SamlAssertionClient
+}
+
+func newAccountGuardClient(cl AwsClient, account, roleArn string) AwsClient {
+	gc := &accountGuardClient{AwsClient: cl, account: account, roleArn: roleArn}
+	gc.callerAccount = gc.stsCallerAccount
+
+	if sc, ok := cl.(SamlAssertionClient); ok {
+		return &samlAccountGuardClient{accountGuardClient: gc, saml: sc}
+	}
+	return gc
+}
+
+// Credentials calls CredentialsWithContext with a background context.
+func (c *accountGuardClient) Credentials() (*credentials.Credentials, error) {
+	return c.CredentialsWithContext(context.Background())
+}
+
+// CredentialsWithContext retrieves credentials from the wrapped client, and verifies they are for the expected account.
+func (c *accountGuardClient) CredentialsWithContext(ctx context.Context) (*credentials.Credentials, error) {
+	creds, err := c.AwsClient.CredentialsWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.verify(ctx, creds)
+}
+
+// Refresh retrieves new credentials from the wrapped client, and verifies they are for the expected account.
+func (c *accountGuardClient) Refresh(ctx context.Context) (*credentials.Credentials, error) {
+	creds, err := c.AwsClient.Refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.verify(ctx, creds)
+}
+
+// ConfigProvider returns the aws.Config of the wrapped client, updated so the credentials used by AWS service clients
+// are also verified.
+func (c *accountGuardClient) ConfigProvider() aws.Config {
+	cfg := c.AwsClient.ConfigProvider().Copy()
+	cfg.Credentials = aws.NewCredentialsCache(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		creds, err := c.CredentialsWithContext(ctx)
+		if err != nil {
+			return aws.Credentials{}, err
+		}
+		return creds.Value(), nil
+	}))
+	return cfg
+}
+
+func (c *accountGuardClient) verify(ctx context.Context, creds *credentials.Credentials) (*credentials.Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.verified) > 0 && c.verified == creds.AccessKeyId {
+		return creds, nil
+	}
+
+	account, ok := roleAccount(c.roleArn)
+	if !ok {
+		var err error
+		if account, err = c.callerAccount(ctx, creds); err != nil {
+			return nil, fmt.Errorf("unable to verify credentials account: %w", err)
+		}
+	}
+
+	if account != c.account {
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrAccountMismatch, c.account, account)
+	}
+
+	c.verified = creds.AccessKeyId
+	return creds, nil
+}
+
+// roleAccount returns the account of the role ARN, and false if the ARN does not name a single account, as with role
+// patterns, so the account the credentials were issued for must be looked up.
+func roleAccount(roleArn string) (string, bool) {
+	if credentials.IsRolePattern(roleArn) {
+		return "", false
+	}
+
+	r, err := arn.Parse(roleArn)
+	if err != nil || !accountIdRe.MatchString(r.AccountID) {
+		return "", false
+	}
+	// credentials from an assume role operation will always be for the account of the role
+	return r.AccountID, true
+}
+
+// stsCallerAccount calls GetCallerIdentity using the provided credentials to find the account they belong to.
+func (c *accountGuardClient) stsCallerAccount(ctx context.Context, creds *credentials.Credentials) (string, error) {
+	cfg := c.AwsClient.ConfigProvider().Copy()
+	cfg.Credentials = aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return creds.Value(), nil
+	})
+
+	out, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, new(sts.GetCallerIdentityInput))
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.Account), nil
+}
+
+// SamlAssertion calls the SamlAssertion method of the wrapped client.
+func (c *samlAccountGuardClient) SamlAssertion() (*credentials.SamlAssertion, error) {
+	return c.saml.SamlAssertion()
+}
+
+// SamlAssertionWithContext calls the SamlAssertionWithContext method of the wrapped client.
+func (c *samlAccountGuardClient) SamlAssertionWithContext(ctx context.Context) (*credentials.SamlAssertion, error) {
+	return c.saml.SamlAssertionWithContext(ctx)
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+func TestAccountGuardClient_Credentials(t *testing.T) {
+	t.Run("role arn match", func(t *testing.T) {
+		cl := newAccountGuardClient(newSessionTokenClient(), "123456789012", "arn:aws:iam::123456789012:role/Role")
+		if _, err := cl.Credentials(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("role arn mismatch", func(t *testing.T) {
+		cl := newAccountGuardClient(newSessionTokenClient(), "123456789012", "arn:aws:iam::210987654321:role/Role")
+		if _, err := cl.Credentials(); !errors.Is(err, ErrAccountMismatch) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("caller identity match", func(t *testing.T) {
+		var calls int
+		gc := newAccountGuardClient(newSessionTokenClient(), "123456789012", "").(*accountGuardClient)
+		gc.callerAccount = func(context.Context, *credentials.Credentials) (string, error) {
+			calls++
+			return "123456789012", nil
+		}
+
+		if _, err := gc.Credentials(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		// verified credentials should not be looked up again
+		if _, err := gc.Credentials(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if calls != 1 {
+			t.Errorf("unexpected lookup count: %d", calls)
+		}
+	})
+
+	t.Run("caller identity mismatch", func(t *testing.T) {
+		gc := newAccountGuardClient(newSessionTokenClient(), "123456789012", "").(*accountGuardClient)
+		gc.callerAccount = func(context.Context, *credentials.Credentials) (string, error) {
+			return "210987654321", nil
+		}
+
+		if _, err := gc.Credentials(); !errors.Is(err, ErrAccountMismatch) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("caller identity error", func(t *testing.T) {
+		gc := newAccountGuardClient(newSessionTokenClient(), "123456789012", "").(*accountGuardClient)
+		gc.callerAccount = func(context.Context, *credentials.Credentials) (string, error) {
+			return "", errors.New("failed")
+		}
+
+		if _, err := gc.Credentials(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("role pattern", func(t *testing.T) {
+		var calls int
+		gc := newAccountGuardClient(newSessionTokenClient(), "123456789012", "arn:aws:iam::*:role/Dev*").(*accountGuardClient)
+		gc.callerAccount = func(context.Context, *credentials.Credentials) (string, error) {
+			calls++
+			return "123456789012", nil
+		}
+
+		if _, err := gc.Credentials(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if calls != 1 {
+			t.Errorf("unexpected lookup count: %d", calls)
+		}
+	})
+
+	t.Run("role pattern mismatch", func(t *testing.T) {
+		gc := newAccountGuardClient(newSessionTokenClient(), "123456789012", "arn:aws:iam::*:role/Dev*").(*accountGuardClient)
+		gc.callerAccount = func(context.Context, *credentials.Credentials) (string, error) {
+			return "210987654321", nil
+		}
+
+		if _, err := gc.Credentials(); !errors.Is(err, ErrAccountMismatch) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("config provider", func(t *testing.T) {
+		cl := newAccountGuardClient(newSessionTokenClient(), "123456789012", "arn:aws:iam::210987654321:role/Role")
+		if _, err := cl.ConfigProvider().Credentials.Retrieve(context.Background()); !errors.Is(err, ErrAccountMismatch) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestNewAccountGuardClient(t *testing.T) {
+	t.Run("saml", func(t *testing.T) {
+		cl := newAccountGuardClient(&samlRoleClient{samlClient: new(mockSamlClient), roleProvider: new(mockSamlRoleProvider)}, "123456789012", "")
+		if _, ok := cl.(SamlAssertionClient); !ok {
+			t.Error("client is not a SamlAssertionClient")
+		}
+	})
+
+	t.Run("factory", func(t *testing.T) {
+		cfg := &config.AwsConfig{RoleArn: "arn:aws:iam::123456789012:role/Role", RoleSessionName: "mock", ExpectedAccountId: "123456789012"}
+		cl, err := NewClientFactory(new(mockResolver), DefaultOptions).Get(cfg)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if _, ok := cl.(*accountGuardClient); !ok {
+			t.Error("client is not an accountGuardClient")
+		}
+	})
+}
@@ -14,6 +14,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/mmmorris1975/aws-runas/credentials"
@@ -39,7 +40,7 @@ type AssumeRoleClientConfig struct {
 func NewAssumeRoleClient(cfg aws.Config, clientCfg *AssumeRoleClientConfig) *assumeRoleClient {
 	c := &assumeRoleClient{newBaseIamClient(cfg, clientCfg.Logger), nil}
 
-	p := credentials.NewAssumeRoleProvider(cfg, clientCfg.RoleArn)
+	p := credentials.NewAssumeRoleProvider(stsConfig(cfg, clientCfg.StsRetryer), clientCfg.RoleArn)
 	p.Cache = clientCfg.Cache
 	p.Duration = clientCfg.Duration
 	p.SerialNumber = clientCfg.SerialNumber
@@ -79,3 +80,12 @@ func (c *assumeRoleClient) ClearCache() error {
 	}
 	return nil
 }
+
+// Refresh is the implementation of the CredentialClient interface which clears any cached credentials for this client,
+// and retrieves a new set of credentials.
+func (c *assumeRoleClient) Refresh(ctx context.Context) (*credentials.Credentials, error) {
+	if err := c.ClearCache(); err != nil {
+		return nil, err
+	}
+	return c.CredentialsWithContext(ctx)
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/shared"
+)
+
+// The audit log event types.
+const (
+	// AuditEventIssued is recorded when credentials are provided to the local aws-runas process.
+	AuditEventIssued = "issued"
+	// AuditEventServed is recorded when credentials are provided to a caller of the metadata credential service.
+	AuditEventServed = "served"
+)
+
+// AuditRecord is an entry in the credential audit log, written as a single line of JSON.
+type AuditRecord struct {
+	Timestamp      time.Time `json:"timestamp"`
+	Event          string    `json:"event"`
+	Profile        string    `json:"profile,omitempty"`
+	RoleArn        string    `json:"role_arn,omitempty"`
+	SourceIdentity string    `json:"source_identity,omitempty"`
+	AccessKeyId    string    `json:"access_key_id"`
+	Expiration     time.Time `json:"expiration,omitzero"`
+	Duration       int64     `json:"duration"` // seconds until the credentials expire, 0 if they do not expire
+	Caller         string    `json:"caller"`
+}
+
+type auditCallerKey struct{}
+
+// WithAuditCaller returns a copy of ctx which identifies the caller the credentials are served to, like the remote
+// address of a metadata credential service request.  Credentials retrieved using this context are recorded in the
+// audit log as served to the caller, instead of issued to the local process.
+func WithAuditCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, auditCallerKey{}, caller)
+}
+
+// auditLogMu serializes writes to audit logs within the process, O_APPEND keeps writes from separate processes intact.
+var auditLogMu sync.Mutex
+
+// processCaller identifies the local aws-runas process as the caller.
+var processCaller = sync.OnceValue(func() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return fmt.Sprintf("%s pid=%d user=%s", filepath.Base(os.Args[0]), os.Getpid(), name)
+})
+
+// auditClient wraps an AwsClient, appending a record to the audit log file each time credentials are retrieved.
+type auditClient struct {
+	AwsClient
+	file   string
+	logger shared.Logger
+	record AuditRecord
+}
+
+// samlAuditClient is an auditClient which retains the SamlAssertionClient behavior of the wrapped client.
+type samlAuditClient struct {
+	*auditClient
+	saml SamlAssertionClient
+}
+
+func newAuditClient(cl AwsClient, cfg *config.AwsConfig, file string, logger shared.Logger) AwsClient {
+	ac := &auditClient{
+		AwsClient: cl,
+		file:      file,
+		logger:    logger,
+		record: AuditRecord{
+			Profile:        cfg.ProfileName,
+			RoleArn:        cfg.RoleArn,
+			SourceIdentity: sourceIdentity(cfg),
+		},
+	}
+
+	if sc, ok := cl.(SamlAssertionClient); ok {
+		return &samlAuditClient{auditClient: ac, saml: sc}
+	}
+	return ac
+}
+
+// Credentials calls CredentialsWithContext with a background context.
+func (c *auditClient) Credentials() (*credentials.Credentials, error) {
+	return c.CredentialsWithContext(context.Background())
+}
+
+// CredentialsWithContext retrieves credentials from the wrapped client, and records them in the audit log.
+func (c *auditClient) CredentialsWithContext(ctx context.Context) (*credentials.Credentials, error) {
+	creds, err := c.AwsClient.CredentialsWithContext(ctx)
+	if err == nil {
+		c.audit(ctx, creds)
+	}
+	return creds, err
+}
+
+// Refresh retrieves new credentials from the wrapped client, and records them in the audit log.
+func (c *auditClient) Refresh(ctx context.Context) (*credentials.Credentials, error) {
+	creds, err := c.AwsClient.Refresh(ctx)
+	if err == nil {
+		c.audit(ctx, creds)
+	}
+	return creds, err
+}
+
+// audit writes the record for the credentials.  Failing to write the record is logged, but does not fail the request
+// for credentials.
+func (c *auditClient) audit(ctx context.Context, creds *credentials.Credentials) {
+	rec := c.record
+	rec.Timestamp = time.Now().UTC()
+	rec.Event = AuditEventIssued
+	rec.Caller = processCaller()
+	rec.AccessKeyId = creds.AccessKeyId
+
+	if caller, ok := ctx.Value(auditCallerKey{}).(string); ok {
+		rec.Event = AuditEventServed
+		rec.Caller = caller
+	}
+
+	if !creds.Expiration.IsZero() {
+		rec.Expiration = creds.Expiration.UTC()
+		rec.Duration = max(int64(time.Until(creds.Expiration).Seconds()), 0)
+	}
+
+	if err := writeAuditRecord(c.file, &rec); err != nil && c.logger != nil {
+		c.logger.Warningf("unable to write audit log: %v", err)
+	}
+}
+
+// SamlAssertion calls the SamlAssertion method of the wrapped client.
+func (c *samlAuditClient) SamlAssertion() (*credentials.SamlAssertion, error) {
+	return c.saml.SamlAssertion()
+}
+
+// SamlAssertionWithContext calls the SamlAssertionWithContext method of the wrapped client.
+func (c *samlAuditClient) SamlAssertionWithContext(ctx context.Context) (*credentials.SamlAssertion, error) {
+	return c.saml.SamlAssertionWithContext(ctx)
+}
+
+// sourceIdentity returns the identity the credentials are derived from: the identity provider username for SAML and
+// Web Identity profiles, or the name of the source profile for IAM profiles.
+func sourceIdentity(cfg *config.AwsConfig) string {
+	switch {
+	case len(cfg.SamlUrl) > 0:
+		return cfg.SamlUsername
+	case len(cfg.WebIdentityUrl) > 0:
+		return cfg.WebIdentityUsername
+	default:
+		return cfg.SrcProfile
+	}
+}
+
+// writeAuditRecord appends the record to the audit log file, creating the file if needed.  The file is only ever
+// appended to, and is readable only by the owner.
+func writeAuditRecord(file string, rec *AuditRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	auditLogMu.Lock()
+	defer auditLogMu.Unlock()
+
+	if err = os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+func readAuditLog(t *testing.T, file string) []AuditRecord {
+	t.Helper()
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	recs := make([]AuditRecord, 0)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var rec AuditRecord
+		if err = json.Unmarshal(s.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestAuditClient_Credentials(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "audit", "audit.log")
+	cfg := &config.AwsConfig{
+		ProfileName:  "saml",
+		RoleArn:      "arn:aws:iam::123456789012:role/Admin",
+		SamlUrl:      "https://idp.local/saml",
+		SamlUsername: "bob",
+	}
+
+	c := newSessionTokenClient()
+	c.provider = credentials.NewSessionTokenProvider(aws.Config{})
+	ac := newAuditClient(c, cfg, file, nil)
+
+	t.Run("issued", func(t *testing.T) {
+		if _, err := ac.Credentials(); err != nil {
+			t.Fatal(err)
+		}
+
+		recs := readAuditLog(t, file)
+		if len(recs) != 1 {
+			t.Fatalf("unexpected audit records: %+v", recs)
+		}
+
+		r := recs[0]
+		if r.Event != AuditEventIssued || r.Profile != "saml" || r.RoleArn != cfg.RoleArn || r.SourceIdentity != "bob" ||
+			len(r.AccessKeyId) < 1 || r.Caller != processCaller() || r.Timestamp.IsZero() {
+			t.Errorf("unexpected audit record: %+v", r)
+		}
+
+		if r.Expiration.IsZero() || r.Duration <= 0 {
+			t.Errorf("missing expiration: %+v", r)
+		}
+	})
+
+	t.Run("served", func(t *testing.T) {
+		if _, err := ac.CredentialsWithContext(WithAuditCaller(context.Background(), "127.0.0.1:5555 aws-cli")); err != nil {
+			t.Fatal(err)
+		}
+
+		recs := readAuditLog(t, file)
+		if len(recs) != 2 || recs[1].Event != AuditEventServed || recs[1].Caller != "127.0.0.1:5555 aws-cli" {
+			t.Errorf("unexpected audit records: %+v", recs)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		c.creds = aws.NewCredentialsCache(&mockCredProvider{sendError: true})
+		if _, err := ac.Refresh(context.Background()); err == nil {
+			t.Error("did not receive expected error")
+		}
+
+		if recs := readAuditLog(t, file); len(recs) != 2 {
+			t.Errorf("unexpected audit records: %+v", recs)
+		}
+	})
+}
+
+func TestAuditClient_sourceIdentity(t *testing.T) {
+	t.Run("web identity", func(t *testing.T) {
+		cfg := &config.AwsConfig{WebIdentityUrl: "https://idp.local", WebIdentityUsername: "alice"}
+		if s := sourceIdentity(cfg); s != "alice" {
+			t.Errorf("unexpected source identity %s", s)
+		}
+	})
+
+	t.Run("iam", func(t *testing.T) {
+		if s := sourceIdentity(&config.AwsConfig{SrcProfile: "default"}); s != "default" {
+			t.Errorf("unexpected source identity %s", s)
+		}
+	})
+}
+
+func TestAuditClient_unwritable(t *testing.T) {
+	// a directory can not be opened for writing, the credentials are still returned
+	c := newSessionTokenClient()
+	c.provider = credentials.NewSessionTokenProvider(aws.Config{})
+	ac := newAuditClient(c, new(config.AwsConfig), t.TempDir(), nil)
+
+	if _, err := ac.Credentials(); err != nil {
+		t.Error(err)
+	}
+}
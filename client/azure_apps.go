@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/mmmorris1975/aws-runas/client/external"
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+// AzureAwsApps returns the AWS enterprise applications assigned to the Azure AD user of the SAML profile configuration.
+// The applications are listed using the Microsoft Graph API, with a delegated token requested from the OAuth client
+// set by the web_identity_client_id and web_identity_redirect_uri profile attributes.
+func (f *Factory) AzureAwsApps(ctx context.Context, cfg *config.AwsConfig) ([]*external.AadAwsApp, error) {
+	if cfg == nil {
+		return nil, errors.New("invalid configuration")
+	}
+
+	urls := cfg.SamlUrls()
+	if len(urls) < 1 {
+		return nil, errors.New("profile is not configured for SAML")
+	}
+
+	if len(cfg.WebIdentityClientId) < 1 || len(cfg.WebIdentityRedirectUri) < 1 {
+		return nil, errors.New("web_identity_client_id and web_identity_redirect_uri are required to use the Graph API")
+	}
+
+	mfaProvider, mfaType, err := f.mfaProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := f.resolver.Credentials(urls[0])
+	if err != nil {
+		// non-fatal error, just set empty creds
+		creds = new(config.AwsCredentials)
+	}
+	creds.MergeIn(f.options.CommandCredentials)
+
+	oidcCfg := external.OidcClientConfig{
+		AuthenticationClientConfig: external.AuthenticationClientConfig{
+			Username:                cfg.SamlUsername,
+			Password:                f.decodePassword(urls[0], creds.SamlPassword),
+			MfaTokenCode:            cfg.MfaCode,
+			MfaTokenProvider:        mfaProvider,
+			MfaType:                 mfaType,
+			CredentialInputProvider: f.options.CredentialInputProvider,
+			FederatedUsername:       cfg.FederatedUsername,
+			Logger:                  f.options.Logger,
+			NewPasswordProvider:     f.options.PasswordChangeProvider,
+			PasswordUpdater:         f.passwordUpdater(urls[0], "saml_password", creds.SamlPassword),
+		},
+		ClientId:    cfg.WebIdentityClientId,
+		RedirectUri: cfg.WebIdentityRedirectUri,
+		PkceMode:    cfg.WebIdentityPkce,
+	}
+
+	c, err := external.GetWebIdentityClient("azuread", urls[0], oidcCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, ok := c.(external.AadAppClient)
+	if !ok {
+		return nil, errors.New("identity provider is not Azure AD")
+	}
+	ac.SetCookieJar(f.cookieJar(cfg))
+	ac.SetTransport(f.transport(urls...))
+	ac.SetLoginThrottler(sharedLoginThrottle(cfg))
+
+	return ac.AwsAppsWithContext(ctx)
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+func TestFactory_AzureAwsApps(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *config.AwsConfig
+	}{
+		{"nil config", nil},
+		{"not saml", &config.AwsConfig{ProfileName: "role", RoleArn: "arn:aws:iam::123456789012:role/role"}},
+		{"no client id", &config.AwsConfig{ProfileName: "aad",
+			SamlUrl: "https://myapps.microsoft.com/signin/aws/12345?tenantId=54321"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewClientFactory(new(mockResolver), DefaultOptions).AzureAwsApps(context.Background(), tc.cfg); err == nil {
+				t.Error("did not receive expected error")
+			}
+		})
+	}
+}
@@ -20,26 +20,40 @@ import (
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"github.com/mmmorris1975/aws-runas/identity"
 	"github.com/mmmorris1975/aws-runas/shared"
+	"time"
 )
 
 type baseIamClient struct {
-	creds   *aws.CredentialsCache
-	ident   identity.Provider
-	session aws.Config
+	creds     *aws.CredentialsCache
+	ident     identity.Provider
+	session   aws.Config
+	expiresAt time.Time
 }
 
 func newBaseIamClient(cfg aws.Config, logger shared.Logger) *baseIamClient {
 	return &baseIamClient{ident: identity.NewAwsIdentityProvider(cfg).WithLogger(logger), session: cfg}
 }
 
-// Identity is the implementation of the IdentityClient interface for retrieving identity information for IAM users.
+// Identity is the implementation of the IdentityClient interface, and calls IdentityWithContext with a background context.
 func (c *baseIamClient) Identity() (*identity.Identity, error) {
-	return c.ident.Identity()
+	return c.IdentityWithContext(context.Background())
 }
 
-// Roles is the implementation of the IdentityClient interface for retrieving IAM role information for IAM users.
+// IdentityWithContext is the implementation of the IdentityClient interface for retrieving identity information for
+// IAM users.
+func (c *baseIamClient) IdentityWithContext(ctx context.Context) (*identity.Identity, error) {
+	return c.ident.IdentityWithContext(ctx)
+}
+
+// Roles is the implementation of the IdentityClient interface, and calls RolesWithContext with a background context.
 func (c *baseIamClient) Roles() (*identity.Roles, error) {
-	return c.ident.Roles()
+	return c.RolesWithContext(context.Background())
+}
+
+// RolesWithContext is the implementation of the IdentityClient interface for retrieving IAM role information for
+// IAM users.
+func (c *baseIamClient) RolesWithContext(ctx context.Context) (*identity.Roles, error) {
+	return c.ident.RolesWithContext(ctx)
 }
 
 // Credentials is the implementation of the CredentialClient interface, and calls CredentialsWithContext with a
@@ -64,11 +78,18 @@ func (c *baseIamClient) CredentialsWithContext(ctx context.Context) (*credential
 			Expiration:      v.Expires,
 			ProviderName:    v.Source,
 		}
+		c.expiresAt = v.Expires
 		return cred, nil
 	}
 	return nil, errors.New("credential provider is not set")
 }
 
+// ExpiresAt is the implementation of the CredentialClient interface, returning the expiration time of the credentials
+// most recently retrieved by this client.  The zero time is returned if no credentials have been retrieved.
+func (c *baseIamClient) ExpiresAt() time.Time {
+	return c.expiresAt
+}
+
 // ConfigProvider returns the AWS SDK aws.Config for this client.
 // AWS SDK v1 terminology retained due to laziness.
 func (c *baseIamClient) ConfigProvider() aws.Config {
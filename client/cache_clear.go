@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"errors"
+	"os"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+// CacheScope selects the cached data removed when clearing caches.  Values may be combined.
+type CacheScope uint8
+
+const (
+	// CacheScopeCredentials is the AWS credentials cached for a profile's role.
+	CacheScopeCredentials CacheScope = 1 << iota
+	// CacheScopeIdentityProvider is the data obtained from a SAML or OIDC identity provider, which is the identity
+	// token, the roles found in SAML assertions, and the AWS credentials retrieved using the SAML assertion or identity
+	// token (including jump roles).
+	CacheScopeIdentityProvider
+	// CacheScopeCookies is the cookie jar used for identity provider sessions.
+	CacheScopeCookies
+
+	// CacheScopeAll is all cached data.
+	CacheScopeAll = CacheScopeCredentials | CacheScopeIdentityProvider | CacheScopeCookies
+)
+
+// ClearProfileCache removes the cached data in the requested scope for the profile in the provided configuration.
+// Session token credentials are shared by all profiles using the same source profile, and are not removed, unless
+// cfg is the configuration of the source profile.  Cookies and identity tokens are shared by all profiles using the
+// same identity provider, so clearing them affects all of those profiles.
+func (f *Factory) ClearProfileCache(cfg *config.AwsConfig, scope CacheScope) error {
+	files := make([]string, 0)
+
+	if scope&CacheScopeCredentials > 0 {
+		files = append(files, cacheFileName(cfg, roleCachePrefix, cfg.ProfileName, cfg.RoleArn))
+		if len(cfg.RoleArn) < 1 {
+			files = append(files, cacheFileName(cfg, sessionCachePrefix, cfg.ProfileName, ""))
+		}
+	}
+
+	if scope&(CacheScopeCredentials|CacheScopeIdentityProvider) > 0 {
+		for _, p := range []string{samlCachePrefix, webCachePrefix} {
+			files = append(files, cacheFileName(cfg, p, cfg.ProfileName, cfg.RoleArn))
+		}
+	}
+
+	if scope&CacheScopeIdentityProvider > 0 {
+		forgetSamlAssertions()
+	}
+
+	if scope&CacheScopeIdentityProvider > 0 && len(cfg.JumpRoleArn) > 0 {
+		jumpFiles := []string{cacheFileName(cfg, samlCachePrefix, "", cfg.JumpRoleArn),
+			cacheFileName(cfg, webCachePrefix, "", cfg.JumpRoleArn)}
+		forgetJumpCredentials(jumpFiles...)
+		files = append(files, jumpFiles...)
+	}
+
+	errs := make([]error, 0)
+	for _, file := range files {
+		errs = append(errs, f.credentialCache(cfg, file).Clear())
+	}
+	errs = append(errs, clearSharedCache(cfg, scope))
+
+	return errors.Join(errs...)
+}
+
+// ClearCache removes the cached data in the requested scope for all profiles.  Only file-backed credential caches are
+// cleared, credentials in shared cache backends (like redis) are left alone.
+func ClearCache(cfg *config.AwsConfig, scope CacheScope) error {
+	entries, err := CacheEntries(cfg)
+	if err != nil {
+		return err
+	}
+
+	if scope&(CacheScopeCredentials|CacheScopeIdentityProvider) > 0 {
+		forgetJumpCredentials()
+	}
+
+	errs := make([]error, 0)
+	for _, e := range entries {
+		idp := e.Type == CacheTypeSaml || e.Type == CacheTypeWeb
+		if scope&CacheScopeCredentials > 0 || (idp && scope&CacheScopeIdentityProvider > 0) {
+			errs = append(errs, os.RemoveAll(e.Path))
+		}
+	}
+	errs = append(errs, clearSharedCache(cfg, scope))
+
+	return errors.Join(errs...)
+}
+
+// clearSharedCache removes the cached data shared across profiles, which is the identity token cache, SAML role cache,
+// OIDC provider cache, and cookie jar.
+func clearSharedCache(cfg *config.AwsConfig, scope CacheScope) error {
+	errs := make([]error, 0)
+
+	if scope&CacheScopeIdentityProvider > 0 {
+		errs = append(errs, sharedTokenCache(cfg).Clear())
+		if c := sharedSamlRoleCache(cfg); c != nil {
+			errs = append(errs, c.Clear())
+		}
+		if c := sharedOidcProviderCache(cfg); c != nil {
+			errs = append(errs, c.Clear())
+		}
+	}
+
+	if scope&CacheScopeCookies > 0 {
+		// RemoveAll handles single files too, but will not error if file not found
+		errs = append(errs, os.RemoveAll(cacheFilePath(cfg, cookieJarFile)))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+func TestClearCache(t *testing.T) {
+	files := []string{
+		".aws_assume_role_my-role",
+		".aws_assume_role_other-role",
+		".aws_session_token_default",
+		".aws_saml_role_123456789012-jump",
+		".aws_web_role_web",
+		cookieJarFile,
+		tokenCacheFile,
+	}
+
+	setup := func(t *testing.T) *config.AwsConfig {
+		t.Helper()
+		dir := t.TempDir()
+		for _, f := range files {
+			if err := os.WriteFile(filepath.Join(dir, f), []byte("{}"), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return &config.AwsConfig{CacheDir: dir}
+	}
+
+	remaining := func(t *testing.T, dir string) map[string]bool {
+		t.Helper()
+		m := make(map[string]bool)
+		for _, f := range files {
+			if _, err := os.Stat(filepath.Join(dir, f)); err == nil {
+				m[f] = true
+			}
+		}
+		return m
+	}
+
+	t.Run("profile credentials", func(t *testing.T) {
+		cfg := setup(t)
+		cfg.ProfileName = "my-role"
+		cfg.RoleArn = "arn:aws:iam::123456789012:role/my-role"
+
+		if err := NewClientFactory(new(mockResolver), DefaultOptions).ClearProfileCache(cfg, CacheScopeCredentials); err != nil {
+			t.Fatal(err)
+		}
+
+		m := remaining(t, cfg.CacheDir)
+		if m[".aws_assume_role_my-role"] || len(m) != len(files)-1 {
+			t.Errorf("unexpected files remaining: %v", m)
+		}
+	})
+
+	t.Run("profile identity provider", func(t *testing.T) {
+		cfg := setup(t)
+		cfg.ProfileName = "saml-role"
+		cfg.RoleArn = "arn:aws:iam::123456789012:role/my-role"
+		cfg.JumpRoleArn = "arn:aws:iam::123456789012:role/jump"
+
+		if err := NewClientFactory(new(mockResolver), DefaultOptions).ClearProfileCache(cfg, CacheScopeIdentityProvider); err != nil {
+			t.Fatal(err)
+		}
+
+		m := remaining(t, cfg.CacheDir)
+		if m[".aws_saml_role_123456789012-jump"] || m[tokenCacheFile] || !m[cookieJarFile] || len(m) != len(files)-2 {
+			t.Errorf("unexpected files remaining: %v", m)
+		}
+	})
+
+	t.Run("cookies", func(t *testing.T) {
+		cfg := setup(t)
+
+		if err := ClearCache(cfg, CacheScopeCookies); err != nil {
+			t.Fatal(err)
+		}
+
+		if m := remaining(t, cfg.CacheDir); m[cookieJarFile] || len(m) != len(files)-1 {
+			t.Errorf("unexpected files remaining: %v", m)
+		}
+	})
+
+	t.Run("all identity provider", func(t *testing.T) {
+		cfg := setup(t)
+
+		if err := ClearCache(cfg, CacheScopeIdentityProvider); err != nil {
+			t.Fatal(err)
+		}
+
+		m := remaining(t, cfg.CacheDir)
+		if m[".aws_saml_role_123456789012-jump"] || m[".aws_web_role_web"] || m[tokenCacheFile] || len(m) != len(files)-3 {
+			t.Errorf("unexpected files remaining: %v", m)
+		}
+	})
+
+	t.Run("all", func(t *testing.T) {
+		cfg := setup(t)
+
+		if err := ClearCache(cfg, CacheScopeAll); err != nil {
+			t.Fatal(err)
+		}
+
+		if m := remaining(t, cfg.CacheDir); len(m) > 0 {
+			t.Errorf("unexpected files remaining: %v", m)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+// ImportProfileCredentials stores credentials obtained outside of aws-runas in the credential cache used by the profile
+// in the provided configuration, so they are used until they expire.
+func (f *Factory) ImportProfileCredentials(cfg *config.AwsConfig, creds *credentials.Credentials) error {
+	if creds == nil || creds.Expiration.IsZero() {
+		return errors.New("invalid credentials, can not be nil or missing an expiration")
+	}
+
+	return f.credentialCache(cfg, profileCacheFile(cfg)).Store(creds)
+}
+
+// ImportSessionCookies stores identity provider session cookies obtained outside of aws-runas (like from a browser) in
+// the cookie jar used by the profile in the provided configuration, so the existing session is used instead of
+// authenticating with a password.  The cookies are set for each of the SAML or Web Identity urls of the profile, so
+// the session is also used with the failover endpoints.
+func (f *Factory) ImportSessionCookies(cfg *config.AwsConfig, cookies []*http.Cookie) error {
+	idpUrls := cfg.SamlUrls()
+	if len(idpUrls) < 1 {
+		idpUrls = cfg.WebIdentityUrls()
+	}
+
+	if len(idpUrls) < 1 {
+		return errors.New("profile is not configured for SAML or Web Identity authentication")
+	}
+
+	if len(cookies) < 1 {
+		return errors.New("no cookies to import")
+	}
+
+	jar := sharedCookieJar(cfg)
+	for _, idpUrl := range idpUrls {
+		u, err := url.Parse(idpUrl)
+		if err != nil {
+			return err
+		}
+		jar.SetCookies(u, cookies)
+	}
+	return nil
+}
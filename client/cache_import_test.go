@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+func TestFactory_ImportProfileCredentials(t *testing.T) {
+	creds := &credentials.Credentials{
+		AccessKeyId:     "AKIAMOCK",
+		SecretAccessKey: "MockSecret",
+		Token:           "MockToken",
+		Expiration:      time.Now().Add(1 * time.Hour),
+	}
+
+	tests := []struct {
+		name, file string
+		cfg        *config.AwsConfig
+	}{
+		{"saml", ".aws_saml_role_saml", &config.AwsConfig{ProfileName: "saml", SamlUrl: "https://example.org/saml",
+			RoleArn: "arn:aws:iam::123456789012:role/saml"}},
+		{"jump role", ".aws_assume_role_jump", &config.AwsConfig{ProfileName: "jump", SamlUrl: "https://example.org/saml",
+			JumpRoleArn: "arn:aws:iam::123456789012:role/jump", RoleArn: "arn:aws:iam::123456789012:role/target"}},
+		{"iam role", ".aws_assume_role_role", &config.AwsConfig{ProfileName: "role",
+			RoleArn: "arn:aws:iam::123456789012:role/role"}},
+		{"session", ".aws_session_token_default", &config.AwsConfig{ProfileName: "default"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.CacheDir = t.TempDir()
+
+			if err := NewClientFactory(new(mockResolver), DefaultOptions).ImportProfileCredentials(tc.cfg, creds); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if _, err := os.Stat(filepath.Join(tc.cfg.CacheDir, tc.file)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	t.Run("no expiration", func(t *testing.T) {
+		cfg := &config.AwsConfig{ProfileName: "role", CacheDir: t.TempDir()}
+		if err := NewClientFactory(new(mockResolver), DefaultOptions).ImportProfileCredentials(cfg, new(credentials.Credentials)); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestFactory_ImportSessionCookies(t *testing.T) {
+	cookie := &http.Cookie{Name: "KEYCLOAK_IDENTITY", Value: "mock", Path: "/realms/mock/"}
+
+	t.Run("saml", func(t *testing.T) {
+		cfg := &config.AwsConfig{ProfileName: "saml", SamlUrl: "https://example.org/realms/mock/protocol/saml/clients/aws",
+			CacheDir: t.TempDir()}
+
+		if err := NewClientFactory(new(mockResolver), DefaultOptions).ImportSessionCookies(cfg, []*http.Cookie{cookie}); err != nil {
+			t.Fatal(err)
+		}
+
+		u, _ := url.Parse(cfg.SamlUrl)
+		if c := sharedCookieJar(cfg).Cookies(u); len(c) != 1 || c[0].Value != "mock" {
+			t.Errorf("cookie not imported: %v", c)
+		}
+
+		if _, err := os.Stat(filepath.Join(cfg.CacheDir, cookieJarFile)); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("failover urls", func(t *testing.T) {
+		cfg := &config.AwsConfig{ProfileName: "saml", CacheDir: t.TempDir(),
+			SamlUrl: "https://a.example.org/realms/mock/protocol/saml/clients/aws, https://b.example.org/realms/mock/protocol/saml/clients/aws"}
+
+		if err := NewClientFactory(new(mockResolver), DefaultOptions).ImportSessionCookies(cfg, []*http.Cookie{cookie}); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, v := range cfg.SamlUrls() {
+			u, _ := url.Parse(v)
+			if c := sharedCookieJar(cfg).Cookies(u); len(c) != 1 || c[0].Value != "mock" {
+				t.Errorf("cookie not imported for %s: %v", v, c)
+			}
+		}
+	})
+
+	t.Run("no idp", func(t *testing.T) {
+		cfg := &config.AwsConfig{ProfileName: "role", CacheDir: t.TempDir()}
+		if err := NewClientFactory(new(mockResolver), DefaultOptions).ImportSessionCookies(cfg, []*http.Cookie{cookie}); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("no cookies", func(t *testing.T) {
+		cfg := &config.AwsConfig{ProfileName: "oidc", WebIdentityUrl: "https://example.org/realms/mock", CacheDir: t.TempDir()}
+		if err := NewClientFactory(new(mockResolver), DefaultOptions).ImportSessionCookies(cfg, nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
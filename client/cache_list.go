@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials/cache"
+)
+
+// Credential cache types reported in a CacheEntry.
+const (
+	CacheTypeSession = "session"
+	CacheTypeRole    = "role"
+	CacheTypeSaml    = "saml"
+	CacheTypeWeb     = "web"
+)
+
+var cacheTypes = map[string]string{
+	sessionCachePrefix: CacheTypeSession,
+	roleCachePrefix:    CacheTypeRole,
+	samlCachePrefix:    CacheTypeSaml,
+	webCachePrefix:     CacheTypeWeb,
+}
+
+// CacheEntry describes a file-backed credential cache.  Profile is the name of the profile which owns the credentials,
+// or a name in the form of <account>-<role name> for credentials cached for a role ARN (like jump roles).
+type CacheEntry struct {
+	Type       string    `json:"type"`
+	Profile    string    `json:"profile"`
+	Path       string    `json:"path"`
+	Expiration time.Time `json:"expiration"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Expired returns true if the cache entry does not hold valid credentials.
+func (e *CacheEntry) Expired() bool {
+	return len(e.Error) > 0 || time.Now().After(e.Expiration)
+}
+
+// CacheEntries finds all credential cache files in the cache directories used for the provided configuration.  Only
+// file-backed caches are found, credentials in shared cache backends (like redis) are not enumerated.  Files which can
+// not be read are still returned, with the Error field set.
+func CacheEntries(cfg *config.AwsConfig) ([]*CacheEntry, error) {
+	entries := make([]*CacheEntry, 0)
+
+	for _, dir := range cacheDirs(cfg) {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, f := range files {
+			e := parseCacheFile(f.Name())
+			if e == nil || !f.Type().IsRegular() {
+				continue
+			}
+			e.Path = filepath.Join(dir, f.Name())
+
+			creds, err := cache.ReadCredentialFile(e.Path)
+			if err != nil {
+				e.Error = err.Error()
+			} else {
+				e.Expiration = creds.Expiration
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Profile == entries[j].Profile {
+			return entries[i].Type < entries[j].Type
+		}
+		return entries[i].Profile < entries[j].Profile
+	})
+	return entries, nil
+}
+
+// cacheDirs returns the directories which may contain credential cache files.  Without an explicitly configured cache
+// directory, files may be found in both the legacy location and the platform cache directory.
+func cacheDirs(cfg *config.AwsConfig) []string {
+	if hasCacheDir(cfg) {
+		return []string{cacheDir(cfg)}
+	}
+
+	dirs := []string{cachePath()}
+	if dir, err := userCacheDir(); err == nil && dir != dirs[0] {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// parseCacheFile returns a CacheEntry if the file name is a credential cache file, or nil.  Temporary files created
+// while storing credentials, and quarantined corrupt files are skipped.
+func parseCacheFile(name string) *CacheEntry {
+	if strings.HasSuffix(name, ".tmp") || strings.HasSuffix(name, ".corrupt") {
+		return nil
+	}
+
+	for prefix, t := range cacheTypes {
+		for _, p := range []string{prefix, strings.TrimPrefix(prefix, ".")} {
+			if profile, ok := strings.CutPrefix(name, p+"_"); ok && len(profile) > 0 {
+				return &CacheEntry{Type: t, Profile: profile}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/credentials/cache"
+)
+
+func TestCacheEntries(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.AwsConfig{CacheDir: dir}
+
+	good := &credentials.Credentials{
+		AccessKeyId:     "AKIAM0CK",
+		SecretAccessKey: "secretKey",
+		Expiration:      time.Now().Add(1 * time.Hour),
+	}
+	if err := cache.NewFileCredentialCache(cacheFileName(cfg, roleCachePrefix, "my-role", "")).Store(good); err != nil {
+		t.Fatal(err)
+	}
+
+	expired := &credentials.Credentials{
+		AccessKeyId:     "AKIAM0CK",
+		SecretAccessKey: "secretKey",
+		Expiration:      time.Now().Add(-1 * time.Hour),
+	}
+	if err := cache.NewFileCredentialCache(cacheFileName(cfg, sessionCachePrefix, "default", "")).Store(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		samlCachePrefix + "_123456789012-jump":   "bogus",
+		roleCachePrefix + "_my-role.corrupt":     "bogus",
+		roleCachePrefix + "_my-role_1234.tmp":    "bogus",
+		cookieJarFile:                            "{}",
+		"aws_web_role_web":                       "bogus",
+		webCachePrefix + "Missing_underscore_me": "bogus",
+	}
+	for k, v := range files {
+		if err := os.WriteFile(filepath.Join(dir, k), []byte(v), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := CacheEntries(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		profile, kind string
+		expired       bool
+	}{
+		{"123456789012-jump", CacheTypeSaml, true},
+		{"default", CacheTypeSession, true},
+		{"my-role", CacheTypeRole, false},
+		{"web", CacheTypeWeb, true},
+	}
+
+	if len(entries) != len(expected) {
+		t.Fatalf("unexpected entries: %d", len(entries))
+	}
+
+	for i, e := range expected {
+		t.Run(e.profile, func(t *testing.T) {
+			got := entries[i]
+			if got.Profile != e.profile || got.Type != e.kind || got.Expired() != e.expired {
+				t.Errorf("unexpected entry: %+v", got)
+			}
+		})
+	}
+
+	if len(entries[0].Error) < 1 {
+		t.Error("expected error for corrupt cache file")
+	}
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/credentials/cache"
+)
+
+// CachedCredentials returns the credentials in the local cache file used by the profile in the provided configuration.
+// AWS, the identity provider, and any shared cache backend (like redis) are not contacted, so this is fast, and never
+// prompts for input.  An error is returned if there are no cached credentials for the profile, the returned credentials
+// may be expired.
+func CachedCredentials(cfg *config.AwsConfig) (*credentials.Credentials, error) {
+	c := *cfg
+	if arn.IsARN(c.ProfileName) {
+		c.RoleArn = c.ProfileName
+		c.ProfileName = ""
+	}
+
+	return cache.ReadCredentialFile(profileCacheFile(&c))
+}
+
+// profileCacheFile returns the path of the cache file holding the credentials used by the profile in the configuration.
+func profileCacheFile(cfg *config.AwsConfig) string {
+	prefix := roleCachePrefix
+	switch {
+	case len(cfg.JumpRoleArn) > 0:
+		// the role credentials are cached separately from the jump role credentials
+	case len(cfg.SamlUrl) > 0 || len(cfg.SamlMetadataUrl) > 0:
+		// the SAML URL of a metadata profile may not be loaded yet, but it still uses a SAML client
+		prefix = samlCachePrefix
+	case len(cfg.WebIdentityUrl) > 0:
+		prefix = webCachePrefix
+	case len(cfg.RoleArn) < 1:
+		prefix = sessionCachePrefix
+	}
+
+	return cacheFileName(cfg, prefix, cfg.ProfileName, cfg.RoleArn)
+}
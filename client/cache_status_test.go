@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+func TestCachedCredentials(t *testing.T) {
+	creds := &credentials.Credentials{
+		AccessKeyId:     "AKIAMOCK",
+		SecretAccessKey: "MockSecret",
+		Token:           "MockToken",
+		Expiration:      time.Now().Add(1 * time.Hour).Round(time.Second),
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		cfg := &config.AwsConfig{ProfileName: "role", RoleArn: "arn:aws:iam::123456789012:role/role", CacheDir: t.TempDir()}
+		if err := NewClientFactory(new(mockResolver), DefaultOptions).ImportProfileCredentials(cfg, creds); err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := CachedCredentials(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.AccessKeyId != creds.AccessKeyId || !c.Expiration.Equal(creds.Expiration) {
+			t.Errorf("data mismatch: %+v", c)
+		}
+	})
+
+	t.Run("role arn profile", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := &config.AwsConfig{RoleArn: "arn:aws:iam::123456789012:role/role", CacheDir: dir}
+		if err := NewClientFactory(new(mockResolver), DefaultOptions).ImportProfileCredentials(cfg, creds); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := CachedCredentials(&config.AwsConfig{ProfileName: cfg.RoleArn, CacheDir: dir}); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("saml metadata profile", func(t *testing.T) {
+		cfg := &config.AwsConfig{ProfileName: "saml", SamlMetadataUrl: "https://idp.local/metadata", CacheDir: t.TempDir()}
+		if f := filepath.Base(profileCacheFile(cfg)); !strings.HasPrefix(f, samlCachePrefix) {
+			t.Errorf("unexpected cache file: %s", f)
+		}
+	})
+
+	t.Run("not cached", func(t *testing.T) {
+		if _, err := CachedCredentials(&config.AwsConfig{ProfileName: "role", CacheDir: t.TempDir()}); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
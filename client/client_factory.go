@@ -15,14 +15,21 @@ package client
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/processcreds"
 	"github.com/aws/smithy-go/logging"
 
 	"github.com/mmmorris1975/aws-runas/client/external"
@@ -30,15 +37,35 @@ import (
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"github.com/mmmorris1975/aws-runas/credentials/cache"
 	"github.com/mmmorris1975/aws-runas/credentials/helpers"
+	"github.com/mmmorris1975/aws-runas/shared"
 )
 
-// singleton cookie jar implementation.
-var cookieJar = cache.CookieJar(filepath.Join(cachePath(), ".aws_runas.cookies"))
+const (
+	cookieJarFile     = ".aws_runas.cookies"
+	loginThrottleFile = ".aws_runas_login.state"
+	tokenCacheFile    = ".aws_runas_identity_token.cache"
+	samlRoleCacheFile = ".aws_runas_saml_roles.cache"
+	oidcCacheFile     = ".aws_runas_oidc_provider.cache"
+
+	sessionCachePrefix = ".aws_session_token"
+	roleCachePrefix    = ".aws_assume_role"
+	samlCachePrefix    = ".aws_saml_role"
+	webCachePrefix     = ".aws_web_role"
+)
+
+// login throttles are singletons per state file, shared by all clients so failed password attempts are tracked
+// across profiles.
+var (
+	loginThrottles   = make(map[string]external.LoginThrottler)
+	loginThrottlesMu sync.Mutex
+)
 
 // Factory holds the configuration and options necessary of obtaining an AwsClient used to retrieve credentials.
 type Factory struct {
 	resolver config.Resolver
 	options  *Options
+	// forced holds the cache files (and other cached state) already force-refreshed by the factory
+	forced sync.Map
 }
 
 // NewClientFactory uses the provides Resolver to determine an appropriate AwsClient for retrieving credential and
@@ -57,15 +84,60 @@ func NewClientFactory(res config.Resolver, opts *Options) *Factory {
 // If neither of those is set, it will check the value of the RoleArn config attribute, and if set, will return an
 // Assume Role client using IAM credentials. If non of the above situations apply, a client to fetch Session Token
 // credentials using IAM credentials will be returned.
+//
+// If the configuration sets ExpectedAccountId, the returned client will return an error instead of credentials for any
+// other account.  If the factory options include Hooks, the returned client will call them as credentials are retrieved.
+// If the IdentityDetails option is set, the identity returned by the client includes the AWS caller details.
+//
+// If the Offline option is set, the returned client only provides the credentials found in the local cache for the
+// profile, and returns ErrOffline for anything requiring network access.
 func (f *Factory) Get(cfg *config.AwsConfig) (AwsClient, error) {
+	var cl AwsClient
+	var err error
+
+	if f.options.Offline {
+		if cfg == nil {
+			return nil, errors.New("invalid configuration")
+		}
+		cl = newOfflineClient(cfg)
+	} else if cl, err = f.get(cfg); err != nil {
+		return cl, err
+	}
+
+	if len(cfg.ExpectedAccountId) > 0 {
+		cl = newAccountGuardClient(cl, cfg.ExpectedAccountId, cfg.RoleArn)
+	}
+
+	if f.options.IdentityDetails && !f.options.Offline {
+		cl = newIdentityDetailsClient(cl)
+	}
+
+	if len(f.options.AuditLog) > 0 {
+		cl = newAuditClient(cl, cfg, f.options.AuditLog, f.options.Logger)
+	}
+
+	if f.options.Hooks != nil {
+		cl = newHookClient(cl, f.options.Hooks)
+	}
+	return cl, nil
+}
+
+func (f *Factory) get(cfg *config.AwsConfig) (AwsClient, error) {
 	if cfg == nil {
 		return nil, errors.New("invalid configuration")
 	}
 
+	if err := ApplySamlMetadata(context.Background(), cfg, f.options); err != nil {
+		return nil, err
+	}
+
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
 
+	// report problems found with cache files using our logger
+	cache.SetLogger(f.options.Logger)
+
 	if arn.IsARN(cfg.ProfileName) {
 		cfg.RoleArn = cfg.ProfileName
 		cfg.ProfileName = ""
@@ -73,13 +145,15 @@ func (f *Factory) Get(cfg *config.AwsConfig) (AwsClient, error) {
 
 	var logFunc logging.LoggerFunc = func(c logging.Classification, fmt string, v ...any) {
 		if f.options.Logger != nil {
+			// AWS SDK request and response logging includes signatures, tokens, and cookies
+			l := shared.NewRedactingLogger(f.options.Logger)
 			switch c {
 			case logging.Warn:
-				f.options.Logger.Warningf(fmt, v)
+				l.Warningf(fmt, v...)
 			case logging.Debug:
-				f.options.Logger.Debugf(fmt, v)
+				l.Debugf(fmt, v...)
 			default:
-				f.options.Logger.Infof(fmt, v)
+				l.Infof(fmt, v...)
 			}
 		}
 	}
@@ -91,28 +165,37 @@ func (f *Factory) Get(cfg *config.AwsConfig) (AwsClient, error) {
 		awsconfig.WithLogConfigurationWarnings(true),
 	}
 
+	if len(cfg.BaseCredentialProcess) > 0 {
+		// the process output replaces the IAM credentials which would be found in the credentials file
+		f.options.Logger.Debugf("using credential process for base credentials")
+		p := processcreds.NewProvider(cfg.BaseCredentialProcess)
+		opts = append(opts, awsconfig.WithCredentialsProvider(aws.NewCredentialsCache(p)))
+	}
+
 	f.options.Logger.Debugf("CLIENT CONFIG: %+v", cfg)
 
-	if len(cfg.SamlUrl) > 0 {
-		creds, err := f.resolver.Credentials(cfg.SamlUrl)
+	// the first of the identity provider URLs is the primary endpoint, which identifies the identity provider for
+	// things like stored passwords; any others are only used for failover
+	if urls := cfg.SamlUrls(); len(urls) > 0 {
+		creds, err := f.resolver.Credentials(urls[0])
 		if err != nil {
 			// non-fatal error, just set empty creds
 			creds = new(config.AwsCredentials)
 		}
 		creds.MergeIn(f.options.CommandCredentials)
 
-		return f.samlClient(cfg, creds, opts...)
+		return f.samlClient(cfg, urls, creds, opts...)
 	}
 
-	if len(cfg.WebIdentityUrl) > 0 {
-		creds, err := f.resolver.Credentials(cfg.WebIdentityUrl)
+	if urls := cfg.WebIdentityUrls(); len(urls) > 0 {
+		creds, err := f.resolver.Credentials(urls[0])
 		if err != nil {
 			// non-fatal error, just set empty creds
 			creds = new(config.AwsCredentials)
 		}
 		creds.MergeIn(f.options.CommandCredentials)
 
-		return f.webClient(cfg, creds, opts...)
+		return f.webClient(cfg, urls, creds, opts...)
 	}
 
 	if len(cfg.RoleArn) > 0 {
@@ -123,36 +206,59 @@ func (f *Factory) Get(cfg *config.AwsConfig) (AwsClient, error) {
 }
 
 //nolint:funlen
-func (f *Factory) samlClient(cfg *config.AwsConfig, creds *config.AwsCredentials, opts ...func(*awsconfig.LoadOptions) error) (AwsClient, error) {
+func (f *Factory) samlClient(cfg *config.AwsConfig, urls []string, creds *config.AwsCredentials, opts ...func(*awsconfig.LoadOptions) error) (AwsClient, error) {
 	logger := f.options.Logger
 	logger.Debugf("configuring SAML client")
 
+	// the IdP login URL may carry parameters selecting the relying party, the bare URL identifies the IdP
+	loginUrl := cfg.SamlLoginUrls()[0]
+
+	mfaProvider, mfaType, err := f.mfaProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	samlCfg := &SamlRoleClientConfig{
 		AuthenticationClientConfig: external.AuthenticationClientConfig{
 			Username:                cfg.SamlUsername,
-			Password:                f.decodePassword(cfg.SamlUrl, creds.SamlPassword),
+			Password:                f.decodePassword(urls[0], creds.SamlPassword),
 			MfaTokenCode:            cfg.MfaCode,
-			MfaTokenProvider:        f.options.MfaInputProvider,
-			MfaType:                 cfg.MfaType,
+			MfaTokenProvider:        mfaProvider,
+			MfaType:                 mfaType,
 			CredentialInputProvider: f.options.CredentialInputProvider,
 			IdentityProviderName:    cfg.SamlProvider,
 			FederatedUsername:       cfg.FederatedUsername,
 			Logger:                  logger,
 			AuthBrowser:             cfg.AuthBrowser,
 			SamlEntityId:            cfg.SamlEntityId,
+			NewPasswordProvider:     f.options.PasswordChangeProvider,
+			PasswordUpdater:         f.passwordUpdater(urls[0], "saml_password", creds.SamlPassword),
 		},
-		Duration: cfg.RoleCredentialDuration(),
-		RoleArn:  cfg.RoleArn,
+		Duration:       cfg.RoleCredentialDuration(),
+		RoleArn:        cfg.RoleArn,
+		PreferredRoles: cfg.PreferredRoleList(),
+		StsRetryer:     stsRetryer(cfg),
+
+		RoleSelectionProvider: f.options.RoleSelectionProvider,
+		ProfileName:           cfg.ProfileName,
+		// the users of a multi-user service have their own cache, and must never get another user's assertion
+		SessionScope: cfg.CacheDir + " " + f.options.CacheKeyScope,
+	}
+
+	if len(samlCfg.IdentityProviderName) < 1 && len(urls) > 1 {
+		// detect the provider using the failover endpoints too, in case the primary endpoint is down
+		samlCfg.IdentityProviderName = external.DetectProvider(urls...)
 	}
 
 	if f.options.EnableCache {
-		cacheFile := cacheFileName(".aws_saml_role", cfg.ProfileName, cfg.RoleArn)
-		samlCfg.Cache = cache.NewFileCredentialCache(cacheFile)
+		cacheFile := cacheFileName(cfg, samlCachePrefix, cfg.ProfileName, cfg.RoleArn)
+		samlCfg.Cache = f.clientCache(cfg, cacheFile)
+		samlCfg.RoleCache = f.samlRoleCache(cfg)
 	}
 
 	// unset opts.Profile, since there's nothing we need it for in the config/credentials files past here
 	opts = append(opts, awsconfig.WithSharedConfigProfile(""))
-	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	awsCfg, err := f.loadAwsConfig(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -160,23 +266,27 @@ func (f *Factory) samlClient(cfg *config.AwsConfig, creds *config.AwsCredentials
 	if len(cfg.JumpRoleArn) > 0 {
 		var roleCache credentials.CredentialCacher
 		samlCfg.RoleArn = cfg.JumpRoleArn
+		jumpFile := cacheFileName(cfg, samlCachePrefix, "", cfg.JumpRoleArn)
 		// return role client configured with saml creds
 		if f.options.EnableCache {
-			samlCfg.Cache = cache.NewFileCredentialCache(cacheFileName(".aws_saml_role", "", cfg.JumpRoleArn))
-			roleCache = cache.NewFileCredentialCache(cacheFileName(".aws_assume_role", cfg.ProfileName, cfg.RoleArn))
+			samlCfg.Cache = f.clientCache(cfg, jumpFile)
+			roleCache = f.clientCache(cfg, cacheFileName(cfg, roleCachePrefix, cfg.ProfileName, cfg.RoleArn))
 		}
 
 		logger.Debugf("jump role found, configuring SAML client as base client")
-		baseCl := NewSamlRoleClient(awsCfg, cfg.SamlUrl, samlCfg)
-		baseCl.samlClient.SetCookieJar(cookieJar)
+		baseCl := NewSamlRoleClient(awsCfg, loginUrl, samlCfg)
+		baseCl.samlClient.SetCookieJar(f.cookieJar(cfg))
+		baseCl.samlClient.SetTransport(f.transport(urls...))
+		baseCl.samlClient.SetLoginThrottler(sharedLoginThrottle(cfg))
 
-		awsCfg.Credentials = baseCl.roleProvider
+		// profiles sharing the jump role share its credentials, instead of each assuming the jump role
+		awsCfg.Credentials = sharedJumpCredentials(jumpCredentialsKey(jumpFile, loginUrl, cfg.SamlUsername), baseCl)
 
 		// if we don't have an explicit RoleSessionName set, NewAssumeRoleClient() will try calling
 		// sts.GetCallerIdentity() to find the user name associated with the SAML client, which
 		// means we should have valid AWS credentials loaded (we don't need the value here)
 		if len(cfg.RoleSessionName) < 2 {
-			_, err = baseCl.Credentials()
+			_, err = awsCfg.Credentials.Retrieve(context.Background())
 			if err != nil {
 				return nil, err
 			}
@@ -185,9 +295,10 @@ func (f *Factory) samlClient(cfg *config.AwsConfig, creds *config.AwsCredentials
 		// use assume role client configured with saml creds for role chaining
 		roleCfg := &AssumeRoleClientConfig{
 			SessionTokenClientConfig: SessionTokenClientConfig{
-				Logger:   f.options.Logger,
-				Cache:    roleCache,
-				Duration: credentials.AssumeRoleDurationDefault, // AWS limits chained creds max duration to 1 hr
+				Logger:     f.options.Logger,
+				Cache:      roleCache,
+				Duration:   credentials.AssumeRoleDurationDefault, // AWS limits chained creds max duration to 1 hr
+				StsRetryer: stsRetryer(cfg),
 			},
 			RoleArn:         cfg.RoleArn,
 			RoleSessionName: cfg.RoleSessionName,
@@ -201,43 +312,62 @@ func (f *Factory) samlClient(cfg *config.AwsConfig, creds *config.AwsCredentials
 	}
 
 	logger.Debugf("no jump role found, only configuring SAML client")
-	cl := NewSamlRoleClient(awsCfg, cfg.SamlUrl, samlCfg)
-	cl.samlClient.SetCookieJar(cookieJar)
+	cl := NewSamlRoleClient(awsCfg, loginUrl, samlCfg)
+	cl.samlClient.SetCookieJar(f.cookieJar(cfg))
+	cl.samlClient.SetTransport(f.transport(urls...))
+	cl.samlClient.SetLoginThrottler(sharedLoginThrottle(cfg))
 	return cl, nil
 }
 
 //nolint:funlen
-func (f *Factory) webClient(cfg *config.AwsConfig, creds *config.AwsCredentials, opts ...func(*awsconfig.LoadOptions) error) (AwsClient, error) {
+func (f *Factory) webClient(cfg *config.AwsConfig, urls []string, creds *config.AwsCredentials, opts ...func(*awsconfig.LoadOptions) error) (AwsClient, error) {
 	logger := f.options.Logger
 	logger.Debugf("configuring Web Identity client")
 
+	mfaProvider, mfaType, err := f.mfaProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	webCfg := &WebRoleClientConfig{
 		OidcClientConfig: external.OidcClientConfig{AuthenticationClientConfig: external.AuthenticationClientConfig{}},
 	}
 	webCfg.RoleArn = cfg.RoleArn
 	webCfg.Duration = cfg.RoleCredentialDuration()
-	webCfg.MfaType = cfg.MfaType
+	webCfg.MfaType = mfaType
 	webCfg.MfaTokenCode = cfg.MfaCode
-	webCfg.MfaTokenProvider = f.options.MfaInputProvider
+	webCfg.MfaTokenProvider = mfaProvider
 	webCfg.CredentialInputProvider = f.options.CredentialInputProvider
 	webCfg.Username = cfg.WebIdentityUsername
-	webCfg.Password = f.decodePassword(cfg.WebIdentityUrl, creds.WebIdentityPassword)
+	webCfg.Password = f.decodePassword(urls[0], creds.WebIdentityPassword)
+	webCfg.NewPasswordProvider = f.options.PasswordChangeProvider
+	webCfg.PasswordUpdater = f.passwordUpdater(urls[0], "web_identity_password", creds.WebIdentityPassword)
 	webCfg.FederatedUsername = cfg.FederatedUsername
 	webCfg.ClientId = cfg.WebIdentityClientId
 	webCfg.RedirectUri = cfg.WebIdentityRedirectUri
+	webCfg.SubjectTokenFile = cfg.SubjectTokenFile
+	webCfg.Audience = cfg.WebIdentityAudience
+	webCfg.PkceMode = cfg.WebIdentityPkce
 	webCfg.IdentityProviderName = cfg.WebIdentityProvider
+	if len(webCfg.IdentityProviderName) < 1 && len(urls) > 1 {
+		// detect the provider using the failover endpoints too, in case the primary endpoint is down
+		webCfg.IdentityProviderName = external.DetectProvider(urls...)
+	}
 	webCfg.WebIdentityTokenFile = cfg.WebIdentityTokenFile
+	webCfg.StsRetryer = stsRetryer(cfg)
+	webCfg.TokenCache = f.tokenCache(cfg)
+	webCfg.OidcProviderCache = f.oidcProviderCache(cfg)
 	webCfg.Scopes = nil // not supported yet
 	webCfg.Logger = logger
 
-	cacheFile := cacheFileName(".aws_web_role", cfg.ProfileName, cfg.RoleArn)
+	cacheFile := cacheFileName(cfg, webCachePrefix, cfg.ProfileName, cfg.RoleArn)
 	if f.options.EnableCache {
-		webCfg.Cache = cache.NewFileCredentialCache(cacheFile)
+		webCfg.Cache = f.webCache(cfg, cacheFile)
 	}
 
 	// unset opts.Profile, since there's nothing we need it for in the config/credentials files past here
 	opts = append(opts, awsconfig.WithSharedConfigProfile(""))
-	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	awsCfg, err := f.loadAwsConfig(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -245,23 +375,27 @@ func (f *Factory) webClient(cfg *config.AwsConfig, creds *config.AwsCredentials,
 	if len(cfg.JumpRoleArn) > 0 {
 		var roleCache credentials.CredentialCacher
 		webCfg.RoleArn = cfg.JumpRoleArn
+		jumpFile := cacheFileName(cfg, webCachePrefix, "", cfg.JumpRoleArn)
 
 		if f.options.EnableCache {
-			webCfg.Cache = cache.NewFileCredentialCache(cacheFileName(".aws_web_role", "", cfg.JumpRoleArn))
-			roleCache = cache.NewFileCredentialCache(cacheFileName(".aws_assume_role", cfg.ProfileName, cfg.RoleArn))
+			webCfg.Cache = f.webCache(cfg, jumpFile)
+			roleCache = f.webCache(cfg, cacheFileName(cfg, roleCachePrefix, cfg.ProfileName, cfg.RoleArn))
 		}
 
 		logger.Debugf("jump role found, configuring Web Identity client as base client")
-		baseCl := NewWebRoleClient(awsCfg, cfg.WebIdentityUrl, webCfg)
-		baseCl.webClient.SetCookieJar(cookieJar)
+		baseCl := NewWebRoleClient(awsCfg, urls[0], webCfg)
+		baseCl.webClient.SetCookieJar(f.cookieJar(cfg))
+		baseCl.webClient.SetTransport(f.transport(urls...))
+		baseCl.webClient.SetLoginThrottler(sharedLoginThrottle(cfg))
 
-		awsCfg.Credentials = baseCl.roleProvider
+		// profiles sharing the jump role share its credentials, instead of each assuming the jump role
+		awsCfg.Credentials = sharedJumpCredentials(jumpCredentialsKey(jumpFile, urls[0], cfg.WebIdentityUsername), baseCl)
 
 		// if we don't have an explicit RoleSessionName set, NewAssumeRoleClient() will try calling
 		// sts.GetCallerIdentity() to find the user name associated with the SAML client, which
 		// means we should have valid AWS credentials loaded (we don't need the value here)
 		if len(cfg.RoleSessionName) < 2 {
-			_, err = baseCl.Credentials()
+			_, err = awsCfg.Credentials.Retrieve(context.Background())
 			if err != nil {
 				return nil, err
 			}
@@ -270,9 +404,10 @@ func (f *Factory) webClient(cfg *config.AwsConfig, creds *config.AwsCredentials,
 		// use assume role client configured with web identity (oidc) creds for role chaining
 		roleCfg := &AssumeRoleClientConfig{
 			SessionTokenClientConfig: SessionTokenClientConfig{
-				Logger:   f.options.Logger,
-				Cache:    roleCache,
-				Duration: credentials.AssumeRoleDurationDefault, // AWS limits chained creds max duration to 1 hr
+				Logger:     f.options.Logger,
+				Cache:      roleCache,
+				Duration:   credentials.AssumeRoleDurationDefault, // AWS limits chained creds max duration to 1 hr
+				StsRetryer: stsRetryer(cfg),
 			},
 			RoleArn:         cfg.RoleArn,
 			RoleSessionName: cfg.RoleSessionName,
@@ -286,8 +421,10 @@ func (f *Factory) webClient(cfg *config.AwsConfig, creds *config.AwsCredentials,
 	}
 
 	logger.Debugf("no jump role found, only configuring Web Identity client")
-	cl := NewWebRoleClient(awsCfg, cfg.WebIdentityUrl, webCfg)
-	cl.webClient.SetCookieJar(cookieJar)
+	cl := NewWebRoleClient(awsCfg, urls[0], webCfg)
+	cl.webClient.SetCookieJar(f.cookieJar(cfg))
+	cl.webClient.SetTransport(f.transport(urls...))
+	cl.webClient.SetLoginThrottler(sharedLoginThrottle(cfg))
 	return cl, nil
 }
 
@@ -295,22 +432,35 @@ func (f *Factory) roleClient(cfg *config.AwsConfig, opts ...func(*awsconfig.Load
 	logger := f.options.Logger
 	logger.Debugf("configuring Assume Role client")
 
+	mfaProvider, _, err := f.mfaProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	roleCfg := &AssumeRoleClientConfig{
 		SessionTokenClientConfig: SessionTokenClientConfig{
 			Duration:      cfg.RoleCredentialDuration(),
 			SerialNumber:  cfg.MfaSerial,
 			TokenCode:     cfg.MfaCode,
-			TokenProvider: f.options.MfaInputProvider,
+			TokenProvider: mfaProvider,
 			Logger:        logger,
+			StsRetryer:    stsRetryer(cfg),
 		},
 		RoleArn:         cfg.RoleArn,
 		RoleSessionName: cfg.RoleSessionName,
 		ExternalId:      cfg.ExternalId,
 	}
 
+	if len(cfg.RoleMfaSerial) > 0 {
+		// the role requires its own MFA device, the MFA_CODE env var (if any) belongs to the mfa_serial device
+		roleCfg.SerialNumber = cfg.RoleMfaSerial
+		roleCfg.TokenCode = ""
+		roleCfg.TokenProvider = f.roleMfaInputProvider(mfaProvider, cfg.RoleMfaSerial)
+	}
+
 	if f.options.EnableCache {
-		cacheFile := cacheFileName(".aws_assume_role", cfg.ProfileName, cfg.RoleArn)
-		roleCfg.Cache = cache.NewFileCredentialCache(cacheFile)
+		cacheFile := cacheFileName(cfg, roleCachePrefix, cfg.ProfileName, cfg.RoleArn)
+		roleCfg.Cache = f.clientCache(cfg, cacheFile)
 	}
 
 	if len(cfg.SrcProfile) > 0 {
@@ -318,15 +468,22 @@ func (f *Factory) roleClient(cfg *config.AwsConfig, opts ...func(*awsconfig.Load
 		opts = append(opts, awsconfig.WithSharedConfigProfile(cfg.SrcProfile))
 	}
 
-	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	awsCfg, err := f.loadAwsConfig(opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	if cfg.RoleCredentialDuration() <= credentials.AssumeRoleDurationDefault {
+	// credentials from a credential process may already be temporary credentials, which can't be used to get a
+	// session token, so assume the role directly using the process credentials.  Roles whose trust policy checks MFA
+	// on the assume role call itself are also assumed directly, so the MFA code is sent with the AssumeRole request
+	if cfg.RoleCredentialDuration() <= credentials.AssumeRoleDurationDefault && len(cfg.BaseCredentialProcess) < 1 &&
+		!cfg.MfaOnAssumeRole() {
 		logger.Debugf("detected default or lower role credential duration, using session token credentials")
-		// unset MFA Serial Number, it's now the concern of the Session Token client
-		roleCfg.SerialNumber = ""
+		// unset MFA Serial Number, it's now the concern of the Session Token client, unless the role requires
+		// a separate MFA device, in which case both steps will prompt for their own MFA code
+		if len(cfg.RoleMfaSerial) < 1 {
+			roleCfg.SerialNumber = ""
+		}
 
 		// configure role client to use session credentials to fetch role credentials and identity
 		var sc *sessionTokenClient
@@ -344,28 +501,64 @@ func (f *Factory) roleClient(cfg *config.AwsConfig, opts ...func(*awsconfig.Load
 	return NewAssumeRoleClient(awsCfg, roleCfg), nil
 }
 
+// sessionCacheProfile returns the profile name used for the session token credential cache of the profile.  Session
+// token credentials are shared by all profiles using the same source profile, unless the profile uses a different MFA
+// device than the source profile.  Credentials obtained without MFA (or with another device) by the source profile
+// would be missing the MFA context required by the trust policy of the profile's role, so the profile gets its own.
+func sessionCacheProfile(cfg *config.AwsConfig) string {
+	if sp := cfg.SourceProfile(); sp != nil && sp.MfaSerial == cfg.MfaSerial {
+		return sp.ProfileName
+	}
+	return cfg.ProfileName
+}
+
+// webCache returns the credential cache for a Web Identity client, or a client using its credentials.  If the identity
+// token is read from a web identity token file, the cached credentials are only used with the token they came from.
+func (f *Factory) webCache(cfg *config.AwsConfig, file string) credentials.CredentialCacher {
+	c := f.clientCache(cfg, file)
+	if len(cfg.WebIdentityTokenFile) < 1 {
+		return c
+	}
+	return newTokenFileCredentialCache(c, cfg.WebIdentityTokenFile, stateFilePath(cfg, filepath.Base(file)+".token"))
+}
+
+// roleMfaInputProvider wraps the MFA input provider so the user is told which device the MFA code is for when the
+// session token and assume role steps each require their own MFA code.
+func (f *Factory) roleMfaInputProvider(p func() (string, error), serial string) func() (string, error) {
+	if p == nil {
+		return nil
+	}
+
+	return func() (string, error) {
+		f.options.Logger.Infof("role requires MFA code for device %s", serial)
+		return p()
+	}
+}
+
 func (f *Factory) sessionClient(cfg *config.AwsConfig, opts ...func(*awsconfig.LoadOptions) error) (*sessionTokenClient, error) {
 	logger := f.options.Logger
 	logger.Debugf("configuring Session Token client")
 
+	mfaProvider, _, err := f.mfaProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	sesCfg := &SessionTokenClientConfig{
 		Duration:      cfg.SessionTokenDuration,
 		SerialNumber:  cfg.MfaSerial,
 		TokenCode:     cfg.MfaCode,
-		TokenProvider: f.options.MfaInputProvider,
+		TokenProvider: mfaProvider,
 		Logger:        logger,
+		StsRetryer:    stsRetryer(cfg),
 	}
 
 	if f.options.EnableCache {
-		name := cfg.ProfileName
-		if cfg.SourceProfile() != nil {
-			name = cfg.SourceProfile().ProfileName
-		}
-		cacheFile := cacheFileName(".aws_session_token", name, "")
-		sesCfg.Cache = cache.NewFileCredentialCache(cacheFile)
+		cacheFile := cacheFileName(cfg, sessionCachePrefix, sessionCacheProfile(cfg), "")
+		sesCfg.Cache = f.clientCache(cfg, cacheFile)
 	}
 
-	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	awsCfg, err := f.loadAwsConfig(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -373,6 +566,50 @@ func (f *Factory) sessionClient(cfg *config.AwsConfig, opts ...func(*awsconfig.L
 	return NewSessionTokenClient(awsCfg, sesCfg), nil
 }
 
+// loadAwsConfig loads the AWS SDK configuration, using the custom http.RoundTripper from the factory options, if set.
+// The transport is set after loading the configuration, since the SDK is unable to apply settings like a custom
+// CA bundle to an arbitrary http.Client; the custom transport is expected to handle that for itself.
+func (f *Factory) loadAwsConfig(opts ...func(*awsconfig.LoadOptions) error) (aws.Config, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return awsCfg, err
+	}
+
+	if f.options.Transport != nil {
+		awsCfg.HTTPClient = &http.Client{Transport: f.options.Transport}
+	}
+	return awsCfg, nil
+}
+
+// AccountAliases returns the account aliases for the roles in the SAML assertion, found on the AWS sign-in role
+// selection page.  The request is sent using the factory's transport, and ErrOffline is returned in offline mode.
+func (f *Factory) AccountAliases(ctx context.Context, saml *credentials.SamlAssertion) (map[string]string, error) {
+	if f.options.Offline {
+		return nil, ErrOffline
+	}
+	return external.AccountAliases(ctx, saml, f.transport())
+}
+
+// transport returns the http.RoundTripper for requests to external identity providers, which is the custom transport
+// from the factory options, if set, otherwise the transport shared by all clients in the process.  If more than one
+// identity provider endpoint is provided, the transport fails over to the other endpoints if the first one fails.
+func (f *Factory) transport(authUrls ...string) http.RoundTripper {
+	rt := f.options.Transport
+	if rt == nil {
+		rt = shared.DefaultTransport()
+	}
+
+	if len(authUrls) > 1 {
+		t, err := external.NewFailoverTransport(rt, authUrls, f.options.Logger)
+		if err != nil {
+			f.options.Logger.Warningf("identity provider failover disabled: %v", err)
+			return rt
+		}
+		return t
+	}
+	return rt
+}
+
 func (f *Factory) decodePassword(url, password string) string {
 	pw, err := helpers.NewPasswordEncoder([]byte(url)).Decode(password)
 	if err != nil {
@@ -382,7 +619,41 @@ func (f *Factory) decodePassword(url, password string) string {
 	return pw
 }
 
+// passwordUpdater returns a function which saves a changed identity provider password in the credentials file using
+// the given key, so the stored password keeps working.  Nil is returned if the password in use was not stored in the
+// credentials file (like a password provided on the command line), or the resolver can't change credentials.
+func (f *Factory) passwordUpdater(url, key, stored string) func(string) error {
+	w, ok := f.resolver.(config.Writer)
+	if !ok || len(stored) < 1 {
+		return nil
+	}
+
+	if cmd := f.options.CommandCredentials; cmd != nil && (len(cmd.SamlPassword) > 0 || len(cmd.WebIdentityPassword) > 0) {
+		return nil
+	}
+
+	return func(password string) error {
+		enc := helpers.NewPasswordEncoder([]byte(url))
+		crypt, err := enc.Encode(password, 18)
+		if err != nil {
+			return err
+		}
+
+		if crypt, err = enc.Protect(crypt); err != nil {
+			return err
+		}
+		return w.SetCredentials(url, map[string]string{key: crypt})
+	}
+}
+
+// cachePath returns the directory for cache and state files when no platform specific location is used.  If the
+// AWS_RUNAS_CACHE_DIR environment variable is set, that value is used, otherwise the files are kept in the same
+// directory as the AWS configuration file.
 func cachePath() string {
+	if v, ok := os.LookupEnv("AWS_RUNAS_CACHE_DIR"); ok && len(v) > 0 {
+		return v
+	}
+
 	f := awsconfig.DefaultSharedConfigFilename()
 	if v, ok := os.LookupEnv("AWS_CONFIG_FILE"); ok {
 		f = v
@@ -390,11 +661,163 @@ func cachePath() string {
 	return filepath.Dir(f)
 }
 
-func cacheFileName(prefix, profile, role string) string {
-	if len(profile) < 1 && arn.IsARN(role) {
+// cacheDir returns the directory for cache and state files for the given configuration.  The cache_dir configuration
+// attribute takes priority over the default location returned by cachePath().
+func cacheDir(cfg *config.AwsConfig) string {
+	if cfg == nil || len(cfg.CacheDir) < 1 {
+		return cachePath()
+	}
+
+	dir := cfg.CacheDir
+	if strings.HasPrefix(dir, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, dir[1:])
+		}
+	}
+
+	// best effort, any problem will be surfaced when the cache files are written
+	_ = os.MkdirAll(dir, 0750)
+	return dir
+}
+
+func sharedCookieJar(cfg *config.AwsConfig) http.CookieJar {
+	return cache.CookieJar(cacheFilePath(cfg, cookieJarFile))
+}
+
+func sharedLoginThrottle(cfg *config.AwsConfig) external.LoginThrottler {
+	loginThrottlesMu.Lock()
+	defer loginThrottlesMu.Unlock()
+
+	path := stateFilePath(cfg, loginThrottleFile)
+	t, ok := loginThrottles[path]
+	if !ok {
+		t = external.NewLoginThrottle(path)
+		loginThrottles[path] = t
+	}
+	return t
+}
+
+func sharedTokenCache(cfg *config.AwsConfig) credentials.IdentityTokenCacher {
+	return cache.WebIdentityCache(cacheFilePath(cfg, tokenCacheFile))
+}
+
+// sharedSamlRoleCache returns the cache of roles found in SAML assertions, or nil if the cache file can't be used.
+func sharedSamlRoleCache(cfg *config.AwsConfig) credentials.SamlRoleCacher {
+	c, err := cache.SamlRoleCache(cacheFilePath(cfg, samlRoleCacheFile))
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+// sharedOidcProviderCache returns the OIDC provider cache shared by all profiles, or nil if the cache file can not be
+// used, which disables caching of the provider discovery and signing key data.
+func sharedOidcProviderCache(cfg *config.AwsConfig) credentials.OidcProviderCacher {
+	c, err := cache.OidcProviderCache(cacheFilePath(cfg, oidcCacheFile))
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+// credentialCache returns the credential cache for the cache file.  If the configuration specifies a cache backend, the
+// cache is created by the backend registered for the scheme of the cache URI, otherwise (or if the configured backend
+// can not be used) the file-backed cache is returned, with an in-memory layer if the MemoryCache option is set.
+func (f *Factory) credentialCache(cfg *config.AwsConfig, file string) credentials.CredentialCacher {
+	if uri := cacheUri(cfg); len(uri) > 0 {
+		c, err := cache.NewCredentialCache(uri, f.options.CacheKeyScope+strings.TrimPrefix(filepath.Base(file), "."))
+		if err == nil {
+			return c
+		}
+		f.options.Logger.Warningf("unable to use credential cache backend, using file cache: %v", err)
+	}
+
+	if f.options.MemoryCache {
+		return cache.NewMemoryCredentialCache(file)
+	}
+	return cache.NewFileCredentialCache(file)
+}
+
+// cacheUri returns the cache backend URI from the configuration.  The cache_uri attribute is used as-is, otherwise the
+// URI is built from the backend specific configuration attributes.  An empty string is returned for the default file
+// cache.
+func cacheUri(cfg *config.AwsConfig) string {
+	var u *url.URL
+	q := make(url.Values)
+
+	switch {
+	case len(cfg.CacheUri) > 0:
+		u, _ = url.Parse(cfg.CacheUri)
+	case strings.EqualFold(cfg.CacheBackend, config.CacheBackendRedis):
+		u, _ = url.Parse(cfg.CacheRedisUrl)
+	case strings.EqualFold(cfg.CacheBackend, config.CacheBackendDynamoDb):
+		u = &url.URL{Scheme: config.CacheBackendDynamoDb, Host: cfg.CacheDynamoDbTable}
+		q.Set("region", cfg.Region)
+		if len(cfg.CacheKmsKeyId) > 0 {
+			q.Set("kms_key_id", cfg.CacheKmsKeyId)
+		}
+		if len(cfg.CacheKmsContext) > 0 {
+			q.Set("encryption_context", cfg.CacheKmsContext)
+		}
+	default:
+		return ""
+	}
+
+	if u == nil {
+		// config validation ensures this is a valid url, this should never happen
+		return ""
+	}
+
+	// merge settings from the config attributes with any set in the URI query string, the URI query takes priority
+	for k, v := range u.Query() {
+		q[k] = v
+	}
+
+	if len(cfg.CacheKeyPrefix) > 0 && !q.Has("key_prefix") {
+		q.Set("key_prefix", cfg.CacheKeyPrefix)
+	}
+
+	if len(cfg.CacheRedisPassword) > 0 && strings.HasPrefix(strings.ToLower(u.Scheme), "redis") {
+		u.User = url.UserPassword(u.User.Username(), cfg.CacheRedisPassword)
+	}
+
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func cacheFileName(cfg *config.AwsConfig, prefix, profile, role string) string {
+	if len(profile) < 1 && credentials.IsRolePattern(role) {
+		// patterns can contain characters which aren't valid in file names
+		sum := sha256.Sum256([]byte(role))
+		profile = "pattern-" + hex.EncodeToString(sum[:16])
+	} else if len(profile) < 1 && arn.IsARN(role) {
 		roleArn, _ := arn.Parse(role)
 		roleParts := strings.Split(roleArn.Resource, `/`)
 		profile = fmt.Sprintf("%s-%s", roleArn.AccountID, roleParts[len(roleParts)-1])
 	}
-	return filepath.Join(cachePath(), fmt.Sprintf("%s_%s", prefix, profile))
+	return cacheFilePath(cfg, fmt.Sprintf("%s_%s", prefix, profile))
+}
+
+// mfaProvider returns the MFA code provider and MFA type for the profile.  If the profile sets mfa_provider, the
+// provider with that name in the helpers package registry is used, otherwise the MfaInputProvider option and the
+// mfa_type of the profile are used.
+func (f *Factory) mfaProvider(cfg *config.AwsConfig) (func() (string, error), string, error) {
+	if len(cfg.MfaProvider) < 1 {
+		return f.options.MfaInputProvider, cfg.MfaType, nil
+	}
+
+	p, err := helpers.NewMfaProvider(cfg.MfaProvider, &helpers.MfaProviderConfig{
+		Command:    cfg.MfaCommand,
+		TotpSecret: cfg.MfaTotpSecret,
+		Prompt:     f.options.MfaInputProvider,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	mfaType := cfg.MfaType
+	if t := p.MfaType(); len(t) > 0 {
+		mfaType = t
+	}
+	return p.ReadInput, mfaType, nil
 }
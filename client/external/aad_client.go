@@ -142,12 +142,22 @@ func (c *aadClient) Identity() (*identity.Identity, error) {
 	return c.identity(aadIdentityProvider), nil
 }
 
-// Roles retrieves the available roles for the user.  Attempting to call this method
+// IdentityWithContext calls Identity, the context is not used.
+func (c *aadClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return c.Identity()
+}
+
+// Roles calls RolesWithContext using a background context.
+func (c *aadClient) Roles(user ...string) (*identity.Roles, error) {
+	return c.RolesWithContext(context.Background(), user...)
+}
+
+// RolesWithContext retrieves the available roles for the user.  Attempting to call this method
 // against an Oauth/OIDC client will return an error.
-func (c *aadClient) Roles(...string) (*identity.Roles, error) {
+func (c *aadClient) RolesWithContext(ctx context.Context, _ ...string) (*identity.Roles, error) {
 	if c.saml == nil || len(*c.saml) < 1 {
 		var err error
-		c.saml, err = c.SamlAssertion()
+		c.saml, err = c.SamlAssertionWithContext(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -194,6 +204,10 @@ func (c *aadClient) SamlAssertion() (*credentials.SamlAssertion, error) {
 }
 
 func (c *aadClient) SamlAssertionWithContext(ctx context.Context) (*credentials.SamlAssertion, error) {
+	if c.samlValid() {
+		return c.saml, nil
+	}
+
 	// using c.authUrl directly won't work, you need to fetch that URL, then process the response which
 	// contains the actual URL we need to submit (which is embedded in JS, because why wouldn't it be?).
 	req, _ := newHttpRequest(ctx, http.MethodGet, c.authUrl.String())
@@ -287,6 +301,11 @@ func (c *aadClient) submitResponse(u *url.URL, r io.ReadCloser) (*http.Response,
 
 //nolint:bodyclose // response bodies closed in parseResponse
 func (c *aadClient) auth(ctx context.Context, authUrl *url.URL, authRes *aadAuthResponse) error {
+	// the session cookies were not enough to complete the authentication, the user must log in
+	if err := checkLogin(ctx); err != nil {
+		return err
+	}
+
 	authForm := url.Values{}
 	authForm.Set(authRes.FTName, authRes.FT)
 	authForm.Set("ctx", authRes.Ctx)
@@ -302,6 +321,9 @@ func (c *aadClient) auth(ctx context.Context, authUrl *url.URL, authRes *aadAuth
 		return err
 	}
 
+	// only set for aad-managed accounts, federated auth is throttled by the federated client
+	var done func(failed bool)
+
 	// a bold assumption that everything down this path is not nil
 	if u := authRes.CredentialTypeResult.Credentials.FederationRedirectUrl; len(u) > 0 {
 		res, err = c.doFederatedAuth(u)
@@ -318,6 +340,10 @@ func (c *aadClient) auth(ctx context.Context, authUrl *url.URL, authRes *aadAuth
 		}
 		authForm.Set("passwd", c.Password)
 
+		if done, err = c.waitLogin(ctx); err != nil {
+			return err
+		}
+
 		// update existing req with new form values (password)
 		res, err = checkResponseError(c.httpClient.Do(req.withValues(authForm).Request))
 		if err != nil {
@@ -329,7 +355,14 @@ func (c *aadClient) auth(ctx context.Context, authUrl *url.URL, authRes *aadAuth
 	// but we'll be safe and test everything. Code 50126 is bad username/password error
 	if err = parseResponse(res.Body, authRes); err != nil {
 		return err
-	} else if authRes.ErrCode == strconv.Itoa(aadBadUserPassErrCode) {
+	}
+
+	badPassword := authRes.ErrCode == strconv.Itoa(aadBadUserPassErrCode)
+	if done != nil {
+		done(badPassword)
+	}
+
+	if badPassword {
 		return errors.New("authentication failed")
 	}
 
@@ -354,6 +387,7 @@ func (c *aadClient) doFederatedAuth(fedUrl string) (res *http.Response, err erro
 
 	// federation url must be one of the aws-runas supported external clients
 	sc := MustGetSamlClient("", fedUrl, cfg)
+	sc.SetLoginThrottler(c.throttle)
 	if err = sc.Authenticate(); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,249 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// graphBaseUrl is the location of the Microsoft Graph API, it's a variable so tests can use a mock API.
+var graphBaseUrl = "https://graph.microsoft.com/v1.0"
+
+// aadGraphScopes are the delegated permissions needed to list the applications assigned to the signed-in user, and the
+// roles configured in those applications.
+var aadGraphScopes = []string{"https://graph.microsoft.com/User.Read", "https://graph.microsoft.com/Application.Read.All"}
+
+// AadAwsApp is an AWS enterprise application assigned to an Azure AD user.
+type AadAwsApp struct {
+	// Id is the object id of the service principal of the application.
+	Id string
+	// AppId is the application (client) id.
+	AppId string
+	// Name is the display name of the application.
+	Name string
+	// Url is the user access URL of the application, which is the SAML URL used by aws-runas profiles.
+	Url string
+	// Roles are the ARNs of the IAM roles the user is assigned in the application.
+	Roles []string
+}
+
+// AadAppClient is an AuthenticationClient which lists the AWS enterprise applications assigned to the Azure AD user.
+type AadAppClient interface {
+	AuthenticationClient
+	AwsApps() ([]*AadAwsApp, error)
+	AwsAppsWithContext(ctx context.Context) ([]*AadAwsApp, error)
+}
+
+// AwsApps calls AwsAppsWithContext using a background context.
+func (c *aadClient) AwsApps() ([]*AadAwsApp, error) {
+	return c.AwsAppsWithContext(context.Background())
+}
+
+// AwsAppsWithContext returns the AWS enterprise applications assigned to the user, directly or through group
+// membership, using the Microsoft Graph API.  The delegated Graph API token is requested using the OAuth client
+// configured for the client (ClientId and RedirectUri), authenticating the user if required.
+func (c *aadClient) AwsAppsWithContext(ctx context.Context) ([]*AadAwsApp, error) {
+	token, err := c.graphToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get Graph API token: %w", err)
+	}
+	return c.awsApps(ctx, token)
+}
+
+func (c *aadClient) graphToken(ctx context.Context) (string, error) {
+	if len(c.ClientId) < 1 || len(c.RedirectUri) < 1 {
+		return "", errors.New("web identity client id and redirect uri are required")
+	}
+
+	oauthUrlBase := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0", c.tenantId)
+
+	scopes := c.Scopes
+	c.Scopes = aadGraphScopes
+	defer func() { c.Scopes = scopes }()
+
+	pkce, err := newPkceCode()
+	if err != nil {
+		return "", err
+	}
+	authzQS := c.pkceAuthzRequest(pkce.Challenge())
+
+	vals, err := c.oauthAuthorize(fmt.Sprintf("%s/authorize", oauthUrlBase), authzQS, false)
+	if err != nil {
+		// no session with Azure AD, authenticate and try again
+		c.Logger.Debugf("authorization request failed, attempting authentication: %v", err)
+		if err = c.AuthenticateWithContext(ctx); err != nil {
+			return "", err
+		}
+
+		if vals, err = c.oauthAuthorize(fmt.Sprintf("%s/authorize", oauthUrlBase), authzQS, false); err != nil {
+			return "", err
+		}
+	}
+
+	if vals.Get("state") != authzQS.Get("state") {
+		return "", errOauthStateMismatch
+	}
+
+	token, err := c.oauthCodeToken(ctx, fmt.Sprintf("%s/token", oauthUrlBase), vals.Get("code"), pkce.Verifier(), c.RedirectUri)
+	if err != nil {
+		return "", err
+	}
+
+	if len(token.AccessToken) < 1 {
+		return "", errors.New("no access token returned")
+	}
+	return token.AccessToken, nil
+}
+
+// graphAppRoleAssignment is an application role assigned to a user or group.
+type graphAppRoleAssignment struct {
+	AppRoleId  string `json:"appRoleId"`
+	ResourceId string `json:"resourceId"`
+}
+
+// graphServicePrincipal is the service principal of an application, with the roles defined by the application.
+type graphServicePrincipal struct {
+	Id          string `json:"id"`
+	AppId       string `json:"appId"`
+	DisplayName string `json:"displayName"`
+	AppRoles    []struct {
+		Id    string `json:"id"`
+		Value string `json:"value"`
+	} `json:"appRoles"`
+}
+
+func (c *aadClient) awsApps(ctx context.Context, token string) ([]*AadAwsApp, error) {
+	assignments, err := graphList[graphAppRoleAssignment](ctx, c.httpClient, token, graphBaseUrl+"/me/appRoleAssignments")
+	if err != nil {
+		return nil, err
+	}
+
+	// roles assigned through groups are best effort, since reading them may need permissions the user does not have
+	groups, err := graphList[struct {
+		Id string `json:"id"`
+	}](ctx, c.httpClient, token, graphBaseUrl+"/me/transitiveMemberOf/microsoft.graph.group?$select=id")
+	if err != nil {
+		c.Logger.Debugf("unable to list groups, only direct role assignments are used: %v", err)
+	}
+
+	for _, g := range groups {
+		ga, err := graphList[graphAppRoleAssignment](ctx, c.httpClient, token,
+			graphBaseUrl+"/groups/"+url.PathEscape(g.Id)+"/appRoleAssignments")
+		if err != nil {
+			c.Logger.Debugf("unable to list role assignments of group %s: %v", g.Id, err)
+			continue
+		}
+		assignments = append(assignments, ga...)
+	}
+
+	// role assignments, grouped by the service principal of the application
+	assigned := make(map[string]map[string]bool)
+	for _, a := range assignments {
+		if assigned[a.ResourceId] == nil {
+			assigned[a.ResourceId] = make(map[string]bool)
+		}
+		assigned[a.ResourceId][a.AppRoleId] = true
+	}
+
+	apps := make([]*AadAwsApp, 0)
+	for id, roleIds := range assigned {
+		sp := new(graphServicePrincipal)
+		if err = graphGet(ctx, c.httpClient, token,
+			graphBaseUrl+"/servicePrincipals/"+url.PathEscape(id)+"?$select=id,appId,displayName,appRoles", sp); err != nil {
+			return nil, err
+		}
+
+		roles := make([]string, 0)
+		for _, r := range sp.AppRoles {
+			if roleIds[r.Id] {
+				roles = append(roles, awsRoleArns(r.Value)...)
+			}
+		}
+
+		// not an AWS application
+		if len(roles) < 1 {
+			continue
+		}
+		sort.Strings(roles)
+
+		apps = append(apps, &AadAwsApp{
+			Id:    sp.Id,
+			AppId: sp.AppId,
+			Name:  sp.DisplayName,
+			Url: fmt.Sprintf("https://myapps.microsoft.com/signin/%s/%s?tenantId=%s", url.PathEscape(sp.DisplayName),
+				sp.AppId, c.tenantId),
+			Roles: roles,
+		})
+	}
+
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Name < apps[j].Name })
+	return apps, nil
+}
+
+// awsRoleArns returns the IAM role ARNs in the value of an application role, which for AWS applications is the role ARN
+// and the SAML provider ARN, separated by a comma.
+func awsRoleArns(value string) []string {
+	roles := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if r, err := arn.Parse(v); err == nil && r.Service == "iam" && strings.HasPrefix(r.Resource, "role/") {
+			roles = append(roles, v)
+		}
+	}
+	return roles
+}
+
+// graphList returns all of the items in the collection at the Graph API url, following the pagination links.
+func graphList[T any](ctx context.Context, hc *http.Client, token, u string) ([]T, error) {
+	items := make([]T, 0)
+	for len(u) > 0 {
+		page := new(struct {
+			Value    []T    `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		})
+		if err := graphGet(ctx, hc, token, u, page); err != nil {
+			return nil, err
+		}
+
+		items = append(items, page.Value...)
+		u = page.NextLink
+	}
+	return items, nil
+}
+
+// graphGet sends a GET request to the Graph API url, and decodes the JSON response body into out.
+func graphGet(ctx context.Context, hc *http.Client, token, u string, out any) error {
+	req, err := newHttpRequest(ctx, http.MethodGet, u)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", contentTypeJson)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := checkResponseError(hc.Do(req.Request))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAadClient_awsApps(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer mytoken" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var body interface{}
+		switch r.URL.Path {
+		case "/me/appRoleAssignments":
+			if r.URL.Query().Get("page") == "2" {
+				body = map[string]interface{}{"value": []map[string]string{
+					{"appRoleId": "r2", "resourceId": "sp-aws"},
+				}}
+			} else {
+				body = map[string]interface{}{
+					"value": []map[string]string{
+						{"appRoleId": "r1", "resourceId": "sp-aws"},
+						{"appRoleId": "x1", "resourceId": "sp-other"},
+					},
+					"@odata.nextLink": srv.URL + "/me/appRoleAssignments?page=2",
+				}
+			}
+		case "/me/transitiveMemberOf/microsoft.graph.group":
+			body = map[string]interface{}{"value": []map[string]string{{"id": "g1"}, {"id": "g2"}}}
+		case "/groups/g1/appRoleAssignments":
+			body = map[string]interface{}{"value": []map[string]string{
+				{"appRoleId": "d1", "resourceId": "sp-dev"},
+			}}
+		case "/servicePrincipals/sp-aws":
+			body = map[string]interface{}{
+				"id": "sp-aws", "appId": "app1", "displayName": "AWS Prod",
+				"appRoles": []map[string]string{
+					{"id": "r1", "value": "arn:aws:iam::123456789012:role/Admin,arn:aws:iam::123456789012:saml-provider/AAD"},
+					{"id": "r2", "value": "arn:aws:iam::123456789012:saml-provider/AAD,arn:aws:iam::123456789012:role/ReadOnly"},
+					{"id": "r3", "value": "arn:aws:iam::123456789012:role/NotAssigned,arn:aws:iam::123456789012:saml-provider/AAD"},
+				},
+			}
+		case "/servicePrincipals/sp-dev":
+			body = map[string]interface{}{
+				"id": "sp-dev", "appId": "app2", "displayName": "AWS Dev",
+				"appRoles": []map[string]string{
+					{"id": "d1", "value": "arn:aws:iam::210987654321:role/Developer,arn:aws:iam::210987654321:saml-provider/AAD"},
+				},
+			}
+		case "/servicePrincipals/sp-other":
+			body = map[string]interface{}{
+				"id": "sp-other", "appId": "app3", "displayName": "Other",
+				"appRoles": []map[string]string{{"id": "x1", "value": "User"}},
+			}
+		default:
+			// g2 role assignments are not readable, and must not fail the request
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentTypeJson)
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	defer srv.Close()
+
+	defer func(u string) { graphBaseUrl = u }(graphBaseUrl)
+	graphBaseUrl = srv.URL
+
+	c, err := NewAadClient("https://myapps.microsoft.com/signin/testapp/12345?tenantId=54321")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("good", func(t *testing.T) {
+		apps, err := c.awsApps(context.Background(), "mytoken")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(apps) != 2 {
+			t.Fatalf("unexpected number of apps: %d", len(apps))
+		}
+
+		if apps[0].Name != "AWS Dev" || apps[0].AppId != "app2" || len(apps[0].Roles) != 1 ||
+			apps[0].Roles[0] != "arn:aws:iam::210987654321:role/Developer" {
+			t.Errorf("unexpected app: %+v", apps[0])
+		}
+
+		if apps[1].Name != "AWS Prod" || len(apps[1].Roles) != 2 ||
+			apps[1].Roles[0] != "arn:aws:iam::123456789012:role/Admin" ||
+			apps[1].Roles[1] != "arn:aws:iam::123456789012:role/ReadOnly" {
+			t.Errorf("unexpected app: %+v", apps[1])
+		}
+
+		if apps[1].Url != "https://myapps.microsoft.com/signin/AWS%20Prod/app1?tenantId=54321" {
+			t.Errorf("unexpected url: %s", apps[1].Url)
+		}
+	})
+
+	t.Run("bad token", func(t *testing.T) {
+		if _, err := c.awsApps(context.Background(), "badtoken"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestAadClient_AwsApps(t *testing.T) {
+	t.Run("no client id", func(t *testing.T) {
+		c, _ := NewAadClient("https://myapps.microsoft.com/signin/testapp/12345?tenantId=54321")
+		if _, err := c.AwsApps(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"errors"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// the AWS sign-in endpoint which accepts a SAMLResponse and renders the role selection page for the console.
+var awsSamlSigninUrl = "https://signin.aws.amazon.com/saml"
+
+var accountAliasRe = regexp.MustCompile(`^Account:\s+(.+?)\s+\((\d{12})\)$`)
+
+// AccountAliases uses the provided SAML assertion to request the AWS sign-in role selection page, and returns a map
+// of account ID to account alias found on that page.  Accounts without an alias will not be present in the map.  The
+// request is sent using rt, or the shared default transport if rt is nil.
+func AccountAliases(ctx context.Context, saml *credentials.SamlAssertion, rt http.RoundTripper) (map[string]string, error) {
+	if saml == nil || len(*saml) < 1 {
+		return nil, errors.New("invalid saml assertion")
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	req, err := newHttpRequest(ctx, http.MethodPost, awsSamlSigninUrl)
+	if err != nil {
+		return nil, err
+	}
+	req.withValues(url.Values{"SAMLResponse": {saml.String()}})
+
+	var res *http.Response
+	hc := newHttpClient()
+	if rt != nil {
+		hc.Transport = rt
+	}
+
+	res, err = checkResponseError(hc.Do(req.Request))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var doc *goquery.Document
+	doc, err = goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]string)
+	doc.Find("div.saml-account-name").Each(func(i int, s *goquery.Selection) {
+		if match := accountAliasRe.FindStringSubmatch(strings.TrimSpace(s.Text())); len(match) > 2 {
+			m[match[2]] = match[1]
+		}
+	})
+
+	return m, nil
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccountAliases(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || len(r.PostForm.Get("SAMLResponse")) < 1 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		_, _ = w.Write([]byte(`<html><body><form>
+<fieldset>
+<div class="saml-account"><div class="saml-account-name">Account: my-alias (123456789012)</div></div>
+<div class="saml-account"><div class="saml-account-name">Account: 210987654321</div></div>
+</fieldset></form></body></html>`))
+	}))
+	defer s.Close()
+
+	origUrl := awsSamlSigninUrl
+	awsSamlSigninUrl = s.URL
+	defer func() { awsSamlSigninUrl = origUrl }()
+
+	t.Run("good", func(t *testing.T) {
+		saml := credentials.SamlAssertion("PHNhbWw+PC9zYW1sPg==")
+		m, err := AccountAliases(context.Background(), &saml, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(m) != 1 || m["123456789012"] != "my-alias" {
+			t.Errorf("data mismatch: %v", m)
+		}
+	})
+
+	t.Run("transport", func(t *testing.T) {
+		saml := credentials.SamlAssertion("PHNhbWw+PC9zYW1sPg==")
+		rt := new(countingTransport)
+		if _, err := AccountAliases(context.Background(), &saml, rt); err != nil {
+			t.Fatal(err)
+		}
+
+		if rt.requests != 1 {
+			t.Error("transport was not used")
+		}
+	})
+
+	t.Run("nil assertion", func(t *testing.T) {
+		if _, err := AccountAliases(context.Background(), nil, nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("empty assertion", func(t *testing.T) {
+		saml := credentials.SamlAssertion("")
+		if _, err := AccountAliases(context.Background(), &saml, nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+// countingTransport counts the requests sent using http.DefaultTransport.
+type countingTransport struct {
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.requests++
+	return http.DefaultTransport.RoundTrip(r)
+}
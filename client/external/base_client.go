@@ -32,19 +32,47 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"golang.org/x/net/publicsuffix"
 
+	"github.com/mmmorris1975/aws-runas/config"
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"github.com/mmmorris1975/aws-runas/credentials/helpers"
 	"github.com/mmmorris1975/aws-runas/identity"
 	"github.com/mmmorris1975/aws-runas/shared"
 )
 
-var errNilClient = errors.New("client not initialized, use the appropriate constructor")
+var (
+	errNilClient           = errors.New("client not initialized, use the appropriate constructor")
+	errAuthorizeBadRequest = errors.New("authorization request rejected")
+
+	// ErrLoginRequired is returned when logging in to the identity provider is required, and the context does not
+	// allow it.
+	ErrLoginRequired = errors.New("identity provider login required")
+)
+
+type noLoginKey struct{}
+
+// WithoutLogin returns a copy of ctx which does not allow logging in to the identity provider.  Requests made using
+// the context only succeed if the identity provider session cookies are still valid, any step which sends a password,
+// or opens a browser, fails with ErrLoginRequired instead.
+func WithoutLogin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noLoginKey{}, true)
+}
+
+// checkLogin returns ErrLoginRequired if ctx does not allow logging in to the identity provider.
+func checkLogin(ctx context.Context) error {
+	if v, ok := ctx.Value(noLoginKey{}).(bool); ok && v {
+		return ErrLoginRequired
+	}
+	return nil
+}
 
 type baseClient struct {
 	OidcClientConfig
 	authUrl    *url.URL
 	httpClient *http.Client
 	saml       *credentials.SamlAssertion
+	throttle   LoginThrottler
+	// set when config.PkceAuto is used, and the identity provider rejected the PKCE parameters
+	pkceRejected bool
 }
 
 func newBaseClient(u string) (*baseClient, error) {
@@ -69,14 +97,78 @@ func newBaseClient(u string) (*baseClient, error) {
 	return c, nil
 }
 
+// SetSamlAssertion sets the SAML assertion for the client, which is used until it expires.
+func (c *baseClient) SetSamlAssertion(saml *credentials.SamlAssertion) {
+	if c != nil {
+		c.saml = saml
+	}
+}
+
+// samlValid returns true if the client has a SAML assertion which has not expired.
+func (c *baseClient) samlValid() bool {
+	if c == nil || c.saml == nil || len(*c.saml) < 1 {
+		return false
+	}
+
+	t, err := c.saml.ExpiresAt()
+	return err == nil && shared.LocalTime(t).After(time.Now())
+}
+
 // SetCookieJar updates this clients HTTP cookie storage to use the provided http.CookieJar.
 func (c *baseClient) SetCookieJar(jar http.CookieJar) {
 	if c.httpClient == nil {
-		c.httpClient = http.DefaultClient
+		c.httpClient = newHttpClient()
 	}
 	c.httpClient.Jar = jar
 }
 
+// SetTransport updates this clients HTTP transport to use the provided http.RoundTripper.  A nil value will use
+// the default transport from the net/http package.
+func (c *baseClient) SetTransport(rt http.RoundTripper) {
+	if c.httpClient == nil {
+		c.httpClient = newHttpClient()
+	}
+
+	// make a copy so we never modify the transport of a shared http.Client
+	hc := *c.httpClient
+	hc.Transport = rt
+	c.httpClient = &hc
+}
+
+// SetLoginThrottler updates this clients LoginThrottler used to limit password submissions to the identity provider.
+// A nil value will use the default in-memory throttle.
+func (c *baseClient) SetLoginThrottler(t LoginThrottler) {
+	c.throttle = t
+}
+
+// waitLogin must be called before sending a password to the identity provider, and blocks until the login throttle
+// allows the request.  The returned function must be called with the result of the password submission, where failed
+// is true only if the identity provider rejected the credentials (not for network or other errors).
+func (c *baseClient) waitLogin(ctx context.Context) (func(failed bool), error) {
+	t := c.throttle
+	if t == nil {
+		t = defaultLoginThrottle
+	}
+
+	var host string
+	if c.authUrl != nil {
+		host = c.authUrl.Host
+	}
+	key := fmt.Sprintf("%s|%s", host, c.Username)
+
+	if err := t.Wait(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return func(failed bool) {
+		if failed {
+			t.Failure(key)
+			return
+		}
+		t.Success(key)
+	}, nil
+}
+
 // Roles retrieves the available roles for SamlClients.  Attempting to call this method
 // against an Oauth/OIDC client will return an error.
 func (c *baseClient) roles(...string) (*identity.Roles, error) {
@@ -95,7 +187,7 @@ func (c *baseClient) roles(...string) (*identity.Roles, error) {
 
 func (c *baseClient) setHttpClient() {
 	if c.httpClient == nil {
-		c.httpClient = http.DefaultClient
+		c.httpClient = newHttpClient()
 	}
 
 	if c.httpClient.Jar == nil {
@@ -104,6 +196,12 @@ func (c *baseClient) setHttpClient() {
 	}
 }
 
+// newHttpClient returns an http.Client using the shared transport, so connections to the identity provider are reused
+// by all clients.  Each client gets its own http.Client, since the cookie jar and redirect policy are set per client.
+func newHttpClient() *http.Client {
+	return &http.Client{Transport: shared.DefaultTransport()}
+}
+
 func (c *baseClient) samlRequest(ctx context.Context, u *url.URL) error {
 	if c.saml != nil && len(*c.saml) > 0 {
 		t, err := c.saml.ExpiresAt()
@@ -111,7 +209,7 @@ func (c *baseClient) samlRequest(ctx context.Context, u *url.URL) error {
 			return err
 		}
 
-		if t.After(time.Now()) {
+		if shared.LocalTime(t).After(time.Now()) {
 			return nil
 		}
 	}
@@ -155,7 +253,7 @@ func (c *baseClient) handleSamlResponse(r io.Reader) error {
 			saml := credentials.SamlAssertion(v)
 			c.saml = &saml
 
-			c.Logger.Debugf("SAMLResponse:\n%s", saml)
+			c.Logger.Debugf("SAMLResponse:\n%s", shared.Redact(saml.String()))
 			rd, _ := saml.RoleDetails()
 			c.Logger.Debugf("SAML Role Details:\n%s", rd)
 		}
@@ -197,8 +295,10 @@ func (c *baseClient) pkceAuthzRequest(pkceChallenge string) url.Values {
 
 	qs := url.Values{}
 	qs.Set("client_id", c.ClientId)
-	qs.Set("code_challenge", pkceChallenge)
-	qs.Set("code_challenge_method", "S256")
+	if c.pkceEnabled() {
+		qs.Set("code_challenge", pkceChallenge)
+		qs.Set("code_challenge_method", "S256")
+	}
 	qs.Set("redirect_uri", c.RedirectUri)
 	qs.Set("response_type", "code")
 
@@ -213,20 +313,65 @@ func (c *baseClient) pkceAuthzRequest(pkceChallenge string) url.Values {
 	return qs
 }
 
+// pkceEnabled returns true if the PKCE parameters are sent in the authorization code flow.
+func (c *baseClient) pkceEnabled() bool {
+	return !c.pkceRejected && !strings.EqualFold(c.PkceMode, config.PkceDisabled)
+}
+
+// pkceFallback checks if the failed authorization request was rejected because the identity provider does not support
+// the PKCE parameters, and config.PkceAuto allows retrying without them.  Only an invalid_request error naming the
+// code_challenge parameter is taken as that, any other error is returned to the caller.  If the fallback is allowed,
+// PKCE is disabled for the rest of the client's requests, and true is returned.  The vals parameter holds the error
+// from the authorization response redirect, or the body of a bad request response.
+func (c *baseClient) pkceFallback(vals url.Values) bool {
+	if !c.pkceEnabled() || strings.EqualFold(c.PkceMode, config.PkceRequired) {
+		return false
+	}
+
+	if !strings.EqualFold(vals.Get("error"), "invalid_request") ||
+		!strings.Contains(strings.ToLower(vals.Get("error_description")), "code_challenge") {
+		return false
+	}
+
+	c.Logger.Warningf("identity provider rejected the PKCE authorization request, retrying without PKCE. " +
+		"Set web_identity_pkce to disabled to skip this attempt")
+	c.pkceRejected = true
+	return true
+}
+
+// oauthAuthorize sends the authorization request, returning the query parameters of the response redirect.  With
+// config.PkceAuto, a request rejected because of the PKCE parameters is sent again without them.
 func (c *baseClient) oauthAuthorize(ep string, data url.Values, followRedirect bool) (url.Values, error) {
+	vals, err := c.sendAuthorize(ep, data, followRedirect)
+	if len(data.Get("code_challenge")) < 1 {
+		return vals, err
+	}
+
+	// an identity provider which does not redirect for errors returns a bad request status, with the error in the body
+	if (err == nil || errors.Is(err, errAuthorizeBadRequest)) && c.pkceFallback(vals) {
+		data.Del("code_challenge")
+		data.Del("code_challenge_method")
+		return c.sendAuthorize(ep, data, followRedirect)
+	}
+	return vals, err
+}
+
+func (c *baseClient) sendAuthorize(ep string, data url.Values, followRedirect bool) (url.Values, error) {
 	// make sure we use an appropriate http.Client based on the value of followRedirect.
 	httpClient := c.httpClient
 	if followRedirect {
 		if httpClient.CheckRedirect != nil {
 			httpClient = new(http.Client)
 			httpClient.Jar = c.httpClient.Jar
+			httpClient.Transport = c.httpClient.Transport
 		}
 	} else if httpClient.CheckRedirect == nil {
 		httpClient = &http.Client{
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				return http.ErrUseLastResponse
 			},
-			Jar: c.httpClient.Jar,
+			Jar:       c.httpClient.Jar,
+			Transport: c.httpClient.Transport,
 		}
 	}
 
@@ -260,6 +405,10 @@ func (c *baseClient) oauthAuthorize(ep string, data url.Values, followRedirect b
 
 	// we should only ever get here if followRedirect == false, in which case the status code should
 	// always be HTTP 302, but better safe than sorry
+	if res.StatusCode == http.StatusBadRequest {
+		return authorizeErrorBody(res), fmt.Errorf("%w: http status %s", errAuthorizeBadRequest, res.Status)
+	}
+
 	if res.StatusCode != http.StatusFound {
 		return nil, fmt.Errorf("http status %s", res.Status)
 	}
@@ -271,16 +420,40 @@ func (c *baseClient) oauthAuthorize(ep string, data url.Values, followRedirect b
 	return redir.Query(), nil
 }
 
+// authorizeErrorBody returns the OAuth error found in the JSON body of a bad request response to an authorization
+// request, or empty values if there isn't one.
+func authorizeErrorBody(res *http.Response) url.Values {
+	e := struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}{}
+
+	vals := url.Values{}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 8192)).Decode(&e); err == nil && len(e.Error) > 0 {
+		vals.Set("error", e.Error)
+		vals.Set("error_description", e.Description)
+	}
+	return vals
+}
+
 func (c *baseClient) oauthToken(ep, code, verifier string) (*oauthToken, error) {
+	return c.oauthCodeToken(context.Background(), ep, code, verifier, c.RedirectUri)
+}
+
+// oauthCodeToken exchanges the authorization code for tokens, for clients where the redirect URI used in the
+// authorization request is not the configured RedirectUri (like a loopback listener on a random port).
+func (c *baseClient) oauthCodeToken(ctx context.Context, ep, code, verifier, redirectUri string) (*oauthToken, error) {
 	data := url.Values{}
 	data.Set("client_id", c.ClientId)
 	data.Set("code", code)
-	data.Set("code_verifier", verifier)
+	if c.pkceEnabled() {
+		data.Set("code_verifier", verifier)
+	}
 	data.Set("grant_type", "authorization_code")
-	data.Set("redirect_uri", c.RedirectUri)
+	data.Set("redirect_uri", redirectUri)
 	sb := bytes.NewBufferString(data.Encode())
 
-	req, err := newHttpRequest(context.Background(), http.MethodPost, ep)
+	req, err := newHttpRequest(ctx, http.MethodPost, ep)
 	if err != nil {
 		return nil, err
 	}
@@ -305,6 +478,35 @@ func (c *baseClient) oauthToken(ep, code, verifier string) (*oauthToken, error)
 	return token, nil
 }
 
+// newPassword is called when the identity provider reports the user's password has expired, and returns the new
+// password to set.  An error wrapping ErrPasswordExpired is returned if there's no way to get a new password.
+func (c *baseClient) newPassword() (string, error) {
+	if c.NewPasswordProvider == nil {
+		return "", fmt.Errorf("%w for user %s, change the password with the identity provider and try again",
+			ErrPasswordExpired, c.Username)
+	}
+
+	c.Logger.Warningf("the identity provider password for user %s has expired, and must be changed", c.Username)
+	pw, err := c.NewPasswordProvider()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrPasswordExpired, err)
+	}
+	return pw, nil
+}
+
+// passwordChanged records the new password after it is accepted by the identity provider.  Failing to update the
+// stored password is not fatal, since the user is already authenticated with the new password.
+func (c *baseClient) passwordChanged(password string) {
+	c.Password = password
+	c.Logger.Infof("identity provider password changed for user %s", c.Username)
+
+	if c.PasswordUpdater != nil {
+		if err := c.PasswordUpdater(password); err != nil {
+			c.Logger.Warningf("unable to update the stored password, run the 'password' command to set it: %v", err)
+		}
+	}
+}
+
 func (c *baseClient) gatherCredentials() error {
 	var err error
 
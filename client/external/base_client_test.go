@@ -16,8 +16,13 @@ package external
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"github.com/mmmorris1975/aws-runas/config"
 	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/shared"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
@@ -87,6 +92,41 @@ func TestBaseClient_SetCookieJar(t *testing.T) {
 	})
 }
 
+func TestBaseClient_SetTransport(t *testing.T) {
+	t.Run("simple", func(t *testing.T) {
+		c, err := newBaseClient("http://localhost/")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		rt := new(http.Transport)
+		c.SetTransport(rt)
+
+		if c.httpClient.Transport != rt {
+			t.Error("did not update transport setting")
+		}
+
+		if http.DefaultClient.Transport == rt {
+			t.Error("default http client was modified")
+		}
+	})
+
+	t.Run("nil client", func(t *testing.T) {
+		c, err := newBaseClient("http://localhost/")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		c.httpClient = nil
+		c.SetTransport(nil)
+
+		if c.httpClient == nil || c.httpClient.Transport != nil {
+			t.Error("did not update transport setting")
+		}
+	})
+}
+
 func TestBaseClient_Roles(t *testing.T) {
 	t.Run("oidc client", func(t *testing.T) {
 		c, err := newBaseClient("https://localhost/")
@@ -157,6 +197,20 @@ func TestBaseClient_Roles(t *testing.T) {
 	})
 }
 
+func Test_checkLogin(t *testing.T) {
+	t.Run("allowed", func(t *testing.T) {
+		if err := checkLogin(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("without login", func(t *testing.T) {
+		if err := checkLogin(WithoutLogin(context.Background())); !errors.Is(err, ErrLoginRequired) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+}
+
 func Test_identity(t *testing.T) {
 	t.Run("invalid saml", func(t *testing.T) {
 		saml := credentials.SamlAssertion("this is not saml")
@@ -212,3 +266,129 @@ func Test_samlRequest(t *testing.T) {
 		}
 	})
 }
+
+func TestBaseClient_SetSamlAssertion(t *testing.T) {
+	c := new(baseClient)
+	if c.samlValid() {
+		t.Error("empty assertion is valid")
+	}
+
+	rawSaml := fmt.Sprintf(`<saml2:Assertion IssueInstant="%s">`, time.Now().Format(time.RFC3339))
+	saml := credentials.SamlAssertion(base64.StdEncoding.EncodeToString([]byte(rawSaml)))
+	c.SetSamlAssertion(&saml)
+	if !c.samlValid() {
+		t.Error("assertion is not valid")
+	}
+
+	rawSaml = fmt.Sprintf(`<saml2:Assertion IssueInstant="%s">`, time.Now().Add(-1*time.Hour).Format(time.RFC3339))
+	saml = credentials.SamlAssertion(base64.StdEncoding.EncodeToString([]byte(rawSaml)))
+	if c.samlValid() {
+		t.Error("expired assertion is valid")
+	}
+
+	var nilClient *baseClient
+	nilClient.SetSamlAssertion(&saml)
+	if nilClient.samlValid() {
+		t.Error("nil client has a valid assertion")
+	}
+}
+
+func TestBaseClient_Pkce(t *testing.T) {
+	// a legacy authorization server, rejecting requests with PKCE parameters using the redirect, or a 400 status
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if len(q.Get("code_challenge")) > 0 {
+			switch r.URL.Path {
+			case "/status":
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error": "invalid_request", "error_description": "code_challenge not supported"}`))
+			case "/bare-status":
+				w.WriteHeader(http.StatusBadRequest)
+			case "/other-error":
+				http.Redirect(w, r, "http://localhost/cb?error=invalid_request&error_description=missing+scope&state="+
+					q.Get("state"), http.StatusFound)
+			default:
+				http.Redirect(w, r, "http://localhost/cb?error=invalid_request&error_description=code_challenge+not+supported&state="+
+					q.Get("state"), http.StatusFound)
+			}
+			return
+		}
+		http.Redirect(w, r, "http://localhost/cb?code=abc&state="+q.Get("state"), http.StatusFound)
+	}))
+	defer srv.Close()
+
+	newClient := func(mode string) *baseClient {
+		c, _ := newBaseClient(srv.URL)
+		c.ClientId = "my-app"
+		c.RedirectUri = "http://localhost/cb"
+		c.PkceMode = mode
+		c.Logger = new(shared.DefaultLogger)
+		return c
+	}
+
+	t.Run("modes", func(t *testing.T) {
+		for mode, want := range map[string]bool{"": true, config.PkceAuto: true, config.PkceRequired: true, config.PkceDisabled: false, "Disabled": false} {
+			qs := newClient(mode).pkceAuthzRequest("challenge")
+			if (qs.Get("code_challenge") == "challenge") != want || (qs.Get("code_challenge_method") == "S256") != want {
+				t.Errorf("mode '%s' unexpected query: %v", mode, qs)
+			}
+		}
+	})
+
+	for _, path := range []string{"/redirect", "/status"} {
+		t.Run("auto fallback "+path, func(t *testing.T) {
+			c := newClient(config.PkceAuto)
+			vals, err := c.oauthAuthorize(srv.URL+path, c.pkceAuthzRequest("challenge"), false)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if vals.Get("code") != "abc" || c.pkceEnabled() {
+				t.Errorf("did not fall back to authorization without PKCE: %v", vals)
+			}
+		})
+	}
+
+	// only an error saying the PKCE parameters are not supported is a reason to retry without them
+	for _, path := range []string{"/other-error", "/bare-status"} {
+		t.Run("no fallback "+path, func(t *testing.T) {
+			c := newClient(config.PkceAuto)
+			vals, _ := c.oauthAuthorize(srv.URL+path, c.pkceAuthzRequest("challenge"), false)
+			if vals.Get("code") == "abc" || !c.pkceEnabled() {
+				t.Errorf("unexpected fallback: %v", vals)
+			}
+		})
+	}
+
+	t.Run("required", func(t *testing.T) {
+		c := newClient(config.PkceRequired)
+		vals, err := c.oauthAuthorize(srv.URL+"/redirect", c.pkceAuthzRequest("challenge"), false)
+		if err != nil || vals.Get("error") != "invalid_request" || !c.pkceEnabled() {
+			t.Errorf("unexpected fallback: %v, %v", vals, err)
+		}
+
+		if _, err = c.oauthAuthorize(srv.URL+"/status", c.pkceAuthzRequest("challenge"), false); !errors.Is(err, errAuthorizeBadRequest) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("token verifier", func(t *testing.T) {
+		var verifier []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = r.ParseForm()
+			verifier = r.PostForm["code_verifier"]
+			_, _ = w.Write([]byte(`{"id_token": "x.y.z"}`))
+		}))
+		defer ts.Close()
+
+		if _, err := newClient(config.PkceAuto).oauthToken(ts.URL, "abc", "verifier"); err != nil || len(verifier) != 1 {
+			t.Errorf("code verifier not sent: %v", err)
+		}
+
+		if _, err := newClient(config.PkceDisabled).oauthToken(ts.URL, "abc", "verifier"); err != nil || len(verifier) != 0 {
+			t.Errorf("code verifier sent with PKCE disabled: %v", err)
+		}
+	})
+}
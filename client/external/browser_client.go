@@ -21,6 +21,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -36,6 +37,7 @@ import (
 
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"github.com/mmmorris1975/aws-runas/identity"
+	"github.com/mmmorris1975/aws-runas/shared"
 )
 
 const (
@@ -66,17 +68,26 @@ func (c *browserClient) Identity() (*identity.Identity, error) {
 	return c.identity(browserProvider), nil
 }
 
+// IdentityWithContext calls Identity, the context is not used.
+func (c *browserClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return c.Identity()
+}
+
 // Authenticate calls AuthenticateWithContext using a background context.
 func (c *browserClient) Authenticate() error {
 	return c.AuthenticateWithContext(context.Background())
 }
 
 // AuthenticateWithContext uses Chromedp to open a browser for the authentication process.
-func (c *browserClient) AuthenticateWithContext(context.Context) error {
+func (c *browserClient) AuthenticateWithContext(ctx context.Context) error {
 	if c.baseClient == nil || c.Logger == nil {
 		return errNilClient
 	}
 
+	if err := checkLogin(ctx); err != nil {
+		return err
+	}
+
 	dir, err := homedir.Dir()
 	if err != nil {
 		return fmt.Errorf("resolve home dir: %w", err)
@@ -296,14 +307,27 @@ func waitForProcessExit(pid int, max time.Duration) {
 	}
 }
 
+// awsSamlSigninUrls are the AWS sign-in endpoints, for the commercial, GovCloud and China partitions, which receive
+// the SAMLResponse POSTed by the identity provider.
+var awsSamlSigninUrls = []string{
+	"https://signin.aws.amazon.com/saml",
+	"https://signin.amazonaws-us-gov.com/saml",
+	"https://signin.amazonaws.cn/saml",
+}
+
+// isAwsSamlSigninUrl returns true if u is one of the awsSamlSigninUrls.
+func isAwsSamlSigninUrl(u string) bool {
+	return slices.Contains(awsSamlSigninUrls, u)
+}
+
 // targetListener listens for the SAML POST to AWS and extracts the SAMLResponse.
 func (c *browserClient) targetListener(ev any, once *sync.Once) {
 	switch ev := ev.(type) { //nolint:gocritic
 	case *network.EventRequestWillBeSent:
-		if ev.Request.URL == `https://signin.aws.amazon.com/saml` {
+		if isAwsSamlSigninUrl(ev.Request.URL) {
 			for i, entry := range ev.Request.PostDataEntries {
 				decoded, _ := base64.StdEncoding.DecodeString(entry.Bytes)
-				c.Logger.Debugf("%d - %s\n", i, string(decoded))
+				c.Logger.Debugf("%d - %s\n", i, shared.Redact(string(decoded)))
 				qs, err := url.ParseQuery(string(decoded))
 				if err != nil {
 					c.Logger.Errorf("Error parsing SAMLResponse: %v", err)
@@ -324,12 +348,17 @@ func (c *browserClient) targetListener(ev any, once *sync.Once) {
 	}
 }
 
-// Roles retrieves the available roles for the user.  Attempting to call this method
+// Roles calls RolesWithContext using a background context.
+func (c *browserClient) Roles(user ...string) (*identity.Roles, error) {
+	return c.RolesWithContext(context.Background(), user...)
+}
+
+// RolesWithContext retrieves the available roles for the user.  Attempting to call this method
 // against an Oauth/OIDC client will return an error.
-func (c *browserClient) Roles(...string) (*identity.Roles, error) {
+func (c *browserClient) RolesWithContext(ctx context.Context, _ ...string) (*identity.Roles, error) {
 	if c.saml == nil || len(*c.saml) < 1 {
 		var err error
-		c.saml, err = c.SamlAssertion()
+		c.saml, err = c.SamlAssertionWithContext(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -360,6 +389,11 @@ func (c *browserClient) SamlAssertionWithContext(ctx context.Context) (*credenti
 		c.baseClient = new(baseClient)
 	}
 
+	// a browser login is needed for each assertion, so reuse the assertion while it's valid
+	if c.samlValid() {
+		return c.saml, nil
+	}
+
 	err := c.AuthenticateWithContext(ctx)
 	if err != nil {
 		return nil, err
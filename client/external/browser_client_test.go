@@ -76,3 +76,16 @@ func TestBrowserClient_SamlAssertion(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestIsAwsSamlSigninUrl(t *testing.T) {
+	for _, u := range []string{"https://signin.aws.amazon.com/saml", "https://signin.amazonaws-us-gov.com/saml",
+		"https://signin.amazonaws.cn/saml"} {
+		if !isAwsSamlSigninUrl(u) {
+			t.Errorf("%s not matched", u)
+		}
+	}
+
+	if isAwsSamlSigninUrl("https://signin.aws.amazon.com/saml/acs/other") {
+		t.Error("unexpected match")
+	}
+}
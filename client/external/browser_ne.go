@@ -55,6 +55,11 @@ func (c *browserNEClient) Identity() (*identity.Identity, error) {
 	return c.identity(browserNEProvider), nil
 }
 
+// IdentityWithContext calls Identity, the context is not used.
+func (c *browserNEClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return c.Identity()
+}
+
 // Authenticate calls AuthenticateWithContext using a background context.
 func (c *browserNEClient) Authenticate() error {
 	return c.AuthenticateWithContext(context.Background())
@@ -63,7 +68,11 @@ func (c *browserNEClient) Authenticate() error {
 // AuthenticateWithContext uses Chromedp to open a browser for the authentication process.
 //
 //nolint:funlen
-func (c *browserNEClient) AuthenticateWithContext(context.Context) error {
+func (c *browserNEClient) AuthenticateWithContext(ctx context.Context) error {
+	if err := checkLogin(ctx); err != nil {
+		return err
+	}
+
 	var err error
 	var samlassertion credentials.SamlAssertion
 	c.Logger.Debugf("Starting a browser to authenticate with the New Experience flow...")
@@ -182,12 +191,17 @@ func (c *browserNEClient) AuthenticateWithContext(context.Context) error {
 	return nil
 }
 
-// Roles retrieves the available roles for the user.  Attempting to call this method
+// Roles calls RolesWithContext using a background context.
+func (c *browserNEClient) Roles(user ...string) (*identity.Roles, error) {
+	return c.RolesWithContext(context.Background(), user...)
+}
+
+// RolesWithContext retrieves the available roles for the user.  Attempting to call this method
 // against an Oauth/OIDC client will return an error.
-func (c *browserNEClient) Roles(...string) (*identity.Roles, error) {
+func (c *browserNEClient) RolesWithContext(ctx context.Context, _ ...string) (*identity.Roles, error) {
 	if c.saml == nil || len(*c.saml) < 1 {
 		var err error
-		c.saml, err = c.SamlAssertion()
+		c.saml, err = c.SamlAssertionWithContext(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -216,6 +230,12 @@ func (c *browserNEClient) SamlAssertionWithContext(ctx context.Context) (*creden
 	if c.baseClient == nil {
 		c.baseClient = new(baseClient)
 	}
+
+	// a browser login is needed for each assertion, so reuse the assertion while it's valid
+	if c.samlValid() {
+		return c.saml, nil
+	}
+
 	err := c.AuthenticateWithContext(ctx)
 	if err != nil {
 		return nil, err
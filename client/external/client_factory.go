@@ -16,6 +16,7 @@ package external
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -32,6 +33,10 @@ const (
 	unknownProvider   = "unknown"
 	azureadProvider   = "azuread"
 	browserProvider   = "browser"
+	// tokenExchangeProvider is selected when the configuration has a subject token file, since the token exchange
+	// endpoint can not be auto-detected.
+	tokenExchangeProvider = "tokenexchange"
+	loopbackProvider      = "loopback"
 	// Alias for browser provider to select new experience login flows.
 	browserNEProvider            = "browserne"
 	browserNewExperienceProvider = "browserNewExperience"
@@ -46,7 +51,12 @@ func GetSamlClient(provider, authUrl string, cfg AuthenticationClientConfig) (Sa
 	if err != nil {
 		return nil, err
 	}
-	return c.(SamlClient), nil
+
+	sc, ok := c.(SamlClient)
+	if !ok {
+		return nil, fmt.Errorf("identity provider type %s does not support SAML", provider)
+	}
+	return sc, nil
 }
 
 // MustGetSamlClient calls GetSamlClient and panics if an error is returned.
@@ -81,6 +91,10 @@ func MustGetWebIdentityClient(provider, authUrl string, cfg OidcClientConfig) We
 //
 //nolint:gocyclo,funlen,gocognit
 func lookupClient(provider, authUrl string, cfg OidcClientConfig) (any, error) {
+	if len(provider) < 1 && len(cfg.SubjectTokenFile) > 0 {
+		provider = tokenExchangeProvider
+	}
+
 	if len(provider) < 1 {
 		provider = divineClient(authUrl, http.MethodHead)
 	}
@@ -153,6 +167,22 @@ func lookupClient(provider, authUrl string, cfg OidcClientConfig) (any, error) {
 		c.Logger = cfg.Logger
 		c.MfaType = cfg.MfaType
 		return c, nil
+	case loopbackProvider:
+		c, err := NewLoopbackClient(authUrl)
+		if err != nil {
+			return nil, err
+		}
+		c.OidcClientConfig = cfg
+		c.Logger = cfg.Logger
+		return c, nil
+	case tokenExchangeProvider:
+		c, err := NewTokenExchangeClient(authUrl)
+		if err != nil {
+			return nil, err
+		}
+		c.OidcClientConfig = cfg
+		c.Logger = cfg.Logger
+		return c, nil
 	case browserNEProvider, browserNewExperienceProvider:
 		c, err := NewBrowserNEClient(authUrl)
 		if err != nil {
@@ -196,7 +226,7 @@ func divineClient(u, method string) string {
 	resCh := make(chan *http.Response, 1)
 	go func(req *http.Request) {
 		// we don't care about the HTTP status, nearly all non-200 responses contain the info we need
-		res, err := http.DefaultClient.Do(req)
+		res, err := newHttpClient().Do(req)
 		if err != nil {
 			errCh <- err
 			return
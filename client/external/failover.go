@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mmmorris1975/aws-runas/shared"
+)
+
+type failoverTransport struct {
+	rt        http.RoundTripper
+	endpoints []*url.URL
+	active    atomic.Int32
+	logger    shared.Logger
+}
+
+// NewFailoverTransport creates an http.RoundTripper which sends requests for the first (primary) identity provider
+// endpoint to the other endpoints, in order, if the request fails with a connection error or an HTTP 5xx status.  The
+// endpoints are expected to differ only by the scheme and host (like the primary and DR farm of an ADFS service), and
+// once an endpoint fails, later requests start with the endpoint which last succeeded.  Requests for any other host are
+// sent unchanged using rt, which will be the default transport from the net/http package if nil.
+func NewFailoverTransport(rt http.RoundTripper, endpoints []string, logger shared.Logger) (*failoverTransport, error) {
+	if len(endpoints) < 1 {
+		return nil, errors.New("no identity provider endpoints")
+	}
+
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	if logger == nil {
+		logger = new(shared.DefaultLogger)
+	}
+
+	t := &failoverTransport{rt: rt, logger: logger}
+	for _, ep := range endpoints {
+		u, err := url.Parse(ep)
+		if err != nil || !strings.HasPrefix(u.Scheme, "http") || len(u.Host) < 1 {
+			return nil, errors.New("invalid identity provider endpoint " + ep)
+		}
+		t.endpoints = append(t.endpoints, u)
+	}
+	return t, nil
+}
+
+// RoundTrip is the implementation of the http.RoundTripper interface.
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	primary := t.endpoints[0]
+	if req.URL.Scheme != primary.Scheme || !strings.EqualFold(req.URL.Host, primary.Host) {
+		return t.rt.RoundTrip(req)
+	}
+
+	start := int(t.active.Load())
+	for i := range t.endpoints {
+		idx := (start + i) % len(t.endpoints)
+
+		r, err := t.endpointRequest(req, idx, i > 0)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := t.rt.RoundTrip(r)
+		if (err == nil && res.StatusCode < http.StatusInternalServerError) || req.Context().Err() != nil {
+			t.active.Store(int32(idx)) //nolint:gosec // endpoint count is tiny
+			return res, err
+		}
+
+		// the request body can only be sent again if the request provides a way to get a new copy
+		if i == len(t.endpoints)-1 || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+			return res, err
+		}
+
+		if err == nil {
+			res.Body.Close()
+			t.logger.Warningf("identity provider endpoint %s returned %s", t.endpoints[idx].Host, res.Status)
+		} else {
+			t.logger.Warningf("identity provider endpoint %s failed: %v", t.endpoints[idx].Host, err)
+		}
+		t.logger.Infof("trying identity provider endpoint %s", t.endpoints[(idx+1)%len(t.endpoints)].Host)
+	}
+
+	// not reachable, the loop always returns on the last endpoint
+	return nil, errors.New("no identity provider endpoints")
+}
+
+// endpointRequest returns a copy of the request which is sent to the endpoint at idx.  If retry is true, the request
+// body is replaced with a new copy, since the body of the failed request may have been consumed.
+func (t *failoverTransport) endpointRequest(req *http.Request, idx int, retry bool) (*http.Request, error) {
+	if idx == 0 && !retry {
+		return req, nil
+	}
+
+	r := req.Clone(req.Context())
+	r.URL.Scheme = t.endpoints[idx].Scheme
+	r.URL.Host = t.endpoints[idx].Host
+	r.Host = ""
+
+	if retry && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return r, nil
+}
+
+// DetectProvider returns the name of the identity provider found at the first of the authUrls where the provider can be
+// determined, for use when the primary endpoint of an identity provider may be unavailable.
+func DetectProvider(authUrls ...string) string {
+	for _, u := range authUrls {
+		if p := divineClient(u, http.MethodHead); len(p) > 0 && p != unknownProvider {
+			return p
+		}
+	}
+	return unknownProvider
+}
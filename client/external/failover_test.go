@@ -0,0 +1,213 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewFailoverTransport(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		ft, err := NewFailoverTransport(nil, []string{"https://primary.example.com/adfs", "https://dr.example.com/adfs"}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if ft.rt == nil || ft.logger == nil || len(ft.endpoints) != 2 {
+			t.Error("unexpected transport settings")
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if _, err := NewFailoverTransport(nil, nil, nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := NewFailoverTransport(nil, []string{"https://primary.example.com", "dr.example.com"}, nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestFailoverTransport_RoundTrip(t *testing.T) {
+	t.Run("server error", func(t *testing.T) {
+		primary := newFailoverServer(http.StatusServiceUnavailable)
+		defer primary.Close()
+		dr := newFailoverServer(http.StatusOK)
+		defer dr.Close()
+
+		c := newFailoverClient(t, primary.URL+"/adfs", dr.URL+"/adfs")
+		req, _ := newHttpRequest(context.Background(), http.MethodPost, primary.URL+"/adfs/ls")
+		req.withValues(url.Values{"user": {"mock"}})
+
+		res, err := c.Do(req.Request)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK || primary.hits.Load() != 1 || dr.hits.Load() != 1 {
+			t.Errorf("did not fail over: %s", res.Status)
+		}
+
+		if dr.body.Load() != "user=mock" || dr.path.Load() != "/adfs/ls" {
+			t.Errorf("unexpected request: %v %v", dr.path.Load(), dr.body.Load())
+		}
+	})
+
+	t.Run("connection error", func(t *testing.T) {
+		primary := newFailoverServer(http.StatusOK)
+		primary.Close()
+		dr := newFailoverServer(http.StatusOK)
+		defer dr.Close()
+
+		c := newFailoverClient(t, primary.URL, dr.URL)
+		req, _ := newHttpRequest(context.Background(), http.MethodGet, primary.URL)
+
+		res, err := c.Do(req.Request)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer res.Body.Close()
+
+		if dr.hits.Load() != 1 {
+			t.Error("did not fail over")
+		}
+	})
+
+	t.Run("sticky", func(t *testing.T) {
+		primary := newFailoverServer(http.StatusInternalServerError)
+		defer primary.Close()
+		dr := newFailoverServer(http.StatusOK)
+		defer dr.Close()
+
+		c := newFailoverClient(t, primary.URL, dr.URL)
+		for i := 0; i < 3; i++ {
+			req, _ := newHttpRequest(context.Background(), http.MethodGet, primary.URL)
+			res, err := c.Do(req.Request)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			res.Body.Close()
+		}
+
+		if primary.hits.Load() != 1 || dr.hits.Load() != 3 {
+			t.Errorf("unexpected requests: primary %d, dr %d", primary.hits.Load(), dr.hits.Load())
+		}
+	})
+
+	t.Run("all failed", func(t *testing.T) {
+		primary := newFailoverServer(http.StatusServiceUnavailable)
+		defer primary.Close()
+		dr := newFailoverServer(http.StatusBadGateway)
+		defer dr.Close()
+
+		c := newFailoverClient(t, primary.URL, dr.URL)
+		req, _ := newHttpRequest(context.Background(), http.MethodGet, primary.URL)
+
+		res, err := c.Do(req.Request)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusBadGateway {
+			t.Errorf("unexpected status: %s", res.Status)
+		}
+	})
+
+	t.Run("body not replayable", func(t *testing.T) {
+		primary := newFailoverServer(http.StatusServiceUnavailable)
+		defer primary.Close()
+		dr := newFailoverServer(http.StatusOK)
+		defer dr.Close()
+
+		c := newFailoverClient(t, primary.URL, dr.URL)
+		req, _ := newHttpRequest(context.Background(), http.MethodPost, primary.URL)
+		req.withBody(io.NopCloser(strings.NewReader("data")))
+
+		res, err := c.Do(req.Request)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusServiceUnavailable || dr.hits.Load() != 0 {
+			t.Error("unexpected failover")
+		}
+	})
+
+	t.Run("other host", func(t *testing.T) {
+		primary := newFailoverServer(http.StatusOK)
+		defer primary.Close()
+		other := newFailoverServer(http.StatusServiceUnavailable)
+		defer other.Close()
+
+		c := newFailoverClient(t, primary.URL, "http://dr.example.com")
+		req, _ := newHttpRequest(context.Background(), http.MethodGet, other.URL)
+
+		res, err := c.Do(req.Request)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusServiceUnavailable || other.hits.Load() != 1 || primary.hits.Load() != 0 {
+			t.Error("request for other host was not sent unchanged")
+		}
+	})
+}
+
+type failoverServer struct {
+	*httptest.Server
+	hits atomic.Int32
+	path atomic.Value
+	body atomic.Value
+}
+
+func newFailoverServer(status int) *failoverServer {
+	s := new(failoverServer)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		b, _ := io.ReadAll(r.Body)
+		s.path.Store(r.URL.Path)
+		s.body.Store(string(b))
+		w.WriteHeader(status)
+	}))
+	return s
+}
+
+func newFailoverClient(t *testing.T, endpoints ...string) *http.Client {
+	ft, err := NewFailoverTransport(nil, endpoints, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Transport: ft}
+}
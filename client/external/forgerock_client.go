@@ -76,6 +76,10 @@ func (c *forgerockClient) Authenticate() error {
 // AuthenticateWithContext performs authentication against Forgerock using the specified Context, which is passed
 // along to the underlying HTTP requests.  If necessary, it will prompt for the authentication credentials.
 func (c *forgerockClient) AuthenticateWithContext(ctx context.Context) error {
+	if err := checkLogin(ctx); err != nil {
+		return err
+	}
+
 	if err := c.gatherCredentials(); err != nil {
 		return err
 	}
@@ -88,12 +92,22 @@ func (c *forgerockClient) Identity() (*identity.Identity, error) {
 	return c.identity(forgerockIdentityProvider), nil
 }
 
-// Roles retrieves the available roles for the user.  Attempting to call this method
+// IdentityWithContext calls Identity, the context is not used.
+func (c *forgerockClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return c.Identity()
+}
+
+// Roles calls RolesWithContext using a background context.
+func (c *forgerockClient) Roles(user ...string) (*identity.Roles, error) {
+	return c.RolesWithContext(context.Background(), user...)
+}
+
+// RolesWithContext retrieves the available roles for the user.  Attempting to call this method
 // against an Oauth/OIDC client will return an error.
-func (c *forgerockClient) Roles(...string) (*identity.Roles, error) {
+func (c *forgerockClient) RolesWithContext(ctx context.Context, _ ...string) (*identity.Roles, error) {
 	if c.saml == nil || len(*c.saml) < 1 {
 		var err error
-		c.saml, err = c.SamlAssertion()
+		c.saml, err = c.SamlAssertionWithContext(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -354,7 +368,20 @@ func (c *forgerockClient) doAuth(ctx context.Context, u string) ([]byte, error)
 	req.Header.Set("X-OpenAM-Username", rfc2047EncodeString(c.Username))
 	req.Header.Set("X-OpenAM-Password", rfc2047EncodeString(c.Password))
 
-	return c.sendApiRequest(req)
+	done, err := c.waitLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.sendApiRequest(req)
+	if err == nil {
+		done(false)
+	} else if c.isNoFactorErr(err) && !strings.Contains(u, "authIndexType") {
+		// a 401 from an MFA service may only mean the user doesn't have that factor, only count rejections of the
+		// plain login as failures
+		done(true)
+	}
+	return data, err
 }
 
 func (c *forgerockClient) isNoFactorErr(err error) bool {
@@ -90,16 +90,24 @@ func TestForgerockClient_Authenticate(t *testing.T) {
 func TestForgerockClient_Authenticate_Plain(t *testing.T) {
 	t.Run("no mfa good", func(t *testing.T) {
 		c := newMockForgerockClient()
+		lt := new(mockLoginThrottle)
+		c.SetLoginThrottler(lt)
 		c.Username = "nomfa"
 		c.Password = "goodPassword"
 
 		if err := c.Authenticate(); err != nil {
 			t.Error(err)
 		}
+
+		if lt.waits < 1 || lt.failures != 0 || lt.successes != 1 {
+			t.Errorf("unexpected login throttle calls: %+v", *lt)
+		}
 	})
 
 	t.Run("no mfa bad", func(t *testing.T) {
 		c := newMockForgerockClient()
+		lt := new(mockLoginThrottle)
+		c.SetLoginThrottler(lt)
 		c.CredentialInputProvider = func(u, p string) (string, string, error) {
 			return "nomfa", "badPassword", nil
 		}
@@ -107,6 +115,10 @@ func TestForgerockClient_Authenticate_Plain(t *testing.T) {
 		if err := c.Authenticate(); err == nil {
 			t.Error("did not receive expected error")
 		}
+
+		if lt.waits < 1 || lt.failures != 1 || lt.successes != 0 {
+			t.Errorf("unexpected login throttle calls: %+v", *lt)
+		}
 	})
 }
 
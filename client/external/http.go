@@ -16,11 +16,15 @@ package external
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/shared"
 )
 
 type httpRequest struct {
@@ -43,17 +47,32 @@ func (r *httpRequest) withBody(body io.Reader) *httpRequest {
 	var rc io.ReadCloser
 	r.ContentLength = -1
 
+	// in-memory bodies get a GetBody func, so the request can be sent again (like when failing over to another
+	// identity provider endpoint); the reader snapshots are copied the same way net/http.NewRequest does it
 	switch t := body.(type) {
 	case *bytes.Buffer:
 		data := t.Bytes()
 		r.ContentLength = int64(len(data))
 		rc = io.NopCloser(bytes.NewReader(data))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
 	case *bytes.Reader:
 		r.ContentLength = int64(t.Len())
 		rc = io.NopCloser(t)
+		snapshot := *t
+		r.GetBody = func() (io.ReadCloser, error) {
+			br := snapshot
+			return io.NopCloser(&br), nil
+		}
 	case *strings.Reader:
 		r.ContentLength = int64(t.Len())
 		rc = io.NopCloser(t)
+		snapshot := *t
+		r.GetBody = func() (io.ReadCloser, error) {
+			sr := snapshot
+			return io.NopCloser(&sr), nil
+		}
 	case io.ReadCloser:
 		rc = t
 	default:
@@ -69,12 +88,49 @@ func (r *httpRequest) withValues(v url.Values) *httpRequest {
 	return r.withBody(strings.NewReader(v.Encode()))
 }
 
+// getJson sends a GET request to the url, and decodes the JSON response body into out.
+func getJson(ctx context.Context, client *http.Client, u string, out any) error {
+	req, err := newHttpRequest(ctx, http.MethodGet, u)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", contentTypeJson)
+
+	res, err := checkResponseError(client.Do(req.Request))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// discoveryUrl returns the location of the OpenID Connect discovery document for the issuer.
+func discoveryUrl(issuer string) string {
+	return strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+}
+
 func checkResponseError(r *http.Response, err error) (*http.Response, error) {
 	if err != nil {
 		return nil, err
-	} else if r.StatusCode != http.StatusOK {
+	}
+
+	recordServerTime(r)
+	if r.StatusCode != http.StatusOK {
 		r.Body.Close()
 		return nil, fmt.Errorf("http status %s (%d)", r.Status, r.StatusCode)
 	}
 	return r, err
 }
+
+// recordServerTime uses the Date header of the identity provider response to detect local clock skew, so the
+// expiration of credentials obtained using the response can be adjusted to the local clock.
+func recordServerTime(r *http.Response) {
+	if r == nil {
+		return
+	}
+
+	if t, err := http.ParseTime(r.Header.Get("Date")); err == nil {
+		shared.RecordServerTime(t, time.Now())
+	}
+}
@@ -21,6 +21,9 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/shared"
 )
 
 func Test_newHttpRequest(t *testing.T) {
@@ -160,3 +163,30 @@ func Test_withBody(t *testing.T) {
 		}
 	})
 }
+
+func Test_recordServerTime(t *testing.T) {
+	defer shared.RecordServerTime(time.Now(), time.Now())
+
+	t.Run("skewed", func(t *testing.T) {
+		res := &http.Response{Header: http.Header{}}
+		res.Header.Set("Date", time.Now().Add(-1*time.Hour).UTC().Format(http.TimeFormat))
+		recordServerTime(res)
+
+		if skew := shared.ClockSkew(); skew > -59*time.Minute || skew < -61*time.Minute {
+			t.Errorf("unexpected clock skew: %s", skew)
+		}
+	})
+
+	t.Run("missing header", func(t *testing.T) {
+		shared.RecordServerTime(time.Now(), time.Now())
+		recordServerTime(&http.Response{Header: http.Header{}})
+
+		if shared.ClockSkew() != 0 {
+			t.Error("unexpected clock skew")
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		recordServerTime(nil)
+	})
+}
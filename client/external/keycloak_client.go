@@ -67,6 +67,10 @@ func (c *keycloakClient) Authenticate() error {
 // AuthenticateWithContext performs authentication against Keycloak using the specified Context, which is passed
 // along to the underlying HTTP requests.  If necessary, it will prompt for the authentication credentials.
 func (c *keycloakClient) AuthenticateWithContext(ctx context.Context) error {
+	if err := checkLogin(ctx); err != nil {
+		return err
+	}
+
 	if err := c.gatherCredentials(); err != nil {
 		return err
 	}
@@ -79,17 +83,27 @@ func (c *keycloakClient) Identity() (*identity.Identity, error) {
 	return c.identity(keycloakIdentityProvider), nil
 }
 
+// IdentityWithContext calls Identity, the context is not used.
+func (c *keycloakClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return c.Identity()
+}
+
 // IdentityToken calls IdentityTokenWithContext with a background context.
 func (c *keycloakClient) IdentityToken() (*credentials.OidcIdentityToken, error) {
 	return c.IdentityTokenWithContext(context.Background())
 }
 
-// Roles retrieves the available roles for the user.  Attempting to call this method
+// Roles calls RolesWithContext using a background context.
+func (c *keycloakClient) Roles(user ...string) (*identity.Roles, error) {
+	return c.RolesWithContext(context.Background(), user...)
+}
+
+// RolesWithContext retrieves the available roles for the user.  Attempting to call this method
 // against an Oauth/OIDC client will return an error.
-func (c *keycloakClient) Roles(...string) (*identity.Roles, error) {
+func (c *keycloakClient) RolesWithContext(ctx context.Context, _ ...string) (*identity.Roles, error) {
 	if c.saml == nil || len(*c.saml) < 1 {
 		var err error
-		c.saml, err = c.SamlAssertion()
+		c.saml, err = c.SamlAssertionWithContext(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -115,7 +129,7 @@ func (c *keycloakClient) IdentityTokenWithContext(ctx context.Context) (*credent
 		// an error here means we might need to (re-)authenticate
 		if strings.Contains(err.Error(), "status 200") {
 			u := fmt.Sprintf("%s?%s", authUrl, authzQS.Encode())
-			if err = c.formAuth(u); err != nil {
+			if err = c.formAuth(ctx, u); err != nil {
 				return nil, err
 			}
 			return c.IdentityTokenWithContext(ctx)
@@ -150,7 +164,7 @@ func (c *keycloakClient) SamlAssertionWithContext(ctx context.Context) (*credent
 	}
 
 	if c.saml == nil || len(*c.saml) < 1 {
-		if err := c.formAuth(c.authUrl.String()); err != nil {
+		if err := c.formAuth(ctx, c.authUrl.String()); err != nil {
 			return nil, err
 		}
 		return c.SamlAssertionWithContext(ctx)
@@ -170,7 +184,7 @@ func (c *keycloakClient) auth(ctx context.Context) error {
 	return err
 }
 
-func (c *keycloakClient) formAuth(authUrl string) error {
+func (c *keycloakClient) formAuth(ctx context.Context, authUrl string) error {
 	if err := c.gatherCredentials(); err != nil {
 		return err
 	}
@@ -182,7 +196,12 @@ func (c *keycloakClient) formAuth(authUrl string) error {
 
 	var req *httpRequest
 	var res *http.Response
-	req, err = newHttpRequest(context.Background(), http.MethodPost, submitUrl.String())
+	req, err = newHttpRequest(ctx, http.MethodPost, submitUrl.String())
+	if err != nil {
+		return err
+	}
+
+	done, err := c.waitLogin(ctx)
 	if err != nil {
 		return err
 	}
@@ -194,6 +213,7 @@ func (c *keycloakClient) formAuth(authUrl string) error {
 	defer res.Body.Close()
 
 	if c.isAuthSuccess(res.Cookies()) {
+		done(false)
 		return nil
 	}
 
@@ -204,9 +224,19 @@ func (c *keycloakClient) formAuth(authUrl string) error {
 	}
 
 	// HTTP 200 is returned for auth success, auth failure, and mfa prompting ... need to figure out which this is
+	done(c.isLoginForm(body))
 	return c.handle200(body)
 }
 
+// isLoginForm returns true if the page is the login form, which Keycloak shows again when the credentials are rejected.
+func (c *keycloakClient) isLoginForm(data []byte) bool {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+	return doc.Find(`form input[name="password"]`).Length() > 0
+}
+
 func (c *keycloakClient) parseForm(authUrl string) (*url.URL, url.Values, error) {
 	req, err := newHttpRequest(context.Background(), http.MethodGet, authUrl)
 	if err != nil {
@@ -270,6 +300,12 @@ func (c *keycloakClient) handle200(data []byte) error {
 
 	form := doc.Find("form").First()
 	submitUrl := form.AttrOr("action", "")
+
+	// the update password form is shown after a successful login when the password has expired
+	if form.Find(`input[name="password-new"]`).Length() > 0 {
+		return c.updatePassword(submitUrl, form)
+	}
+
 	mfaField := form.Find("input").FilterFunction(func(i int, s *goquery.Selection) bool {
 		if t, ok := s.Attr("name"); ok && strings.HasSuffix(t, "otp") {
 			return true
@@ -320,3 +356,66 @@ func (c keycloakClient) doMfa(submitUrl, mfaField string) error {
 	}
 	return c.handle200(body)
 }
+
+// updatePassword submits the Keycloak update password form, replacing the expired password.  If Keycloak shows the
+// form again, the new password was rejected and the error message from the page is returned.
+func (c *keycloakClient) updatePassword(submitUrl string, form *goquery.Selection) error {
+	pw, err := c.newPassword()
+	if err != nil {
+		return err
+	}
+
+	vals := url.Values{}
+	form.Find("input").Each(func(i int, s *goquery.Selection) {
+		n, ok := s.Attr("name")
+		if !ok {
+			return
+		}
+
+		switch n {
+		case "password-new", "password-confirm":
+			vals.Set(n, pw)
+		default:
+			if t := s.AttrOr("type", ""); t != "submit" && t != "reset" && t != "checkbox" {
+				vals.Set(n, s.AttrOr("value", ""))
+			}
+		}
+	})
+
+	req, err := newHttpRequest(context.Background(), http.MethodPost, submitUrl)
+	if err != nil {
+		return err
+	}
+
+	res, err := checkResponseError(c.httpClient.Do(req.withValues(vals).Request))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if c.isAuthSuccess(res.Cookies()) {
+		c.passwordChanged(pw)
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1024*1024))
+	if err != nil {
+		return err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	if doc.Find(`input[name="password-new"]`).Length() > 0 {
+		msg := strings.TrimSpace(doc.Find("#input-error-password, .kc-feedback-text").First().Text())
+		if len(msg) < 1 {
+			msg = "new password rejected"
+		}
+		return fmt.Errorf("password change failed: %s", msg)
+	}
+
+	c.passwordChanged(pw)
+	return c.handle200(body)
+}
@@ -97,6 +97,8 @@ func TestKeycloakClient_Authenticate_Saml(t *testing.T) {
 	t.Run("bad creds", func(t *testing.T) {
 		c := newMockKeycloakClient()
 		c.authUrl.Path = samlPath
+		lt := new(mockLoginThrottle)
+		c.SetLoginThrottler(lt)
 		c.CredentialInputProvider = func(user, password string) (string, string, error) {
 			return "nomfa", "badPassword", nil
 		}
@@ -104,6 +106,10 @@ func TestKeycloakClient_Authenticate_Saml(t *testing.T) {
 		if err := c.Authenticate(); err == nil {
 			t.Error("did not receive expected error")
 		}
+
+		if lt.waits != 1 || lt.failures != 1 || lt.successes != 0 {
+			t.Errorf("unexpected login throttle calls: %+v", *lt)
+		}
 	})
 
 	t.Run("no mfa", func(t *testing.T) {
@@ -134,10 +140,81 @@ func TestKeycloakClient_Authenticate_Saml(t *testing.T) {
 		// must use a distinct http.Client for successful auth tests, to avoid seeing cookies from other tests
 		c.httpClient = new(http.Client)
 		c.setHttpClient()
+		lt := new(mockLoginThrottle)
+		c.SetLoginThrottler(lt)
 
 		if err := c.Authenticate(); err != nil {
 			t.Error(err)
 		}
+
+		if lt.waits != 1 || lt.failures != 0 || lt.successes != 1 {
+			t.Errorf("unexpected login throttle calls: %+v", *lt)
+		}
+	})
+}
+
+func TestKeycloakClient_Authenticate_PasswordExpired(t *testing.T) {
+	samlPath := "/realms/test/protocol/saml/clients/aws"
+
+	t.Run("no provider", func(t *testing.T) {
+		c := newMockKeycloakClient()
+		c.authUrl.Path = samlPath
+		c.Username = "expired"
+		c.Password = "goodPassword"
+
+		if err := c.Authenticate(); !errors.Is(err, ErrPasswordExpired) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		var stored string
+		c := newMockKeycloakClient()
+		c.authUrl.Path = samlPath
+		c.Username = "expired"
+		c.Password = "goodPassword"
+		c.NewPasswordProvider = func() (string, error) {
+			return "newPassword", nil
+		}
+		c.PasswordUpdater = func(password string) error {
+			stored = password
+			return nil
+		}
+
+		// must use a distinct http.Client for successful auth tests, to avoid seeing cookies from other tests
+		c.httpClient = new(http.Client)
+		c.setHttpClient()
+
+		if err := c.Authenticate(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if c.Password != "newPassword" || stored != "newPassword" {
+			t.Error("password not updated")
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		c := newMockKeycloakClient()
+		c.authUrl.Path = samlPath
+		c.Username = "expired"
+		c.Password = "goodPassword"
+		c.NewPasswordProvider = func() (string, error) {
+			return "weak", nil
+		}
+
+		c.httpClient = new(http.Client)
+		c.setHttpClient()
+
+		err := c.Authenticate()
+		if err == nil || !strings.Contains(err.Error(), "minimum length") {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+
+		if c.Password != "goodPassword" {
+			t.Error("password updated after rejection")
+		}
 	})
 }
 
@@ -422,12 +499,23 @@ func mockKeycloakHandler(w http.ResponseWriter, r *http.Request) {
 				w.Header().Set("Content-Type", "text/html")
 				_, _ = fmt.Fprintf(w, mfaForm, r.Host)
 				return
+			case "expired":
+				w.Header().Set("Content-Type", "text/html")
+				_, _ = fmt.Fprintf(w, updatePasswordForm, r.Host, "")
+				return
 			default:
 				http.Error(w, "invalid username or password", http.StatusUnauthorized)
 				return
 			}
 		}
 
+		if len(r.PostFormValue("password")) > 0 {
+			// rejected credentials re-return the login form w/ http 200
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = fmt.Fprintf(w, loginForm, r.Host)
+			return
+		}
+
 		mfaData := r.PostFormValue("otp")
 		if len(mfaData) > 0 {
 			if mfaData == "54321" {
@@ -442,6 +530,19 @@ func mockKeycloakHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		http.Error(w, "invalid request", http.StatusBadRequest)
+	case strings.Contains(p, "/login-actions/required-action"):
+		// update password form handler
+		pw := r.PostFormValue("password-new")
+		if pw != r.PostFormValue("password-confirm") || pw == "weak" {
+			// rejected passwords re-return the form w/ http 200
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = fmt.Fprintf(w, updatePasswordForm, r.Host,
+				`<span id="input-error-password">Invalid password: minimum length 8.</span>`)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{Name: "KEYCLOAK_SESSION", Value: "authenticated", Secure: false, Path: "/"})
+		_, _ = w.Write(nil)
 	default:
 		http.NotFound(w, r)
 	}
@@ -470,3 +571,17 @@ var mfaForm = `
 </body>
 </html>
 `
+
+var updatePasswordForm = `
+<html>
+<head></head>
+<body>
+ <form id="kc-passwd-update-form" method="post" action="http://%s/auth/realms/master/login-actions/required-action">
+  <input type="password" id="password-new" name="password-new" />
+  <input type="password" id="password-confirm" name="password-confirm" />
+  <input type="checkbox" id="logout-sessions" name="logout-sessions" value="on" checked />
+  %s
+ </form>
+</body>
+</html>
+`
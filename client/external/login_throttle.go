@@ -0,0 +1,210 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLoginMaxFailures is the number of consecutive failed password submissions allowed before the
+	// LoginThrottler stops sending passwords to the identity provider.
+	DefaultLoginMaxFailures = 3
+	// DefaultLoginMinInterval is the minimum time between password submissions for a user.  The wait time doubles
+	// after each consecutive failure.
+	DefaultLoginMinInterval = 2 * time.Second
+	// DefaultLoginResetInterval is the amount of time after the last failure before another password submission
+	// will be allowed once the failure limit is reached.
+	DefaultLoginResetInterval = 15 * time.Minute
+)
+
+// ErrTooManyLoginFailures is returned when the number of consecutive failed password submissions for a user has
+// reached the configured limit.  No password will be sent to the identity provider until the reset interval passes.
+var ErrTooManyLoginFailures = errors.New("too many failed login attempts, not sending password to identity provider")
+
+var defaultLoginThrottle = NewLoginThrottle("")
+
+type loginThrottle struct {
+	// MaxFailures is the number of consecutive failures before password submissions are blocked
+	MaxFailures int
+	// MinInterval is the minimum wait between password submissions, doubled after each consecutive failure
+	MinInterval time.Duration
+	// ResetInterval is the time after the last failure when password submissions are allowed again
+	ResetInterval time.Duration
+
+	path  string
+	mu    sync.Mutex
+	state map[string]*loginAttempts
+}
+
+type loginAttempts struct {
+	Failures int       `json:"failures"`
+	Last     time.Time `json:"last"`
+}
+
+// NewLoginThrottle creates a LoginThrottler using the default limits.  If path is not empty, the login attempt
+// history is persisted to that file, so the limits are shared by all aws-runas processes using the file.  Otherwise,
+// the history is only kept in memory.
+func NewLoginThrottle(path string) *loginThrottle {
+	return &loginThrottle{
+		MaxFailures:   DefaultLoginMaxFailures,
+		MinInterval:   DefaultLoginMinInterval,
+		ResetInterval: DefaultLoginResetInterval,
+		path:          path,
+		state:         make(map[string]*loginAttempts),
+	}
+}
+
+// Wait is the implementation of the LoginThrottler interface which blocks until a password can be sent for the
+// given key.  ErrTooManyLoginFailures is returned if the failure limit has been reached, or the context error if the
+// context is done before the wait has completed.
+func (t *loginThrottle) Wait(ctx context.Context, key string) error {
+	t.mu.Lock()
+	t.load()
+	a := *t.attempts(key)
+	t.mu.Unlock()
+
+	since := time.Since(a.Last)
+	if t.MaxFailures > 0 && a.Failures >= t.MaxFailures {
+		if since < t.ResetInterval {
+			return fmt.Errorf("%w, retry after %s", ErrTooManyLoginFailures, a.Last.Add(t.ResetInterval).Format(time.RFC3339))
+		}
+		// reset interval passed, allow a single attempt
+		return nil
+	}
+
+	// cap the exponent to avoid overflow if the failure limit is disabled
+	shift := min(a.Failures, 10)
+	wait := (t.MinInterval << shift) - since
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Failure is the implementation of the LoginThrottler interface which records a failed password submission.
+func (t *loginThrottle) Failure(key string) {
+	t.update(key, func(a *loginAttempts) {
+		a.Failures++
+		a.Last = time.Now()
+	})
+}
+
+// Success is the implementation of the LoginThrottler interface which records a successful password submission,
+// clearing the failure count.
+func (t *loginThrottle) Success(key string) {
+	t.update(key, func(a *loginAttempts) {
+		a.Failures = 0
+		a.Last = time.Now()
+	})
+}
+
+// update applies fn to the login attempts for key.  Other processes may update the file at any time, so the lock file
+// is held from loading the state until the updated state is written, otherwise the attempts recorded by the other
+// process are lost.  If the lock can't be taken, the update is still made, since the state is best-effort.
+func (t *loginThrottle) update(key string, fn func(a *loginAttempts)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.path) > 0 && os.MkdirAll(filepath.Dir(t.path), 0750) == nil {
+		if lf, err := lockFile(t.path + ".lock"); err == nil {
+			defer func() { _ = unlockFile(lf) }()
+		}
+	}
+
+	t.load()
+	fn(t.attempts(key))
+	t.flush()
+}
+
+func (t *loginThrottle) attempts(key string) *loginAttempts {
+	k := loginThrottleKey(key)
+	a, ok := t.state[k]
+	if !ok {
+		a = new(loginAttempts)
+		t.state[k] = a
+	}
+	return a
+}
+
+// load refreshes the state from the file, so updates from other processes are seen.  Errors are ignored, and the
+// in-memory state is kept.
+func (t *loginThrottle) load() {
+	if len(t.path) < 1 {
+		return
+	}
+
+	data, err := os.ReadFile(t.path)
+	if err != nil || len(data) < 2 {
+		return
+	}
+
+	state := make(map[string]*loginAttempts)
+	if err = json.Unmarshal(data, &state); err == nil {
+		t.state = state
+	}
+}
+
+// flush writes the state to the file.  This is best-effort, a failure only means the state isn't shared.
+func (t *loginThrottle) flush() {
+	if len(t.path) < 1 {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0750); err != nil {
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(t.path), ".aws_runas_login_*.tmp")
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err = json.NewEncoder(tmp).Encode(t.state); err != nil {
+		return
+	}
+
+	if err = os.Rename(tmp.Name(), t.path); err == nil {
+		_ = os.Chmod(t.path, 0600)
+	}
+}
+
+// don't store usernames in the clear, the hash isn't directly serializable so use hex string encoding.
+func loginThrottleKey(key string) string {
+	h := fnv.New128()
+	_, _ = h.Write([]byte(key))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+//nolint:gochecknoinits // disable the shared throttle so the client tests don't wait between logins
+func init() {
+	defaultLoginThrottle.MinInterval = 0
+	defaultLoginThrottle.MaxFailures = 0
+}
+
+func TestLoginThrottle_Wait(t *testing.T) {
+	t.Run("first attempt", func(t *testing.T) {
+		lt := NewLoginThrottle("")
+		start := time.Now()
+		if err := lt.Wait(context.Background(), "user"); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if time.Since(start) > 100*time.Millisecond {
+			t.Error("unexpected wait")
+		}
+	})
+
+	t.Run("min interval", func(t *testing.T) {
+		lt := NewLoginThrottle("")
+		lt.MinInterval = 50 * time.Millisecond
+		lt.Success("user")
+
+		start := time.Now()
+		if err := lt.Wait(context.Background(), "user"); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if time.Since(start) < 40*time.Millisecond {
+			t.Error("did not wait")
+		}
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		lt := NewLoginThrottle("")
+		lt.MinInterval = 25 * time.Millisecond
+		lt.Failure("user")
+		lt.Failure("user")
+
+		start := time.Now()
+		if err := lt.Wait(context.Background(), "user"); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if time.Since(start) < 90*time.Millisecond {
+			t.Error("did not back off")
+		}
+	})
+
+	t.Run("separate keys", func(t *testing.T) {
+		lt := NewLoginThrottle("")
+		lt.MinInterval = time.Hour
+		lt.Failure("user")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		if err := lt.Wait(ctx, "other"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		lt := NewLoginThrottle("")
+		lt.MinInterval = time.Hour
+		lt.Success("user")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		if err := lt.Wait(ctx, "user"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("circuit open", func(t *testing.T) {
+		lt := NewLoginThrottle("")
+		lt.MinInterval = 0
+		for i := 0; i < lt.MaxFailures; i++ {
+			lt.Failure("user")
+		}
+
+		if err := lt.Wait(context.Background(), "user"); !errors.Is(err, ErrTooManyLoginFailures) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("circuit reset", func(t *testing.T) {
+		lt := NewLoginThrottle("")
+		lt.MinInterval = 0
+		lt.ResetInterval = time.Millisecond
+		for i := 0; i < lt.MaxFailures; i++ {
+			lt.Failure("user")
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		if err := lt.Wait(context.Background(), "user"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("success resets failures", func(t *testing.T) {
+		lt := NewLoginThrottle("")
+		lt.MinInterval = 0
+		for i := 0; i < lt.MaxFailures; i++ {
+			lt.Failure("user")
+		}
+		lt.Success("user")
+
+		if err := lt.Wait(context.Background(), "user"); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestLoginThrottle_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "login.state")
+
+	lt := NewLoginThrottle(path)
+	lt.MinInterval = 0
+	for i := 0; i < lt.MaxFailures; i++ {
+		lt.Failure("user")
+	}
+
+	// a separate throttle using the same file should see the failures
+	lt2 := NewLoginThrottle(path)
+	if err := lt2.Wait(context.Background(), "user"); !errors.Is(err, ErrTooManyLoginFailures) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLoginThrottle_File_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "login.state")
+	workers, n := 10, 10
+
+	// each throttle acts like a separate process sharing the file, so only the lock file keeps updates from being lost
+	wg := new(sync.WaitGroup)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lt := NewLoginThrottle(path)
+			for j := 0; j < n; j++ {
+				lt.Failure("user")
+			}
+		}()
+	}
+	wg.Wait()
+
+	lt := NewLoginThrottle(path)
+	lt.load()
+	if f := lt.attempts("user").Failures; f != workers*n {
+		t.Errorf("unexpected failure count: %d", f)
+	}
+}
+
+func TestBaseClient_waitLogin(t *testing.T) {
+	lt := NewLoginThrottle("")
+	lt.MinInterval = 0
+
+	c, _ := newBaseClient("https://localhost/auth")
+	c.Username = "user"
+	c.SetLoginThrottler(lt)
+
+	for i := 0; i < lt.MaxFailures; i++ {
+		done, err := c.waitLogin(context.Background())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		done(true)
+	}
+
+	if _, err := c.waitLogin(context.Background()); !errors.Is(err, ErrTooManyLoginFailures) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// mockLoginThrottle records the results of the password submissions reported by a client.
+type mockLoginThrottle struct {
+	waits     int
+	failures  int
+	successes int
+}
+
+func (t *mockLoginThrottle) Wait(context.Context, string) error {
+	t.waits++
+	return nil
+}
+
+func (t *mockLoginThrottle) Failure(string) {
+	t.failures++
+}
+
+func (t *mockLoginThrottle) Success(string) {
+	t.successes++
+}
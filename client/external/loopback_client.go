@@ -0,0 +1,266 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/identity"
+)
+
+const loopbackIdentityProvider = "LoopbackIdentityProvider"
+
+type loopbackClient struct {
+	*baseClient
+	token *credentials.OidcIdentityToken
+	// openUrl opens the authorization request in the system browser, replaceable for testing
+	openUrl func(url string) error
+}
+
+// NewLoopbackClient creates an OIDC client which performs the authorization code flow in the system browser, and
+// receives the authorization code on a local (loopback) redirect listener, as described in RFC 8252.  The url
+// parameter is the issuer URL of the identity provider, which is used to discover the authorization and token
+// endpoints.  The RedirectUri in the client configuration must be an http URL for 127.0.0.1, [::1], or localhost.
+// If the redirect URI does not have a port, a random port is used.
+func NewLoopbackClient(url string) (*loopbackClient, error) {
+	bc, err := newBaseClient(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loopbackClient{baseClient: bc, openUrl: openBrowser}, nil
+}
+
+// Authenticate calls AuthenticateWithContext using a background context.
+func (c *loopbackClient) Authenticate() error {
+	return c.AuthenticateWithContext(context.Background())
+}
+
+// AuthenticateWithContext opens the identity provider login page in the system browser, and waits for the browser to
+// be redirected back to the loopback listener with the authorization code, which is exchanged for the identity token.
+func (c *loopbackClient) AuthenticateWithContext(ctx context.Context) error {
+	if err := checkLogin(ctx); err != nil {
+		return err
+	}
+
+	err := c.login(ctx)
+
+	// the login is performed again (opening the browser again) if PKCE was rejected
+	var oe *oauthError
+	if errors.As(err, &oe) && c.pkceFallback(url.Values{"error": {oe.Code}, "error_description": {oe.Description}}) {
+		err = c.login(ctx)
+	}
+	return err
+}
+
+//nolint:funlen
+func (c *loopbackClient) login(ctx context.Context) error {
+	ep := new(struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+	})
+	if err := getJson(ctx, c.httpClient, discoveryUrl(c.authUrl.String()), ep); err != nil {
+		return fmt.Errorf("unable to get identity provider endpoints: %w", err)
+	}
+
+	authzUrl, err := url.Parse(ep.AuthorizationEndpoint)
+	if err != nil || len(ep.TokenEndpoint) < 1 {
+		return errors.New("identity provider discovery document is missing the authorization or token endpoint")
+	}
+
+	l, redir, err := c.listen()
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	pkce, err := newPkceCode()
+	if err != nil {
+		return err
+	}
+
+	qs := c.pkceAuthzRequest(pkce.Challenge())
+	qs.Set("redirect_uri", redir.String())
+
+	// keep any parameters which are part of the authorization endpoint
+	authzQs := authzUrl.Query()
+	for k, v := range qs {
+		authzQs[k] = v
+	}
+	authzUrl.RawQuery = authzQs.Encode()
+
+	resCh := make(chan url.Values, 1)
+	srv := &http.Server{Handler: loopbackHandler(redir.Path, resCh), ReadHeaderTimeout: 30 * time.Second}
+	go func() { _ = srv.Serve(l) }()
+	defer func() { _ = srv.Shutdown(context.Background()) }()
+
+	c.Logger.Debugf("waiting for authorization code on %s", redir)
+	if err = c.openUrl(authzUrl.String()); err != nil {
+		c.Logger.Debugf("error opening browser: %v", err)
+		_, _ = fmt.Fprintf(os.Stderr, "Open the following URL in a browser to log in:\n\n%s\n\n", authzUrl)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, browserAuthTimeout)
+	defer cancel()
+
+	var vals url.Values
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("did not receive browser login response: %w", ctx.Err())
+	case vals = <-resCh:
+	}
+
+	if code := vals.Get("error"); len(code) > 0 {
+		return fmt.Errorf("authorization failed: %w", &oauthError{Code: code, Description: vals.Get("error_description")})
+	}
+
+	if vals.Get("state") != qs.Get("state") {
+		return errOauthStateMismatch
+	}
+
+	tok, err := c.oauthCodeToken(ctx, ep.TokenEndpoint, vals.Get("code"), pkce.Verifier(), redir.String())
+	if err != nil {
+		return err
+	}
+
+	if tok.IdToken == nil || len(*tok.IdToken) < 1 {
+		return errors.New("identity provider did not return an identity token")
+	}
+	c.token = tok.IdToken
+
+	return nil
+}
+
+// Identity returns the identity information for the user.  The configured username is used if set, otherwise the
+// username is found in the identity token, which is only available after authentication.
+func (c *loopbackClient) Identity() (*identity.Identity, error) {
+	return c.IdentityWithContext(context.Background())
+}
+
+// IdentityWithContext calls Identity, the context is not used.  An error is returned if the username is not known.
+// Authentication is not performed, since that would open the browser even when cached credentials are used.
+func (c *loopbackClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	id := &identity.Identity{
+		IdentityType: "user",
+		Provider:     loopbackIdentityProvider,
+		Username:     c.Username,
+	}
+
+	if len(id.Username) < 1 && c.token != nil {
+		claims, _ := c.token.Claims()
+		for _, k := range []string{"preferred_username", "email", "sub"} {
+			if v, ok := claims[k].(string); ok && len(v) > 0 {
+				id.Username = v
+				break
+			}
+		}
+	}
+
+	if len(id.Username) < 1 {
+		return nil, errors.New("username is not known until authenticated")
+	}
+	return id, nil
+}
+
+// Roles calls RolesWithContext using a background context.
+func (c *loopbackClient) Roles(user ...string) (*identity.Roles, error) {
+	return c.RolesWithContext(context.Background(), user...)
+}
+
+// RolesWithContext is not supported for OIDC clients, an error is always returned.
+func (c *loopbackClient) RolesWithContext(context.Context, ...string) (*identity.Roles, error) {
+	return nil, errors.New("OIDC clients are not role aware")
+}
+
+// IdentityToken calls IdentityTokenWithContext using a background context.
+func (c *loopbackClient) IdentityToken() (*credentials.OidcIdentityToken, error) {
+	return c.IdentityTokenWithContext(context.Background())
+}
+
+// IdentityTokenWithContext returns the identity token from the most recent authentication, performing the browser
+// login if there is no token, or the token is expired.
+func (c *loopbackClient) IdentityTokenWithContext(ctx context.Context) (*credentials.OidcIdentityToken, error) {
+	if c.token == nil || c.token.IsExpired() {
+		if err := c.AuthenticateWithContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return c.token, nil
+}
+
+// listen starts the loopback listener for the redirect URI, and returns the redirect URI with the listening port.
+func (c *loopbackClient) listen() (net.Listener, *url.URL, error) {
+	u, err := url.Parse(c.RedirectUri)
+	if err != nil || u.Scheme != "http" || !isLoopback(u.Hostname()) {
+		return nil, nil, fmt.Errorf("redirect URI %s must be an http URL for 127.0.0.1, [::1], or localhost", c.RedirectUri)
+	}
+
+	port := u.Port()
+	if len(port) < 1 {
+		port = "0"
+	}
+
+	l, err := net.Listen("tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to start redirect listener: %w", err)
+	}
+
+	u.Host = net.JoinHostPort(u.Hostname(), fmt.Sprintf("%d", l.Addr().(*net.TCPAddr).Port))
+	if len(u.Path) < 1 {
+		u.Path = "/"
+	}
+	return l, u, nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// loopbackHandler handles the redirect from the identity provider, sending the query parameters of the first request
+// to the redirect path on the channel.  Requests for other paths (like favicon.ico) are not found.
+func loopbackHandler(path string, ch chan<- url.Values) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+
+		vals := r.URL.Query()
+		w.Header().Set("Content-Type", "text/html")
+		if len(vals.Get("code")) > 0 && len(vals.Get("error")) < 1 {
+			_, _ = w.Write([]byte(htmlsuccess))
+		} else {
+			_, _ = w.Write([]byte(htmlfail))
+		}
+
+		select {
+		case ch <- vals:
+		default:
+			// already have a response, ignore any duplicate redirects
+		}
+	})
+}
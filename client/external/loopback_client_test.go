@@ -0,0 +1,254 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/shared"
+)
+
+func TestNewLoopbackClient(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		c, err := NewLoopbackClient("https://idp.example.com")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if c.baseClient == nil || c.openUrl == nil {
+			t.Error("invalid client")
+		}
+	})
+
+	t.Run("bad url", func(t *testing.T) {
+		if _, err := NewLoopbackClient("ftp://idp.example.com"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestLoopbackClient_Authenticate(t *testing.T) {
+	idp := newMockLoopbackIdp()
+	defer idp.Close()
+
+	t.Run("good", func(t *testing.T) {
+		c := newMockLoopbackClient(idp.URL)
+		tok, err := c.IdentityToken()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if tok.IsExpired() {
+			t.Error("invalid token")
+			return
+		}
+
+		id, err := c.Identity()
+		if err != nil || id.Username != "me@example.com" {
+			t.Errorf("unexpected identity: %+v, %v", id, err)
+		}
+	})
+
+	t.Run("fixed port", func(t *testing.T) {
+		// find a free port to use as the configured redirect port
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = l.Close()
+
+		c := newMockLoopbackClient(idp.URL)
+		c.RedirectUri = "http://" + l.Addr().String() + "/callback"
+		if err = c.Authenticate(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("authorization error", func(t *testing.T) {
+		c := newMockLoopbackClient(idp.URL)
+		c.ClientId = "bad"
+
+		err := c.Authenticate()
+		if err == nil || !strings.Contains(err.Error(), "access_denied: user is not assigned to the client") {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("pkce fallback", func(t *testing.T) {
+		c := newMockLoopbackClient(idp.URL)
+		c.ClientId = "legacy"
+
+		if err := c.Authenticate(); err != nil || c.pkceEnabled() {
+			t.Errorf("did not fall back to authorization without PKCE: %v", err)
+		}
+	})
+
+	t.Run("browser not opened", func(t *testing.T) {
+		c := newMockLoopbackClient(idp.URL)
+		opened := c.openUrl
+		c.openUrl = func(u string) error {
+			// the user opens the printed url
+			_ = opened(u)
+			return errors.New("no browser")
+		}
+
+		if err := c.Authenticate(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		c := newMockLoopbackClient(idp.URL)
+		c.openUrl = func(string) error { return nil }
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		if err := c.AuthenticateWithContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("bad redirect uri", func(t *testing.T) {
+		c := newMockLoopbackClient(idp.URL)
+		c.RedirectUri = "https://app.example.com/callback"
+		if err := c.Authenticate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("bad discovery", func(t *testing.T) {
+		c := newMockLoopbackClient(idp.URL + "/missing")
+		if err := c.Authenticate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestLoopbackClient_Identity(t *testing.T) {
+	t.Run("configured username", func(t *testing.T) {
+		c, _ := NewLoopbackClient("https://idp.example.com")
+		c.Username = "me"
+
+		if id, err := c.Identity(); err != nil || id.Username != "me" || id.Provider != loopbackIdentityProvider {
+			t.Errorf("unexpected identity: %+v, %v", id, err)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		c, _ := NewLoopbackClient("https://idp.example.com")
+		if _, err := c.Identity(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		c, _ := NewLoopbackClient("https://idp.example.com")
+		if _, err := c.Roles(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func newMockLoopbackClient(u string) *loopbackClient {
+	c, _ := NewLoopbackClient(u)
+	c.ClientId = "my-app"
+	c.RedirectUri = "http://127.0.0.1/callback"
+	c.Logger = new(shared.DefaultLogger)
+
+	// stand in for the browser, the redirect to the loopback listener is followed by the default client
+	c.openUrl = func(u string) error {
+		go func() {
+			if res, err := http.Get(u); err == nil { //nolint:gosec,noctx
+				res.Body.Close()
+			}
+		}()
+		return nil
+	}
+	return c
+}
+
+// newMockLoopbackIdp is a minimal authorization server, which authorizes every request for the 'my-app' client.
+func newMockLoopbackIdp() *httptest.Server {
+	var mu sync.Mutex
+	challenges := make(map[string]string)
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 srv.URL,
+				"authorization_endpoint": srv.URL + "/authorize?tenant=1",
+				"token_endpoint":         srv.URL + "/token",
+			})
+		case "/authorize":
+			q := r.URL.Query()
+			redir, _ := url.Parse(q.Get("redirect_uri"))
+			rq := url.Values{}
+			rq.Set("state", q.Get("state"))
+
+			switch {
+			case q.Get("client_id") == "legacy" && len(q.Get("code_challenge")) > 0:
+				rq.Set("error", "invalid_request")
+				rq.Set("error_description", "code_challenge is not supported")
+			case q.Get("client_id") != "my-app" && q.Get("client_id") != "legacy" || q.Get("tenant") != "1":
+				rq.Set("error", "access_denied")
+				rq.Set("error_description", "user is not assigned to the client")
+			default:
+				mu.Lock()
+				challenges["code123"] = q.Get("code_challenge")
+				mu.Unlock()
+				rq.Set("code", "code123")
+			}
+
+			redir.RawQuery = rq.Encode()
+			http.Redirect(w, r, redir.String(), http.StatusFound)
+		case "/token":
+			_ = r.ParseForm()
+			h := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+
+			mu.Lock()
+			challenge := challenges[r.PostForm.Get("code")]
+			ok := challenge == base64.RawURLEncoding.EncodeToString(h[:]) || len(challenge) < 1 && len(r.PostForm["code_verifier"]) < 1
+			mu.Unlock()
+
+			if !ok || !strings.HasPrefix(r.PostForm.Get("redirect_uri"), "http://") {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error": "invalid_grant"}`))
+				return
+			}
+
+			tok := mockJwt(map[string]any{"iss": srv.URL, "email": "me@example.com", "exp": time.Now().Add(1 * time.Hour).Unix()})
+			_ = json.NewEncoder(w).Encode(map[string]any{"id_token": tok, "access_token": "x", "token_type": "Bearer"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return srv
+}
@@ -40,6 +40,11 @@ func (m *mockClient) Identity() (*identity.Identity, error) {
 	return new(identity.Identity), nil
 }
 
+// IdentityWithContext calls Identity, the context is not used.
+func (m *mockClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return m.Identity()
+}
+
 // Authenticate calls AuthenticateWithContext using a background context.
 func (m *mockClient) Authenticate() error {
 	return m.AuthenticateWithContext(context.Background())
@@ -50,12 +55,17 @@ func (m *mockClient) AuthenticateWithContext(context.Context) error {
 	return nil
 }
 
-// Roles retrieves the available roles for the user.  Attempting to call this method
+// Roles calls RolesWithContext using a background context.
+func (m *mockClient) Roles(user ...string) (*identity.Roles, error) {
+	return m.RolesWithContext(context.Background(), user...)
+}
+
+// RolesWithContext retrieves the available roles for the user.  Attempting to call this method
 // against an Oauth/OIDC client will return an error.
-func (m *mockClient) Roles(...string) (*identity.Roles, error) {
+func (m *mockClient) RolesWithContext(ctx context.Context, _ ...string) (*identity.Roles, error) {
 	if m.saml == nil || len(*m.saml) < 1 {
 		var err error
-		m.saml, err = m.SamlAssertion()
+		m.saml, err = m.SamlAssertionWithContext(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/shared"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// the maximum age of cached provider signing keys before they are fetched again from the identity provider.
+const jwksMaxAge = 24 * time.Hour
+
+type tokenValidator struct {
+	idpUrl     *url.URL
+	clientId   string
+	cache      credentials.OidcProviderCacher
+	httpClient *http.Client
+	Logger     shared.Logger
+}
+
+// NewTokenValidator returns a validator which checks OIDC identity tokens issued by the identity provider at idpUrl
+// before they are sent to AWS.  The provider discovery document and signing keys are fetched from the token issuer,
+// and stored in the provided cache.  If cache is nil, the provider data is fetched for every validation.
+func NewTokenValidator(idpUrl, clientId string, cache credentials.OidcProviderCacher) *tokenValidator {
+	u, _ := url.Parse(idpUrl)
+	if u == nil {
+		u = new(url.URL)
+	}
+
+	return &tokenValidator{
+		idpUrl:     u,
+		clientId:   clientId,
+		cache:      cache,
+		httpClient: newHttpClient(),
+		Logger:     new(shared.DefaultLogger),
+	}
+}
+
+// Validate calls ValidateWithContext using a background context.
+func (v *tokenValidator) Validate(tok *credentials.OidcIdentityToken) error {
+	return v.ValidateWithContext(context.Background(), tok)
+}
+
+// ValidateWithContext verifies the signature, issuer, audience, and validity period of the identity token.  An error
+// wrapping credentials.ErrInvalidIdentityToken is returned if the token would be rejected by AWS.  Validation is
+// skipped, returning nil, if the token issuer is not hosted by the configured identity provider, or the provider
+// discovery or signing key data can not be retrieved, which leaves the final decision to AWS.
+func (v *tokenValidator) ValidateWithContext(ctx context.Context, tok *credentials.OidcIdentityToken) error {
+	iss := tok.Issuer()
+	if len(iss) < 1 {
+		return fmt.Errorf("%w: token has no issuer", credentials.ErrInvalidIdentityToken)
+	}
+
+	u, err := url.Parse(iss)
+	if err != nil || !strings.EqualFold(u.Host, v.idpUrl.Host) {
+		v.Logger.Debugf("skipping local token validation, issuer %s is not part of identity provider %s", iss, v.idpUrl)
+		return nil
+	}
+
+	p, err := v.provider(ctx, iss, tok.KeyId())
+	if err != nil {
+		// non-fatal, AWS will still validate the token
+		v.Logger.Debugf("skipping local token validation: %v", err)
+		return nil
+	}
+
+	return tok.Verify(p, v.clientId)
+}
+
+// provider returns the provider data for the issuer, using the cached value unless it is older than jwksMaxAge
+// or does not contain the key used to sign the token, which is expected after the provider rotates its keys.
+func (v *tokenValidator) provider(ctx context.Context, iss, kid string) (*credentials.OidcProvider, error) {
+	if v.cache != nil {
+		if p := v.cache.Load(iss); p != nil && p.Key(kid) != nil && time.Since(p.Updated) < jwksMaxAge {
+			return p, nil
+		}
+	}
+
+	p, err := v.discover(ctx, iss)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Keys, err = v.fetchKeys(ctx, p.JwksUri); err != nil {
+		return nil, err
+	}
+	p.Updated = time.Now()
+
+	if v.cache != nil {
+		if err = v.cache.Store(iss, p); err != nil {
+			// non-fatal ... just won't have cached provider data
+			v.Logger.Debugf("error writing to oidc provider cache: %v", err)
+		}
+	}
+	return p, nil
+}
+
+func (v *tokenValidator) discover(ctx context.Context, iss string) (*credentials.OidcProvider, error) {
+	doc := new(struct {
+		Issuer  string `json:"issuer"`
+		JwksUri string `json:"jwks_uri"`
+	})
+
+	if err := getJson(ctx, v.httpClient, discoveryUrl(iss), doc); err != nil {
+		return nil, fmt.Errorf("discovery document: %v", err)
+	}
+
+	if len(doc.JwksUri) < 1 {
+		return nil, errors.New("discovery document does not contain jwks_uri")
+	}
+
+	return &credentials.OidcProvider{Issuer: doc.Issuer, JwksUri: doc.JwksUri}, nil
+}
+
+func (v *tokenValidator) fetchKeys(ctx context.Context, jwksUri string) ([]*credentials.JsonWebKey, error) {
+	jwks := new(struct {
+		Keys []*credentials.JsonWebKey `json:"keys"`
+	})
+
+	if err := getJson(ctx, v.httpClient, jwksUri, jwks); err != nil {
+		return nil, fmt.Errorf("jwks: %v", err)
+	}
+
+	keys := make([]*credentials.JsonWebKey, 0, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		// skip encryption keys
+		if k != nil && (len(k.Use) < 1 || k.Use == "sig") {
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) < 1 {
+		return nil, errors.New("jwks does not contain any signing keys")
+	}
+	return keys, nil
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+func TestTokenValidator_Validate(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var discoveryCalls, jwksCalls atomic.Int32
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			discoveryCalls.Add(1)
+			_ = json.NewEncoder(w).Encode(map[string]string{"issuer": srv.URL, "jwks_uri": srv.URL + "/keys"})
+		case "/keys":
+			jwksCalls.Add(1)
+			_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+				{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+				{
+					"kty": "RSA",
+					"kid": "k1",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	claims := func(iss, aud string, exp time.Duration) map[string]any {
+		return map[string]any{"iss": iss, "aud": aud, "exp": time.Now().Add(exp).Unix()}
+	}
+
+	t.Run("good", func(t *testing.T) {
+		c := new(mockOidcProviderCache)
+		v := NewTokenValidator(srv.URL+"/oauth2", "my-client", c)
+
+		tok := rsaSignedToken(key, "k1", claims(srv.URL, "my-client", 1*time.Hour))
+		if err := v.Validate(tok); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if c.p == nil || c.p.Key("k1") == nil || c.p.Key("enc") != nil {
+			t.Errorf("unexpected cached provider data: %+v", c.p)
+		}
+
+		// 2nd call uses cached data
+		d, j := discoveryCalls.Load(), jwksCalls.Load()
+		if err := v.Validate(tok); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if discoveryCalls.Load() != d || jwksCalls.Load() != j {
+			t.Error("provider data was not cached")
+		}
+	})
+
+	t.Run("unknown key refetch", func(t *testing.T) {
+		c := &mockOidcProviderCache{p: &credentials.OidcProvider{Issuer: srv.URL, Updated: time.Now()}}
+		v := NewTokenValidator(srv.URL, "my-client", c)
+
+		j := jwksCalls.Load()
+		if err := v.Validate(rsaSignedToken(key, "k1", claims(srv.URL, "my-client", 1*time.Hour))); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if jwksCalls.Load() == j {
+			t.Error("signing keys were not fetched")
+		}
+	})
+
+	t.Run("stale cache refetch", func(t *testing.T) {
+		old := time.Now().Add(-2 * jwksMaxAge)
+		c := &mockOidcProviderCache{p: &credentials.OidcProvider{Issuer: srv.URL, Updated: old,
+			Keys: []*credentials.JsonWebKey{{Kty: "RSA", Kid: "k1"}}}}
+		v := NewTokenValidator(srv.URL, "my-client", c)
+
+		if err := v.Validate(rsaSignedToken(key, "k1", claims(srv.URL, "my-client", 1*time.Hour))); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if !c.p.Updated.After(old) {
+			t.Error("cached provider data was not refreshed")
+		}
+	})
+
+	t.Run("audience", func(t *testing.T) {
+		v := NewTokenValidator(srv.URL, "my-client", nil)
+		err := v.Validate(rsaSignedToken(key, "k1", claims(srv.URL, "other-client", 1*time.Hour)))
+		if err == nil || !errors.Is(err, credentials.ErrInvalidIdentityToken) || !strings.Contains(err.Error(), "audience") {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		v := NewTokenValidator(srv.URL, "my-client", nil)
+		err := v.Validate(rsaSignedToken(key, "k1", claims(srv.URL, "my-client", -1*time.Hour)))
+		if err == nil || !errors.Is(err, credentials.ErrInvalidIdentityToken) || !strings.Contains(err.Error(), "expired") {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("bad signature", func(t *testing.T) {
+		other, _ := rsa.GenerateKey(rand.Reader, 2048)
+		v := NewTokenValidator(srv.URL, "my-client", nil)
+		err := v.Validate(rsaSignedToken(other, "k1", claims(srv.URL, "my-client", 1*time.Hour)))
+		if err == nil || !errors.Is(err, credentials.ErrInvalidIdentityToken) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("no issuer", func(t *testing.T) {
+		v := NewTokenValidator(srv.URL, "my-client", nil)
+		if err := v.Validate(rsaSignedToken(key, "k1", claims("", "my-client", 1*time.Hour))); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("foreign issuer skipped", func(t *testing.T) {
+		v := NewTokenValidator(srv.URL, "my-client", nil)
+		if err := v.Validate(rsaSignedToken(key, "k1", claims("https://other.example.com", "x", -1*time.Hour))); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("discovery failure skipped", func(t *testing.T) {
+		v := NewTokenValidator(srv.URL, "my-client", nil)
+		if err := v.Validate(rsaSignedToken(key, "k1", claims(srv.URL+"/missing", "x", -1*time.Hour))); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+type mockOidcProviderCache struct {
+	p *credentials.OidcProvider
+}
+
+func (c *mockOidcProviderCache) Load(string) *credentials.OidcProvider {
+	return c.p
+}
+
+func (c *mockOidcProviderCache) Store(_ string, p *credentials.OidcProvider) error {
+	c.p = p
+	return nil
+}
+
+func (c *mockOidcProviderCache) Clear() error {
+	c.p = nil
+	return nil
+}
+
+func rsaSignedToken(key *rsa.PrivateKey, kid string, claims map[string]any) *credentials.OidcIdentityToken {
+	h, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	c, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(input))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest.Sum(nil))
+
+	tok := credentials.OidcIdentityToken(input + "." + base64.RawURLEncoding.EncodeToString(sig))
+	return &tok
+}
@@ -0,0 +1,192 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// oktaAwsAppName is the name Okta uses for the AWS Account Federation application.
+const oktaAwsAppName = "amazon_aws"
+
+// oktaApiTimeout is the limit for each request sent to the Okta API.
+const oktaApiTimeout = 30 * time.Second
+
+// OktaAwsApp is an AWS Account Federation application assigned to an Okta user.
+type OktaAwsApp struct {
+	// Id is the id of the application instance.
+	Id string
+	// Label is the name of the application shown to the user.
+	Label string
+	// Url is the embed link of the application, which is the SAML URL used by aws-runas profiles.
+	Url string
+	// Roles are the ARNs of the IAM roles the user is allowed to assume using the application.
+	Roles []string
+}
+
+// oktaApi sends requests to the Okta management API, authenticated using an API token.
+type oktaApi struct {
+	baseUrl    string
+	token      string
+	httpClient *http.Client
+}
+
+// GetOktaAwsApps returns the AWS applications assigned to the Okta user, and the roles the user may assume using each
+// application, using the Okta API at orgUrl.  The API token must be allowed to read users and applications, like a
+// token created by a read-only administrator.
+func GetOktaAwsApps(ctx context.Context, orgUrl, token, user string) ([]*OktaAwsApp, error) {
+	u, err := url.Parse(orgUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(u.Scheme) < 1 || len(u.Host) < 1 {
+		return nil, fmt.Errorf("invalid Okta url %s", orgUrl)
+	}
+
+	api := &oktaApi{
+		baseUrl:    fmt.Sprintf("%s://%s/api/v1", u.Scheme, u.Host),
+		token:      token,
+		httpClient: &http.Client{Timeout: oktaApiTimeout, Transport: newOktaRateLimitTransport(nil, nil)},
+	}
+
+	var oktaUser struct {
+		Id string `json:"id"`
+	}
+	if err = api.get(ctx, "/users/"+url.PathEscape(user), &oktaUser); err != nil {
+		return nil, fmt.Errorf("unable to find Okta user %s: %w", user, err)
+	}
+
+	var links []struct {
+		Label         string `json:"label"`
+		LinkUrl       string `json:"linkUrl"`
+		AppName       string `json:"appName"`
+		AppInstanceId string `json:"appInstanceId"`
+	}
+	if err = api.get(ctx, "/users/"+url.PathEscape(oktaUser.Id)+"/appLinks", &links); err != nil {
+		return nil, fmt.Errorf("unable to list the applications of Okta user %s: %w", user, err)
+	}
+
+	apps := make([]*OktaAwsApp, 0)
+	for _, l := range links {
+		if l.AppName != oktaAwsAppName {
+			continue
+		}
+
+		app := &OktaAwsApp{Id: l.AppInstanceId, Label: l.Label, Url: l.LinkUrl}
+		if app.Roles, err = api.appRoles(ctx, l.AppInstanceId, oktaUser.Id); err != nil {
+			return nil, fmt.Errorf("unable to get the roles of application %s: %w", l.Label, err)
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+// appRoles returns the ARNs of the roles assigned to the user in the AWS application.  The samlRoles of a user are
+// role ARNs when the application is configured for multiple accounts, otherwise they are role names in the account
+// of the application's identity provider.
+func (a *oktaApi) appRoles(ctx context.Context, appId, userId string) ([]string, error) {
+	var appUser struct {
+		Profile struct {
+			Role      string   `json:"role"`
+			SamlRoles []string `json:"samlRoles"`
+		} `json:"profile"`
+	}
+	if err := a.get(ctx, "/apps/"+url.PathEscape(appId)+"/users/"+url.PathEscape(userId), &appUser); err != nil {
+		return nil, err
+	}
+
+	values := appUser.Profile.SamlRoles
+	if len(appUser.Profile.Role) > 0 {
+		values = append(values, appUser.Profile.Role)
+	}
+
+	var idp *arn.ARN
+	roles := make(map[string]bool)
+	for _, v := range values {
+		// values may also be a pair of comma separated saml-provider and role ARNs
+		for _, p := range strings.Split(v, ",") {
+			p = strings.TrimSpace(p)
+
+			if r, err := arn.Parse(p); err == nil {
+				if strings.HasPrefix(r.Resource, "role/") {
+					roles[p] = true
+				}
+				continue
+			}
+
+			if idp == nil {
+				var err error
+				if idp, err = a.appIdentityProvider(ctx, appId); err != nil {
+					return nil, err
+				}
+			}
+			roles[arn.ARN{Partition: idp.Partition, Service: "iam", AccountID: idp.AccountID, Resource: "role/" + p}.String()] = true
+		}
+	}
+
+	arns := make([]string, 0, len(roles))
+	for r := range roles {
+		arns = append(arns, r)
+	}
+	sort.Strings(arns)
+	return arns, nil
+}
+
+// appIdentityProvider returns the ARN of the IAM identity provider configured in the AWS application.
+func (a *oktaApi) appIdentityProvider(ctx context.Context, appId string) (*arn.ARN, error) {
+	var app struct {
+		Settings struct {
+			App struct {
+				IdentityProviderArn string `json:"identityProviderArn"`
+			} `json:"app"`
+		} `json:"settings"`
+	}
+	if err := a.get(ctx, "/apps/"+url.PathEscape(appId), &app); err != nil {
+		return nil, err
+	}
+
+	idp, err := arn.Parse(app.Settings.App.IdentityProviderArn)
+	if err != nil {
+		return nil, fmt.Errorf("application has no valid identity provider ARN: %w", err)
+	}
+	return &idp, nil
+}
+
+// get sends a GET request for the API path, and decodes the JSON response body into out.
+func (a *oktaApi) get(ctx context.Context, path string, out any) error {
+	req, err := newHttpRequest(ctx, http.MethodGet, a.baseUrl+path)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", contentTypeJson)
+	req.Header.Set("Authorization", "SSWS "+a.token)
+
+	res, err := checkResponseError(a.httpClient.Do(req.Request))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
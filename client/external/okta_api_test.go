@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetOktaAwsApps(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/users/mock@example.org", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"id": "00umock"}`))
+	})
+	mux.HandleFunc("/api/v1/users/00umock/appLinks", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`[
+  {"label": "AWS Prod", "linkUrl": "https://mock.okta.com/home/amazon_aws/0oaprod/272", "appName": "amazon_aws", "appInstanceId": "0oaprod"},
+  {"label": "AWS Dev", "linkUrl": "https://mock.okta.com/home/amazon_aws/0oadev/272", "appName": "amazon_aws", "appInstanceId": "0oadev"},
+  {"label": "Slack", "linkUrl": "https://mock.okta.com/home/slack/0oaslack/1", "appName": "slack", "appInstanceId": "0oaslack"}
+]`))
+	})
+	mux.HandleFunc("/api/v1/apps/0oaprod/users/00umock", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"profile": {"samlRoles": ["Admin", "ReadOnly"]}}`))
+	})
+	mux.HandleFunc("/api/v1/apps/0oaprod", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"settings": {"app": {"identityProviderArn": "arn:aws:iam::123456789012:saml-provider/Okta"}}}`))
+	})
+	mux.HandleFunc("/api/v1/apps/0oadev/users/00umock", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"profile": {"samlRoles": ["arn:aws:iam::210987654321:saml-provider/Okta,arn:aws:iam::210987654321:role/Dev"]}}`))
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "SSWS mock-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	t.Run("good", func(t *testing.T) {
+		apps, err := GetOktaAwsApps(context.Background(), srv.URL+"/some/path", "mock-token", "mock@example.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(apps) != 2 {
+			t.Fatalf("unexpected apps: %+v", apps)
+		}
+
+		prod := apps[0]
+		if prod.Label != "AWS Prod" || prod.Url != "https://mock.okta.com/home/amazon_aws/0oaprod/272" || len(prod.Roles) != 2 ||
+			prod.Roles[0] != "arn:aws:iam::123456789012:role/Admin" || prod.Roles[1] != "arn:aws:iam::123456789012:role/ReadOnly" {
+			t.Errorf("data mismatch: %+v", prod)
+		}
+
+		if dev := apps[1]; len(dev.Roles) != 1 || dev.Roles[0] != "arn:aws:iam::210987654321:role/Dev" {
+			t.Errorf("data mismatch: %+v", dev)
+		}
+	})
+
+	t.Run("bad token", func(t *testing.T) {
+		if _, err := GetOktaAwsApps(context.Background(), srv.URL, "bad", "mock@example.org"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		if _, err := GetOktaAwsApps(context.Background(), srv.URL, "mock-token", "other@example.org"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("invalid url", func(t *testing.T) {
+		if _, err := GetOktaAwsApps(context.Background(), "mock.okta.com", "mock-token", "mock@example.org"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
@@ -47,10 +47,17 @@ func NewOktaClient(url string) (*oktaClient, error) {
 
 	oc := new(oktaClient)
 	oc.baseClient = bc
+	oc.SetTransport(bc.httpClient.Transport)
 
 	return oc, nil
 }
 
+// SetTransport updates this clients HTTP transport to use the provided http.RoundTripper, with the Okta rate limits
+// handled by waiting for the limit to reset.  A nil value will use the default transport from the net/http package.
+func (c *oktaClient) SetTransport(rt http.RoundTripper) {
+	c.baseClient.SetTransport(newOktaRateLimitTransport(rt, c.Logger))
+}
+
 // Authenticate performs authentication against OneLogin.  This delegates to AuthenticateWithContext using
 // context.Background().
 func (c *oktaClient) Authenticate() error {
@@ -60,6 +67,10 @@ func (c *oktaClient) Authenticate() error {
 // AuthenticateWithContext performs authentication against Okta using the specified Context, which is passed
 // along to the underlying HTTP requests.  If necessary, it will prompt for the authentication credentials.
 func (c *oktaClient) AuthenticateWithContext(ctx context.Context) error {
+	if err := checkLogin(ctx); err != nil {
+		return err
+	}
+
 	if err := c.gatherCredentials(); err != nil {
 		return err
 	}
@@ -78,12 +89,22 @@ func (c *oktaClient) Identity() (*identity.Identity, error) {
 	return c.identity(oktaIdentityProvider), nil
 }
 
-// Roles retrieves the available roles for the user.  Attempting to call this method
+// IdentityWithContext calls Identity, the context is not used.
+func (c *oktaClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return c.Identity()
+}
+
+// Roles calls RolesWithContext using a background context.
+func (c *oktaClient) Roles(user ...string) (*identity.Roles, error) {
+	return c.RolesWithContext(context.Background(), user...)
+}
+
+// RolesWithContext retrieves the available roles for the user.  Attempting to call this method
 // against an Oauth/OIDC client will return an error.
-func (c *oktaClient) Roles(...string) (*identity.Roles, error) {
+func (c *oktaClient) RolesWithContext(ctx context.Context, _ ...string) (*identity.Roles, error) {
 	if c.saml == nil || len(*c.saml) < 1 {
 		var err error
-		c.saml, err = c.SamlAssertion()
+		c.saml, err = c.SamlAssertionWithContext(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -172,16 +193,62 @@ func (c *oktaClient) auth(ctx context.Context) (*oktaAuthnResponse, error) {
 		return nil, err
 	}
 
+	return c.handleAuthStatus(ctx, res)
+}
+
+func (c *oktaClient) handleAuthStatus(ctx context.Context, res *oktaAuthnResponse) (*oktaAuthnResponse, error) {
 	switch strings.ToUpper(res.Status) {
 	case "SUCCESS":
 		return res, nil
 	case "MFA_REQUIRED":
-		return c.doMfa(ctx, res.StateToken, res.EmbeddedData.MfaFactors)
+		r, err := c.doMfa(ctx, res.StateToken, res.EmbeddedData.MfaFactors)
+		if err != nil {
+			return nil, err
+		}
+
+		// Okta may require the expired password to be changed after the MFA challenge
+		if strings.EqualFold(r.Status, "PASSWORD_EXPIRED") {
+			return c.changePassword(ctx, r)
+		}
+		return r, nil
+	case "PASSWORD_EXPIRED":
+		return c.changePassword(ctx, res)
 	default:
 		return nil, fmt.Errorf("authentication status %s", res.Status)
 	}
 }
 
+// changePassword sends a new password to Okta, replacing the expired password.  If the new password doesn't meet the
+// password policy, the error returned by Okta explains why.
+func (c *oktaClient) changePassword(ctx context.Context, res *oktaAuthnResponse) (*oktaAuthnResponse, error) {
+	pw, err := c.newPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	changeUrl := fmt.Sprintf("%s://%s/api/v1/authn/credentials/change_password", c.authUrl.Scheme, c.authUrl.Host)
+	if v, ok := res.Links["next"].(map[string]any); ok {
+		if u, ok := v["href"].(string); ok && len(u) > 0 {
+			changeUrl = u
+		}
+	}
+
+	body, _ := json.Marshal(oktaPasswordChange{Token: res.StateToken, OldPassword: c.Password, NewPassword: pw})
+
+	r, err := c.sendApiRequst(ctx, changeUrl, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err = c.handleAuthResponse(r)
+	if err != nil {
+		return nil, fmt.Errorf("password change failed: %w", err)
+	}
+	c.passwordChanged(pw)
+
+	return c.handleAuthStatus(ctx, res)
+}
+
 func (c *oktaClient) sendAuthnRequest(ctx context.Context) (*oktaAuthnResponse, error) {
 	creds, err := json.Marshal(map[string]string{
 		"username": c.Username,
@@ -191,12 +258,25 @@ func (c *oktaClient) sendAuthnRequest(ctx context.Context) (*oktaAuthnResponse,
 		return nil, err
 	}
 
+	done, err := c.waitLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	authUrl := fmt.Sprintf("%s://%s/api/v1/authn", c.authUrl.Scheme, c.authUrl.Host)
 	res, err := c.sendApiRequst(ctx, authUrl, bytes.NewReader(creds))
 	if err != nil {
 		return nil, err
 	}
 
+	// only count outright credential rejections as failures, rate limiting or server errors don't affect lockout
+	switch res.StatusCode {
+	case http.StatusOK:
+		done(false)
+	case http.StatusUnauthorized:
+		done(true)
+	}
+
 	return c.handleAuthResponse(res)
 }
 
@@ -614,6 +694,7 @@ func (c *oktaClient) sendApiRequst(ctx context.Context, url string, body io.Read
 
 func (c *oktaClient) handleAuthResponse(res *http.Response) (*oktaAuthnResponse, error) {
 	defer res.Body.Close()
+	recordServerTime(res)
 
 	body, err := io.ReadAll(io.LimitReader(res.Body, 1024*1024))
 	if err != nil {
@@ -661,10 +742,19 @@ type oktaMfaResponse struct {
 	Code  string `json:"passCode,omitempty"`
 }
 
+type oktaPasswordChange struct {
+	Token       string `json:"stateToken"`
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
 type oktaApiError struct {
 	Code    string `json:"errorCode"`
 	Message string `json:"errorSummary"`
 	Id      string `json:"errorId"`
+	Causes  []struct {
+		Message string `json:"errorSummary"`
+	} `json:"errorCauses,omitempty"`
 }
 
 type oktaDuoAttrs struct {
@@ -706,5 +796,10 @@ type oktaDuoResponse struct {
 }
 
 func (e *oktaApiError) Error() string {
-	return e.Message
+	// the causes explain validation failures, like a new password which doesn't meet the password policy
+	msg := e.Message
+	for _, c := range e.Causes {
+		msg = fmt.Sprintf("%s: %s", msg, c.Message)
+	}
+	return msg
 }
@@ -14,6 +14,7 @@
 package external
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -37,6 +38,7 @@ func init() {
 	mux.HandleFunc("/home/amazon_aws/", oktaSamlHandler)
 	mux.HandleFunc("/api/v1/authn", oktaUserAuthHandler)
 	mux.HandleFunc("/verify_mfa_local", oktaVerifyMfaHandler)
+	mux.HandleFunc("/api/v1/authn/credentials/change_password", oktaChangePasswordHandler)
 
 	oktaMock = httptest.NewServer(mux)
 }
@@ -87,6 +89,16 @@ func TestOktaClient_Authenticate(t *testing.T) {
 			t.Error(err)
 		}
 	})
+
+	t.Run("without login", func(t *testing.T) {
+		c := newMockOktaClient()
+		c.Username = "nomfa"
+		c.Password = "goodPassword"
+
+		if err := c.AuthenticateWithContext(WithoutLogin(context.Background())); !errors.Is(err, ErrLoginRequired) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
 }
 
 func TestOktaClient_Authenticate_Plain(t *testing.T) {
@@ -122,6 +134,72 @@ func TestOktaClient_Authenticate_Plain(t *testing.T) {
 	})
 }
 
+func TestOktaClient_Authenticate_PasswordExpired(t *testing.T) {
+	t.Run("no provider", func(t *testing.T) {
+		c := newMockOktaClient()
+		c.Username = "expired"
+		c.Password = "goodPassword"
+
+		if err := c.Authenticate(); !errors.Is(err, ErrPasswordExpired) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		var stored string
+		c := newMockOktaClient()
+		c.Username = "expired"
+		c.Password = "goodPassword"
+		c.NewPasswordProvider = func() (string, error) {
+			return "newPassword", nil
+		}
+		c.PasswordUpdater = func(password string) error {
+			stored = password
+			return nil
+		}
+
+		if err := c.Authenticate(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if c.Password != "newPassword" || stored != "newPassword" {
+			t.Error("password not updated")
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		c := newMockOktaClient()
+		c.Username = "expired"
+		c.Password = "goodPassword"
+		c.NewPasswordProvider = func() (string, error) {
+			return "weak", nil
+		}
+		c.PasswordUpdater = func(string) error {
+			t.Error("updater called for rejected password")
+			return nil
+		}
+
+		err := c.Authenticate()
+		if err == nil || !strings.Contains(err.Error(), "requirements were not met") {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("provider error", func(t *testing.T) {
+		c := newMockOktaClient()
+		c.Username = "expired"
+		c.Password = "goodPassword"
+		c.NewPasswordProvider = func() (string, error) {
+			return "", errors.New("new passwords do not match")
+		}
+
+		if err := c.Authenticate(); !errors.Is(err, ErrPasswordExpired) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+}
+
 func TestOktaClient_Authenticate_CodeMfa(t *testing.T) {
 	t.Run("no factor found", func(t *testing.T) {
 		c := newMockOktaClient()
@@ -355,6 +433,21 @@ func oktaUserAuthHandler(w http.ResponseWriter, r *http.Request) {
 				SessionToken: "mock session token",
 			}
 
+			body, _ := json.Marshal(reply)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(body)
+			return
+		case "expired":
+			reply := oktaAuthnResponse{
+				Status:     "PASSWORD_EXPIRED",
+				StateToken: "mock state token",
+				Links: map[string]any{
+					"next": map[string]any{
+						"href": fmt.Sprintf("http://%s/api/v1/authn/credentials/change_password", r.Host),
+					},
+				},
+			}
+
 			body, _ := json.Marshal(reply)
 			w.Header().Set("Content-Type", "application/json")
 			_, _ = w.Write(body)
@@ -516,3 +609,24 @@ func oktaVerifyMfaHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_, _ = w.Write(j)
 }
+
+func oktaChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	change := new(oktaPasswordChange)
+	if err := json.NewDecoder(r.Body).Decode(change); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if change.Token != "mock state token" || change.OldPassword != "goodPassword" || change.NewPassword == "weak" {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errorCode":"E0000080","errorSummary":"The password does not meet the complexity requirements",
+"errorCauses":[{"errorSummary":"Password requirements were not met"}]}`))
+		return
+	}
+
+	body, _ := json.Marshal(oktaAuthnResponse{Status: "SUCCESS", SessionToken: "mock session token"})
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/shared"
+)
+
+const (
+	// oktaRateLimitRetries is the number of times a request rejected by an Okta rate limit is sent again.
+	oktaRateLimitRetries = 3
+	// oktaRateLimitMaxWait is the longest time to wait for a rate limit to reset, a request which would need to wait
+	// longer fails with the rate limit error instead.
+	oktaRateLimitMaxWait = time.Minute
+)
+
+// oktaRateLimitTransport is an http.RoundTripper which handles the Okta rate limits.  Requests rejected for exceeding
+// a rate limit (HTTP 429) are sent again after the limit resets, and once a response reports there are no requests
+// left before the limit resets, later requests wait for the reset instead of being rejected.  Okta reports the limit
+// state in the X-Rate-Limit-Remaining and X-Rate-Limit-Reset (seconds since the epoch) response headers.
+type oktaRateLimitTransport struct {
+	rt     http.RoundTripper
+	logger shared.Logger
+
+	mu      sync.Mutex
+	resetAt time.Time // local time when the exhausted rate limit resets
+}
+
+// newOktaRateLimitTransport creates an oktaRateLimitTransport which sends requests using rt, which will be the default
+// transport from the net/http package if nil.
+func newOktaRateLimitTransport(rt http.RoundTripper, logger shared.Logger) *oktaRateLimitTransport {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	if logger == nil {
+		logger = new(shared.DefaultLogger)
+	}
+
+	return &oktaRateLimitTransport{rt: rt, logger: logger}
+}
+
+// RoundTrip is the implementation of the http.RoundTripper interface.
+func (t *oktaRateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.waitReset(req); err != nil {
+		return nil, err
+	}
+
+	r := req
+	for attempt := 0; ; attempt++ {
+		res, err := t.rt.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+
+		wait := oktaRateLimitWait(res.Header, time.Now(), attempt)
+		if res.StatusCode != http.StatusTooManyRequests {
+			if res.Header.Get("X-Rate-Limit-Remaining") == "0" {
+				t.setReset(time.Now().Add(wait))
+			}
+			return res, nil
+		}
+
+		// the request body can only be sent again if the request provides a way to get a new copy
+		if attempt >= oktaRateLimitRetries || wait > oktaRateLimitMaxWait ||
+			(req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+			return res, nil
+		}
+
+		r = req.Clone(req.Context())
+		if req.GetBody != nil {
+			if r.Body, err = req.GetBody(); err != nil {
+				return res, nil //nolint:nilerr // the rate limit response is more useful than the body error
+			}
+		}
+		res.Body.Close()
+
+		t.logger.Warningf("Okta rate limit exceeded, retrying in %s", wait.Round(time.Second))
+		if err = sleepContext(req, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// waitReset waits until the exhausted rate limit reported by an earlier response resets, if the reset is soon enough.
+func (t *oktaRateLimitTransport) waitReset(req *http.Request) error {
+	t.mu.Lock()
+	wait := time.Until(t.resetAt)
+	t.mu.Unlock()
+
+	if wait <= 0 || wait > oktaRateLimitMaxWait {
+		return nil
+	}
+
+	t.logger.Debugf("Okta rate limit reached, waiting %s for the limit to reset", wait.Round(time.Second))
+	return sleepContext(req, wait)
+}
+
+func (t *oktaRateLimitTransport) setReset(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resetAt = at
+}
+
+// sleepContext waits for the duration, or until the context of the request is done.
+func sleepContext(req *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-req.Context().Done():
+		return req.Context().Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// oktaRateLimitWait returns the time to wait before sending a request again, using the rate limit headers of the
+// response.  The reset time is compared to the Date header of the response, when available, so the wait is correct even
+// if the local clock is wrong.  If the response has no usable rate limit headers, the wait grows exponentially with the
+// attempt.  A random delay of up to 1 second is added, so many clients rejected at the same time don't all retry at once.
+func oktaRateLimitWait(h http.Header, now time.Time, attempt int) time.Duration {
+	jitter := time.Duration(rand.Int63n(int64(time.Second))) //nolint:gosec // not used for security
+
+	if v, err := strconv.ParseInt(h.Get("Retry-After"), 10, 64); err == nil && v >= 0 {
+		return time.Duration(v)*time.Second + jitter
+	}
+
+	if v, err := strconv.ParseInt(h.Get("X-Rate-Limit-Reset"), 10, 64); err == nil {
+		if t, err := http.ParseTime(h.Get("Date")); err == nil {
+			now = t
+		}
+
+		if d := time.Unix(v, 0).Sub(now); d > 0 {
+			return d + jitter
+		}
+		return jitter
+	}
+
+	return time.Duration(1<<attempt)*time.Second + jitter
+}
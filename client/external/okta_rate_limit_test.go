@@ -0,0 +1,156 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOktaRateLimitTransport_RoundTrip(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "data" && r.Method == http.MethodPost {
+			http.Error(w, "missing body", http.StatusBadRequest)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/limited":
+			if calls.Add(1) < 2 {
+				w.Header().Set("X-Rate-Limit-Remaining", "0")
+				w.Header().Set("X-Rate-Limit-Reset", strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10))
+				http.Error(w, "rate limited", http.StatusTooManyRequests)
+				return
+			}
+		case "/always":
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		case "/long":
+			w.Header().Set("X-Rate-Limit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	t.Run("retry", func(t *testing.T) {
+		calls.Store(0)
+		hc := &http.Client{Transport: newOktaRateLimitTransport(nil, nil)}
+
+		req, _ := newHttpRequest(context.Background(), http.MethodPost, srv.URL+"/limited")
+		res, err := hc.Do(req.withBody(strings.NewReader("data")).Request)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK || calls.Load() != 2 {
+			t.Errorf("unexpected status %d after %d calls", res.StatusCode, calls.Load())
+		}
+	})
+
+	t.Run("too long", func(t *testing.T) {
+		hc := &http.Client{Transport: newOktaRateLimitTransport(nil, nil)}
+
+		res, err := hc.Get(srv.URL + "/long")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("unexpected status %d", res.StatusCode)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		hc := &http.Client{Transport: newOktaRateLimitTransport(nil, nil)}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		req, _ := newHttpRequest(ctx, http.MethodGet, srv.URL+"/always")
+		if res, err := hc.Do(req.Request); err == nil {
+			res.Body.Close()
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("wait for reset", func(t *testing.T) {
+		rt := newOktaRateLimitTransport(nil, nil)
+		rt.setReset(time.Now().Add(100 * time.Millisecond))
+		hc := &http.Client{Transport: rt}
+
+		start := time.Now()
+		res, err := hc.Get(srv.URL + "/")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer res.Body.Close()
+
+		if time.Since(start) < 100*time.Millisecond {
+			t.Error("request did not wait for the rate limit to reset")
+		}
+	})
+}
+
+func Test_oktaRateLimitWait(t *testing.T) {
+	now := time.Now()
+
+	t.Run("retry after", func(t *testing.T) {
+		h := http.Header{"Retry-After": []string{"5"}}
+		if d := oktaRateLimitWait(h, now, 0); d < 5*time.Second || d >= 6*time.Second {
+			t.Errorf("unexpected wait: %s", d)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		h := http.Header{"X-Rate-Limit-Reset": []string{strconv.FormatInt(now.Add(30*time.Second).Unix(), 10)}}
+		if d := oktaRateLimitWait(h, now, 0); d < 28*time.Second || d >= 31*time.Second {
+			t.Errorf("unexpected wait: %s", d)
+		}
+	})
+
+	t.Run("reset server time", func(t *testing.T) {
+		// the local clock is an hour ahead of the server
+		srvTime := now.Add(-time.Hour)
+		h := http.Header{
+			"X-Rate-Limit-Reset": []string{strconv.FormatInt(srvTime.Add(10*time.Second).Unix(), 10)},
+			"Date":               []string{srvTime.UTC().Format(http.TimeFormat)},
+		}
+		if d := oktaRateLimitWait(h, now, 0); d < 9*time.Second || d >= 12*time.Second {
+			t.Errorf("unexpected wait: %s", d)
+		}
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		if d := oktaRateLimitWait(http.Header{}, now, 2); d < 4*time.Second || d >= 5*time.Second {
+			t.Errorf("unexpected wait: %s", d)
+		}
+	})
+}
@@ -70,6 +70,10 @@ func (c *oneloginClient) Authenticate() error {
 // AuthenticateWithContext performs authentication against OneLogin using the specified Context, which is passed
 // along to the underlying HTTP requests.  If necessary, it will prompt for the authentication credentials.
 func (c *oneloginClient) AuthenticateWithContext(ctx context.Context) error {
+	if err := checkLogin(ctx); err != nil {
+		return err
+	}
+
 	if err := c.gatherCredentials(); err != nil {
 		return err
 	}
@@ -82,12 +86,22 @@ func (c *oneloginClient) Identity() (*identity.Identity, error) {
 	return c.identity(oneloginIdentityProvider), nil
 }
 
-// Roles retrieves the available roles for the user.  Attempting to call this method
+// IdentityWithContext calls Identity, the context is not used.
+func (c *oneloginClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return c.Identity()
+}
+
+// Roles calls RolesWithContext using a background context.
+func (c *oneloginClient) Roles(user ...string) (*identity.Roles, error) {
+	return c.RolesWithContext(context.Background(), user...)
+}
+
+// RolesWithContext retrieves the available roles for the user.  Attempting to call this method
 // against an Oauth/OIDC client will return an error.
-func (c *oneloginClient) Roles(...string) (*identity.Roles, error) {
+func (c *oneloginClient) RolesWithContext(ctx context.Context, _ ...string) (*identity.Roles, error) {
 	if c.saml == nil || len(*c.saml) < 1 {
 		var err error
-		c.saml, err = c.SamlAssertion()
+		c.saml, err = c.SamlAssertionWithContext(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -235,11 +249,22 @@ func (c *oneloginClient) auth(ctx context.Context) error {
 		return err
 	}
 
+	done, err := c.waitLogin(ctx)
+	if err != nil {
+		return err
+	}
+
 	var data []byte
 	data, err = c.sendApiRequest(req)
 	if err != nil {
+		// only count outright credential rejections as failures, rate limiting or server errors don't affect lockout
+		var apiErr *oneloginApiError
+		if errors.As(err, &apiErr) && apiErr.Status != nil && apiErr.Status.Code == http.StatusUnauthorized {
+			done(true)
+		}
 		return err
 	}
+	done(false)
 
 	authReply := new(oneloginAuthReply)
 	if err = json.Unmarshal(data, authReply); err != nil {
@@ -119,16 +119,24 @@ func TestOneloginClient_Authenticate(t *testing.T) {
 func TestOneloginClient_Authenticate_Plain(t *testing.T) {
 	t.Run("no mfa good", func(t *testing.T) {
 		c := newMockOneloginClient()
+		lt := new(mockLoginThrottle)
+		c.SetLoginThrottler(lt)
 		c.Username = "nomfa"
 		c.Password = "goodPassword"
 
 		if err := c.Authenticate(); err != nil {
 			t.Error(err)
 		}
+
+		if lt.waits != 1 || lt.failures != 0 || lt.successes != 1 {
+			t.Errorf("unexpected login throttle calls: %+v", *lt)
+		}
 	})
 
 	t.Run("no mfa bad", func(t *testing.T) {
 		c := newMockOneloginClient()
+		lt := new(mockLoginThrottle)
+		c.SetLoginThrottler(lt)
 		c.CredentialInputProvider = func(u, p string) (string, string, error) {
 			return "nomfa", "badPassword", nil
 		}
@@ -136,6 +144,10 @@ func TestOneloginClient_Authenticate_Plain(t *testing.T) {
 		if err := c.Authenticate(); err == nil {
 			t.Error("did not receive expected error")
 		}
+
+		if lt.waits != 1 || lt.failures != 1 || lt.successes != 0 {
+			t.Errorf("unexpected login throttle calls: %+v", *lt)
+		}
 	})
 }
 
@@ -450,7 +462,9 @@ func olAuthHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if authParam["password"] != "goodPassword" {
-		http.Error(w, "invalid username or password", http.StatusUnauthorized)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"status":{"error":true,"code":401,"type":"Unauthorized","message":"Authentication Failed"}}`))
 		return
 	}
 
@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+)
+
+// DefaultSamlEntityId is the entity ID of the AWS sign-in service provider, used as the issuer of SAML authentication
+// requests sent to the single sign-on endpoint found in identity provider metadata, unless configured otherwise.
+const DefaultSamlEntityId = "https://signin.aws.amazon.com/saml"
+
+// samlMetadataTimeout is the limit for fetching identity provider metadata from a URL.
+const samlMetadataTimeout = 10 * time.Second
+
+var (
+	samlMetadataCache   = make(map[string]*SamlMetadata)
+	samlMetadataCacheMu sync.Mutex
+)
+
+// SamlMetadata is the identity provider information found in SAML IdP metadata.
+type SamlMetadata struct {
+	// EntityId is the entity ID of the identity provider.
+	EntityId string
+	// SsoUrl is the single sign-on service endpoint, which accepts SAML authentication requests.
+	SsoUrl string
+	// Provider is the client provider type able to authenticate using the SsoUrl.
+	Provider string
+}
+
+// GetSamlMetadata returns the identity provider information in the SAML IdP metadata at location, which is an http(s)
+// URL, a file URL, or the path of a local file.  The metadata is only loaded once for each location.  Metadata at an
+// http(s) URL is fetched using rt, or the shared default transport if rt is nil.
+func GetSamlMetadata(ctx context.Context, location string, rt http.RoundTripper) (*SamlMetadata, error) {
+	samlMetadataCacheMu.Lock()
+	defer samlMetadataCacheMu.Unlock()
+
+	if md, ok := samlMetadataCache[location]; ok {
+		return md, nil
+	}
+
+	data, err := readSamlMetadata(ctx, location, rt)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SAML metadata from %s: %w", location, err)
+	}
+
+	md, err := ParseSamlMetadata(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML metadata from %s: %w", location, err)
+	}
+
+	samlMetadataCache[location] = md
+	return md, nil
+}
+
+// ParseSamlMetadata returns the identity provider information in the SAML IdP metadata document.  The document may be
+// a single EntityDescriptor, or an EntitiesDescriptor, in which case the first entity describing an identity provider
+// is used.  The single sign-on endpoint using the HTTP-Redirect binding is preferred over the HTTP-POST binding.
+//
+// The single sign-on endpoint expects service provider initiated logins, which are supported by the browserne
+// provider, so it is the provider used with the metadata.
+func ParseSamlMetadata(data []byte) (*SamlMetadata, error) {
+	ed, err := samlsp.ParseMetadata(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var sso string
+	for _, binding := range []string{saml.HTTPRedirectBinding, saml.HTTPPostBinding} {
+		if sso = ssoLocation(ed.IDPSSODescriptors, binding); len(sso) > 0 {
+			break
+		}
+	}
+
+	if len(sso) < 1 {
+		return nil, errors.New("no supported single sign-on endpoint found")
+	}
+
+	return &SamlMetadata{EntityId: ed.EntityID, SsoUrl: sso, Provider: browserNEProvider}, nil
+}
+
+func ssoLocation(descriptors []saml.IDPSSODescriptor, binding string) string {
+	for _, d := range descriptors {
+		for _, ep := range d.SingleSignOnServices {
+			if ep.Binding == binding && len(ep.Location) > 0 {
+				return ep.Location
+			}
+		}
+	}
+	return ""
+}
+
+// IsRemoteSamlMetadata returns true if the SAML metadata at location is fetched from an http(s) URL.
+func IsRemoteSamlMetadata(location string) bool {
+	u, err := url.Parse(location)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+func readSamlMetadata(ctx context.Context, location string, rt http.RoundTripper) ([]byte, error) {
+	u, err := url.Parse(location)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		if err == nil && u.Scheme == "file" {
+			location = u.Path
+		}
+		return os.ReadFile(location)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, samlMetadataTimeout)
+	defer cancel()
+
+	req, err := newHttpRequest(ctx, http.MethodGet, u.String())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/samlmetadata+xml, application/xml, text/xml")
+
+	hc := newHttpClient()
+	if rt != nil {
+		hc.Transport = rt
+	}
+
+	res, err := hc.Do(req.Request)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http status %d", res.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, 1<<20))
+}
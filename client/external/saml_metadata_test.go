@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const samlMetadataXml = `<?xml version="1.0" encoding="UTF-8"?>
+<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com/metadata">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.example.com/sso/post"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso/redirect"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+
+const samlEntitiesXml = `<?xml version="1.0" encoding="UTF-8"?>
+<md:EntitiesDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata">
+  <md:EntityDescriptor entityID="https://sp.example.com/metadata">
+    <md:SPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+      <md:AssertionConsumerService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://sp.example.com/acs" index="0"/>
+    </md:SPSSODescriptor>
+  </md:EntityDescriptor>
+  <md:EntityDescriptor entityID="https://idp.example.com/metadata">
+    <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+      <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.example.com/sso/post"/>
+    </md:IDPSSODescriptor>
+  </md:EntityDescriptor>
+</md:EntitiesDescriptor>`
+
+func TestParseSamlMetadata(t *testing.T) {
+	t.Run("redirect binding", func(t *testing.T) {
+		md, err := ParseSamlMetadata([]byte(samlMetadataXml))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if md.EntityId != "https://idp.example.com/metadata" || md.SsoUrl != "https://idp.example.com/sso/redirect" ||
+			md.Provider != browserNEProvider {
+			t.Errorf("data mismatch: %+v", md)
+		}
+	})
+
+	t.Run("entities", func(t *testing.T) {
+		md, err := ParseSamlMetadata([]byte(samlEntitiesXml))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if md.EntityId != "https://idp.example.com/metadata" || md.SsoUrl != "https://idp.example.com/sso/post" {
+			t.Errorf("data mismatch: %+v", md)
+		}
+	})
+
+	t.Run("no sso endpoint", func(t *testing.T) {
+		md := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol"/>
+</md:EntityDescriptor>`
+
+		if _, err := ParseSamlMetadata([]byte(md)); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := ParseSamlMetadata([]byte("not xml")); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestGetSamlMetadata(t *testing.T) {
+	t.Run("file", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "metadata.xml")
+		if err := os.WriteFile(f, []byte(samlMetadataXml), 0600); err != nil {
+			t.Error(err)
+			return
+		}
+
+		for _, loc := range []string{f, "file://" + f} {
+			md, err := GetSamlMetadata(context.Background(), loc, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if md.SsoUrl != "https://idp.example.com/sso/redirect" {
+				t.Errorf("data mismatch: %+v", md)
+			}
+		}
+	})
+
+	t.Run("url", func(t *testing.T) {
+		var calls int
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls++
+			_, _ = w.Write([]byte(samlMetadataXml))
+		}))
+		defer s.Close()
+
+		for range 2 {
+			md, err := GetSamlMetadata(context.Background(), s.URL+"/metadata", nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if md.EntityId != "https://idp.example.com/metadata" {
+				t.Errorf("data mismatch: %+v", md)
+			}
+		}
+
+		if calls != 1 {
+			t.Errorf("unexpected request count: %d", calls)
+		}
+	})
+
+	t.Run("http error", func(t *testing.T) {
+		s := httptest.NewServer(http.NotFoundHandler())
+		defer s.Close()
+
+		if _, err := GetSamlMetadata(context.Background(), s.URL, nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := GetSamlMetadata(context.Background(), filepath.Join(t.TempDir(), "missing.xml"), nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
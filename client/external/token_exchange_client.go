@@ -0,0 +1,193 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/identity"
+)
+
+const (
+	tokenExchangeIdentityProvider = "TokenExchangeIdentityProvider"
+
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	tokenTypeJwt           = "urn:ietf:params:oauth:token-type:jwt"
+	tokenTypeIdToken       = "urn:ietf:params:oauth:token-type:id_token"
+)
+
+type tokenExchangeClient struct {
+	*baseClient
+	subjectToken *credentials.OidcIdentityToken
+}
+
+// NewTokenExchangeClient creates a client which exchanges an existing token (like a JWT issued by an internal identity
+// provider) for an identity token usable with AWS, using OAuth 2.0 Token Exchange (RFC 8693).  The url parameter is
+// the token endpoint of the authorization server performing the exchange.  The token to exchange is read from the
+// SubjectTokenFile set in the client configuration.
+func NewTokenExchangeClient(url string) (*tokenExchangeClient, error) {
+	bc, err := newBaseClient(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenExchangeClient{baseClient: bc}, nil
+}
+
+// Authenticate calls AuthenticateWithContext using a background context.
+func (c *tokenExchangeClient) Authenticate() error {
+	return c.AuthenticateWithContext(context.Background())
+}
+
+// AuthenticateWithContext reads the subject token from the configured file, there is no interactive authentication
+// with the authorization server.  An error is returned if the token can not be read, or is expired.
+func (c *tokenExchangeClient) AuthenticateWithContext(context.Context) error {
+	if len(c.SubjectTokenFile) < 1 {
+		return errors.New("token exchange requires a subject token file")
+	}
+
+	data, err := os.ReadFile(c.SubjectTokenFile)
+	if err != nil {
+		return fmt.Errorf("unable to read subject token: %w", err)
+	}
+
+	tok := credentials.OidcIdentityToken(strings.TrimSpace(string(data)))
+	if tok.IsExpired() {
+		return fmt.Errorf("subject token in %s has expired, or is not a valid JWT", c.SubjectTokenFile)
+	}
+
+	c.subjectToken = &tok
+	return nil
+}
+
+// Identity returns the identity information for the user, using the username found in the subject token.
+func (c *tokenExchangeClient) Identity() (*identity.Identity, error) {
+	return c.IdentityWithContext(context.Background())
+}
+
+// IdentityWithContext returns the identity information for the user, using the username found in the subject token.
+// The configured username is used if the subject token has no username claim.
+func (c *tokenExchangeClient) IdentityWithContext(ctx context.Context) (*identity.Identity, error) {
+	if c.subjectToken == nil {
+		if err := c.AuthenticateWithContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	id := &identity.Identity{
+		IdentityType: "user",
+		Provider:     tokenExchangeIdentityProvider,
+		Username:     c.Username,
+	}
+
+	claims, _ := c.subjectToken.Claims()
+	for _, k := range []string{"preferred_username", "email", "sub"} {
+		if v, ok := claims[k].(string); ok && len(v) > 0 {
+			id.Username = v
+			break
+		}
+	}
+
+	return id, nil
+}
+
+// Roles calls RolesWithContext using a background context.
+func (c *tokenExchangeClient) Roles(user ...string) (*identity.Roles, error) {
+	return c.RolesWithContext(context.Background(), user...)
+}
+
+// RolesWithContext is not supported, a token exchange is not role aware, so an error is always returned.
+func (c *tokenExchangeClient) RolesWithContext(context.Context, ...string) (*identity.Roles, error) {
+	return nil, errors.New("OIDC clients are not role aware")
+}
+
+// IdentityToken calls IdentityTokenWithContext using a background context.
+func (c *tokenExchangeClient) IdentityToken() (*credentials.OidcIdentityToken, error) {
+	return c.IdentityTokenWithContext(context.Background())
+}
+
+// IdentityTokenWithContext exchanges the subject token for an identity token at the authorization server's token
+// endpoint.  The subject token is read again for every exchange, so an updated token in the file is always used.
+func (c *tokenExchangeClient) IdentityTokenWithContext(ctx context.Context) (*credentials.OidcIdentityToken, error) {
+	if err := c.AuthenticateWithContext(ctx); err != nil {
+		return nil, err
+	}
+
+	data := url.Values{}
+	data.Set("grant_type", tokenExchangeGrantType)
+	data.Set("client_id", c.ClientId)
+	data.Set("subject_token", c.subjectToken.String())
+	data.Set("subject_token_type", tokenTypeJwt)
+	data.Set("requested_token_type", tokenTypeIdToken)
+
+	if len(c.Audience) > 0 {
+		data.Set("audience", c.Audience)
+	}
+
+	scopes := append([]string{"openid"}, c.Scopes...)
+	data.Set("scope", strings.Join(scopes, " "))
+
+	req, err := newHttpRequest(ctx, http.MethodPost, c.authUrl.String())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", contentTypeJson)
+
+	res, err := c.httpClient.Do(req.withValues(data).Request)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange request error: %w", err)
+	}
+	defer res.Body.Close()
+	recordServerTime(res)
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		oe := new(oauthError)
+		if json.Unmarshal(body, oe) == nil && len(oe.Code) > 0 {
+			return nil, fmt.Errorf("token exchange failed: %w", oe)
+		}
+		return nil, fmt.Errorf("token exchange failed: http status %s", res.Status)
+	}
+
+	token := new(oauthToken)
+	if err = json.Unmarshal(body, token); err != nil {
+		return nil, err
+	}
+
+	// the issued token is returned in the access_token field (RFC 8693 section 2.2.1), whatever its type
+	tok := token.IdToken
+	if len(token.AccessToken) > 0 {
+		v := credentials.OidcIdentityToken(token.AccessToken)
+		tok = &v
+	}
+
+	// AWS only accepts JWTs with an expiration time, catch opaque access tokens here with a useful error
+	if tok == nil || tok.IsExpired() {
+		return nil, fmt.Errorf("token exchange did not issue a valid JWT (issued token type '%s')", token.IssuedTokenType)
+	}
+	return tok, nil
+}
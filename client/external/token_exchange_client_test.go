@@ -0,0 +1,236 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package external
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/shared"
+)
+
+var tokenExchangeMock = httptest.NewServer(http.HandlerFunc(mockTokenExchangeHandler))
+
+func TestNewTokenExchangeClient(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		c, err := NewTokenExchangeClient(tokenExchangeMock.URL)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if c.baseClient == nil {
+			t.Error("invalid client")
+		}
+	})
+
+	t.Run("bad url", func(t *testing.T) {
+		if _, err := NewTokenExchangeClient("ftp://localhost/token"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("lookup", func(t *testing.T) {
+		cfg := OidcClientConfig{SubjectTokenFile: "token.jwt"}
+		c, err := GetWebIdentityClient("", tokenExchangeMock.URL, cfg)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if _, ok := c.(*tokenExchangeClient); !ok {
+			t.Error("did not return token exchange client")
+		}
+	})
+
+	t.Run("saml", func(t *testing.T) {
+		if _, err := GetSamlClient(tokenExchangeProvider, tokenExchangeMock.URL, AuthenticationClientConfig{}); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestTokenExchangeClient_Authenticate(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		c := newMockTokenExchangeClient(t, mockJwt(map[string]any{"sub": "me", "exp": time.Now().Add(1 * time.Hour).Unix()}))
+		if err := c.Authenticate(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		c := newMockTokenExchangeClient(t, mockJwt(map[string]any{"sub": "me", "exp": time.Now().Add(-1 * time.Hour).Unix()}))
+		if err := c.Authenticate(); err == nil || !strings.Contains(err.Error(), "expired") {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		c := newMockTokenExchangeClient(t, "")
+		c.SubjectTokenFile = filepath.Join(t.TempDir(), "missing")
+		if err := c.Authenticate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("no file", func(t *testing.T) {
+		c := newMockTokenExchangeClient(t, "")
+		c.SubjectTokenFile = ""
+		if err := c.Authenticate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestTokenExchangeClient_Identity(t *testing.T) {
+	t.Run("preferred username", func(t *testing.T) {
+		c := newMockTokenExchangeClient(t, mockJwt(map[string]any{"sub": "1234", "preferred_username": "me",
+			"exp": time.Now().Add(1 * time.Hour).Unix()}))
+
+		id, err := c.Identity()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if id.Username != "me" || id.Provider != tokenExchangeIdentityProvider {
+			t.Errorf("unexpected identity: %+v", id)
+		}
+	})
+
+	t.Run("subject", func(t *testing.T) {
+		c := newMockTokenExchangeClient(t, mockJwt(map[string]any{"sub": "1234", "exp": time.Now().Add(1 * time.Hour).Unix()}))
+		if id, err := c.Identity(); err != nil || id.Username != "1234" {
+			t.Errorf("unexpected identity: %+v, %v", id, err)
+		}
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		c := newMockTokenExchangeClient(t, "")
+		if _, err := c.Roles(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestTokenExchangeClient_IdentityToken(t *testing.T) {
+	subject := mockJwt(map[string]any{"sub": "me", "exp": time.Now().Add(1 * time.Hour).Unix()})
+
+	t.Run("good", func(t *testing.T) {
+		c := newMockTokenExchangeClient(t, subject)
+		c.Audience = "sts.amazonaws.com"
+
+		tok, err := c.IdentityTokenWithContext(context.Background())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if iss := tok.Issuer(); iss != "https://exchange.example.com" {
+			t.Errorf("unexpected token issuer: %s", iss)
+		}
+	})
+
+	t.Run("id_token response", func(t *testing.T) {
+		c := newMockTokenExchangeClient(t, subject)
+		c.Audience = "id_token"
+
+		if _, err := c.IdentityToken(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("oauth error", func(t *testing.T) {
+		c := newMockTokenExchangeClient(t, subject)
+		c.ClientId = "bad"
+
+		_, err := c.IdentityToken()
+		if err == nil || !strings.Contains(err.Error(), "unauthorized_client: client not allowed to exchange tokens") {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("opaque token", func(t *testing.T) {
+		c := newMockTokenExchangeClient(t, subject)
+		c.Audience = "opaque"
+
+		_, err := c.IdentityToken()
+		if err == nil || !strings.Contains(err.Error(), "did not issue a valid JWT") {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+}
+
+func newMockTokenExchangeClient(t *testing.T, token string) *tokenExchangeClient {
+	f := filepath.Join(t.TempDir(), "token.jwt")
+	_ = os.WriteFile(f, []byte(token+"\n"), 0600)
+
+	c, _ := NewTokenExchangeClient(tokenExchangeMock.URL + "/token")
+	c.ClientId = "my-app"
+	c.SubjectTokenFile = f
+	c.Logger = new(shared.DefaultLogger)
+	return c
+}
+
+func mockJwt(claims map[string]any) string {
+	c, _ := json.Marshal(claims)
+	return fmt.Sprintf("e30.%s.sig", base64.RawURLEncoding.EncodeToString(c))
+}
+
+func mockTokenExchangeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/token" || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	_ = r.ParseForm()
+	w.Header().Set("Content-Type", contentTypeJson)
+
+	if r.PostForm.Get("grant_type") != tokenExchangeGrantType || r.PostForm.Get("subject_token_type") != tokenTypeJwt ||
+		len(r.PostForm.Get("subject_token")) < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error": "invalid_request"}`))
+		return
+	}
+
+	if r.PostForm.Get("client_id") != "my-app" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error": "unauthorized_client", "error_description": "client not allowed to exchange tokens"}`))
+		return
+	}
+
+	tok := mockJwt(map[string]any{"iss": "https://exchange.example.com", "aud": r.PostForm.Get("audience"),
+		"exp": time.Now().Add(1 * time.Hour).Unix()})
+
+	body := map[string]any{"issued_token_type": tokenTypeIdToken, "token_type": "N_A", "expires_in": 3600}
+	switch r.PostForm.Get("audience") {
+	case "id_token":
+		body["id_token"] = tok
+	case "opaque":
+		body["access_token"] = "2YotnFZFEjr1zCsicMWpAA"
+		body["issued_token_type"] = "urn:ietf:params:oauth:token-type:access_token"
+	default:
+		body["access_token"] = tok
+	}
+	_ = json.NewEncoder(w).Encode(body)
+}
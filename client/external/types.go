@@ -16,6 +16,7 @@ package external
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/mmmorris1975/aws-runas/credentials"
@@ -42,6 +43,10 @@ const (
 var (
 	errMfaNotConfigured   = errors.New("MFA token is empty, and no token provider configured")
 	errOauthStateMismatch = errors.New("oauth state token mismatch")
+
+	// ErrPasswordExpired is returned when the identity provider reports the user's password has expired, and it
+	// could not be changed as part of the authentication.
+	ErrPasswordExpired = errors.New("identity provider password has expired")
 )
 
 // AuthenticationClient is the specification for integration with external identity providers, like Okta,
@@ -50,6 +55,22 @@ type AuthenticationClient interface {
 	Authenticate() error
 	AuthenticateWithContext(ctx context.Context) error
 	SetCookieJar(jar http.CookieJar)
+	SetTransport(rt http.RoundTripper)
+	SetLoginThrottler(t LoginThrottler)
+}
+
+// LoginThrottler limits the rate of password submissions to an identity provider, to avoid locking out a user's
+// account when the wrong password is repeatedly submitted.  The key identifies the identity provider and user.
+type LoginThrottler interface {
+	Wait(ctx context.Context, key string) error
+	Failure(key string)
+	Success(key string)
+}
+
+// SamlAssertionSetter is implemented by SAML clients which can use a SAML assertion already retrieved for the same
+// identity provider and user, so Identity, Roles, and SamlAssertion calls use it instead of authenticating again.
+type SamlAssertionSetter interface {
+	SetSamlAssertion(saml *credentials.SamlAssertion)
 }
 
 // SamlClient is a type of AuthenticationClient which is capable of returning SAML Assertion documents
@@ -105,6 +126,12 @@ type AuthenticationClientConfig struct {
 	SamlProvider string
 	// SamlEntityId is the entity ID to use with the SAML provider, if applicable
 	SamlEntityId string
+	// NewPasswordProvider defines a function which the AuthenticationClient can call to get a new password when the
+	// identity provider requires an expired password to be changed.  If nil, ErrPasswordExpired is returned instead.
+	NewPasswordProvider func() (string, error)
+	// PasswordUpdater defines a function which the AuthenticationClient calls with the new password after it is
+	// successfully changed with the identity provider, so any stored copy of the password can be updated.
+	PasswordUpdater func(password string) error
 }
 
 // OidcClientConfig is an extension of AuthenticationClientConfig which defines the extra properties needed to
@@ -120,6 +147,14 @@ type OidcClientConfig struct {
 	// Scopes are the additional OAuth scopes to request with the identity token.  The 'oidc' scope is always
 	// explicitly requested
 	Scopes []string
+	// SubjectTokenFile is the file containing an existing token (like a JWT from an internal identity provider)
+	// which the token exchange client exchanges for the identity token used with AWS.
+	SubjectTokenFile string
+	// Audience is the optional audience to request for the identity token issued by a token exchange
+	Audience string
+	// PkceMode controls the use of PKCE in the authorization code flow, see the config.Pkce* constants for supported
+	// values.  If unset, config.PkceAuto is used.
+	PkceMode string
 }
 
 type oauthToken struct {
@@ -128,4 +163,19 @@ type oauthToken struct {
 	IdToken     *credentials.OidcIdentityToken `json:"id_token"`
 	Scope       string                         `json:"scope"`
 	TokenType   string                         `json:"token_type"`
+	// IssuedTokenType is only returned by a token exchange (RFC 8693)
+	IssuedTokenType string `json:"issued_token_type"`
+}
+
+// oauthError is the error response body for OAuth token requests (RFC 6749 section 5.2).
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *oauthError) Error() string {
+	if len(e.Description) > 0 {
+		return fmt.Sprintf("%s: %s", e.Code, e.Description)
+	}
+	return e.Code
 }
@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync/atomic"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+// forceRefresh returns true if the ForceRefresh option is set, and the cached state identified by key has not been
+// force-refreshed by the factory yet.  Cached state is only ignored once per factory, so long-running services (which
+// get a client for every request) don't ignore the credentials they just fetched.
+func (f *Factory) forceRefresh(key string) bool {
+	if !f.options.ForceRefresh {
+		return false
+	}
+	_, done := f.forced.LoadOrStore(key, true)
+	return !done
+}
+
+// clientCache returns the credential cache for the cache file used by a client.  If the ForceRefresh option is set, the
+// cached credentials are ignored the first time the cache is used.
+func (f *Factory) clientCache(cfg *config.AwsConfig, file string) credentials.CredentialCacher {
+	c := f.credentialCache(cfg, file)
+	if f.forceRefresh(file) {
+		return &refreshCredentialCache{CredentialCacher: c}
+	}
+	return c
+}
+
+// cookieJar returns the shared cookie jar.  If the ForceRefresh option is set, cookies already in the jar (like an
+// identity provider session) are ignored the first time the jar is used.
+func (f *Factory) cookieJar(cfg *config.AwsConfig) http.CookieJar {
+	jar := sharedCookieJar(cfg)
+	if f.forceRefresh(cacheFilePath(cfg, cookieJarFile)) {
+		// this never returns an error, so don't bother checking
+		session, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+		return &refreshCookieJar{CookieJar: jar, session: session}
+	}
+	return jar
+}
+
+// tokenCache returns the shared identity token cache.  If the ForceRefresh option is set, cached tokens are ignored
+// the first time the cache is used.
+func (f *Factory) tokenCache(cfg *config.AwsConfig) credentials.IdentityTokenCacher {
+	c := sharedTokenCache(cfg)
+	if f.forceRefresh(cacheFilePath(cfg, tokenCacheFile)) {
+		return &refreshTokenCache{IdentityTokenCacher: c}
+	}
+	return c
+}
+
+// samlRoleCache returns the shared SAML role cache.  If the ForceRefresh option is set, the cached roles are ignored
+// the first time the cache is used.
+func (f *Factory) samlRoleCache(cfg *config.AwsConfig) credentials.SamlRoleCacher {
+	c := sharedSamlRoleCache(cfg)
+	if c != nil && f.forceRefresh(cacheFilePath(cfg, samlRoleCacheFile)) {
+		return &refreshSamlRoleCache{SamlRoleCacher: c}
+	}
+	return c
+}
+
+// oidcProviderCache returns the shared OIDC provider cache.  If the ForceRefresh option is set, the cached provider
+// data is ignored the first time the cache is used.
+func (f *Factory) oidcProviderCache(cfg *config.AwsConfig) credentials.OidcProviderCacher {
+	c := sharedOidcProviderCache(cfg)
+	if c != nil && f.forceRefresh(cacheFilePath(cfg, oidcCacheFile)) {
+		return &refreshOidcProviderCache{OidcProviderCacher: c}
+	}
+	return c
+}
+
+// refreshCredentialCache is a CredentialCacher which ignores the cached credentials until new credentials are stored.
+// Nothing is removed from the wrapped cache, the new credentials replace the cached credentials when they're stored.
+type refreshCredentialCache struct {
+	credentials.CredentialCacher
+	stored atomic.Bool
+}
+
+// Load returns an expired set of credentials until new credentials are stored, then loads from the wrapped cache.
+func (c *refreshCredentialCache) Load() *credentials.Credentials {
+	if !c.stored.Load() {
+		return new(credentials.Credentials)
+	}
+	return c.CredentialCacher.Load()
+}
+
+// Store saves the credentials to the wrapped cache.
+func (c *refreshCredentialCache) Store(creds *credentials.Credentials) error {
+	if err := c.CredentialCacher.Store(creds); err != nil {
+		return err
+	}
+	c.stored.Store(true)
+	return nil
+}
+
+// refreshTokenCache is an IdentityTokenCacher which ignores the cached tokens until a new token is stored.
+type refreshTokenCache struct {
+	credentials.IdentityTokenCacher
+	stored atomic.Bool
+}
+
+// Load returns nil until a new token is stored, then loads from the wrapped cache.
+func (c *refreshTokenCache) Load(url string) *credentials.OidcIdentityToken {
+	if !c.stored.Load() {
+		return nil
+	}
+	return c.IdentityTokenCacher.Load(url)
+}
+
+// Store saves the token to the wrapped cache.
+func (c *refreshTokenCache) Store(url string, token *credentials.OidcIdentityToken) error {
+	if err := c.IdentityTokenCacher.Store(url, token); err != nil {
+		return err
+	}
+	c.stored.Store(true)
+	return nil
+}
+
+// refreshSamlRoleCache is a SamlRoleCacher which ignores the cached roles until new roles are stored.
+type refreshSamlRoleCache struct {
+	credentials.SamlRoleCacher
+	stored atomic.Bool
+}
+
+// Load returns nil until new roles are stored, then loads from the wrapped cache.
+func (c *refreshSamlRoleCache) Load(key string) *credentials.SamlRoleList {
+	if !c.stored.Load() {
+		return nil
+	}
+	return c.SamlRoleCacher.Load(key)
+}
+
+// Store saves the roles to the wrapped cache.
+func (c *refreshSamlRoleCache) Store(key string, roles *credentials.SamlRoleList) error {
+	if err := c.SamlRoleCacher.Store(key, roles); err != nil {
+		return err
+	}
+	c.stored.Store(true)
+	return nil
+}
+
+// refreshOidcProviderCache is an OidcProviderCacher which ignores the cached provider data until new data is stored.
+type refreshOidcProviderCache struct {
+	credentials.OidcProviderCacher
+	stored atomic.Bool
+}
+
+// Load returns nil until new provider data is stored, then loads from the wrapped cache.
+func (c *refreshOidcProviderCache) Load(issuer string) *credentials.OidcProvider {
+	if !c.stored.Load() {
+		return nil
+	}
+	return c.OidcProviderCacher.Load(issuer)
+}
+
+// Store saves the provider data to the wrapped cache.
+func (c *refreshOidcProviderCache) Store(issuer string, provider *credentials.OidcProvider) error {
+	if err := c.OidcProviderCacher.Store(issuer, provider); err != nil {
+		return err
+	}
+	c.stored.Store(true)
+	return nil
+}
+
+// refreshCookieJar is an http.CookieJar which only returns the cookies set since it was created, so an existing
+// identity provider session isn't reused.  Cookies are also saved to the wrapped jar, so the new session is kept.
+type refreshCookieJar struct {
+	http.CookieJar
+	session http.CookieJar
+}
+
+// SetCookies saves the cookies to both the session and wrapped cookie jars.
+func (j *refreshCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.session.SetCookies(u, cookies)
+	j.CookieJar.SetCookies(u, cookies)
+}
+
+// Cookies returns the cookies for the URL which were set since the jar was created.
+func (j *refreshCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.session.Cookies(u)
+}
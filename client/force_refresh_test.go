@@ -0,0 +1,230 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/credentials/cache"
+)
+
+func TestFactory_forceRefresh(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		f := NewClientFactory(new(mockResolver), &Options{})
+		if f.forceRefresh("key") {
+			t.Error("force refresh without option")
+		}
+	})
+
+	t.Run("once", func(t *testing.T) {
+		f := NewClientFactory(new(mockResolver), &Options{ForceRefresh: true})
+		if !f.forceRefresh("key") || !f.forceRefresh("other") {
+			t.Error("did not force refresh")
+		}
+
+		if f.forceRefresh("key") {
+			t.Error("forced refresh more than once")
+		}
+	})
+}
+
+func TestRefreshCredentialCache(t *testing.T) {
+	cfg := &config.AwsConfig{ProfileName: "role", CacheDir: t.TempDir()}
+	file := profileCacheFile(cfg)
+	creds := &credentials.Credentials{
+		AccessKeyId:     "AKIAOLD",
+		SecretAccessKey: "MockSecret",
+		Expiration:      time.Now().Add(1 * time.Hour),
+	}
+	if err := cache.NewFileCredentialCache(file).Store(creds); err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewClientFactory(new(mockResolver), &Options{ForceRefresh: true, Logger: DefaultOptions.Logger})
+	c := f.clientCache(cfg, file)
+	if c.Load().Value().HasKeys() {
+		t.Error("cached credentials were not ignored")
+		return
+	}
+
+	creds.AccessKeyId = "AKIANEW"
+	if err := c.Store(creds); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if c.Load().AccessKeyId != "AKIANEW" {
+		t.Error("stored credentials not loaded")
+	}
+
+	if f.clientCache(cfg, file).Load().AccessKeyId != "AKIANEW" {
+		t.Error("cache was ignored more than once")
+	}
+}
+
+func TestRefreshTokenCache(t *testing.T) {
+	idp := "https://idp.example.com"
+	tc := make(mockTokenCache)
+	old := credentials.OidcIdentityToken("old")
+	if err := tc.Store(idp, &old); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &refreshTokenCache{IdentityTokenCacher: tc}
+	if c.Load(idp) != nil {
+		t.Error("cached token was not ignored")
+		return
+	}
+
+	tok := credentials.OidcIdentityToken("new")
+	if err := c.Store(idp, &tok); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if tok := c.Load(idp); tok == nil || *tok != "new" {
+		t.Error("stored token not loaded")
+	}
+}
+
+func TestRefreshSamlRoleCache(t *testing.T) {
+	rc := make(mockSamlRoleCache)
+	if err := rc.Store("key", &credentials.SamlRoleList{Roles: []string{"old"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &refreshSamlRoleCache{SamlRoleCacher: rc}
+	if c.Load("key") != nil {
+		t.Error("cached roles were not ignored")
+		return
+	}
+
+	if err := c.Store("key", &credentials.SamlRoleList{Roles: []string{"new"}}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if l := c.Load("key"); l == nil || l.Roles[0] != "new" {
+		t.Error("stored roles not loaded")
+	}
+}
+
+func TestRefreshOidcProviderCache(t *testing.T) {
+	pc := make(mockOidcProviderCache)
+	if err := pc.Store("issuer", &credentials.OidcProvider{JwksUri: "old"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &refreshOidcProviderCache{OidcProviderCacher: pc}
+	if c.Load("issuer") != nil {
+		t.Error("cached provider data was not ignored")
+		return
+	}
+
+	if err := c.Store("issuer", &credentials.OidcProvider{JwksUri: "new"}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if p := c.Load("issuer"); p == nil || p.JwksUri != "new" {
+		t.Error("stored provider data not loaded")
+	}
+}
+
+func TestRefreshCookieJar(t *testing.T) {
+	u, _ := url.Parse("https://idp.example.com/")
+	jar := cache.CookieJar(filepath.Join(t.TempDir(), "cookies"))
+	jar.SetCookies(u, []*http.Cookie{{Name: "old", Value: "session", Expires: time.Now().Add(1 * time.Hour)}})
+
+	cfg := &config.AwsConfig{CacheDir: t.TempDir()}
+	f := NewClientFactory(new(mockResolver), &Options{ForceRefresh: true})
+	rj := f.cookieJar(cfg)
+	if _, ok := rj.(*refreshCookieJar); !ok {
+		t.Error("did not return refresh cookie jar")
+		return
+	}
+
+	j := &refreshCookieJar{CookieJar: jar, session: rj.(*refreshCookieJar).session}
+	if len(j.Cookies(u)) > 0 {
+		t.Error("existing cookies were not ignored")
+		return
+	}
+
+	j.SetCookies(u, []*http.Cookie{{Name: "new", Value: "session", Expires: time.Now().Add(1 * time.Hour)}})
+	if c := j.Cookies(u); len(c) != 1 || c[0].Name != "new" {
+		t.Errorf("unexpected session cookies: %v", c)
+	}
+
+	if c := jar.Cookies(u); len(c) != 2 {
+		t.Errorf("new cookie not saved to shared jar: %v", c)
+	}
+
+	if _, ok := f.cookieJar(cfg).(*refreshCookieJar); ok {
+		t.Error("cookie jar was ignored more than once")
+	}
+}
+
+type mockTokenCache map[string]*credentials.OidcIdentityToken
+
+func (c mockTokenCache) Load(url string) *credentials.OidcIdentityToken {
+	return c[url]
+}
+
+func (c mockTokenCache) Store(url string, token *credentials.OidcIdentityToken) error {
+	c[url] = token
+	return nil
+}
+
+func (c mockTokenCache) Clear() error {
+	clear(c)
+	return nil
+}
+
+type mockSamlRoleCache map[string]*credentials.SamlRoleList
+
+func (c mockSamlRoleCache) Load(key string) *credentials.SamlRoleList {
+	return c[key]
+}
+
+func (c mockSamlRoleCache) Store(key string, roles *credentials.SamlRoleList) error {
+	c[key] = roles
+	return nil
+}
+
+func (c mockSamlRoleCache) Clear() error {
+	clear(c)
+	return nil
+}
+
+type mockOidcProviderCache map[string]*credentials.OidcProvider
+
+func (c mockOidcProviderCache) Load(issuer string) *credentials.OidcProvider {
+	return c[issuer]
+}
+
+func (c mockOidcProviderCache) Store(issuer string, provider *credentials.OidcProvider) error {
+	c[issuer] = provider
+	return nil
+}
+
+func (c mockOidcProviderCache) Clear() error {
+	clear(c)
+	return nil
+}
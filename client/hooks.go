@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+// Hooks is a set of optional callbacks which are called when the state of a client's credentials changes.  Callbacks
+// are executed synchronously, so long running work should be done in a separate goroutine.
+type Hooks struct {
+	// OnAcquire is called the first time credentials are retrieved by the client.
+	OnAcquire func(creds *credentials.Credentials)
+	// OnRefresh is called when the client retrieves credentials which are different from the previous set.
+	OnRefresh func(creds *credentials.Credentials)
+	// OnExpire is called when the most recently retrieved credentials expire, and no new credentials were retrieved.
+	OnExpire func(expiredAt time.Time)
+	// OnError is called when the client fails to retrieve credentials.
+	OnError func(err error)
+}
+
+// hookClient wraps an AwsClient, calling the configured Hooks as credentials are retrieved.
+type hookClient struct {
+	AwsClient
+	hooks *Hooks
+	mu    sync.Mutex
+	last  *credentials.Credentials
+	timer *time.Timer
+}
+
+// samlHookClient is a hookClient which retains the SamlAssertionClient behavior of the wrapped client.
+type samlHookClient struct {
+	*hookClient
+	saml SamlAssertionClient
+}
+
+func newHookClient(cl AwsClient, hooks *Hooks) AwsClient {
+	hc := &hookClient{AwsClient: cl, hooks: hooks}
+	if sc, ok := cl.(SamlAssertionClient); ok {
+		return &samlHookClient{hookClient: hc, saml: sc}
+	}
+	return hc
+}
+
+// Credentials calls CredentialsWithContext with a background context.
+func (c *hookClient) Credentials() (*credentials.Credentials, error) {
+	return c.CredentialsWithContext(context.Background())
+}
+
+// CredentialsWithContext retrieves credentials from the wrapped client, and calls the appropriate hooks.
+func (c *hookClient) CredentialsWithContext(ctx context.Context) (*credentials.Credentials, error) {
+	creds, err := c.AwsClient.CredentialsWithContext(ctx)
+	c.handle(creds, err)
+	return creds, err
+}
+
+// Refresh retrieves new credentials from the wrapped client, and calls the appropriate hooks.
+func (c *hookClient) Refresh(ctx context.Context) (*credentials.Credentials, error) {
+	creds, err := c.AwsClient.Refresh(ctx)
+	c.handle(creds, err)
+	return creds, err
+}
+
+func (c *hookClient) handle(creds *credentials.Credentials, err error) {
+	if err != nil {
+		if c.hooks.OnError != nil {
+			c.hooks.OnError(err)
+		}
+		return
+	}
+
+	c.mu.Lock()
+	prev := c.last
+	changed := prev == nil || prev.AccessKeyId != creds.AccessKeyId || !prev.Expiration.Equal(creds.Expiration)
+	if changed {
+		c.last = creds
+		c.watchExpiration(creds.Expiration)
+	}
+	c.mu.Unlock()
+
+	switch {
+	case prev == nil:
+		if c.hooks.OnAcquire != nil {
+			c.hooks.OnAcquire(creds)
+		}
+	case changed:
+		if c.hooks.OnRefresh != nil {
+			c.hooks.OnRefresh(creds)
+		}
+	}
+}
+
+// watchExpiration (re)starts the timer used to call the OnExpire hook, the caller must hold the lock.
+func (c *hookClient) watchExpiration(exp time.Time) {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+
+	if c.hooks.OnExpire == nil || exp.IsZero() {
+		return
+	}
+
+	c.timer = time.AfterFunc(time.Until(exp), func() {
+		c.hooks.OnExpire(exp)
+	})
+}
+
+// SamlAssertion calls the SamlAssertion method of the wrapped client.
+func (c *samlHookClient) SamlAssertion() (*credentials.SamlAssertion, error) {
+	return c.saml.SamlAssertion()
+}
+
+// SamlAssertionWithContext calls the SamlAssertionWithContext method of the wrapped client.
+func (c *samlHookClient) SamlAssertionWithContext(ctx context.Context) (*credentials.SamlAssertion, error) {
+	return c.saml.SamlAssertionWithContext(ctx)
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"testing"
+	"time"
+)
+
+func TestHookClient_Credentials(t *testing.T) {
+	var acquired, refreshed, errored int
+	hooks := &Hooks{
+		OnAcquire: func(*credentials.Credentials) { acquired++ },
+		OnRefresh: func(*credentials.Credentials) { refreshed++ },
+		OnError:   func(error) { errored++ },
+	}
+
+	c := newSessionTokenClient()
+	c.provider = credentials.NewSessionTokenProvider(aws.Config{})
+	hc := newHookClient(c, hooks)
+
+	t.Run("acquire", func(t *testing.T) {
+		if _, err := hc.Credentials(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		// cached credentials should not call any hooks
+		if _, err := hc.Credentials(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if acquired != 1 || refreshed != 0 || errored != 0 {
+			t.Errorf("unexpected hook calls: %d %d %d", acquired, refreshed, errored)
+		}
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		// mock provider sets expiration relative to the current time, so these will always be "new" credentials
+		time.Sleep(5 * time.Millisecond)
+		if _, err := hc.Refresh(context.Background()); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if acquired != 1 || refreshed != 1 || errored != 0 {
+			t.Errorf("unexpected hook calls: %d %d %d", acquired, refreshed, errored)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		c.creds = aws.NewCredentialsCache(&mockCredProvider{sendError: true})
+		if _, err := hc.Refresh(context.Background()); err == nil {
+			t.Error("did not receive expected error")
+			return
+		}
+
+		if errored != 1 {
+			t.Errorf("unexpected hook calls: %d %d %d", acquired, refreshed, errored)
+		}
+	})
+}
+
+func TestHookClient_OnExpire(t *testing.T) {
+	ch := make(chan time.Time, 1)
+	hc := &hookClient{hooks: &Hooks{OnExpire: func(exp time.Time) { ch <- exp }}}
+	hc.handle(&credentials.Credentials{AccessKeyId: "mockAK", Expiration: time.Now().Add(10 * time.Millisecond)}, nil)
+
+	select {
+	case <-ch:
+	case <-time.After(1 * time.Second):
+		t.Error("OnExpire hook not called")
+	}
+}
+
+func TestNewHookClient(t *testing.T) {
+	t.Run("saml", func(t *testing.T) {
+		cl := newHookClient(&samlRoleClient{samlClient: new(mockSamlClient), roleProvider: new(mockSamlRoleProvider)}, new(Hooks))
+		if _, ok := cl.(SamlAssertionClient); !ok {
+			t.Error("client is not a SamlAssertionClient")
+		}
+	})
+
+	t.Run("iam", func(t *testing.T) {
+		cl := newHookClient(newSessionTokenClient(), new(Hooks))
+		if _, ok := cl.(SamlAssertionClient); ok {
+			t.Error("client should not be a SamlAssertionClient")
+		}
+	})
+
+	t.Run("factory", func(t *testing.T) {
+		opts := *DefaultOptions
+		opts.Hooks = &Hooks{OnError: func(error) {}}
+
+		cl, err := NewClientFactory(new(mockResolver), &opts).Get(nil)
+		if err == nil || cl != nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
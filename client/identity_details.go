@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/identity"
+)
+
+// identityDetailsClient wraps an AwsClient, adding the AWS caller ARN, account ID, and organization ID to the identity
+// returned by the wrapped client.  The details are looked up using the client's credentials only when the identity
+// is requested, so clients which are only used for credentials don't make the extra API calls.
+type identityDetailsClient struct {
+	AwsClient
+	stsApi func() identity.StsApi
+	orgApi func() identity.OrgApi
+}
+
+// samlIdentityDetailsClient is an identityDetailsClient which retains the SamlAssertionClient behavior of the wrapped
+// client.
+type samlIdentityDetailsClient struct {
+	*identityDetailsClient
+	saml SamlAssertionClient
+}
+
+func newIdentityDetailsClient(cl AwsClient) AwsClient {
+	dc := &identityDetailsClient{AwsClient: cl}
+	dc.stsApi = func() identity.StsApi { return sts.NewFromConfig(dc.ConfigProvider()) }
+	dc.orgApi = func() identity.OrgApi { return organizations.NewFromConfig(dc.ConfigProvider()) }
+
+	if sc, ok := cl.(SamlAssertionClient); ok {
+		return &samlIdentityDetailsClient{identityDetailsClient: dc, saml: sc}
+	}
+	return dc
+}
+
+// Identity calls IdentityWithContext with a background context.
+func (c *identityDetailsClient) Identity() (*identity.Identity, error) {
+	return c.IdentityWithContext(context.Background())
+}
+
+// IdentityWithContext retrieves the identity from the wrapped client, and adds the AWS caller and organization details.
+func (c *identityDetailsClient) IdentityWithContext(ctx context.Context) (*identity.Identity, error) {
+	id, err := c.AwsClient.IdentityWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = identity.Enrich(ctx, id, c.stsApi(), c.orgApi()); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// SamlAssertion calls the SamlAssertion method of the wrapped client.
+func (c *samlIdentityDetailsClient) SamlAssertion() (*credentials.SamlAssertion, error) {
+	return c.saml.SamlAssertion()
+}
+
+// SamlAssertionWithContext calls the SamlAssertionWithContext method of the wrapped client.
+func (c *samlIdentityDetailsClient) SamlAssertionWithContext(ctx context.Context) (*credentials.SamlAssertion, error) {
+	return c.saml.SamlAssertionWithContext(ctx)
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/mmmorris1975/aws-runas/identity"
+)
+
+func TestIdentityDetailsClient_Identity(t *testing.T) {
+	newClient := func(stsErr, orgErr bool) *identityDetailsClient {
+		dc := newIdentityDetailsClient(newSessionTokenClient()).(*identityDetailsClient)
+		dc.stsApi = func() identity.StsApi { return &mockDetailsStsApi{sendError: stsErr} }
+		dc.orgApi = func() identity.OrgApi { return &mockDetailsOrgApi{sendError: orgErr} }
+		return dc
+	}
+
+	t.Run("good", func(t *testing.T) {
+		id, err := newClient(false, false).Identity()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if id.Username != "mockUser" || id.Arn != "arn:aws:sts::123456789012:assumed-role/Admin/mockUser" ||
+			id.Account != "123456789012" || id.Organization != "o-mock" {
+			t.Errorf("data mismatch: %+v", id)
+		}
+	})
+
+	t.Run("organization denied", func(t *testing.T) {
+		id, err := newClient(false, true).Identity()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if id.Account != "123456789012" || len(id.Organization) > 0 {
+			t.Errorf("data mismatch: %+v", id)
+		}
+	})
+
+	t.Run("sts error", func(t *testing.T) {
+		if _, err := newClient(true, false).Identity(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("identity error", func(t *testing.T) {
+		c := newSessionTokenClient()
+		c.ident = &mockIdent{sendError: true}
+
+		if _, err := newIdentityDetailsClient(c).Identity(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+type mockDetailsStsApi struct {
+	sendError bool
+}
+
+func (m *mockDetailsStsApi) GetCallerIdentity(context.Context, *sts.GetCallerIdentityInput, ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	if m.sendError {
+		return nil, errors.New("error: GetCallerIdentity()")
+	}
+
+	return &sts.GetCallerIdentityOutput{
+		Account: aws.String("123456789012"),
+		Arn:     aws.String("arn:aws:sts::123456789012:assumed-role/Admin/mockUser"),
+		UserId:  aws.String("AROAMOCK:mockUser"),
+	}, nil
+}
+
+type mockDetailsOrgApi struct {
+	sendError bool
+}
+
+func (m *mockDetailsOrgApi) DescribeOrganization(context.Context, *organizations.DescribeOrganizationInput, ...func(*organizations.Options)) (*organizations.DescribeOrganizationOutput, error) {
+	if m.sendError {
+		return nil, errors.New("AccessDeniedException")
+	}
+	return &organizations.DescribeOrganizationOutput{Organization: &types.Organization{Id: aws.String("o-mock")}}, nil
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// jumpCredentials holds the jump role credentials provider shared by the clients of all profiles in the process which
+// use the same jump role, identity provider, and user.  The jump role is assumed once for all of the target roles, and
+// concurrent clients (like the batch command, or the metadata credential service) wait for the same request instead of
+// each authenticating with the identity provider.
+var jumpCredentials sync.Map
+
+// jumpCredentialsKey is the key of the shared jump role credentials.  The jump role cache file is keyed by the jump role
+// ARN (in the cache directory of the profile), the identity provider URL and username keep different identities apart.
+func jumpCredentialsKey(cacheFile, authUrl, username string) string {
+	return strings.Join([]string{cacheFile, authUrl, username}, "\x00")
+}
+
+// sharedJumpCredentials returns the jump role credentials provider for the key.  The first client for the key provides
+// the credentials, until the key is removed from the shared providers.
+func sharedJumpCredentials(key string, base CredentialClient) aws.CredentialsProvider {
+	if p, ok := jumpCredentials.Load(key); ok {
+		return p.(aws.CredentialsProvider)
+	}
+
+	p := aws.NewCredentialsCache(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		creds, err := base.CredentialsWithContext(ctx)
+		if err != nil {
+			return aws.Credentials{}, err
+		}
+		return creds.Value(), nil
+	}))
+
+	v, _ := jumpCredentials.LoadOrStore(key, p)
+	return v.(aws.CredentialsProvider)
+}
+
+// forgetJumpCredentials removes the shared jump role credentials for the cache files, so they're retrieved again.  If
+// no files are provided, all shared jump role credentials are removed.
+func forgetJumpCredentials(cacheFiles ...string) {
+	jumpCredentials.Range(func(k, _ any) bool {
+		if len(cacheFiles) < 1 {
+			jumpCredentials.Delete(k)
+			return true
+		}
+
+		for _, f := range cacheFiles {
+			if strings.HasPrefix(k.(string), f+"\x00") {
+				jumpCredentials.Delete(k)
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+// countingCredClient is a CredentialClient which counts the calls to CredentialsWithContext.
+type countingCredClient struct {
+	CredentialClient
+	calls atomic.Int32
+}
+
+func (c *countingCredClient) CredentialsWithContext(context.Context) (*credentials.Credentials, error) {
+	c.calls.Add(1)
+	time.Sleep(10 * time.Millisecond)
+	return &credentials.Credentials{AccessKeyId: "AKIAMOCK", SecretAccessKey: "MockSecret",
+		Expiration: time.Now().Add(1 * time.Hour)}, nil
+}
+
+func TestSharedJumpCredentials(t *testing.T) {
+	defer forgetJumpCredentials()
+
+	key := jumpCredentialsKey("/cache/.aws_saml_role_123456789012-jump", "https://example.org/saml", "bob")
+	first := new(countingCredClient)
+	other := new(countingCredClient)
+
+	t.Run("shared", func(t *testing.T) {
+		wg := new(sync.WaitGroup)
+		for i := 0; i < 10; i++ {
+			base := CredentialClient(first)
+			if i%2 > 0 {
+				base = other
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := sharedJumpCredentials(key, base).Retrieve(context.Background()); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if n := first.calls.Load() + other.calls.Load(); n != 1 {
+			t.Errorf("jump role credentials retrieved %d times", n)
+		}
+	})
+
+	t.Run("different user", func(t *testing.T) {
+		k := jumpCredentialsKey("/cache/.aws_saml_role_123456789012-jump", "https://example.org/saml", "alice")
+		if sharedJumpCredentials(k, other) == sharedJumpCredentials(key, other) {
+			t.Error("credentials shared between users")
+		}
+	})
+
+	t.Run("forget", func(t *testing.T) {
+		p := sharedJumpCredentials(key, other)
+		forgetJumpCredentials("/cache/.aws_saml_role_123456789012-jump")
+
+		if sharedJumpCredentials(key, other) == p {
+			t.Error("shared credentials were not removed")
+		}
+
+		if _, ok := sharedJumpCredentials(key, other).(*aws.CredentialsCache); !ok {
+			t.Error("unexpected provider type")
+		}
+	})
+}
@@ -40,6 +40,8 @@ func (m *mockCredProvider) Retrieve(context.Context) (aws.Credentials, error) {
 		SecretAccessKey: "mockSK",
 		SessionToken:    "mockST",
 		Source:          "mockProvider",
+		CanExpire:       true,
+		Expires:         m.ExpiresAt(),
 	}, nil
 }
 
@@ -66,6 +68,14 @@ func (m *mockIdent) Roles(...string) (*identity.Roles, error) {
 	return &roles, nil
 }
 
+func (m *mockIdent) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return m.Identity()
+}
+
+func (m *mockIdent) RolesWithContext(_ context.Context, user ...string) (*identity.Roles, error) {
+	return m.Roles(user...)
+}
+
 func (m *mockIdent) Identity() (*identity.Identity, error) {
 	if m.sendError {
 		return nil, errors.New("error: Identity()")
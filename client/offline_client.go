@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/credentials/cache"
+	"github.com/mmmorris1975/aws-runas/identity"
+)
+
+// ErrOffline is returned when an operation requires network access, and offline mode is enabled.
+var ErrOffline = errors.New("offline mode is enabled")
+
+// OfflineTransport is an http.RoundTripper which fails every request with ErrOffline.
+var OfflineTransport http.RoundTripper = offlineTransport{}
+
+type offlineTransport struct{}
+
+// RoundTrip is the implementation of the http.RoundTripper interface, and always returns an error.
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	return nil, fmt.Errorf("%w, not sending request to %s", ErrOffline, req.URL.Host)
+}
+
+// offlineClient is an AwsClient which only returns the credentials found in the local cache for a profile.  It never
+// contacts AWS or an identity provider, and never prompts for input.
+type offlineClient struct {
+	cfg *config.AwsConfig
+}
+
+func newOfflineClient(cfg *config.AwsConfig) *offlineClient {
+	return &offlineClient{cfg: cfg}
+}
+
+// Credentials calls CredentialsWithContext with a background context.
+func (c *offlineClient) Credentials() (*credentials.Credentials, error) {
+	return c.CredentialsWithContext(context.Background())
+}
+
+// CredentialsWithContext returns the cached credentials for the profile, or ErrOffline if there are no unexpired
+// credentials in the cache.
+func (c *offlineClient) CredentialsWithContext(context.Context) (*credentials.Credentials, error) {
+	name := c.cfg.ProfileName
+	if len(name) < 1 {
+		name = c.cfg.RoleArn
+	}
+
+	creds, err := CachedCredentials(c.cfg)
+	if err != nil || !creds.Value().HasKeys() {
+		return nil, fmt.Errorf("%w, and there are no cached credentials for %s", ErrOffline, name)
+	}
+
+	if !creds.Expiration.After(time.Now()) {
+		return nil, fmt.Errorf("%w, and the cached credentials for %s expired at %s", ErrOffline, name,
+			creds.Expiration.Local().Format(time.RFC1123))
+	}
+	return creds, nil
+}
+
+// Refresh returns the cached credentials, since new credentials can not be retrieved in offline mode.
+func (c *offlineClient) Refresh(ctx context.Context) (*credentials.Credentials, error) {
+	return c.CredentialsWithContext(ctx)
+}
+
+// ExpiresAt returns the expiration time of the cached credentials, or the zero time if there are none.
+func (c *offlineClient) ExpiresAt() time.Time {
+	creds, err := CachedCredentials(c.cfg)
+	if err != nil {
+		return time.Time{}
+	}
+	return creds.Expiration
+}
+
+// ClearCache removes the cached credentials for the profile.
+func (c *offlineClient) ClearCache() error {
+	return cache.NewFileCredentialCache(profileCacheFile(c.cfg)).Clear()
+}
+
+// ConfigProvider returns an aws.Config using the cached credentials.  AWS service clients created with the config
+// fail all requests with ErrOffline, without retrying.
+func (c *offlineClient) ConfigProvider() aws.Config {
+	return aws.Config{
+		Region: c.cfg.Region,
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			creds, err := c.CredentialsWithContext(ctx)
+			if err != nil {
+				return aws.Credentials{}, err
+			}
+			return creds.Value(), nil
+		}),
+		HTTPClient: &http.Client{Transport: OfflineTransport},
+		Retryer: func() aws.Retryer {
+			return aws.NopRetryer{}
+		},
+	}
+}
+
+// Identity calls IdentityWithContext with a background context.
+func (c *offlineClient) Identity() (*identity.Identity, error) {
+	return c.IdentityWithContext(context.Background())
+}
+
+// IdentityWithContext returns ErrOffline, since the identity can only be found by calling AWS or the identity provider.
+func (c *offlineClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return nil, fmt.Errorf("%w, unable to look up identity", ErrOffline)
+}
+
+// Roles calls RolesWithContext with a background context.
+func (c *offlineClient) Roles() (*identity.Roles, error) {
+	return c.RolesWithContext(context.Background())
+}
+
+// RolesWithContext returns ErrOffline, since the roles can only be found by calling AWS or the identity provider.
+func (c *offlineClient) RolesWithContext(context.Context) (*identity.Roles, error) {
+	return nil, fmt.Errorf("%w, unable to look up roles", ErrOffline)
+}
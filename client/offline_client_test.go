@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/credentials/cache"
+)
+
+func TestOfflineClient_Credentials(t *testing.T) {
+	creds := &credentials.Credentials{
+		AccessKeyId:     "AKIAMOCK",
+		SecretAccessKey: "MockSecret",
+		Token:           "MockToken",
+		Expiration:      time.Now().Add(1 * time.Hour).Round(time.Second),
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		cfg := &config.AwsConfig{ProfileName: "role", RoleArn: "arn:aws:iam::123456789012:role/role", CacheDir: t.TempDir()}
+		if err := cache.NewFileCredentialCache(profileCacheFile(cfg)).Store(creds); err != nil {
+			t.Fatal(err)
+		}
+
+		c := newOfflineClient(cfg)
+		cr, err := c.Credentials()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if cr.AccessKeyId != creds.AccessKeyId || !c.ExpiresAt().Equal(creds.Expiration) {
+			t.Errorf("data mismatch: %+v", cr)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		cfg := &config.AwsConfig{ProfileName: "role", RoleArn: "arn:aws:iam::123456789012:role/role", CacheDir: t.TempDir()}
+		expired := *creds
+		expired.Expiration = time.Now().Add(-1 * time.Minute)
+		if err := cache.NewFileCredentialCache(profileCacheFile(cfg)).Store(&expired); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := newOfflineClient(cfg).Refresh(context.Background()); !errors.Is(err, ErrOffline) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("not cached", func(t *testing.T) {
+		c := newOfflineClient(&config.AwsConfig{ProfileName: "role", CacheDir: t.TempDir()})
+		if _, err := c.Credentials(); !errors.Is(err, ErrOffline) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+
+		if !c.ExpiresAt().IsZero() {
+			t.Error("unexpected expiration time")
+		}
+	})
+
+	t.Run("clear cache", func(t *testing.T) {
+		cfg := &config.AwsConfig{ProfileName: "role", CacheDir: t.TempDir()}
+		if err := cache.NewFileCredentialCache(profileCacheFile(cfg)).Store(creds); err != nil {
+			t.Fatal(err)
+		}
+
+		c := newOfflineClient(cfg)
+		if err := c.ClearCache(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if _, err := c.Credentials(); err == nil {
+			t.Error("cache was not cleared")
+		}
+	})
+}
+
+func TestOfflineClient_Network(t *testing.T) {
+	cfg := &config.AwsConfig{ProfileName: "role", Region: "us-east-1", CacheDir: t.TempDir()}
+	creds := &credentials.Credentials{
+		AccessKeyId:     "AKIAMOCK",
+		SecretAccessKey: "MockSecret",
+		Expiration:      time.Now().Add(1 * time.Hour),
+	}
+	if err := cache.NewFileCredentialCache(profileCacheFile(cfg)).Store(creds); err != nil {
+		t.Fatal(err)
+	}
+	c := newOfflineClient(cfg)
+
+	t.Run("identity", func(t *testing.T) {
+		if _, err := c.Identity(); !errors.Is(err, ErrOffline) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		if _, err := c.Roles(); !errors.Is(err, ErrOffline) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("aws api", func(t *testing.T) {
+		_, err := sts.NewFromConfig(c.ConfigProvider()).GetCallerIdentity(context.Background(), new(sts.GetCallerIdentityInput))
+		if !errors.Is(err, ErrOffline) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+}
+
+func TestFactory_Get_Offline(t *testing.T) {
+	opts := *DefaultOptions
+	opts.Offline = true
+
+	// an incomplete web identity configuration would fail validation, if the client were configured
+	cfg := &config.AwsConfig{ProfileName: "oidc", WebIdentityUrl: "https://idp.example.com", CacheDir: t.TempDir()}
+	cl, err := NewClientFactory(new(mockResolver), &opts).Get(cfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, err = cl.Credentials(); !errors.Is(err, ErrOffline) {
+		t.Errorf("did not receive expected error: %v", err)
+	}
+}
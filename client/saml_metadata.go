@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/client/external"
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+// samlMetadataCachePrefix is the name prefix of the files caching the identity provider information found in SAML
+// metadata at an http(s) URL, so the metadata isn't fetched every time aws-runas runs.
+const samlMetadataCachePrefix = ".aws_saml_metadata"
+
+// samlMetadataMaxAge is how long the cached identity provider information is used before the metadata is fetched again.
+const samlMetadataMaxAge = 7 * 24 * time.Hour
+
+// ApplySamlMetadata sets the SAML configuration derived from the identity provider metadata found at the location in
+// the SamlMetadataUrl field of cfg.  Nothing is loaded if SamlMetadataUrl is empty, or SamlUrl is set.  The single
+// sign-on endpoint from the metadata becomes the SamlUrl, and the derived provider type and entity ID only fill in
+// SamlProvider and SamlEntityId if they are not set, so an explicitly configured provider is always kept.
+//
+// The information found in metadata at an http(s) URL is cached in the cache directory, and only fetched again after
+// samlMetadataMaxAge, using the Transport option of opts.  If the Offline option is set, the cached information is used
+// regardless of its age, and ErrOffline is returned if there is none.
+func ApplySamlMetadata(ctx context.Context, cfg *config.AwsConfig, opts *Options) error {
+	if cfg == nil || len(cfg.SamlMetadataUrl) < 1 || len(cfg.SamlUrl) > 0 {
+		return nil
+	}
+
+	if opts == nil {
+		opts = DefaultOptions
+	}
+
+	md, err := samlMetadata(ctx, cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	cfg.SamlUrl = md.SsoUrl
+	if len(cfg.SamlProvider) < 1 {
+		cfg.SamlProvider = md.Provider
+	}
+
+	if len(cfg.SamlEntityId) < 1 && strings.EqualFold(cfg.SamlProvider, md.Provider) {
+		cfg.SamlEntityId = external.DefaultSamlEntityId
+	}
+	return nil
+}
+
+func samlMetadata(ctx context.Context, cfg *config.AwsConfig, opts *Options) (*external.SamlMetadata, error) {
+	if !external.IsRemoteSamlMetadata(cfg.SamlMetadataUrl) {
+		// local files are cheap to read, and always current
+		return external.GetSamlMetadata(ctx, cfg.SamlMetadataUrl, nil)
+	}
+
+	file := samlMetadataCacheFile(cfg)
+	md, age, err := readSamlMetadataCache(file)
+	if err == nil && (opts.Offline || age < samlMetadataMaxAge) {
+		return md, nil
+	}
+
+	if opts.Offline {
+		return nil, fmt.Errorf("%w, no cached SAML metadata for %s", ErrOffline, cfg.SamlMetadataUrl)
+	}
+
+	md, err = external.GetSamlMetadata(ctx, cfg.SamlMetadataUrl, opts.Transport)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = writeSamlMetadataCache(file, md); err != nil && opts.Logger != nil {
+		opts.Logger.Debugf("unable to cache SAML metadata: %v", err)
+	}
+	return md, nil
+}
+
+func samlMetadataCacheFile(cfg *config.AwsConfig) string {
+	sum := sha256.Sum256([]byte(cfg.SamlMetadataUrl))
+	return cacheFilePath(cfg, fmt.Sprintf("%s_%x", samlMetadataCachePrefix, sum[:8]))
+}
+
+func readSamlMetadataCache(file string) (*external.SamlMetadata, time.Duration, error) {
+	st, err := os.Stat(file)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	md := new(external.SamlMetadata)
+	if err = json.Unmarshal(data, md); err != nil {
+		return nil, 0, err
+	}
+
+	if len(md.SsoUrl) < 1 {
+		return nil, 0, fmt.Errorf("invalid SAML metadata cache file %s", file)
+	}
+	return md, time.Since(st.ModTime()), nil
+}
+
+func writeSamlMetadataCache(file string, md *external.SamlMetadata) error {
+	data, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0600)
+}
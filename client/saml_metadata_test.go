@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/client/external"
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+func TestApplySamlMetadata(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "metadata.xml")
+	md := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com/metadata">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+	if err := os.WriteFile(f, []byte(md), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("derived", func(t *testing.T) {
+		cfg := &config.AwsConfig{SamlMetadataUrl: f}
+		if err := ApplySamlMetadata(context.Background(), cfg, nil); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if cfg.SamlUrl != "https://idp.example.com/sso" || cfg.SamlProvider != "browserne" ||
+			cfg.SamlEntityId != external.DefaultSamlEntityId {
+			t.Errorf("data mismatch: %+v", cfg)
+		}
+	})
+
+	t.Run("explicit settings", func(t *testing.T) {
+		cfg := &config.AwsConfig{SamlMetadataUrl: f, SamlProvider: "browser", SamlEntityId: "urn:amazon:webservices"}
+		if err := ApplySamlMetadata(context.Background(), cfg, nil); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if cfg.SamlUrl != "https://idp.example.com/sso" || cfg.SamlProvider != "browser" ||
+			cfg.SamlEntityId != "urn:amazon:webservices" {
+			t.Errorf("data mismatch: %+v", cfg)
+		}
+	})
+
+	t.Run("auth url set", func(t *testing.T) {
+		cfg := &config.AwsConfig{SamlMetadataUrl: filepath.Join(t.TempDir(), "missing.xml"), SamlUrl: "https://idp"}
+		if err := ApplySamlMetadata(context.Background(), cfg, nil); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(cfg.SamlProvider) > 0 || len(cfg.SamlEntityId) > 0 {
+			t.Errorf("data mismatch: %+v", cfg)
+		}
+	})
+
+	t.Run("bad metadata", func(t *testing.T) {
+		cfg := &config.AwsConfig{SamlMetadataUrl: filepath.Join(t.TempDir(), "missing.xml")}
+		if err := ApplySamlMetadata(context.Background(), cfg, nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("remote cached", func(t *testing.T) {
+		var hits int
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits++
+			_, _ = w.Write([]byte(md))
+		}))
+		defer s.Close()
+
+		dir := t.TempDir()
+		cfg := &config.AwsConfig{SamlMetadataUrl: s.URL + "/cached", CacheDir: dir}
+		if err := ApplySamlMetadata(context.Background(), cfg, &Options{Transport: s.Client().Transport}); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if hits != 1 || cfg.SamlUrl != "https://idp.example.com/sso" {
+			t.Errorf("data mismatch: %d %+v", hits, cfg)
+		}
+
+		if _, err := os.Stat(samlMetadataCacheFile(cfg)); err != nil {
+			t.Errorf("metadata not cached: %v", err)
+		}
+
+		// offline mode uses the cached information, without sending a request
+		cfg = &config.AwsConfig{SamlMetadataUrl: s.URL + "/cached", CacheDir: dir}
+		if err := ApplySamlMetadata(context.Background(), cfg, &Options{Offline: true, Transport: OfflineTransport}); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if hits != 1 || cfg.SamlUrl != "https://idp.example.com/sso" || cfg.SamlProvider != "browserne" {
+			t.Errorf("data mismatch: %d %+v", hits, cfg)
+		}
+	})
+
+	t.Run("offline not cached", func(t *testing.T) {
+		cfg := &config.AwsConfig{SamlMetadataUrl: "https://idp.example.com/metadata", CacheDir: t.TempDir()}
+		err := ApplySamlMetadata(context.Background(), cfg, &Options{Offline: true, Transport: OfflineTransport})
+		if !errors.Is(err, ErrOffline) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+}
@@ -15,6 +15,8 @@ package client
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -22,65 +24,150 @@ import (
 	"github.com/mmmorris1975/aws-runas/client/external"
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"github.com/mmmorris1975/aws-runas/identity"
+	"github.com/mmmorris1975/aws-runas/shared"
 )
 
+// samlRoleListMaxAge is the age after which the cached list of roles from the last SAML assertion is no longer trusted
+// to fail early when the role isn't in the list.  Access may have been granted since then.
+const samlRoleListMaxAge = 12 * time.Hour
+
 type samlRoleClient struct {
-	samlClient   external.SamlClient
-	roleProvider credentials.SamlRoleProvider
-	awsCredCache *aws.CredentialsCache
-	session      aws.Config
+	samlClient     external.SamlClient
+	roleProvider   credentials.SamlRoleProvider
+	awsCredCache   *aws.CredentialsCache
+	session        aws.Config
+	expiresAt      time.Time
+	roleArn        string
+	preferredRoles []string
+	roleCache      credentials.SamlRoleCacher
+	roleCacheKey   string
+	assertionKey   string
+	roleSelection  func(roles []string, last string) (string, error)
+	profile        string
 }
 
 // SamlRoleClientConfig is the means to specify the configuration for the Assume Role with SAML operation.  This includes
 // information necessary to communicate with the external IdP, as well as the configuration for the AWS API calls.
 type SamlRoleClientConfig struct {
 	external.AuthenticationClientConfig
-	Cache    credentials.CredentialCacher
-	Duration time.Duration
-	RoleArn  string
+	Cache          credentials.CredentialCacher
+	Duration       time.Duration
+	RoleArn        string
+	PreferredRoles []string
+	// RoleCache keeps the roles found in the SAML assertions from the identity provider, if set.  Before
+	// authenticating to the identity provider (and possibly prompting for MFA), the client checks the cached roles,
+	// and fails early if the role is not in the user's last SAML assertion.
+	RoleCache credentials.SamlRoleCacher
+	// StsRetryer is the retryer for the STS API calls retrieving credentials, the SDK default is used if nil
+	StsRetryer func() aws.Retryer
+	// RoleSelectionProvider picks one of the roles when RoleArn is a pattern matching multiple roles.  The selection
+	// is remembered for ProfileName in the RoleCache, and offered as the default the next time.
+	RoleSelectionProvider func(roles []string, last string) (string, error)
+	ProfileName           string
+	// SessionScope keeps the SAML assertions of clients in different scopes apart, clients only reuse an assertion
+	// retrieved by another client with the same identity provider, user, and scope.
+	SessionScope string
 }
 
 // NewSamlRoleClient returns a new SAML aware AwsClient for obtaining identity information from the external IdP, and
 // for making the AWS Assume Role with SAML API call.
 func NewSamlRoleClient(cfg aws.Config, url string, clientCfg *SamlRoleClientConfig) *samlRoleClient {
-	p := credentials.NewSamlRoleProvider(cfg, clientCfg.RoleArn, new(credentials.SamlAssertion))
+	p := credentials.NewSamlRoleProvider(stsConfig(cfg, clientCfg.StsRetryer), clientCfg.RoleArn, new(credentials.SamlAssertion))
 	p.Duration = clientCfg.Duration
 	p.Cache = clientCfg.Cache
 	p.Logger = clientCfg.Logger
+	p.PreferredRoles = clientCfg.PreferredRoles
 
 	cfg.Credentials = p
 
-	return &samlRoleClient{
+	c := &samlRoleClient{
 		samlClient:   external.MustGetSamlClient(clientCfg.IdentityProviderName, url, clientCfg.AuthenticationClientConfig),
 		roleProvider: p,
 		session:      cfg,
 		awsCredCache: aws.NewCredentialsCache(p, func(o *aws.CredentialsCacheOptions) {
 			o.ExpiryWindow = p.ExpiryWindow
 		}),
+		roleArn:        clientCfg.RoleArn,
+		preferredRoles: clientCfg.PreferredRoles,
+		roleCache:      clientCfg.RoleCache,
+		roleCacheKey:   samlRoleCacheKey(url, clientCfg.Username),
+		assertionKey:   samlRoleCacheKey(url, clientCfg.Username) + " " + clientCfg.SessionScope,
+		roleSelection:  clientCfg.RoleSelectionProvider,
+		profile:        clientCfg.ProfileName,
+	}
+
+	if c.roleSelection != nil {
+		p.RoleSelector = c.selectRole
 	}
+	return c
 }
 
-// Identity is the implementation of the IdentityClient interface for retrieving identity information from the external IdP.
+// Identity is the implementation of the IdentityClient interface, and calls IdentityWithContext with a background context.
 func (c *samlRoleClient) Identity() (*identity.Identity, error) {
-	return c.samlClient.Identity()
+	return c.IdentityWithContext(context.Background())
+}
+
+// IdentityWithContext is the implementation of the IdentityClient interface for retrieving identity information from
+// the external IdP.
+func (c *samlRoleClient) IdentityWithContext(ctx context.Context) (*identity.Identity, error) {
+	c.useSharedAssertion()
+	return c.samlClient.IdentityWithContext(ctx)
 }
 
-// Roles is the implementation of the IdentityClient interface for retrieving IAM role information from the external IdP.
+// Roles is the implementation of the IdentityClient interface, and calls RolesWithContext with a background context.
 func (c *samlRoleClient) Roles() (*identity.Roles, error) {
-	roles, err := c.samlClient.Roles()
+	return c.RolesWithContext(context.Background())
+}
+
+// RolesWithContext is the implementation of the IdentityClient interface for retrieving IAM role information from the
+// external IdP.
+func (c *samlRoleClient) RolesWithContext(ctx context.Context) (*identity.Roles, error) {
+	c.useSharedAssertion()
+	roles, err := c.samlClient.RolesWithContext(ctx)
 	if err != nil {
-		var saml *credentials.SamlAssertion
-		saml, err = c.samlClient.SamlAssertion()
-		if err != nil {
+		if _, err = c.SamlAssertionWithContext(ctx); err != nil {
 			return nil, err
 		}
-
-		c.roleProvider.SamlAssertion(saml)
-		roles, err = c.samlClient.Roles()
+		roles, err = c.samlClient.RolesWithContext(ctx)
 	}
 	return roles, err
 }
 
+// SamlAssertion is the implementation of the SamlAssertionClient interface, and calls SamlAssertionWithContext with a
+// background context.
+func (c *samlRoleClient) SamlAssertion() (*credentials.SamlAssertion, error) {
+	return c.SamlAssertionWithContext(context.Background())
+}
+
+// SamlAssertionWithContext is the implementation of the SamlAssertionClient interface, returning the SAML assertion
+// from the external IdP.  The assertion is also provided to the role provider so it can be used for any later
+// credential lookups, and the roles in the assertion are saved in the role cache.  A valid assertion retrieved by
+// another client for the same identity provider and user is used instead of authenticating again.
+func (c *samlRoleClient) SamlAssertionWithContext(ctx context.Context) (*credentials.SamlAssertion, error) {
+	c.useSharedAssertion()
+
+	ctx, span := shared.StartSpan(ctx, "idp.SamlAssertion")
+	saml, err := c.samlClient.SamlAssertionWithContext(ctx)
+	shared.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	c.roleProvider.SamlAssertion(saml)
+	storeSamlAssertion(c.assertionKey, saml)
+
+	if c.roleCache != nil {
+		// best effort, the cached roles are only used to fail early when the role isn't available
+		if roles, err := credentials.NewSamlRoleList(saml); err == nil && len(roles.Roles) > 0 {
+			if old := c.roleCache.Load(c.roleCacheKey); old != nil {
+				roles.Selected = old.Selected
+			}
+			_ = c.roleCache.Store(c.roleCacheKey, roles)
+		}
+	}
+	return saml, nil
+}
+
 // Credentials is the implementation of the CredentialClient interface, and calls CredentialsWithContext with a
 // background context.
 func (c *samlRoleClient) Credentials() (*credentials.Credentials, error) {
@@ -94,12 +181,13 @@ func (c *samlRoleClient) CredentialsWithContext(ctx context.Context) (*credentia
 	// we should re-fetch credentials from the IdP and AWS
 	v, err := c.awsCredCache.Retrieve(ctx)
 	if err != nil {
-		var saml *credentials.SamlAssertion
-		saml, err = c.samlClient.SamlAssertionWithContext(ctx)
-		if err != nil {
+		if err = c.checkCachedRoles(); err != nil {
+			return nil, err
+		}
+
+		if _, err = c.SamlAssertionWithContext(ctx); err != nil {
 			return nil, err
 		}
-		c.roleProvider.SamlAssertion(saml)
 
 		v, err = c.awsCredCache.Retrieve(ctx)
 		if err != nil {
@@ -114,10 +202,75 @@ func (c *samlRoleClient) CredentialsWithContext(ctx context.Context) (*credentia
 		Expiration:      v.Expires,
 		ProviderName:    v.Source,
 	}
+	c.expiresAt = v.Expires
+
+	if p, ok := c.roleProvider.(interface{ AssumedRole() string }); ok {
+		// the role matched by a role pattern is only known to the provider
+		cred.RoleArn = p.AssumedRole()
+	}
 
 	return cred, nil
 }
 
+// checkCachedRoles returns an error if the role isn't in the list of roles from the user's last SAML assertion, so
+// credential retrieval fails before authenticating with the identity provider, and possibly prompting for MFA, when
+// the AssumeRoleWithSAML call is certain to fail.  A missing or outdated role list is not an error.
+func (c *samlRoleClient) checkCachedRoles() error {
+	if c.roleCache == nil || len(c.roleArn) < 1 {
+		return nil
+	}
+
+	roles := c.roleCache.Load(c.roleCacheKey)
+	if roles == nil || time.Since(roles.Updated) > samlRoleListMaxAge {
+		return nil
+	}
+
+	_, err := roles.FindRole(c.roleArn, c.preferredRoles...)
+	if errors.Is(err, credentials.ErrAmbiguousRole) && c.roleSelection != nil {
+		// the role will be selected after authenticating
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w\n(roles from your SAML assertion retrieved at %s, use --force-refresh if access "+
+			"to the role was granted since then)", err, roles.Updated.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// useSharedAssertion gives the SAML client the valid assertion retrieved for the identity provider and user by any
+// client in the process, if the SAML client supports it.
+func (c *samlRoleClient) useSharedAssertion() {
+	s, ok := c.samlClient.(external.SamlAssertionSetter)
+	if !ok {
+		return
+	}
+
+	if saml := sharedSamlAssertion(c.assertionKey); saml != nil {
+		s.SetSamlAssertion(saml)
+	}
+}
+
+// samlRoleCacheKey is the key for the roles of the user at the identity provider in the role cache.
+func samlRoleCacheKey(url, username string) string {
+	return url + " " + username
+}
+
+// ExpiresAt is the implementation of the CredentialClient interface, returning the expiration time of the credentials
+// most recently retrieved by this client.  The zero time is returned if no credentials have been retrieved.
+func (c *samlRoleClient) ExpiresAt() time.Time {
+	return c.expiresAt
+}
+
+// Refresh is the implementation of the CredentialClient interface which clears the cached AWS credentials for this
+// client, and retrieves a new set of credentials.  The SAML assertion is re-used, if it is still valid.
+func (c *samlRoleClient) Refresh(ctx context.Context) (*credentials.Credentials, error) {
+	if err := c.ClearCache(); err != nil {
+		return nil, err
+	}
+	return c.CredentialsWithContext(ctx)
+}
+
 // ConfigProvider returns the AWS SDK aws.Config for this client.
 // AWS SDK v1 terminology retained due to laziness.
 func (c *samlRoleClient) ConfigProvider() aws.Config {
@@ -132,3 +285,30 @@ func (c *samlRoleClient) ClearCache() error {
 
 	return c.roleProvider.ClearCache()
 }
+
+// selectRole calls the role selection provider with the roles matching the role pattern, offering the role last
+// selected for the profile as the default, and remembers the selection in the role cache.
+func (c *samlRoleClient) selectRole(roles []string) (string, error) {
+	var last string
+	var list *credentials.SamlRoleList
+	if c.roleCache != nil {
+		if list = c.roleCache.Load(c.roleCacheKey); list != nil {
+			last = list.Selected[c.profile]
+		}
+	}
+
+	role, err := c.roleSelection(roles, last)
+	if err != nil {
+		return "", err
+	}
+
+	if list != nil && role != last && len(c.profile) > 0 {
+		if list.Selected == nil {
+			list.Selected = make(map[string]string)
+		}
+		list.Selected[c.profile] = role
+		// best effort, failing to remember the selection only loses the default for the next selection
+		_ = c.roleCache.Store(c.roleCacheKey, list)
+	}
+	return role, nil
+}
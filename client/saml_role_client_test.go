@@ -21,7 +21,9 @@ import (
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"github.com/mmmorris1975/aws-runas/identity"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewSamlRoleClient(t *testing.T) {
@@ -94,6 +96,26 @@ func TestSamlRoleClient_Roles(t *testing.T) {
 	})
 }
 
+func TestSamlRoleClient_SamlAssertion(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		c := &samlRoleClient{
+			samlClient:   new(mockSamlClient),
+			roleProvider: new(mockSamlRoleProvider),
+		}
+
+		if _, err := c.SamlAssertionWithContext(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		c := &samlRoleClient{samlClient: &mockSamlClient{true}}
+		if _, err := c.SamlAssertion(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
 func TestSamlRoleClient_Credentials(t *testing.T) {
 	t.Run("good", func(t *testing.T) {
 		c := &samlRoleClient{
@@ -127,6 +149,124 @@ func TestSamlRoleClient_Credentials(t *testing.T) {
 	})
 }
 
+func TestSamlRoleClient_checkCachedRoles(t *testing.T) {
+	key := samlRoleCacheKey("https://idp.example.com/saml", "user")
+	rc := make(mockSamlRoleCache)
+	rc[key] = &credentials.SamlRoleList{Roles: []string{"arn:aws:iam::123456789012:role/Admin"}, Updated: time.Now()}
+
+	tests := []struct {
+		name, role string
+		cache      credentials.SamlRoleCacher
+		updated    time.Time
+		err        bool
+	}{
+		{"in assertion", "arn:aws:iam::123456789012:role/Admin", rc, time.Now(), false},
+		{"pattern match", "arn:aws:iam::*:role/Adm*", rc, time.Now(), false},
+		{"not in assertion", "arn:aws:iam::123456789012:role/Other", rc, time.Now(), true},
+		{"outdated roles", "arn:aws:iam::123456789012:role/Other", rc, time.Now().Add(-24 * time.Hour), false},
+		{"no cache", "arn:aws:iam::123456789012:role/Other", nil, time.Now(), false},
+		{"no role", "", rc, time.Now(), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rc[key].Updated = tc.updated
+			c := &samlRoleClient{roleArn: tc.role, roleCache: tc.cache, roleCacheKey: key}
+
+			if err := c.checkCachedRoles(); (err != nil) != tc.err {
+				t.Errorf("unexpected error result: %v", err)
+			}
+		})
+	}
+
+	t.Run("unknown user", func(t *testing.T) {
+		c := &samlRoleClient{roleArn: "arn:aws:iam::123456789012:role/Other", roleCache: rc,
+			roleCacheKey: samlRoleCacheKey("https://idp.example.com/saml", "other")}
+
+		if err := c.checkCachedRoles(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("credentials fail early", func(t *testing.T) {
+		rc[key].Updated = time.Now()
+		var p mockSamlRoleProvider = true
+		c := &samlRoleClient{
+			samlClient:   new(mockSamlClient),
+			roleProvider: &p,
+			roleArn:      "arn:aws:iam::123456789012:role/Other",
+			roleCache:    rc,
+			roleCacheKey: key,
+		}
+		c.awsCredCache = aws.NewCredentialsCache(c.roleProvider)
+
+		_, err := c.Credentials()
+		if err == nil || !strings.Contains(err.Error(), "not in your SAML assertion") {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+}
+
+func TestSamlRoleClient_selectRole(t *testing.T) {
+	key := samlRoleCacheKey("https://idp.example.com/saml", "user")
+	roles := []string{"arn:aws:iam::123456789012:role/Admin", "arn:aws:iam::210987654321:role/Admin"}
+
+	var offered string
+	c := &samlRoleClient{
+		roleSelection: func(r []string, last string) (string, error) {
+			offered = last
+			return r[1], nil
+		},
+		roleCache:    mockSamlRoleCache{key: &credentials.SamlRoleList{Roles: roles, Updated: time.Now()}},
+		roleCacheKey: key,
+		profile:      "mock",
+	}
+
+	t.Run("first selection", func(t *testing.T) {
+		role, err := c.selectRole(roles)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if role != roles[1] || len(offered) > 0 {
+			t.Error("unexpected selection")
+		}
+
+		if c.roleCache.Load(key).Selected["mock"] != roles[1] {
+			t.Error("selection not remembered")
+		}
+	})
+
+	t.Run("last selection offered", func(t *testing.T) {
+		if _, err := c.selectRole(roles); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if offered != roles[1] {
+			t.Error("last selection not offered")
+		}
+	})
+
+	t.Run("selection error", func(t *testing.T) {
+		c := &samlRoleClient{roleSelection: func([]string, string) (string, error) {
+			return "", errors.New("error")
+		}}
+
+		if _, err := c.selectRole(roles); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("ambiguous cached roles", func(t *testing.T) {
+		c.roleArn = "arn:aws:iam::*:role/Admin"
+		if err := c.checkCachedRoles(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
 func TestSamlRoleClient_ConfigProvider(t *testing.T) {
 	c := &samlRoleClient{session: aws.Config{}}
 	if cp := c.ConfigProvider(); cp.Credentials != c.session.Credentials {
@@ -167,6 +307,14 @@ func (c *mockSamlClient) Roles(...string) (*identity.Roles, error) {
 	return &r, nil
 }
 
+func (c *mockSamlClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return c.Identity()
+}
+
+func (c *mockSamlClient) RolesWithContext(_ context.Context, user ...string) (*identity.Roles, error) {
+	return c.Roles(user...)
+}
+
 // func (c *mockSamlClient) RoleDetails() (*external.RoleDetails, error) {
 //	panic("implement me")
 // }
@@ -186,6 +334,14 @@ func (c *mockSamlClient) SetCookieJar(http.CookieJar) {
 	// return
 }
 
+func (c *mockSamlClient) SetTransport(http.RoundTripper) {
+	// return
+}
+
+func (c *mockSamlClient) SetLoginThrottler(external.LoginThrottler) {
+	// return
+}
+
 func (c *mockSamlClient) SamlAssertion() (*credentials.SamlAssertion, error) {
 	return c.SamlAssertionWithContext(context.Background())
 }
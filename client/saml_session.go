@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/shared"
+)
+
+// samlAssertions holds the last SAML assertion retrieved from each identity provider and user in the process.  The
+// assertion has all of the user's roles, so the clients of every profile using the identity provider (and the Identity,
+// Roles, and Credentials calls of a single client) share one login, and one MFA approval, while the assertion is valid.
+var samlAssertions sync.Map
+
+// sharedSamlAssertion returns the shared SAML assertion for the key, or nil if there is no unexpired assertion.
+func sharedSamlAssertion(key string) *credentials.SamlAssertion {
+	v, ok := samlAssertions.Load(key)
+	if !ok {
+		return nil
+	}
+
+	saml := v.(*credentials.SamlAssertion)
+	if t, err := saml.ExpiresAt(); err != nil || !shared.LocalTime(t).After(time.Now()) {
+		samlAssertions.CompareAndDelete(key, v)
+		return nil
+	}
+	return saml
+}
+
+// storeSamlAssertion makes the SAML assertion available to the other clients using the key.
+func storeSamlAssertion(key string, saml *credentials.SamlAssertion) {
+	if saml != nil && len(*saml) > 0 {
+		samlAssertions.Store(key, saml)
+	}
+}
+
+// forgetSamlAssertions removes all shared SAML assertions, so the next request authenticates with the identity provider.
+func forgetSamlAssertions() {
+	samlAssertions.Clear()
+}
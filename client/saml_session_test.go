@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/mmmorris1975/aws-runas/client/external"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+func TestSamlRoleClient_SharedAssertion(t *testing.T) {
+	defer forgetSamlAssertions()
+
+	newClient := func(key string) (*samlRoleClient, *loginCountSamlClient) {
+		sc := new(loginCountSamlClient)
+		return &samlRoleClient{samlClient: sc, roleProvider: new(mockSamlRoleProvider), roleCacheKey: key,
+			assertionKey: key}, sc
+	}
+
+	t.Run("same identity provider", func(t *testing.T) {
+		forgetSamlAssertions()
+		c1, sc1 := newClient("https://idp.example.com/saml user")
+		c2, sc2 := newClient("https://idp.example.com/saml user")
+
+		for _, c := range []*samlRoleClient{c1, c2, c1} {
+			if _, err := c.SamlAssertionWithContext(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if sc1.logins+sc2.logins != 1 {
+			t.Errorf("unexpected logins: %d %d", sc1.logins, sc2.logins)
+		}
+	})
+
+	t.Run("identity before assertion", func(t *testing.T) {
+		forgetSamlAssertions()
+		c1, _ := newClient("https://idp.example.com/saml user")
+		c2, sc2 := newClient("https://idp.example.com/saml user")
+
+		if _, err := c1.SamlAssertionWithContext(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := c2.IdentityWithContext(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if sc2.saml == nil || sc2.logins != 0 {
+			t.Error("shared assertion not used")
+		}
+	})
+
+	t.Run("different user", func(t *testing.T) {
+		forgetSamlAssertions()
+		c1, _ := newClient("https://idp.example.com/saml user")
+		c2, sc2 := newClient("https://idp.example.com/saml other")
+
+		_, _ = c1.SamlAssertionWithContext(context.Background())
+		_, _ = c2.SamlAssertionWithContext(context.Background())
+
+		if sc2.logins != 1 {
+			t.Errorf("unexpected logins: %d", sc2.logins)
+		}
+	})
+
+	t.Run("different session scope", func(t *testing.T) {
+		forgetSamlAssertions()
+		newScoped := func(scope string) *samlRoleClient {
+			cfg := &SamlRoleClientConfig{
+				AuthenticationClientConfig: external.AuthenticationClientConfig{IdentityProviderName: "okta", Username: "user"},
+				SessionScope:               scope,
+			}
+			return NewSamlRoleClient(aws.Config{}, "https://idp.example.com/saml", cfg)
+		}
+
+		c1, c2 := newScoped("/cache/users/1000"), newScoped("/cache/users/1001")
+		if c1.assertionKey == c2.assertionKey || c1.roleCacheKey != c2.roleCacheKey {
+			t.Errorf("unexpected keys: %s, %s", c1.assertionKey, c2.assertionKey)
+		}
+
+		storeSamlAssertion(c1.assertionKey, testSamlAssertion(time.Now()))
+		if sharedSamlAssertion(c2.assertionKey) != nil {
+			t.Error("assertion shared with a different scope")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		forgetSamlAssertions()
+		storeSamlAssertion("expired", testSamlAssertion(time.Now().Add(-1*time.Hour)))
+		if sharedSamlAssertion("expired") != nil {
+			t.Error("expired assertion was shared")
+		}
+	})
+
+	t.Run("forget", func(t *testing.T) {
+		storeSamlAssertion("forget", testSamlAssertion(time.Now()))
+		forgetSamlAssertions()
+		if sharedSamlAssertion("forget") != nil {
+			t.Error("assertion was not forgotten")
+		}
+	})
+}
+
+// testSamlAssertion returns an encoded SAML assertion issued at the provided time.
+func testSamlAssertion(issued time.Time) *credentials.SamlAssertion {
+	data := fmt.Sprintf(`<saml2:Assertion IssueInstant="%s">`, issued.UTC().Format(time.RFC3339))
+	saml := credentials.SamlAssertion(base64.StdEncoding.EncodeToString([]byte(data)))
+	return &saml
+}
+
+// loginCountSamlClient is a SamlAssertionSetter which counts the logins needed to get the SAML assertions.
+type loginCountSamlClient struct {
+	mockSamlClient
+	saml   *credentials.SamlAssertion
+	logins int
+}
+
+func (c *loginCountSamlClient) SetSamlAssertion(saml *credentials.SamlAssertion) {
+	c.saml = saml
+}
+
+func (c *loginCountSamlClient) SamlAssertionWithContext(context.Context) (*credentials.SamlAssertion, error) {
+	if c.saml == nil {
+		c.logins++
+		c.saml = testSamlAssertion(time.Now())
+	}
+	return c.saml, nil
+}
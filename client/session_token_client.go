@@ -14,6 +14,7 @@
 package client
 
 import (
+	"context"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"github.com/mmmorris1975/aws-runas/shared"
@@ -33,13 +34,15 @@ type SessionTokenClientConfig struct {
 	SerialNumber  string
 	TokenCode     string
 	TokenProvider func() (string, error)
+	// StsRetryer is the retryer for the STS API calls retrieving credentials, the SDK default is used if nil
+	StsRetryer func() aws.Retryer
 }
 
 // NewSessionTokenClient is an AwsClient which knows how to do Get Session Token operations.
 func NewSessionTokenClient(cfg aws.Config, clientCfg *SessionTokenClientConfig) *sessionTokenClient {
 	c := &sessionTokenClient{newBaseIamClient(cfg, clientCfg.Logger), nil}
 
-	p := credentials.NewSessionTokenProvider(cfg)
+	p := credentials.NewSessionTokenProvider(stsConfig(cfg, clientCfg.StsRetryer))
 	p.Cache = clientCfg.Cache
 	p.Duration = clientCfg.Duration
 	p.SerialNumber = clientCfg.SerialNumber
@@ -65,7 +68,11 @@ func (c *sessionTokenClient) ClearCache() error {
 	return nil
 }
 
-// I can't remember why we added this, and it seems to be unhelpful.
-// func (c *sessionTokenClient) ExpiresAt() time.Time {
-//	return time.Time{}
-// }
+// Refresh is the implementation of the CredentialClient interface which clears any cached credentials for this client,
+// and retrieves a new set of credentials.
+func (c *sessionTokenClient) Refresh(ctx context.Context) (*credentials.Credentials, error) {
+	if err := c.ClearCache(); err != nil {
+		return nil, err
+	}
+	return c.CredentialsWithContext(ctx)
+}
@@ -14,9 +14,11 @@
 package client
 
 import (
+	"context"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"testing"
+	"time"
 )
 
 func TestNewSessionTokenClient(t *testing.T) {
@@ -123,6 +125,49 @@ func TestSessionTokenClient_Credentials(t *testing.T) {
 	})
 }
 
+func TestSessionTokenClient_ExpiresAt(t *testing.T) {
+	c := newSessionTokenClient()
+	if !c.ExpiresAt().IsZero() {
+		t.Error("expiration set before retrieving credentials")
+	}
+
+	if _, err := c.Credentials(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if c.ExpiresAt().Before(time.Now()) {
+		t.Error("invalid expiration")
+	}
+}
+
+func TestSessionTokenClient_Refresh(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		c := newSessionTokenClient()
+		c.provider = credentials.NewSessionTokenProvider(aws.Config{})
+
+		creds, err := c.Refresh(context.Background())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if creds.AccessKeyId != "mockAK" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		c := newSessionTokenClient()
+		c.provider = credentials.NewSessionTokenProvider(aws.Config{})
+		c.creds = aws.NewCredentialsCache(&mockCredProvider{sendError: true})
+
+		if _, err := c.Refresh(context.Background()); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
 func TestSessionTokenClient_ConfigProvider(t *testing.T) {
 	c := newSessionTokenClient()
 	c.session = aws.Config{}
@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+const (
+	// StsMaxAttemptsDefault is the default number of attempts made for an STS API call retrieving credentials.
+	StsMaxAttemptsDefault = 5
+	// StsMaxBackoffDefault is the default maximum delay between attempts of an STS API call retrieving credentials.
+	StsMaxBackoffDefault = 20 * time.Second
+)
+
+// stsRetryer returns the retryer for the STS API calls retrieving credentials for the configuration.  Throttling
+// errors, 5xx responses, and identity provider communication errors are retried using exponential backoff with full
+// jitter, up to the sts_max_attempts and sts_max_backoff settings of the configuration.  The retry quota of the SDK's
+// standard retryer is disabled, since the many clients used by batch and multi-account operations would exhaust it
+// during a burst of throttling, which is exactly when retrying is needed.
+func stsRetryer(cfg *config.AwsConfig) func() aws.Retryer {
+	attempts := StsMaxAttemptsDefault
+	if cfg.StsMaxAttempts > 0 {
+		attempts = cfg.StsMaxAttempts
+	}
+
+	backoff := StsMaxBackoffDefault
+	if cfg.StsMaxBackoff > 0 {
+		backoff = cfg.StsMaxBackoff
+	}
+
+	return func() aws.Retryer {
+		return retry.NewStandard(func(o *retry.StandardOptions) {
+			o.MaxAttempts = attempts
+			o.MaxBackoff = backoff
+			o.RateLimiter = ratelimit.None
+			o.Retryables = append(o.Retryables, retry.RetryableErrorCode{
+				Codes: map[string]struct{}{"IDPCommunicationError": {}},
+			})
+		})
+	}
+}
+
+// stsConfig returns a copy of the AWS configuration for the STS client retrieving credentials, using the retryer (if
+// not nil) and logging retry attempts.
+func stsConfig(cfg aws.Config, retryer func() aws.Retryer) aws.Config {
+	if retryer != nil {
+		cfg.Retryer = retryer
+		cfg.ClientLogMode |= aws.LogRetries
+	}
+	return cfg
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+func TestStsRetryer(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		r := stsRetryer(new(config.AwsConfig))()
+		if r.MaxAttempts() != StsMaxAttemptsDefault {
+			t.Errorf("unexpected max attempts: %d", r.MaxAttempts())
+		}
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		r := stsRetryer(&config.AwsConfig{StsMaxAttempts: 10, StsMaxBackoff: 2 * time.Second})()
+		if r.MaxAttempts() != 10 {
+			t.Errorf("unexpected max attempts: %d", r.MaxAttempts())
+		}
+
+		for i := 1; i < 10; i++ {
+			if d, err := r.RetryDelay(i, &smithy.GenericAPIError{Code: "Throttling"}); err != nil || d > 2*time.Second {
+				t.Errorf("unexpected retry delay %s: %v", d, err)
+			}
+		}
+	})
+
+	t.Run("retryable errors", func(t *testing.T) {
+		r := stsRetryer(new(config.AwsConfig))()
+
+		for _, code := range []string{"Throttling", "ThrottlingException", "IDPCommunicationError"} {
+			if !r.IsErrorRetryable(&smithy.GenericAPIError{Code: code}) {
+				t.Errorf("%s is not retryable", code)
+			}
+		}
+
+		if r.IsErrorRetryable(&smithy.GenericAPIError{Code: "AccessDenied"}) {
+			t.Error("AccessDenied is retryable")
+		}
+	})
+}
+
+func TestStsConfig(t *testing.T) {
+	t.Run("nil retryer", func(t *testing.T) {
+		cfg := stsConfig(aws.Config{Region: "us-east-1"}, nil)
+		if cfg.Retryer != nil || cfg.ClientLogMode != 0 {
+			t.Error("configuration was modified")
+		}
+	})
+
+	t.Run("retryer", func(t *testing.T) {
+		orig := aws.Config{Region: "us-east-1"}
+		cfg := stsConfig(orig, stsRetryer(new(config.AwsConfig)))
+		if cfg.Retryer == nil || !cfg.ClientLogMode.IsRetries() {
+			t.Error("retryer not configured")
+		}
+
+		if orig.Retryer != nil {
+			t.Error("original configuration was modified")
+		}
+	})
+
+	t.Run("throttled call", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/xml")
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>Throttling</Code>` +
+					`<Message>Rate exceeded</Message></Error><RequestId>x</RequestId></ErrorResponse>`))
+				return
+			}
+			_, _ = w.Write([]byte(`<GetCallerIdentityResponse><GetCallerIdentityResult>` +
+				`<Account>123456789012</Account><Arn>arn:aws:iam::123456789012:user/mock</Arn><UserId>AIDAMOCK</UserId>` +
+				`</GetCallerIdentityResult></GetCallerIdentityResponse>`))
+		}))
+		defer srv.Close()
+
+		awsCfg := aws.Config{
+			Region:       "us-east-1",
+			BaseEndpoint: aws.String(srv.URL),
+			Credentials:  aws.AnonymousCredentials{},
+		}
+		retryer := stsRetryer(&config.AwsConfig{StsMaxAttempts: 3, StsMaxBackoff: time.Millisecond})
+
+		out, err := sts.NewFromConfig(stsConfig(awsCfg, retryer)).GetCallerIdentity(context.Background(), new(sts.GetCallerIdentityInput))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if calls.Load() != 3 || aws.ToString(out.Account) != "123456789012" {
+			t.Errorf("unexpected result after %d calls", calls.Load())
+		}
+	})
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+// tokenFileCredentialCache is the credential cache for credentials derived from the identity token in a web identity
+// token file.  The file is rotated by another process (like the projected service account token used by IRSA), and the
+// new token may be issued for a different audience or subject, so the cached credentials are only used if they were
+// retrieved while the current token was in the file.  The checksum of that token is kept in a state file.
+type tokenFileCredentialCache struct {
+	credentials.CredentialCacher
+	tokenFile string
+	stateFile string
+	mu        sync.Mutex
+	sum       string
+}
+
+func newTokenFileCredentialCache(c credentials.CredentialCacher, tokenFile, stateFile string) *tokenFileCredentialCache {
+	return &tokenFileCredentialCache{CredentialCacher: c, tokenFile: tokenFile, stateFile: stateFile}
+}
+
+// Load returns an expired set of credentials if the token in the file is not the one used to retrieve the cached
+// credentials, otherwise the credentials are loaded from the wrapped cache.
+func (c *tokenFileCredentialCache) Load() *credentials.Credentials {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sum = tokenFileSum(c.tokenFile)
+	if state, err := os.ReadFile(c.stateFile); err != nil || len(c.sum) < 1 || string(state) != c.sum {
+		return new(credentials.Credentials)
+	}
+	return c.CredentialCacher.Load()
+}
+
+// Store saves the credentials in the wrapped cache, and records the token in the file as the token they belong to.
+func (c *tokenFileCredentialCache) Store(creds *credentials.Credentials) error {
+	if err := c.CredentialCacher.Store(creds); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.sum) < 1 {
+		c.sum = tokenFileSum(c.tokenFile)
+	}
+	return os.WriteFile(c.stateFile, []byte(c.sum), 0600)
+}
+
+// Clear removes the credentials from the wrapped cache, and the record of the token they belong to.
+func (c *tokenFileCredentialCache) Clear() error {
+	err := os.Remove(c.stateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return errors.Join(c.CredentialCacher.Clear(), err)
+}
+
+// tokenFileSum returns the checksum of the contents of the token file, or an empty string if it can't be read.
+func tokenFileSum(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return tokenSum(data)
+}
+
+// tokenSum returns the checksum of an identity token.
+func tokenSum(tok []byte) string {
+	sum := sha256.Sum256(tok)
+	return hex.EncodeToString(sum[:])
+}
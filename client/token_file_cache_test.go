@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/shared"
+)
+
+func TestTokenFileCredentialCache(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	stateFile := filepath.Join(dir, "state")
+
+	writeToken := func(tok string) {
+		if err := os.WriteFile(tokenFile, []byte(tok), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	creds := &credentials.Credentials{AccessKeyId: "AK", SecretAccessKey: "SK", Expiration: time.Now().Add(time.Hour)}
+	c := newTokenFileCredentialCache(new(memCredCache), tokenFile, stateFile)
+
+	t.Run("no state", func(t *testing.T) {
+		writeToken("token1")
+		if c.Load().Value().HasKeys() {
+			t.Error("loaded credentials without token state")
+		}
+	})
+
+	t.Run("same token", func(t *testing.T) {
+		if err := c.Store(creds); err != nil {
+			t.Fatal(err)
+		}
+
+		if v := c.Load(); v.AccessKeyId != "AK" {
+			t.Errorf("unexpected credentials: %+v", v)
+		}
+	})
+
+	t.Run("rotated token", func(t *testing.T) {
+		writeToken("token2")
+		if c.Load().Value().HasKeys() {
+			t.Error("loaded credentials for rotated token")
+		}
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		if err := c.Store(creds); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Clear(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := os.Stat(stateFile); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("state file not removed: %v", err)
+		}
+
+		if err := c.Clear(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestWebRoleClient_TokenFileRotation(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	writeToken := func(tok string) {
+		if err := os.WriteFile(tokenFile, []byte(tok), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := new(tokenRecordingProvider)
+	c := &webRoleClient{
+		webClient:    new(mockWebClient),
+		roleProvider: p,
+		tokenFile:    tokenFile,
+		logger:       new(shared.DefaultLogger),
+	}
+	c.awsCredCache = aws.NewCredentialsCache(c.roleProvider)
+
+	writeToken("token1")
+	for i := 0; i < 2; i++ {
+		creds, err := c.Credentials()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if creds.AccessKeyId != "token1" || p.retrieved != 1 {
+			t.Errorf("unexpected credentials: %s (%d retrieved)", creds.AccessKeyId, p.retrieved)
+		}
+	}
+
+	writeToken("token2")
+	creds, err := c.Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if creds.AccessKeyId != "token2" || p.retrieved != 2 {
+		t.Errorf("credentials not refreshed after token rotation: %s (%d retrieved)", creds.AccessKeyId, p.retrieved)
+	}
+}
+
+// tokenRecordingProvider returns credentials using the identity token as the access key.
+type tokenRecordingProvider struct {
+	tok       *credentials.OidcIdentityToken
+	retrieved int
+}
+
+func (p *tokenRecordingProvider) Retrieve(context.Context) (aws.Credentials, error) {
+	if len(p.tok.String()) < 1 {
+		return aws.Credentials{}, errors.New("missing token")
+	}
+
+	p.retrieved++
+	return aws.Credentials{
+		AccessKeyID:     p.tok.String(),
+		SecretAccessKey: "mockSK",
+		CanExpire:       true,
+		Expires:         time.Now().Add(time.Hour),
+	}, nil
+}
+
+func (p *tokenRecordingProvider) WebIdentityToken(tok *credentials.OidcIdentityToken) {
+	p.tok = tok
+}
+
+func (p *tokenRecordingProvider) ClearCache() error {
+	return nil
+}
@@ -22,7 +22,9 @@ import (
 	"github.com/mmmorris1975/aws-runas/credentials/helpers"
 	"github.com/mmmorris1975/aws-runas/identity"
 	"github.com/mmmorris1975/aws-runas/shared"
+	"net/http"
 	"os"
+	"time"
 )
 
 var (
@@ -32,6 +34,8 @@ var (
 		EnableCache:             true,
 		MfaInputProvider:        helpers.NewMfaTokenProvider(os.Stdin).ReadInput,
 		CredentialInputProvider: helpers.NewUserPasswordInputProvider(os.Stdin).ReadInput,
+		PasswordChangeProvider:  helpers.NewPasswordChangeInputProvider(os.Stdin).ReadInput,
+		RoleSelectionProvider:   helpers.NewRoleSelectionInputProvider(os.Stdin).ReadInput,
 		Logger:                  new(shared.DefaultLogger),
 		AwsLogLevel:             logging.Warn,
 		CommandCredentials:      new(config.AwsCredentials),
@@ -45,13 +49,24 @@ type CredentialClient interface {
 	CredentialsWithContext(ctx context.Context) (*credentials.Credentials, error)
 	ConfigProvider() aws.Config
 	ClearCache() error
+	ExpiresAt() time.Time
+	Refresh(ctx context.Context) (*credentials.Credentials, error)
 }
 
 // IdentityClient defines the methods for implementations which retrieve caller identity information for AWS IAM users,
 // or identities managed by an external identity source.
 type IdentityClient interface {
 	Identity() (*identity.Identity, error)
+	IdentityWithContext(ctx context.Context) (*identity.Identity, error)
 	Roles() (*identity.Roles, error)
+	RolesWithContext(ctx context.Context) (*identity.Roles, error)
+}
+
+// SamlAssertionClient defines the method for implementations which are able to provide the SAML assertion obtained
+// from an external identity source.
+type SamlAssertionClient interface {
+	SamlAssertion() (*credentials.SamlAssertion, error)
+	SamlAssertionWithContext(ctx context.Context) (*credentials.SamlAssertion, error)
 }
 
 // AwsClient is a super-interface which combines the functions of the CredentialClient and IdentityClient to provide a
@@ -70,4 +85,37 @@ type Options struct {
 	Logger                  shared.Logger
 	AwsLogLevel             logging.Classification
 	CommandCredentials      *config.AwsCredentials
+	// Transport is an optional http.RoundTripper used for all HTTP requests made to AWS and external identity
+	// providers.  If nil, the AWS SDK default transport is used for AWS requests, and shared.DefaultTransport() for
+	// requests to external identity providers.
+	Transport http.RoundTripper
+	// Hooks are optional callbacks which are called as the state of the client's credentials changes.
+	Hooks *Hooks
+	// MemoryCache keeps the credentials from the file-backed credential caches in memory, re-reading a file only when
+	// it changes.  Intended for long-running processes which get credentials often, like the metadata service.
+	MemoryCache bool
+	// CacheKeyScope is prepended to the keys of the credentials stored by a cache backend (like redis or dynamodb), so
+	// clients with a different scope never share cached credentials.  Used to isolate the users of a multi-user
+	// metadata service, it has no effect on the file cache.
+	CacheKeyScope string
+	// Offline forbids network requests, clients only provide the credentials found in the local cache.
+	Offline bool
+	// ForceRefresh ignores the cached credentials, identity tokens and identity provider cookies the first time they're
+	// used by a client, so new credentials are always fetched.  The cached state is replaced, not removed.
+	ForceRefresh bool
+	// AuditLog is the path of a file which gets a JSON record appended each time a client provides credentials.  No
+	// audit records are written if this is empty.
+	AuditLog string
+	// PasswordChangeProvider gets a new password when the identity provider requires an expired password to be
+	// changed.  If nil, the expired password is reported as an error.  The password stored in the credentials file,
+	// if any, is updated after the change.
+	PasswordChangeProvider func() (string, error)
+	// RoleSelectionProvider picks one of the roles when a SAML profile's role pattern matches multiple roles, and no
+	// preferred role resolves it.  The role last selected for the profile is provided as the default.  If nil, the
+	// pattern matching multiple roles is reported as an error.
+	RoleSelectionProvider func(roles []string, last string) (string, error)
+	// IdentityDetails adds the AWS caller ARN, account ID, and organization ID (if the credentials are allowed to
+	// describe the organization) to the identity returned by the client.  The details are looked up using the client's
+	// credentials when the identity is requested.  Not supported with the Offline option.
+	IdentityDetails bool
 }
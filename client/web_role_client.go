@@ -23,12 +23,13 @@ import (
 	"github.com/mmmorris1975/aws-runas/identity"
 	"github.com/mmmorris1975/aws-runas/shared"
 	"os"
-	"path/filepath"
 	"time"
 )
 
-// singleton Web (OIDC) Identity Token cache implementation.
-var tokenCache = cache.WebIdentityCache(filepath.Join(cachePath(), ".aws_runas_identity_token.cache"))
+// tokenValidator checks an identity token before it is sent to AWS.
+type tokenValidator interface {
+	ValidateWithContext(ctx context.Context, tok *credentials.OidcIdentityToken) error
+}
 
 type webRoleClient struct {
 	webClient    external.WebIdentityClient
@@ -36,18 +37,28 @@ type webRoleClient struct {
 	awsCredCache *aws.CredentialsCache
 	idpUrl       string
 	tokenFile    string
+	tokenSum     string // checksum of the token from the token file given to the role provider
+	tokenCache   credentials.IdentityTokenCacher
+	validator    tokenValidator
 	session      aws.Config
 	logger       shared.Logger
+	expiresAt    time.Time
 }
 
 // WebRoleClientConfig is the means to specify the configuration for the Assume Role with Web Identity operation.
 // This includes information necessary to communicate with the external IdP, as well as the configuration for the AWS API calls.
 type WebRoleClientConfig struct {
 	external.OidcClientConfig
-	Cache                credentials.CredentialCacher
+	Cache      credentials.CredentialCacher
+	TokenCache credentials.IdentityTokenCacher
+	// OidcProviderCache stores the identity provider discovery and signing key data used to validate identity
+	// tokens before they are sent to AWS, the data is fetched for every new token if nil
+	OidcProviderCache    credentials.OidcProviderCacher
 	Duration             time.Duration
 	RoleArn              string
 	WebIdentityTokenFile string
+	// StsRetryer is the retryer for the STS API calls retrieving credentials, the SDK default is used if nil
+	StsRetryer func() aws.Retryer
 }
 
 // NewWebRoleClient returns a new SAML aware AwsClient for obtaining identity information from the external IdP, and
@@ -57,6 +68,7 @@ func NewWebRoleClient(cfg aws.Config, url string, clientCfg *WebRoleClientConfig
 	c.webClient = external.MustGetWebIdentityClient(clientCfg.IdentityProviderName, url, clientCfg.OidcClientConfig)
 	c.idpUrl = url
 	c.tokenFile = clientCfg.WebIdentityTokenFile
+	c.tokenCache = clientCfg.TokenCache
 	c.session = cfg
 
 	c.logger = new(shared.DefaultLogger)
@@ -64,7 +76,17 @@ func NewWebRoleClient(cfg aws.Config, url string, clientCfg *WebRoleClientConfig
 		c.logger = clientCfg.Logger
 	}
 
-	p := credentials.NewWebRoleProvider(cfg, clientCfg.RoleArn)
+	// a token exchange may issue the token for a requested audience, instead of the client ID
+	aud := clientCfg.ClientId
+	if len(clientCfg.Audience) > 0 {
+		aud = clientCfg.Audience
+	}
+
+	v := external.NewTokenValidator(url, aud, clientCfg.OidcProviderCache)
+	v.Logger = c.logger
+	c.validator = v
+
+	p := credentials.NewWebRoleProvider(stsConfig(cfg, clientCfg.StsRetryer), clientCfg.RoleArn)
 	p.Duration = clientCfg.Duration
 	p.Cache = clientCfg.Cache
 	p.Logger = clientCfg.Logger
@@ -86,15 +108,26 @@ func NewWebRoleClient(cfg aws.Config, url string, clientCfg *WebRoleClientConfig
 	return c
 }
 
-// Identity is the implementation of the IdentityClient interface for retrieving identity information from the external IdP.
+// Identity is the implementation of the IdentityClient interface, and calls IdentityWithContext with a background context.
 func (c *webRoleClient) Identity() (*identity.Identity, error) {
-	return c.webClient.Identity()
+	return c.IdentityWithContext(context.Background())
+}
+
+// IdentityWithContext is the implementation of the IdentityClient interface for retrieving identity information from
+// the external IdP.
+func (c *webRoleClient) IdentityWithContext(ctx context.Context) (*identity.Identity, error) {
+	return c.webClient.IdentityWithContext(ctx)
 }
 
-// Roles is the implementation of the IdentityClient interface for retrieving IAM role information from the external IdP
-// Web Identity providers are not role aware, so this method will always return an error for this client type.
+// Roles is the implementation of the IdentityClient interface, and calls RolesWithContext with a background context.
 func (c *webRoleClient) Roles() (*identity.Roles, error) {
-	return c.webClient.Roles()
+	return c.RolesWithContext(context.Background())
+}
+
+// RolesWithContext is the implementation of the IdentityClient interface for retrieving IAM role information from the
+// external IdP. Web Identity providers are not role aware, so this method will always return an error for this client type.
+func (c *webRoleClient) RolesWithContext(ctx context.Context) (*identity.Roles, error) {
+	return c.webClient.RolesWithContext(ctx)
 }
 
 // Credentials is the implementation of the CredentialClient interface, and calls CredentialsWithContext with a
@@ -106,25 +139,34 @@ func (c *webRoleClient) Credentials() (*credentials.Credentials, error) {
 // CredentialsWithContext is the implementation of the CredentialClient interface for retrieving temporary AWS
 // credentials using the Assume Role with Web Identity operation.
 func (c *webRoleClient) CredentialsWithContext(ctx context.Context) (*credentials.Credentials, error) {
+	// credentials derived from a token which has since been rotated out of the token file are not used
+	if c.tokenRotated() {
+		c.logger.Debugf("web identity token file %s has changed, refreshing credentials", c.tokenFile)
+		c.awsCredCache.Invalidate()
+		if err := c.setToken(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	// check if we can retrieve valid credentials from cache, assume any error means
 	// we should re-fetch credentials from the IdP and AWS
 	v, err := c.awsCredCache.Retrieve(ctx)
 	if err != nil {
-		var tok []byte
-		tok, err = c.FetchToken(ctx)
-		if err != nil {
+		if err = c.setToken(ctx); err != nil {
 			return nil, err
 		}
 
-		tt := credentials.OidcIdentityToken(tok)
-		c.roleProvider.WebIdentityToken(&tt)
-
 		v, err = c.awsCredCache.Retrieve(ctx)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	if len(c.tokenFile) > 0 && len(c.tokenSum) < 1 {
+		// the cached credentials were retrieved with the token currently in the file
+		c.tokenSum = tokenFileSum(c.tokenFile)
+	}
+
 	cred := &credentials.Credentials{
 		AccessKeyId:     v.AccessKeyID,
 		SecretAccessKey: v.SecretAccessKey,
@@ -132,10 +174,61 @@ func (c *webRoleClient) CredentialsWithContext(ctx context.Context) (*credential
 		Expiration:      v.Expires,
 		ProviderName:    v.Source,
 	}
+	c.expiresAt = v.Expires
 
 	return cred, nil
 }
 
+// setToken fetches the identity token, and gives it to the role provider for the Assume Role with Web Identity operation.
+func (c *webRoleClient) setToken(ctx context.Context) error {
+	tok, err := c.FetchToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	tt := credentials.OidcIdentityToken(tok)
+	if err = c.validateToken(ctx, &tt); err != nil {
+		return err
+	}
+	c.roleProvider.WebIdentityToken(&tt)
+
+	if len(c.tokenFile) > 0 {
+		c.tokenSum = tokenSum(tok)
+	}
+	return nil
+}
+
+// tokenRotated returns true if the token in the web identity token file is not the token given to the role provider.
+// The file is checked every time credentials are requested, so long-running clients pick up the rotated token.
+func (c *webRoleClient) tokenRotated() bool {
+	if len(c.tokenFile) < 1 || len(c.tokenSum) < 1 {
+		return false
+	}
+
+	sum := tokenFileSum(c.tokenFile)
+	return len(sum) > 0 && sum != c.tokenSum
+}
+
+// ExpiresAt is the implementation of the CredentialClient interface, returning the expiration time of the credentials
+// most recently retrieved by this client.  The zero time is returned if no credentials have been retrieved.
+func (c *webRoleClient) ExpiresAt() time.Time {
+	return c.expiresAt
+}
+
+// Refresh is the implementation of the CredentialClient interface which clears the cached AWS credentials for this
+// client, and retrieves a new set of credentials.  Unlike ClearCache, the cached web identity token is retained, so
+// re-authentication with the IdP is only performed if the token has expired.
+func (c *webRoleClient) Refresh(ctx context.Context) (*credentials.Credentials, error) {
+	if c.awsCredCache != nil {
+		c.awsCredCache.Invalidate()
+	}
+
+	if err := c.roleProvider.ClearCache(); err != nil {
+		return nil, err
+	}
+	return c.CredentialsWithContext(ctx)
+}
+
 // FetchToken is the implementation of the AWS TokenFetch interface for retrieving Web (OIDC) Identity tokens.  If
 // configured, this implementation will consult a Web Identity Token file.  Otherwise, if caching is enabled, it will
 // be checked.  If no cache is configured or the token retrieved from cache is expired, a new token will be retrieved
@@ -150,17 +243,19 @@ func (c *webRoleClient) FetchToken(ctx context.Context) ([]byte, error) {
 
 	var err error
 
-	tok := tokenCache.Load(c.idpUrl)
+	tok := c.identityTokenCache().Load(c.idpUrl)
 	if tok != nil && !tok.IsExpired() {
 		return []byte(tok.String()), nil
 	}
 
+	ctx, span := shared.StartSpan(ctx, "idp.IdentityToken")
 	tok, err = c.webClient.IdentityTokenWithContext(ctx)
+	shared.EndSpan(span, err)
 	if err != nil {
 		return nil, err
 	}
 
-	err = tokenCache.Store(c.idpUrl, tok)
+	err = c.identityTokenCache().Store(c.idpUrl, tok)
 	if err != nil {
 		// non-fatal ... just won't have a cached token
 		c.logger.Debugf("error writing to token cache: %v", err)
@@ -169,6 +264,20 @@ func (c *webRoleClient) FetchToken(ctx context.Context) ([]byte, error) {
 	return []byte(tok.String()), nil
 }
 
+// validateToken checks the identity token locally before it is used with the Assume Role with Web Identity operation,
+// so an invalid token fails with a specific reason, instead of the generic InvalidIdentityToken error from AWS.
+// Tokens read from a Web Identity token file are not validated, since they are not issued by the configured IdP.
+func (c *webRoleClient) validateToken(ctx context.Context, tok *credentials.OidcIdentityToken) error {
+	if len(c.tokenFile) > 0 || c.validator == nil {
+		return nil
+	}
+
+	ctx, span := shared.StartSpan(ctx, "idp.ValidateToken")
+	err := c.validator.ValidateWithContext(ctx, tok)
+	shared.EndSpan(span, err)
+	return err
+}
+
 // ConfigProvider returns the AWS SDK client.ConfigProvider for this client.
 func (c *webRoleClient) ConfigProvider() aws.Config {
 	return c.session
@@ -181,7 +290,7 @@ func (c *webRoleClient) ClearCache() error {
 		c.awsCredCache.Invalidate()
 	}
 
-	e1 := tokenCache.Clear()
+	e1 := c.identityTokenCache().Clear()
 	e2 := c.roleProvider.ClearCache()
 
 	if e1 != nil {
@@ -193,3 +302,12 @@ func (c *webRoleClient) ClearCache() error {
 	}
 	return nil
 }
+
+// identityTokenCache returns the cache for the client's identity token, falling back to the default token cache if one
+// was not provided in the client configuration.
+func (c *webRoleClient) identityTokenCache() credentials.IdentityTokenCacher {
+	if c.tokenCache == nil {
+		c.tokenCache = cache.WebIdentityCache(cacheFilePath(nil, tokenCacheFile))
+	}
+	return c.tokenCache
+}
@@ -16,6 +16,7 @@ package client
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/mmmorris1975/aws-runas/client/external"
 	"github.com/mmmorris1975/aws-runas/credentials"
@@ -133,6 +134,30 @@ func TestWebRoleClient_Credentials(t *testing.T) {
 			t.Error("did not receive expected error")
 		}
 	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		var p mockWebRoleProvider = true
+		c := &webRoleClient{
+			webClient:    new(mockWebClient),
+			roleProvider: &p,
+			tokenCache:   make(mockTokenCache),
+			logger:       new(shared.DefaultLogger),
+			validator: mockTokenValidator(func(*credentials.OidcIdentityToken) error {
+				return fmt.Errorf("%w: token expired", credentials.ErrInvalidIdentityToken)
+			}),
+		}
+		c.awsCredCache = aws.NewCredentialsCache(c.roleProvider)
+
+		if _, err := c.Credentials(); !errors.Is(err, credentials.ErrInvalidIdentityToken) {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+}
+
+type mockTokenValidator func(tok *credentials.OidcIdentityToken) error
+
+func (v mockTokenValidator) ValidateWithContext(_ context.Context, tok *credentials.OidcIdentityToken) error {
+	return v(tok)
 }
 
 func TestWebRoleClient_ConfigProvider(t *testing.T) {
@@ -151,6 +176,41 @@ func TestWebRoleClient_ClearCache(t *testing.T) {
 	}
 }
 
+func TestWebRoleClient_Refresh(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		c := &webRoleClient{
+			webClient:    new(mockWebClient),
+			roleProvider: new(mockWebRoleProvider),
+			logger:       new(shared.DefaultLogger),
+		}
+		c.awsCredCache = aws.NewCredentialsCache(c.roleProvider)
+
+		creds, err := c.Refresh(context.Background())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if !creds.Value().HasKeys() {
+			t.Error("invalid credentials")
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var p mockWebRoleProvider = true
+		c := &webRoleClient{
+			webClient:    new(mockWebClient),
+			roleProvider: &p,
+			tokenFile:    "i am not a real file",
+		}
+		c.awsCredCache = aws.NewCredentialsCache(c.roleProvider)
+
+		if _, err := c.Refresh(context.Background()); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
 type mockWebClient struct {
 	sendError bool
 }
@@ -176,6 +236,14 @@ func (c *mockWebClient) Roles(...string) (*identity.Roles, error) {
 	return &r, nil
 }
 
+func (c *mockWebClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return c.Identity()
+}
+
+func (c *mockWebClient) RolesWithContext(_ context.Context, user ...string) (*identity.Roles, error) {
+	return c.Roles(user...)
+}
+
 func (c *mockWebClient) Authenticate() error {
 	return c.AuthenticateWithContext(context.Background())
 }
@@ -191,6 +259,14 @@ func (c *mockWebClient) SetCookieJar(http.CookieJar) {
 	// return
 }
 
+func (c *mockWebClient) SetTransport(http.RoundTripper) {
+	// return
+}
+
+func (c *mockWebClient) SetLoginThrottler(external.LoginThrottler) {
+	// return
+}
+
 func (c *mockWebClient) IdentityToken() (*credentials.OidcIdentityToken, error) {
 	return c.IdentityTokenWithContext(context.Background())
 }
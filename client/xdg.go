@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+// appDirName is the name of the directory created under the platform cache and state directories.
+const appDirName = "aws-runas"
+
+// userCacheDir returns the platform-specific directory for cache files. This honors XDG_CACHE_HOME on Unix-like systems,
+// and uses ~/Library/Caches on macOS, and %LocalAppData% on Windows.
+func userCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appDirName), nil
+}
+
+// userStateDir returns the platform-specific directory for state files. This honors XDG_STATE_HOME (defaulting to
+// ~/.local/state) on Unix-like systems, and uses ~/Library/Application Support on macOS, and %LocalAppData% on Windows.
+func userStateDir() (string, error) {
+	var dir string
+
+	switch runtime.GOOS {
+	case "windows":
+		dir = os.Getenv("LocalAppData")
+	case "darwin", "ios":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, "Library", "Application Support")
+	default:
+		dir = os.Getenv("XDG_STATE_HOME")
+		if !filepath.IsAbs(dir) {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			dir = filepath.Join(home, ".local", "state")
+		}
+	}
+
+	if len(dir) < 1 {
+		return "", errors.New("unable to determine user state directory")
+	}
+	return filepath.Join(dir, appDirName), nil
+}
+
+// cacheFilePath returns the location of the named cache file.
+func cacheFilePath(cfg *config.AwsConfig, name string) string {
+	return resolveFile(cfg, name, userCacheDir)
+}
+
+// stateFilePath returns the location of the named state file.
+func stateFilePath(cfg *config.AwsConfig, name string) string {
+	return resolveFile(cfg, name, userStateDir)
+}
+
+// StateFile returns the location of the named state file, for the state kept by other packages, like the active
+// profile of the metadata credential service.
+func StateFile(cfg *config.AwsConfig, name string) string {
+	return stateFilePath(cfg, name)
+}
+
+// resolveFile determines the location of the named file.  An explicitly configured cache directory always wins.  Next,
+// a file of the same name in the AWS configuration directory (where older versions of aws-runas kept these files) is
+// used if it exists.  Otherwise, the file is located in the platform directory returned by userDir, falling back to the
+// AWS configuration directory if the platform directory is unavailable.
+func resolveFile(cfg *config.AwsConfig, name string, userDir func() (string, error)) string {
+	if hasCacheDir(cfg) {
+		return filepath.Join(cacheDir(cfg), name)
+	}
+
+	legacy := filepath.Join(cachePath(), name)
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy
+	}
+
+	dir, err := userDir()
+	if err != nil {
+		return legacy
+	}
+
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return legacy
+	}
+
+	// the files live in a dedicated directory, so there's no reason to hide them
+	return filepath.Join(dir, strings.TrimPrefix(name, "."))
+}
+
+// hasCacheDir reports whether a cache directory has been explicitly configured.
+func hasCacheDir(cfg *config.AwsConfig) bool {
+	if cfg != nil && len(cfg.CacheDir) > 0 {
+		return true
+	}
+	return len(os.Getenv("AWS_RUNAS_CACHE_DIR")) > 0
+}
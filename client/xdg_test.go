@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+func TestResolveFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG directories only apply to linux")
+	}
+
+	setup := func(t *testing.T) (string, string) {
+		t.Helper()
+		cfgDir := t.TempDir()
+		xdgDir := t.TempDir()
+		t.Setenv("AWS_RUNAS_CACHE_DIR", "")
+		t.Setenv("AWS_CONFIG_FILE", filepath.Join(cfgDir, "config"))
+		t.Setenv("XDG_CACHE_HOME", xdgDir)
+		t.Setenv("XDG_STATE_HOME", xdgDir)
+		return cfgDir, xdgDir
+	}
+
+	t.Run("xdg cache", func(t *testing.T) {
+		_, xdgDir := setup(t)
+
+		expected := filepath.Join(xdgDir, appDirName, "aws_runas.cookies")
+		if f := cacheFilePath(nil, cookieJarFile); f != expected {
+			t.Errorf("unexpected file path: %s", f)
+		}
+	})
+
+	t.Run("xdg state", func(t *testing.T) {
+		_, xdgDir := setup(t)
+
+		expected := filepath.Join(xdgDir, appDirName, "aws_runas_login.state")
+		if f := stateFilePath(nil, loginThrottleFile); f != expected {
+			t.Errorf("unexpected file path: %s", f)
+		}
+	})
+
+	t.Run("legacy file", func(t *testing.T) {
+		cfgDir, _ := setup(t)
+
+		legacy := filepath.Join(cfgDir, cookieJarFile)
+		if err := os.WriteFile(legacy, []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if f := cacheFilePath(nil, cookieJarFile); f != legacy {
+			t.Errorf("unexpected file path: %s", f)
+		}
+	})
+
+	t.Run("cache dir", func(t *testing.T) {
+		setup(t)
+		dir := t.TempDir()
+
+		expected := filepath.Join(dir, loginThrottleFile)
+		if f := stateFilePath(&config.AwsConfig{CacheDir: dir}, loginThrottleFile); f != expected {
+			t.Errorf("unexpected file path: %s", f)
+		}
+	})
+}
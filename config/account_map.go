@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/ini.v1"
+)
+
+// LoadAccountMap reads the AWS account aliases from the file at path, and returns a map of account ID to alias.  The
+// file uses ini-style 'account_id = alias' lines, which don't belong to a section.
+func LoadAccountMap(path string) (map[string]string, error) {
+	f, err := ini.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := make(map[string]string)
+	for _, k := range f.Section(ini.DefaultSection).Keys() {
+		if !accountIdRe.MatchString(k.Name()) {
+			return nil, fmt.Errorf("invalid AWS account ID '%s' in account map file %s", k.Name(), path)
+		}
+
+		if v := k.String(); len(v) > 0 {
+			aliases[k.Name()] = v
+		}
+	}
+	return aliases, nil
+}
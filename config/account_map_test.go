@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAccountMap(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "accounts")
+		data := "# account aliases\n123456789012 = prod\n210987654321 = dev-account\n"
+		if err := os.WriteFile(f, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := LoadAccountMap(f)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(m) != 2 || m["123456789012"] != "prod" || m["210987654321"] != "dev-account" {
+			t.Errorf("unexpected account map: %v", m)
+		}
+	})
+
+	t.Run("bad account id", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "accounts")
+		if err := os.WriteFile(f, []byte("prod = 123456789012\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := LoadAccountMap(f); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := LoadAccountMap(filepath.Join(t.TempDir(), "missing")); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
@@ -15,10 +15,58 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"net/url"
+	"regexp"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/shared"
+)
+
+var accountIdRe = regexp.MustCompile(`^\d{12}$`)
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Supported values for the CacheBackend configuration attribute.
+const (
+	CacheBackendFile     = "file"
+	CacheBackendRedis    = "redis"
+	CacheBackendDynamoDb = "dynamodb"
+)
+
+// Values returned by AwsConfig.ProfileType.
+const (
+	ProfileTypeIam     = "iam"
+	ProfileTypeSession = "session"
+	ProfileTypeRole    = "role"
+	ProfileTypeSaml    = "saml"
+	ProfileTypeOidc    = "oidc"
+	ProfileTypeSso     = "sso"
+)
+
+// DefaultSamlRpId is the identifier of the AWS relying party in identity providers like ADFS, used with a SAML relay
+// state if the SamlRpId configuration attribute is not set.
+const DefaultSamlRpId = "urn:amazon:webservices"
+
+// Supported values for the RoleMfaMode configuration attribute.
+const (
+	// RoleMfaModeSession performs MFA when getting session token credentials, which are used to assume the role.
+	RoleMfaModeSession = "session"
+	// RoleMfaModeAssumeRole performs MFA on the AssumeRole call, using the long-lived credentials.
+	RoleMfaModeAssumeRole = "assume_role"
+)
+
+// Supported values for the WebIdentityPkce configuration attribute.
+const (
+	// PkceAuto sends PKCE parameters in the authorization code flow, unless the identity provider rejects them.
+	PkceAuto = "auto"
+	// PkceRequired always sends PKCE parameters in the authorization code flow.
+	PkceRequired = "required"
+	// PkceDisabled never sends PKCE parameters in the authorization code flow.
+	PkceDisabled = "disabled"
 )
 
 // AwsConfig contains many standard AWS SDK configuration variables, and some non-standard configuration variables used
@@ -33,13 +81,22 @@ type AwsConfig struct {
 	MfaSerial              string        `ini:"mfa_serial,omitempty" env:"MFA_SERIAL"`   // only relevant to IAM identities
 	MfaCode                string        `ini:"-" env:"MFA_CODE"`                        // only env var supported, since this value frequently changes over time
 	MfaType                string        `ini:"mfa_type" env:"MFA_TYPE"`                 // only relevant for external IdP clients
+	MfaProvider            string        `ini:"mfa_provider,omitempty" env:"MFA_PROVIDER"`
+	MfaCommand             string        `ini:"mfa_command,omitempty" env:"MFA_COMMAND"`
+	MfaTotpSecret          string        `ini:"-" env:"MFA_TOTP_SECRET"` // only env var supported, keep secrets out of the config file
 	Region                 string        `ini:"region,omitempty" env:"AWS_REGION,AWS_DEFAULT_REGION"`
 	RoleArn                string        `ini:"role_arn"`                                                // env var not supported, comes in as command argument
 	RoleSessionName        string        `ini:"role_session_name,omitempty" env:"AWS_ROLE_SESSION_NAME"` // don't use? (only use IAM identity info or *_username for value?)
 	SrcProfile             string        `ini:"source_profile,omitempty"`                                // env var not supported, only found in config file, and should not be explicitly set
 	JumpRoleArn            string        `ini:"jump_role_arn,omitempty" env:"JUMP_ROLE_ARN"`
+	RoleMfaSerial          string        `ini:"role_mfa_serial,omitempty" env:"ROLE_MFA_SERIAL"`
+	RoleMfaMode            string        `ini:"role_mfa_mode,omitempty" env:"ROLE_MFA_MODE"`
+	PreferredRoles         string        `ini:"preferred_roles,omitempty" env:"PREFERRED_ROLES"`
 	SamlUrl                string        `ini:"saml_auth_url,omitempty" env:"SAML_AUTH_URL"`
 	SamlEntityId           string        `ini:"saml_auth_entityid,omitempty" env:"SAML_ENTITYID"`
+	SamlRpId               string        `ini:"saml_rp_id,omitempty" env:"SAML_RP_ID"`
+	SamlRelayState         string        `ini:"saml_relay_state,omitempty" env:"SAML_RELAY_STATE"`
+	SamlMetadataUrl        string        `ini:"saml_metadata_url,omitempty" env:"SAML_METADATA_URL"`
 	SamlUsername           string        `ini:"saml_username,omitempty" env:"SAML_USERNAME"`
 	SamlProvider           string        `ini:"saml_provider,omitempty" env:"SAML_PROVIDER"`
 	WebIdentityUrl         string        `ini:"web_identity_auth_url,omitempty" env:"WEB_IDENTITY_AUTH_URL"`
@@ -48,9 +105,36 @@ type AwsConfig struct {
 	WebIdentityTokenFile   string        `ini:"web_identity_token_file,omitempty" env:"AWS_WEB_IDENTITY_TOKEN_FILE"`
 	WebIdentityClientId    string        `ini:"web_identity_client_id,omitempty" env:"WEB_IDENTITY_CLIENT_ID"`
 	WebIdentityRedirectUri string        `ini:"web_identity_redirect_uri,omitempty" env:"WEB_IDENTITY_REDIRECT_URI"`
+	WebIdentityAudience    string        `ini:"web_identity_audience,omitempty" env:"WEB_IDENTITY_AUDIENCE"`
+	WebIdentityPkce        string        `ini:"web_identity_pkce,omitempty" env:"WEB_IDENTITY_PKCE"`
+	SubjectTokenFile       string        `ini:"web_identity_subject_token_file,omitempty" env:"WEB_IDENTITY_SUBJECT_TOKEN_FILE"`
 	FederatedUsername      string        `ini:"federated_username,omitempty" env:"FEDERATED_USERNAME"`
 	AuthBrowser            string        `ini:"auth_browser,omitempty" env:"AUTH_BROWSER"`
-	ProfileName            string        `ini:"-"` // does not participate in Marshal/Unmarshal, explicitly set
+	ConsoleBrowser         string        `ini:"console_browser,omitempty" env:"CONSOLE_BROWSER"`
+	ConsoleBrowserProfile  string        `ini:"console_browser_profile,omitempty" env:"CONSOLE_BROWSER_PROFILE"`
+	ConsoleContainer       string        `ini:"console_container,omitempty" env:"CONSOLE_CONTAINER"`
+	ConsoleSessionDuration time.Duration `ini:"console_session_duration,omitempty" env:"CONSOLE_SESSION_DURATION"`
+	ExpectedAccountId      string        `ini:"expected_account_id,omitempty" env:"EXPECTED_ACCOUNT_ID"`
+	AccountMapFile         string        `ini:"account_map_file,omitempty" env:"ACCOUNT_MAP_FILE"`
+	BaseCredentialProcess  string        `ini:"base_credential_process,omitempty" env:"BASE_CREDENTIAL_PROCESS"`
+	SsoStartUrl            string        `ini:"sso_start_url,omitempty"` // not used by aws-runas, only identifies AWS SSO profiles
+	SsoSession             string        `ini:"sso_session,omitempty"`   // not used by aws-runas, only identifies AWS SSO profiles
+	CacheDir               string        `ini:"cache_dir,omitempty" env:"AWS_RUNAS_CACHE_DIR"`
+	CacheUri               string        `ini:"cache_uri,omitempty" env:"CACHE_URI"`
+	CacheBackend           string        `ini:"cache_backend,omitempty" env:"CACHE_BACKEND"`
+	CacheRedisUrl          string        `ini:"cache_redis_url,omitempty" env:"CACHE_REDIS_URL"`
+	CacheRedisPassword     string        `ini:"-" env:"CACHE_REDIS_PASSWORD"` // only env var supported, keep secrets out of the config file
+	CacheKeyPrefix         string        `ini:"cache_key_prefix,omitempty" env:"CACHE_KEY_PREFIX"`
+	CacheDynamoDbTable     string        `ini:"cache_dynamodb_table,omitempty" env:"CACHE_DYNAMODB_TABLE"`
+	CacheKmsKeyId          string        `ini:"cache_kms_key_id,omitempty" env:"CACHE_KMS_KEY_ID"`
+	CacheKmsContext        string        `ini:"cache_kms_encryption_context,omitempty" env:"CACHE_KMS_ENCRYPTION_CONTEXT"`
+	StsMaxAttempts         int           `ini:"sts_max_attempts,omitempty" env:"STS_MAX_ATTEMPTS"`
+	StsMaxBackoff          time.Duration `ini:"sts_max_backoff,omitempty" env:"STS_MAX_BACKOFF"`
+	ProfileEnv             string        `ini:"env,omitempty"`             // env var not supported, only found in config file
+	DefaultCommand         string        `ini:"default_command,omitempty"` // env var not supported, only found in config file
+	ProfileAliases         string        `ini:"aliases,omitempty"`         // env var not supported, only found in config file
+	ProfileTags            string        `ini:"tags,omitempty"`            // env var not supported, only found in config file
+	ProfileName            string        `ini:"-"`                         // does not participate in Marshal/Unmarshal, explicitly set
 	sourceProfile          *AwsConfig
 }
 
@@ -85,14 +169,98 @@ func (c *AwsConfig) JumpRoleARN() (arn.ARN, error) {
 	return arn.Parse(c.JumpRoleArn)
 }
 
-// SamlURL returns the url.URL value for the SamlUrl field in the AwsConfig object.
+// SamlURL returns the url.URL value for the SamlUrl field in the AwsConfig object.  If the field is a list of URLs,
+// the first (primary) URL is returned.
 func (c *AwsConfig) SamlURL() (*url.URL, error) {
-	return c.handleUrl(c.SamlUrl)
+	return c.handleUrl(primaryUrl(c.SamlUrls()))
 }
 
-// WebIdentityURL returns the url.URL value for the WebIdentityUrl field in the AwsConfig object.
+// WebIdentityURL returns the url.URL value for the WebIdentityUrl field in the AwsConfig object.  If the field is a
+// list of URLs, the first (primary) URL is returned.
 func (c *AwsConfig) WebIdentityURL() (*url.URL, error) {
-	return c.handleUrl(c.WebIdentityUrl)
+	return c.handleUrl(primaryUrl(c.WebIdentityUrls()))
+}
+
+// SamlUrls returns the SamlUrl field, a comma separated list of identity provider endpoints in priority order, as a
+// slice.  The first URL is the primary endpoint, the others are only used if the endpoints before them fail.
+func (c *AwsConfig) SamlUrls() []string {
+	return urlList(c.SamlUrl)
+}
+
+// SamlLoginUrls returns the SamlUrls, with the parameters for an IdP-initiated sign on to the relying party configured
+// in the SamlRpId and SamlRelayState fields added, which lets identity providers like ADFS serve several relying
+// parties (like the AWS commercial and GovCloud partitions) from the same sign on URL.  A relay state is sent in the
+// RelayState parameter, along with the relying party identifier (DefaultSamlRpId if SamlRpId isn't set), otherwise
+// the relying party identifier is sent in the loginToRp parameter.  The parameters are only added to ADFS URLs (those
+// with an /adfs/ path), other URLs are returned unchanged, as are all URLs if neither field is set.
+func (c *AwsConfig) SamlLoginUrls() []string {
+	urls := c.SamlUrls()
+	if len(c.SamlRpId) < 1 && len(c.SamlRelayState) < 1 {
+		return urls
+	}
+
+	for i, s := range urls {
+		u, err := url.Parse(s)
+		if err != nil || !isAdfsUrl(u) {
+			continue
+		}
+
+		q := u.Query()
+		if len(c.SamlRelayState) > 0 {
+			rp := c.SamlRpId
+			if len(rp) < 1 {
+				rp = DefaultSamlRpId
+			}
+
+			rs := url.Values{}
+			rs.Set("RPID", rp)
+			rs.Set("RelayState", c.SamlRelayState)
+			q.Set("RelayState", rs.Encode())
+		} else {
+			q.Set("loginToRp", c.SamlRpId)
+		}
+
+		u.RawQuery = q.Encode()
+		urls[i] = u.String()
+	}
+	return urls
+}
+
+// isAdfsUrl returns true if the URL path is an ADFS endpoint, which live under the /adfs/ path.
+func isAdfsUrl(u *url.URL) bool {
+	return strings.Contains(strings.ToLower(u.Path)+"/", "/adfs/")
+}
+
+// WebIdentityUrls returns the WebIdentityUrl field, a comma separated list of identity provider endpoints in priority
+// order, as a slice.  The first URL is the primary endpoint, the others are only used if the endpoints before them fail.
+func (c *AwsConfig) WebIdentityUrls() []string {
+	return urlList(c.WebIdentityUrl)
+}
+
+// ProfileType returns the kind of credentials provided by the profile, one of the ProfileType* constants.  SAML and
+// Web Identity profiles are reported as such, even if they use a jump role.  Other profiles with a role ARN are role
+// profiles, and AWS SSO profiles (which aws-runas does not handle itself) are identified by their sso_* attributes.
+// IAM profiles which set an MFA serial number or session token duration are session profiles, all others are iam.
+func (c *AwsConfig) ProfileType() string {
+	switch {
+	case len(c.SamlUrl) > 0 || len(c.SamlMetadataUrl) > 0:
+		return ProfileTypeSaml
+	case len(c.WebIdentityUrl) > 0:
+		return ProfileTypeOidc
+	case len(c.RoleArn) > 0:
+		return ProfileTypeRole
+	case len(c.SsoStartUrl) > 0 || len(c.SsoSession) > 0:
+		return ProfileTypeSso
+	case len(c.MfaSerial) > 0 || c.SessionTokenDuration > 0:
+		return ProfileTypeSession
+	}
+	return ProfileTypeIam
+}
+
+// MfaOnAssumeRole returns true if the RoleMfaMode field requires MFA to be performed on the AssumeRole call, for roles
+// whose trust policy checks for MFA using credentials other than session token credentials.
+func (c *AwsConfig) MfaOnAssumeRole() bool {
+	return strings.EqualFold(c.RoleMfaMode, RoleMfaModeAssumeRole)
 }
 
 // MergeIn takes the settings in the provided "config" argument and applies them to the existing AwsConfig object.
@@ -113,6 +281,14 @@ func (c *AwsConfig) MergeIn(config ...*AwsConfig) {
 			c.DurationSeconds = cfg.DurationSeconds
 		}
 
+		if cfg.StsMaxAttempts > 0 {
+			c.StsMaxAttempts = cfg.StsMaxAttempts
+		}
+
+		if cfg.StsMaxBackoff > 0 {
+			c.StsMaxBackoff = cfg.StsMaxBackoff
+		}
+
 		if len(cfg.ExternalId) > 0 {
 			c.ExternalId = cfg.ExternalId
 		}
@@ -125,10 +301,34 @@ func (c *AwsConfig) MergeIn(config ...*AwsConfig) {
 			c.MfaCode = cfg.MfaCode
 		}
 
+		if len(cfg.RoleMfaSerial) > 0 {
+			c.RoleMfaSerial = cfg.RoleMfaSerial
+		}
+
+		if len(cfg.RoleMfaMode) > 0 {
+			c.RoleMfaMode = cfg.RoleMfaMode
+		}
+
+		if len(cfg.PreferredRoles) > 0 {
+			c.PreferredRoles = cfg.PreferredRoles
+		}
+
 		if len(cfg.MfaType) > 0 {
 			c.MfaType = cfg.MfaType
 		}
 
+		if len(cfg.MfaProvider) > 0 {
+			c.MfaProvider = cfg.MfaProvider
+		}
+
+		if len(cfg.MfaCommand) > 0 {
+			c.MfaCommand = cfg.MfaCommand
+		}
+
+		if len(cfg.MfaTotpSecret) > 0 {
+			c.MfaTotpSecret = cfg.MfaTotpSecret
+		}
+
 		if len(cfg.Region) > 0 {
 			c.Region = cfg.Region
 		}
@@ -158,6 +358,18 @@ func (c *AwsConfig) MergeIn(config ...*AwsConfig) {
 			c.SamlEntityId = cfg.SamlEntityId
 		}
 
+		if len(cfg.SamlRpId) > 0 {
+			c.SamlRpId = cfg.SamlRpId
+		}
+
+		if len(cfg.SamlRelayState) > 0 {
+			c.SamlRelayState = cfg.SamlRelayState
+		}
+
+		if len(cfg.SamlMetadataUrl) > 0 {
+			c.SamlMetadataUrl = cfg.SamlMetadataUrl
+		}
+
 		if len(cfg.SamlUsername) > 0 {
 			c.SamlUsername = cfg.SamlUsername
 		}
@@ -190,12 +402,112 @@ func (c *AwsConfig) MergeIn(config ...*AwsConfig) {
 			c.WebIdentityRedirectUri = cfg.WebIdentityRedirectUri
 		}
 
+		if len(cfg.WebIdentityAudience) > 0 {
+			c.WebIdentityAudience = cfg.WebIdentityAudience
+		}
+
+		if len(cfg.WebIdentityPkce) > 0 {
+			c.WebIdentityPkce = cfg.WebIdentityPkce
+		}
+
+		if len(cfg.SubjectTokenFile) > 0 {
+			c.SubjectTokenFile = cfg.SubjectTokenFile
+		}
+
 		if len(cfg.FederatedUsername) > 0 {
 			c.FederatedUsername = cfg.FederatedUsername
 		}
 		if len(cfg.AuthBrowser) > 0 {
 			c.AuthBrowser = cfg.AuthBrowser
 		}
+
+		if len(cfg.ConsoleBrowser) > 0 {
+			c.ConsoleBrowser = cfg.ConsoleBrowser
+		}
+
+		if len(cfg.ConsoleBrowserProfile) > 0 {
+			c.ConsoleBrowserProfile = cfg.ConsoleBrowserProfile
+		}
+
+		if len(cfg.ConsoleContainer) > 0 {
+			c.ConsoleContainer = cfg.ConsoleContainer
+		}
+
+		if cfg.ConsoleSessionDuration > 0 {
+			c.ConsoleSessionDuration = cfg.ConsoleSessionDuration
+		}
+
+		if len(cfg.ExpectedAccountId) > 0 {
+			c.ExpectedAccountId = cfg.ExpectedAccountId
+		}
+
+		if len(cfg.AccountMapFile) > 0 {
+			c.AccountMapFile = cfg.AccountMapFile
+		}
+
+		if len(cfg.BaseCredentialProcess) > 0 {
+			c.BaseCredentialProcess = cfg.BaseCredentialProcess
+		}
+
+		if len(cfg.SsoStartUrl) > 0 {
+			c.SsoStartUrl = cfg.SsoStartUrl
+		}
+
+		if len(cfg.SsoSession) > 0 {
+			c.SsoSession = cfg.SsoSession
+		}
+
+		if len(cfg.CacheDir) > 0 {
+			c.CacheDir = cfg.CacheDir
+		}
+
+		if len(cfg.CacheUri) > 0 {
+			c.CacheUri = cfg.CacheUri
+		}
+
+		if len(cfg.CacheBackend) > 0 {
+			c.CacheBackend = cfg.CacheBackend
+		}
+
+		if len(cfg.CacheRedisUrl) > 0 {
+			c.CacheRedisUrl = cfg.CacheRedisUrl
+		}
+
+		if len(cfg.CacheRedisPassword) > 0 {
+			c.CacheRedisPassword = cfg.CacheRedisPassword
+		}
+
+		if len(cfg.CacheKeyPrefix) > 0 {
+			c.CacheKeyPrefix = cfg.CacheKeyPrefix
+		}
+
+		if len(cfg.CacheDynamoDbTable) > 0 {
+			c.CacheDynamoDbTable = cfg.CacheDynamoDbTable
+		}
+
+		if len(cfg.CacheKmsKeyId) > 0 {
+			c.CacheKmsKeyId = cfg.CacheKmsKeyId
+		}
+
+		if len(cfg.CacheKmsContext) > 0 {
+			c.CacheKmsContext = cfg.CacheKmsContext
+		}
+
+		if len(cfg.ProfileEnv) > 0 {
+			c.ProfileEnv = cfg.ProfileEnv
+		}
+
+		if len(cfg.DefaultCommand) > 0 {
+			c.DefaultCommand = cfg.DefaultCommand
+		}
+
+		if len(cfg.ProfileAliases) > 0 {
+			c.ProfileAliases = cfg.ProfileAliases
+		}
+
+		if len(cfg.ProfileTags) > 0 {
+			c.ProfileTags = cfg.ProfileTags
+		}
 	}
 }
 
@@ -203,8 +515,14 @@ func (c *AwsConfig) MergeIn(config ...*AwsConfig) {
 // It performs the following tests:
 //   - Check that sourceProfile != nil if SrcProfile is set
 //   - Check that only one of SamlUrl or WebIdentityUrl is set
+//   - Check that BaseCredentialProcess is not set along with SamlUrl or WebIdentityUrl
 //   - Check that all required Web Identity fields (WebIdentityClientId, WebIdentityRedirectUri)
-//     are configured if WebIdentityUrl is set.
+//     are configured if WebIdentityUrl is set.  The redirect URI is not used, and not required,
+//     for token exchange (when SubjectTokenFile is set).
+//   - Check that ProfileEnv is a list of NAME=value pairs with valid environment variable names
+//   - Check that DefaultCommand does not have unbalanced quotes
+//   - Check that ProfileTags is a list of key=value pairs (or bare keys) with non-empty keys
+//   - Check that RoleMfaMode and WebIdentityPkce are supported values
 //
 //nolint:gocognit
 func (c *AwsConfig) Validate() error {
@@ -216,10 +534,59 @@ func (c *AwsConfig) Validate() error {
 		return errors.New("can not set SAML provider URL and Web Identity provider URL together")
 	}
 
-	if len(c.WebIdentityUrl) > 0 && (len(c.WebIdentityClientId) < 1 || len(c.WebIdentityRedirectUri) < 1) {
+	missingRedirect := len(c.WebIdentityRedirectUri) < 1 && len(c.SubjectTokenFile) < 1
+	if len(c.WebIdentityUrl) > 0 && (len(c.WebIdentityClientId) < 1 || missingRedirect) {
 		return errors.New("incomplete Web Identity configuration, missing client ID or redirect URI")
 	}
 
+	if len(c.BaseCredentialProcess) > 0 && (len(c.SamlUrl) > 0 || len(c.WebIdentityUrl) > 0) {
+		return errors.New("base_credential_process can not be used with a SAML or Web Identity provider")
+	}
+
+	if credentials.IsRolePattern(c.RoleArn) && (len(c.SamlUrl) < 1 || len(c.JumpRoleArn) > 0) {
+		return errors.New("role_arn patterns are only supported for SAML profiles without a jump role")
+	}
+
+	if credentials.IsRolePattern(c.JumpRoleArn) && len(c.SamlUrl) < 1 {
+		return errors.New("jump_role_arn patterns are only supported for SAML profiles")
+	}
+
+	if c.StsMaxAttempts < 0 || c.StsMaxBackoff < 0 {
+		return errors.New("sts_max_attempts and sts_max_backoff can not be negative")
+	}
+
+	if len(c.ExpectedAccountId) > 0 && !accountIdRe.MatchString(c.ExpectedAccountId) {
+		return errors.New("expected_account_id must be a 12 digit AWS account ID")
+	}
+
+	switch strings.ToLower(c.RoleMfaMode) {
+	case "", RoleMfaModeSession, RoleMfaModeAssumeRole:
+	default:
+		return fmt.Errorf("role_mfa_mode must be one of %s or %s", RoleMfaModeSession, RoleMfaModeAssumeRole)
+	}
+
+	switch strings.ToLower(c.WebIdentityPkce) {
+	case "", PkceAuto, PkceRequired, PkceDisabled:
+	default:
+		return fmt.Errorf("web_identity_pkce must be one of %s, %s, or %s", PkceRequired, PkceAuto, PkceDisabled)
+	}
+
+	if err := c.validateCacheBackend(); err != nil {
+		return err
+	}
+
+	if _, err := c.ProfileEnvironment(); err != nil {
+		return err
+	}
+
+	if _, err := c.TagMap(); err != nil {
+		return err
+	}
+
+	if _, err := c.DefaultCommandArgs(); err != nil {
+		return err
+	}
+
 	if len(c.AuthBrowser) > 0 && (c.AuthBrowser != "msedge") {
 		if c.AuthBrowser != `chrome` {
 			return errors.New("auth_browser is not set to msedge or chrome")
@@ -229,6 +596,169 @@ func (c *AwsConfig) Validate() error {
 	return nil
 }
 
+// PreferredRoleList returns the PreferredRoles field, a comma separated list of role names or patterns in priority
+// order, as a slice.
+func (c *AwsConfig) PreferredRoleList() []string {
+	roles := make([]string, 0)
+	for _, r := range strings.Split(c.PreferredRoles, ",") {
+		if r = strings.TrimSpace(r); len(r) > 0 {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
+
+// CacheKmsEncryptionContext returns the CacheKmsContext field, a comma separated list of key=value pairs, as a map.
+func (c *AwsConfig) CacheKmsEncryptionContext() (map[string]string, error) {
+	m, err := shared.ParseKeyValues(c.CacheKmsContext)
+	if err != nil {
+		return nil, fmt.Errorf("cache_kms_encryption_context: %w", err)
+	}
+	return m, nil
+}
+
+// ProfileEnvironment returns the ProfileEnv field, a comma separated list of NAME=value pairs, as a map.  These are the
+// extra environment variables set for commands wrapped using this profile.
+func (c *AwsConfig) ProfileEnvironment() (map[string]string, error) {
+	m := make(map[string]string)
+	for _, kv := range strings.Split(c.ProfileEnv, ",") {
+		if len(strings.TrimSpace(kv)) < 1 {
+			continue
+		}
+
+		k, v, ok := strings.Cut(kv, "=")
+		if k = strings.TrimSpace(k); !ok || !envNameRe.MatchString(k) {
+			return nil, fmt.Errorf("invalid env entry %s", kv)
+		}
+		m[k] = strings.TrimSpace(v)
+	}
+	return m, nil
+}
+
+// DefaultCommandArgs returns the DefaultCommand field, split in to the program and its arguments.  Arguments are
+// separated by whitespace, single or double quotes group words in to a single argument, and a backslash escapes the
+// next character (outside of single quotes).  This is the command run when the profile is used without a command.
+func (c *AwsConfig) DefaultCommandArgs() ([]string, error) {
+	args := make([]string, 0)
+	arg := new(strings.Builder)
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range c.DefaultCommand {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("invalid default_command, unterminated quote or escape: %s", c.DefaultCommand)
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// AliasList returns the ProfileAliases field, a comma separated list of alternate names for the profile, as a slice.
+func (c *AwsConfig) AliasList() []string {
+	aliases := make([]string, 0)
+	for _, a := range strings.Split(c.ProfileAliases, ",") {
+		if a = strings.TrimSpace(a); len(a) > 0 {
+			aliases = append(aliases, a)
+		}
+	}
+	return aliases
+}
+
+// TagMap returns the ProfileTags field, a comma separated list of key=value pairs, as a map.  A tag without a value
+// (no '=' character) is returned with an empty value, so it can still be matched by key.
+func (c *AwsConfig) TagMap() (map[string]string, error) {
+	m := make(map[string]string)
+	for _, kv := range strings.Split(c.ProfileTags, ",") {
+		if len(strings.TrimSpace(kv)) < 1 {
+			continue
+		}
+
+		k, v, _ := strings.Cut(kv, "=")
+		if k = strings.TrimSpace(k); len(k) < 1 {
+			return nil, fmt.Errorf("invalid tags entry %s", kv)
+		}
+		m[k] = strings.TrimSpace(v)
+	}
+	return m, nil
+}
+
+func (c *AwsConfig) validateCacheBackend() error {
+	if len(c.CacheUri) > 0 {
+		if u, err := url.Parse(c.CacheUri); err != nil || len(u.Scheme) < 1 {
+			return errors.New("cache_uri must be a URI with a scheme identifying the cache backend")
+		}
+		return nil
+	}
+
+	switch strings.ToLower(c.CacheBackend) {
+	case "", CacheBackendFile:
+		return nil
+	case CacheBackendRedis:
+		if len(c.CacheRedisUrl) < 1 {
+			return errors.New("cache_redis_url is required for the redis cache backend")
+		}
+		_, err := url.Parse(c.CacheRedisUrl)
+		return err
+	case CacheBackendDynamoDb:
+		if len(c.CacheDynamoDbTable) < 1 {
+			return errors.New("cache_dynamodb_table is required for the dynamodb cache backend")
+		}
+		_, err := c.CacheKmsEncryptionContext()
+		return err
+	}
+	return fmt.Errorf("invalid cache_backend %s", c.CacheBackend)
+}
+
+func urlList(s string) []string {
+	urls := make([]string, 0)
+	for _, u := range strings.Split(s, ",") {
+		if u = strings.TrimSpace(u); len(u) > 0 {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// primaryUrl returns the first URL of a list returned by SamlUrls or WebIdentityUrls, or an empty string if the list is
+// empty.
+func primaryUrl(urls []string) string {
+	if len(urls) < 1 {
+		return ""
+	}
+	return urls[0]
+}
+
 func (c *AwsConfig) handleUrl(u string) (*url.URL, error) {
 	if len(u) < 1 {
 		return nil, &url.Error{
@@ -14,7 +14,9 @@
 package config
 
 import (
+	"net/url"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -28,6 +30,8 @@ func TestAwsConfig_MergeIn(t *testing.T) {
 		ExternalId:             "ext",
 		MfaSerial:              "mfa",
 		MfaCode:                "code",
+		RoleMfaSerial:          "rolemfa",
+		PreferredRoles:         "Admin",
 		MfaType:                "auto",
 		Region:                 "region",
 		RoleArn:                "role",
@@ -44,6 +48,22 @@ func TestAwsConfig_MergeIn(t *testing.T) {
 		WebIdentityClientId:    "oauth_client",
 		WebIdentityRedirectUri: "app:/callback",
 		FederatedUsername:      "fed",
+		ConsoleBrowser:         "firefox",
+		ConsoleBrowserProfile:  "work",
+		ConsoleContainer:       "dev",
+		ExpectedAccountId:      "123456789012",
+		BaseCredentialProcess:  "true",
+		CacheDir:               os.TempDir(),
+		CacheUri:               "redis://localhost",
+		CacheBackend:           CacheBackendRedis,
+		CacheRedisUrl:          "redis://localhost",
+		CacheRedisPassword:     "secret",
+		CacheKeyPrefix:         "prefix:",
+		CacheDynamoDbTable:     "table",
+		CacheKmsKeyId:          "alias/key",
+		CacheKmsContext:        "k=v",
+		ProfileEnv:             "K=v",
+		DefaultCommand:         "terraform",
 		sourceProfile:          nil,
 	})
 }
@@ -61,6 +81,157 @@ func TestAwsConfig_Validate(t *testing.T) {
 		}
 	})
 
+	t.Run("good expected account", func(t *testing.T) {
+		if err := (&AwsConfig{ExpectedAccountId: "123456789012"}).Validate(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("bad expected account", func(t *testing.T) {
+		if err := (&AwsConfig{ExpectedAccountId: "12345"}).Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("bad sts retry", func(t *testing.T) {
+		if err := (&AwsConfig{StsMaxAttempts: -1}).Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+
+		if err := (&AwsConfig{StsMaxBackoff: -1 * time.Second}).Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("pkce", func(t *testing.T) {
+		for _, v := range []string{"", "auto", "Required", "disabled"} {
+			if err := (&AwsConfig{WebIdentityPkce: v}).Validate(); err != nil {
+				t.Error(err)
+			}
+		}
+
+		if err := (&AwsConfig{WebIdentityPkce: "plain"}).Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("role mfa mode", func(t *testing.T) {
+		for _, v := range []string{"", "session", "Assume_Role"} {
+			c := &AwsConfig{RoleMfaMode: v}
+			if err := c.Validate(); err != nil {
+				t.Error(err)
+			}
+
+			if c.MfaOnAssumeRole() != (v == "Assume_Role") {
+				t.Errorf("unexpected MfaOnAssumeRole() for %s", v)
+			}
+		}
+
+		if err := (&AwsConfig{RoleMfaMode: "both"}).Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("bad profile env", func(t *testing.T) {
+		if err := (&AwsConfig{ProfileEnv: "MY-VAR=x"}).Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("incomplete web identity", func(t *testing.T) {
+		cfg := &AwsConfig{WebIdentityUrl: "https://example.org/token", WebIdentityClientId: "my-app"}
+		if err := cfg.Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("token exchange without redirect uri", func(t *testing.T) {
+		cfg := &AwsConfig{WebIdentityUrl: "https://example.org/token", WebIdentityClientId: "my-app",
+			SubjectTokenFile: "/tmp/token.jwt"}
+		if err := cfg.Validate(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("saml role pattern", func(t *testing.T) {
+		cfg := &AwsConfig{SamlUrl: "https://example.org/saml", RoleArn: "arn:aws:iam::*:role/Dev*"}
+		if err := cfg.Validate(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("iam role pattern", func(t *testing.T) {
+		if err := (&AwsConfig{RoleArn: "arn:aws:iam::*:role/Dev*"}).Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("jump role with role pattern", func(t *testing.T) {
+		cfg := &AwsConfig{SamlUrl: "https://example.org/saml", JumpRoleArn: "arn:aws:iam::123456789012:role/jump",
+			RoleArn: "/arn:aws:iam::\\d+:role/Dev/"}
+		if err := cfg.Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("good redis cache backend", func(t *testing.T) {
+		cfg := &AwsConfig{CacheBackend: "Redis", CacheRedisUrl: "redis://localhost:6379"}
+		if err := cfg.Validate(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("missing redis cache url", func(t *testing.T) {
+		if err := (&AwsConfig{CacheBackend: CacheBackendRedis}).Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("good dynamodb cache backend", func(t *testing.T) {
+		cfg := &AwsConfig{CacheBackend: CacheBackendDynamoDb, CacheDynamoDbTable: "table", CacheKmsContext: "a=b"}
+		if err := cfg.Validate(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("missing dynamodb cache table", func(t *testing.T) {
+		if err := (&AwsConfig{CacheBackend: CacheBackendDynamoDb}).Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("bad kms encryption context", func(t *testing.T) {
+		cfg := &AwsConfig{CacheBackend: CacheBackendDynamoDb, CacheDynamoDbTable: "table", CacheKmsContext: "bad"}
+		if err := cfg.Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("good cache uri", func(t *testing.T) {
+		if err := (&AwsConfig{CacheUri: "keyring://aws-runas"}).Validate(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("bad cache uri", func(t *testing.T) {
+		if err := (&AwsConfig{CacheUri: "/no/scheme"}).Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("bad cache backend", func(t *testing.T) {
+		if err := (&AwsConfig{CacheBackend: "memcached"}).Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("base credential process with saml", func(t *testing.T) {
+		cfg := &AwsConfig{SamlUrl: "http://localhost/saml", BaseCredentialProcess: "broker get-creds"}
+		if err := cfg.Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
 	t.Run("saml and oidc urls", func(t *testing.T) {
 		cfg := &AwsConfig{
 			SamlUrl:        "http://localhost/saml",
@@ -94,3 +265,297 @@ func TestAwsConfig_Validate(t *testing.T) {
 		}
 	})
 }
+
+func TestAwsConfig_CacheKmsEncryptionContext(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		m, err := (&AwsConfig{CacheKmsContext: "team = ops, env=ci,"}).CacheKmsEncryptionContext()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(m) != 2 || m["team"] != "ops" || m["env"] != "ci" {
+			t.Errorf("unexpected encryption context: %v", m)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		m, err := new(AwsConfig).CacheKmsEncryptionContext()
+		if err != nil || len(m) > 0 {
+			t.Errorf("unexpected encryption context: %v, %v", m, err)
+		}
+	})
+
+	t.Run("bad", func(t *testing.T) {
+		if _, err := (&AwsConfig{CacheKmsContext: "=v"}).CacheKmsEncryptionContext(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestAwsConfig_ProfileEnvironment(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		m, err := (&AwsConfig{ProfileEnv: "KUBECONFIG = ~/.kube/dev, TF_VAR_env=dev=1,"}).ProfileEnvironment()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(m) != 2 || m["KUBECONFIG"] != "~/.kube/dev" || m["TF_VAR_env"] != "dev=1" {
+			t.Errorf("unexpected profile env: %v", m)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		m, err := new(AwsConfig).ProfileEnvironment()
+		if err != nil || len(m) > 0 {
+			t.Errorf("unexpected profile env: %v, %v", m, err)
+		}
+	})
+
+	t.Run("bad", func(t *testing.T) {
+		for _, e := range []string{"NOVALUE", "=v", "1VAR=v"} {
+			if _, err := (&AwsConfig{ProfileEnv: e}).ProfileEnvironment(); err == nil {
+				t.Errorf("did not receive expected error for %s", e)
+			}
+		}
+	})
+}
+
+func TestAwsConfig_DefaultCommandArgs(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		tests := map[string][]string{
+			"terraform":                      {"terraform"},
+			"  terraform   plan ":            {"terraform", "plan"},
+			`terraform -chdir="my dir" plan`: {"terraform", "-chdir=my dir", "plan"},
+			`sh -c 'echo "$HOME"'`:           {"sh", "-c", `echo "$HOME"`},
+			`echo a\ b \"c\" '' \\`:          {"echo", "a b", `"c"`, "", `\`},
+		}
+
+		for k, v := range tests {
+			a, err := (&AwsConfig{DefaultCommand: k}).DefaultCommandArgs()
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+
+			if !reflect.DeepEqual(a, v) {
+				t.Errorf("unexpected args for %s: %q", k, a)
+			}
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		a, err := (&AwsConfig{DefaultCommand: "  "}).DefaultCommandArgs()
+		if err != nil || len(a) > 0 {
+			t.Errorf("unexpected args: %q, %v", a, err)
+		}
+	})
+
+	t.Run("bad", func(t *testing.T) {
+		for _, e := range []string{`echo "hello`, `echo 'hello`, `echo \`} {
+			if _, err := (&AwsConfig{DefaultCommand: e}).DefaultCommandArgs(); err == nil {
+				t.Errorf("did not receive expected error for %s", e)
+			}
+		}
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		if err := (&AwsConfig{DefaultCommand: `terraform "plan`}).Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestAwsConfig_ProfileType(t *testing.T) {
+	tests := map[string]*AwsConfig{
+		ProfileTypeIam:     {Region: "us-east-1"},
+		ProfileTypeSession: {MfaSerial: "mfa"},
+		ProfileTypeRole:    {RoleArn: "arn:aws:iam::012345678901:role/Admin", SsoSession: "sso"},
+		ProfileTypeSaml:    {SamlMetadataUrl: "https://idp.local/metadata", RoleArn: "arn:aws:iam::012345678901:role/Admin"},
+		ProfileTypeOidc:    {WebIdentityUrl: "https://idp.local", JumpRoleArn: "arn:aws:iam::012345678901:role/Jump"},
+		ProfileTypeSso:     {SsoStartUrl: "https://sso.local/start"},
+	}
+
+	for k, v := range tests {
+		t.Run(k, func(t *testing.T) {
+			if p := v.ProfileType(); p != k {
+				t.Errorf("unexpected profile type: %s", p)
+			}
+		})
+	}
+
+	t.Run("session duration", func(t *testing.T) {
+		if p := (&AwsConfig{SessionTokenDuration: time.Hour}).ProfileType(); p != ProfileTypeSession {
+			t.Errorf("unexpected profile type: %s", p)
+		}
+	})
+}
+
+func TestAwsConfig_TagMap(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		m, err := (&AwsConfig{ProfileTags: "env = prod, team=platform,legacy,"}).TagMap()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(m) != 3 || m["env"] != "prod" || m["team"] != "platform" {
+			t.Errorf("unexpected tags: %v", m)
+		}
+
+		if v, ok := m["legacy"]; !ok || len(v) > 0 {
+			t.Errorf("unexpected value for bare tag: %v", m)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		m, err := new(AwsConfig).TagMap()
+		if err != nil || len(m) > 0 {
+			t.Errorf("unexpected tags: %v, %v", m, err)
+		}
+	})
+
+	t.Run("bad", func(t *testing.T) {
+		for _, e := range []string{"=prod", " = prod"} {
+			if _, err := (&AwsConfig{ProfileTags: e}).TagMap(); err == nil {
+				t.Errorf("did not receive expected error for %s", e)
+			}
+		}
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		if err := (&AwsConfig{ProfileTags: "=prod"}).Validate(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestAwsConfig_AliasList(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		a := (&AwsConfig{ProfileAliases: " dev, d,,"}).AliasList()
+		if len(a) != 2 || a[0] != "dev" || a[1] != "d" {
+			t.Errorf("unexpected aliases: %v", a)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if a := new(AwsConfig).AliasList(); len(a) > 0 {
+			t.Errorf("unexpected aliases: %v", a)
+		}
+	})
+}
+
+func TestAwsConfig_PreferredRoleList(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		r := (&AwsConfig{PreferredRoles: " Admin, *ReadOnly,,"}).PreferredRoleList()
+		if len(r) != 2 || r[0] != "Admin" || r[1] != "*ReadOnly" {
+			t.Errorf("unexpected preferred roles: %v", r)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if r := new(AwsConfig).PreferredRoleList(); len(r) > 0 {
+			t.Errorf("unexpected preferred roles: %v", r)
+		}
+	})
+}
+
+func TestAwsConfig_SamlUrls(t *testing.T) {
+	t.Run("list", func(t *testing.T) {
+		c := &AwsConfig{SamlUrl: "https://adfs.example.com/adfs/ls, https://adfs-dr.example.com/adfs/ls,"}
+		u := c.SamlUrls()
+		if len(u) != 2 || u[0] != "https://adfs.example.com/adfs/ls" || u[1] != "https://adfs-dr.example.com/adfs/ls" {
+			t.Errorf("unexpected urls: %v", u)
+		}
+
+		p, err := c.SamlURL()
+		if err != nil || p.Host != "adfs.example.com" {
+			t.Errorf("unexpected primary url: %v", p)
+		}
+	})
+
+	t.Run("single", func(t *testing.T) {
+		c := &AwsConfig{WebIdentityUrl: "https://idp.example.com/oauth2"}
+		if u := c.WebIdentityUrls(); len(u) != 1 || u[0] != c.WebIdentityUrl {
+			t.Errorf("unexpected urls: %v", u)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		c := new(AwsConfig)
+		if u := c.SamlUrls(); len(u) > 0 {
+			t.Errorf("unexpected urls: %v", u)
+		}
+
+		if _, err := c.SamlURL(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestAwsConfig_SamlLoginUrls(t *testing.T) {
+	const idp = "https://adfs.example.com/adfs/ls/IdpInitiatedSignOn.aspx"
+
+	t.Run("unset", func(t *testing.T) {
+		c := &AwsConfig{SamlUrl: idp}
+		if u := c.SamlLoginUrls(); len(u) != 1 || u[0] != idp {
+			t.Errorf("unexpected urls: %v", u)
+		}
+	})
+
+	t.Run("rp id", func(t *testing.T) {
+		c := &AwsConfig{SamlUrl: idp + "?foo=bar, https://adfs-dr.example.com/adfs/ls", SamlRpId: "urn:amazon:webservices:govcloud"}
+		u := c.SamlLoginUrls()
+		if len(u) != 2 ||
+			u[0] != idp+"?foo=bar&loginToRp=urn%3Aamazon%3Awebservices%3Agovcloud" ||
+			u[1] != "https://adfs-dr.example.com/adfs/ls?loginToRp=urn%3Aamazon%3Awebservices%3Agovcloud" {
+			t.Errorf("unexpected urls: %v", u)
+		}
+	})
+
+	t.Run("relay state", func(t *testing.T) {
+		c := &AwsConfig{SamlUrl: idp, SamlRelayState: "https://console.aws.amazon.com/"}
+		u := c.SamlLoginUrls()
+		if len(u) != 1 {
+			t.Fatalf("unexpected urls: %v", u)
+		}
+
+		p, err := url.Parse(u[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rs, err := url.ParseQuery(p.Query().Get("RelayState"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rs.Get("RPID") != DefaultSamlRpId || rs.Get("RelayState") != c.SamlRelayState || p.Query().Has("loginToRp") {
+			t.Errorf("unexpected url: %s", u[0])
+		}
+	})
+
+	t.Run("not adfs", func(t *testing.T) {
+		const okta = "https://example.okta.com/home/amazon_aws/0oa1234/272"
+		c := &AwsConfig{SamlUrl: okta + ", " + idp, SamlRpId: "urn:amazon:webservices:govcloud", SamlRelayState: "dev"}
+		u := c.SamlLoginUrls()
+		if len(u) != 2 || u[0] != okta || u[1] == idp {
+			t.Errorf("unexpected urls: %v", u)
+		}
+	})
+
+	t.Run("relay state with rp id", func(t *testing.T) {
+		c := &AwsConfig{SamlUrl: idp, SamlRpId: "urn:amazon:webservices:govcloud", SamlRelayState: "dev"}
+		p, _ := url.Parse(c.SamlLoginUrls()[0])
+		rs, _ := url.ParseQuery(p.Query().Get("RelayState"))
+		if rs.Get("RPID") != c.SamlRpId || rs.Get("RelayState") != "dev" {
+			t.Errorf("unexpected url: %s", p)
+		}
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		c := &AwsConfig{SamlRpId: "a"}
+		c.MergeIn(&AwsConfig{SamlRpId: "b", SamlRelayState: "c"})
+		if c.SamlRpId != "b" || c.SamlRelayState != "c" {
+			t.Errorf("unexpected config: %+v", c)
+		}
+	})
+}
@@ -64,3 +64,27 @@ func (l *chainLoader) Credentials(profile string, sources ...any) (*AwsCredentia
 
 	return c, nil
 }
+
+// ResolveAlias returns the profile name for alias using the Loaders in the chain which support profile aliases.  The
+// first Loader which resolves alias to a different name wins.  Like the other methods, errors are logged and the next
+// loader in the chain is consulted, so this method never returns an error.
+func (l *chainLoader) ResolveAlias(alias string, sources ...any) (string, error) {
+	for _, ldr := range l.loaders {
+		ar, ok := ldr.(AliasResolver)
+		if !ok {
+			continue
+		}
+
+		name, err := ar.ResolveAlias(alias, sources...)
+		if err != nil {
+			logger.Debugf("error resolving profile alias: %v", err)
+			continue
+		}
+
+		if name != alias {
+			return name, nil
+		}
+	}
+
+	return alias, nil
+}
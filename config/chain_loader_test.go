@@ -63,3 +63,24 @@ func TestChainLoader_Credentials(t *testing.T) {
 		}
 	})
 }
+
+func TestChainLoader_ResolveAlias(t *testing.T) {
+	t.Run("should never error", func(t *testing.T) {
+		l := NewChainLoader([]Loader{DefaultIniLoader})
+		p, err := l.ResolveAlias("dev", "not-a-file")
+		if err != nil {
+			t.Fatal("chain loader returned an error")
+		}
+
+		if p != "dev" {
+			t.Errorf("unexpected profile: %s", p)
+		}
+	})
+
+	t.Run("full chain", func(t *testing.T) {
+		l := NewChainLoader([]Loader{new(simpleLoader), DefaultIniLoader})
+		if p, _ := l.ResolveAlias("dev", aliasConfig); p != "development" {
+			t.Errorf("unexpected profile: %s", p)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// durationKeys are the ini keys holding a time.Duration value in the AwsConfig type.
+var durationKeys = []string{"credentials_duration", "session_token_duration", "sts_max_backoff", "console_session_duration"}
+
+// secondsKeys are the ini keys holding an integer number of seconds in the AwsConfig type.
+var secondsKeys = []string{"duration_seconds"}
+
+// maxSeconds is the largest bare integer number of seconds which fits in a time.Duration.
+const maxSeconds = int64(math.MaxInt64 / time.Second)
+
+// ParseDuration converts the provided string to a time.Duration.  The value may be a Go duration string (like "8h"
+// or "45m"), or a bare integer number of seconds.  Earlier versions read bare integers as a number of nanoseconds, and
+// those values are too large to be a number of seconds, so they are converted with a warning to update the setting.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.ParseDuration(s)
+	}
+
+	if i > maxSeconds || i < -maxSeconds {
+		d := time.Duration(i)
+		logger.Warningf("duration %d is read as a number of nanoseconds, change it to %d (seconds) or %s", i, i/int64(time.Second), d)
+		return d, nil
+	}
+	return time.Duration(i) * time.Second, nil
+}
+
+// parseSeconds converts the provided string to a number of seconds.  The value may be a bare integer number of
+// seconds, or a Go duration string (like "8h" or "45m").
+func parseSeconds(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	return int64(d / time.Second), nil
+}
+
+// normalizeDurations rewrites the duration-related keys in the section so they are parsed correctly when the section
+// is mapped to an AwsConfig.  Bare integers for the time.Duration keys are a number of seconds, and Go duration
+// strings for the integer seconds keys are converted to a number of seconds.  Values which can not be parsed are left
+// as-is.
+func normalizeDurations(s *ini.Section) {
+	for _, k := range durationKeys {
+		if key, err := s.GetKey(k); err == nil {
+			if d, err := ParseDuration(key.String()); err == nil {
+				key.SetValue(d.String())
+			}
+		}
+	}
+
+	for _, k := range secondsKeys {
+		if key, err := s.GetKey(k); err == nil {
+			if i, err := parseSeconds(key.String()); err == nil {
+				key.SetValue(strconv.FormatInt(i, 10))
+			}
+		}
+	}
+}
+
+// mapSection normalizes the duration values in the section, then maps it to the provided AwsConfig.
+func mapSection(s *ini.Section, c *AwsConfig) error {
+	normalizeDurations(s)
+	return s.MapTo(c)
+}
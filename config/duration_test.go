@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	t.Run("duration string", func(t *testing.T) {
+		d, err := ParseDuration("8h")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if d != 8*time.Hour {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("seconds", func(t *testing.T) {
+		d, err := ParseDuration(" 900 ")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if d != 15*time.Minute {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("nanoseconds", func(t *testing.T) {
+		d, err := ParseDuration("3600000000000")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if d != time.Hour {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("large seconds", func(t *testing.T) {
+		d, err := ParseDuration("1000000000")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if d != 1000000000*time.Second {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := ParseDuration("eight hours"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func Test_parseSeconds(t *testing.T) {
+	t.Run("duration string", func(t *testing.T) {
+		i, err := parseSeconds("8h")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if i != 28800 {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("seconds", func(t *testing.T) {
+		i, err := parseSeconds(" 900 ")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if i != 900 {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := parseSeconds("eight hours"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if _, err := parseSeconds(""); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
@@ -118,22 +118,28 @@ func setVal(field reflect.Value, value string) error {
 	//		}
 	//	}
 	//	field.SetFloat(fl)
-	// case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
-	//	i := int64(0)
-	//	if len(value) > 0 {
-	//		i, err = strconv.ParseInt(value, 0, 64)
-	//		if err != nil {
-	//			return err
-	//		}
-	//	}
-	//	field.SetInt(i)
-	case reflect.Int64:
+	case reflect.Int:
 		i := int64(0)
 		if len(value) > 0 {
-			// could be an actual Int64, or an alias ... like time.Duration
-			i, err = strconv.ParseInt(value, 0, 64)
+			i, err = strconv.ParseInt(strings.TrimSpace(value), 10, 0)
 			if err != nil {
-				d, err := time.ParseDuration(value)
+				return err
+			}
+		}
+		field.SetInt(i)
+	case reflect.Int64:
+		i := int64(0)
+		if len(value) > 0 {
+			if field.Type() != reflect.TypeOf(time.Duration(0)) {
+				// an actual Int64, which is a number of seconds, or a Go duration string
+				i, err = parseSeconds(value)
+				if err != nil {
+					return err
+				}
+			} else {
+				// an alias ... like time.Duration, a Go duration string or bare integer number of seconds
+				var d time.Duration
+				d, err = ParseDuration(value)
 				if err != nil {
 					return err
 				}
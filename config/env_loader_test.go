@@ -180,6 +180,24 @@ func TestEnvLoader_Config_Duration(t *testing.T) {
 		}
 	})
 
+	t.Run("good int seconds", func(t *testing.T) {
+		t.Setenv("CREDENTIALS_DURATION", "3600")
+		t.Setenv("SESSION_TOKEN_DURATION", "43200")
+		t.Setenv("CONSOLE_SESSION_DURATION", "7200")
+
+		c, err := DefaultEnvLoader.Config("")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if c.RoleCredentialDuration() != time.Hour || c.SessionTokenDuration != 12*time.Hour ||
+			c.ConsoleSessionDuration != 2*time.Hour {
+			t.Error("data mismatch")
+			return
+		}
+	})
+
 	t.Run("good int raw", func(t *testing.T) {
 		v := 3600 * 1000 * 1000 * 1000
 		_ = os.Setenv("SESSION_TOKEN_DURATION", strconv.Itoa(v))
@@ -197,6 +215,22 @@ func TestEnvLoader_Config_Duration(t *testing.T) {
 		}
 	})
 
+	t.Run("string duration seconds", func(t *testing.T) {
+		_ = os.Setenv("DURATION_SECONDS", "45m")
+		defer os.Unsetenv("DURATION_SECONDS")
+
+		c, err := DefaultEnvLoader.Config("")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if c.DurationSeconds != 2700 || c.RoleCredentialDuration() != 45*time.Minute {
+			t.Error("data mismatch")
+			return
+		}
+	})
+
 	t.Run("invalid string duration", func(t *testing.T) {
 		_ = os.Setenv("SESSION_TOKEN_DURATION", "lasdfa")
 		defer os.Unsetenv("SESSION_TOKEN_DURATION")
@@ -223,6 +257,47 @@ func TestEnvLoader_Config_Duration(t *testing.T) {
 	})
 }
 
+func TestEnvLoader_Config_StsRetry(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		t.Setenv("STS_MAX_ATTEMPTS", "8")
+		t.Setenv("STS_MAX_BACKOFF", "30s")
+
+		c, err := DefaultEnvLoader.Config("")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if c.StsMaxAttempts != 8 || c.StsMaxBackoff != 30*time.Second {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("backoff seconds", func(t *testing.T) {
+		for _, v := range []string{"30", "30000000000"} {
+			t.Setenv("STS_MAX_BACKOFF", v)
+
+			c, err := DefaultEnvLoader.Config("")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if c.StsMaxBackoff != 30*time.Second {
+				t.Errorf("data mismatch: %s", v)
+			}
+		}
+	})
+
+	t.Run("invalid attempts", func(t *testing.T) {
+		t.Setenv("STS_MAX_ATTEMPTS", "5s")
+
+		if _, err := DefaultEnvLoader.Config(""); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
 func TestEnvLoader_Credentials(t *testing.T) {
 	t.Run("empty", func(t *testing.T) {
 		c, err := DefaultEnvLoader.Credentials("")
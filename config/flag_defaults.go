@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"gopkg.in/ini.v1"
+)
+
+const (
+	// FlagDefaultsFileName is the name of the file, in the user's home directory, which sets default flag values.
+	FlagDefaultsFileName = ".aws_runasrc"
+	// FlagDefaultsFileEnvVar is the environment variable used to set a custom location for the flag defaults file.
+	FlagDefaultsFileEnvVar = "AWS_RUNAS_RC_FILE"
+)
+
+// FlagDefaultsFile returns the path of the flag defaults file, honoring the AWS_RUNAS_RC_FILE environment variable.
+// An empty string is returned if the user's home directory can not be determined.
+func FlagDefaultsFile() string {
+	if e, ok := os.LookupEnv(FlagDefaultsFileEnvVar); ok {
+		return e
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, FlagDefaultsFileName)
+}
+
+// LoadFlagDefaults reads the default command line flag values for the profile from the ini-style file at path, and
+// returns a map of flag name to value.  Keys outside of any section, or in the [default] section, apply to every
+// profile.  Keys in the [profile name] section apply to that profile, and replace the global value of the same key.
+// A file which does not exist is not an error, and returns no values.
+func LoadFlagDefaults(path, profile string) (map[string]string, error) {
+	m := make(map[string]string)
+	if len(path) < 1 {
+		return m, nil
+	}
+
+	f, err := ini.LoadSources(ini.LoadOptions{IgnoreInlineComment: true}, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return m, nil
+		}
+		return nil, fmt.Errorf("unable to load flag defaults file %s: %w", path, err)
+	}
+
+	sections := []string{ini.DefaultSection, config.DefaultSharedConfigProfile}
+	if len(profile) > 0 && profile != config.DefaultSharedConfigProfile {
+		sections = append(sections, "profile "+profile)
+	}
+
+	for _, name := range sections {
+		s, err := f.GetSection(name)
+		if err != nil {
+			continue
+		}
+
+		for _, k := range s.Keys() {
+			m[strings.TrimLeft(k.Name(), "-")] = k.String()
+		}
+	}
+	return m, nil
+}
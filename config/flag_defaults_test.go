@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadFlagDefaults(t *testing.T) {
+	f := filepath.Join(t.TempDir(), FlagDefaultsFileName)
+	data := `output = powershell
+verbose = true
+
+[default]
+warn-lifetime = 15m
+
+[profile prod]
+output = sh
+--refresh = true
+console.browser = firefox
+`
+	if err := os.WriteFile(f, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("global", func(t *testing.T) {
+		m, err := LoadFlagDefaults(f, "dev")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(m) != 3 || m["output"] != "powershell" || m["verbose"] != "true" || m["warn-lifetime"] != "15m" {
+			t.Errorf("unexpected flag defaults: %v", m)
+		}
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		m, err := LoadFlagDefaults(f, "prod")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(m) != 5 || m["output"] != "sh" || m["refresh"] != "true" || m["console.browser"] != "firefox" {
+			t.Errorf("unexpected flag defaults: %v", m)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		m, err := LoadFlagDefaults(filepath.Join(t.TempDir(), "missing"), "prod")
+		if err != nil || len(m) > 0 {
+			t.Errorf("unexpected flag defaults: %v, %v", m, err)
+		}
+	})
+
+	t.Run("no path", func(t *testing.T) {
+		m, err := LoadFlagDefaults("", "prod")
+		if err != nil || len(m) > 0 {
+			t.Errorf("unexpected flag defaults: %v, %v", m, err)
+		}
+	})
+
+	t.Run("bad file", func(t *testing.T) {
+		if _, err := LoadFlagDefaults(t.TempDir(), "prod"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestFlagDefaultsFile(t *testing.T) {
+	t.Run("env var", func(t *testing.T) {
+		t.Setenv(FlagDefaultsFileEnvVar, "/tmp/runasrc")
+		if f := FlagDefaultsFile(); f != "/tmp/runasrc" {
+			t.Errorf("unexpected file: %s", f)
+		}
+	})
+
+	t.Run("home dir", func(t *testing.T) {
+		if f := FlagDefaultsFile(); filepath.Base(f) != FlagDefaultsFileName {
+			t.Errorf("unexpected file: %s", f)
+		}
+	})
+}
@@ -0,0 +1,354 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"gopkg.in/ini.v1"
+)
+
+// saml2aws provider names which have an equivalent aws-runas SAML client.
+var saml2awsProviders = map[string]string{
+	"okta":     "okta",
+	"keycloak": "keycloak",
+	"onelogin": "onelogin",
+	"azuread":  "azuread",
+	"browser":  "browser",
+}
+
+// ImportSaml2Aws converts the accounts in the saml2aws configuration file at path to aws-runas profiles.  The profile
+// name is the aws_profile setting of the account, if set, otherwise the account name.  Accounts without a URL, or
+// without a role ARN are skipped, since aws-runas requires them.  Accounts using a provider with no aws-runas
+// equivalent are converted without a provider, so aws-runas will try to detect it from the URL.
+func ImportSaml2Aws(path string) ([]*AwsConfig, error) {
+	f, err := ini.LoadSources(ini.LoadOptions{IgnoreInlineComment: true}, path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfgs := make([]*AwsConfig, 0)
+	for _, s := range f.Sections() {
+		if s.Name() == ini.DefaultSection {
+			continue
+		}
+
+		cfg := &AwsConfig{
+			ProfileName:  s.Key("aws_profile").String(),
+			SamlUrl:      s.Key("url").String(),
+			SamlUsername: s.Key("username").String(),
+			RoleArn:      s.Key("role_arn").String(),
+			Region:       s.Key("region").String(),
+			MfaType:      saml2awsMfaType(s.Key("mfa").String()),
+		}
+
+		if len(cfg.ProfileName) < 1 {
+			cfg.ProfileName = s.Name()
+		}
+
+		if len(cfg.SamlUrl) < 1 || len(cfg.RoleArn) < 1 {
+			logger.Warningf("skipping saml2aws account %s, url and role_arn are required", s.Name())
+			continue
+		}
+
+		if p := s.Key("provider").String(); len(p) > 0 {
+			if v, ok := saml2awsProviders[strings.ToLower(p)]; ok {
+				cfg.SamlProvider = v
+			} else {
+				logger.Warningf("saml2aws provider %s for account %s is not supported, provider will be auto-detected", p, s.Name())
+			}
+		}
+
+		if d, _ := s.Key("aws_session_duration").Int64(); d > 0 {
+			cfg.DurationSeconds = d
+		}
+
+		cfgs = append(cfgs, cfg)
+	}
+
+	sortConfigs(cfgs)
+	return cfgs, nil
+}
+
+// saml2aws MFA option values map to the aws-runas MFA type which provides the same behavior.
+func saml2awsMfaType(mfa string) string {
+	switch strings.ToLower(mfa) {
+	case "", "auto":
+		return "auto"
+	case "push", "okta", "duo", "phoneappnotification":
+		return "push"
+	}
+	return "code"
+}
+
+// Saml2AwsCredentials returns the credentials saml2aws saved in the AWS credentials file at path for the profile.
+func Saml2AwsCredentials(path, profile string) (*credentials.Credentials, error) {
+	f, err := ini.LoadSources(ini.LoadOptions{IgnoreInlineComment: true}, path)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := f.GetSection(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := &credentials.Credentials{
+		AccessKeyId:     s.Key("aws_access_key_id").String(),
+		SecretAccessKey: s.Key("aws_secret_access_key").String(),
+		Token:           s.Key("aws_session_token").String(),
+	}
+
+	if len(creds.AccessKeyId) < 1 || len(creds.SecretAccessKey) < 1 || len(creds.Token) < 1 {
+		return nil, fmt.Errorf("no session credentials found for profile %s", profile)
+	}
+
+	// saml2aws writes the expiration in RFC3339 format, which is what Key.Time() expects
+	if creds.Expiration, err = s.Key("x_security_token_expires").Time(); err != nil {
+		return nil, fmt.Errorf("invalid credential expiration for profile %s: %w", profile, err)
+	}
+	return creds, nil
+}
+
+// ImportAwsVault converts the role profiles in the aws-vault (AWS config) file at path to aws-runas profiles.  The
+// aws-vault include_profile and parent_profile settings are resolved, since aws-runas does not support them.  The
+// names of the profiles which use long-term credentials (which aws-vault keeps in its credential store, instead of the
+// AWS credentials file) are returned with the role profiles.
+func ImportAwsVault(path string) ([]*AwsConfig, []string, error) {
+	f, err := ini.LoadSources(ini.LoadOptions{IgnoreInlineComment: true}, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sections := make(map[string]*ini.Section)
+	for _, s := range f.Sections() {
+		if s.Name() != ini.DefaultSection {
+			sections[strings.TrimPrefix(s.Name(), "profile ")] = s
+		}
+	}
+
+	cfgs := make([]*AwsConfig, 0)
+	base := make([]string, 0)
+	for name := range sections {
+		get := func(key string) string {
+			return awsVaultValue(sections, name, key)
+		}
+
+		if len(get("role_arn")) < 1 {
+			// profiles using SSO or an external credential process do not have credentials in the aws-vault store
+			if len(get("sso_start_url")) < 1 && len(get("sso_session")) < 1 && len(get("credential_process")) < 1 {
+				base = append(base, name)
+			}
+			continue
+		}
+
+		cfg := &AwsConfig{
+			ProfileName:     name,
+			RoleArn:         get("role_arn"),
+			SrcProfile:      get("source_profile"),
+			MfaSerial:       get("mfa_serial"),
+			ExternalId:      get("external_id"),
+			RoleSessionName: get("role_session_name"),
+			Region:          get("region"),
+		}
+
+		if len(cfg.SrcProfile) < 1 {
+			cfg.SrcProfile = get("parent_profile")
+		}
+
+		if d, err := strconv.ParseInt(get("duration_seconds"), 10, 64); err == nil {
+			cfg.DurationSeconds = d
+		}
+
+		cfgs = append(cfgs, cfg)
+	}
+
+	sortConfigs(cfgs)
+	sort.Strings(base)
+	return cfgs, base, nil
+}
+
+// awsVaultValue returns the value of key in the named profile, or the first profile in its chain of include_profile
+// settings which has the key.
+func awsVaultValue(sections map[string]*ini.Section, name, key string) string {
+	seen := make(map[string]bool)
+
+	for s, ok := sections[name]; ok && !seen[name]; s, ok = sections[name] {
+		if s.HasKey(key) {
+			return s.Key(key).String()
+		}
+
+		seen[name] = true
+		name = s.Key("include_profile").String()
+	}
+	return ""
+}
+
+// ImportGimmeAwsCreds converts the profiles in the gimme-aws-creds configuration file (.okta_aws_login_config) at path
+// to aws-runas Okta SAML profiles.  The inherits setting of gimme-aws-creds, and the values of the DEFAULT profile, are
+// resolved like gimme-aws-creds does.  A profile with more than one ARN in its aws_rolename setting is converted to a
+// profile for each role, named using the profile and role names.  Profiles without an app_url, or without role ARNs
+// (like 'all') are skipped, since aws-runas requires them.
+func ImportGimmeAwsCreds(path string) ([]*AwsConfig, error) {
+	f, err := ini.LoadSources(ini.LoadOptions{IgnoreInlineComment: true}, path)
+	if err != nil {
+		return nil, err
+	}
+
+	sections := make(map[string]*ini.Section)
+	for _, s := range f.Sections() {
+		sections[s.Name()] = s
+	}
+
+	cfgs := make([]*AwsConfig, 0)
+	for name, s := range sections {
+		if name == ini.DefaultSection && len(s.Keys()) < 1 {
+			continue
+		}
+
+		get := func(key string) string {
+			return gimmeAwsCredsValue(sections, name, key)
+		}
+
+		profile := name
+		if name == ini.DefaultSection {
+			profile = "default"
+		}
+
+		roles := gimmeAwsCredsRoles(get("aws_rolename"))
+		if len(get("app_url")) < 1 || len(roles) < 1 {
+			logger.Warningf("skipping gimme-aws-creds profile %s, app_url and aws_rolename ARNs are required", profile)
+			continue
+		}
+
+		for _, r := range roles {
+			cfg := &AwsConfig{
+				ProfileName:  profile,
+				SamlUrl:      get("app_url"),
+				SamlUsername: get("okta_username"),
+				SamlProvider: "okta",
+				RoleArn:      r,
+				MfaType:      oktaMfaType(get("preferred_mfa_type")),
+			}
+
+			if len(roles) > 1 {
+				cfg.ProfileName = fmt.Sprintf("%s-%s", profile, r[strings.LastIndex(r, "/")+1:])
+			}
+
+			if d, err := strconv.ParseInt(get("aws_default_duration"), 10, 64); err == nil {
+				cfg.DurationSeconds = d
+			}
+
+			cfgs = append(cfgs, cfg)
+		}
+	}
+
+	sortConfigs(cfgs)
+	return cfgs, nil
+}
+
+// gimmeAwsCredsValue returns the value of key in the named profile, the first profile in its chain of inherits settings
+// which has the key, or the DEFAULT profile.
+func gimmeAwsCredsValue(sections map[string]*ini.Section, name, key string) string {
+	seen := make(map[string]bool)
+
+	for s, ok := sections[name]; ok && !seen[name]; s, ok = sections[name] {
+		if s.HasKey(key) {
+			return s.Key(key).String()
+		}
+
+		seen[name] = true
+		name = s.Key("inherits").String()
+	}
+
+	if s, ok := sections[ini.DefaultSection]; ok {
+		return s.Key(key).String()
+	}
+	return ""
+}
+
+// gimmeAwsCredsRoles returns the role ARNs in the comma separated aws_rolename value.  Values which aren't ARNs, like
+// 'all', select roles interactively in gimme-aws-creds, and are ignored.
+func gimmeAwsCredsRoles(v string) []string {
+	roles := make([]string, 0)
+	for _, r := range strings.Split(v, ",") {
+		if r = strings.TrimSpace(r); arn.IsARN(r) {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
+
+// ImportOktaAwsCli converts the okta-aws-cli configuration file (config.properties) at path to an aws-runas Okta SAML
+// profile.  The profile name is the OKTA_PROFILE setting, if set, otherwise okta-aws-cli.  A configuration without an
+// OKTA_AWS_APP_URL, or without an OKTA_AWS_ROLE_TO_ASSUME is not converted, since aws-runas requires them.
+func ImportOktaAwsCli(path string) ([]*AwsConfig, error) {
+	f, err := ini.LoadSources(ini.LoadOptions{IgnoreInlineComment: true}, path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := f.Section(ini.DefaultSection)
+	cfg := &AwsConfig{
+		ProfileName:  s.Key("OKTA_PROFILE").String(),
+		SamlUrl:      s.Key("OKTA_AWS_APP_URL").String(),
+		SamlUsername: s.Key("OKTA_USERNAME").String(),
+		SamlProvider: "okta",
+		RoleArn:      s.Key("OKTA_AWS_ROLE_TO_ASSUME").String(),
+		Region:       s.Key("OKTA_AWS_REGION").String(),
+	}
+
+	if len(cfg.ProfileName) < 1 {
+		cfg.ProfileName = "okta-aws-cli"
+	}
+
+	if len(cfg.SamlUrl) < 1 || !arn.IsARN(cfg.RoleArn) {
+		logger.Warningf("skipping okta-aws-cli configuration, OKTA_AWS_APP_URL and OKTA_AWS_ROLE_TO_ASSUME are required")
+		return []*AwsConfig{}, nil
+	}
+
+	// the MFA choice is the factor provider and type, like OKTA.push or GOOGLE.token:software:totp
+	if c := s.Key("OKTA_MFA_CHOICE").String(); len(c) > 0 {
+		cfg.MfaType = oktaMfaType(c[strings.Index(c, ".")+1:])
+	}
+
+	if d, err := s.Key("OKTA_STS_DURATION").Int64(); err == nil {
+		cfg.DurationSeconds = d
+	}
+
+	return []*AwsConfig{cfg}, nil
+}
+
+// oktaMfaType maps the Okta factor type to the aws-runas MFA type which provides the same behavior.
+func oktaMfaType(factor string) string {
+	f := strings.ToLower(factor)
+	switch {
+	case f == "push":
+		return "push"
+	case strings.HasPrefix(f, "token"), f == "sms", f == "call":
+		return "code"
+	}
+	return "auto"
+}
+
+func sortConfigs(cfgs []*AwsConfig) {
+	sort.Slice(cfgs, func(i, j int) bool {
+		return cfgs[i].ProfileName < cfgs[j].ProfileName
+	})
+}
@@ -0,0 +1,290 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeImportFile(t *testing.T, data string) string {
+	t.Helper()
+	f := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(f, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestImportSaml2Aws(t *testing.T) {
+	data := `
+[default]
+url                  = https://example.okta.com/home/amazon_aws/0oa123/272
+username             = user@example.com
+provider             = Okta
+mfa                  = PUSH
+aws_session_duration = 7200
+aws_profile          = okta-admin
+role_arn             = arn:aws:iam::123456789012:role/Admin
+region               = us-east-2
+
+[adfs]
+url                  = https://adfs.example.com
+provider             = ADFS
+mfa                  = TOTP
+role_arn             = arn:aws:iam::123456789012:role/ReadOnly
+
+[norole]
+url                  = https://example.onelogin.com
+provider             = OneLogin
+`
+
+	cfgs, err := ImportSaml2Aws(writeImportFile(t, data))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(cfgs) != 2 {
+		t.Fatalf("expected 2 profiles, got %d", len(cfgs))
+	}
+
+	t.Run("aws profile name", func(t *testing.T) {
+		c := cfgs[1]
+		if c.ProfileName != "okta-admin" || c.SamlProvider != "okta" || c.MfaType != "push" || c.DurationSeconds != 7200 ||
+			c.SamlUsername != "user@example.com" || c.Region != "us-east-2" || c.RoleArn != "arn:aws:iam::123456789012:role/Admin" {
+			t.Errorf("data mismatch: %+v", c)
+		}
+	})
+
+	t.Run("unsupported provider", func(t *testing.T) {
+		c := cfgs[0]
+		if c.ProfileName != "adfs" || len(c.SamlProvider) > 0 || c.MfaType != "code" {
+			t.Errorf("data mismatch: %+v", c)
+		}
+	})
+
+	t.Run("bad file", func(t *testing.T) {
+		if _, err := ImportSaml2Aws(filepath.Join(t.TempDir(), "missing")); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestSaml2AwsCredentials(t *testing.T) {
+	exp := time.Now().Add(1 * time.Hour).UTC().Truncate(time.Second)
+	data := `
+[okta-admin]
+aws_access_key_id        = ASIAMOCK
+aws_secret_access_key    = MockSecret
+aws_session_token        = MockToken
+x_security_token_expires = ` + exp.Format(time.RFC3339) + `
+
+[static]
+aws_access_key_id     = AKIAMOCK
+aws_secret_access_key = MockSecret
+`
+	f := writeImportFile(t, data)
+
+	t.Run("good", func(t *testing.T) {
+		c, err := Saml2AwsCredentials(f, "okta-admin")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if c.AccessKeyId != "ASIAMOCK" || c.Token != "MockToken" || !c.Expiration.Equal(exp) {
+			t.Errorf("data mismatch: %+v", c)
+		}
+	})
+
+	t.Run("no session", func(t *testing.T) {
+		if _, err := Saml2AwsCredentials(f, "static"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("missing profile", func(t *testing.T) {
+		if _, err := Saml2AwsCredentials(f, "missing"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestImportAwsVault(t *testing.T) {
+	data := `
+[default]
+region = us-east-1
+
+[profile base]
+mfa_serial = arn:aws:iam::123456789012:mfa/user
+
+[profile common]
+mfa_serial       = arn:aws:iam::123456789012:mfa/user
+duration_seconds = 3600
+
+[profile admin]
+include_profile = common
+parent_profile  = base
+role_arn        = arn:aws:iam::123456789012:role/Admin
+
+[profile dev]
+source_profile = base
+role_arn       = arn:aws:iam::210987654321:role/Dev
+external_id    = xyz
+
+[profile sso]
+sso_start_url = https://example.awsapps.com/start
+`
+
+	cfgs, base, err := ImportAwsVault(writeImportFile(t, data))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(cfgs) != 2 {
+		t.Fatalf("expected 2 profiles, got %d", len(cfgs))
+	}
+
+	t.Run("include profile", func(t *testing.T) {
+		c := cfgs[0]
+		if c.ProfileName != "admin" || c.SrcProfile != "base" || c.DurationSeconds != 3600 ||
+			c.MfaSerial != "arn:aws:iam::123456789012:mfa/user" {
+			t.Errorf("data mismatch: %+v", c)
+		}
+	})
+
+	t.Run("source profile", func(t *testing.T) {
+		c := cfgs[1]
+		if c.ProfileName != "dev" || c.SrcProfile != "base" || c.ExternalId != "xyz" || len(c.MfaSerial) > 0 {
+			t.Errorf("data mismatch: %+v", c)
+		}
+	})
+
+	t.Run("base profiles", func(t *testing.T) {
+		if len(base) != 3 || base[0] != "base" || base[1] != "common" || base[2] != "default" {
+			t.Errorf("data mismatch: %v", base)
+		}
+	})
+}
+
+func TestImportGimmeAwsCreds(t *testing.T) {
+	data := `
+[DEFAULT]
+okta_org_url         = https://example.okta.com
+okta_username        = user@example.com
+app_url              = https://example.okta.com/home/amazon_aws/0oa123/272
+gimme_creds_server   = appurl
+preferred_mfa_type   = push
+aws_default_duration = 3600
+aws_rolename         = arn:aws:iam::123456789012:role/Admin
+
+[dev]
+inherits           = DEFAULT
+aws_rolename       = arn:aws:iam::123456789012:role/Dev, arn:aws:iam::123456789012:role/ReadOnly
+preferred_mfa_type = token:software:totp
+
+[all]
+aws_rolename = all
+`
+
+	cfgs, err := ImportGimmeAwsCreds(writeImportFile(t, data))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(cfgs) != 3 {
+		t.Fatalf("expected 3 profiles, got %d", len(cfgs))
+	}
+
+	t.Run("default profile", func(t *testing.T) {
+		c := cfgs[0]
+		if c.ProfileName != "default" || c.SamlProvider != "okta" || c.MfaType != "push" || c.DurationSeconds != 3600 ||
+			c.SamlUsername != "user@example.com" || c.SamlUrl != "https://example.okta.com/home/amazon_aws/0oa123/272" ||
+			c.RoleArn != "arn:aws:iam::123456789012:role/Admin" {
+			t.Errorf("data mismatch: %+v", c)
+		}
+	})
+
+	t.Run("multiple roles", func(t *testing.T) {
+		c := cfgs[1]
+		if c.ProfileName != "dev-Dev" || c.RoleArn != "arn:aws:iam::123456789012:role/Dev" || c.MfaType != "code" ||
+			c.SamlUsername != "user@example.com" {
+			t.Errorf("data mismatch: %+v", c)
+		}
+
+		if cfgs[2].ProfileName != "dev-ReadOnly" {
+			t.Errorf("data mismatch: %+v", cfgs[2])
+		}
+	})
+
+	t.Run("bad file", func(t *testing.T) {
+		if _, err := ImportGimmeAwsCreds(filepath.Join(t.TempDir(), "missing")); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestImportOktaAwsCli(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		data := `
+OKTA_ORG=example.okta.com
+OKTA_AWS_APP_URL=https://example.okta.com/home/amazon_aws/0oa123/272
+OKTA_USERNAME=user@example.com
+OKTA_AWS_ROLE_TO_ASSUME=arn:aws:iam::123456789012:role/Admin
+OKTA_PROFILE=okta-admin
+OKTA_STS_DURATION=7200
+OKTA_AWS_REGION=us-east-2
+OKTA_MFA_CHOICE=OKTA.push
+`
+
+		cfgs, err := ImportOktaAwsCli(writeImportFile(t, data))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(cfgs) != 1 {
+			t.Fatalf("expected 1 profile, got %d", len(cfgs))
+		}
+
+		c := cfgs[0]
+		if c.ProfileName != "okta-admin" || c.SamlProvider != "okta" || c.MfaType != "push" || c.DurationSeconds != 7200 ||
+			c.SamlUsername != "user@example.com" || c.Region != "us-east-2" || c.RoleArn != "arn:aws:iam::123456789012:role/Admin" {
+			t.Errorf("data mismatch: %+v", c)
+		}
+	})
+
+	t.Run("no role", func(t *testing.T) {
+		cfgs, err := ImportOktaAwsCli(writeImportFile(t, "OKTA_AWS_APP_URL=https://example.okta.com/home/amazon_aws/0oa123/272\n"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(cfgs) != 0 {
+			t.Error("unexpected profile")
+		}
+	})
+
+	t.Run("bad file", func(t *testing.T) {
+		if _, err := ImportOktaAwsCli(filepath.Join(t.TempDir(), "missing")); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
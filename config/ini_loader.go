@@ -39,6 +39,7 @@ type iniLoader bool
 // sources are specified, the default AWS config file (~/.aws/config) is used, unless overridden with the AWS_CONFIG_FILE
 // environment variable.
 func (l *iniLoader) Config(profile string, sources ...any) (*AwsConfig, error) {
+	sources = configSources(sources...)
 	file, err := resolveConfigSources(sources...)
 	if err != nil {
 		return nil, err
@@ -49,16 +50,18 @@ func (l *iniLoader) Config(profile string, sources ...any) (*AwsConfig, error) {
 		profile = config.DefaultSharedConfigProfile
 	} else {
 		// unconditionally attempt to load default profile config
-		_ = file.Section(config.DefaultSharedConfigProfile).MapTo(c)
+		_ = mapSection(file.Section(config.DefaultSharedConfigProfile), c)
+		warnUnknownKeys(file.Section(config.DefaultSharedConfigProfile), sources)
 	}
 
 	s, err := lookupProfile(file, profile)
 	if err != nil {
 		return c, err
 	}
+	warnUnknownKeys(s, sources)
 
 	pc := new(AwsConfig)
-	if err := s.MapTo(pc); err != nil {
+	if err := mapSection(s, pc); err != nil {
 		return c, err
 	}
 	c.MergeIn(pc)
@@ -67,18 +70,19 @@ func (l *iniLoader) Config(profile string, sources ...any) (*AwsConfig, error) {
 		src := new(AwsConfig)
 		src.ProfileName = c.SrcProfile
 
-		_ = file.Section(config.DefaultSharedConfigProfile).MapTo(src) // add defaults to source profile config
+		_ = mapSection(file.Section(config.DefaultSharedConfigProfile), src) // add defaults to source profile config
 
 		sp, err := lookupProfile(file, c.SrcProfile)
 		if err != nil {
 			return nil, err
 		}
+		warnUnknownKeys(sp, sources)
 
-		if err := sp.MapTo(c); err != nil {
+		if err := mapSection(sp, c); err != nil {
 			return nil, err
 		}
 
-		if err := sp.MapTo(src); err != nil {
+		if err := mapSection(sp, src); err != nil {
 			return nil, err
 		}
 
@@ -160,6 +164,41 @@ func (l *iniLoader) Profiles(sources ...any) (map[string]bool, error) {
 	return profiles, nil
 }
 
+// Aliases returns a map with profile aliases, set using the aliases attribute of a profile, as keys and the name of
+// the profile using the alias as values.  If more than one profile uses the same alias, the first profile in the
+// configuration keeps the alias, and a warning is logged.
+func (l *iniLoader) Aliases(sources ...any) (map[string]string, error) {
+	file, err := resolveConfigSources(sources...)
+	if err != nil {
+		return nil, err
+	}
+	return profileAliases(file), nil
+}
+
+// ResolveAlias returns the name of the profile which uses the alias argument in its aliases attribute.  If alias is
+// the name of an existing profile, or is not used by any profile, it is returned unchanged.  Profile names always take
+// priority over aliases.
+func (l *iniLoader) ResolveAlias(alias string, sources ...any) (string, error) {
+	if len(alias) < 1 {
+		return alias, nil
+	}
+
+	file, err := resolveConfigSources(sources...)
+	if err != nil {
+		return alias, err
+	}
+
+	if _, err = lookupProfile(file, alias); err == nil {
+		return alias, nil
+	}
+
+	if name, ok := profileAliases(file)[alias]; ok {
+		logger.Debugf("resolved alias %s to profile %s", alias, name)
+		return name, nil
+	}
+	return alias, nil
+}
+
 // SaveProfile writes the data in cfg to the AWS configuration file.  An error will be returned if there is an error
 // writing to the file, if cfg is nil, or if the ProfileName or RoleArn fields are empty in cfg.  This method will not
 // validate that the profile information in cfg is well formed for a given role type (IAM, SAML, OIDC).  This method
@@ -170,21 +209,9 @@ func (l *iniLoader) SaveProfile(cfg *AwsConfig) error {
 		return errors.New("invalid configuration, can not be nil or have empty profile name or role arn")
 	}
 
-	src := config.DefaultSharedConfigFilename()
-	if e, ok := os.LookupEnv("AWS_CONFIG_FILE"); ok {
-		src = e
-	}
-
-	f, err := loadFile(src)
-	if err != nil {
-		return err
-	}
-
-	if err = f.Section(fmt.Sprintf("profile %s", cfg.ProfileName)).ReflectFrom(cfg); err != nil {
-		return err
-	}
-
-	return writeFile(f, src, 0600)
+	return updateFile(configFilePath(), func(f *ini.File) error {
+		return f.Section(fmt.Sprintf("profile %s", cfg.ProfileName)).ReflectFrom(cfg)
+	})
 }
 
 // SaveCredentials writes the data in cred to the AWS credentials file, using the profile name specified by the profile
@@ -201,28 +228,16 @@ func (l *iniLoader) SaveCredentials(profile string, cred *AwsCredentials) error
 		return errors.New("profile name can not be empty")
 	}
 
-	src := config.DefaultSharedCredentialsFilename()
-	if e, ok := os.LookupEnv("AWS_SHARED_CREDENTIALS_FILE"); ok {
-		src = e
-	}
-
-	f, err := loadFile(src)
-	if err != nil {
-		return err
-	}
-
-	if err = f.Section(profile).ReflectFrom(cred); err != nil {
-		return err
-	}
-
-	return writeFile(f, src, 0600)
+	return updateFile(credentialsFilePath(), func(f *ini.File) error {
+		return f.Section(profile).ReflectFrom(cred)
+	})
 }
 
 // SaveStsCredentials writes AWS STS credentials (access key, secret, session token) to the AWS credentials
 // file under the given profile section, preserving all other sections. Safe for concurrent use across
 // goroutines and OS processes via an in-process mutex and an OS-level exclusive file lock on a companion
 // lock file. The write is fail-safe: credentials are written to a temp file and atomically renamed into place.
-func (l *iniLoader) SaveStsCredentials(profile string, cred *credentials.Credentials) (retErr error) {
+func (l *iniLoader) SaveStsCredentials(profile string, cred *credentials.Credentials) error {
 	if cred == nil {
 		return errors.New("invalid credentials, can not be nil")
 	}
@@ -231,45 +246,31 @@ func (l *iniLoader) SaveStsCredentials(profile string, cred *credentials.Credent
 		return errors.New("profile name can not be empty")
 	}
 
-	src := config.DefaultSharedCredentialsFilename()
-	if e, ok := os.LookupEnv("AWS_SHARED_CREDENTIALS_FILE"); ok {
-		src = e
-	}
-
-	if resolved, err := filepath.EvalSymlinks(src); err == nil {
-		src = resolved
-	}
-
-	if err := os.MkdirAll(filepath.Dir(src), 0750); err != nil {
-		return fmt.Errorf("unable to create credentials directory: %w", err)
-	}
-
-	// In-process lock
-	iniFileMu.Lock()
-	defer iniFileMu.Unlock()
+	return saveCredentialSection(profile+"-awsrunas", cred)
+}
 
-	// Cross-process lock via companion lock file
-	lockFile, err := acquireCredentialLock(src + ".lock")
-	if err != nil {
-		return fmt.Errorf("unable to lock credentials file: %w", err)
+// SaveAccessKeys writes long-term AWS credentials (access key and secret key) to the AWS credentials file under the
+// given profile section, preserving all other sections.  Any session token in cred is not written.  This method
+// assumes you know what you are doing and will happily overwrite existing credentials if the profile already exists.
+func (l *iniLoader) SaveAccessKeys(profile string, cred *credentials.Credentials) error {
+	if cred == nil || len(cred.AccessKeyId) < 1 || len(cred.SecretAccessKey) < 1 {
+		return errors.New("invalid credentials, can not be nil or empty")
 	}
-	defer func() {
-		if e := releaseCredentialLock(lockFile); e != nil && retErr == nil {
-			retErr = e
-		}
-	}()
 
-	// Read-modify-write: load current file, update only the target section
-	f, err := loadFile(src)
-	if err != nil {
-		return err
+	if len(profile) < 1 {
+		return errors.New("profile name can not be empty")
 	}
 
-	if err = f.Section(profile + "-awsrunas").ReflectFrom(cred); err != nil {
-		return err
-	}
+	return saveCredentialSection(profile, &credentials.Credentials{
+		AccessKeyId:     cred.AccessKeyId,
+		SecretAccessKey: cred.SecretAccessKey,
+	})
+}
 
-	return writeFile(f, src, 0600)
+func saveCredentialSection(section string, cred *credentials.Credentials) error {
+	return updateFile(credentialsFilePath(), func(f *ini.File) error {
+		return f.Section(section).ReflectFrom(cred)
+	})
 }
 
 func loadFile(path string) (*ini.File, error) {
@@ -321,17 +322,7 @@ func writeFile(f *ini.File, dst string, mode os.FileMode) error {
 func resolveConfigSources(sources ...any) (*ini.File, error) {
 	f := ini.Empty(ini.LoadOptions{IgnoreInlineComment: true})
 
-	if len(sources) < 1 {
-		src := config.DefaultSharedConfigFilename()
-		if e, ok := os.LookupEnv("AWS_CONFIG_FILE"); ok {
-			src = e
-		}
-		sources = make([]any, 1)
-		sources[0] = src
-		logger.Debugf("using configuration source %s", src)
-	}
-
-	for _, s := range sources {
+	for _, s := range configSources(sources...) {
 		if err := f.Append(s); err != nil {
 			return nil, err
 		}
@@ -340,14 +331,21 @@ func resolveConfigSources(sources ...any) (*ini.File, error) {
 	return f, nil
 }
 
+// configSources returns the provided sources, or the default AWS config file if no sources are provided.
+func configSources(sources ...any) []any {
+	if len(sources) < 1 {
+		src := configFilePath()
+		sources = []any{src}
+		logger.Debugf("using configuration source %s", src)
+	}
+	return sources
+}
+
 func resolveCredentialSources(sources ...any) (*ini.File, error) {
 	f := ini.Empty()
 
 	if len(sources) < 1 {
-		src := config.DefaultSharedCredentialsFilename()
-		if e, ok := os.LookupEnv("AWS_SHARED_CREDENTIALS_FILE"); ok {
-			src = e
-		}
+		src := credentialsFilePath()
 		sources = make([]any, 1)
 		sources[0] = src
 		logger.Debugf("using credentials source %s", src)
@@ -362,6 +360,29 @@ func resolveCredentialSources(sources ...any) (*ini.File, error) {
 	return f, nil
 }
 
+func profileAliases(f *ini.File) map[string]string {
+	aliases := make(map[string]string)
+	for _, s := range f.Sections() {
+		if s.Name() == ini.DefaultSection || !s.HasKey("aliases") {
+			continue
+		}
+
+		name := strings.TrimPrefix(s.Name(), "profile ")
+		c := &AwsConfig{ProfileAliases: s.Key("aliases").String()}
+		for _, a := range c.AliasList() {
+			if p, ok := aliases[a]; ok && p != name {
+				w := fmt.Sprintf("alias %s of profile %s is already used by profile %s, ignoring", a, name, p)
+				if _, loaded := warnedKeys.LoadOrStore(w, true); !loaded {
+					logger.Warningf("%s", w)
+				}
+				continue
+			}
+			aliases[a] = name
+		}
+	}
+	return aliases
+}
+
 func lookupProfile(f *ini.File, profile string) (*ini.Section, error) {
 	s, err := f.GetSection(profile)
 	if err != nil {
@@ -127,6 +127,35 @@ func TestIniLoader_Config_Duration(t *testing.T) {
 		}
 	})
 
+	t.Run("string duration seconds", func(t *testing.T) {
+		c, err := DefaultIniLoader.Config("string_duration_seconds", testConfig)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if c.DurationSeconds != 28800 || c.RoleCredentialDuration() != 8*time.Hour {
+			t.Error("data mismatch")
+			return
+		}
+	})
+
+	t.Run("int duration", func(t *testing.T) {
+		for _, p := range []string{"int_credentials_duration", "nanos_credentials_duration"} {
+			c, err := DefaultIniLoader.Config(p, testConfig)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if c.RoleCredentialDuration() != time.Hour || c.SessionTokenDuration != 12*time.Hour ||
+				c.ConsoleSessionDuration != 2*time.Hour {
+				t.Errorf("data mismatch: %s", p)
+				return
+			}
+		}
+	})
+
 	t.Run("invalid string duration", func(t *testing.T) {
 		c, err := DefaultIniLoader.Config("invalid_duration", testConfig)
 		if err != nil {
@@ -279,7 +308,7 @@ func TestIniLoader_Profiles(t *testing.T) {
 		return
 	}
 
-	if len(p) != 9 {
+	if len(p) != 12 {
 		t.Error("did not receive expected number of profiles")
 	}
 
@@ -295,6 +324,73 @@ func TestIniLoader_Profiles(t *testing.T) {
 	}
 }
 
+func TestIniLoader_Aliases(t *testing.T) {
+	a, err := DefaultIniLoader.Aliases(aliasConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a) != 4 || a["dev"] != "development" || a["d"] != "development" || a["prod"] != "production" {
+		t.Errorf("unexpected aliases: %v", a)
+	}
+}
+
+func TestIniLoader_ResolveAlias(t *testing.T) {
+	t.Run("alias", func(t *testing.T) {
+		p, err := DefaultIniLoader.ResolveAlias("d", aliasConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p != "development" {
+			t.Errorf("unexpected profile: %s", p)
+		}
+	})
+
+	t.Run("profile name wins", func(t *testing.T) {
+		if p, _ := DefaultIniLoader.ResolveAlias("staging", aliasConfig); p != "staging" {
+			t.Errorf("unexpected profile: %s", p)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		if p, _ := DefaultIniLoader.ResolveAlias("unknown", aliasConfig); p != "unknown" {
+			t.Errorf("unexpected profile: %s", p)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if p, err := DefaultIniLoader.ResolveAlias("", aliasConfig); err != nil || len(p) > 0 {
+			t.Errorf("unexpected profile: %s, %v", p, err)
+		}
+	})
+
+	t.Run("bad file", func(t *testing.T) {
+		if _, err := DefaultIniLoader.ResolveAlias("dev", "not-a-file"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("config", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "config")
+		if err := os.WriteFile(f, aliasConfig, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		os.Setenv("AWS_CONFIG_FILE", f)
+		defer os.Unsetenv("AWS_CONFIG_FILE")
+
+		c, err := NewResolver(DefaultIniLoader, false).Config("prod")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.ProfileName != "production" || c.Region != "us-west-2" {
+			t.Errorf("unexpected config: %s %s", c.ProfileName, c.Region)
+		}
+	})
+}
+
 func TestIniLoader_SaveProfile(t *testing.T) {
 	tf, err := os.CreateTemp(t.TempDir(), t.Name())
 	if err != nil {
@@ -841,6 +937,48 @@ func TestIniLoader_SaveStsCredentials(t *testing.T) {
 	})
 }
 
+func TestIniLoader_SaveAccessKeys(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "credentials")
+		t.Setenv("AWS_SHARED_CREDENTIALS_FILE", f)
+
+		cred := &credentials.Credentials{AccessKeyId: "AKIAMOCK", SecretAccessKey: "MockSecret", Token: "MockToken"}
+		if err := DefaultIniLoader.SaveAccessKeys("base", cred); err != nil {
+			t.Error(err)
+			return
+		}
+
+		file, err := loadFile(f)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		s, err := file.GetSection("base")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if s.Key("aws_access_key_id").String() != "AKIAMOCK" || s.HasKey("aws_session_token") {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("empty keys", func(t *testing.T) {
+		if err := DefaultIniLoader.SaveAccessKeys("base", new(credentials.Credentials)); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("empty profile", func(t *testing.T) {
+		cred := &credentials.Credentials{AccessKeyId: "AKIAMOCK", SecretAccessKey: "MockSecret"}
+		if err := DefaultIniLoader.SaveAccessKeys("", cred); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
 var testCredentials = []byte(`
 [default]
 aws_access_key_id = mockAk
@@ -873,6 +1011,19 @@ role_arn =
 [profile int_duration]
 duration_seconds = 900
 
+[profile string_duration_seconds]
+duration_seconds = 8h
+
+[profile int_credentials_duration]
+credentials_duration = 3600
+session_token_duration = 43200
+console_session_duration = 7200
+
+[profile nanos_credentials_duration]
+credentials_duration = 3600000000000
+session_token_duration = 43200000000000
+console_session_duration = 7200000000000
+
 [invalid_duration]
 credentials_duration = alsga
 
@@ -887,3 +1038,19 @@ mfa_serial = mockMfa
 source_profile = error_404
 role_arn = arn:aws:iam::0123456789:role/Admin
 `)
+
+var aliasConfig = []byte(`
+[profile development]
+region = us-east-1
+aliases = dev, d
+tags = env=dev
+
+[profile production]
+region = us-west-2
+aliases = prod, staging
+tags = env=prod
+
+[profile staging]
+region = us-east-2
+aliases = d
+`)
@@ -0,0 +1,239 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"gopkg.in/ini.v1"
+)
+
+var iniKeyRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// CreateProfile adds a new profile with the provided settings to the AWS configuration file.  An error is returned if
+// the profile already exists.
+func (l *iniLoader) CreateProfile(profile string, values map[string]string) error {
+	if err := validateValues(profile, values); err != nil {
+		return err
+	}
+
+	return updateFile(configFilePath(), func(f *ini.File) error {
+		if s := findConfigSection(f, profile); s != nil {
+			return fmt.Errorf("profile %s already exists", profile)
+		}
+
+		s, err := f.NewSection(configSectionName(profile))
+		if err != nil {
+			return err
+		}
+		return setKeys(s, values)
+	})
+}
+
+// DeleteProfile removes the profile from the AWS configuration file.  An error is returned if the profile does not
+// exist.
+func (l *iniLoader) DeleteProfile(profile string) error {
+	return updateFile(configFilePath(), func(f *ini.File) error {
+		s := findConfigSection(f, profile)
+		if s == nil {
+			return fmt.Errorf("profile %s not found", profile)
+		}
+
+		f.DeleteSection(s.Name())
+		return nil
+	})
+}
+
+// SetConfig sets the provided keys of the profile in the AWS configuration file, creating the profile if it does not
+// exist.  Existing keys keep their place in the profile, new keys are added to the end of the profile.
+func (l *iniLoader) SetConfig(profile string, values map[string]string) error {
+	if err := validateValues(profile, values); err != nil {
+		return err
+	}
+
+	return updateFile(configFilePath(), func(f *ini.File) error {
+		s := findConfigSection(f, profile)
+		if s == nil {
+			var err error
+			if s, err = f.NewSection(configSectionName(profile)); err != nil {
+				return err
+			}
+		}
+		return setKeys(s, values)
+	})
+}
+
+// RemoveConfig removes the keys from the profile in the AWS configuration file.  Keys which are not set in the profile
+// are ignored.  An error is returned if the profile does not exist.
+func (l *iniLoader) RemoveConfig(profile string, keys ...string) error {
+	return updateFile(configFilePath(), func(f *ini.File) error {
+		s := findConfigSection(f, profile)
+		if s == nil {
+			return fmt.Errorf("profile %s not found", profile)
+		}
+
+		for _, k := range keys {
+			s.DeleteKey(k)
+		}
+		return nil
+	})
+}
+
+// SetCredentials sets the provided keys of the section in the AWS credentials file, creating the section if it does not
+// exist.  Values are stored as-is, any encryption/obfuscation is expected to be completed before calling this method.
+func (l *iniLoader) SetCredentials(profile string, values map[string]string) error {
+	if err := validateValues(profile, values); err != nil {
+		return err
+	}
+
+	return updateFile(credentialsFilePath(), func(f *ini.File) error {
+		return setKeys(f.Section(profile), values)
+	})
+}
+
+// RemoveCredentials removes the keys from the section in the AWS credentials file.  If no keys are provided, the whole
+// section is removed.  Missing keys or sections are ignored.
+func (l *iniLoader) RemoveCredentials(profile string, keys ...string) error {
+	return updateFile(credentialsFilePath(), func(f *ini.File) error {
+		s, err := f.GetSection(profile)
+		if err != nil {
+			return nil //nolint:nilerr // nothing to remove
+		}
+
+		if len(keys) < 1 {
+			f.DeleteSection(s.Name())
+			return nil
+		}
+
+		for _, k := range keys {
+			s.DeleteKey(k)
+		}
+		return nil
+	})
+}
+
+func validateValues(profile string, values map[string]string) error {
+	if len(profile) < 1 {
+		return errors.New("profile name can not be empty")
+	}
+
+	for k, v := range values {
+		if !iniKeyRe.MatchString(k) {
+			return fmt.Errorf("invalid key name '%s'", k)
+		}
+
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("value for %s can not contain line breaks", k)
+		}
+	}
+	return nil
+}
+
+func setKeys(s *ini.Section, values map[string]string) error {
+	for k, v := range values {
+		if s.HasKey(k) {
+			s.Key(k).SetValue(v)
+			continue
+		}
+
+		if _, err := s.NewKey(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findConfigSection returns the section for the profile in the AWS configuration file, or nil if there is no section
+// for the profile.
+func findConfigSection(f *ini.File, profile string) *ini.Section {
+	s, err := lookupProfile(f, profile)
+	if err != nil {
+		return nil
+	}
+	return s
+}
+
+// configSectionName returns the name of a new section for the profile in the AWS configuration file.
+func configSectionName(profile string) string {
+	if profile == config.DefaultSharedConfigProfile {
+		return profile
+	}
+	return fmt.Sprintf("profile %s", profile)
+}
+
+// SharedConfigFiles returns the paths of the AWS config and credentials files, honoring the AWS_CONFIG_FILE and
+// AWS_SHARED_CREDENTIALS_FILE environment variables.
+func SharedConfigFiles() []string {
+	return []string{configFilePath(), credentialsFilePath()}
+}
+
+func configFilePath() string {
+	if e, ok := os.LookupEnv("AWS_CONFIG_FILE"); ok {
+		return e
+	}
+	return config.DefaultSharedConfigFilename()
+}
+
+func credentialsFilePath() string {
+	if e, ok := os.LookupEnv("AWS_SHARED_CREDENTIALS_FILE"); ok {
+		return e
+	}
+	return config.DefaultSharedCredentialsFilename()
+}
+
+// updateFile loads the ini file at path, applies the changes made by fn, and writes the file back.  Updates are safe
+// for concurrent use across goroutines and OS processes, and the file is replaced atomically, so a failed update leaves
+// the original file in place.  Comments and the order of the sections and keys in the file are preserved.
+func updateFile(path string, fn func(f *ini.File) error) (retErr error) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("unable to create directory: %w", err)
+	}
+
+	// In-process lock
+	iniFileMu.Lock()
+	defer iniFileMu.Unlock()
+
+	// Cross-process lock via companion lock file
+	lockFile, err := acquireCredentialLock(path + ".lock")
+	if err != nil {
+		return fmt.Errorf("unable to lock %s: %w", path, err)
+	}
+	defer func() {
+		if e := releaseCredentialLock(lockFile); e != nil && retErr == nil {
+			retErr = e
+		}
+	}()
+
+	// Read-modify-write: load current file, update only the requested data
+	f, err := loadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err = fn(f); err != nil {
+		return err
+	}
+
+	return writeFile(f, path, 0600)
+}
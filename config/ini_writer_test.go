@@ -0,0 +1,261 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var writerConfig = `# my aws config
+[default]
+region = us-east-1
+
+# the dev account
+[profile dev]
+role_arn = arn:aws:iam::0123456789:role/Admin
+# keep the session short
+credentials_duration = 1h
+
+[legacy]
+region = us-west-2
+`
+
+func newWriterConfig(t *testing.T) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(file, []byte(writerConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", file)
+	return file
+}
+
+func readWriterFile(t *testing.T, file string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestIniLoader_SetConfig(t *testing.T) {
+	t.Run("existing profile", func(t *testing.T) {
+		file := newWriterConfig(t)
+
+		err := DefaultIniLoader.SetConfig("dev", map[string]string{"credentials_duration": "2h", "region": "eu-west-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data := readWriterFile(t, file)
+		for _, s := range []string{"# my aws config", "# the dev account", "# keep the session short"} {
+			if !strings.Contains(data, s) {
+				t.Errorf("comment '%s' was not preserved", s)
+			}
+		}
+
+		if strings.Index(data, "role_arn") > strings.Index(data, "credentials_duration") ||
+			strings.Index(data, "credentials_duration") > strings.Index(data, "eu-west-1") {
+			t.Errorf("key order not preserved:\n%s", data)
+		}
+
+		c, err := DefaultIniLoader.Config("dev", file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.CredentialsDuration.Hours() != 2 || c.Region != "eu-west-1" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("legacy section name", func(t *testing.T) {
+		file := newWriterConfig(t)
+
+		if err := DefaultIniLoader.SetConfig("legacy", map[string]string{"region": "ca-central-1"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if data := readWriterFile(t, file); strings.Contains(data, "[profile legacy]") {
+			t.Errorf("unexpected new section:\n%s", data)
+		}
+	})
+
+	t.Run("new profile", func(t *testing.T) {
+		file := newWriterConfig(t)
+
+		if err := DefaultIniLoader.SetConfig("new", map[string]string{"region": "ca-central-1"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if data := readWriterFile(t, file); !strings.Contains(data, "[profile new]") {
+			t.Errorf("profile not created:\n%s", data)
+		}
+	})
+
+	t.Run("bad key", func(t *testing.T) {
+		newWriterConfig(t)
+
+		if err := DefaultIniLoader.SetConfig("dev", map[string]string{"bad key": "x"}); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("bad value", func(t *testing.T) {
+		newWriterConfig(t)
+
+		if err := DefaultIniLoader.SetConfig("dev", map[string]string{"region": "x\n[evil]"}); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestIniLoader_RemoveConfig(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		file := newWriterConfig(t)
+
+		if err := DefaultIniLoader.RemoveConfig("dev", "credentials_duration", "not_set"); err != nil {
+			t.Fatal(err)
+		}
+
+		data := readWriterFile(t, file)
+		if strings.Contains(data, "credentials_duration") || !strings.Contains(data, "role_arn") {
+			t.Errorf("unexpected config:\n%s", data)
+		}
+	})
+
+	t.Run("missing profile", func(t *testing.T) {
+		newWriterConfig(t)
+
+		if err := DefaultIniLoader.RemoveConfig("missing", "region"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestIniLoader_CreateProfile(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		file := newWriterConfig(t)
+
+		if err := DefaultIniLoader.CreateProfile("prod", map[string]string{"role_arn": "arn:aws:iam::0123456789:role/Prod"}); err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := DefaultIniLoader.Config("prod", file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.RoleArn != "arn:aws:iam::0123456789:role/Prod" || c.Region != "us-east-1" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("exists", func(t *testing.T) {
+		newWriterConfig(t)
+
+		if err := DefaultIniLoader.CreateProfile("dev", nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("empty name", func(t *testing.T) {
+		newWriterConfig(t)
+
+		if err := DefaultIniLoader.CreateProfile("", nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestIniLoader_DeleteProfile(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		file := newWriterConfig(t)
+
+		if err := DefaultIniLoader.DeleteProfile("dev"); err != nil {
+			t.Fatal(err)
+		}
+
+		data := readWriterFile(t, file)
+		if strings.Contains(data, "[profile dev]") || !strings.Contains(data, "[legacy]") {
+			t.Errorf("unexpected config:\n%s", data)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		newWriterConfig(t)
+
+		if err := DefaultIniLoader.DeleteProfile("missing"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestIniLoader_SetCredentials(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "credentials")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", file)
+
+	t.Run("set", func(t *testing.T) {
+		values := map[string]string{"saml_password": "secret", "web_identity_password": "other"}
+		if err := DefaultIniLoader.SetCredentials("https://idp.local/saml", values); err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := DefaultIniLoader.Credentials("https://idp.local/saml", file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.SamlPassword != "secret" || c.WebIdentityPassword != "other" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("remove key", func(t *testing.T) {
+		if err := DefaultIniLoader.RemoveCredentials("https://idp.local/saml", "web_identity_password"); err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := DefaultIniLoader.Credentials("https://idp.local/saml", file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.SamlPassword != "secret" || len(c.WebIdentityPassword) > 0 {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("remove section", func(t *testing.T) {
+		if err := DefaultIniLoader.RemoveCredentials("https://idp.local/saml"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := DefaultIniLoader.Credentials("https://idp.local/saml", file); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("remove missing", func(t *testing.T) {
+		if err := DefaultIniLoader.RemoveCredentials("missing"); err != nil {
+			t.Error(err)
+		}
+	})
+}
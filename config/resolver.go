@@ -13,7 +13,13 @@
 
 package config
 
-import "github.com/mmmorris1975/aws-runas/shared"
+import (
+	"errors"
+
+	"github.com/mmmorris1975/aws-runas/shared"
+)
+
+var errReadOnly = errors.New("configuration is read-only, no writer configured")
 
 type resolver struct {
 	loader     Loader
@@ -21,6 +27,7 @@ type resolver struct {
 	defCreds   *AwsCredentials
 	config     *AwsConfig
 	creds      *AwsCredentials
+	writer     Writer
 	resolveSrc bool
 }
 
@@ -41,6 +48,13 @@ func (r *resolver) WithLogger(l shared.Logger) *resolver {
 	return r
 }
 
+// WithWriter is a fluent method for setting the Writer used to save configuration and credential changes.  Without a
+// Writer, the resolver is read-only and the Writer methods return an error.
+func (r *resolver) WithWriter(w Writer) *resolver {
+	r.writer = w
+	return r
+}
+
 // WithDefaultConfig is a fluent method for setting an initial/default configuration object, which will be used as the
 // base configuration for any calls to Config().
 func (r *resolver) WithDefaultConfig(config *AwsConfig) *resolver {
@@ -81,6 +95,8 @@ func (r *resolver) MergeCredentials(creds ...*AwsCredentials) *AwsCredentials {
 
 // Config is the implementation of the Resolver interface to build a coherent AwsConfig object.
 func (r *resolver) Config(profile string) (*AwsConfig, error) {
+	profile = r.resolveAlias(profile)
+
 	c, err := r.loader.Config(profile)
 	if err != nil {
 		return nil, err
@@ -100,6 +116,8 @@ func (r *resolver) Config(profile string) (*AwsConfig, error) {
 
 // Credentials is the implementation of the Resolver interface to build a coherent AwsCredentials object.
 func (r resolver) Credentials(profile string) (*AwsCredentials, error) {
+	profile = r.resolveAlias(profile)
+
 	c, err := r.loader.Credentials(profile)
 	if err != nil {
 		return nil, err
@@ -111,3 +129,71 @@ func (r resolver) Credentials(profile string) (*AwsCredentials, error) {
 
 	return r.creds, nil
 }
+
+// ResolveAlias is the implementation of the AliasResolver interface to look up a profile name using one of its
+// aliases.  If the resolver's Loader does not support profile aliases, alias is returned unchanged.
+func (r *resolver) ResolveAlias(alias string, sources ...any) (string, error) {
+	if ar, ok := r.loader.(AliasResolver); ok {
+		return ar.ResolveAlias(alias, sources...)
+	}
+	return alias, nil
+}
+
+// resolveAlias returns the profile name for alias, or alias itself if it can not be resolved.  Errors are not fatal
+// here, since the same problem will be reported when the configuration is loaded.
+func (r *resolver) resolveAlias(alias string) string {
+	name, err := r.ResolveAlias(alias)
+	if err != nil {
+		logger.Debugf("error resolving profile alias: %v", err)
+		return alias
+	}
+	return name
+}
+
+// CreateProfile is the implementation of the Writer interface to add a new profile to the configuration.
+func (r *resolver) CreateProfile(profile string, values map[string]string) error {
+	if r.writer == nil {
+		return errReadOnly
+	}
+	return r.writer.CreateProfile(profile, values)
+}
+
+// DeleteProfile is the implementation of the Writer interface to remove a profile from the configuration.
+func (r *resolver) DeleteProfile(profile string) error {
+	if r.writer == nil {
+		return errReadOnly
+	}
+	return r.writer.DeleteProfile(profile)
+}
+
+// SetConfig is the implementation of the Writer interface to set configuration values for a profile.
+func (r *resolver) SetConfig(profile string, values map[string]string) error {
+	if r.writer == nil {
+		return errReadOnly
+	}
+	return r.writer.SetConfig(profile, values)
+}
+
+// RemoveConfig is the implementation of the Writer interface to remove configuration values from a profile.
+func (r *resolver) RemoveConfig(profile string, keys ...string) error {
+	if r.writer == nil {
+		return errReadOnly
+	}
+	return r.writer.RemoveConfig(profile, keys...)
+}
+
+// SetCredentials is the implementation of the Writer interface to set credential values for a profile.
+func (r *resolver) SetCredentials(profile string, values map[string]string) error {
+	if r.writer == nil {
+		return errReadOnly
+	}
+	return r.writer.SetCredentials(profile, values)
+}
+
+// RemoveCredentials is the implementation of the Writer interface to remove credential values for a profile.
+func (r *resolver) RemoveCredentials(profile string, keys ...string) error {
+	if r.writer == nil {
+		return errReadOnly
+	}
+	return r.writer.RemoveCredentials(profile, keys...)
+}
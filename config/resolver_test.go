@@ -174,3 +174,43 @@ func TestResolver_MergeCredentials(t *testing.T) {
 		t.Error("data mismatch")
 	}
 }
+
+func TestResolver_Writer(t *testing.T) {
+	t.Run("read-only", func(t *testing.T) {
+		r := NewResolver(nil, false)
+
+		if err := r.SetConfig("p", map[string]string{"region": "x"}); err == nil {
+			t.Error("did not receive expected error")
+		}
+
+		if err := r.RemoveCredentials("p"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("writer", func(t *testing.T) {
+		file := newWriterConfig(t)
+		r := NewResolver(DefaultIniLoader, false).WithWriter(DefaultIniLoader)
+
+		if err := r.CreateProfile("prod", map[string]string{"role_arn": "arn:aws:iam::0123456789:role/Prod"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := r.SetConfig("prod", map[string]string{"region": "eu-west-1"}); err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := DefaultIniLoader.Config("prod", file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.Region != "eu-west-1" || len(c.RoleArn) < 1 {
+			t.Error("data mismatch")
+		}
+
+		if err = r.DeleteProfile("prod"); err != nil {
+			t.Error(err)
+		}
+	})
+}
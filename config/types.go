@@ -23,8 +23,9 @@ var (
 	// ChainLoader configured with the DefaultLoaderChain.
 	DefaultLoader = NewChainLoader(DefaultLoaderChain)
 	// DefaultResolver is the default resolution object for building configuration and credential information.  It uses
-	// the DefaultLoader, and will apply values from any source profile to the configuration.
-	DefaultResolver = NewResolver(DefaultLoader, true)
+	// the DefaultLoader, and will apply values from any source profile to the configuration.  Changes made using the
+	// Writer methods are saved to the default AWS configuration and credentials files.
+	DefaultResolver = NewResolver(DefaultLoader, true).WithWriter(DefaultIniLoader)
 
 	logger shared.Logger = new(shared.DefaultLogger)
 )
@@ -43,8 +44,31 @@ type Loader interface {
 	Credentials(profile string, sources ...any) (*AwsCredentials, error)
 }
 
+// AliasResolver defines the method for looking up the name of a profile using one of its aliases.
+type AliasResolver interface {
+	ResolveAlias(alias string, sources ...any) (string, error)
+}
+
 // Resolver defines the methods for retrieving configuration and credential information using profile names.
 type Resolver interface {
 	Config(profile string) (*AwsConfig, error)
 	Credentials(profile string) (*AwsCredentials, error)
 }
+
+// Writer defines the methods for changing configuration and credential information using profile names.  Changes are
+// applied to the existing data, preserving any comments and the order of profiles and settings.
+type Writer interface {
+	CreateProfile(profile string, values map[string]string) error
+	DeleteProfile(profile string) error
+	SetConfig(profile string, values map[string]string) error
+	RemoveConfig(profile string, keys ...string) error
+	SetCredentials(profile string, values map[string]string) error
+	RemoveCredentials(profile string, keys ...string) error
+}
+
+// WritableResolver defines the methods for a Resolver which can also change the configuration and credential
+// information it resolves.
+type WritableResolver interface {
+	Resolver
+	Writer
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gopkg.in/ini.v1"
+)
+
+// awsConfigKeys are the configuration keys supported by the AWS SDKs and CLI which aws-runas does not use itself.
+var awsConfigKeys = []string{
+	"account_id_endpoint_mode", "api_versions", "auth_scheme_preference", "aws_access_key_id", "aws_account_id",
+	"aws_secret_access_key", "aws_session_token", "ca_bundle", "cli_auto_prompt", "cli_binary_format",
+	"cli_follow_urlparam", "cli_history", "cli_pager", "cli_timestamp_format", "credential_process",
+	"credential_source", "defaults_mode", "disable_request_compression", "dynamodb", "ec2_metadata_service_endpoint",
+	"ec2_metadata_service_endpoint_mode", "ec2_metadata_v1_disabled", "endpoint_url",
+	"ignore_configure_endpoint_urls", "max_attempts", "metadata_service_num_attempts", "metadata_service_timeout",
+	"output", "parameter_validation", "request_checksum_calculation", "request_min_compression_size_bytes",
+	"response_checksum_validation", "retry_mode", "s3", "sdk_ua_app_id", "services", "sigv4a_signing_region_set",
+	"sso_account_id", "sso_region", "sso_registration_scopes", "sso_role_name", "sso_session", "sso_start_url",
+	"sts_regional_endpoints", "tcp_keepalive", "use_dualstack_endpoint", "use_fips_endpoint",
+}
+
+var (
+	knownKeys      map[string]bool
+	runasPrefixes  map[string]bool
+	knownKeysOnce  sync.Once
+	warnedKeys     sync.Map
+	maxKeyDistance = 2
+)
+
+// loadKnownKeys builds the set of known configuration keys from the ini tags of the AwsConfig type, and the list of
+// AWS SDK keys.  The prefixes (the part before the first '_') of the aws-runas keys are also collected, so unknown keys
+// which look like they are meant for aws-runas can be reported.
+func loadKnownKeys() {
+	knownKeys = make(map[string]bool)
+	runasPrefixes = make(map[string]bool)
+
+	for _, k := range awsConfigKeys {
+		knownKeys[k] = true
+	}
+
+	runas := make([]string, 0)
+	t := reflect.TypeOf(AwsConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("ini"), ",")
+		if len(tag) > 0 && tag != "-" && !knownKeys[tag] {
+			runas = append(runas, tag)
+		}
+	}
+
+	for _, k := range runas {
+		knownKeys[k] = true
+		if p, _, ok := strings.Cut(k, "_"); ok {
+			runasPrefixes[p] = true
+		}
+	}
+}
+
+// warnUnknownKeys logs a warning for each key in the section which is not a known configuration key, but is close to
+// one or looks like an aws-runas setting.  Each key is only reported once.
+func warnUnknownKeys(s *ini.Section, sources []any) {
+	for _, w := range unknownKeyWarnings(s, sources) {
+		if _, loaded := warnedKeys.LoadOrStore(w, true); !loaded {
+			logger.Warningf("%s", w)
+		}
+	}
+}
+
+// unknownKeyWarnings returns the warning messages for the unknown keys in the section.  A message includes the closest
+// known key as a suggestion, and the file and line of the key, if it can be found in the sources.
+func unknownKeyWarnings(s *ini.Section, sources []any) []string {
+	knownKeysOnce.Do(loadKnownKeys)
+
+	msgs := make([]string, 0)
+	for _, k := range s.KeyStrings() {
+		name := strings.ToLower(k)
+		if knownKeys[name] {
+			continue
+		}
+
+		suggest := suggestKey(name)
+		prefix, _, _ := strings.Cut(name, "_")
+		if len(suggest) < 1 && !runasPrefixes[prefix] {
+			// most likely a setting for some other tool
+			continue
+		}
+
+		msg := fmt.Sprintf("unknown configuration key '%s' in section [%s]", k, s.Name())
+		if loc := keyLocation(s.Name(), k, sources); len(loc) > 0 {
+			msg = fmt.Sprintf("%s (%s)", msg, loc)
+		}
+
+		if len(suggest) > 0 {
+			msg = fmt.Sprintf("%s, did you mean '%s'?", msg, suggest)
+		} else {
+			msg += ", the setting is ignored"
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+// suggestKey returns the known key closest to the provided key, if it is a near-miss.
+func suggestKey(key string) string {
+	if len(key) < 4 {
+		return ""
+	}
+
+	var best string
+	bestDist := maxKeyDistance + 1
+	for k := range knownKeys {
+		if d := editDistance(key, k); d < bestDist || (d == bestDist && k < best) {
+			best = k
+			bestDist = d
+		}
+	}
+
+	if bestDist > maxKeyDistance {
+		return ""
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// keyLocation returns the file name (or "config data", for in-memory sources) and line number of the key in the
+// section, using the last source which has the key.  An empty string is returned if the key can not be found.
+func keyLocation(section, key string, sources []any) string {
+	var loc string
+	for _, src := range sources {
+		var name string
+		var data []byte
+
+		switch v := src.(type) {
+		case string:
+			name = v
+			data, _ = os.ReadFile(v)
+		case []byte:
+			name = "config data"
+			data = v
+		default:
+			continue
+		}
+
+		if line := findKeyLine(data, section, key); line > 0 {
+			loc = fmt.Sprintf("%s:%d", name, line)
+		}
+	}
+	return loc
+}
+
+// findKeyLine returns the line number of the key in the section of the ini data, or 0 if it's not found.
+func findKeyLine(data []byte, section, key string) int {
+	var cur string
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			cur = strings.TrimSpace(line[1 : len(line)-1])
+		case cur == section:
+			if k, _, ok := strings.Cut(line, "="); ok && strings.TrimSpace(k) == key {
+				return n
+			}
+		}
+	}
+	return 0
+}
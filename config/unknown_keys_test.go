@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+var unknownKeyConfig = []byte(`[default]
+region = us-east-1
+
+[profile typo]
+role_arn = arn:aws:iam::0123456789:role/Admin
+saml_usernme = bob
+output = json
+cli_pager =
+my_tool_setting = x
+cache_flavor = redis
+`)
+
+func TestUnknownKeyWarnings(t *testing.T) {
+	f, err := ini.Load(unknownKeyConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("known keys", func(t *testing.T) {
+		if w := unknownKeyWarnings(f.Section("default"), nil); len(w) > 0 {
+			t.Errorf("unexpected warnings: %v", w)
+		}
+	})
+
+	t.Run("unknown keys", func(t *testing.T) {
+		w := unknownKeyWarnings(f.Section("profile typo"), []any{unknownKeyConfig})
+		if len(w) != 2 {
+			t.Fatalf("unexpected warnings: %v", w)
+		}
+
+		if w[0] != "unknown configuration key 'saml_usernme' in section [profile typo] (config data:6), did you mean 'saml_username'?" {
+			t.Errorf("unexpected warning: %s", w[0])
+		}
+
+		if !strings.HasPrefix(w[1], "unknown configuration key 'cache_flavor'") || !strings.HasSuffix(w[1], "the setting is ignored") {
+			t.Errorf("unexpected warning: %s", w[1])
+		}
+	})
+}
+
+func TestSuggestKey(t *testing.T) {
+	knownKeysOnce.Do(loadKnownKeys)
+
+	tests := map[string]string{
+		"web_identity_clientid": "web_identity_client_id",
+		"regoin":                "region",
+		"jump_rol_arn":          "jump_role_arn",
+		"my_tool_setting":       "",
+		"rgn":                   "",
+	}
+
+	for k, v := range tests {
+		t.Run(k, func(t *testing.T) {
+			if s := suggestKey(k); s != v {
+				t.Errorf("unexpected suggestion %s", s)
+			}
+		})
+	}
+}
+
+func TestKeyLocation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(file, unknownKeyConfig, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("found", func(t *testing.T) {
+		if loc := keyLocation("profile typo", "output", []any{file}); loc != file+":7" {
+			t.Errorf("unexpected location %s", loc)
+		}
+	})
+
+	t.Run("wrong section", func(t *testing.T) {
+		if loc := keyLocation("default", "output", []any{file}); len(loc) > 0 {
+			t.Errorf("unexpected location %s", loc)
+		}
+	})
+
+	t.Run("last source wins", func(t *testing.T) {
+		if loc := keyLocation("default", "region", []any{file, []byte("[default]\n\nregion=x\n")}); loc != "config data:3" {
+			t.Errorf("unexpected location %s", loc)
+		}
+	})
+}
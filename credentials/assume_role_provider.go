@@ -17,6 +17,9 @@ import (
 	"context"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/mmmorris1975/aws-runas/shared"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"time"
 )
 
@@ -50,6 +53,7 @@ func NewAssumeRoleProvider(cfg aws.Config, roleArn string) *AssumeRoleProvider {
 	}
 	p.Duration = AssumeRoleDurationDefault
 	p.ExpiryWindow = -1
+	p.RoleMaxDuration = iamRoleMaxDuration(cfg)
 
 	return p
 }
@@ -59,13 +63,19 @@ func NewAssumeRoleProvider(cfg aws.Config, roleArn string) *AssumeRoleProvider {
 // consulted to load the credentials.  If the credentials are expired, the credentials will be refreshed (prompting for
 // MFA, if necessary), and stored back in the cache.
 func (p *AssumeRoleProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	ctx, span := shared.StartSpan(ctx, "AssumeRoleProvider.Retrieve")
+	defer span.End()
+
 	var err error
 	creds := p.CheckCache()
+	span.SetAttributes(attribute.Bool("cache.hit", creds != nil && !creds.Value().Expired()))
 
 	if creds == nil || creds.Value().Expired() {
 		p.Logger.Debugf("Detected expired or unset assume role credentials, refreshing")
 		creds, err = p.retrieve(ctx)
 		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 			return aws.Credentials{}, err
 		}
 
@@ -86,30 +96,35 @@ func (p *AssumeRoleProvider) Retrieve(ctx context.Context) (aws.Credentials, err
 	v := creds.Value()
 	v.Source = AssumeRoleProviderName
 
-	p.Logger.Debugf("ASSUME ROLE CREDENTIALS: %+v", v)
+	p.Logger.Debugf("ASSUME ROLE CREDENTIALS: %+v", redactCredentials(v))
 	return v, nil
 }
 
 func (p AssumeRoleProvider) retrieve(ctx context.Context) (*Credentials, error) {
-	in, err := p.getAssumeRoleInput()
+	in, err := p.getAssumeRoleInput(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	ctx, span := shared.StartSpan(ctx, "sts.AssumeRole")
 	out, err := p.Client.AssumeRole(ctx, in)
+	if d, ok := p.maxDuration(ctx, err, p.RoleArn, in.DurationSeconds); ok {
+		in.DurationSeconds = d
+		out, err = p.Client.AssumeRole(ctx, in)
+	}
+	shared.EndSpan(span, err)
 	if err != nil {
 		return nil, err
 	}
 
-	if p.ExpiryWindow < 1 {
-		p.ExpiryWindow = p.Duration / 10
-	}
+	p.updateExpiryWindow(in.DurationSeconds)
 
 	c := FromStsCredentials(out.Credentials)
+	c.Expiration = p.localExpiration(out.ResultMetadata, c.Expiration)
 	return c, nil
 }
 
-func (p AssumeRoleProvider) getAssumeRoleInput() (*sts.AssumeRoleInput, error) {
+func (p AssumeRoleProvider) getAssumeRoleInput(ctx context.Context) (*sts.AssumeRoleInput, error) {
 	in := &sts.AssumeRoleInput{
 		DurationSeconds: p.ConvertDuration(p.Duration, AssumeRoleDurationMin, AssumeRoleDurationMax, AssumeRoleDurationDefault),
 		RoleArn:         aws.String(p.RoleArn),
@@ -124,7 +139,7 @@ func (p AssumeRoleProvider) getAssumeRoleInput() (*sts.AssumeRoleInput, error) {
 		in.ExternalId = aws.String(p.ExternalId)
 	}
 
-	code, err := p.handleMfa()
+	code, err := p.handleMfa(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -15,8 +15,13 @@ package credentials
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
 	"github.com/mmmorris1975/aws-runas/shared"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -126,6 +131,164 @@ func TestAssumeRoleProvider_Retrieve(t *testing.T) {
 	})
 }
 
+func TestAssumeRoleProvider_Retrieve_MaxDuration(t *testing.T) {
+	for _, role := range []string{"mockRole-1h", "mockRole-chained"} {
+		t.Run(role, func(t *testing.T) {
+			p := newAssumeRoleProvider()
+			p.RoleArn = role
+			p.Duration = 8 * time.Hour
+
+			v, err := p.Retrieve(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !v.HasKeys() || v.Expires.After(time.Now().Add(time.Hour)) {
+				t.Errorf("invalid credentials, expires %s", v.Expires)
+			}
+		})
+	}
+
+	t.Run("other error", func(t *testing.T) {
+		p := newAssumeRoleProvider()
+		if _, ok := p.maxDuration(context.Background(), errors.New("InvalidParameter"), p.RoleArn, aws.Int32(28800)); ok {
+			t.Error("unexpected retry")
+		}
+	})
+
+	t.Run("already at max", func(t *testing.T) {
+		p := newAssumeRoleProvider()
+		err := &smithy.GenericAPIError{Code: "ValidationError",
+			Message: "The requested DurationSeconds exceeds the MaxSessionDuration set for this role."}
+
+		if _, ok := p.maxDuration(context.Background(), err, p.RoleArn, aws.Int32(3600)); ok {
+			t.Error("unexpected retry")
+		}
+	})
+
+	t.Run("role max duration", func(t *testing.T) {
+		p := newAssumeRoleProvider()
+		p.RoleMaxDuration = func(_ context.Context, role string) (time.Duration, error) {
+			if role != p.RoleArn {
+				return 0, errors.New("unexpected role")
+			}
+			return 4 * time.Hour, nil
+		}
+		err := &smithy.GenericAPIError{Code: "ValidationError",
+			Message: "The requested DurationSeconds exceeds the MaxSessionDuration set for this role."}
+
+		d, ok := p.maxDuration(context.Background(), err, p.RoleArn, aws.Int32(28800))
+		if !ok || *d != 14400 || p.Duration != 4*time.Hour {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("role max duration error", func(t *testing.T) {
+		p := newAssumeRoleProvider()
+		p.RoleMaxDuration = func(context.Context, string) (time.Duration, error) {
+			return 0, errors.New("AccessDenied")
+		}
+		err := &smithy.GenericAPIError{Code: "ValidationError",
+			Message: "The requested DurationSeconds exceeds the MaxSessionDuration set for this role."}
+
+		if d, ok := p.maxDuration(context.Background(), err, p.RoleArn, aws.Int32(28800)); !ok || *d != 3600 {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("expiry window", func(t *testing.T) {
+		p := newAssumeRoleProvider()
+		p.RoleArn = "mockRole-1h"
+		p.Duration = 8 * time.Hour
+
+		if _, err := p.Retrieve(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		// the window follows the duration of the issued credentials, not the requested duration
+		if p.ExpiryWindow != 6*time.Minute {
+			t.Errorf("unexpected expiry window %s", p.ExpiryWindow)
+		}
+	})
+}
+
+func TestIamRoleMaxDuration(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.Form.Get("Action") != "GetRole" || r.Form.Get("RoleName") != "Role" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = fmt.Fprint(w, `<GetRoleResponse xmlns="https://iam.amazonaws.com/doc/2010-05-08/">
+<GetRoleResult><Role><RoleName>Role</RoleName><MaxSessionDuration>14400</MaxSessionDuration></Role></GetRoleResult>
+</GetRoleResponse>`)
+	}))
+	defer srv.Close()
+
+	f := iamRoleMaxDuration(aws.Config{
+		Region:       "us-east-1",
+		Credentials:  aws.AnonymousCredentials{},
+		BaseEndpoint: aws.String(srv.URL),
+	})
+
+	t.Run("good", func(t *testing.T) {
+		d, err := f(context.Background(), "arn:aws:iam::123456789012:role/path/Role")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if d != 4*time.Hour {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("bad arn", func(t *testing.T) {
+		if _, err := f(context.Background(), "Role"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestAssumeRoleProvider_Retrieve_ClockSkew(t *testing.T) {
+	exp := time.Now().Add(1 * time.Hour).UTC().Truncate(time.Second)
+
+	// server clock is 10 minutes ahead of the local clock
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(10*time.Minute).UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+<AssumeRoleResult><Credentials><AccessKeyId>AKIAMOCK</AccessKeyId><SecretAccessKey>mockSK</SecretAccessKey>
+<SessionToken>mockST</SessionToken><Expiration>%s</Expiration></Credentials></AssumeRoleResult>
+</AssumeRoleResponse>`, exp.Format(time.RFC3339))
+	}))
+	defer srv.Close()
+	defer shared.RecordServerTime(time.Now(), time.Now())
+
+	cfg := aws.Config{
+		Region:       "us-east-1",
+		Credentials:  aws.AnonymousCredentials{},
+		BaseEndpoint: aws.String(srv.URL),
+	}
+
+	p := NewAssumeRoleProvider(cfg, "arn:aws:iam::123456789012:role/Role")
+	p.RoleSessionName = "mock"
+
+	v, err := p.Retrieve(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// allow a few seconds for the Date header resolution and test execution time
+	want := exp.Add(-10 * time.Minute)
+	if d := v.Expires.Sub(want); d < -5*time.Second || d > 5*time.Second {
+		t.Errorf("expiration not adjusted for clock skew, got %s, want %s", v.Expires, want)
+	}
+}
+
 func TestAssumeRoleProvider_Retrieve_Mfa(t *testing.T) {
 	t.Run("good code", func(t *testing.T) {
 		p := newAssumeRoleProvider()
@@ -14,14 +14,28 @@
 package credentials
 
 import (
+	"context"
+	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
 	"github.com/mmmorris1975/aws-runas/credentials/helpers"
 	"github.com/mmmorris1975/aws-runas/shared"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// sessionLimitRe finds the session duration limit in the error message returned by AWS when the requested duration
+// is too long for a chained role session.
+var sessionLimitRe = regexp.MustCompile(`(\d+) hour session limit`)
+
 type baseStsProvider struct {
 	Client        stsApi
 	Cache         CredentialCacher
@@ -31,6 +45,10 @@ type baseStsProvider struct {
 	SerialNumber  string
 	TokenCode     string
 	TokenProvider func() (string, error)
+	// RoleMaxDuration looks up the maximum session duration of a role, when the requested duration is too long for the
+	// role.  If nil, or the lookup fails, the duration falls back to AssumeRoleDurationDefault.
+	RoleMaxDuration func(ctx context.Context, roleArn string) (time.Duration, error)
+	autoWindow      bool // the ExpiryWindow was derived from the credential duration
 }
 
 func newBaseStsProvider(cfg aws.Config) *baseStsProvider {
@@ -78,7 +96,7 @@ func (p *baseStsProvider) ConvertDuration(d, min, max, def time.Duration) *int32
 	return aws.Int32(int32(d.Seconds()))
 }
 
-func (p *baseStsProvider) handleMfa() (*string, error) {
+func (p *baseStsProvider) handleMfa(ctx context.Context) (*string, error) {
 	if len(p.SerialNumber) > 0 {
 		if len(p.TokenCode) > 0 {
 			return aws.String(p.TokenCode), nil
@@ -86,7 +104,9 @@ func (p *baseStsProvider) handleMfa() (*string, error) {
 
 		// prompt for mfa
 		if p.TokenProvider != nil {
+			_, span := shared.StartSpan(ctx, "mfa.wait")
 			t, err := p.TokenProvider()
+			shared.EndSpan(span, err)
 			if err != nil {
 				return nil, err
 			}
@@ -99,3 +119,93 @@ func (p *baseStsProvider) handleMfa() (*string, error) {
 	// mfa not required
 	return nil, nil
 }
+
+// maxDuration checks if the error from an Assume Role operation was caused by the requested duration exceeding the
+// maximum session duration of the role.  If so, the maximum allowed duration is returned, along with true to signal
+// that the operation should be retried with the new duration.  The limit for chained role sessions is taken from the
+// error message, otherwise the role's MaxSessionDuration is looked up using RoleMaxDuration.  The default duration is
+// used if neither is available, since every role allows sessions of at least that long.
+func (p *baseStsProvider) maxDuration(ctx context.Context, err error, roleArn string, requested *int32) (*int32, bool) {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || !strings.Contains(apiErr.ErrorMessage(), "DurationSeconds exceeds") {
+		return nil, false
+	}
+
+	limit := AssumeRoleDurationDefault
+	if m := sessionLimitRe.FindStringSubmatch(apiErr.ErrorMessage()); m != nil {
+		if h, e := strconv.Atoi(m[1]); e == nil && h > 0 {
+			limit = time.Duration(h) * time.Hour
+		}
+	} else if p.RoleMaxDuration != nil {
+		if d, e := p.RoleMaxDuration(ctx, roleArn); e == nil && d > 0 {
+			limit = d
+		} else {
+			p.Logger.Debugf("unable to look up the maximum session duration of the role: %v", e)
+		}
+	}
+
+	d := p.ConvertDuration(limit, AssumeRoleDurationMin, AssumeRoleDurationMax, AssumeRoleDurationDefault)
+	if requested != nil && *requested <= *d {
+		// we're already asking for no more than the max, retrying won't help
+		return nil, false
+	}
+
+	p.Logger.Warningf("requested credential duration exceeds the maximum allowed for the role, retrying with %s. "+
+		"Consider setting a shorter credentials_duration for the profile", limit)
+
+	// keep the allowed duration, so later refreshes don't repeat the failed request
+	p.Duration = time.Duration(*d) * time.Second
+	return d, true
+}
+
+// updateExpiryWindow sets the ExpiryWindow to 10% of the duration of the credentials, unless a window was explicitly
+// configured.  This is called after the credentials are issued, so the window follows any change to the duration.
+func (p *baseStsProvider) updateExpiryWindow(seconds *int32) {
+	if p.ExpiryWindow < 1 || p.autoWindow {
+		p.ExpiryWindow = time.Duration(aws.ToInt32(seconds)) * time.Second / 10
+		p.autoWindow = true
+	}
+}
+
+// iamRoleMaxDuration returns a function which calls the IAM GetRole API, using the credentials in cfg, to find the
+// MaxSessionDuration of a role.
+func iamRoleMaxDuration(cfg aws.Config) func(context.Context, string) (time.Duration, error) {
+	return func(ctx context.Context, roleArn string) (time.Duration, error) {
+		r, err := arn.Parse(roleArn)
+		if err != nil {
+			return 0, err
+		}
+
+		name := r.Resource[strings.LastIndex(r.Resource, "/")+1:]
+		out, err := iam.NewFromConfig(cfg).GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(name)})
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(aws.ToInt32(out.Role.MaxSessionDuration)) * time.Second, nil
+	}
+}
+
+// localExpiration records the clock skew found in the metadata of an AWS API response, and returns the credential
+// expiration time adjusted to the local clock.  A warning is logged if the local clock is significantly off, since
+// this can cause confusing signature and expired token errors.
+func (p *baseStsProvider) localExpiration(md middleware.Metadata, exp time.Time) time.Time {
+	if serverTime, ok := awsmiddleware.GetServerTime(md); ok {
+		at, ok := awsmiddleware.GetResponseAt(md)
+		if !ok {
+			at = time.Now()
+		}
+
+		if skew, significant := shared.RecordServerTime(serverTime, at); significant {
+			p.Logger.Warningf("local clock differs from AWS by %s, credential expiration will be adjusted. "+
+				"Check the system time settings", skew.Round(time.Second))
+		}
+	}
+	return shared.LocalTime(exp)
+}
+
+// redactCredentials returns a copy of the credentials with the secret values redacted, for use in log output.
+func redactCredentials(v aws.Credentials) aws.Credentials {
+	v.SecretAccessKey = shared.Redact(v.SecretAccessKey)
+	v.SessionToken = shared.Redact(v.SessionToken)
+	return v
+}
@@ -15,6 +15,7 @@ package cache
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"golang.org/x/net/publicsuffix"
 	"net/http"
@@ -24,12 +25,19 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
-var cjMap map[string]*cookieJar
+var (
+	cjMap map[string]*cookieJar
+	cjMu  sync.Mutex
+)
 
 // CookieJar provides a file-backed cookie jar implementation at the specified path.
 var CookieJar = func(path string) *cookieJar {
+	cjMu.Lock()
+	defer cjMu.Unlock()
+
 	if cjMap == nil {
 		cjMap = make(map[string]*cookieJar)
 	}
@@ -54,8 +62,9 @@ type cookieJar struct {
 }
 
 // force public access through CookieJar() so we have better safety for concurrent access to individual files
-// for cases where multiple calls to CookieJar() with the same path are made within the same process.  Doesn't
-// solve cases where distinct processes use the same CookieJar(), we need to rely on our file handling logic for that.
+// for cases where multiple calls to CookieJar() with the same path are made within the same process.  Distinct
+// processes using the same file are handled by flush(), which holds a lock file while merging its cookies with those
+// in the file.
 func newCookieJar(path string) (*cookieJar, error) {
 	// ensure all intermediate directories exist
 	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
@@ -85,7 +94,10 @@ func (c *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
 	defer c.mu.Unlock()
 
 	c.jar.SetCookies(u, cookies)
-	_ = c.flush(u, cookies) // todo handle (log?) error
+	if err := c.flush(u, cookies); err != nil {
+		// the cookies are still usable by this process, they just won't be available to the next one
+		log().Warningf("unable to save cookies to %s: %v", c.path, err)
+	}
 }
 
 // Cookies is the implementation of the http.CookieJar interface to retrieve cookies from the cache.
@@ -123,7 +135,24 @@ func (c *cookieJar) loadCache() error {
 }
 
 // WARNING - be sure to Lock() before calling this method.
-func (c *cookieJar) flush(u *url.URL, cookies []*http.Cookie) error {
+func (c *cookieJar) flush(u *url.URL, cookies []*http.Cookie) (err error) {
+	if fi, e := os.Stat(c.path); e == nil && !fi.Mode().IsRegular() {
+		// things like os.DevNull are used to disable the file storage, never replace them with a regular file
+		return nil
+	}
+
+	// other processes may update the file at any time, so hold the lock file from reading the current cookies until
+	// the merged cookies are written, otherwise the cookies saved by the other process are lost
+	lf, err := lockFile(c.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("unable to lock cookie file: %w", err)
+	}
+	defer func() {
+		if e := unlockFile(lf); e != nil && err == nil {
+			err = e
+		}
+	}()
+
 	// we'll need this read-before-update step to ensure we have a complete view of the cookies, since we can't
 	// dump the entire in-memory jar (details hidden)
 	cache, err := readCache(c.path)
@@ -132,16 +161,46 @@ func (c *cookieJar) flush(u *url.URL, cookies []*http.Cookie) error {
 	}
 
 	key := fmt.Sprintf("%s://%s", u.Scheme, u.Hostname())
-	cache[key] = merge(cache[key], cookies)
+	cache[key] = merge(cache[key], cookies, time.Now())
 
-	return writeCache(c.path, cache)
+	if err = writeCache(c.path, cache); err != nil {
+		return err
+	}
+
+	// pick up the cookies saved by other processes since this jar was loaded, like a fresh identity provider session
+	for k, v := range cache {
+		if k == key {
+			continue
+		}
+
+		if cu, e := url.Parse(k); e == nil {
+			c.jar.SetCookies(cu, v)
+		}
+	}
+	return nil
 }
 
-func merge(src []*http.Cookie, new []*http.Cookie) []*http.Cookie {
+// merge returns the cookies in src updated with the cookies in new.  Expired cookies, and cookies deleted using a
+// negative MaxAge, are dropped.  A positive MaxAge is converted to an expiration time, since the cookie would otherwise
+// be given a new lifetime each time the file is loaded.
+func merge(src []*http.Cookie, new []*http.Cookie, now time.Time) []*http.Cookie {
 	mergeMap := make(map[string]*http.Cookie, len(src))
 
 	for _, v := range append(src, new...) {
-		mergeMap[strings.Join([]string{v.Name, v.Domain, v.Path}, `|`)] = v
+		k := strings.Join([]string{v.Name, v.Domain, v.Path}, `|`)
+
+		if v.MaxAge > 0 {
+			nc := *v
+			nc.Expires = now.Add(time.Duration(v.MaxAge) * time.Second)
+			nc.MaxAge = 0
+			v = &nc
+		}
+
+		if v.MaxAge < 0 || (!v.Expires.IsZero() && !v.Expires.After(now)) {
+			delete(mergeMap, k)
+			continue
+		}
+		mergeMap[k] = v
 	}
 
 	i := 0
@@ -168,8 +227,18 @@ func readCache(path string) (map[string][]*http.Cookie, error) {
 	}
 
 	if len(data) > 2 {
-		// this is non-fatal, just rewrite a fresh cache without the old data
-		_ = json.Unmarshal(data, &cookies)
+		// this is non-fatal, move the bad file aside and rewrite a fresh cache without the old data
+		var raw json.RawMessage
+		if raw, err = decodeCache(data); err == nil {
+			err = json.Unmarshal(raw, &cookies)
+		}
+
+		if err != nil {
+			if !errors.Is(err, errUnreadableCache) {
+				quarantine(path, err.Error())
+			}
+			cookies = make(map[string][]*http.Cookie)
+		}
 	}
 
 	return cookies, nil
@@ -187,10 +256,21 @@ func writeCache(path string, data map[string][]*http.Cookie) error {
 
 	// this should never return an error, all code paths to get here will have valid/serializable 'data'
 	// anything causing an error here is probably a panic-level issue
-	_ = json.NewEncoder(tmp).Encode(data)
+	b, _ := encodeCache(data)
+
+	// a short write (like a full disk) must not replace the existing file with a truncated one
+	if _, err = tmp.Write(b); err == nil {
+		err = tmp.Sync()
+	}
 
 	// close file before rename to keep Windows file handling happy
-	_ = tmp.Close()
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+
+	if err != nil {
+		return err
+	}
 
 	err = os.Rename(tmp.Name(), path)
 	if err == nil {
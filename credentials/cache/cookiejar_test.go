@@ -21,7 +21,10 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestCookieJar(t *testing.T) {
@@ -213,6 +216,119 @@ func TestCookieJar_SetCookies_Errors(t *testing.T) {
 	})
 }
 
+func TestCookieJar_SetCookies_Shared(t *testing.T) {
+	// distinct jars using the same file behave like separate processes
+	f := filepath.Join(t.TempDir(), "cookies")
+
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			j, err := newCookieJar(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				u, _ := url.Parse(fmt.Sprintf("https://idp%d.example.org", i))
+				j.SetCookies(u, []*http.Cookie{{Name: "session", Value: strconv.Itoa(i), Path: "/", Domain: u.Hostname()}})
+			}(i)
+		}
+		wg.Wait()
+
+		cache, err := readCache(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(cache) != 10 {
+			t.Errorf("lost cookies written concurrently, found %d sites", len(cache))
+		}
+	})
+
+	t.Run("merge from file", func(t *testing.T) {
+		a, _ := newCookieJar(f)
+		b, _ := newCookieJar(f)
+
+		ub, _ := url.Parse("https://b.example.org")
+		b.SetCookies(ub, []*http.Cookie{{Name: "fresh", Value: "b", Path: "/", Domain: ub.Hostname()}})
+
+		ua, _ := url.Parse("https://a.example.org")
+		a.SetCookies(ua, []*http.Cookie{{Name: "fresh", Value: "a", Path: "/", Domain: ua.Hostname()}})
+
+		if c := a.Cookies(ub); len(c) != 1 || c[0].Value != "b" {
+			t.Errorf("did not pick up cookies saved by another jar: %v", c)
+		}
+	})
+
+	t.Run("dev null", func(t *testing.T) {
+		fi, err := os.Stat(os.DevNull)
+		if err != nil || fi.Mode().IsRegular() {
+			t.Skip("no usable null device")
+		}
+
+		j, _ := newCookieJar(os.DevNull)
+		u, _ := url.Parse("https://example.org")
+		j.SetCookies(u, []*http.Cookie{{Name: "test", Value: "value", Path: "/", Domain: u.Hostname()}})
+
+		if fi, err = os.Stat(os.DevNull); err != nil || fi.Mode().IsRegular() {
+			t.Error("null device was replaced")
+		}
+	})
+}
+
+func TestCookieJar_merge(t *testing.T) {
+	now := time.Now()
+	src := []*http.Cookie{
+		{Name: "keep", Value: "old", Path: "/", Domain: "example.org"},
+		{Name: "update", Value: "old", Path: "/", Domain: "example.org"},
+		{Name: "delete", Value: "old", Path: "/", Domain: "example.org"},
+		{Name: "expired", Value: "old", Path: "/", Domain: "example.org", Expires: now.Add(-time.Minute)},
+	}
+
+	maxAge := &http.Cookie{Name: "maxage", Value: "new", Path: "/", Domain: "example.org", MaxAge: 3600}
+	m := merge(src, []*http.Cookie{
+		{Name: "update", Value: "new", Path: "/", Domain: "example.org"},
+		{Name: "delete", Path: "/", Domain: "example.org", MaxAge: -1},
+		maxAge,
+	}, now)
+
+	found := make(map[string]*http.Cookie)
+	for _, c := range m {
+		found[c.Name] = c
+	}
+
+	if len(found) != 3 || found["keep"].Value != "old" || found["update"].Value != "new" {
+		t.Errorf("unexpected cookies: %v", m)
+	}
+
+	if c := found["maxage"]; c.MaxAge != 0 || !c.Expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("max age not converted to expiration: %v", c)
+	}
+
+	if maxAge.MaxAge != 3600 {
+		t.Error("source cookie was modified")
+	}
+}
+
+func TestCookieJar_Corrupt(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "cookies")
+	if err := os.WriteFile(f, []byte(`{"https://localhost": "not cookies"}`), 0600); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, err := newCookieJar(f); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, err := os.Stat(f + ".corrupt"); err != nil {
+		t.Error("cache file was not quarantined")
+	}
+}
+
 func TestCookieJar_Cache(t *testing.T) {
 	t.Run("good", func(t *testing.T) {
 		u, _ := url.Parse("https://example.org")
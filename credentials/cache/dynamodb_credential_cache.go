@@ -0,0 +1,277 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/shared"
+)
+
+// Attribute names for the items in the DynamoDB table.  The table must use DynamoDbKeyAttribute (type String) as the
+// partition key, and should enable TTL using DynamoDbTtlAttribute.
+const (
+	DynamoDbKeyAttribute = "CacheKey"
+	DynamoDbTtlAttribute = "ExpiresAt"
+	dynamoDbDataAttr     = "Data"
+	dynamoDbKmsAttr      = "Encrypted"
+)
+
+// kmsContextKey is the encryption context key which binds the encrypted data to the cache key it's stored under.
+const kmsContextKey = "aws-runas:cache-key"
+
+// dynamoDbTimeout is the longest time to wait for the DynamoDB and KMS API calls for a single cache operation.
+const dynamoDbTimeout = 10 * time.Second
+
+type dynamoDbApi interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+type kmsApi interface {
+	Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// DynamoDbCacheConfig is the configuration for a DynamoDB backed credential cache.
+type DynamoDbCacheConfig struct {
+	// Table is the name, or ARN, of the DynamoDB table.
+	Table string
+	// KmsKeyId is the ID, alias, or ARN of the KMS key used to encrypt the cached credentials.  If empty, the data is
+	// not encrypted by aws-runas, and relies on the DynamoDB encryption at rest.
+	KmsKeyId string
+	// EncryptionContext is additional KMS encryption context used when encrypting and decrypting the data.
+	EncryptionContext map[string]string
+}
+
+type dynamoDbCredentialCache struct {
+	*DynamoDbCacheConfig
+	db  dynamoDbApi
+	kms kmsApi
+	key string
+}
+
+// NewDynamoDbCredentialCache creates a credential cache stored at the key in a DynamoDB table, using the DynamoDB and
+// KMS clients created from the provided AWS configuration.
+func NewDynamoDbCredentialCache(cfg aws.Config, cacheCfg *DynamoDbCacheConfig, key string) *dynamoDbCredentialCache {
+	return &dynamoDbCredentialCache{
+		DynamoDbCacheConfig: cacheCfg,
+		db:                  dynamodb.NewFromConfig(cfg),
+		kms:                 kms.NewFromConfig(cfg),
+		key:                 key,
+	}
+}
+
+// dynamoDbBackend stores the cache in the DynamoDB table named by the URI host (ex: dynamodb://my-table).  The region,
+// kms_key_id, encryption_context (a comma separated list of key=value pairs), and key_prefix query parameters configure
+// the cache.  The DynamoDB and KMS API calls use the AWS SDK default credentials.
+func dynamoDbBackend(u *url.URL, name string) (credentials.CredentialCacher, error) {
+	u, key := cacheKey(u, name)
+	q := u.Query()
+
+	if len(u.Host) < 1 {
+		return nil, fmt.Errorf("missing table name in dynamodb cache URI %s", u.Redacted())
+	}
+
+	encCtx, err := shared.ParseKeyValues(q.Get("encryption_context"))
+	if err != nil {
+		return nil, fmt.Errorf("encryption_context: %w", err)
+	}
+
+	cfg, err := dynamoDbAwsConfig(q.Get("region"))
+	if err != nil {
+		return nil, err
+	}
+
+	cacheCfg := &DynamoDbCacheConfig{
+		Table:             u.Host,
+		KmsKeyId:          q.Get("kms_key_id"),
+		EncryptionContext: encCtx,
+	}
+	return NewDynamoDbCredentialCache(cfg, cacheCfg, key), nil
+}
+
+var (
+	dynamoDbConfigs   = make(map[string]aws.Config)
+	dynamoDbConfigsMu sync.Mutex
+)
+
+// dynamoDbAwsConfig returns the AWS configuration for the DynamoDB and KMS clients in the region.  The configuration is
+// loaded once for each region, and shared by the caches for all profiles.  Requests are sent using the transport shared
+// by the process, so the proxy, CA bundle, and offline settings apply to the cache too.
+func dynamoDbAwsConfig(region string) (aws.Config, error) {
+	dynamoDbConfigsMu.Lock()
+	defer dynamoDbConfigsMu.Unlock()
+
+	if cfg, ok := dynamoDbConfigs[region]; ok {
+		return cfg, nil
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(), awsconfig.WithRegion(region))
+	if err != nil {
+		return cfg, err
+	}
+
+	// set after loading, the SDK is unable to apply settings like a custom CA bundle to an arbitrary http.Client
+	cfg.HTTPClient = &http.Client{Transport: shared.DefaultTransport()}
+	dynamoDbConfigs[region] = cfg
+	return cfg, nil
+}
+
+// Load the cached credentials from DynamoDB, if no cached credentials are found an expired set of credentials is
+// returned.  Any error calling the DynamoDB or KMS APIs is treated as a cache miss.
+func (c *dynamoDbCredentialCache) Load() *credentials.Credentials {
+	ctx, cancel := context.WithTimeout(context.Background(), dynamoDbTimeout)
+	defer cancel()
+
+	out, err := c.db.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(c.Table),
+		Key:            c.itemKey(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		log().Debugf("error reading cached credentials from dynamodb: %v", err)
+		return new(credentials.Credentials)
+	}
+
+	data, err := c.itemData(ctx, out.Item)
+	if err != nil {
+		log().Debugf("error reading cached credentials from dynamodb: %v", err)
+		return new(credentials.Credentials)
+	}
+
+	if data == nil {
+		return new(credentials.Credentials)
+	}
+
+	creds, err := decodeCredentials(data)
+	if err != nil {
+		if !errors.Is(err, errUnreadableCache) {
+			log().Warningf("cached credentials at dynamodb key %s are corrupt (%s), removing", c.key, err.Error())
+			_ = c.Clear()
+		}
+		return new(credentials.Credentials)
+	}
+
+	return creds
+}
+
+// Store the provided credentials in DynamoDB as a serialized JSON representation, encrypted with KMS if a key is
+// configured.  The item expiration time is set to the credential expiration, so the table TTL can remove stale items.
+func (c *dynamoDbCredentialCache) Store(creds *credentials.Credentials) error {
+	if creds == nil || !creds.Value().HasKeys() {
+		return credentials.ErrInvalidCredentials
+	}
+
+	if !creds.Expiration.After(time.Now()) {
+		// no point caching expired credentials
+		return c.Clear()
+	}
+
+	data, err := encodeSharedCache(creds.StsCredentials())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dynamoDbTimeout)
+	defer cancel()
+
+	item := c.itemKey()
+	item[DynamoDbTtlAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(creds.Expiration.Unix(), 10)}
+	item[dynamoDbKmsAttr] = &types.AttributeValueMemberBOOL{Value: len(c.KmsKeyId) > 0}
+
+	if len(c.KmsKeyId) > 0 {
+		var out *kms.EncryptOutput
+		out, err = c.kms.Encrypt(ctx, &kms.EncryptInput{
+			KeyId:             aws.String(c.KmsKeyId),
+			Plaintext:         data,
+			EncryptionContext: c.encryptionContext(),
+		})
+		if err != nil {
+			return err
+		}
+		data = out.CiphertextBlob
+	}
+	item[dynamoDbDataAttr] = &types.AttributeValueMemberB{Value: data}
+
+	_, err = c.db.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String(c.Table), Item: item})
+	return err
+}
+
+// Clear is the implementation of the CredentialCacher interface to clear data from the cache.  For this DynamoDB
+// backed implementation, this deletes the item from the table.
+func (c *dynamoDbCredentialCache) Clear() error {
+	ctx, cancel := context.WithTimeout(context.Background(), dynamoDbTimeout)
+	defer cancel()
+
+	_, err := c.db.DeleteItem(ctx, &dynamodb.DeleteItemInput{TableName: aws.String(c.Table), Key: c.itemKey()})
+	return err
+}
+
+func (c *dynamoDbCredentialCache) itemKey() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		DynamoDbKeyAttribute: &types.AttributeValueMemberS{Value: c.key},
+	}
+}
+
+// itemData returns the decrypted cache data from the item, or nil if the item has no data.
+func (c *dynamoDbCredentialCache) itemData(ctx context.Context, item map[string]types.AttributeValue) ([]byte, error) {
+	v, ok := item[dynamoDbDataAttr].(*types.AttributeValueMemberB)
+	if !ok {
+		return nil, nil
+	}
+
+	if enc, ok := item[dynamoDbKmsAttr].(*types.AttributeValueMemberBOOL); !ok || !enc.Value {
+		return v.Value, nil
+	}
+
+	in := &kms.DecryptInput{
+		CiphertextBlob:    v.Value,
+		EncryptionContext: c.encryptionContext(),
+	}
+
+	if len(c.KmsKeyId) > 0 {
+		// ensure the data was encrypted using the expected key
+		in.KeyId = aws.String(c.KmsKeyId)
+	}
+
+	out, err := c.kms.Decrypt(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+func (c *dynamoDbCredentialCache) encryptionContext() map[string]string {
+	ctx := make(map[string]string, len(c.EncryptionContext)+1)
+	for k, v := range c.EncryptionContext {
+		ctx[k] = v
+	}
+	ctx[kmsContextKey] = c.key
+	return ctx
+}
@@ -0,0 +1,261 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+func TestNewDynamoDbCredentialCache(t *testing.T) {
+	c := NewDynamoDbCredentialCache(aws.Config{Region: "us-east-1"}, &DynamoDbCacheConfig{Table: "mock"}, "key")
+	if c.db == nil || c.kms == nil || c.Table != "mock" || c.key != "key" {
+		t.Error("invalid cache")
+	}
+}
+
+func Test_dynamoDbBackend(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		u, _ := url.Parse("dynamodb://table?region=us-west-2&key_prefix=team:&encryption_context=a=b,c=d")
+		c, err := dynamoDbBackend(u, "mock")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dc := c.(*dynamoDbCredentialCache)
+		if dc.Table != "table" || dc.key != "team:mock" || len(dc.EncryptionContext) != 2 || dc.EncryptionContext["c"] != "d" {
+			t.Errorf("invalid cache: %+v", dc)
+		}
+
+		if !u.Query().Has("key_prefix") {
+			t.Error("uri was modified")
+		}
+	})
+
+	t.Run("bad encryption context", func(t *testing.T) {
+		u, _ := url.Parse("dynamodb://table?encryption_context=a")
+		if _, err := dynamoDbBackend(u, "mock"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("shared config", func(t *testing.T) {
+		a, err := dynamoDbAwsConfig("eu-west-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, _ := dynamoDbAwsConfig("eu-west-1")
+		if a.HTTPClient != b.HTTPClient || a.Region != "eu-west-1" {
+			t.Error("config was not shared")
+		}
+	})
+}
+
+func TestDynamoDbCredentialCache(t *testing.T) {
+	creds := &credentials.Credentials{
+		AccessKeyId:     "mockAK",
+		SecretAccessKey: "mockSK",
+		Token:           "mockST",
+		Expiration:      time.Now().Add(1 * time.Hour).Truncate(time.Second),
+	}
+
+	newCache := func(keyId string) (*dynamoDbCredentialCache, *mockDynamoDb, *mockKms) {
+		db := &mockDynamoDb{items: make(map[string]map[string]types.AttributeValue)}
+		k := new(mockKms)
+		cfg := &DynamoDbCacheConfig{Table: "mock", KmsKeyId: keyId, EncryptionContext: map[string]string{"team": "mock"}}
+		return &dynamoDbCredentialCache{DynamoDbCacheConfig: cfg, db: db, kms: k, key: "mock"}, db, k
+	}
+
+	t.Run("store and load", func(t *testing.T) {
+		c, db, _ := newCache("")
+
+		if err := c.Store(creds); err != nil {
+			t.Fatal(err)
+		}
+
+		ttl := db.items["mock"][DynamoDbTtlAttribute].(*types.AttributeValueMemberN).Value
+		if ttl != strconv.FormatInt(creds.Expiration.Unix(), 10) {
+			t.Errorf("invalid ttl: %s", ttl)
+		}
+
+		if v := c.Load(); v.AccessKeyId != creds.AccessKeyId || !v.Expiration.Equal(creds.Expiration) {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("store and load encrypted", func(t *testing.T) {
+		c, db, k := newCache("alias/mock")
+
+		if err := c.Store(creds); err != nil {
+			t.Fatal(err)
+		}
+
+		data := db.items["mock"][dynamoDbDataAttr].(*types.AttributeValueMemberB).Value
+		if bytes.Contains(data, []byte(creds.SecretAccessKey)) {
+			t.Error("data was not encrypted")
+		}
+
+		if k.context[kmsContextKey] != "mock" || k.context["team"] != "mock" {
+			t.Errorf("invalid encryption context: %v", k.context)
+		}
+
+		if v := c.Load(); v.AccessKeyId != creds.AccessKeyId {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("decrypt error", func(t *testing.T) {
+		c, _, k := newCache("alias/mock")
+
+		if err := c.Store(creds); err != nil {
+			t.Fatal(err)
+		}
+
+		k.err = errors.New("access denied")
+		if v := c.Load(); v.Value().HasKeys() {
+			t.Error("unexpected credentials loaded")
+		}
+	})
+
+	t.Run("store expired", func(t *testing.T) {
+		c, db, _ := newCache("")
+		db.items["mock"] = map[string]types.AttributeValue{}
+
+		if err := c.Store(&credentials.Credentials{AccessKeyId: "a", SecretAccessKey: "s"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := db.items["mock"]; ok {
+			t.Error("expired credentials were cached")
+		}
+	})
+
+	t.Run("store invalid", func(t *testing.T) {
+		c, _, _ := newCache("")
+		if err := c.Store(nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("load missing", func(t *testing.T) {
+		c, _, _ := newCache("")
+		if v := c.Load(); v.Value().HasKeys() {
+			t.Error("unexpected credentials loaded")
+		}
+	})
+
+	t.Run("load error", func(t *testing.T) {
+		c, db, _ := newCache("")
+		db.err = errors.New("throttled")
+
+		if v := c.Load(); v.Value().HasKeys() {
+			t.Error("unexpected credentials loaded")
+		}
+	})
+
+	t.Run("load corrupt", func(t *testing.T) {
+		c, db, _ := newCache("")
+		db.items["mock"] = map[string]types.AttributeValue{
+			DynamoDbKeyAttribute: &types.AttributeValueMemberS{Value: "mock"},
+			dynamoDbDataAttr:     &types.AttributeValueMemberB{Value: []byte("not json")},
+		}
+
+		if v := c.Load(); v.Value().HasKeys() {
+			t.Error("unexpected credentials loaded")
+		}
+
+		if _, ok := db.items["mock"]; ok {
+			t.Error("corrupt item was not removed")
+		}
+	})
+}
+
+type mockDynamoDb struct {
+	items map[string]map[string]types.AttributeValue
+	err   error
+}
+
+func (m *mockDynamoDb) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	k := params.Key[DynamoDbKeyAttribute].(*types.AttributeValueMemberS).Value
+	return &dynamodb.GetItemOutput{Item: m.items[k]}, nil
+}
+
+func (m *mockDynamoDb) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	k := params.Item[DynamoDbKeyAttribute].(*types.AttributeValueMemberS).Value
+	m.items[k] = params.Item
+	return new(dynamodb.PutItemOutput), nil
+}
+
+func (m *mockDynamoDb) DeleteItem(_ context.Context, params *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	delete(m.items, params.Key[DynamoDbKeyAttribute].(*types.AttributeValueMemberS).Value)
+	return new(dynamodb.DeleteItemOutput), nil
+}
+
+// mockKms "encrypts" data by reversing it.
+type mockKms struct {
+	context map[string]string
+	err     error
+}
+
+func (m *mockKms) Encrypt(_ context.Context, params *kms.EncryptInput, _ ...func(*kms.Options)) (*kms.EncryptOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	m.context = params.EncryptionContext
+	return &kms.EncryptOutput{CiphertextBlob: reverse(params.Plaintext)}, nil
+}
+
+func (m *mockKms) Decrypt(_ context.Context, params *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if params.EncryptionContext[kmsContextKey] != m.context[kmsContextKey] {
+		return nil, errors.New("invalid encryption context")
+	}
+	return &kms.DecryptOutput{Plaintext: reverse(params.CiphertextBlob)}, nil
+}
+
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
@@ -15,12 +15,14 @@ package cache
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/service/sts/types"
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
 type fileCredentialCache struct {
@@ -39,24 +41,56 @@ func (f *fileCredentialCache) Load() *credentials.Credentials {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 
-	creds := new(credentials.Credentials)
-	stsCreds := new(types.Credentials)
-
 	data, err := os.ReadFile(f.path)
 	if err != nil {
-		return creds
+		return new(credentials.Credentials)
+	}
+
+	creds, err := decodeCredentials(data)
+	if err != nil {
+		if len(data) > 0 && !errors.Is(err, errUnreadableCache) {
+			quarantine(f.path, err.Error())
+		}
+		return new(credentials.Credentials)
+	}
+
+	return creds
+}
+
+// ReadCredentialFile returns the credentials stored in the cache file at path.  Unlike Load, an error is returned if
+// the file can not be read or decoded, and corrupt files are left in place.
+func ReadCredentialFile(path string) (*credentials.Credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
+	return decodeCredentials(data)
+}
 
-	if err := json.Unmarshal(data, stsCreds); err != nil {
-		return creds
+// decodeCredentials unwraps cached credential data, returning an error if the data is corrupt.  Data without a valid
+// set of credentials is not an error, and returns an empty set of credentials.
+func decodeCredentials(data []byte) (*credentials.Credentials, error) {
+	raw, err := decodeCache(data)
+	if err != nil {
+		return nil, err
 	}
 
-	creds = credentials.FromStsCredentials(stsCreds)
+	stsCreds := new(types.Credentials)
+	if err = json.Unmarshal(raw, stsCreds); err != nil {
+		return nil, err
+	}
+
+	creds := credentials.FromStsCredentials(stsCreds)
 	if !creds.Value().HasKeys() {
-		return new(credentials.Credentials)
+		return new(credentials.Credentials), nil
 	}
 
-	return creds
+	// STS credentials always expire, and never more than a day and a half from when they're issued
+	if creds.Expiration.IsZero() || creds.Expiration.After(time.Now().Add(maxExpiration)) {
+		return nil, fmt.Errorf("invalid expiration %s", creds.Expiration)
+	}
+
+	return creds, nil
 }
 
 // Store the provided credentials to the file as a serialized JSON representation.
@@ -94,7 +128,8 @@ func (f *fileCredentialCache) writeFile(creds *credentials.Credentials) error {
 
 	// this should never return an error, all code paths to get here will have valid/serializable 'data'
 	// anything causing an error here is probably a panic-level issue
-	_ = json.NewEncoder(tmp).Encode(creds.StsCredentials())
+	data, _ := encodeCache(creds.StsCredentials())
+	_, _ = tmp.Write(data)
 
 	// close file before rename to keep Windows file handling happy
 	_ = tmp.Close()
@@ -138,6 +138,112 @@ func TestFileCredentialCache_Load(t *testing.T) {
 	})
 }
 
+func TestFileCredentialCache_Load_Corrupt(t *testing.T) {
+	t.Run("bad json", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "cache")
+		if err := os.WriteFile(f, []byte(`{"AccessKeyId": "akid"`), 0600); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if cr := NewFileCredentialCache(f).Load(); cr.Value().HasKeys() {
+			t.Error("did not receive empty credentials")
+		}
+
+		if _, err := os.Stat(f + ".corrupt"); err != nil {
+			t.Error("cache file was not quarantined")
+		}
+
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Error("corrupt cache file still exists")
+		}
+	})
+
+	t.Run("impossible expiration", func(t *testing.T) {
+		cred := &credentials.Credentials{
+			AccessKeyId:     "AKIAM0CK",
+			SecretAccessKey: "secretKey",
+			Expiration:      time.Now().AddDate(10, 0, 0),
+		}
+
+		f := filepath.Join(t.TempDir(), "cache")
+		c := NewFileCredentialCache(f)
+		if err := c.Store(cred); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if cr := c.Load(); cr.Value().HasKeys() {
+			t.Error("did not receive empty credentials")
+		}
+
+		if _, err := os.Stat(f + ".corrupt"); err != nil {
+			t.Error("cache file was not quarantined")
+		}
+	})
+
+	t.Run("missing expiration", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "cache")
+		if err := os.WriteFile(f, []byte(`{"AccessKeyId": "akid", "SecretAccessKey": "sak"}`), 0600); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if cr := NewFileCredentialCache(f).Load(); cr.Value().HasKeys() {
+			t.Error("did not receive empty credentials")
+		}
+
+		if _, err := os.Stat(f + ".corrupt"); err != nil {
+			t.Error("cache file was not quarantined")
+		}
+	})
+}
+
+func TestReadCredentialFile(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		cred := &credentials.Credentials{
+			AccessKeyId:     "AKIAM0CK",
+			SecretAccessKey: "secretKey",
+			Expiration:      time.Now().Add(1 * time.Hour).Round(time.Second),
+		}
+
+		f := filepath.Join(t.TempDir(), "cache")
+		if err := NewFileCredentialCache(f).Store(cred); err != nil {
+			t.Fatal(err)
+		}
+
+		cr, err := ReadCredentialFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !cr.Expiration.Equal(cred.Expiration) {
+			t.Error("expiration mismatch")
+		}
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "cache")
+		if err := os.WriteFile(f, []byte(`{"AccessKeyId": "akid"`), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := ReadCredentialFile(f); err == nil {
+			t.Error("did not receive expected error")
+		}
+
+		if _, err := os.Stat(f); err != nil {
+			t.Error("corrupt cache file was moved")
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		if _, err := ReadCredentialFile(filepath.Join(t.TempDir(), "cache")); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
 func TestFileCredentialCache_Clear(t *testing.T) {
 	f := filepath.Join(t.TempDir(), "cache")
 	if err := NewFileCredentialCache(f).Clear(); err != nil {
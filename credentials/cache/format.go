@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mmmorris1975/aws-runas/shared"
+)
+
+// cacheVersion is the current version of the cache file format.  When the format of the cached data changes, increment
+// this value and add a migration from the previous version to the migrations map.
+const cacheVersion = 1
+
+// errUnreadableCache is returned when a cache file is valid, but can not be read by this process.  The data is treated
+// as a cache miss, and is not quarantined, since it's not corrupt.
+var errUnreadableCache = errors.New("cache file is unreadable")
+
+// errNewerCacheVersion is returned when a cache file was written by a newer version of aws-runas.
+var errNewerCacheVersion = fmt.Errorf("%w: format is newer than supported", errUnreadableCache)
+
+// migration converts cached data from one version of the file format to the next.
+type migration func(data json.RawMessage) (json.RawMessage, error)
+
+// migrations holds the function which converts data from the map key version to the next version.
+var migrations = map[int]migration{
+	// version 0 is the unversioned format written by older releases, which is the bare data
+	0: func(data json.RawMessage) (json.RawMessage, error) { return data, nil },
+}
+
+type cacheEnvelope struct {
+	Version    int             `json:"version"`
+	Protection string          `json:"protection,omitempty"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// encodeCache wraps the data in the current version of the cache file format.  If the platform supports data
+// protection (DPAPI on Windows), the data is encrypted so it is only readable by the current user.
+func encodeCache(data any) ([]byte, error) {
+	return encodeCacheData(data, len(shared.DataProtectionScheme) > 0)
+}
+
+// encodeSharedCache wraps the data in the current version of the cache file format, without any platform data
+// protection, for caches which are shared between users or hosts.
+func encodeSharedCache(data any) ([]byte, error) {
+	return encodeCacheData(data, false)
+}
+
+func encodeCacheData(data any, protect bool) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &cacheEnvelope{Version: cacheVersion, Data: raw}
+	if protect {
+		var b []byte
+		if b, err = shared.ProtectData(raw); err != nil {
+			return nil, err
+		}
+
+		// a []byte is marshaled as a base64 encoded string
+		if env.Data, err = json.Marshal(b); err != nil {
+			return nil, err
+		}
+		env.Protection = shared.DataProtectionScheme
+	}
+
+	return json.Marshal(env)
+}
+
+// decodeCache unwraps the data from a cache file, migrating it from older versions of the file format if necessary.
+func decodeCache(data []byte) (json.RawMessage, error) {
+	env := new(cacheEnvelope)
+	if err := json.Unmarshal(data, env); err != nil {
+		return nil, err
+	}
+
+	if env.Version == 0 || env.Data == nil {
+		// unversioned data from an older release
+		env.Version = 0
+		env.Data = data
+	}
+
+	if env.Version > cacheVersion {
+		return nil, fmt.Errorf("%w: version %d", errNewerCacheVersion, env.Version)
+	}
+
+	var err error
+	if len(env.Protection) > 0 {
+		if env.Data, err = unprotectCache(env.Protection, env.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	for v := env.Version; v < cacheVersion; v++ {
+		m, ok := migrations[v]
+		if !ok {
+			return nil, fmt.Errorf("no migration from cache format version %d", v)
+		}
+
+		if env.Data, err = m(env.Data); err != nil {
+			return nil, fmt.Errorf("cache format migration from version %d failed: %w", v, err)
+		}
+	}
+
+	return env.Data, nil
+}
+
+// unprotectCache decrypts protected cache data.  Data which can not be decrypted, because it was protected on another
+// platform or by another user, is considered unreadable.
+func unprotectCache(scheme string, data json.RawMessage) (json.RawMessage, error) {
+	if scheme != shared.DataProtectionScheme {
+		return nil, fmt.Errorf("%w: unsupported data protection %s", errUnreadableCache, scheme)
+	}
+
+	var b []byte
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, err
+	}
+
+	raw, err := shared.UnprotectData(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnreadableCache, err)
+	}
+	return raw, nil
+}
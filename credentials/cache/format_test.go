@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeCache(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		data, err := encodeCache(map[string]string{"key": "value"})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		env := new(cacheEnvelope)
+		if err = json.Unmarshal(data, env); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if env.Version != cacheVersion {
+			t.Error("version mismatch")
+		}
+
+		raw, err := decodeCache(data)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		m := make(map[string]string)
+		if err = json.Unmarshal(raw, &m); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if m["key"] != "value" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("unversioned", func(t *testing.T) {
+		legacy := []byte(`{"AccessKeyId":"mockAK","SecretAccessKey":"mockSK"}`)
+
+		raw, err := decodeCache(legacy)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if string(raw) != string(legacy) {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("newer version", func(t *testing.T) {
+		_, err := decodeCache([]byte(`{"version":999,"data":{}}`))
+		if !errors.Is(err, errNewerCacheVersion) {
+			t.Errorf("did not receive expected error, got: %v", err)
+		}
+	})
+
+	t.Run("unsupported protection", func(t *testing.T) {
+		_, err := decodeCache([]byte(`{"version":1,"protection":"mock","data":"AAAA"}`))
+		if !errors.Is(err, errUnreadableCache) {
+			t.Errorf("did not receive expected error, got: %v", err)
+		}
+	})
+
+	t.Run("missing migration", func(t *testing.T) {
+		orig := migrations[0]
+		delete(migrations, 0)
+		defer func() { migrations[0] = orig }()
+
+		if _, err := decodeCache([]byte(`{"key":"value"}`)); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("bad json", func(t *testing.T) {
+		if _, err := decodeCache([]byte(`not json`)); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestFileCredentialCache_Load_Versions(t *testing.T) {
+	exp := time.Now().Add(1 * time.Hour).UTC().Format(time.RFC3339)
+
+	t.Run("unversioned", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "cache")
+		data := `{"AccessKeyId":"mockAK","SecretAccessKey":"mockSK","Expiration":"` + exp + `"}`
+		if err := os.WriteFile(f, []byte(data), 0600); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if c := NewFileCredentialCache(f).Load(); c.AccessKeyId != "mockAK" {
+			t.Error("did not load unversioned cache data")
+		}
+	})
+
+	t.Run("newer version", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "cache")
+		if err := os.WriteFile(f, []byte(`{"version":999,"data":{"encrypted":"xxx"}}`), 0600); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if c := NewFileCredentialCache(f).Load(); len(c.AccessKeyId) > 0 {
+			t.Error("unexpected credentials loaded")
+		}
+
+		if _, err := os.Stat(f + ".corrupt"); err == nil {
+			t.Error("newer cache file was quarantined")
+		}
+	})
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cache
+
+import (
+	"os"
+	"sync"
+
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+// memoryEntries holds the credentials read from, or written to, each cache file by any memoryCredentialCache in the
+// process, so clients created for each request in long-running processes share them.
+var memoryEntries = struct {
+	sync.Mutex
+	m map[string]*memoryEntry
+}{m: make(map[string]*memoryEntry)}
+
+type memoryEntry struct {
+	creds credentials.Credentials
+	info  os.FileInfo
+}
+
+type memoryCredentialCache struct {
+	file *fileCredentialCache
+}
+
+// NewMemoryCredentialCache creates a file-backed credential cache at the specified path, which keeps the credentials in
+// memory.  Writes go to both memory and the file, and the credentials are only read from the file again if it changes
+// (like when another aws-runas process refreshes the credentials).  This is meant for long-running processes, like the
+// metadata credential service, where SDKs may ask for the credentials many times a minute.
+func NewMemoryCredentialCache(path string) *memoryCredentialCache {
+	return &memoryCredentialCache{file: NewFileCredentialCache(path)}
+}
+
+// Load returns the credentials held in memory, if the cache file is unchanged since they were stored.  Otherwise, the
+// credentials are loaded from the file.  If no cached credentials are found an expired set of credentials is returned.
+func (c *memoryCredentialCache) Load() *credentials.Credentials {
+	info, err := os.Stat(c.file.path)
+	if err != nil {
+		c.forget()
+		return c.file.Load()
+	}
+
+	memoryEntries.Lock()
+	e, ok := memoryEntries.m[c.file.path]
+	memoryEntries.Unlock()
+
+	if ok && sameFile(e.info, info) {
+		creds := e.creds // copy, so callers can't modify the cached value
+		return &creds
+	}
+
+	creds := c.file.Load()
+	if creds.Value().HasKeys() {
+		c.remember(creds, info)
+	} else {
+		c.forget()
+	}
+	return creds
+}
+
+// Store writes the credentials to the file, and keeps them in memory.
+func (c *memoryCredentialCache) Store(creds *credentials.Credentials) error {
+	if err := c.file.Store(creds); err != nil {
+		c.forget()
+		return err
+	}
+
+	if info, err := os.Stat(c.file.path); err == nil {
+		c.remember(creds, info)
+	}
+	return nil
+}
+
+// Clear removes the credentials from memory, and the file.
+func (c *memoryCredentialCache) Clear() error {
+	c.forget()
+	return c.file.Clear()
+}
+
+func (c *memoryCredentialCache) remember(creds *credentials.Credentials, info os.FileInfo) {
+	memoryEntries.Lock()
+	defer memoryEntries.Unlock()
+	memoryEntries.m[c.file.path] = &memoryEntry{creds: *creds, info: info}
+}
+
+func (c *memoryCredentialCache) forget() {
+	memoryEntries.Lock()
+	defer memoryEntries.Unlock()
+	delete(memoryEntries.m, c.file.path)
+}
+
+// sameFile returns true if the file info describes the same, unmodified, file.  The cache file is replaced when it's
+// written, so a change of the underlying file is detected even if the modification time didn't change.
+func sameFile(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cache
+
+import (
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemoryCredentialCache_Load(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "cache")
+		c := NewMemoryCredentialCache(f)
+		if err := c.Store(mockMemoryCreds("AKIAM0CK")); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if creds := c.Load(); creds.AccessKeyId != "AKIAM0CK" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "cache")
+		if err := NewMemoryCredentialCache(f).Store(mockMemoryCreds("AKIAM0CK")); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if creds := NewMemoryCredentialCache(f).Load(); creds.AccessKeyId != "AKIAM0CK" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("file changed", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "cache")
+		c := NewMemoryCredentialCache(f)
+		if err := c.Store(mockMemoryCreds("AKIAM0CK")); err != nil {
+			t.Error(err)
+			return
+		}
+
+		// simulate another process refreshing the credentials
+		if err := NewFileCredentialCache(f).Store(mockMemoryCreds("AKIAUPDATED")); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if creds := c.Load(); creds.AccessKeyId != "AKIAUPDATED" {
+			t.Error("did not reload changed cache file")
+		}
+	})
+
+	t.Run("file removed", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "cache")
+		c := NewMemoryCredentialCache(f)
+		if err := c.Store(mockMemoryCreds("AKIAM0CK")); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if err := NewFileCredentialCache(f).Clear(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if creds := c.Load(); creds.Value().HasKeys() {
+			t.Error("returned credentials for removed cache file")
+		}
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "cache")
+		c := NewMemoryCredentialCache(f)
+		if err := c.Store(mockMemoryCreds("AKIAM0CK")); err != nil {
+			t.Error(err)
+			return
+		}
+
+		c.Load().AccessKeyId = "modified"
+		if creds := c.Load(); creds.AccessKeyId != "AKIAM0CK" {
+			t.Error("cached credentials were modified")
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		c := NewMemoryCredentialCache(filepath.Join(t.TempDir(), "cache"))
+		if creds := c.Load(); creds.Value().HasKeys() {
+			t.Error("returned credentials for missing cache file")
+		}
+	})
+}
+
+func TestMemoryCredentialCache_Store(t *testing.T) {
+	t.Run("invalid", func(t *testing.T) {
+		c := NewMemoryCredentialCache(filepath.Join(t.TempDir(), "cache"))
+		if err := c.Store(new(credentials.Credentials)); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestMemoryCredentialCache_Clear(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "cache")
+	c := NewMemoryCredentialCache(f)
+	if err := c.Store(mockMemoryCreds("AKIAM0CK")); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if creds := c.Load(); creds.Value().HasKeys() {
+		t.Error("credentials not cleared")
+	}
+}
+
+func mockMemoryCreds(ak string) *credentials.Credentials {
+	return &credentials.Credentials{
+		AccessKeyId:     ak,
+		SecretAccessKey: "secretKey",
+		Token:           "sessionToken",
+		Expiration:      time.Now().Add(1 * time.Hour),
+	}
+}
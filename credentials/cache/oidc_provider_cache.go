@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	oidcProviderCaches   = make(map[string]*oidcProviderCache)
+	oidcProviderCachesMu sync.Mutex
+)
+
+// OidcProviderCache provides a file-backed OidcProviderCacher implementation at the specified path.
+func OidcProviderCache(path string) (*oidcProviderCache, error) {
+	oidcProviderCachesMu.Lock()
+	defer oidcProviderCachesMu.Unlock()
+
+	if v, ok := oidcProviderCaches[path]; ok {
+		return v, nil
+	}
+
+	c, err := newOidcProviderCache(path)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcProviderCaches[path] = c
+	return c, nil
+}
+
+type oidcProviderCache struct {
+	path  string
+	mu    sync.RWMutex
+	cache map[string]*credentials.OidcProvider
+}
+
+// force public access through OidcProviderCache() so we have better safety for concurrent access to individual files.
+func newOidcProviderCache(path string) (*oidcProviderCache, error) {
+	// ensure all intermediate directories exist
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+
+	c := &oidcProviderCache{path: path, cache: make(map[string]*credentials.OidcProvider)}
+	if err := c.loadCache(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Load is the implementation of the OidcProviderCacher interface to load data from the cache. If no provider data is
+// found for the issuer, nil will be returned.
+func (c *oidcProviderCache) Load(issuer string) *credentials.OidcProvider {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cache[tokenCacheKey(issuer)]
+}
+
+// Store is the implementation of the OidcProviderCacher interface to write data to the cache. If an empty issuer, or
+// nil provider is provided, the cache will not be updated.
+func (c *oidcProviderCache) Store(issuer string, provider *credentials.OidcProvider) error {
+	if len(issuer) < 1 || provider == nil {
+		return errors.New("invalid provider or issuer")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache[tokenCacheKey(issuer)] = provider
+	return c.flush()
+}
+
+// Clear is the implementation of the OidcProviderCacher interface to clear data from the cache.  For this file-backed
+// implementation, this removes the cache file.
+func (c *oidcProviderCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache = make(map[string]*credentials.OidcProvider)
+
+	// RemoveAll handles single files too, but will not error if file not found
+	return os.RemoveAll(c.path)
+}
+
+func (c *oidcProviderCache) loadCache() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err // some kind of I/O error we probably care about
+		}
+		// file does not exist, this is not an error, just return
+		return nil
+	}
+
+	if len(data) > 2 {
+		// this is non-fatal, move the bad file aside and rewrite a fresh cache without the old data
+		var raw json.RawMessage
+		if raw, err = decodeCache(data); err == nil {
+			err = json.Unmarshal(raw, &c.cache)
+		}
+
+		if err != nil {
+			if !errors.Is(err, errUnreadableCache) {
+				quarantine(c.path, err.Error())
+			}
+			c.cache = make(map[string]*credentials.OidcProvider)
+		}
+	}
+
+	return nil
+}
+
+func (c *oidcProviderCache) flush() error {
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".aws_runas_oidc_provider_*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	// this should never return an error, all code paths to get here will have valid/serializable 'data'
+	data, _ := encodeCache(c.cache)
+	_, _ = tmp.Write(data)
+
+	err = os.Rename(tmp.Name(), c.path)
+	if err == nil {
+		_ = os.Chmod(c.path, 0600)
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cache
+
+import (
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOidcProviderCache(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "oidc")
+
+	t.Run("re-get", func(t *testing.T) {
+		c1, err := OidcProviderCache(f)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		c2, _ := OidcProviderCache(f)
+		if c1 != c2 {
+			t.Error("did not receive singleton")
+		}
+	})
+
+	t.Run("bad", func(t *testing.T) {
+		if _, err := OidcProviderCache(filepath.Join(os.Args[0], "oidc")); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestOidcProviderCache_StoreLoad(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "oidc")
+	c, err := newOidcProviderCache(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &credentials.OidcProvider{
+		Issuer:  "https://idp.example.com",
+		JwksUri: "https://idp.example.com/keys",
+		Keys:    []*credentials.JsonWebKey{{Kty: "RSA", Kid: "k1", N: "AQAB", E: "AQAB"}},
+		Updated: time.Now().Truncate(time.Second),
+	}
+
+	t.Run("good", func(t *testing.T) {
+		if err = c.Store(p.Issuer, p); err != nil {
+			t.Error(err)
+			return
+		}
+
+		// reading the file in a new cache verifies the data was persisted
+		c2, err := newOidcProviderCache(f)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		l := c2.Load(p.Issuer)
+		if l == nil || l.JwksUri != p.JwksUri || l.Key("k1") == nil || !l.Updated.Equal(p.Updated) {
+			t.Errorf("data mismatch: %+v", l)
+		}
+
+		if c2.Load("https://other.example.com") != nil {
+			t.Error("found provider for unknown issuer")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if err = c.Store("", p); err == nil {
+			t.Error("did not receive expected error")
+		}
+
+		if err = c.Store("issuer", nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		if err = c.Clear(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if _, err = os.Stat(f); !os.IsNotExist(err) {
+			t.Error("cache file was not removed")
+		}
+
+		if c.Load(p.Issuer) != nil {
+			t.Error("found provider after clear")
+		}
+	})
+}
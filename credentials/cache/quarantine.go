@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cache
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/shared"
+)
+
+// maxExpiration is the longest time in the future a cached credential can expire.  The longest lived STS credentials
+// are 36 hour session tokens, anything beyond this (with some allowance for clock skew) must be corrupt data.
+const maxExpiration = 48 * time.Hour
+
+// atomic.Value requires a consistent concrete type, so the logger is wrapped.
+type loggerHolder struct {
+	shared.Logger
+}
+
+var logger atomic.Value
+
+// SetLogger sets the logger used to report problems found with cache files.
+func SetLogger(l shared.Logger) {
+	if l != nil {
+		logger.Store(loggerHolder{l})
+	}
+}
+
+func log() shared.Logger {
+	if h, ok := logger.Load().(loggerHolder); ok {
+		return h.Logger
+	}
+	return new(shared.DefaultLogger)
+}
+
+// quarantine moves a corrupt cache file aside, so it will be replaced with fresh data while keeping the original for
+// troubleshooting.  Only regular files are moved, so things like os.DevNull are left alone.
+func quarantine(path string, reason string) {
+	if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+		return
+	}
+
+	dst := path + ".corrupt"
+	if err := os.Rename(path, dst); err != nil {
+		log().Warningf("cache file %s is corrupt (%s), and could not be moved aside: %v", path, reason, err)
+		return
+	}
+	log().Warningf("cache file %s is corrupt (%s), moved to %s and fetching new data", path, reason, dst)
+}
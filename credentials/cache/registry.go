@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cache
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+// DefaultKeyPrefix is the prefix for the cache keys used by backends which are not file-based, unless the key_prefix
+// query parameter is set in the cache URI.
+const DefaultKeyPrefix = "aws-runas:"
+
+// BackendFactory returns a CredentialCacher for the named cache using the backend configuration in the cache URI.
+// The name is unique for the profile or role being cached (ex: aws_assume_role_my-profile).
+type BackendFactory func(u *url.URL, name string) (credentials.CredentialCacher, error)
+
+var (
+	backends   = make(map[string]BackendFactory)
+	backendsMu sync.RWMutex
+)
+
+func init() {
+	RegisterBackend("file", fileBackend)
+	RegisterBackend("redis", redisBackend)
+	RegisterBackend("rediss", redisBackend)
+	RegisterBackend("dynamodb", dynamoDbBackend)
+}
+
+// RegisterBackend makes a credential cache backend available for cache URIs using the scheme.  Registering a backend
+// for a scheme which is already registered replaces the existing backend.
+func RegisterBackend(scheme string, f BackendFactory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if f == nil {
+		delete(backends, strings.ToLower(scheme))
+		return
+	}
+	backends[strings.ToLower(scheme)] = f
+}
+
+// Backends returns the sorted list of URI schemes with a registered backend.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	s := make([]string, 0, len(backends))
+	for k := range backends {
+		s = append(s, k)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// NewCredentialCache returns a CredentialCacher for the named cache, using the backend registered for the scheme of
+// the cache URI.
+func NewCredentialCache(uri, name string) (credentials.CredentialCacher, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	backendsMu.RLock()
+	f, ok := backends[strings.ToLower(u.Scheme)]
+	backendsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no credential cache backend registered for scheme '%s'", u.Scheme)
+	}
+	return f(u, name)
+}
+
+// cacheKey returns the key for the named cache in backends which are not file-based, and a copy of the URI without the
+// key_prefix query parameter.  The provided URI is not modified.
+func cacheKey(u *url.URL, name string) (*url.URL, string) {
+	c := *u
+	if u.User != nil {
+		user := *u.User
+		c.User = &user
+	}
+
+	q := c.Query()
+	prefix := DefaultKeyPrefix
+	if q.Has("key_prefix") {
+		prefix = q.Get("key_prefix")
+		q.Del("key_prefix")
+		c.RawQuery = q.Encode()
+	}
+	return &c, prefix + name
+}
+
+// fileBackend stores the cache in a file named for the cache in the directory from the URI path
+// (ex: file:///var/cache/aws-runas).
+func fileBackend(u *url.URL, name string) (credentials.CredentialCacher, error) {
+	dir := u.Path
+	if len(u.Opaque) > 0 {
+		// relative path, like file:cache
+		dir = u.Opaque
+	}
+
+	if len(dir) < 1 {
+		return nil, fmt.Errorf("missing directory in file cache URI %s", u.Redacted())
+	}
+
+	if len(dir) > 2 && dir[0] == '/' && dir[2] == ':' {
+		// windows drive letter path, like file:///C:/cache
+		dir = dir[1:]
+	}
+	return NewFileCredentialCache(filepath.Join(filepath.FromSlash(dir), "."+name)), nil
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	samlRoleCaches   = make(map[string]*samlRoleCache)
+	samlRoleCachesMu sync.Mutex
+)
+
+// SamlRoleCache provides a file-backed SamlRoleCacher implementation at the specified path.
+func SamlRoleCache(path string) (*samlRoleCache, error) {
+	samlRoleCachesMu.Lock()
+	defer samlRoleCachesMu.Unlock()
+
+	if v, ok := samlRoleCaches[path]; ok {
+		return v, nil
+	}
+
+	c, err := newSamlRoleCache(path)
+	if err != nil {
+		return nil, err
+	}
+
+	samlRoleCaches[path] = c
+	return c, nil
+}
+
+type samlRoleCache struct {
+	path  string
+	mu    sync.RWMutex
+	cache map[string]*credentials.SamlRoleList
+}
+
+// force public access through SamlRoleCache() so we have better safety for concurrent access to individual files.
+func newSamlRoleCache(path string) (*samlRoleCache, error) {
+	// ensure all intermediate directories exist
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+
+	c := &samlRoleCache{path: path, cache: make(map[string]*credentials.SamlRoleList)}
+	if err := c.loadCache(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Load is the implementation of the SamlRoleCacher interface to load data from the cache. If no role list is found
+// for the key, nil will be returned.
+func (c *samlRoleCache) Load(key string) *credentials.SamlRoleList {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cache[tokenCacheKey(key)]
+}
+
+// Store is the implementation of the SamlRoleCacher interface to write data to the cache. If an empty key, or nil
+// role list is provided, the cache will not be updated.
+func (c *samlRoleCache) Store(key string, roles *credentials.SamlRoleList) error {
+	if len(key) < 1 || roles == nil {
+		return errors.New("invalid role list or key")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache[tokenCacheKey(key)] = roles
+	return c.flush()
+}
+
+// Clear is the implementation of the SamlRoleCacher interface to clear data from the cache.  For this file-backed
+// implementation, this removes the cache file.
+func (c *samlRoleCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache = make(map[string]*credentials.SamlRoleList)
+
+	// RemoveAll handles single files too, but will not error if file not found
+	return os.RemoveAll(c.path)
+}
+
+func (c *samlRoleCache) loadCache() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err // some kind of I/O error we probably care about
+		}
+		// file does not exist, this is not an error, just return
+		return nil
+	}
+
+	if len(data) > 2 {
+		// this is non-fatal, move the bad file aside and rewrite a fresh cache without the old data
+		var raw json.RawMessage
+		if raw, err = decodeCache(data); err == nil {
+			err = json.Unmarshal(raw, &c.cache)
+		}
+
+		if err != nil {
+			if !errors.Is(err, errUnreadableCache) {
+				quarantine(c.path, err.Error())
+			}
+			c.cache = make(map[string]*credentials.SamlRoleList)
+		}
+	}
+
+	return nil
+}
+
+func (c *samlRoleCache) flush() error {
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".aws_runas_saml_roles_*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	// this should never return an error, all code paths to get here will have valid/serializable 'data'
+	data, _ := encodeCache(c.cache)
+	_, _ = tmp.Write(data)
+
+	err = os.Rename(tmp.Name(), c.path)
+	if err == nil {
+		_ = os.Chmod(c.path, 0600)
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package cache
+
+import (
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSamlRoleCache(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "roles")
+
+	t.Run("re-get", func(t *testing.T) {
+		c1, err := SamlRoleCache(f)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		c2, _ := SamlRoleCache(f)
+		if c1 != c2 {
+			t.Error("did not receive singleton")
+		}
+	})
+
+	t.Run("bad", func(t *testing.T) {
+		if _, err := SamlRoleCache(filepath.Join(os.Args[0], "roles")); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestSamlRoleCache_StoreLoad(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "roles")
+	c, err := newSamlRoleCache(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles := &credentials.SamlRoleList{
+		Roles:   []string{"arn:aws:iam::123456789012:role/Admin"},
+		Updated: time.Now().Truncate(time.Second),
+	}
+
+	t.Run("good", func(t *testing.T) {
+		if err = c.Store("https://idp.example.com/saml user", roles); err != nil {
+			t.Error(err)
+			return
+		}
+
+		// reading the file in a new cache verifies the data was persisted
+		c2, err := newSamlRoleCache(f)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		l := c2.Load("https://idp.example.com/saml user")
+		if l == nil || len(l.Roles) != 1 || l.Roles[0] != roles.Roles[0] || !l.Updated.Equal(roles.Updated) {
+			t.Errorf("data mismatch: %+v", l)
+		}
+
+		if c2.Load("https://idp.example.com/saml other") != nil {
+			t.Error("found roles for unknown key")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if err = c.Store("", roles); err == nil {
+			t.Error("did not receive expected error")
+		}
+
+		if err = c.Store("key", nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		if err = c.Clear(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if _, err = os.Stat(f); !os.IsNotExist(err) {
+			t.Error("cache file was not removed")
+		}
+
+		if c.Load("https://idp.example.com/saml user") != nil {
+			t.Error("found roles after clear")
+		}
+	})
+}
+
+func TestSamlRoleCache_loadCache(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "roles")
+	_ = os.WriteFile(f, []byte("not a cache file"), 0600)
+
+	c, err := newSamlRoleCache(f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(c.cache) > 0 {
+		t.Error("found roles in corrupt cache")
+	}
+}
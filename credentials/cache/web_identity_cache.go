@@ -113,8 +113,18 @@ func (c *webIdentityCache) loadCache() error {
 	}
 
 	if len(data) > 2 {
-		// this is non-fatal, just rewrite a fresh cache without the old data
-		_ = json.Unmarshal(data, &c.cache) // todo handle (log?) error
+		// this is non-fatal, move the bad file aside and rewrite a fresh cache without the old data
+		var raw json.RawMessage
+		if raw, err = decodeCache(data); err == nil {
+			err = json.Unmarshal(raw, &c.cache)
+		}
+
+		if err != nil {
+			if !errors.Is(err, errUnreadableCache) {
+				quarantine(c.path, err.Error())
+			}
+			c.cache = make(map[string]*credentials.OidcIdentityToken)
+		}
 	}
 
 	return nil
@@ -132,7 +142,8 @@ func (c *webIdentityCache) flush() error {
 
 	// this should never return an error, all code paths to get here will have valid/serializable 'data'
 	// anything causing an error here is probably a panic-level issue
-	_ = json.NewEncoder(tmp).Encode(c.cache)
+	data, _ := encodeCache(c.cache)
+	_, _ = tmp.Write(data)
 
 	err = os.Rename(tmp.Name(), c.path)
 	if err == nil {
@@ -171,6 +171,28 @@ func TestWebIdentityCache_Load(t *testing.T) {
 	})
 }
 
+func TestWebIdentityCache_Corrupt(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "cache")
+	if err := os.WriteFile(f, []byte(`{"mock": [1, 2, 3]}`), 0600); err != nil {
+		t.Error(err)
+		return
+	}
+
+	c, err := newWebIdentityCache(f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(c.cache) > 0 {
+		t.Error("unexpected cache data")
+	}
+
+	if _, err = os.Stat(f + ".corrupt"); err != nil {
+		t.Error("cache file was not quarantined")
+	}
+}
+
 func TestWebIdentityCache_Clear(t *testing.T) {
 	f := filepath.Join(t.TempDir(), "cache")
 	c, _ := newWebIdentityCache(f)
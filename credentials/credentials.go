@@ -32,6 +32,7 @@ type Credentials struct {
 	Type            string     `json:",omitempty" ini:"-" env:"-"` // only used with EC2 credentials
 	LastUpdated     *time.Time `json:",omitempty" ini:"-" env:"-"` // only used with EC2 credentials
 	ProviderName    string     `json:"-" ini:"-" env:"-"`          // only used for Value()
+	RoleArn         string     `json:",omitempty" ini:"-" env:"-"` // the assumed role, if known
 }
 
 // ProcessCredentials is a specific type of credentials used for the credential process credential type.
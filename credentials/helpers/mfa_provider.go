@@ -0,0 +1,206 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package helpers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // required by RFC 6238
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/mmmorris1975/aws-runas/shared"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MfaProviderConfig holds the profile settings available to MFA providers.
+type MfaProviderConfig struct {
+	// Command is the command run by the command provider, which prints the MFA code.
+	Command string
+	// TotpSecret is the base32 encoded secret used by the totp provider to generate MFA codes.
+	TotpSecret string
+	// Prompt is the interactive MFA code prompt of the application, used by the stdin provider.  If nil, the code is
+	// read from os.Stdin.
+	Prompt func() (string, error)
+}
+
+// MfaProvider is the source of the MFA factor for a profile.  ReadInput returns the MFA code, and MfaType returns the
+// MFA type (see the MfaType* constants in the external package) the identity provider clients should use with it.
+// An empty MfaType leaves the mfa_type of the profile in effect.
+type MfaProvider interface {
+	MfaInputProvider
+	MfaType() string
+}
+
+// MfaProviderFactory returns the MfaProvider for the profile configuration.
+type MfaProviderFactory func(cfg *MfaProviderConfig) (MfaProvider, error)
+
+var (
+	mfaProviders   = make(map[string]MfaProviderFactory)
+	mfaProvidersMu sync.RWMutex
+)
+
+//nolint:gochecknoinits // registry of the built-in providers
+func init() {
+	RegisterMfaProvider("stdin", stdinMfaProvider)
+	RegisterMfaProvider("command", commandMfaProvider)
+	RegisterMfaProvider("totp", totpMfaProvider)
+	RegisterMfaProvider("push", pushMfaProvider)
+}
+
+// RegisterMfaProvider makes an MFA provider available to profiles using the name in the mfa_provider attribute.
+// Registering a provider for a name which is already registered replaces the existing provider.
+func RegisterMfaProvider(name string, f MfaProviderFactory) {
+	mfaProvidersMu.Lock()
+	defer mfaProvidersMu.Unlock()
+
+	if f == nil {
+		delete(mfaProviders, strings.ToLower(name))
+		return
+	}
+	mfaProviders[strings.ToLower(name)] = f
+}
+
+// MfaProviders returns the sorted list of registered MFA provider names.
+func MfaProviders() []string {
+	mfaProvidersMu.RLock()
+	defer mfaProvidersMu.RUnlock()
+
+	s := make([]string, 0, len(mfaProviders))
+	for k := range mfaProviders {
+		s = append(s, k)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// NewMfaProvider returns the MfaProvider registered with the name, configured for the profile.
+func NewMfaProvider(name string, cfg *MfaProviderConfig) (MfaProvider, error) {
+	mfaProvidersMu.RLock()
+	f, ok := mfaProviders[strings.ToLower(name)]
+	mfaProvidersMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown mfa_provider '%s', valid values are: %s", name, strings.Join(MfaProviders(), ", "))
+	}
+
+	if cfg == nil {
+		cfg = new(MfaProviderConfig)
+	}
+	return f(cfg)
+}
+
+// mfaProviderFunc adapts a function returning MFA codes to the MfaProvider interface.
+type mfaProviderFunc struct {
+	read    func() (string, error)
+	mfaType string
+}
+
+// ReadInput returns the MFA code.
+func (p *mfaProviderFunc) ReadInput() (string, error) {
+	return p.read()
+}
+
+// MfaType returns the MFA type used with the codes.
+func (p *mfaProviderFunc) MfaType() string {
+	return p.mfaType
+}
+
+// stdinMfaProvider prompts for the MFA code.
+func stdinMfaProvider(cfg *MfaProviderConfig) (MfaProvider, error) {
+	p := cfg.Prompt
+	if p == nil {
+		p = NewMfaTokenProvider(os.Stdin).ReadInput
+	}
+	return &mfaProviderFunc{read: p}, nil
+}
+
+// commandMfaProvider runs a command with the system shell, and uses the first line of its output as the MFA code.
+// This allows codes to be retrieved from password managers and other tools.
+func commandMfaProvider(cfg *MfaProviderConfig) (MfaProvider, error) {
+	if len(cfg.Command) < 1 {
+		return nil, errors.New("mfa_command is required for the command MFA provider")
+	}
+
+	read := func() (string, error) {
+		shell := []string{"/bin/sh", "-c"}
+		if runtime.GOOS == "windows" {
+			shell = []string{"cmd.exe", "/C"}
+		}
+
+		c := exec.Command(shell[0], shell[1], cfg.Command) //nolint:gosec // running the user's command is the point
+		c.Stderr = os.Stderr
+
+		out, err := c.Output()
+		if err != nil {
+			return "", fmt.Errorf("mfa_command failed: %w", err)
+		}
+
+		code, _, _ := bytes.Cut(bytes.TrimSpace(out), []byte("\n"))
+		if len(code) < 1 {
+			return "", errors.New("mfa_command did not return an MFA code")
+		}
+		return string(bytes.TrimSpace(code)), nil
+	}
+	return &mfaProviderFunc{read: read, mfaType: "code"}, nil
+}
+
+// totpMfaProvider generates RFC 6238 time-based MFA codes from a shared secret, like an authenticator app.
+func totpMfaProvider(cfg *MfaProviderConfig) (MfaProvider, error) {
+	secret := strings.ToUpper(strings.Join(strings.Fields(cfg.TotpSecret), ""))
+	if len(secret) < 1 {
+		return nil, errors.New("the MFA_TOTP_SECRET environment variable is required for the totp MFA provider")
+	}
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+
+	read := func() (string, error) {
+		// use the clock of the AWS and identity provider servers, if the local clock is known to be off
+		return totpCode(key, time.Now().Add(shared.ClockSkew())), nil
+	}
+	return &mfaProviderFunc{read: read, mfaType: "code"}, nil
+}
+
+// totpCode returns the 6 digit TOTP code for the key at time t, using the RFC 6238 defaults (30 second period, SHA1).
+func totpCode(key []byte, t time.Time) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(t.Unix()/30)) //nolint:gosec // unix time is positive
+
+	h := hmac.New(sha1.New, key)
+	h.Write(msg)
+	sum := h.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", code%1000000)
+}
+
+// pushMfaProvider has the identity provider send a push notification to the user's device, instead of using a code.
+// Since AWS only accepts MFA codes, it can not be used with IAM profiles.
+func pushMfaProvider(_ *MfaProviderConfig) (MfaProvider, error) {
+	read := func() (string, error) {
+		return "", errors.New("the push MFA provider can only be used with SAML and Web Identity profiles")
+	}
+	return &mfaProviderFunc{read: read, mfaType: "push"}, nil
+}
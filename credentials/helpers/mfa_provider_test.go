@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package helpers
+
+import (
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNewMfaProvider(t *testing.T) {
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := NewMfaProvider("unknown", nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("stdin prompt", func(t *testing.T) {
+		p, err := NewMfaProvider("STDIN", &MfaProviderConfig{Prompt: func() (string, error) { return "123456", nil }})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if v, err := p.ReadInput(); err != nil || v != "123456" || len(p.MfaType()) > 0 {
+			t.Errorf("unexpected result: %s %s %v", v, p.MfaType(), err)
+		}
+	})
+
+	t.Run("command", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("requires a posix shell")
+		}
+
+		p, err := NewMfaProvider("command", &MfaProviderConfig{Command: "printf ' 654321 \\nother\\n'"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if v, err := p.ReadInput(); err != nil || v != "654321" || p.MfaType() != "code" {
+			t.Errorf("unexpected result: %s %s %v", v, p.MfaType(), err)
+		}
+	})
+
+	t.Run("command failure", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("requires a posix shell")
+		}
+
+		p, _ := NewMfaProvider("command", &MfaProviderConfig{Command: "exit 1"})
+		if _, err := p.ReadInput(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("command missing", func(t *testing.T) {
+		if _, err := NewMfaProvider("command", nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("totp", func(t *testing.T) {
+		p, err := NewMfaProvider("totp", &MfaProviderConfig{TotpSecret: "gezd gnbv gy3t qojq"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if v, err := p.ReadInput(); err != nil || len(v) != 6 || p.MfaType() != "code" {
+			t.Errorf("unexpected result: %s %s %v", v, p.MfaType(), err)
+		}
+	})
+
+	t.Run("totp bad secret", func(t *testing.T) {
+		if _, err := NewMfaProvider("totp", &MfaProviderConfig{TotpSecret: "not!base32"}); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("push", func(t *testing.T) {
+		p, err := NewMfaProvider("push", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = p.ReadInput(); err == nil || p.MfaType() != "push" {
+			t.Error("unexpected push provider behavior")
+		}
+	})
+}
+
+func TestRegisterMfaProvider(t *testing.T) {
+	RegisterMfaProvider("Mock", func(_ *MfaProviderConfig) (MfaProvider, error) {
+		return &mfaProviderFunc{read: func() (string, error) { return "mock", nil }}, nil
+	})
+
+	if !slices.Contains(MfaProviders(), "mock") {
+		t.Error("provider not registered")
+	}
+
+	RegisterMfaProvider("mock", nil)
+	if slices.Contains(MfaProviders(), "mock") {
+		t.Error("provider not removed")
+	}
+}
+
+func Test_totpCode(t *testing.T) {
+	// RFC 6238 appendix B test vectors, truncated to 6 digits
+	key := []byte("12345678901234567890")
+	tests := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for ts, code := range tests {
+		if v := totpCode(key, time.Unix(ts, 0)); v != code {
+			t.Errorf("code mismatch at %d: %s != %s", ts, v, code)
+		}
+	}
+}
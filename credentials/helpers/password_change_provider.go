@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+type passwordChangeInputProvider struct {
+	input io.Reader
+}
+
+// NewPasswordChangeInputProvider returns a PasswordChangeInputProvider which will read a new password, and its
+// confirmation, from the provided reader as line-separated values.
+func NewPasswordChangeInputProvider(in io.Reader) *passwordChangeInputProvider {
+	return &passwordChangeInputProvider{input: in}
+}
+
+// ReadInput prompts for a new password on os.Stderr, then prompts again to confirm it.  An error is returned if the
+// password is empty, or the values do not match.  If the input reader is determined to be a console/tty, a secure
+// password prompt will be used to gather the input.
+func (p *passwordChangeInputProvider) ReadInput() (string, error) {
+	password, err := p.read("New Password: ")
+	if err != nil {
+		return "", err
+	}
+
+	if len(password) < 1 {
+		return "", errors.New("new password is empty")
+	}
+
+	confirm, err := p.read("Confirm New Password: ")
+	if err != nil {
+		return "", err
+	}
+
+	if password != confirm {
+		return "", errors.New("new passwords do not match")
+	}
+	return password, nil
+}
+
+func (p *passwordChangeInputProvider) read(prompt string) (string, error) {
+	_, _ = fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Println()
+
+	if f, ok := p.input.(*os.File); ok {
+		return trySecureRead(f)
+	}
+
+	var val string
+	err := readInput(p.input, &val)
+	return val, err
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package helpers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordChangeInputProvider_ReadInput(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		pw, err := NewPasswordChangeInputProvider(strings.NewReader("newPassword\nnewPassword\n")).ReadInput()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if pw != "newPassword" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		_, err := NewPasswordChangeInputProvider(strings.NewReader("newPassword\notherPassword\n")).ReadInput()
+		if err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := NewPasswordChangeInputProvider(strings.NewReader("")).ReadInput()
+		if err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
@@ -21,6 +21,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"github.com/mmmorris1975/aws-runas/shared"
 	"golang.org/x/crypto/scrypt"
 	"strconv"
 	"strings"
@@ -87,8 +88,35 @@ func (e *passwordEncoder) encode(s string) string {
 	return rot32(e.b64.EncodeToString([]byte(s)))
 }
 
-// Decode takes the string, which is the output from Encode() and decrypts the data.
+// Protect wraps the output from Encode() using the platform data protection mechanism (DPAPI on Windows), so the value
+// can only be decoded by the current user on the current host.  If the platform does not support data protection, the
+// value is returned unchanged.
+func (e *passwordEncoder) Protect(s string) (string, error) {
+	if len(shared.DataProtectionScheme) < 1 {
+		return s, nil
+	}
+
+	b, err := shared.ProtectData([]byte(s))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s$%s", shared.DataProtectionScheme, e.b64.EncodeToString(b)), nil
+}
+
+// Decode takes the string, which is the output from Encode() or Protect() and decrypts the data.
 func (e *passwordEncoder) Decode(s string) (string, error) {
+	if len(shared.DataProtectionScheme) > 0 && strings.HasPrefix(s, shared.DataProtectionScheme+"$") {
+		b, err := e.b64.DecodeString(strings.TrimPrefix(s, shared.DataProtectionScheme+"$"))
+		if err != nil {
+			return "", err
+		}
+
+		if b, err = shared.UnprotectData(b); err != nil {
+			return "", err
+		}
+		s = string(b)
+	}
+
 	sp := strings.Split(s, "$")
 	if len(sp) < 3 {
 		return "", errors.New("invalid input")
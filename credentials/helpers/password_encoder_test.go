@@ -16,6 +16,8 @@ package helpers
 import (
 	"strings"
 	"testing"
+
+	"github.com/mmmorris1975/aws-runas/shared"
 )
 
 var (
@@ -164,6 +166,31 @@ func TestPasswordEncoder_Decode(t *testing.T) {
 	})
 }
 
+func TestPasswordEncoder_Protect(t *testing.T) {
+	e, err := p.Encode(longPw, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pe, err := p.Protect(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(shared.DataProtectionScheme) < 1 && pe != e {
+		t.Error("password was modified without data protection support")
+	}
+
+	d, err := p.Decode(pe)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d != longPw {
+		t.Error("password mismatch")
+	}
+}
+
 func Test_rot32(t *testing.T) {
 	s := p.encode("TeSt!23")
 	if rot32(rot32(s)) != s {
@@ -0,0 +1,175 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type roleSelectionInputProvider struct {
+	input io.Reader
+}
+
+// NewRoleSelectionInputProvider returns a RoleSelectionInputProvider which will read the account and role selection
+// from the provided reader as line-separated values.
+func NewRoleSelectionInputProvider(in io.Reader) *roleSelectionInputProvider {
+	return &roleSelectionInputProvider{input: in}
+}
+
+// ReadInput prompts on os.Stderr for one of the role ARNs in roles.  The roles are grouped by account, and the account
+// is selected first, then the role in the account.  Either step is skipped if there is only one choice.  A selection is
+// made by number, or by account ID or role name, and an empty value selects the default, which is last if it's one of
+// the roles, otherwise the first choice.
+func (p *roleSelectionInputProvider) ReadInput(roles []string, last string) (string, error) {
+	if len(roles) < 1 {
+		return "", errors.New("no roles to select from")
+	}
+
+	accounts, byAccount := groupRoles(roles)
+	lastAccount := accountId(last)
+
+	acct := accounts[0]
+	if len(accounts) > 1 {
+		choices := make([]string, len(accounts))
+		for i, a := range accounts {
+			choices[i] = fmt.Sprintf("%s (%d roles)", a, len(byAccount[a]))
+		}
+
+		i, err := p.choose("Accounts:", "Select account", choices, accounts, indexOf(accounts, lastAccount))
+		if err != nil {
+			return "", err
+		}
+		acct = accounts[i]
+	}
+
+	acctRoles := byAccount[acct]
+	if len(acctRoles) == 1 {
+		return acctRoles[0], nil
+	}
+
+	names := make([]string, len(acctRoles))
+	for i, r := range acctRoles {
+		names[i] = roleName(r)
+	}
+
+	i, err := p.choose(fmt.Sprintf("Roles in account %s:", acct), "Select role", names, names, indexOf(acctRoles, last))
+	if err != nil {
+		return "", err
+	}
+	return acctRoles[i], nil
+}
+
+// choose prints the numbered choices, and returns the index of the selected choice.  The value read is either the
+// choice number, or one of the keys.  The default choice is used for an empty value, or the first choice if def < 0.
+func (p *roleSelectionInputProvider) choose(title, prompt string, choices, keys []string, def int) (int, error) {
+	if def < 0 {
+		def = 0
+	}
+
+	_, _ = fmt.Fprintln(os.Stderr, title)
+	for i, c := range choices {
+		_, _ = fmt.Fprintf(os.Stderr, "  %d) %s\n", i+1, c)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "%s [%d]: ", prompt, def+1)
+
+	val, err := readLine(p.input)
+	if err != nil {
+		return -1, err
+	}
+
+	if len(val) < 1 {
+		return def, nil
+	}
+
+	if i, err := strconv.Atoi(val); err == nil && i > 0 && i <= len(choices) {
+		return i - 1, nil
+	}
+
+	if i := indexOf(keys, val); i >= 0 {
+		return i, nil
+	}
+	return -1, fmt.Errorf("invalid selection: %s", val)
+}
+
+// readLine reads a single line from the input a byte at a time, so no input past the line is consumed.
+func readLine(input io.Reader) (string, error) {
+	sb := new(strings.Builder)
+	b := make([]byte, 1)
+
+	for {
+		n, err := input.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+			sb.WriteByte(b[0])
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", err
+		}
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// groupRoles returns the sorted list of accounts for the roles, and the sorted roles in each account.
+func groupRoles(roles []string) ([]string, map[string][]string) {
+	byAccount := make(map[string][]string)
+	for _, r := range roles {
+		a := accountId(r)
+		byAccount[a] = append(byAccount[a], r)
+	}
+
+	accounts := make([]string, 0, len(byAccount))
+	for a, r := range byAccount {
+		sort.Strings(r)
+		accounts = append(accounts, a)
+	}
+	sort.Strings(accounts)
+
+	return accounts, byAccount
+}
+
+func accountId(role string) string {
+	if a, err := arn.Parse(role); err == nil {
+		return a.AccountID
+	}
+	return ""
+}
+
+func roleName(role string) string {
+	if a, err := arn.Parse(role); err == nil {
+		return strings.TrimPrefix(a.Resource, "role/")
+	}
+	return role
+}
+
+func indexOf(vals []string, val string) int {
+	for i, v := range vals {
+		if v == val {
+			return i
+		}
+	}
+	return -1
+}
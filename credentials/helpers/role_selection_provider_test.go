@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package helpers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoleSelectionInputProvider_ReadInput(t *testing.T) {
+	roles := []string{
+		"arn:aws:iam::210987654321:role/Admin",
+		"arn:aws:iam::123456789012:role/ReadOnly",
+		"arn:aws:iam::123456789012:role/Admin",
+		"arn:aws:iam::333333333333:role/path/Deploy",
+	}
+
+	tests := []struct {
+		name, input, last, want string
+	}{
+		{"by number", "1\n2\n", "", "arn:aws:iam::123456789012:role/ReadOnly"},
+		{"by name", "123456789012\nAdmin\n", "", "arn:aws:iam::123456789012:role/Admin"},
+		{"single role account", "3\n", "", "arn:aws:iam::333333333333:role/path/Deploy"},
+		{"default first", "\n\n", "", "arn:aws:iam::123456789012:role/Admin"},
+		{"default last", "\n\n", "arn:aws:iam::123456789012:role/ReadOnly", "arn:aws:iam::123456789012:role/ReadOnly"},
+		{"eof", "", "arn:aws:iam::210987654321:role/Admin", "arn:aws:iam::210987654321:role/Admin"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			role, err := NewRoleSelectionInputProvider(strings.NewReader(tc.input)).ReadInput(roles, tc.last)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if role != tc.want {
+				t.Errorf("unexpected role: %s", role)
+			}
+		})
+	}
+
+	t.Run("single account", func(t *testing.T) {
+		role, err := NewRoleSelectionInputProvider(strings.NewReader("2\n")).ReadInput(roles[1:3], "")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if role != roles[1] {
+			t.Errorf("unexpected role: %s", role)
+		}
+	})
+
+	t.Run("invalid selection", func(t *testing.T) {
+		if _, err := NewRoleSelectionInputProvider(strings.NewReader("9\n")).ReadInput(roles, ""); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("no roles", func(t *testing.T) {
+		if _, err := NewRoleSelectionInputProvider(strings.NewReader("")).ReadInput(nil, ""); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("bad input", func(t *testing.T) {
+		if _, err := NewRoleSelectionInputProvider(new(errReader)).ReadInput(roles, ""); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
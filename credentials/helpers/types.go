@@ -25,3 +25,15 @@ type CredentialInputProvider interface {
 type MfaInputProvider interface {
 	ReadInput() (string, error)
 }
+
+// PasswordChangeInputProvider specifies the interface for getting a new password from the user, when the identity
+// provider requires an expired password to be changed.
+type PasswordChangeInputProvider interface {
+	ReadInput() (string, error)
+}
+
+// RoleSelectionInputProvider specifies the interface for selecting one of the roles matching a role pattern, with the
+// user's last selection as the default.
+type RoleSelectionInputProvider interface {
+	ReadInput(roles []string, last string) (string, error)
+}
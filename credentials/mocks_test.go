@@ -20,6 +20,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+	"strings"
 	"time"
 )
 
@@ -61,6 +63,10 @@ func (m *stsMock) AssumeRole(_ context.Context, in *sts.AssumeRoleInput, _ ...fu
 		return nil, err
 	}
 
+	if err = validateMaxDuration(in.RoleArn, d); err != nil {
+		return nil, err
+	}
+
 	return &sts.AssumeRoleOutput{Credentials: buildCredentials(d)}, nil
 }
 
@@ -122,6 +128,25 @@ func validateDuration(d *int32, min, max, def time.Duration) (time.Duration, err
 	return def, nil
 }
 
+// roles with a name ending in "-1h" have a MaxSessionDuration of 1 hour, and roles ending in "-chained" act like a
+// role assumed via role chaining.
+func validateMaxDuration(role *string, d time.Duration) error {
+	if d <= time.Hour || role == nil {
+		return nil
+	}
+
+	if strings.HasSuffix(*role, "-1h") {
+		return &smithy.GenericAPIError{Code: "ValidationError",
+			Message: "The requested DurationSeconds exceeds the MaxSessionDuration set for this role."}
+	}
+
+	if strings.HasSuffix(*role, "-chained") {
+		return &smithy.GenericAPIError{Code: "ValidationError",
+			Message: "The requested DurationSeconds exceeds the 1 hour session limit for roles assumed by role chaining."}
+	}
+	return nil
+}
+
 func validateMfa(serial, code *string) error {
 	if serial != nil && len(*serial) > 0 {
 		if code != nil && *code == "123456" {
@@ -14,13 +14,22 @@
 package credentials
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/mmmorris1975/aws-runas/shared"
+	"math/big"
 	"strings"
 	"time"
 )
 
+// the allowed difference between the local clock and the identity provider clock when checking token times.
+const tokenClockSkew = 5 * time.Minute
+
 // OidcIdentityToken provides a type for inspecting and managing an OIDC identity token used with
 // the AssumeRoleWithWebIdentity AWS API call.
 type OidcIdentityToken string
@@ -45,6 +54,82 @@ func (t *OidcIdentityToken) ExpiresAt() time.Time {
 	}
 }
 
+// Claims returns the decoded payload of the identity token.
+func (t *OidcIdentityToken) Claims() (map[string]any, error) {
+	return t.decodePayload()
+}
+
+// Issuer retrieves the 'iss' field from the identity token payload.
+func (t *OidcIdentityToken) Issuer() string {
+	payload, err := t.decodePayload()
+	if err != nil {
+		return ""
+	}
+
+	iss, _ := payload["iss"].(string)
+	return iss
+}
+
+// KeyId returns the 'kid' field from the identity token header, which names the key used to sign the token.
+func (t *OidcIdentityToken) KeyId() string {
+	header, err := t.decodeHeader()
+	if err != nil {
+		return ""
+	}
+
+	kid, _ := header["kid"].(string)
+	return kid
+}
+
+// Verify checks the identity token signature against the signing keys of the provider, and validates the issuer,
+// audience, and validity period claims. Validation of the audience is skipped if the audience parameter is empty.
+// Any validation failure returns an error wrapping ErrInvalidIdentityToken.
+func (t *OidcIdentityToken) Verify(p *OidcProvider, audience string) error {
+	header, err := t.decodeHeader()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidIdentityToken, err)
+	}
+
+	payload, err := t.decodePayload()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidIdentityToken, err)
+	}
+
+	if err = t.verifySignature(header, p); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidIdentityToken, err)
+	}
+
+	if iss, _ := payload["iss"].(string); iss != p.Issuer {
+		return fmt.Errorf("%w: token issuer '%s' does not match identity provider issuer '%s'",
+			ErrInvalidIdentityToken, iss, p.Issuer)
+	}
+
+	if len(audience) > 0 && !hasAudience(payload["aud"], audience) {
+		return fmt.Errorf("%w: token audience %v does not include client ID '%s'",
+			ErrInvalidIdentityToken, payload["aud"], audience)
+	}
+
+	now := time.Now()
+	exp, ok := payload["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("%w: token has no expiration time", ErrInvalidIdentityToken)
+	}
+
+	// token times come from the identity provider clock, adjust them for any skew detected with the local clock
+	if expTime := shared.LocalTime(time.Unix(int64(exp), 0)); !now.Before(expTime.Add(tokenClockSkew)) {
+		return fmt.Errorf("%w: token expired at %s", ErrInvalidIdentityToken, expTime.Format(time.RFC3339))
+	}
+
+	if nbf, ok := payload["nbf"].(float64); ok {
+		if nbfTime := shared.LocalTime(time.Unix(int64(nbf), 0)); now.Add(tokenClockSkew).Before(nbfTime) {
+			return fmt.Errorf("%w: token is not valid until %s, check the system clock",
+				ErrInvalidIdentityToken, nbfTime.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
 func (t *OidcIdentityToken) String() string {
 	if t == nil || len(*t) < 1 {
 		return ""
@@ -62,13 +147,21 @@ func (t *OidcIdentityToken) sections() ([]string, error) {
 	return parts, nil
 }
 
+func (t *OidcIdentityToken) decodeHeader() (map[string]any, error) {
+	return t.decodeSection(0)
+}
+
 func (t *OidcIdentityToken) decodePayload() (map[string]any, error) {
+	return t.decodeSection(1)
+}
+
+func (t *OidcIdentityToken) decodeSection(idx int) (map[string]any, error) {
 	parts, err := t.sections()
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	data, err := base64.RawURLEncoding.DecodeString(parts[idx])
 	if err != nil {
 		return nil, err
 	}
@@ -80,3 +173,82 @@ func (t *OidcIdentityToken) decodePayload() (map[string]any, error) {
 
 	return v, nil
 }
+
+func (t *OidcIdentityToken) verifySignature(header map[string]any, p *OidcProvider) error {
+	alg, _ := header["alg"].(string)
+	kid, _ := header["kid"].(string)
+
+	var hash crypto.Hash
+	switch alg[min(2, len(alg)):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+
+	if hash == 0 || !strings.HasPrefix(alg, "RS") && !strings.HasPrefix(alg, "PS") && !strings.HasPrefix(alg, "ES") {
+		return fmt.Errorf("unsupported signing algorithm '%s'", alg)
+	}
+
+	jwk := p.Key(kid)
+	if jwk == nil {
+		return fmt.Errorf("signing key '%s' not found in identity provider JWKS", kid)
+	}
+
+	key, err := jwk.PublicKey()
+	if err != nil {
+		return fmt.Errorf("signing key '%s': %v", kid, err)
+	}
+
+	parts, _ := t.sections()
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			err = rsa.VerifyPKCS1v15(k, hash, digest, sig)
+		case "PS":
+			err = rsa.VerifyPSS(k, hash, digest, sig, nil)
+		default:
+			err = fmt.Errorf("signing algorithm '%s' does not match RSA key '%s'", alg, kid)
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" {
+			err = fmt.Errorf("signing algorithm '%s' does not match EC key '%s'", alg, kid)
+		} else if len(sig) != 2*size {
+			err = errors.New("invalid EC signature length")
+		} else if !ecdsa.Verify(k, digest, new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])) {
+			err = errors.New("EC signature is not valid")
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("signature verification failed: %v", err)
+	}
+	return nil
+}
+
+func hasAudience(aud any, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []any:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -14,9 +14,16 @@
 package credentials
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -76,3 +83,146 @@ func TestOidcIdentityToken_String(t *testing.T) {
 		}
 	})
 }
+
+func TestOidcIdentityToken_KeyId(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		tok := signedToken(nil, "RS256", "k1", nil)
+		if tok.KeyId() != "k1" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tok := OidcIdentityToken("mock.payload")
+		if tok.KeyId() != "" {
+			t.Error("data mismatch")
+		}
+	})
+}
+
+func TestOidcIdentityToken_Verify(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	p := &OidcProvider{
+		Issuer: "https://idp.example.com",
+		Keys:   []*JsonWebKey{rsaJwk("rsa", &rsaKey.PublicKey), ecJwk("ec", ecKey)},
+	}
+
+	claims := func(mod func(c map[string]any)) map[string]any {
+		c := map[string]any{
+			"iss": p.Issuer,
+			"aud": "my-client",
+			"exp": time.Now().Add(1 * time.Hour).Unix(),
+		}
+		if mod != nil {
+			mod(c)
+		}
+		return c
+	}
+
+	t.Run("good", func(t *testing.T) {
+		for _, alg := range []string{"RS256", "RS512", "PS256"} {
+			if err := signedToken(rsaKey, alg, "rsa", claims(nil)).Verify(p, "my-client"); err != nil {
+				t.Errorf("%s: %v", alg, err)
+			}
+		}
+
+		if err := signedToken(ecKey, "ES256", "ec", claims(nil)).Verify(p, "my-client"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("audience list", func(t *testing.T) {
+		c := claims(func(c map[string]any) { c["aud"] = []string{"other", "my-client"} })
+		if err := signedToken(rsaKey, "RS256", "rsa", c).Verify(p, "my-client"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("no audience check", func(t *testing.T) {
+		if err := signedToken(rsaKey, "RS256", "rsa", claims(nil)).Verify(p, ""); err != nil {
+			t.Error(err)
+		}
+	})
+
+	tests := []struct {
+		name string
+		tok  *OidcIdentityToken
+		msg  string
+	}{
+		{"alg none", signedToken(nil, "none", "rsa", claims(nil)), "unsupported signing algorithm"},
+		{"unknown key", signedToken(rsaKey, "RS256", "other", claims(nil)), "signing key 'other' not found"},
+		{"wrong key", signedToken(ecKey, "RS256", "rsa", claims(nil)), "signature verification failed"},
+		{"key type mismatch", signedToken(ecKey, "ES256", "rsa", claims(nil)), "does not match RSA key"},
+		{"tampered", tamper(signedToken(rsaKey, "RS256", "rsa", claims(nil))), "signature verification failed"},
+		{"issuer", signedToken(rsaKey, "RS256", "rsa", claims(func(c map[string]any) {
+			c["iss"] = "https://evil.example.com"
+		})), "does not match identity provider issuer"},
+		{"audience", signedToken(rsaKey, "RS256", "rsa", claims(func(c map[string]any) {
+			c["aud"] = "other"
+		})), "does not include client ID"},
+		{"no expiration", signedToken(rsaKey, "RS256", "rsa", claims(func(c map[string]any) {
+			delete(c, "exp")
+		})), "no expiration time"},
+		{"expired", signedToken(rsaKey, "RS256", "rsa", claims(func(c map[string]any) {
+			c["exp"] = time.Now().Add(-1 * time.Hour).Unix()
+		})), "token expired at"},
+		{"not yet valid", signedToken(rsaKey, "RS256", "rsa", claims(func(c map[string]any) {
+			c["nbf"] = time.Now().Add(1 * time.Hour).Unix()
+		})), "not valid until"},
+		{"bad format", func() *OidcIdentityToken { v := OidcIdentityToken("mock.payload"); return &v }(), "invalid token format"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.tok.Verify(p, "my-client")
+			if err == nil || !errors.Is(err, ErrInvalidIdentityToken) || !strings.Contains(err.Error(), tc.msg) {
+				t.Errorf("did not receive expected error: %v", err)
+			}
+		})
+	}
+}
+
+func signedToken(key crypto.Signer, alg, kid string, claims map[string]any) *OidcIdentityToken {
+	h, _ := json.Marshal(map[string]any{"alg": alg, "kid": kid, "typ": "JWT"})
+	c, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+
+	var sig []byte
+	if key != nil {
+		hash := crypto.SHA256
+		if strings.HasSuffix(alg, "512") {
+			hash = crypto.SHA512
+		}
+
+		d := hash.New()
+		d.Write([]byte(input))
+		digest := d.Sum(nil)
+
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			if strings.HasPrefix(alg, "PS") {
+				sig, _ = rsa.SignPSS(rand.Reader, k, hash, digest, nil)
+			} else {
+				sig, _ = rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+			}
+		case *ecdsa.PrivateKey:
+			r, s, _ := ecdsa.Sign(rand.Reader, k, digest)
+			sig = make([]byte, 64)
+			r.FillBytes(sig[:32])
+			s.FillBytes(sig[32:])
+		}
+	}
+
+	tok := OidcIdentityToken(input + "." + base64.RawURLEncoding.EncodeToString(sig))
+	return &tok
+}
+
+func tamper(tok *OidcIdentityToken) *OidcIdentityToken {
+	parts := strings.Split(tok.String(), ".")
+	c, _ := json.Marshal(map[string]any{"iss": "https://idp.example.com", "aud": "my-client", "sub": "admin",
+		"exp": time.Now().Add(1 * time.Hour).Unix()})
+	v := OidcIdentityToken(parts[0] + "." + base64.RawURLEncoding.EncodeToString(c) + "." + parts[2])
+	return &v
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package credentials
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// OidcProvider holds the details of an OIDC identity provider needed to validate identity tokens locally.
+// The data is sourced from the provider's discovery document, and the JWKS it references.
+type OidcProvider struct {
+	Issuer  string        `json:"issuer"`
+	JwksUri string        `json:"jwks_uri"`
+	Keys    []*JsonWebKey `json:"keys"`
+	Updated time.Time     `json:"updated"`
+}
+
+// Key returns the signing key with the provided key ID, or nil if the key is not known. If kid is empty,
+// and the provider only publishes a single key, that key is returned.
+func (p *OidcProvider) Key(kid string) *JsonWebKey {
+	if p == nil {
+		return nil
+	}
+
+	if len(kid) < 1 {
+		if len(p.Keys) == 1 {
+			return p.Keys[0]
+		}
+		return nil
+	}
+
+	for _, k := range p.Keys {
+		if k.Kid == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+// JsonWebKey is the subset of RFC 7517 JSON Web Key fields used for RSA and EC token signing keys.
+type JsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// PublicKey converts the JSON Web Key to a crypto.PublicKey, returning an *rsa.PublicKey or *ecdsa.PublicKey.
+func (k *JsonWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		exp := new(big.Int).SetBytes(e)
+		if len(n) < 1 || !exp.IsInt64() || exp.Int64() < 2 || exp.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA key parameters")
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported EC curve %s", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) > size || len(y) > size {
+			return nil, errors.New("invalid EC key parameters")
+		}
+
+		// uncompressed point encoding: 0x04 || X || Y, with each coordinate left-padded to the curve size
+		data := make([]byte, 1+2*size)
+		data[0] = 4
+		copy(data[1+size-len(x):1+size], x)
+		copy(data[1+2*size-len(y):], y)
+
+		return ecdsa.ParseUncompressedPublicKey(curve, data)
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
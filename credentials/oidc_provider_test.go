@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package credentials
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+)
+
+func TestOidcProvider_Key(t *testing.T) {
+	p := &OidcProvider{Keys: []*JsonWebKey{{Kid: "k1"}, {Kid: "k2"}}}
+
+	t.Run("found", func(t *testing.T) {
+		if k := p.Key("k2"); k == nil || k.Kid != "k2" {
+			t.Error("did not find key")
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if p.Key("k3") != nil {
+			t.Error("found unknown key")
+		}
+	})
+
+	t.Run("empty kid multiple keys", func(t *testing.T) {
+		if p.Key("") != nil {
+			t.Error("found key without kid")
+		}
+	})
+
+	t.Run("empty kid single key", func(t *testing.T) {
+		if k := (&OidcProvider{Keys: p.Keys[:1]}).Key(""); k == nil || k.Kid != "k1" {
+			t.Error("did not find key")
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var np *OidcProvider
+		if np.Key("k1") != nil {
+			t.Error("found key in nil provider")
+		}
+	})
+}
+
+func TestJsonWebKey_PublicKey(t *testing.T) {
+	t.Run("rsa", func(t *testing.T) {
+		k, _ := rsa.GenerateKey(rand.Reader, 2048)
+		pub, err := rsaJwk("k1", &k.PublicKey).PublicKey()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if !k.PublicKey.Equal(pub) {
+			t.Error("key mismatch")
+		}
+	})
+
+	t.Run("ec", func(t *testing.T) {
+		for _, c := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+			k, _ := ecdsa.GenerateKey(c, rand.Reader)
+			pub, err := ecJwk("k1", k).PublicKey()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if !k.PublicKey.Equal(pub) {
+				t.Errorf("%s key mismatch", c.Params().Name)
+			}
+		}
+	})
+
+	t.Run("bad rsa exponent", func(t *testing.T) {
+		if _, err := (&JsonWebKey{Kty: "RSA", N: "AQAB", E: ""}).PublicKey(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("bad ec curve", func(t *testing.T) {
+		if _, err := (&JsonWebKey{Kty: "EC", Crv: "P-224"}).PublicKey(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("bad ec point", func(t *testing.T) {
+		k := &JsonWebKey{Kty: "EC", Crv: "P-256", X: "AQAB", Y: "AQAB"}
+		if _, err := k.PublicKey(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		if _, err := (&JsonWebKey{Kty: "oct"}).PublicKey(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func rsaJwk(kid string, k *rsa.PublicKey) *JsonWebKey {
+	return &JsonWebKey{
+		Kty: "RSA",
+		Kid: kid,
+		N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+	}
+}
+
+func ecJwk(kid string, k *ecdsa.PrivateKey) *JsonWebKey {
+	data, _ := k.PublicKey.Bytes()
+	size := (len(data) - 1) / 2
+
+	return &JsonWebKey{
+		Kty: "EC",
+		Kid: kid,
+		Crv: k.Curve.Params().Name,
+		X:   base64.RawURLEncoding.EncodeToString(data[1 : 1+size]),
+		Y:   base64.RawURLEncoding.EncodeToString(data[1+size:]),
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
@@ -147,6 +148,66 @@ func (r *roleDetails) Principals() []string {
 	return rd
 }
 
+// MatchRole returns the role in the SAML assertion which matches pattern.  The pattern is either a regular expression
+// surrounded by slashes (like /arn:aws:iam::\d+:role/Dev.*/), or a glob, where '*' matches any sequence of characters
+// and '?' matches any single character.  The pattern must match the entire role ARN.  If the pattern matches more
+// than one role, the preferred roles are consulted in order, and the first one matching a single role is used.  An
+// error listing the candidate roles is returned if the pattern matches zero, or more than one, of the roles.
+func (r *roleDetails) MatchRole(pattern string, preferred ...string) (string, error) {
+	re, err := rolePatternRegexp(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	roles := r.Roles()
+	sort.Strings(roles)
+
+	matches := matchRoles(re, roles)
+	if len(matches) > 1 {
+		if role, ok := preferredRole(matches, preferred); ok {
+			return role, nil
+		}
+	}
+
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return "", fmt.Errorf("role pattern %s did not match any roles in the SAML assertion, available roles:\n  %s",
+			pattern, strings.Join(roles, "\n  "))
+	}
+	return "", fmt.Errorf("%w: role pattern %s matched multiple roles in the SAML assertion, use a more specific "+
+		"pattern:\n  %s", ErrAmbiguousRole, pattern, strings.Join(matches, "\n  "))
+}
+
+// MatchingRoles returns the sorted list of roles in the SAML assertion which match pattern, using the same rules as
+// MatchRole, without consulting any preferred roles.
+func (r *roleDetails) MatchingRoles(pattern string) ([]string, error) {
+	re, err := rolePatternRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := r.Roles()
+	sort.Strings(roles)
+	return matchRoles(re, roles), nil
+}
+
+// FindRole returns the role in the SAML assertion for the role ARN, or role pattern (see MatchRole).  An error listing
+// the available roles is returned if the role is not found in the assertion.
+func (r *roleDetails) FindRole(role string, preferred ...string) (string, error) {
+	if IsRolePattern(role) {
+		return r.MatchRole(role, preferred...)
+	}
+
+	if _, ok := r.details[role]; !ok {
+		roles := r.Roles()
+		sort.Strings(roles)
+		return "", fmt.Errorf("role %s not in your SAML assertion, available roles:\n  %s", role, strings.Join(roles, "\n  "))
+	}
+	return role, nil
+}
+
 // String iterates over the configured role and principal ARNs and returns a line-based
 // string of the role/principal pairs.
 func (r *roleDetails) String() string {
@@ -156,3 +217,98 @@ func (r *roleDetails) String() string {
 	}
 	return sb.String()
 }
+
+// SamlRoleList is the list of roles found in a SAML assertion, and the time the assertion was retrieved.  Selected
+// holds the role last chosen interactively for each profile, keyed by profile name.
+type SamlRoleList struct {
+	Roles    []string          `json:"roles"`
+	Updated  time.Time         `json:"updated"`
+	Selected map[string]string `json:"selected,omitempty"`
+}
+
+// NewSamlRoleList returns the SamlRoleList for the roles in the SAML assertion, updated at the current time.
+func NewSamlRoleList(saml *SamlAssertion) (*SamlRoleList, error) {
+	rd, err := saml.RoleDetails()
+	if err != nil {
+		return nil, err
+	}
+
+	roles := rd.Roles()
+	sort.Strings(roles)
+	return &SamlRoleList{Roles: roles, Updated: time.Now()}, nil
+}
+
+// FindRole returns the role in the list for the role ARN, or role pattern, using the same rules as roleDetails.FindRole.
+func (l *SamlRoleList) FindRole(role string, preferred ...string) (string, error) {
+	rd := &roleDetails{details: make(map[string]string, len(l.Roles))}
+	for _, r := range l.Roles {
+		rd.details[r] = ""
+	}
+	return rd.FindRole(role, preferred...)
+}
+
+// IsRolePattern returns true if the role is a pattern to match against the roles in a SAML assertion, instead of a
+// role ARN.
+func IsRolePattern(role string) bool {
+	return isRoleRegexp(role) || strings.ContainsAny(role, "*?")
+}
+
+// preferredRole returns the role matching the highest priority preference which matches exactly one of the roles.
+// A preference containing a ':', or surrounded by slashes, is a pattern matching the role ARN (see MatchRole),
+// otherwise it's a glob matching the role name.
+func preferredRole(roles, preferred []string) (string, bool) {
+	for _, p := range preferred {
+		full := strings.Contains(p, ":") || isRoleRegexp(p)
+
+		re, err := rolePatternRegexp(p)
+		if err != nil {
+			continue
+		}
+
+		matches := make([]string, 0)
+		for _, role := range roles {
+			v := role
+			if !full {
+				v = role[strings.LastIndex(role, "/")+1:]
+			}
+
+			if re.MatchString(v) {
+				matches = append(matches, role)
+			}
+		}
+
+		if len(matches) == 1 {
+			return matches[0], true
+		}
+	}
+	return "", false
+}
+
+func matchRoles(re *regexp.Regexp, roles []string) []string {
+	matches := make([]string, 0)
+	for _, role := range roles {
+		if re.MatchString(role) {
+			matches = append(matches, role)
+		}
+	}
+	return matches
+}
+
+func isRoleRegexp(role string) bool {
+	return len(role) > 2 && strings.HasPrefix(role, "/") && strings.HasSuffix(role, "/")
+}
+
+func rolePatternRegexp(pattern string) (*regexp.Regexp, error) {
+	if isRoleRegexp(pattern) {
+		re, err := regexp.Compile(`^(?:` + pattern[1:len(pattern)-1] + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid role pattern %s: %w", pattern, err)
+		}
+		return re, nil
+	}
+
+	glob := regexp.QuoteMeta(pattern)
+	glob = strings.ReplaceAll(glob, `\*`, `.*`)
+	glob = strings.ReplaceAll(glob, `\?`, `.`)
+	return regexp.MustCompile(`^` + glob + `$`), nil
+}
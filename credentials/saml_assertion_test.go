@@ -79,6 +79,107 @@ func TestSamlAssertion_RoleDetails(t *testing.T) {
 	})
 }
 
+func TestRoleDetails_MatchRole(t *testing.T) {
+	data := `
+<someTag>arn:aws:iam::123456789012:role/DevAdmin,arn:aws:iam::123456789012:saml-provider/mockPrincipal</someTag>
+<someTag>arn:aws:iam::123456789012:role/DevReadOnly,arn:aws:iam::123456789012:saml-provider/mockPrincipal</someTag>
+<someTag>arn:aws:iam::210987654321:role/ProdAdmin,arn:aws:iam::210987654321:saml-provider/mockPrincipal</someTag>
+`
+	a := SamlAssertion(base64.StdEncoding.EncodeToString([]byte(data)))
+	rd, err := (&a).RoleDetails()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, pattern, expected string
+		preferred               []string
+		err                     bool
+	}{
+		{"glob", "arn:aws:iam::*:role/Prod*", "arn:aws:iam::210987654321:role/ProdAdmin", nil, false},
+		{"single char glob", "arn:aws:iam::123456789012:role/DevAdmi?", "arn:aws:iam::123456789012:role/DevAdmin", nil, false},
+		{"regexp", `/arn:aws:iam::\d+:role/Dev(Read)+Only/`, "arn:aws:iam::123456789012:role/DevReadOnly", nil, false},
+		{"no match", "arn:aws:iam::*:role/Test*", "", nil, true},
+		{"multiple matches", "arn:aws:iam::*:role/Dev*", "", nil, true},
+		{"partial match", "/role/DevAdmin/", "", nil, true},
+		{"bad regexp", "/arn:aws:iam::(/", "", nil, true},
+		{"preferred name", "arn:aws:iam::*:role/*", "arn:aws:iam::123456789012:role/DevReadOnly", []string{"ReadOnly", "*ReadOnly", "ProdAdmin"}, false},
+		{"preferred arn", "*", "arn:aws:iam::210987654321:role/ProdAdmin", []string{"arn:aws:iam::210987654321:role/*"}, false},
+		{"preferred regexp", "*", "arn:aws:iam::123456789012:role/DevAdmin", []string{`/.*:123456789012:role/.*Admin/`}, false},
+		{"preferred multiple matches", "*", "", []string{"*Admin"}, true},
+		{"preferred no match", "*", "", []string{"Test"}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := rd.MatchRole(tc.pattern, tc.preferred...)
+			if (err != nil) != tc.err {
+				t.Errorf("unexpected error result: %v", err)
+				return
+			}
+
+			if r != tc.expected {
+				t.Errorf("data mismatch, got %s", r)
+			}
+		})
+	}
+}
+
+func TestSamlRoleList_FindRole(t *testing.T) {
+	data := `
+<someTag>arn:aws:iam::123456789012:role/DevAdmin,arn:aws:iam::123456789012:saml-provider/mockPrincipal</someTag>
+<someTag>arn:aws:iam::210987654321:role/ProdAdmin,arn:aws:iam::210987654321:saml-provider/mockPrincipal</someTag>
+`
+	a := SamlAssertion(base64.StdEncoding.EncodeToString([]byte(data)))
+	l, err := NewSamlRoleList(&a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(l.Roles) != 2 || l.Roles[0] != "arn:aws:iam::123456789012:role/DevAdmin" || l.Updated.IsZero() {
+		t.Fatalf("unexpected role list: %+v", l)
+	}
+
+	t.Run("role", func(t *testing.T) {
+		r, err := l.FindRole("arn:aws:iam::210987654321:role/ProdAdmin")
+		if err != nil || r != "arn:aws:iam::210987654321:role/ProdAdmin" {
+			t.Errorf("unexpected result %s: %v", r, err)
+		}
+	})
+
+	t.Run("pattern", func(t *testing.T) {
+		r, err := l.FindRole("arn:aws:iam::*:role/*Admin", "Prod*")
+		if err != nil || r != "arn:aws:iam::210987654321:role/ProdAdmin" {
+			t.Errorf("unexpected result %s: %v", r, err)
+		}
+	})
+
+	t.Run("missing role", func(t *testing.T) {
+		_, err := l.FindRole("arn:aws:iam::210987654321:role/ProdReadOnly")
+		if err == nil || !strings.Contains(err.Error(), "not in your SAML assertion") ||
+			!strings.Contains(err.Error(), "role/DevAdmin") {
+			t.Errorf("did not receive expected error: %v", err)
+		}
+	})
+
+	t.Run("bad assertion", func(t *testing.T) {
+		bad := SamlAssertion("not base64!")
+		if _, err := NewSamlRoleList(&bad); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestIsRolePattern(t *testing.T) {
+	if IsRolePattern("arn:aws:iam::123456789012:role/Admin") || IsRolePattern("") || IsRolePattern("/") {
+		t.Error("role ARN detected as pattern")
+	}
+
+	if !IsRolePattern("arn:aws:iam::*:role/Admin") || !IsRolePattern("/arn:aws:iam::\\d+:role/Admin/") {
+		t.Error("pattern not detected")
+	}
+}
+
 func TestSamlAssertion_ExpiresAt(t *testing.T) {
 	t.Run("empty", func(t *testing.T) {
 		if _, err := new(SamlAssertion).ExpiresAt(); err == nil {
@@ -16,8 +16,12 @@ package credentials
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/mmmorris1975/aws-runas/shared"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 const (
@@ -29,7 +33,12 @@ const (
 // An optional Cache provides the ability to cache the credentials in order to limit API calls.
 type samlRoleProvider struct {
 	*AssumeRoleProvider
+	PreferredRoles []string // consulted in order when a role pattern matches multiple roles
+	// RoleSelector picks one of the roles matching the role pattern, when the preferred roles don't resolve the
+	// pattern to a single role.  If nil, a pattern matching multiple roles is an error.
+	RoleSelector  func(roles []string) (string, error)
 	samlAssertion *SamlAssertion
+	assumedRole   string
 }
 
 // NewSamlRoleProvider configures a default samlRoleProvider to allow Assume Role using SAML.  The default provider uses
@@ -38,10 +47,13 @@ type samlRoleProvider struct {
 // identity provider. The credential duration is set to AssumeRoleDefaultDuration, and the ExpiryWindow is set to 10% of
 // the duration value.
 func NewSamlRoleProvider(cfg aws.Config, roleArn string, saml *SamlAssertion) *samlRoleProvider {
-	return &samlRoleProvider{
+	p := &samlRoleProvider{
 		AssumeRoleProvider: NewAssumeRoleProvider(cfg, roleArn),
 		samlAssertion:      saml,
 	}
+	// there are no AWS credentials to look up the role with until the role is assumed
+	p.RoleMaxDuration = nil
+	return p
 }
 
 // SamlAssertion is the implementation of the SamlRoleProvider interface for setting the SAML assertion used for the
@@ -55,13 +67,19 @@ func (p *samlRoleProvider) SamlAssertion(saml *SamlAssertion) {
 // consulted to load the credentials.  If the credentials are expired, the credentials will be refreshed, and stored back
 // in the cache.
 func (p *samlRoleProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	ctx, span := shared.StartSpan(ctx, "SamlRoleProvider.Retrieve")
+	defer span.End()
+
 	var err error
 	creds := p.CheckCache()
+	span.SetAttributes(attribute.Bool("cache.hit", creds != nil && !creds.Value().Expired()))
 
 	if creds == nil || creds.Value().Expired() {
 		p.Logger.Debugf("Detected expired or unset saml role credentials, refreshing")
 		creds, err = p.retrieve(ctx)
 		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 			return aws.Credentials{}, err
 		}
 
@@ -79,10 +97,15 @@ func (p *samlRoleProvider) Retrieve(ctx context.Context) (aws.Credentials, error
 	//	return p.Retrieve()
 	// }
 
+	p.assumedRole = creds.RoleArn
+	if len(p.assumedRole) < 1 && !IsRolePattern(p.RoleArn) {
+		p.assumedRole = p.RoleArn
+	}
+
 	v := creds.Value()
 	v.Source = SamlRoleProviderName
 
-	p.Logger.Debugf("SAML ROLE CREDENTIALS: %+v", v)
+	p.Logger.Debugf("SAML ROLE CREDENTIALS: %+v", redactCredentials(v))
 	return v, nil
 }
 
@@ -92,37 +115,82 @@ func (p *samlRoleProvider) retrieve(ctx context.Context) (*Credentials, error) {
 		return nil, err
 	}
 
+	ctx, span := shared.StartSpan(ctx, "sts.AssumeRoleWithSAML")
 	out, err := p.Client.AssumeRoleWithSAML(ctx, in)
+	if d, ok := p.maxDuration(ctx, err, aws.ToString(in.RoleArn), in.DurationSeconds); ok {
+		in.DurationSeconds = d
+		out, err = p.Client.AssumeRoleWithSAML(ctx, in)
+	}
+	shared.EndSpan(span, err)
 	if err != nil {
 		return nil, err
 	}
 
-	if p.ExpiryWindow < 1 {
-		p.ExpiryWindow = p.Duration / 10
-	}
+	p.updateExpiryWindow(in.DurationSeconds)
 
 	c := FromStsCredentials(out.Credentials)
+	c.Expiration = p.localExpiration(out.ResultMetadata, c.Expiration)
+	c.RoleArn = aws.ToString(in.RoleArn)
 	return c, nil
 }
 
+// AssumedRole returns the ARN of the role for the credentials most recently returned by Retrieve, which is the role
+// matched by a role pattern.  An empty string is returned if no credentials have been retrieved.
+func (p *samlRoleProvider) AssumedRole() string {
+	return p.assumedRole
+}
+
 func (p *samlRoleProvider) getAssumeRoleWithSamlInput() (*sts.AssumeRoleWithSAMLInput, error) {
 	if p.samlAssertion == nil || len(*p.samlAssertion) < 20 {
 		return nil, errors.New("invalid SAML Assertion detected, check your local SAML and identity provider configuration")
 	}
 
+	prin, err := p.samlAssertion.RoleDetails()
+	if err != nil {
+		return nil, err
+	}
+
+	// resolve role patterns every time, the roles in the assertion could change between authentications
+	role, err := prin.FindRole(p.RoleArn, p.PreferredRoles...)
+	if errors.Is(err, ErrAmbiguousRole) && p.RoleSelector != nil {
+		role, err = p.selectRole(prin)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if role != p.RoleArn {
+		p.Logger.Debugf("role pattern %s matched role %s", p.RoleArn, role)
+	}
+
 	in := &sts.AssumeRoleWithSAMLInput{
 		DurationSeconds: p.ConvertDuration(p.Duration, AssumeRoleDurationMin, AssumeRoleDurationMax, AssumeRoleDurationDefault),
-		RoleArn:         aws.String(p.RoleArn),
+		RoleArn:         aws.String(role),
 		SAMLAssertion:   aws.String(p.samlAssertion.String()),
+		PrincipalArn:    aws.String(prin.RolePrincipal(role)),
 	}
 
-	prin, err := p.samlAssertion.RoleDetails()
+	return in, nil
+}
+
+// selectRole calls the RoleSelector with the roles matching the role pattern, and checks the selected role is one of them.
+func (p *samlRoleProvider) selectRole(prin *roleDetails) (string, error) {
+	roles, err := prin.MatchingRoles(p.RoleArn)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	in.PrincipalArn = aws.String(prin.RolePrincipal(p.RoleArn))
 
-	return in, nil
+	role, err := p.RoleSelector(roles)
+	if err != nil {
+		return "", err
+	}
+
+	for _, r := range roles {
+		if r == role {
+			return role, nil
+		}
+	}
+	return "", fmt.Errorf("selected role %s does not match role pattern %s", role, p.RoleArn)
 }
 
 func (p *samlRoleProvider) ClearCache() error {
@@ -16,6 +16,7 @@ package credentials
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/mmmorris1975/aws-runas/shared"
@@ -57,6 +58,88 @@ func TestSamlRoleProvider_Retrieve(t *testing.T) {
 		}
 	})
 
+	t.Run("role pattern", func(t *testing.T) {
+		p := newSamlRoleProvider()
+		p.RoleArn = "arn:aws:iam::*:role/mock*"
+
+		in, err := p.getAssumeRoleWithSamlInput()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if *in.RoleArn != "arn:aws:iam::1234567890:role/mockRole" ||
+			*in.PrincipalArn != "arn:aws:iam::1234567890:saml-provider/mockPrincipal" {
+			t.Error("role pattern not resolved")
+		}
+	})
+
+	t.Run("role pattern assumed role", func(t *testing.T) {
+		p := newSamlRoleProvider()
+		p.RoleArn = "arn:aws:iam::*:role/mock*"
+		p.Cache = new(memCredCache)
+
+		if _, err := p.Retrieve(context.Background()); err != nil {
+			t.Error(err)
+			return
+		}
+
+		// the matched role must also be known for cached credentials
+		if p.AssumedRole() != "arn:aws:iam::1234567890:role/mockRole" ||
+			p.Cache.Load().RoleArn != "arn:aws:iam::1234567890:role/mockRole" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("role pattern no match", func(t *testing.T) {
+		p := newSamlRoleProvider()
+		p.RoleArn = "arn:aws:iam::*:role/other*"
+
+		if _, err := p.Retrieve(context.Background()); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("role pattern selection", func(t *testing.T) {
+		a := ">arn:aws:iam::1234567890:role/mockRole,arn:aws:iam::1234567890:saml-provider/mockPrincipal<" +
+			">arn:aws:iam::0987654321:role/mockRole,arn:aws:iam::0987654321:saml-provider/mockPrincipal<"
+		saml := SamlAssertion(base64.StdEncoding.EncodeToString([]byte(a)))
+
+		p := newSamlRoleProvider()
+		p.SamlAssertion(&saml)
+		p.RoleArn = "arn:aws:iam::*:role/mock*"
+
+		if _, err := p.getAssumeRoleWithSamlInput(); !errors.Is(err, ErrAmbiguousRole) {
+			t.Errorf("did not receive expected error: %v", err)
+			return
+		}
+
+		var choices []string
+		p.RoleSelector = func(roles []string) (string, error) {
+			choices = roles
+			return roles[1], nil
+		}
+
+		in, err := p.getAssumeRoleWithSamlInput()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(choices) != 2 || *in.RoleArn != "arn:aws:iam::1234567890:role/mockRole" ||
+			*in.PrincipalArn != "arn:aws:iam::1234567890:saml-provider/mockPrincipal" {
+			t.Error("selected role not used")
+		}
+
+		p.RoleSelector = func(roles []string) (string, error) {
+			return "arn:aws:iam::1234567890:role/other", nil
+		}
+
+		if _, err = p.getAssumeRoleWithSamlInput(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
 	t.Run("zero duration", func(t *testing.T) {
 		p := newSamlRoleProvider()
 		p.Duration = 0 * time.Second
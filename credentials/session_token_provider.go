@@ -17,6 +17,9 @@ import (
 	"context"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/mmmorris1975/aws-runas/shared"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"time"
 )
 
@@ -53,13 +56,19 @@ func NewSessionTokenProvider(cfg aws.Config) *SessionTokenProvider {
 // consulted to load the credentials.  If the credentials are expired, the credentials will be refreshed (prompting for
 // MFA, if necessary), and stored back in the cache.
 func (p *SessionTokenProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	ctx, span := shared.StartSpan(ctx, "SessionTokenProvider.Retrieve")
+	defer span.End()
+
 	var err error
 	creds := p.CheckCache()
+	span.SetAttributes(attribute.Bool("cache.hit", creds != nil && !creds.Value().Expired()))
 
 	if creds == nil || creds.Value().Expired() {
 		p.Logger.Debugf("Detected expired or unset session token credentials, refreshing")
 		creds, err = p.retrieve(ctx)
 		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 			return aws.Credentials{}, err
 		}
 
@@ -80,17 +89,19 @@ func (p *SessionTokenProvider) Retrieve(ctx context.Context) (aws.Credentials, e
 	v := creds.Value()
 	v.Source = SessionTokenProviderName
 
-	p.Logger.Debugf("SESSION TOKEN CREDENTIALS: %+v", v)
+	p.Logger.Debugf("SESSION TOKEN CREDENTIALS: %+v", redactCredentials(v))
 	return v, nil
 }
 
 func (p *SessionTokenProvider) retrieve(ctx context.Context) (*Credentials, error) {
-	in, err := p.getSessionTokenInput()
+	in, err := p.getSessionTokenInput(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	ctx, span := shared.StartSpan(ctx, "sts.GetSessionToken")
 	out, err := p.Client.GetSessionToken(ctx, in)
+	shared.EndSpan(span, err)
 	if err != nil {
 		return nil, err
 	}
@@ -100,11 +111,11 @@ func (p *SessionTokenProvider) retrieve(ctx context.Context) (*Credentials, erro
 	}
 
 	c := FromStsCredentials(out.Credentials)
-	c.Expiration = *out.Credentials.Expiration
+	c.Expiration = p.localExpiration(out.ResultMetadata, c.Expiration)
 	return c, nil
 }
 
-func (p *SessionTokenProvider) getSessionTokenInput() (*sts.GetSessionTokenInput, error) {
+func (p *SessionTokenProvider) getSessionTokenInput(ctx context.Context) (*sts.GetSessionTokenInput, error) {
 	in := new(sts.GetSessionTokenInput)
 	in.DurationSeconds = p.ConvertDuration(p.Duration, SessionTokenDurationMin, SessionTokenDurationMax, SessionTokenDurationDefault)
 
@@ -112,7 +123,7 @@ func (p *SessionTokenProvider) getSessionTokenInput() (*sts.GetSessionTokenInput
 		in.SerialNumber = aws.String(p.SerialNumber)
 	}
 
-	code, err := p.handleMfa()
+	code, err := p.handleMfa(ctx)
 	if err != nil {
 		return nil, err
 	}
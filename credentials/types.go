@@ -27,6 +27,13 @@ var ErrInvalidCredentials = errors.New("invalid credentials")
 // but no source for the MFA information was found.
 var ErrMfaRequired = errors.New("MFA required, but no code sent")
 
+// ErrInvalidIdentityToken is the error returned when local validation of an OIDC identity token fails.
+var ErrInvalidIdentityToken = errors.New("invalid identity token")
+
+// ErrAmbiguousRole is the error returned when a role pattern matches more than one role in a SAML assertion, and no
+// preferred role or role selection resolves it to a single role.
+var ErrAmbiguousRole = errors.New("role pattern matched multiple roles")
+
 // CredentialCacher is the interface details to implement AWS credential caching.
 type CredentialCacher interface {
 	Load() *Credentials
@@ -41,6 +48,20 @@ type IdentityTokenCacher interface {
 	Clear() error
 }
 
+// SamlRoleCacher defines the methods used for caching the list of roles found in SAML assertions.
+type SamlRoleCacher interface {
+	Load(key string) *SamlRoleList
+	Store(key string, roles *SamlRoleList) error
+	Clear() error
+}
+
+// OidcProviderCacher defines the methods used for caching OIDC provider discovery and signing key data.
+type OidcProviderCacher interface {
+	Load(issuer string) *OidcProvider
+	Store(issuer string, provider *OidcProvider) error
+	Clear() error
+}
+
 // SamlRoleProvider defines the methods used for interacting with the AssumeRoleWithSAML call.
 type SamlRoleProvider interface {
 	aws.CredentialsProvider
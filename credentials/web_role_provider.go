@@ -18,6 +18,9 @@ import (
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/mmmorris1975/aws-runas/shared"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 const (
@@ -38,7 +41,10 @@ type webRoleProvider struct {
 // identity provider. The credential duration is set to AssumeRoleDefaultDuration, and the ExpiryWindow is set to 10% of
 // the duration value.
 func NewWebRoleProvider(cfg aws.Config, roleArn string) *webRoleProvider {
-	return &webRoleProvider{AssumeRoleProvider: NewAssumeRoleProvider(cfg, roleArn)}
+	p := &webRoleProvider{AssumeRoleProvider: NewAssumeRoleProvider(cfg, roleArn)}
+	// there are no AWS credentials to look up the role with until the role is assumed
+	p.RoleMaxDuration = nil
+	return p
 }
 
 // WebIdentityToken is the implementation of the WebRoleProvider interface for setting the Web (OIDC) Identity Token
@@ -52,13 +58,19 @@ func (p *webRoleProvider) WebIdentityToken(token *OidcIdentityToken) {
 // be consulted to load the credentials.  If the credentials are expired, the credentials will be refreshed, and stored
 // back in the cache.
 func (p *webRoleProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	ctx, span := shared.StartSpan(ctx, "WebRoleProvider.Retrieve")
+	defer span.End()
+
 	var err error
 	creds := p.CheckCache()
+	span.SetAttributes(attribute.Bool("cache.hit", creds != nil && !creds.Value().Expired()))
 
 	if creds == nil || creds.Value().Expired() {
 		p.Logger.Debugf("Detected expired or unset web identity role credentials, refreshing")
 		creds, err = p.retrieve(ctx)
 		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 			return aws.Credentials{}, err
 		}
 
@@ -79,7 +91,7 @@ func (p *webRoleProvider) Retrieve(ctx context.Context) (aws.Credentials, error)
 	v := creds.Value()
 	v.Source = WebRoleProviderName
 
-	p.Logger.Debugf("WEB IDENTITY ROLE CREDENTIALS: %+v", v)
+	p.Logger.Debugf("WEB IDENTITY ROLE CREDENTIALS: %+v", redactCredentials(v))
 	return v, nil
 }
 
@@ -89,16 +101,21 @@ func (p *webRoleProvider) retrieve(ctx context.Context) (*Credentials, error) {
 		return nil, err
 	}
 
+	ctx, span := shared.StartSpan(ctx, "sts.AssumeRoleWithWebIdentity")
 	out, err := p.Client.AssumeRoleWithWebIdentity(ctx, in)
+	if d, ok := p.maxDuration(ctx, err, p.RoleArn, in.DurationSeconds); ok {
+		in.DurationSeconds = d
+		out, err = p.Client.AssumeRoleWithWebIdentity(ctx, in)
+	}
+	shared.EndSpan(span, err)
 	if err != nil {
 		return nil, err
 	}
 
-	if p.ExpiryWindow < 1 {
-		p.ExpiryWindow = p.Duration / 10
-	}
+	p.updateExpiryWindow(in.DurationSeconds)
 
 	c := FromStsCredentials(out.Credentials)
+	c.Expiration = p.localExpiration(out.ResultMetadata, c.Expiration)
 	return c, nil
 }
 
@@ -20,6 +20,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/mmmorris1975/aws-runas/shared"
 	"sync"
@@ -52,7 +54,38 @@ func TestAwsIdentityProvider_Identity(t *testing.T) {
 			return
 		}
 
-		if id.Username != "bob" || id.IdentityType != "user" || id.Provider != "AwsIdentityProvider" {
+		if id.Username != "bob" || id.IdentityType != "user" || id.Provider != "AwsIdentityProvider" ||
+			id.Account != "123456789012" || id.Arn != "arn:aws:iam::123456789012:user/bob" || len(id.Organization) > 0 {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("with organization", func(t *testing.T) {
+		p := &awsIdentityProvider{stsClient: new(mockStsClient), orgClient: new(mockOrgClient),
+			logger: new(shared.DefaultLogger)}
+
+		id, err := p.WithOrganization().Identity()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if id.Organization != "o-mock" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("organization error", func(t *testing.T) {
+		p := &awsIdentityProvider{stsClient: new(mockStsClient), orgClient: &mockOrgClient{sendError: true},
+			logger: new(shared.DefaultLogger)}
+
+		id, err := p.WithOrganization().Identity()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(id.Organization) > 0 || id.Account != "123456789012" {
 			t.Error("data mismatch")
 		}
 	})
@@ -149,6 +182,18 @@ func (c *mockStsClient) GetCallerIdentity(context.Context, *sts.GetCallerIdentit
 		UserId:  aws.String("AIDAB0B")}, nil
 }
 
+type mockOrgClient struct {
+	sendError bool
+}
+
+func (c *mockOrgClient) DescribeOrganization(context.Context, *organizations.DescribeOrganizationInput, ...func(*organizations.Options)) (*organizations.DescribeOrganizationOutput, error) {
+	if c.sendError {
+		return nil, errors.New("error: DescribeOrganization()")
+	}
+
+	return &organizations.DescribeOrganizationOutput{Organization: &orgtypes.Organization{Id: aws.String("o-mock")}}, nil
+}
+
 // An IAM client we can use for testing to avoid calls out to AWS
 // In addition to the IAM API, we also create a number of private methods in order to manage that data used
 // by the various IAM API calls.
@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package identity
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Enrich adds the AWS caller ARN and account ID to the identity, using the GetCallerIdentity API, if they are not
+// already set.  If orgApi is not nil, the ID of the account's organization is also added using the DescribeOrganization
+// API.  Looking up the organization is best effort, since it requires permissions the caller may not have, or the
+// account may not be in an organization, so a failure only leaves the Organization field empty.  An error is returned
+// if the caller identity can not be retrieved.
+func Enrich(ctx context.Context, id *Identity, stsApi StsApi, orgApi OrgApi) error {
+	if len(id.Arn) < 1 || len(id.Account) < 1 {
+		out, err := stsApi.GetCallerIdentity(ctx, new(sts.GetCallerIdentityInput))
+		if err != nil {
+			return err
+		}
+		id.Arn = aws.ToString(out.Arn)
+		id.Account = aws.ToString(out.Account)
+	}
+
+	if orgApi != nil && len(id.Organization) < 1 {
+		id.Organization = organizationId(ctx, orgApi)
+	}
+	return nil
+}
+
+func organizationId(ctx context.Context, api OrgApi) string {
+	out, err := api.DescribeOrganization(ctx, new(organizations.DescribeOrganizationInput))
+	if err != nil || out.Organization == nil {
+		return ""
+	}
+	return aws.ToString(out.Organization.Id)
+}
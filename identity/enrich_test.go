@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package identity
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnrich(t *testing.T) {
+	t.Run("caller identity", func(t *testing.T) {
+		id := &Identity{Username: "bob"}
+		if err := Enrich(context.Background(), id, new(mockStsClient), nil); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if id.Arn != "arn:aws:iam::123456789012:user/bob" || id.Account != "123456789012" || len(id.Organization) > 0 {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("organization", func(t *testing.T) {
+		id := new(Identity)
+		if err := Enrich(context.Background(), id, new(mockStsClient), new(mockOrgClient)); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if id.Account != "123456789012" || id.Organization != "o-mock" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("organization error", func(t *testing.T) {
+		id := new(Identity)
+		if err := Enrich(context.Background(), id, new(mockStsClient), &mockOrgClient{sendError: true}); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if id.Account != "123456789012" || len(id.Organization) > 0 {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("already set", func(t *testing.T) {
+		id := &Identity{Arn: "arn:aws:iam::210987654321:user/alice", Account: "210987654321"}
+		if err := Enrich(context.Background(), id, &mockStsClient{sendError: true}, nil); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if id.Account != "210987654321" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("sts error", func(t *testing.T) {
+		if err := Enrich(context.Background(), new(Identity), &mockStsClient{sendError: true}, nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
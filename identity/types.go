@@ -15,26 +15,57 @@ package identity
 
 import (
 	"context"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"slices"
 )
 
-// Identity is the type used to store information for IAM or SAML user identity.
+// Identity is the type used to store information for IAM or SAML user identity.  The Arn, Account, and Organization
+// fields describe the AWS caller identity, and are only set by providers which look them up (see Enrich).
 type Identity struct {
 	IdentityType string
 	Provider     string
 	Username     string
+	Arn          string
+	Account      string
+	Organization string // the AWS Organizations ID of the account
 }
 
 // Roles is the list of roles the identity is allowed to assume.
 type Roles []string
 
+// ByAccount groups the roles by the AWS account ID found in the role ARN.  The roles for each account are sorted,
+// and any value which is not a valid ARN is skipped.
+func (r Roles) ByAccount() map[string]Roles {
+	m := make(map[string]Roles)
+
+	for _, v := range r {
+		a, err := arn.Parse(v)
+		if err != nil {
+			continue
+		}
+		m[a.AccountID] = append(m[a.AccountID], v)
+	}
+
+	for _, v := range m {
+		slices.Sort(v)
+	}
+
+	return m
+}
+
 // Provider is the interface which conforming identity providers will adhere to.
 type Provider interface {
 	// Identity will return the Identity information for a user.
 	Identity() (*Identity, error)
+	// IdentityWithContext will return the Identity information for a user, using the provided context.
+	IdentityWithContext(ctx context.Context) (*Identity, error)
 	// Roles returns the list of Roles the provided user is allowed to use.
 	Roles(user ...string) (*Roles, error)
+	// RolesWithContext returns the list of Roles the provided user is allowed to use, using the provided context.
+	RolesWithContext(ctx context.Context, user ...string) (*Roles, error)
 }
 
 // StsApi is a stub interface used for mocking the GetCallerIdentity AWS API call.
@@ -42,6 +73,11 @@ type StsApi interface {
 	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
 }
 
+// OrgApi is a stub interface used for mocking the DescribeOrganization AWS API call.
+type OrgApi interface {
+	DescribeOrganization(ctx context.Context, params *organizations.DescribeOrganizationInput, optFns ...func(*organizations.Options)) (*organizations.DescribeOrganizationOutput, error)
+}
+
 type iamApi interface {
 	iam.ListGroupsForUserAPIClient
 	iam.ListUserPoliciesAPIClient
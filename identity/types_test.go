@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package identity
+
+import "testing"
+
+func TestRoles_ByAccount(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		if m := new(Roles).ByAccount(); len(m) > 0 {
+			t.Error("unexpected data returned")
+		}
+	})
+
+	t.Run("good", func(t *testing.T) {
+		r := Roles{
+			"arn:aws:iam::111111111111:role/b",
+			"arn:aws:iam::222222222222:role/a",
+			"arn:aws:iam::111111111111:role/a",
+			"not an arn",
+		}
+
+		m := r.ByAccount()
+		if len(m) != 2 {
+			t.Errorf("unexpected number of accounts: %d", len(m))
+			return
+		}
+
+		if v := m["111111111111"]; len(v) != 2 || v[0] != "arn:aws:iam::111111111111:role/a" {
+			t.Errorf("data mismatch: %v", v)
+		}
+
+		if v := m["222222222222"]; len(v) != 1 {
+			t.Errorf("data mismatch: %v", v)
+		}
+	})
+}
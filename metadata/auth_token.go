@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// authTokenCookie is the cookie holding the authorization token for the web interface, since a browser can't send the
+// token in the Authorization header.
+const authTokenCookie = "aws_runas_token"
+
+// tokenHandler requires every request, except health checks, to provide the AuthToken option value when it is set, so
+// none of the endpoints serving (or changing) credentials can be used without it.  Requests provide the token in the
+// Authorization header, like the AWS SDKs do for the ECS credential endpoint.  The web interface is opened using the
+// token in the 'token' query parameter of the root page, which stores it in a cookie used for the following requests.
+func (s *metadataCredentialService) tokenHandler(next http.Handler) http.Handler {
+	if len(s.options.AuthToken) < 1 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == healthPath || s.authenticated(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.URL.Path == "/" && s.validToken(r.URL.Query().Get("token")) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     authTokenCookie,
+				Value:    s.options.AuthToken,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+			})
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+
+		http.Error(w, "invalid authorization token", http.StatusUnauthorized)
+	})
+}
+
+// authenticated returns true if the request provides the AuthToken option value in the Authorization header or the
+// token cookie, or if the AuthToken option is not set.
+func (s *metadataCredentialService) authenticated(r *http.Request) bool {
+	if len(s.options.AuthToken) < 1 || s.validToken(r.Header.Get("Authorization")) {
+		return true
+	}
+
+	c, err := r.Cookie(authTokenCookie)
+	return err == nil && s.validToken(c.Value)
+}
+
+// validToken returns true if token matches the AuthToken option value.
+func (s *metadataCredentialService) validToken(token string) bool {
+	return len(token) > 0 && subtle.ConstantTimeCompare([]byte(token), []byte(s.options.AuthToken)) == 1
+}
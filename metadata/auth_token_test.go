@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/client"
+)
+
+func TestMetadataCredentialService_tokenHandler(t *testing.T) {
+	mcs := mockMetadataCredentialService()
+	mcs.clientOptions = client.DefaultOptions
+	mcs.awsClient = new(mockAwsClient)
+	mcs.awsConfig, _ = mcs.configResolver.Config("mock")
+	mcs.options.Path = DefaultEcsCredPath
+	mcs.options.AuthToken = "s3cret"
+	h := mcs.tokenHandler(mcs.handler())
+
+	request := func(path string, mod func(r *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		if mod != nil {
+			mod(req)
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, p := range []string{ec2CredPath + "mock", identityDocPath + "document", profilePath, DefaultEcsCredPath, "/"} {
+		t.Run(fmt.Sprintf("no token %s", p), func(t *testing.T) {
+			if rec := request(p, nil); rec.Code != http.StatusUnauthorized {
+				t.Errorf("unexpected http status code: %d", rec.Code)
+			}
+		})
+	}
+
+	t.Run("bad token", func(t *testing.T) {
+		rec := request(ec2CredPath+"mock", func(r *http.Request) { r.Header.Set("Authorization", "bogus") })
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("unexpected http status code: %d", rec.Code)
+		}
+	})
+
+	t.Run("header", func(t *testing.T) {
+		rec := request(ec2CredPath+"mock", func(r *http.Request) { r.Header.Set("Authorization", "s3cret") })
+		if rec.Code != http.StatusOK {
+			t.Errorf("unexpected http status code: %d", rec.Code)
+		}
+	})
+
+	t.Run("health", func(t *testing.T) {
+		if rec := request(healthPath, nil); rec.Code == http.StatusUnauthorized {
+			t.Errorf("unexpected http status code: %d", rec.Code)
+		}
+	})
+
+	t.Run("web interface", func(t *testing.T) {
+		rec := request("/?token=s3cret", nil)
+		if rec.Code != http.StatusSeeOther {
+			t.Fatalf("unexpected http status code: %d", rec.Code)
+		}
+
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != authTokenCookie || !cookies[0].HttpOnly {
+			t.Fatalf("unexpected cookies: %v", cookies)
+		}
+
+		rec = request(listRolesPath, func(r *http.Request) { r.AddCookie(cookies[0]) })
+		if rec.Code == http.StatusUnauthorized {
+			t.Errorf("cookie not accepted")
+		}
+
+		if rec = request("/?token=bogus", nil); rec.Code != http.StatusUnauthorized {
+			t.Errorf("unexpected http status code: %d", rec.Code)
+		}
+	})
+
+	t.Run("no token configured", func(t *testing.T) {
+		mcs.options.AuthToken = ""
+		defer func() { mcs.options.AuthToken = "s3cret" }()
+
+		rec := httptest.NewRecorder()
+		mcs.tokenHandler(mcs.handler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ec2CredPath+"mock", http.NoBody))
+		if rec.Code != http.StatusOK {
+			t.Errorf("unexpected http status code: %d", rec.Code)
+		}
+	})
+}
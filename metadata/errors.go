@@ -13,6 +13,12 @@
 
 package metadata
 
+import "errors"
+
+// ErrInputRequired is the error returned when MFA or credential input is needed, but the service is
+// running non-interactively.
+var ErrInputRequired = errors.New("authentication input required, but running non-interactively")
+
 // WebAuthenticationError is a type of error signaling some sort of authentication issue with the
 // metadata credential service.
 type WebAuthenticationError string
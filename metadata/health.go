@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/shared"
+)
+
+const (
+	healthPath = "/healthz"
+
+	idpCheckTimeout = 5 * time.Second
+)
+
+// healthStatus is the body of the health endpoint response.
+type healthStatus struct {
+	Healthy          bool              `json:"healthy"`
+	Profile          string            `json:"profile,omitempty"`
+	Error            string            `json:"error,omitempty"`
+	Credentials      *credentialHealth `json:"credentials,omitempty"`
+	IdentityProvider *idpHealth        `json:"identity_provider,omitempty"`
+}
+
+// credentialHealth describes the freshness of the cached credentials for the profile.
+type credentialHealth struct {
+	Cached     bool       `json:"cached"`
+	Expired    bool       `json:"expired"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+}
+
+// idpHealth describes whether the SAML or OIDC identity provider of the profile can be reached.
+type idpHealth struct {
+	Url       string `json:"url"`
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+}
+
+// healthHandler reports the health of the service.  The service is unhealthy if there is no profile selected, or the
+// identity provider of a SAML or OIDC profile can not be reached.  Cached credentials which expired because nothing
+// asked for them do not make the service unhealthy, since they are refreshed by the next request, and neither do
+// credentials missing from the local cache (for example, when a shared cache backend is used).  Unhealthy responses
+// use the 503 status code, so the endpoint works with simple HTTP checks.  The identity provider check is skipped if
+// the idp query parameter is false, for frequent checks which only need to know the service is responding.
+//
+// The health details include the profile and identity provider, so requests without the authorization token (when
+// the AuthToken option is set) only get the status code.
+func (s *metadataCredentialService) healthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "unsupported http method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := s.health(r.Context(), r.URL.Query().Get("idp") != "false")
+
+	code := http.StatusOK
+	if !status.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if !s.authenticated(r) {
+		w.WriteHeader(code)
+		return
+	}
+
+	body, err := json.Marshal(status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
+}
+
+func (s *metadataCredentialService) health(ctx context.Context, checkIdp bool) *healthStatus {
+	cfg, cl := s.active()
+	if cfg == nil || cl == nil {
+		return &healthStatus{Error: "no profile selected"}
+	}
+
+	status := &healthStatus{Healthy: true, Profile: cfg.ProfileName, Credentials: new(credentialHealth)}
+
+	if creds, err := client.CachedCredentials(cfg); err == nil {
+		exp := creds.Expiration
+		status.Credentials.Cached = true
+		status.Credentials.Expiration = &exp
+		status.Credentials.Expired = creds.Expiration.Before(time.Now())
+	}
+
+	// the first of the identity provider URLs is the primary endpoint, any others are only used for failover
+	var u string
+	if urls := cfg.SamlUrls(); len(urls) > 0 {
+		u = urls[0]
+	} else if urls = cfg.WebIdentityUrls(); len(urls) > 0 {
+		u = urls[0]
+	}
+
+	if len(u) > 0 && checkIdp {
+		status.IdentityProvider = s.checkIdp(ctx, u)
+		if !status.IdentityProvider.Reachable {
+			status.Healthy = false
+			status.Error = "identity provider is not reachable"
+		}
+	}
+	return status
+}
+
+// checkIdp checks if the identity provider at u can be reached.  Any HTTP response means the provider is reachable,
+// since an unauthenticated request is likely to get an error status.
+func (s *metadataCredentialService) checkIdp(ctx context.Context, u string) *idpHealth {
+	h := &idpHealth{Url: u}
+
+	rt := s.clientOptions.Transport
+	if rt == nil {
+		rt = shared.DefaultTransport()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, idpCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, http.NoBody)
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+
+	hc := &http.Client{
+		Transport: rt,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := hc.Do(req)
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	_ = res.Body.Close()
+
+	h.Reachable = true
+	return h
+}
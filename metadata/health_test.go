@@ -0,0 +1,149 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+func TestMetadataCredentialService_healthHandler(t *testing.T) {
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer idp.Close()
+
+	mcs := mockMetadataCredentialService()
+	mcs.clientOptions = client.DefaultOptions
+
+	newConfig := func(t *testing.T, exp time.Time, samlUrl string) *config.AwsConfig {
+		t.Helper()
+		cfg := &config.AwsConfig{
+			ProfileName: "mock",
+			RoleArn:     "arn:aws:iam::123456789012:role/Mock",
+			SamlUrl:     samlUrl,
+			CacheDir:    t.TempDir(),
+		}
+
+		creds := &credentials.Credentials{AccessKeyId: "ASIAMOCK", SecretAccessKey: "mock", Token: "mock", Expiration: exp}
+		if err := mcs.clientFactory.ImportProfileCredentials(cfg, creds); err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+
+	health := func(path string) (int, *healthStatus) {
+		rec := httptest.NewRecorder()
+		mcs.healthHandler(rec, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+
+		status := new(healthStatus)
+		_ = json.Unmarshal(rec.Body.Bytes(), status)
+		return rec.Code, status
+	}
+
+	t.Run("no profile", func(t *testing.T) {
+		mcs.awsConfig = nil
+		mcs.awsClient = nil
+
+		if code, status := health(healthPath); code != http.StatusServiceUnavailable || status.Healthy {
+			t.Errorf("unexpected health status: %d %+v", code, status)
+		}
+	})
+
+	t.Run("healthy", func(t *testing.T) {
+		mcs.awsConfig = newConfig(t, time.Now().Add(1*time.Hour), idp.URL)
+		mcs.awsClient = new(mockAwsClient)
+
+		code, status := health(healthPath)
+		if code != http.StatusOK || !status.Healthy || !status.Credentials.Cached || status.Credentials.Expired {
+			t.Errorf("unexpected health status: %d %+v", code, status)
+		}
+
+		if status.IdentityProvider == nil || !status.IdentityProvider.Reachable {
+			t.Errorf("unexpected identity provider status: %+v", status.IdentityProvider)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		mcs.awsConfig = newConfig(t, time.Now().Add(-1*time.Hour), "")
+		mcs.awsClient = new(mockAwsClient)
+
+		// credentials expiring while nobody uses them are refreshed by the next request
+		if code, status := health(healthPath); code != http.StatusOK || !status.Healthy || !status.Credentials.Expired {
+			t.Errorf("unexpected health status: %d %+v", code, status)
+		}
+	})
+
+	t.Run("failover urls", func(t *testing.T) {
+		mcs.awsConfig = newConfig(t, time.Now().Add(1*time.Hour), idp.URL+"/saml, http://127.0.0.1:1/saml")
+		mcs.awsClient = new(mockAwsClient)
+
+		code, status := health(healthPath)
+		if code != http.StatusOK || status.IdentityProvider == nil || status.IdentityProvider.Url != idp.URL+"/saml" {
+			t.Errorf("unexpected health status: %d %+v", code, status.IdentityProvider)
+		}
+	})
+
+	t.Run("no token", func(t *testing.T) {
+		mcs.awsConfig = newConfig(t, time.Now().Add(1*time.Hour), "")
+		mcs.awsClient = new(mockAwsClient)
+		mcs.options.AuthToken = "mockToken"
+		defer func() { mcs.options.AuthToken = "" }()
+
+		rec := httptest.NewRecorder()
+		mcs.healthHandler(rec, httptest.NewRequest(http.MethodGet, healthPath, http.NoBody))
+		if rec.Code != http.StatusOK || rec.Body.Len() > 0 {
+			t.Errorf("unexpected health response: %d %s", rec.Code, rec.Body.String())
+		}
+
+		req := httptest.NewRequest(http.MethodGet, healthPath, http.NoBody)
+		req.Header.Set("Authorization", "mockToken")
+		rec = httptest.NewRecorder()
+		mcs.healthHandler(rec, req)
+		if rec.Code != http.StatusOK || rec.Body.Len() < 1 {
+			t.Errorf("unexpected health response: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("idp unreachable", func(t *testing.T) {
+		mcs.awsConfig = newConfig(t, time.Now().Add(1*time.Hour), "")
+		mcs.awsConfig.WebIdentityUrl = "http://127.0.0.1:1/oauth2"
+		mcs.awsClient = new(mockAwsClient)
+
+		code, status := health(healthPath)
+		if code != http.StatusServiceUnavailable || status.IdentityProvider == nil || status.IdentityProvider.Reachable {
+			t.Errorf("unexpected health status: %d %+v", code, status)
+		}
+
+		if code, status = health(healthPath + "?idp=false"); code != http.StatusOK || status.IdentityProvider != nil {
+			t.Errorf("unexpected health status: %d %+v", code, status)
+		}
+	})
+
+	t.Run("unsupported method", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		mcs.healthHandler(rec, httptest.NewRequest(http.MethodPost, healthPath, http.NoBody))
+
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("unexpected http status code: %d", rec.Code)
+		}
+	})
+}
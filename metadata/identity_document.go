@@ -0,0 +1,294 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"path"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+const (
+	identityDocPath = "/latest/dynamic/instance-identity/"
+
+	fakeInstanceId = "i-00000000000000000"
+	fakeAccountId  = "000000000000"
+	fakeSignerName = "aws-runas fake instance identity signer"
+)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSha256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRsaEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+
+	identitySigner = new(fakeIdentitySigner)
+)
+
+// instanceIdentityDocument is the EC2 instance identity document, as returned by IMDS.  Anything not
+// derived from the active profile is a fixed, obviously fake, value.
+type instanceIdentityDocument struct {
+	AccountId        string    `json:"accountId"`
+	Architecture     string    `json:"architecture"`
+	AvailabilityZone string    `json:"availabilityZone"`
+	BillingProducts  []string  `json:"billingProducts"`
+	ImageId          string    `json:"imageId"`
+	InstanceId       string    `json:"instanceId"`
+	InstanceType     string    `json:"instanceType"`
+	KernelId         *string   `json:"kernelId"`
+	PendingTime      time.Time `json:"pendingTime"`
+	PrivateIp        string    `json:"privateIp"`
+	RamdiskId        *string   `json:"ramdiskId"`
+	Region           string    `json:"region"`
+	Version          string    `json:"version"`
+}
+
+// identityDocHandler serves the instance identity document, and (unless disabled with the NoIdentitySignature
+// option) its signatures.  The signatures are made with a self-signed certificate created when the service
+// starts, so they are well-formed but will never validate against the AWS public certificates.  This lets tools
+// which fetch the signature work in a degraded mode for local development, instead of failing outright.
+func (s *metadataCredentialService) identityDocHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	doc := s.identityDocument(r)
+
+	switch path.Base(r.URL.Path) {
+	case "document":
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write(doc)
+		return
+	case "signature", "pkcs7", "rsa2048":
+		if s.options.NoIdentitySignature {
+			break
+		}
+
+		var sig []byte
+		var err error
+		if path.Base(r.URL.Path) == "signature" {
+			sig, err = identitySigner.sign(doc)
+		} else {
+			sig, err = identitySigner.pkcs7(doc)
+		}
+
+		if err != nil {
+			logger.Errorf("error signing instance identity document: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write(wrapBase64(sig))
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// identityDocument builds the instance identity document for the active profile.  The output is the same for
+// every call using the same profile, so a signature fetched separately will match the document.
+func (s *metadataCredentialService) identityDocument(r *http.Request) []byte {
+	doc := instanceIdentityDocument{
+		AccountId:    fakeAccountId,
+		Architecture: runtime.GOARCH,
+		ImageId:      "ami-00000000000000000",
+		InstanceId:   fakeInstanceId,
+		InstanceType: "t3.micro",
+		PendingTime:  identitySigner.started().UTC().Truncate(time.Second),
+		PrivateIp:    "127.0.0.1",
+		Region:       "us-east-1",
+		Version:      "2017-09-30",
+	}
+
+	if runtime.GOARCH == "amd64" {
+		doc.Architecture = "x86_64"
+	}
+
+	if cfg, cl := s.active(); cfg != nil {
+		if len(cfg.Region) > 0 {
+			doc.Region = cfg.Region
+		}
+
+		if a, err := arn.Parse(cfg.RoleArn); err == nil {
+			doc.AccountId = a.AccountID
+		} else if cl != nil {
+			if id, err := cl.IdentityWithContext(r.Context()); err == nil && len(id.Account) > 0 {
+				doc.AccountId = id.Account
+			}
+		}
+	}
+	doc.AvailabilityZone = doc.Region + "a"
+
+	j, _ := json.MarshalIndent(doc, "", "  ")
+	return j
+}
+
+// wrapBase64 encodes the data as base64, split in to 64 character lines, like the signatures returned by IMDS.
+func wrapBase64(data []byte) []byte {
+	enc := base64.StdEncoding.EncodeToString(data)
+
+	buf := new(bytes.Buffer)
+	for len(enc) > 64 {
+		buf.WriteString(enc[:64])
+		buf.WriteByte('\n')
+		enc = enc[64:]
+	}
+	buf.WriteString(enc)
+	return buf.Bytes()
+}
+
+// fakeIdentitySigner holds the key and self-signed certificate used to sign instance identity documents.
+// They are created on first use, and live for the life of the process.
+type fakeIdentitySigner struct {
+	once  sync.Once
+	start time.Time
+	key   *rsa.PrivateKey
+	cert  *x509.Certificate
+	err   error
+}
+
+func (f *fakeIdentitySigner) init() {
+	f.once.Do(func() {
+		f.start = time.Now()
+
+		f.key, f.err = rsa.GenerateKey(rand.Reader, 2048)
+		if f.err != nil {
+			return
+		}
+
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(f.start.UnixNano()),
+			Subject: pkix.Name{
+				CommonName:   fakeSignerName,
+				Organization: []string{"NOT A VALID AWS SIGNATURE"},
+			},
+			NotBefore: f.start.Add(-1 * time.Hour),
+			NotAfter:  f.start.Add(365 * 24 * time.Hour),
+			KeyUsage:  x509.KeyUsageDigitalSignature,
+		}
+
+		var der []byte
+		der, f.err = x509.CreateCertificate(rand.Reader, tmpl, tmpl, &f.key.PublicKey, f.key)
+		if f.err != nil {
+			return
+		}
+		f.cert, f.err = x509.ParseCertificate(der)
+	})
+}
+
+func (f *fakeIdentitySigner) started() time.Time {
+	f.init()
+	return f.start
+}
+
+// sign returns the PKCS#1 v1.5 SHA-256 RSA signature of the data.
+func (f *fakeIdentitySigner) sign(data []byte) ([]byte, error) {
+	if f.init(); f.err != nil {
+		return nil, f.err
+	}
+
+	sum := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, sum[:])
+}
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // [0] EXPLICIT, built with explicitTag()
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue     // [0] IMPLICIT
+	SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+}
+
+type pkcs7IssuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type pkcs7SignerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     pkcs7IssuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+}
+
+// pkcs7 returns the DER encoded PKCS#7 SignedData structure, with the data and the signing certificate
+// embedded, like the pkcs7 and rsa2048 IMDS endpoints.
+func (f *fakeIdentitySigner) pkcs7(data []byte) ([]byte, error) {
+	sig, err := f.sign(data)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := asn1.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sha256Alg := pkix.AlgorithmIdentifier{Algorithm: oidSha256, Parameters: asn1.NullRawValue}
+	sd := pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Alg},
+		ContentInfo: pkcs7ContentInfo{
+			ContentType: oidData,
+			Content:     explicitTag(content),
+		},
+		Certificates: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: f.cert.Raw},
+		SignerInfos: []pkcs7SignerInfo{{
+			Version: 1,
+			IssuerAndSerialNumber: pkcs7IssuerAndSerial{
+				Issuer:       asn1.RawValue{FullBytes: f.cert.RawIssuer},
+				SerialNumber: f.cert.SerialNumber,
+			},
+			DigestAlgorithm:           sha256Alg,
+			DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRsaEncryption, Parameters: asn1.NullRawValue},
+			EncryptedDigest:           sig,
+		}},
+	}
+
+	sdBytes, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pkcs7ContentInfo{
+		ContentType: oidSignedData,
+		Content:     explicitTag(sdBytes),
+	})
+}
+
+// explicitTag wraps the DER encoded value in a context-specific [0] tag.  The asn1 package ignores struct
+// field tags for RawValue fields, so this must be done by hand.
+func explicitTag(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+func TestMetadataCredentialService_identityDocHandler(t *testing.T) {
+	mcs := mockMetadataCredentialService()
+	mcs.awsClient = new(mockAwsClient)
+	mcs.awsConfig = &config.AwsConfig{
+		ProfileName: "mock",
+		Region:      "eu-west-1",
+		RoleArn:     "arn:aws:iam::123456789012:role/Admin",
+	}
+
+	get := func(t *testing.T, p string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mcs.identityDocHandler(rec, httptest.NewRequest(http.MethodGet, identityDocPath+p, http.NoBody))
+		return rec
+	}
+
+	doc := get(t, "document")
+	if doc.Code != http.StatusOK {
+		t.Fatalf("unexpected http status code: %d", doc.Code)
+	}
+
+	t.Run("document", func(t *testing.T) {
+		d := new(instanceIdentityDocument)
+		if err := json.Unmarshal(doc.Body.Bytes(), d); err != nil {
+			t.Fatal(err)
+		}
+
+		if d.AccountId != "123456789012" || d.Region != "eu-west-1" || d.AvailabilityZone != "eu-west-1a" ||
+			d.InstanceId != fakeInstanceId {
+			t.Errorf("unexpected document: %+v", d)
+		}
+
+		if again := get(t, "document"); again.Body.String() != doc.Body.String() {
+			t.Error("document changed between requests")
+		}
+	})
+
+	t.Run("signature", func(t *testing.T) {
+		rec := get(t, "signature")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("unexpected http status code: %d", rec.Code)
+		}
+
+		for _, l := range strings.Split(rec.Body.String(), "\n") {
+			if len(l) > 64 {
+				t.Errorf("signature line too long: %d", len(l))
+			}
+		}
+
+		sig, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(rec.Body.String(), "\n", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sum := sha256.Sum256(doc.Body.Bytes())
+		pub := identitySigner.cert.PublicKey.(*rsa.PublicKey)
+		if err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig); err != nil {
+			t.Error(err)
+		}
+
+		if !strings.Contains(identitySigner.cert.Subject.CommonName, "fake") {
+			t.Error("signing certificate is not clearly fake")
+		}
+	})
+
+	t.Run("pkcs7", func(t *testing.T) {
+		for _, p := range []string{"pkcs7", "rsa2048"} {
+			rec := get(t, p)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected http status code: %d", rec.Code)
+			}
+
+			der, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(rec.Body.String(), "\n", ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ci := new(pkcs7ContentInfo)
+			if _, err = asn1.Unmarshal(der, ci); err != nil {
+				t.Fatal(err)
+			}
+
+			if !ci.ContentType.Equal(oidSignedData) {
+				t.Errorf("unexpected content type: %s", ci.ContentType)
+			}
+
+			sd := new(pkcs7SignedData)
+			if _, err = asn1.Unmarshal(ci.Content.Bytes, sd); err != nil {
+				t.Fatal(err)
+			}
+
+			var content []byte
+			if _, err = asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &content); err != nil {
+				t.Fatal(err)
+			}
+
+			if string(content) != doc.Body.String() || len(sd.SignerInfos) != 1 {
+				t.Error("signed data does not contain the document")
+			}
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		mcs.options.NoIdentitySignature = true
+		defer func() { mcs.options.NoIdentitySignature = false }()
+
+		for _, p := range []string{"signature", "pkcs7", "rsa2048"} {
+			if rec := get(t, p); rec.Code != http.StatusNotFound {
+				t.Errorf("unexpected http status code for %s: %d", p, rec.Code)
+			}
+		}
+
+		if rec := get(t, "document"); rec.Code != http.StatusOK {
+			t.Errorf("unexpected http status code: %d", rec.Code)
+		}
+	})
+
+	t.Run("unknown path", func(t *testing.T) {
+		if rec := get(t, "bogus"); rec.Code != http.StatusNotFound {
+			t.Errorf("unexpected http status code: %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+// errOwnerCredentials is returned when a user of a multi-user service selects a profile which would provide the
+// credentials of the user running the service.
+var errOwnerCredentials = errors.New("profile not allowed in multi-user mode")
+
+// peerUidKey is the request context key holding the user id of the process which sent the request.
+type peerUidKey struct{}
+
+// connContext adds the user id of the process on the other end of the connection to the context of the requests
+// received on c.  Requests on connections where the user can not be identified are rejected by multiUserHandler().
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	uid, err := peerUid(c)
+	if err != nil {
+		logger.Debugf("unable to identify connecting user: %v", err)
+		return ctx
+	}
+	return context.WithValue(ctx, peerUidKey{}, uid)
+}
+
+// multiUserHandler returns the handler used in multi-user mode, which sends each request to a service dedicated to the
+// user making the request.  Users have their own active profile, client, and credential cache, so the credentials and
+// identity of one user are never served to another.
+func (s *metadataCredentialService) multiUserHandler() http.Handler {
+	mu := new(sync.Mutex)
+	users := make(map[uint32]http.Handler)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := r.Context().Value(peerUidKey{}).(uint32)
+		if !ok {
+			http.Error(w, "unable to identify the requesting user", http.StatusForbidden)
+			return
+		}
+
+		mu.Lock()
+		h, ok := users[uid]
+		if !ok {
+			logger.Infof("starting session for uid %d", uid)
+			h = s.userService(uid).handler()
+			users[uid] = h
+		}
+		mu.Unlock()
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// userService returns a copy of the service for the user with the given uid.  The copy has no active profile (unless
+// one was provided in the Options), and keeps its cached credentials in a directory separate from other users.
+func (s *metadataCredentialService) userService(uid uint32) *metadataCredentialService {
+	opts := *s.options
+	clientOpts := *s.clientOptions
+
+	us := new(metadataCredentialService)
+	us.options = &opts
+	us.listener = s.listener
+	us.webhook = s.webhook
+	us.configResolver = &userResolver{Resolver: s.configResolver, uid: uid}
+	us.clientOptions = &clientOpts
+	us.clientOptions.CacheKeyScope = "users/" + strconv.FormatUint(uint64(uid), 10) + "/"
+	us.clientFactory = client.NewClientFactory(us.configResolver, us.clientOptions)
+
+	if len(opts.Profile) > 0 {
+		// authentication is deferred until the first credential request, so it's done by the user and not the service
+		var err error
+		if us.awsConfig, us.awsClient, err = us.getConfigAndClient(opts.Profile); err != nil {
+			logger.Warningf("unable to set initial profile '%s' for uid %d: %v", opts.Profile, uid, err)
+		}
+	}
+	return us
+}
+
+// userResolver is a config.Resolver which isolates the cached credentials of a user of a multi-user service.
+// Credentials saved in the shared credentials file belong to the user running the service, and are never provided.
+// Profiles which get credentials without the user authenticating to an identity provider would hand out the
+// credentials of the user running the service, and are refused.
+type userResolver struct {
+	config.Resolver
+	uid uint32
+}
+
+// Config returns the configuration for profile, with the cache directory set to the user's private directory.
+func (r *userResolver) Config(profile string) (*config.AwsConfig, error) {
+	cfg, err := r.Resolver.Config(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if !userAuthenticated(cfg) {
+		return nil, fmt.Errorf("%w: profile '%s' uses the credentials of the user running the service",
+			errOwnerCredentials, profile)
+	}
+
+	cfg.CacheDir = userCacheDir(cfg.CacheDir, r.uid)
+	return cfg, nil
+}
+
+// userAuthenticated returns true if the profile gets its credentials by having the user authenticate to a SAML or
+// Web Identity provider.  Profiles using IAM user credentials, a web identity token file, or a credential process
+// all get credentials belonging to the user running the service.
+func userAuthenticated(cfg *config.AwsConfig) bool {
+	if len(cfg.SamlUrl) < 1 && len(cfg.SamlMetadataUrl) < 1 && len(cfg.WebIdentityUrl) < 1 {
+		return false
+	}
+	return len(cfg.WebIdentityTokenFile) < 1 && len(cfg.SubjectTokenFile) < 1 && len(cfg.BaseCredentialProcess) < 1
+}
+
+// Credentials always returns empty credentials, users must provide their own when authentication is required.
+func (r *userResolver) Credentials(string) (*config.AwsCredentials, error) {
+	return new(config.AwsCredentials), nil
+}
+
+// userCacheDir returns the cache directory for the user with the given uid, which is a sub-directory of dir, or of the
+// cache directory of the user running the service if dir is not set.
+func userCacheDir(dir string, uid uint32) string {
+	if len(dir) < 1 {
+		if dir = os.Getenv("AWS_RUNAS_CACHE_DIR"); len(dir) < 1 {
+			base, err := os.UserCacheDir()
+			if err != nil {
+				base = os.TempDir()
+			}
+			dir = filepath.Join(base, "aws-runas")
+		}
+	}
+	return filepath.Join(dir, "users", strconv.FormatUint(uint64(uid), 10))
+}
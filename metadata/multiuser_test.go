@@ -0,0 +1,191 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+func TestMetadataCredentialService_multiUserHandler(t *testing.T) {
+	mcs := mockMetadataCredentialService()
+	mcs.clientOptions = client.DefaultOptions
+	h := mcs.multiUserHandler()
+
+	request := func(uid any) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, healthPath, http.NoBody)
+		if uid != nil {
+			r = r.WithContext(context.WithValue(r.Context(), peerUidKey{}, uid))
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	t.Run("unknown user", func(t *testing.T) {
+		if rec := request(nil); rec.Code != http.StatusForbidden {
+			t.Errorf("unexpected http status code: %d", rec.Code)
+		}
+	})
+
+	t.Run("user", func(t *testing.T) {
+		// no profile selected for the user, so the service is not healthy
+		if rec := request(uint32(1000)); rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("unexpected http status code: %d", rec.Code)
+		}
+	})
+}
+
+func TestMetadataCredentialService_userService(t *testing.T) {
+	t.Setenv("AWS_RUNAS_CACHE_DIR", t.TempDir())
+	mcs := mockMetadataCredentialService()
+	mcs.clientOptions = client.DefaultOptions
+	mcs.options.Profile = "mock"
+	mcs.awsClient = new(mockAwsClient)
+
+	us := mcs.userService(1000)
+	if us.options == mcs.options || us.clientOptions == mcs.clientOptions {
+		t.Error("options are shared with the parent service")
+	}
+
+	if us.awsConfig == nil || us.awsConfig.ProfileName != "mock" {
+		t.Fatalf("initial profile not set: %+v", us.awsConfig)
+	}
+
+	if us.awsConfig.CacheDir != userCacheDir("", 1000) {
+		t.Errorf("unexpected cache dir: %s", us.awsConfig.CacheDir)
+	}
+
+	if us.clientOptions.CacheKeyScope != "users/1000/" || len(mcs.clientOptions.CacheKeyScope) > 0 {
+		t.Errorf("unexpected cache key scope: %s", us.clientOptions.CacheKeyScope)
+	}
+}
+
+func Test_userResolver(t *testing.T) {
+	t.Setenv("AWS_RUNAS_CACHE_DIR", t.TempDir())
+	r := &userResolver{Resolver: new(mockConfigResolver), uid: 1000}
+
+	t.Run("config", func(t *testing.T) {
+		cfg, err := r.Config("mock")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cfg.ProfileName != "mock" || cfg.CacheDir != userCacheDir("", 1000) {
+			t.Errorf("data mismatch: %+v", cfg)
+		}
+	})
+
+	t.Run("owner credentials", func(t *testing.T) {
+		tests := map[string]*config.AwsConfig{
+			"iam":          {RoleArn: "arn:aws:iam::123456789012:role/Admin"},
+			"token file":   {WebIdentityUrl: "https://idp.local", WebIdentityTokenFile: "/tmp/token"},
+			"process":      {SamlUrl: "https://idp.local/saml", BaseCredentialProcess: "true"},
+			"session only": {MfaSerial: "arn:aws:iam::123456789012:mfa/me"},
+		}
+
+		for k, v := range tests {
+			or := &userResolver{Resolver: &staticResolver{cfg: v}, uid: 1000}
+			if _, err := or.Config(k); !errors.Is(err, errOwnerCredentials) {
+				t.Errorf("%s: did not receive expected error: %v", k, err)
+			}
+		}
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		creds, err := r.Credentials("mock")
+		if err != nil || creds == nil || len(creds.SamlPassword) > 0 || len(creds.WebIdentityPassword) > 0 {
+			t.Errorf("unexpected credentials: %+v %v", creds, err)
+		}
+	})
+}
+
+func Test_userCacheDir(t *testing.T) {
+	t.Run("configured", func(t *testing.T) {
+		if d := userCacheDir("/tmp/cache", 1000); d != filepath.Join("/tmp/cache", "users", "1000") {
+			t.Errorf("unexpected cache dir: %s", d)
+		}
+	})
+
+	t.Run("env var", func(t *testing.T) {
+		t.Setenv("AWS_RUNAS_CACHE_DIR", "/tmp/env")
+		if d := userCacheDir("", 1000); d != filepath.Join("/tmp/env", "users", "1000") {
+			t.Errorf("unexpected cache dir: %s", d)
+		}
+	})
+
+	t.Run("separate users", func(t *testing.T) {
+		if userCacheDir("", 1000) == userCacheDir("", 1001) {
+			t.Error("users share a cache dir")
+		}
+	})
+}
+
+func Test_connContext(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only supported on linux")
+	}
+
+	path := filepath.Join(t.TempDir(), "runas.sock")
+	l, err := listenUnix(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		c, err := net.Dial("unix", path)
+		if err == nil {
+			defer c.Close()
+			_, _ = c.Read(make([]byte, 1))
+		}
+	}()
+
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	uid, ok := connContext(context.Background(), c).Value(peerUidKey{}).(uint32)
+	if !ok || int(uid) != os.Getuid() {
+		t.Errorf("unexpected peer uid: %d", uid)
+	}
+}
+
+// staticResolver returns a copy of the same configuration for every profile.
+type staticResolver struct {
+	cfg *config.AwsConfig
+}
+
+func (r *staticResolver) Config(profile string) (*config.AwsConfig, error) {
+	cfg := *r.cfg
+	cfg.ProfileName = profile
+	return &cfg, nil
+}
+
+func (r *staticResolver) Credentials(string) (*config.AwsCredentials, error) {
+	return new(config.AwsCredentials), nil
+}
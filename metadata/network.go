@@ -62,9 +62,54 @@ func findInterfaceByAddress(addr string) (*net.Interface, error) {
 	return nil, errors.New("no interface found for address")
 }
 
+// SetupImdsRedirect configures the EC2 IMDS address on the loopback interface, and redirects requests for port 80 of
+// that address to the provided port on 127.0.0.1.  This allows programs to use the default EC2 IMDS endpoint with the
+// EC2 metadata service running without administrator/root authority, which is only required for this setup.  The
+// configuration remains in place until RemoveImdsRedirect is called (or the system is restarted).
+func SetupImdsRedirect(port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port %d", port)
+	}
+
+	iface, err := discoverLoopback()
+	if err != nil {
+		return err
+	}
+
+	if _, err = findInterfaceByAddress(DefaultEc2ImdsAddr); err != nil {
+		if err = addAddress(iface, DefaultEc2ImdsCidr); err != nil {
+			return err
+		}
+	}
+
+	return addRedirect(iface, port)
+}
+
+// RemoveImdsRedirect removes the configuration done by SetupImdsRedirect for the provided port.
+func RemoveImdsRedirect(port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port %d", port)
+	}
+
+	iface, err := discoverLoopback()
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(removeRedirect(iface, port), removeAddress())
+}
+
 func doCommand(cmd []string) error {
+	return doCommandInput(cmd, "")
+}
+
+// doCommandInput runs the command, with the provided input (if any) as stdin.
+func doCommandInput(cmd []string, input string) error {
 	c := exec.Command(cmd[0], cmd[1:]...) //nolint:gosec
 	c.Stdin = nil
+	if len(input) > 0 {
+		c.Stdin = strings.NewReader(input)
+	}
 	c.Stdout = os.Stdout
 	c.Stderr = os.Stderr
 
@@ -15,7 +15,20 @@
 
 package metadata
 
-import "net"
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// pfAnchor returns the pf anchor for the redirect rule.  MacOS only evaluates anchors under com.apple with the default
+// pf configuration, other systems must reference the aws-runas anchor in pf.conf.
+func pfAnchor() string {
+	if runtime.GOOS == "darwin" {
+		return "com.apple/aws-runas"
+	}
+	return "aws-runas"
+}
 
 func addAddress(iface *net.Interface, cidrAddr string) error {
 	cmd := []string{"ifconfig", iface.Name, "alias", cidrAddr}
@@ -33,3 +46,18 @@ func removeAddress() error {
 	cmd := []string{"ifconfig", iface.Name, "-alias", DefaultEc2ImdsAddr}
 	return doCommand(cmd)
 }
+
+func addRedirect(iface *net.Interface, port int) error {
+	rule := fmt.Sprintf("rdr pass on %s inet proto tcp from any to %s port 80 -> 127.0.0.1 port %d\n",
+		iface.Name, DefaultEc2ImdsAddr, port)
+	if err := doCommandInput([]string{"pfctl", "-a", pfAnchor(), "-f", "-"}, rule); err != nil {
+		return err
+	}
+
+	// enabling pf when it's already enabled is harmless
+	return doCommand([]string{"pfctl", "-E"})
+}
+
+func removeRedirect(_ *net.Interface, _ int) error {
+	return doCommand([]string{"pfctl", "-a", pfAnchor(), "-F", "all"})
+}
@@ -15,7 +15,10 @@
 
 package metadata
 
-import "net"
+import (
+	"fmt"
+	"net"
+)
 
 func addAddress(iface *net.Interface, cidrAddr string) error {
 	cmd := []string{"ip", "address", "add", cidrAddr, "dev", iface.Name}
@@ -33,3 +36,17 @@ func removeAddress() error {
 	cmd := []string{"ip", "address", "del", DefaultEc2ImdsCidr, "dev", iface.Name}
 	return doCommand(cmd)
 }
+
+// redirect requests to the IMDS address made from this host, which only go through the OUTPUT chain.
+func redirectRule(op string, port int) []string {
+	return []string{"iptables", "-t", "nat", op, "OUTPUT", "-p", "tcp", "-d", DefaultEc2ImdsCidr, "--dport", "80",
+		"-j", "DNAT", "--to-destination", fmt.Sprintf("127.0.0.1:%d", port)}
+}
+
+func addRedirect(_ *net.Interface, port int) error {
+	return doCommand(redirectRule("-A", port))
+}
+
+func removeRedirect(_ *net.Interface, port int) error {
+	return doCommand(redirectRule("-D", port))
+}
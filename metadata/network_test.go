@@ -55,3 +55,15 @@ func Test_doCommand(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestImdsRedirect_InvalidPort(t *testing.T) {
+	for _, p := range []int{-1, 0, 65536} {
+		if err := SetupImdsRedirect(p); err == nil {
+			t.Errorf("did not receive expected error for setup with port %d", p)
+		}
+
+		if err := RemoveImdsRedirect(p); err == nil {
+			t.Errorf("did not receive expected error for remove with port %d", p)
+		}
+	}
+}
@@ -16,6 +16,7 @@
 package metadata
 
 import (
+	"fmt"
 	"net"
 )
 
@@ -40,3 +41,15 @@ func removeAddress() error {
 	cmd := []string{"netsh", "interface", "ipv4", "delete", "address", iface.Name, DefaultEc2ImdsAddr}
 	return doCommand(cmd)
 }
+
+func addRedirect(_ *net.Interface, port int) error {
+	cmd := []string{"netsh", "interface", "portproxy", "add", "v4tov4", "listenaddress=" + DefaultEc2ImdsAddr,
+		"listenport=80", "connectaddress=127.0.0.1", fmt.Sprintf("connectport=%d", port)}
+	return doCommand(cmd)
+}
+
+func removeRedirect(_ *net.Interface, _ int) error {
+	cmd := []string{"netsh", "interface", "portproxy", "delete", "v4tov4", "listenaddress=" + DefaultEc2ImdsAddr,
+		"listenport=80"}
+	return doCommand(cmd)
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import "strings"
+
+// PipePrefix is the prefix of the path of a Windows named pipe, addresses starting with it are served using a named pipe
+// instead of a TCP port.
+const PipePrefix = `\\.\pipe\`
+
+// pipeAddr is the net.Addr of a named pipe, which is its path.
+type pipeAddr string
+
+// Network returns the name of the network.
+func (a pipeAddr) Network() string {
+	return "pipe"
+}
+
+// String returns the path of the named pipe.
+func (a pipeAddr) String() string {
+	return string(a)
+}
+
+// IsPipeAddr returns true if addr is the path of a Windows named pipe.
+func IsPipeAddr(addr string) bool {
+	return strings.HasPrefix(strings.ToLower(addr), PipePrefix)
+}
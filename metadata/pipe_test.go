@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestIsPipeAddr(t *testing.T) {
+	t.Run("pipe", func(t *testing.T) {
+		if !IsPipeAddr(`\\.\pipe\aws-runas`) {
+			t.Error("pipe address not detected")
+		}
+	})
+
+	t.Run("case insensitive", func(t *testing.T) {
+		if !IsPipeAddr(`\\.\PIPE\aws-runas`) {
+			t.Error("pipe address not detected")
+		}
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		if IsPipeAddr("127.0.0.1:12319") {
+			t.Error("tcp address detected as pipe")
+		}
+	})
+}
+
+func Test_pipeAddr(t *testing.T) {
+	a := pipeAddr(`\\.\pipe\aws-runas`)
+	if a.Network() != "pipe" || a.String() != `\\.\pipe\aws-runas` {
+		t.Error("data mismatch")
+	}
+}
+
+func Test_listenPipe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("named pipes are supported on windows")
+	}
+
+	if _, err := listenPipe(`\\.\pipe\aws-runas`); err == nil {
+		t.Error("did not receive expected error")
+	}
+}
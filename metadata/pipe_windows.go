@@ -0,0 +1,197 @@
+//go:build windows
+
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// pipeBufferSize is the size of the input and output buffers of each pipe instance.
+const pipeBufferSize = 64 * 1024
+
+// pipeListener is a net.Listener accepting connections on a Windows named pipe.  Only the user running the service is
+// allowed to connect, and connections from other hosts are rejected.
+type pipeListener struct {
+	path    string
+	name    *uint16
+	sa      *windows.SecurityAttributes
+	closeEv windows.Handle
+	mu      sync.Mutex
+	next    windows.Handle // pipe instance for the next Accept call
+	closed  bool
+}
+
+// listenPipe creates the first instance of the named pipe at path, so problems like the pipe already being in use
+// are reported before the service starts.
+func listenPipe(path string) (net.Listener, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sd, err := pipeSecurityDescriptor()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create named pipe security descriptor: %w", err)
+	}
+
+	l := &pipeListener{path: path, name: name}
+	l.sa = &windows.SecurityAttributes{SecurityDescriptor: sd}
+	l.sa.Length = uint32(unsafe.Sizeof(*l.sa))
+
+	if l.closeEv, err = windows.CreateEvent(nil, 1, 0, nil); err != nil {
+		return nil, err
+	}
+
+	if l.next, err = l.newInstance(windows.FILE_FLAG_FIRST_PIPE_INSTANCE); err != nil {
+		_ = windows.CloseHandle(l.closeEv)
+		return nil, fmt.Errorf("unable to create named pipe %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// pipeSecurityDescriptor returns a security descriptor granting access only to the user running the process.
+func pipeSecurityDescriptor() (*windows.SECURITY_DESCRIPTOR, error) {
+	u, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return nil, err
+	}
+	return windows.SecurityDescriptorFromString(fmt.Sprintf("D:P(A;;GA;;;%s)", u.User.Sid.String()))
+}
+
+func (l *pipeListener) newInstance(flags uint32) (windows.Handle, error) {
+	return windows.CreateNamedPipe(l.name,
+		windows.PIPE_ACCESS_DUPLEX|windows.FILE_FLAG_OVERLAPPED|flags,
+		windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+		windows.PIPE_UNLIMITED_INSTANCES, pipeBufferSize, pipeBufferSize, 0, l.sa)
+}
+
+// Accept waits for a client to connect to an instance of the pipe, and returns the connection.
+func (l *pipeListener) Accept() (net.Conn, error) {
+	h, err := l.instance()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = l.connect(h); err != nil {
+		_ = windows.CloseHandle(h)
+		return nil, err
+	}
+
+	// the handle was opened for overlapped I/O, so the os.File uses the runtime's I/O completion port, which allows
+	// concurrent reads and writes, and deadlines
+	return &pipeConn{File: os.NewFile(uintptr(h), l.path), handle: h, addr: pipeAddr(l.path)}, nil
+}
+
+func (l *pipeListener) instance() (windows.Handle, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return 0, net.ErrClosed
+	}
+
+	if h := l.next; h != 0 {
+		l.next = 0
+		return h, nil
+	}
+	return l.newInstance(0)
+}
+
+// connect waits for a client to connect to the pipe instance, or for the listener to be closed.
+func (l *pipeListener) connect(h windows.Handle) error {
+	ev, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(ev) //nolint:errcheck
+
+	ov := &windows.Overlapped{HEvent: ev}
+	err = windows.ConnectNamedPipe(h, ov)
+	if err == nil || errors.Is(err, windows.ERROR_PIPE_CONNECTED) {
+		return nil
+	}
+
+	if !errors.Is(err, windows.ERROR_IO_PENDING) {
+		return err
+	}
+
+	var n uint32
+	i, err := windows.WaitForMultipleObjects([]windows.Handle{ev, l.closeEv}, false, windows.INFINITE)
+	if err != nil || i != windows.WAIT_OBJECT_0 {
+		_ = windows.CancelIoEx(h, ov)
+		_ = windows.GetOverlappedResult(h, ov, &n, true)
+		if err == nil {
+			err = net.ErrClosed
+		}
+		return err
+	}
+	return windows.GetOverlappedResult(h, ov, &n, false)
+}
+
+// Close stops accepting connections.  Connections which were already accepted are not closed.
+func (l *pipeListener) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+
+	if l.next != 0 {
+		_ = windows.CloseHandle(l.next)
+		l.next = 0
+	}
+
+	// wake any Accept waiting for a connection, the event is left open since that Accept may still be using it
+	return windows.SetEvent(l.closeEv)
+}
+
+// Addr returns the path of the named pipe.
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr(l.path)
+}
+
+// pipeConn is a connection to a client of a named pipe.
+type pipeConn struct {
+	*os.File
+	handle windows.Handle
+	addr   pipeAddr
+}
+
+// Close waits for the client to read the data written to the pipe, then disconnects the client and closes the pipe.
+func (c *pipeConn) Close() error {
+	_ = windows.FlushFileBuffers(c.handle)
+	_ = windows.DisconnectNamedPipe(c.handle)
+	return c.File.Close()
+}
+
+// LocalAddr returns the path of the named pipe.
+func (c *pipeConn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+// RemoteAddr returns the path of the named pipe, since pipe clients have no address of their own.
+func (c *pipeConn) RemoteAddr() net.Addr {
+	return c.addr
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay is how long to wait after the last change to a watched file before reloading, since editors and the
+// config writers in this tool may touch a file more than once during a single update.
+const reloadDelay = 500 * time.Millisecond
+
+// watchConfig reloads the active profile when any of the files is changed, until ctx is done.  The parent directory
+// of each file is watched, instead of the file itself, so files which are replaced (not written in place) or created
+// after the service starts are still detected.
+func (s *metadataCredentialService) watchConfig(ctx context.Context, files ...string) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	watched := make(map[string]bool)
+	for _, f := range files {
+		f = filepath.Clean(f)
+		watched[f] = true
+
+		if err = w.Add(filepath.Dir(f)); err != nil {
+			logger.Debugf("unable to watch %s for changes: %v", f, err)
+		}
+	}
+
+	go func() {
+		defer w.Close()
+
+		var reload <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-w.Events:
+				if !ok {
+					return
+				}
+
+				if watched[filepath.Clean(e.Name)] && !e.Has(fsnotify.Chmod) {
+					reload = time.After(reloadDelay)
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				logger.Debugf("configuration file watcher error: %v", err)
+			case <-reload:
+				reload = nil
+				s.reloadConfig()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// reloadConfig resolves the active profile again, replacing the running configuration and client.  Profiles which
+// are not active are always resolved from the files when selected, so only the active profile needs to be reloaded.
+// The current client is kept if the profile is no longer valid, or if a request selected another profile while the
+// active profile was reloaded.
+func (s *metadataCredentialService) reloadConfig() {
+	// custom profiles which are not saved to the config file have no name, and nothing to reload
+	cur, _ := s.active()
+	if cur == nil || len(cur.ProfileName) < 1 {
+		logger.Infof("configuration files changed")
+		return
+	}
+	profile := cur.ProfileName
+
+	cfg, cl, err := s.getConfigAndClient(profile)
+	if err != nil {
+		logger.Warningf("unable to reload profile '%s', keeping current client: %v", profile, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.awsConfig == nil || s.awsConfig.ProfileName != profile {
+		logger.Debugf("active profile changed while reloading profile '%s'", profile)
+		return
+	}
+
+	s.awsConfig = cfg
+	s.awsClient = cl
+	logger.Infof("configuration files changed, reloaded profile '%s'", profile)
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+func TestMetadataCredentialService_reloadConfig(t *testing.T) {
+	t.Run("no profile", func(t *testing.T) {
+		mcs := mockMetadataCredentialService()
+		mcs.configResolver = &reloadResolver{}
+		mcs.reloadConfig()
+
+		if mcs.awsConfig != nil || mcs.awsClient != nil {
+			t.Error("unexpected config or client after reload")
+		}
+	})
+
+	t.Run("good", func(t *testing.T) {
+		mcs := mockMetadataCredentialService()
+		cl := new(mockAwsClient)
+		mcs.awsConfig = &config.AwsConfig{ProfileName: "mock", SamlUrl: "https://old.local/saml"}
+		mcs.awsClient = cl
+		mcs.reloadConfig()
+
+		if mcs.awsConfig.SamlUrl != "https://mock.local/saml" || mcs.awsConfig.SamlProvider != "mock" {
+			t.Errorf("profile was not reloaded: %+v", mcs.awsConfig)
+		}
+
+		if mcs.awsClient != cl {
+			t.Error("unexpected client after reload")
+		}
+	})
+
+	t.Run("concurrent requests", func(t *testing.T) {
+		mcs := mockMetadataCredentialService()
+		mcs.awsConfig = &config.AwsConfig{ProfileName: "mock"}
+		mcs.awsClient = new(mockAwsClient)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				mcs.reloadConfig()
+			}()
+			go func() {
+				defer wg.Done()
+				rec := httptest.NewRecorder()
+				mcs.ec2CredHandler(rec, httptest.NewRequest(http.MethodGet, ec2CredPath, http.NoBody))
+			}()
+		}
+		wg.Wait()
+
+		if cfg, cl := mcs.active(); cfg.ProfileName != "mock" || cl == nil {
+			t.Errorf("unexpected config or client after reload: %+v", cfg)
+		}
+	})
+
+	t.Run("bad config", func(t *testing.T) {
+		mcs := mockMetadataCredentialService()
+		mcs.configResolver = &reloadResolver{err: errors.New("profile not found")}
+		cfg := &config.AwsConfig{ProfileName: "mock"}
+		cl := new(mockAwsClient)
+		mcs.awsConfig = cfg
+		mcs.awsClient = cl
+		mcs.reloadConfig()
+
+		if mcs.awsConfig != cfg || mcs.awsClient != cl {
+			t.Error("current configuration was not kept")
+		}
+	})
+}
+
+func TestMetadataCredentialService_watchConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "config")
+
+	r := new(reloadResolver)
+	mcs := mockMetadataCredentialService()
+	mcs.configResolver = r
+	mcs.awsConfig = &config.AwsConfig{ProfileName: "mock"}
+	mcs.awsClient = new(mockAwsClient)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := mcs.watchConfig(ctx, cfgFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// changes to other files in the directory are ignored
+	if err := os.WriteFile(filepath.Join(dir, "other"), []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * reloadDelay)
+
+	if r.calls.Load() > 0 {
+		t.Fatal("reloaded after change to unwatched file")
+	}
+
+	if err := os.WriteFile(cfgFile, []byte("[profile mock]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for the reload to complete, not only start
+	for i := 0; i < 50; i++ {
+		if cfg, _ := mcs.active(); cfg.SamlProvider == "mock" {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if r.calls.Load() != 1 {
+		t.Errorf("unexpected number of reloads: %d", r.calls.Load())
+	}
+}
+
+type reloadResolver struct {
+	err   error
+	calls atomic.Int32
+}
+
+func (r *reloadResolver) Config(profile string) (*config.AwsConfig, error) {
+	r.calls.Add(1)
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return &config.AwsConfig{
+		SamlProvider: "mock",
+		ProfileName:  profile,
+	}, nil
+}
+
+func (r *reloadResolver) Credentials(string) (*config.AwsCredentials, error) {
+	return new(config.AwsCredentials), r.err
+}
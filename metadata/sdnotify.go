@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sdNotify sends the state to the systemd service manager, using the socket in the NOTIFY_SOCKET environment variable.
+// It does nothing if the variable is not set, which is the case when not running as a systemd notify service.
+func sdNotify(state string) error {
+	sock := os.Getenv("NOTIFY_SOCKET")
+	if len(sock) < 1 {
+		return nil
+	}
+
+	// a leading @ is an abstract namespace socket
+	if sock[0] == '@' {
+		sock = "\x00" + sock[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: sock, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// sdWatchdogInterval returns how often the watchdog must be notified, which is half of the timeout systemd sets in the
+// WATCHDOG_USEC environment variable, or 0 if the watchdog is not enabled for this process.
+func sdWatchdogInterval() time.Duration {
+	if p := os.Getenv("WATCHDOG_PID"); len(p) > 0 && p != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec < 1 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// notifyReady tells systemd the service is ready, and starts notifying the watchdog, if enabled.  The returned function
+// stops the watchdog notifications, and tells systemd the service is stopping.  Nothing is sent unless the SystemdNotify
+// option is set, since programs run by aws-runas inherit NOTIFY_SOCKET.
+// The watchdog is only notified when the health endpoint of the service responds, so a service which stops serving
+// requests is restarted by systemd.  The response status is not checked, since restarting the service does not fix
+// expired credentials, or an unreachable identity provider.
+func (s *metadataCredentialService) notifyReady() func() {
+	if !s.options.SystemdNotify {
+		return func() {}
+	}
+
+	if err := sdNotify("READY=1"); err != nil {
+		logger.Warningf("unable to notify systemd: %v", err)
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := func() {
+		cancel()
+		_ = sdNotify("STOPPING=1")
+	}
+
+	interval := sdWatchdogInterval()
+	if interval < 1 {
+		return stop
+	}
+
+	addr := s.listener.Addr()
+	hc := &http.Client{
+		Timeout: interval,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return new(net.Dialer).DialContext(ctx, addr.Network(), addr.String())
+			},
+		},
+	}
+
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if s.alive(ctx, hc) {
+					_ = sdNotify("WATCHDOG=1")
+				} else {
+					logger.Warningf("health endpoint is not responding, not notifying the systemd watchdog")
+				}
+			}
+		}
+	}()
+	return stop
+}
+
+// alive returns true if the health endpoint returns any response, the identity provider check is skipped.
+func (s *metadataCredentialService) alive(ctx context.Context, hc *http.Client) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, "http://localhost"+healthPath+"?idp=false", http.NoBody)
+	if err != nil {
+		return false
+	}
+
+	res, err := hc.Do(req)
+	if err != nil {
+		return false
+	}
+	_ = res.Body.Close()
+	return true
+}
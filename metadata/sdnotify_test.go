@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_sdNotify(t *testing.T) {
+	t.Run("no socket", func(t *testing.T) {
+		t.Setenv("NOTIFY_SOCKET", "")
+		if err := sdNotify("READY=1"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("socket", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("unixgram sockets are not supported on windows")
+		}
+
+		dir, err := os.MkdirTemp("", "sd") // keep the path short, socket paths have a small size limit
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		sock := filepath.Join(dir, "notify")
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sock, Net: "unixgram"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		t.Setenv("NOTIFY_SOCKET", sock)
+		if err = sdNotify("READY=1"); err != nil {
+			t.Error(err)
+			return
+		}
+
+		b := make([]byte, 64)
+		_ = conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+		n, err := conn.Read(b)
+		if err != nil || string(b[:n]) != "READY=1" {
+			t.Errorf("unexpected notification: %s %v", b[:n], err)
+		}
+	})
+}
+
+func Test_sdWatchdogInterval(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		t.Setenv("WATCHDOG_USEC", "")
+		if sdWatchdogInterval() != 0 {
+			t.Error("unexpected watchdog interval")
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Setenv("WATCHDOG_USEC", "10000000")
+		t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+		if sdWatchdogInterval() != 5*time.Second {
+			t.Error("unexpected watchdog interval")
+		}
+	})
+
+	t.Run("other process", func(t *testing.T) {
+		t.Setenv("WATCHDOG_USEC", "10000000")
+		t.Setenv("WATCHDOG_PID", "1")
+		if sdWatchdogInterval() != 0 {
+			t.Error("unexpected watchdog interval")
+		}
+	})
+}
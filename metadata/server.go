@@ -21,11 +21,15 @@ import (
 	"fmt"
 	"github.com/aws/smithy-go/logging"
 	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/client/external"
 	"github.com/mmmorris1975/aws-runas/config"
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"github.com/mmmorris1975/aws-runas/credentials/helpers"
+	"github.com/mmmorris1975/aws-runas/identity"
 	"github.com/mmmorris1975/aws-runas/shared"
 	"github.com/syndtr/gocapability/capability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"io"
 	"maps"
 	"net"
@@ -35,6 +39,7 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
@@ -60,6 +65,7 @@ const (
 	listRolesPath    = "/list-roles"
 	listProfilesPath = "/list-profiles"
 	refreshPath      = "/refresh"
+	samlRolesPath    = "/saml-roles"
 )
 
 var (
@@ -80,16 +86,54 @@ type Options struct {
 	Logger      shared.Logger
 	AwsLogLevel logging.Classification
 	Headless    bool
+	AuthToken   string // if set, all requests (except health checks) must provide this value in the Authorization header
+	// NonInteractive disables prompting for MFA codes and credentials, used when there is no terminal available
+	NonInteractive bool
+	// WebhookUrl is the https url sent credential and authentication events, if set
+	WebhookUrl string
+	// WebhookSecret is the key used to sign the webhook requests, if set
+	WebhookSecret string
+	// SystemdNotify sends readiness and watchdog notifications to systemd, when run as a notify service
+	SystemdNotify bool
+	// MultiUser isolates the active profile and cached credentials of each user connecting to a unix socket address
+	MultiUser bool
+	// NoIdentitySignature disables the fake signatures of the EC2 instance identity document, only the document is served
+	NoIdentitySignature bool
+	// EcsOnly serves only the ECS credential endpoint and health check, without the EC2 metadata or profile handlers
+	EcsOnly bool
 }
 
 type metadataCredentialService struct {
-	options        *Options
+	options *Options
+	// mu guards awsClient and awsConfig, which are replaced (never modified in place) when the active profile changes
+	mu             sync.RWMutex
 	awsClient      client.AwsClient
 	awsConfig      *config.AwsConfig
 	configResolver config.Resolver
 	clientFactory  *client.Factory
 	clientOptions  *client.Options
 	listener       net.Listener
+	webhook        *webhookNotifier
+	// aliasMu guards aliasSaml and aliases, the account aliases looked up for the most recent SAML assertion
+	aliasMu   sync.Mutex
+	aliasSaml string
+	aliases   map[string]string
+}
+
+// active returns the configuration and client of the active profile.  They are replaced, not modified, when the active
+// profile changes, so they are safe to use after the lock is released.
+func (s *metadataCredentialService) active() (*config.AwsConfig, client.AwsClient) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.awsConfig, s.awsClient
+}
+
+// setActive replaces the configuration and client of the active profile.
+func (s *metadataCredentialService) setActive(cfg *config.AwsConfig, cl client.AwsClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.awsConfig = cfg
+	s.awsClient = cl
 }
 
 // NewMetadataCredentialService creates a new metadataCredentialService using the supplied 'opts' Options.
@@ -108,8 +152,15 @@ func NewMetadataCredentialService(addr string, opts *Options) (*metadataCredenti
 	mcs.clientOptions = client.DefaultOptions
 	mcs.clientOptions.Logger = logger
 	mcs.clientOptions.AwsLogLevel = opts.AwsLogLevel
+	mcs.clientOptions.MemoryCache = true // SDKs may poll for credentials often, avoid reading the cache file each time
 
 	var err error
+	if len(opts.WebhookUrl) > 0 {
+		if mcs.webhook, err = newWebhookNotifier(opts.WebhookUrl, opts.WebhookSecret); err != nil {
+			return nil, err
+		}
+	}
+
 	if strings.HasPrefix(addr, DefaultEc2ImdsAddr) && os.Getuid() != 0 && runtime.GOOS == "linux" {
 		logger.Debugf("enabling Linux capabilities")
 		if err = linuxSetCap(); err != nil {
@@ -117,7 +168,22 @@ func NewMetadataCredentialService(addr string, opts *Options) (*metadataCredenti
 		}
 	}
 
-	mcs.listener, err = configureListener(addr)
+	if opts.MultiUser {
+		// users are identified by the peer credentials of the unix socket connection
+		if !IsUnixAddr(addr) {
+			return nil, errors.New("multi-user mode requires a unix socket address")
+		}
+
+		if runtime.GOOS != "linux" {
+			return nil, errors.New("multi-user mode is only supported on Linux")
+		}
+	}
+
+	if IsUnixAddr(addr) {
+		mcs.listener, err = listenUnix(strings.TrimPrefix(addr, UnixPrefix), opts.MultiUser)
+	} else {
+		mcs.listener, err = configureListener(addr)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -151,45 +217,55 @@ func (s *metadataCredentialService) Addr() net.Addr {
 func (s *metadataCredentialService) Run() error {
 	s.clientFactory = client.NewClientFactory(s.configResolver, s.clientOptions)
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", logHandler(s.rootHandler))
-	mux.HandleFunc(authPath, logHandler(s.authHandler))
-	mux.HandleFunc(mfaPath, logHandler(s.mfaHandler))
-	mux.HandleFunc(profilePath, logHandler(s.profileHandler))
-	mux.HandleFunc(listRolesPath, logHandler(s.listRolesHandler))
-	mux.HandleFunc(refreshPath, logHandler(s.refreshHandler))
-	mux.HandleFunc(imdsTokenPath, logHandler(s.imdsV2TokenHandler))
-	mux.HandleFunc(ec2CredPath, logHandler(s.ec2CredHandler))
-	mux.HandleFunc(newProfilePath, logHandler(s.customProfileHandler))
-	mux.HandleFunc(listProfilesPath, logHandler(s.listProfilesHandler))
-
 	if len(s.options.Path) > 0 {
-		// configure ECS http handlers with logging
-		mux.HandleFunc(s.options.Path, logHandler(s.ecsCredHandler))
-		mux.HandleFunc(s.options.Path+`/`, logHandler(s.ecsCredHandler))
-
 		// print ECS credential endpoint message
-		logger.Infof("ECS credential endpoint set to http://%s%s", s.Addr().String(), s.options.Path)
-		logger.Infof("Set the AWS_CONTAINER_CREDENTIALS_FULL_URI environment variable with the above value to allow programs to use it")
+		switch {
+		case IsPipeAddr(s.Addr().String()):
+			logger.Infof("ECS credential endpoint set to path %s on named pipe %s", s.options.Path, s.Addr().String())
+		case s.Addr().Network() == "unix":
+			logger.Infof("ECS credential endpoint set to path %s on unix socket %s", s.options.Path, s.Addr().String())
+		default:
+			logger.Infof("ECS credential endpoint set to http://%s%s", s.Addr().String(), s.options.Path)
+			logger.Infof("Set the AWS_CONTAINER_CREDENTIALS_FULL_URI environment variable with the above value to allow programs to use it")
+		}
 	} else if !strings.HasPrefix(s.listener.Addr().String(), DefaultEc2ImdsAddr) {
 		// print non-default EC2 IMDS endpoint message
-		logger.Infof("EC2 metadata endpoint set to http://%s/", s.Addr().String())
-		logger.Infof("Set the AWS_EC2_METADATA_SERVICE_ENDPOINT environment variable with the above value to allow programs to use it")
+		ep := fmt.Sprintf("http://%s/", s.Addr().String())
+		logger.Infof("EC2 metadata endpoint set to %s", ep)
+		logger.Infof("Set these environment variables to allow programs to use it:")
+
+		env := Ec2EndpointEnv(ep)
+		for _, k := range slices.Sorted(maps.Keys(env)) {
+			logger.Infof("  %s=%s", k, env[k])
+		}
 	}
 
-	if len(s.options.Profile) > 0 {
+	if s.options.MultiUser {
+		// each user selects their own profile, which happens in userService()
+		logger.Infof("Multi-user mode enabled, users select a profile by sending its name in a POST request to %s on the unix socket", profilePath)
+	} else if len(s.options.Profile) > 0 {
 		// since we don't have a valid http server yet, we need to bang on profileHandler() directly
 		r, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, profilePath,
 			strings.NewReader(s.options.Profile))
 		s.profileHandler(httptest.NewRecorder(), r)
 		logger.Infof("Using initial profile '%s'", s.options.Profile)
+	} else if p := s.restoreState(context.Background()); len(p) > 0 {
+		logger.Infof("Restored profile '%s' which was active when the service stopped", p)
+	} else if IsPipeAddr(s.Addr().String()) {
+		logger.Infof("Select a profile by sending its name in a POST request to %s on the named pipe", profilePath)
+	} else if s.Addr().Network() == "unix" {
+		logger.Infof("Select a profile by sending its name in a POST request to %s on the unix socket", profilePath)
+	} else if len(s.options.AuthToken) > 0 {
+		logger.Infof("Access the web interface at http://%s/?token=%s and select a profile to begin",
+			s.Addr().String(), s.options.AuthToken)
 	} else {
 		logger.Infof("Access the web interface at http://%s and select a profile to begin", s.Addr().String())
 	}
 
-	if !s.options.Headless {
+	if !s.options.Headless || s.options.MultiUser {
 		// install web-aware credential and mfa handlers after calling profileHandler() so that initial prompting for
-		// missing authentication information is sent to the command line during startup.
+		// missing authentication information is sent to the command line during startup.  The command line is never
+		// used in multi-user mode, since it belongs to the user running the service.
 		s.clientOptions.MfaInputProvider = func() (string, error) {
 			return "", NewWebMfaRequiredError()
 		}
@@ -197,31 +273,114 @@ func (s *metadataCredentialService) Run() error {
 		s.clientOptions.CredentialInputProvider = func(_ string, _ string) (string, string, error) {
 			return "", "", NewWebAuthenticationError()
 		}
+
+		// the web interface can't change an expired password, so it's reported as an authentication error
+		s.clientOptions.PasswordChangeProvider = nil
+
+		// nor select a role, a role pattern matching multiple roles is reported as an error
+		s.clientOptions.RoleSelectionProvider = nil
+	}
+
+	// reload the active profile when the config files change, other profiles are read from the files when selected
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.watchConfig(ctx, config.SharedConfigFiles()...); err != nil {
+		logger.Warningf("unable to watch configuration files, changes will require a restart: %v", err)
 	}
 
 	srv := new(http.Server)
-	srv.Handler = mux
+	srv.Handler = s.tokenHandler(s.handler())
+	if s.options.MultiUser {
+		srv.Handler = s.multiUserHandler()
+		srv.ConnContext = connContext
+	}
+	// events for the last requests are sent before the service exits, after the server shuts down
+	defer s.webhook.wait()
 	defer cleanup(srv, s.listener)
 
+	stopNotify := s.notifyReady()
+	defer stopNotify()
+
 	installSigHandler(srv, s.listener)
 	return srv.Serve(s.listener)
 }
 
+// Ec2EndpointEnv returns the environment variables which point the AWS SDKs to the EC2 metadata service at the
+// endpoint URL, for services not listening on the default IMDS address.  The awscli (botocore) metadata timeout of
+// 1 second is raised, since getting credentials from an external identity provider may take longer than that.
+func Ec2EndpointEnv(endpoint string) map[string]string {
+	return map[string]string{
+		"AWS_EC2_METADATA_SERVICE_ENDPOINT": endpoint,
+		"AWS_EC2_METADATA_DISABLED":         "false",
+		"AWS_METADATA_SERVICE_TIMEOUT":      "5",
+		"AWS_METADATA_SERVICE_NUM_ATTEMPTS": "2",
+	}
+}
+
+// handler returns the handler for all of the endpoints provided by the Run() method.
+func (s *metadataCredentialService) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", logHandler(s.rootHandler))
+	mux.HandleFunc(authPath, logHandler(s.authHandler))
+	mux.HandleFunc(mfaPath, logHandler(s.mfaHandler))
+	mux.HandleFunc(profilePath, logHandler(s.profileHandler))
+	mux.HandleFunc(listRolesPath, logHandler(s.listRolesHandler))
+	mux.HandleFunc(refreshPath, logHandler(s.refreshHandler))
+	mux.HandleFunc(imdsTokenPath, logHandler(s.imdsV2TokenHandler))
+	mux.HandleFunc(ec2CredPath, logHandler(s.ec2CredHandler))
+	mux.HandleFunc(identityDocPath, logHandler(s.identityDocHandler))
+	mux.HandleFunc(newProfilePath, logHandler(s.customProfileHandler))
+	mux.HandleFunc(listProfilesPath, logHandler(s.listProfilesHandler))
+	mux.HandleFunc(samlRolesPath, logHandler(s.samlRolesHandler))
+	mux.HandleFunc(healthPath, s.healthHandler) // frequently polled, do not log requests
+
+	if len(s.options.Path) > 0 {
+		// configure ECS http handlers with logging
+		mux.HandleFunc(s.options.Path, logHandler(s.ecsCredHandler))
+		mux.HandleFunc(s.options.Path+`/`, logHandler(s.ecsCredHandler))
+	}
+	return mux
+}
+
 // RunNoApi starts the metadataCredentialService with the bare minimum endpoints required to serve
 // the EC2 or ECS services, management and SAML/OIDC authentication API endpoints are not provided.
 func (s *metadataCredentialService) RunNoApi(cl client.AwsClient, cfg *config.AwsConfig, readyCh chan<- bool) error {
-	s.clientOptions.MfaInputProvider = helpers.NewMfaTokenProvider(os.Stdin).ReadInput
-	s.clientOptions.CredentialInputProvider = helpers.NewUserPasswordInputProvider(os.Stdin).ReadInput
+	if s.options.NonInteractive {
+		s.clientOptions.MfaInputProvider = func() (string, error) {
+			return "", ErrInputRequired
+		}
+
+		s.clientOptions.CredentialInputProvider = func(_ string, _ string) (string, string, error) {
+			return "", "", ErrInputRequired
+		}
+
+		s.clientOptions.PasswordChangeProvider = func() (string, error) {
+			return "", ErrInputRequired
+		}
+
+		s.clientOptions.RoleSelectionProvider = func(_ []string, _ string) (string, error) {
+			return "", ErrInputRequired
+		}
+	} else {
+		s.clientOptions.MfaInputProvider = helpers.NewMfaTokenProvider(os.Stdin).ReadInput
+		s.clientOptions.CredentialInputProvider = helpers.NewUserPasswordInputProvider(os.Stdin).ReadInput
+		s.clientOptions.PasswordChangeProvider = helpers.NewPasswordChangeInputProvider(os.Stdin).ReadInput
+		s.clientOptions.RoleSelectionProvider = helpers.NewRoleSelectionInputProvider(os.Stdin).ReadInput
+	}
 
 	s.clientFactory = client.NewClientFactory(s.configResolver, s.clientOptions)
-	s.awsClient = cl
-	s.awsConfig = cfg
+	s.setActive(cfg, cl)
 
 	// only configure the handlers useful when running without a browser, do not use request logging
 	mux := http.NewServeMux()
-	mux.HandleFunc(profilePath, s.profileHandler)
-	mux.HandleFunc(imdsTokenPath, s.imdsV2TokenHandler)
-	mux.HandleFunc(ec2CredPath, s.ec2CredHandler)
+	mux.HandleFunc(healthPath, s.healthHandler)
+
+	if !s.options.EcsOnly {
+		mux.HandleFunc(profilePath, s.profileHandler)
+		mux.HandleFunc(imdsTokenPath, s.imdsV2TokenHandler)
+		mux.HandleFunc(ec2CredPath, s.ec2CredHandler)
+		mux.HandleFunc(identityDocPath, s.identityDocHandler)
+	}
 
 	if len(s.options.Path) > 0 {
 		// configure ECS http handlers without request logging
@@ -232,10 +391,22 @@ func (s *metadataCredentialService) RunNoApi(cl client.AwsClient, cfg *config.Aw
 		logger.Debugf("EC2 metadata endpoint set to http://%s/", s.Addr().String())
 	}
 
+	// reload the active profile when the config files change, like the full service does
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.watchConfig(ctx, config.SharedConfigFiles()...); err != nil {
+		logger.Debugf("unable to watch configuration files: %v", err)
+	}
+
 	srv := new(http.Server)
-	srv.Handler = mux
+	srv.Handler = s.tokenHandler(mux)
+	// events for the last requests are sent before the service exits, after the server shuts down
+	defer s.webhook.wait()
 	defer cleanup(srv, s.listener)
 
+	stopNotify := s.notifyReady()
+	defer stopNotify()
+
 	if readyCh != nil {
 		readyCh <- true
 	}
@@ -256,6 +427,7 @@ func (s *metadataCredentialService) rootHandler(w http.ResponseWriter, r *http.R
 			"custom_ep":   newProfilePath,
 			"profiles_ep": listProfilesPath,
 			"refresh_ep":  refreshPath,
+			"saml_ep":     samlRolesPath,
 		}
 
 		tmpl := template.Must(template.ParseFS(content, "templates/site.js"))
@@ -277,6 +449,7 @@ func (s *metadataCredentialService) rootHandler(w http.ResponseWriter, r *http.R
 //nolint:gocognit
 func (s *metadataCredentialService) profileHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
+	cfg, _ := s.active()
 
 	if r.Method == http.MethodPost {
 		body, err := io.ReadAll(io.LimitReader(r.Body, 256)) // if there's a profile name longer than this ... I mean, really
@@ -287,7 +460,9 @@ func (s *metadataCredentialService) profileHandler(w http.ResponseWriter, r *htt
 		profile := strings.TrimSpace(string(body))
 
 		// Do this unconditionally so we can get any potential changes in the config or credentials files
-		s.awsConfig, s.awsClient, err = s.getConfigAndClient(profile)
+		var cl client.AwsClient
+		cfg, cl, err = s.getConfigAndClient(profile)
+		s.setActive(cfg, cl)
 		if err != nil {
 			// this could be an auth error trying to initialize a saml or oidc client
 			s.handleAuthError(err, w)
@@ -295,21 +470,22 @@ func (s *metadataCredentialService) profileHandler(w http.ResponseWriter, r *htt
 		}
 
 		// fetch credentials after switching profile to see if we should re-auth while we have their attention
-		if _, err = s.awsClient.Credentials(); err != nil {
+		if _, err = cl.CredentialsWithContext(r.Context()); err != nil {
 			s.handleAuthError(err, w)
 			return
 		}
 
-		logger.Debugf("updated profile to %s", s.awsConfig.ProfileName)
+		logger.Debugf("updated profile to %s", cfg.ProfileName)
+		s.saveState()
 	} else {
-		if s.awsConfig == nil || len(s.awsConfig.ProfileName) < 1 {
+		if cfg == nil || len(cfg.ProfileName) < 1 {
 			http.Error(w, "profile not set", http.StatusInternalServerError)
 			return
 		}
-		logger.Debugf("profile: %s", s.awsConfig.ProfileName)
+		logger.Debugf("profile: %s", cfg.ProfileName)
 	}
 
-	_, _ = w.Write(marshalProfile(s.awsConfig))
+	_, _ = w.Write(marshalProfile(cfg))
 }
 
 func marshalProfile(cfg *config.AwsConfig) []byte {
@@ -354,11 +530,13 @@ func (s *metadataCredentialService) imdsV2TokenHandler(w http.ResponseWriter, r
 func (s *metadataCredentialService) ec2CredHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
+	cfg, cl := s.active()
 	p := strings.Split(r.URL.Path, "/")[1:]
 	if len(p[len(p)-1]) < 1 {
-		_, _ = w.Write([]byte(s.awsConfig.ProfileName))
+		_, _ = w.Write([]byte(cfg.ProfileName))
 	} else {
-		creds, err := s.awsClient.Credentials()
+		creds, err := cl.CredentialsWithContext(client.WithAuditCaller(r.Context(), auditCaller(r)))
+		s.webhook.notifyCredentials(credentialEvent(cfg, creds, r, err))
 		if err != nil {
 			s.handleAuthError(err, w)
 			return
@@ -383,10 +561,18 @@ func (s *metadataCredentialService) ecsCredHandler(w http.ResponseWriter, r *htt
 	var creds *credentials.Credentials
 	var err error
 
-	cl := s.awsClient
+	// also checked by tokenHandler(), but the ECS endpoint must never serve credentials without the token
+	if len(s.options.AuthToken) > 0 && !s.validToken(r.Header.Get("Authorization")) {
+		http.Error(w, "invalid authorization token", http.StatusUnauthorized)
+		return
+	}
+
+	cfg, active := s.active()
+	cl := active
 	if cl == nil {
-		cl, err = s.clientFactory.Get(s.awsConfig)
+		cl, err = s.clientFactory.Get(cfg)
 		if err != nil {
+			s.webhook.notifyCredentials(credentialEvent(cfg, nil, r, err))
 			s.handleAuthError(err, w)
 			return
 		}
@@ -400,9 +586,9 @@ func (s *metadataCredentialService) ecsCredHandler(w http.ResponseWriter, r *htt
 		parts := strings.Split(r.URL.Path, `/`)
 		profile := parts[len(parts)-1]
 
-		var cfg *config.AwsConfig
 		cfg, cl, err = s.getConfigAndClient(profile)
 		if err != nil {
+			s.webhook.notifyCredentials(credentialEvent(&config.AwsConfig{ProfileName: profile}, nil, r, err))
 			logger.Errorf("Client fetch: %v", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -411,11 +597,12 @@ func (s *metadataCredentialService) ecsCredHandler(w http.ResponseWriter, r *htt
 		// this really only exists to facilitate testing, since clientFactory is a concrete type.
 		// Ideally, we make it an interface and mock it, but for the 1 case we need it for, this is sufficient
 		if cfg.SamlProvider == "mock" {
-			cl = s.awsClient
+			cl = active
 		}
 	}
 
-	creds, err = cl.Credentials()
+	creds, err = cl.CredentialsWithContext(client.WithAuditCaller(r.Context(), auditCaller(r)))
+	s.webhook.notifyCredentials(credentialEvent(cfg, creds, r, err))
 	if err != nil {
 		s.handleAuthError(err, w)
 		return
@@ -428,10 +615,18 @@ func (s *metadataCredentialService) ecsCredHandler(w http.ResponseWriter, r *htt
 	_, _ = w.Write(ecsCreds)
 }
 
+// auditCaller identifies the caller of a credential request in the audit log, using the remote address and user agent.
+func auditCaller(r *http.Request) string {
+	if ua := r.UserAgent(); len(ua) > 0 {
+		return fmt.Sprintf("%s %s", r.RemoteAddr, ua)
+	}
+	return r.RemoteAddr
+}
+
 func (s *metadataCredentialService) refreshHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodPost && s.awsClient != nil {
+	if _, cl := s.active(); r.Method == http.MethodPost && cl != nil {
 		logger.Debugf("Refreshing credentials")
-		if err := s.awsClient.ClearCache(); err != nil {
+		if err := cl.ClearCache(); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -469,6 +664,126 @@ func (s *metadataCredentialService) listProfilesHandler(w http.ResponseWriter, _
 	writeJson(w, p)
 }
 
+// samlAccount is the JSON representation of the roles available to an AWS account in a SAML assertion.
+type samlAccount struct {
+	AccountId string         `json:"account_id"`
+	Alias     string         `json:"alias,omitempty"`
+	Roles     identity.Roles `json:"roles"`
+}
+
+// GET requests return the roles available in the SAML assertion for the active profile, grouped by AWS account.
+// POST requests switch the active configuration to the role ARN provided in the request body, which must be one of
+// the roles available in the SAML assertion.
+func (s *metadataCredentialService) samlRolesHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	cur, curClient := s.active()
+	if cur == nil || curClient == nil || len(cur.SamlUrl) < 1 {
+		http.Error(w, "active profile is not a SAML profile", http.StatusBadRequest)
+		return
+	}
+
+	roles, err := curClient.RolesWithContext(r.Context())
+	if err != nil {
+		s.handleAuthError(err, w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJson(w, s.samlAccounts(r.Context(), curClient, *roles))
+	case http.MethodPost:
+		var body []byte
+		body, err = io.ReadAll(io.LimitReader(r.Body, 2048))
+		if err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		role := strings.TrimSpace(string(body))
+		if !slices.Contains(*roles, role) {
+			http.Error(w, "role not found in SAML assertion", http.StatusBadRequest)
+			return
+		}
+
+		// don't carry over the jump role or the profile name (and associated cache) for the newly selected role
+		cfg := *cur
+		cfg.RoleArn = role
+		cfg.JumpRoleArn = ""
+		cfg.ProfileName = ""
+
+		var cl client.AwsClient
+		cl, err = s.clientFactory.Get(&cfg)
+		if err != nil {
+			s.handleAuthError(err, w)
+			return
+		}
+
+		if _, err = cl.CredentialsWithContext(r.Context()); err != nil {
+			s.handleAuthError(err, w)
+			return
+		}
+
+		s.setActive(&cfg, cl)
+		logger.Debugf("updated SAML role to %s", role)
+
+		_, _ = w.Write(marshalProfile(&cfg))
+	default:
+		http.Error(w, "unsupported http method", http.StatusMethodNotAllowed)
+	}
+}
+
+// samlAccounts returns the roles grouped by AWS account, sorted by account ID.  If the client is able to provide the
+// SAML assertion, the account aliases are looked up and added to the result.  Failures to look up account aliases are
+// logged, and are not considered fatal.
+func (s *metadataCredentialService) samlAccounts(ctx context.Context, cl client.AwsClient, roles identity.Roles) []samlAccount {
+	aliases := make(map[string]string)
+	if c, ok := cl.(client.SamlAssertionClient); ok {
+		if saml, err := c.SamlAssertionWithContext(ctx); err == nil {
+			aliases = s.accountAliases(ctx, saml)
+		}
+	}
+
+	accounts := make([]samlAccount, 0)
+	for k, v := range roles.ByAccount() {
+		accounts = append(accounts, samlAccount{AccountId: k, Alias: aliases[k], Roles: v})
+	}
+
+	slices.SortFunc(accounts, func(a, b samlAccount) int {
+		return strings.Compare(a.AccountId, b.AccountId)
+	})
+
+	return accounts
+}
+
+// accountAliases returns the account aliases for the roles in the SAML assertion.  The aliases are only looked up once
+// for each assertion, since the role list is requested each time the role selector in the web UI is opened.
+func (s *metadataCredentialService) accountAliases(ctx context.Context, saml *credentials.SamlAssertion) map[string]string {
+	s.aliasMu.Lock()
+	defer s.aliasMu.Unlock()
+
+	if s.aliases != nil && s.aliasSaml == saml.String() {
+		return s.aliases
+	}
+
+	var aliases map[string]string
+	var err error
+	if s.clientFactory != nil {
+		aliases, err = s.clientFactory.AccountAliases(ctx, saml)
+	} else {
+		aliases, err = external.AccountAliases(ctx, saml, nil)
+	}
+
+	if err != nil {
+		logger.Debugf("error looking up account aliases: %v", err)
+		return make(map[string]string)
+	}
+
+	s.aliasSaml = saml.String()
+	s.aliases = aliases
+	return aliases
+}
+
 func (s *metadataCredentialService) authHandler(w http.ResponseWriter, r *http.Request) {
 	// don't clear username or password from config state after completing this handler, we may need them later (mfa)
 	defer r.Body.Close()
@@ -482,22 +797,28 @@ func (s *metadataCredentialService) authHandler(w http.ResponseWriter, r *http.R
 
 	user := r.Form.Get("username")
 	pass := r.Form.Get("password")
-	s.awsConfig.SamlUsername = user
-	s.awsConfig.WebIdentityUsername = user
+
+	cur, _ := s.active()
+	cfg := *cur
+	cfg.SamlUsername = user
+	cfg.WebIdentityUsername = user
 
 	creds := new(config.AwsCredentials)
 	creds.SamlPassword = pass
 	creds.WebIdentityPassword = pass
 
 	s.clientOptions.CommandCredentials = creds
-	s.awsClient, err = s.clientFactory.Get(s.awsConfig)
+	cl, err := s.clientFactory.Get(&cfg)
+	s.setActive(&cfg, cl)
 	if err != nil {
+		s.webhook.notify(authFailedEvent(&cfg, r, err))
 		s.options.Logger.Errorf("%v", err)
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	if _, err = s.awsClient.Credentials(); err != nil {
+	if _, err = cl.CredentialsWithContext(r.Context()); err != nil {
+		s.webhook.notify(authFailedEvent(&cfg, r, err))
 		s.options.Logger.Errorf("%v", err)
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
@@ -508,7 +829,6 @@ func (s *metadataCredentialService) authHandler(w http.ResponseWriter, r *http.R
 
 func (s *metadataCredentialService) mfaHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	defer func() { s.awsConfig.MfaCode = "" }()
 	var err error
 
 	if err = r.ParseForm(); err != nil {
@@ -517,15 +837,24 @@ func (s *metadataCredentialService) mfaHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	s.awsConfig.MfaCode = r.Form.Get("mfa")
-	s.awsClient, err = s.clientFactory.Get(s.awsConfig)
+	// the code is only used for this client, the active configuration never keeps it
+	cur, _ := s.active()
+	cfg := *cur
+	cfg.MfaCode = r.Form.Get("mfa")
+
+	cl, err := s.clientFactory.Get(&cfg)
+	saved := cfg
+	saved.MfaCode = ""
+	s.setActive(&saved, cl)
 	if err != nil {
+		s.webhook.notify(authFailedEvent(&saved, r, err))
 		s.options.Logger.Errorf("%v", err)
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	if _, err = s.awsClient.Credentials(); err != nil {
+	if _, err = cl.CredentialsWithContext(r.Context()); err != nil {
+		s.webhook.notify(authFailedEvent(&saved, r, err))
 		s.options.Logger.Errorf("%v", err)
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
@@ -557,22 +886,39 @@ func (s *metadataCredentialService) customProfileHandler(w http.ResponseWriter,
 	case http.MethodPost:
 		// update running config without persisting to config file
 		// clear ProfileName so we don't whack (or possibly use?) the cache for an existing profile
-		s.awsConfig.MergeIn(newCfg)
-		s.awsConfig.ProfileName = ""
+		cfg := new(config.AwsConfig)
+		if cur, _ := s.active(); cur != nil {
+			*cfg = *cur
+		}
+		cfg.MergeIn(newCfg)
+		cfg.ProfileName = ""
 
-		if err = s.awsConfig.Validate(); err != nil {
+		if err = cfg.Validate(); err != nil {
 			http.Error(w, fmt.Sprintf("Invalid Configuration: %v", err), http.StatusBadRequest)
 			return
 		}
 
+		if s.options.MultiUser && !userAuthenticated(cfg) {
+			http.Error(w, "Profiles using the credentials of the user running the service are not allowed in "+
+				"multi-user mode", http.StatusForbidden)
+			return
+		}
+
 		var cl client.AwsClient
-		cl, err = s.clientFactory.Get(s.awsConfig)
+		cl, err = s.clientFactory.Get(cfg)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Invalid Configuration: %v", err), http.StatusBadRequest)
 			return
 		}
-		s.awsClient = cl
+		s.setActive(cfg, cl)
+		s.saveState()
 	case http.MethodPut:
+		// the config and credentials files belong to the user running the service
+		if s.options.MultiUser {
+			http.Error(w, "Saving profiles is not allowed in multi-user mode", http.StatusForbidden)
+			return
+		}
+
 		newCfg.ProfileName = r.Form.Get("profile-name")
 
 		if len(newCfg.ProfileName) < 1 {
@@ -607,7 +953,11 @@ func (s *metadataCredentialService) buildConfig(v url.Values) (*config.AwsConfig
 
 	if p := v.Get("password"); len(p) > 0 {
 		authUrl = v.Get("auth-url")
-		crypt, err = helpers.NewPasswordEncoder([]byte(authUrl)).Encode(p, 18)
+		enc := helpers.NewPasswordEncoder([]byte(authUrl))
+		if crypt, err = enc.Encode(p, 18); err == nil {
+			crypt, err = enc.Protect(crypt)
+		}
+
 		if err != nil {
 			return nil, nil, err
 		}
@@ -658,13 +1008,18 @@ func (s *metadataCredentialService) getConfigAndClient(profile string) (cfg *con
 		return nil, nil, err
 	}
 
+	s.mu.Lock()
 	if s.awsConfig != nil {
-		s.awsConfig.MergeIn(cfg)
+		merged := *s.awsConfig
+		merged.MergeIn(cfg)
+		s.awsConfig = &merged
 	}
+	active := s.awsClient
+	s.mu.Unlock()
 
 	// ewww, testing-specific code in actual code
-	if cfg.SamlProvider == "mock" && s.awsClient != nil {
-		return cfg, s.awsClient, nil
+	if cfg.SamlProvider == "mock" && active != nil {
+		return cfg, active, nil
 	}
 
 	cl, err = s.clientFactory.Get(cfg)
@@ -683,8 +1038,8 @@ func (s *metadataCredentialService) handleAuthError(err error, w http.ResponseWr
 		case "MFA":
 		default:
 			m["username"] = ""
-			if s.awsConfig != nil {
-				m["username"] = s.awsConfig.SamlUsername
+			if cfg, _ := s.active(); cfg != nil {
+				m["username"] = cfg.SamlUsername
 			}
 		}
 
@@ -716,6 +1071,10 @@ func linuxSetCap() error {
 }
 
 func configureListener(addr string) (net.Listener, error) {
+	if IsPipeAddr(addr) {
+		return listenPipe(addr)
+	}
+
 	if strings.HasPrefix(addr, DefaultEc2ImdsAddr) {
 		// DefaultEc2ImdsAddr requires that we setup the address on an interface. (eww, root/admin is required!)
 		// Under the covers, it relies on OS-specific commands, but it avoids a bunch of other ugliness to make
@@ -777,8 +1136,15 @@ func installSigHandler(srv *http.Server, lsnr net.Listener) {
 
 func logHandler(nextHandler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := shared.StartSpan(r.Context(), r.Method+" "+r.URL.Path)
+		defer span.End()
+
 		rec := httptest.NewRecorder()
-		nextHandler(rec, r)
+		nextHandler(rec, r.WithContext(ctx))
+		span.SetAttributes(attribute.Int("http.status_code", rec.Code))
+		if rec.Code >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.Code))
+		}
 
 		logger.Infof("%s %s %s %d %d", r.Method, r.URL.Path, r.Proto, rec.Code, rec.Body.Len())
 
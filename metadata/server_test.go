@@ -25,6 +25,7 @@ import (
 	"github.com/mmmorris1975/aws-runas/credentials"
 	"github.com/mmmorris1975/aws-runas/identity"
 	"github.com/mmmorris1975/aws-runas/shared"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -88,6 +89,12 @@ func TestNewMetadataCredentialService(t *testing.T) {
 			t.Error("invalid listener address")
 		}
 	})
+
+	t.Run("multi-user tcp", func(t *testing.T) {
+		if _, err := NewMetadataCredentialService(":0", &Options{MultiUser: true}); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
 }
 
 func TestMetadataCredentialService_Run(t *testing.T) {
@@ -358,6 +365,26 @@ func TestMetadataCredentialService_ecsCredHandler(t *testing.T) {
 			return
 		}
 	})
+
+	t.Run("auth token", func(t *testing.T) {
+		mcs.awsClient = new(mockAwsClient)
+		mcs.options = &Options{Path: DefaultEcsCredPath, AuthToken: "s3cret"}
+		defer func() { mcs.options = &Options{Path: DefaultEcsCredPath} }()
+
+		for token, code := range map[string]int{"s3cret": http.StatusOK, "bogus": http.StatusUnauthorized, "": http.StatusUnauthorized} {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, mcs.options.Path, http.NoBody)
+			if len(token) > 0 {
+				req.Header.Set("Authorization", token)
+			}
+
+			mcs.ecsCredHandler(rec, req)
+
+			if rec.Code != code {
+				t.Errorf("unexpected http status code for token '%s': %d", token, rec.Code)
+			}
+		}
+	})
 }
 
 func TestMetadataCredentialService_refreshHandler(t *testing.T) {
@@ -471,6 +498,106 @@ func TestMetadataCredentialService_listRolesHandler(t *testing.T) {
 	})
 }
 
+func TestMetadataCredentialService_samlRolesHandler(t *testing.T) {
+	mcs := mockMetadataCredentialService()
+	mcs.awsConfig, _ = mcs.configResolver.Config("mock")
+	mcs.awsClient = new(mockAwsClient)
+
+	t.Run("list", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, samlRolesPath, http.NoBody)
+
+		mcs.samlRolesHandler(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("unexpected http status code: %d", rec.Code)
+			return
+		}
+
+		accts := make([]samlAccount, 0)
+		if err := json.Unmarshal(rec.Body.Bytes(), &accts); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(accts) != 1 || accts[0].AccountId != "123456789012" || len(accts[0].Roles) != 2 {
+			t.Errorf("unexpected result: %+v", accts)
+		}
+	})
+
+	t.Run("unknown role", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, samlRolesPath, bytes.NewBufferString("arn:aws:iam::123456789012:role/other"))
+
+		mcs.samlRolesHandler(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("unexpected http status code: %d", rec.Code)
+		}
+	})
+
+	t.Run("unsupported method", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodDelete, samlRolesPath, http.NoBody)
+
+		mcs.samlRolesHandler(rec, req)
+
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("unexpected http status code: %d", rec.Code)
+		}
+	})
+
+	t.Run("not saml", func(t *testing.T) {
+		mcs.awsConfig = new(config.AwsConfig)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, samlRolesPath, http.NoBody)
+
+		mcs.samlRolesHandler(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("unexpected http status code: %d", rec.Code)
+		}
+	})
+}
+
+func TestMetadataCredentialService_accountAliases(t *testing.T) {
+	rt := new(aliasTransport)
+	opts := *client.DefaultOptions
+	opts.Transport = rt
+
+	mcs := &metadataCredentialService{clientFactory: client.NewClientFactory(new(mockConfigResolver), &opts)}
+
+	saml := credentials.SamlAssertion("PHNhbWw+PC9zYW1sPg==")
+	for range 3 {
+		if a := mcs.accountAliases(context.Background(), &saml); a["123456789012"] != "my-alias" {
+			t.Errorf("unexpected aliases: %v", a)
+		}
+	}
+
+	if rt.requests != 1 {
+		t.Errorf("aliases looked up %d times", rt.requests)
+	}
+
+	other := credentials.SamlAssertion("PHNhbWw+b3RoZXI8L3NhbWw+")
+	mcs.accountAliases(context.Background(), &other)
+	if rt.requests != 2 {
+		t.Error("aliases not looked up for a new assertion")
+	}
+}
+
+// aliasTransport returns the AWS sign-in role selection page, counting the requests.
+type aliasTransport struct {
+	requests int
+}
+
+func (t *aliasTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.requests++
+	body := `<div class="saml-account-name">Account: my-alias (123456789012)</div>`
+	return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(body)),
+		Request: r}, nil
+}
+
 // for teh coverage gainz!!
 //
 //nolint:misspell  // it's a joke, son!
@@ -879,6 +1006,22 @@ func Test_listProfilesHandler(t *testing.T) {
 	}
 }
 
+func TestEc2EndpointEnv(t *testing.T) {
+	env := Ec2EndpointEnv("http://127.0.0.1:8000/")
+
+	if env["AWS_EC2_METADATA_SERVICE_ENDPOINT"] != "http://127.0.0.1:8000/" {
+		t.Error("endpoint env var not set")
+	}
+
+	if env["AWS_EC2_METADATA_DISABLED"] != "false" {
+		t.Error("metadata service not enabled")
+	}
+
+	if len(env["AWS_METADATA_SERVICE_TIMEOUT"]) < 1 || len(env["AWS_METADATA_SERVICE_NUM_ATTEMPTS"]) < 1 {
+		t.Error("metadata service timeout env vars not set")
+	}
+}
+
 func mockMetadataCredentialService() *metadataCredentialService {
 	mcs := new(metadataCredentialService)
 	mcs.configResolver = new(mockConfigResolver)
@@ -932,7 +1075,15 @@ func (m *mockAwsClient) Identity() (*identity.Identity, error) {
 }
 
 func (m *mockAwsClient) Roles() (*identity.Roles, error) {
-	return new(identity.Roles), nil
+	return &identity.Roles{"arn:aws:iam::123456789012:role/role2", "arn:aws:iam::123456789012:role/role1"}, nil
+}
+
+func (m *mockAwsClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return m.Identity()
+}
+
+func (m *mockAwsClient) RolesWithContext(context.Context) (*identity.Roles, error) {
+	return m.Roles()
 }
 
 func (m *mockAwsClient) Credentials() (*credentials.Credentials, error) {
@@ -965,6 +1116,17 @@ func (m *mockAwsClient) ClearCache() error {
 	return nil
 }
 
+func (m *mockAwsClient) ExpiresAt() time.Time {
+	return time.Time{}
+}
+
+func (m *mockAwsClient) Refresh(ctx context.Context) (*credentials.Credentials, error) {
+	if err := m.ClearCache(); err != nil {
+		return nil, err
+	}
+	return m.CredentialsWithContext(ctx)
+}
+
 var testConfig = `[default]
 
 [profile norole]
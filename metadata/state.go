@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/client"
+)
+
+// stateFileFmt is the name of the file holding the state of the service, which includes a hash of the listener address
+// so services listening on different addresses don't share their state.
+const stateFileFmt = ".aws_runas_serve_%x.state"
+
+// serviceState is the state of the service saved across restarts.
+type serviceState struct {
+	Profile string    `json:"profile"`
+	Updated time.Time `json:"updated"`
+}
+
+// stateFile returns the location of the file holding the state of the service, or an empty string if the state is
+// not saved.  Each user of a multi-user service selects their own profile, so the state is never saved in that mode.
+func (s *metadataCredentialService) stateFile() string {
+	if s.options.MultiUser || s.listener == nil {
+		return ""
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s.listener.Addr().Network() + "://" + s.listener.Addr().String()))
+	return client.StateFile(nil, fmt.Sprintf(stateFileFmt, h.Sum64()))
+}
+
+// saveState records the active profile, so it's used again when the service is restarted.  Custom profiles which are
+// not saved to the config file have no name, which is recorded so no profile is selected after a restart.
+func (s *metadataCredentialService) saveState() {
+	cfg, _ := s.active()
+	path := s.stateFile()
+	if len(path) < 1 || cfg == nil {
+		return
+	}
+
+	if err := writeState(path, &serviceState{Profile: cfg.ProfileName, Updated: time.Now().UTC()}); err != nil {
+		logger.Warningf("unable to save service state, the active profile will not be restored on restart: %v", err)
+	}
+}
+
+// restoreState selects the profile which was active when the service last stopped.  The credentials are fetched
+// before the service starts handling requests, which completes any refresh interrupted by the restart, or provides
+// the still valid cached credentials, so SDK clients continue to get credentials without an error.  It returns the
+// name of the restored profile, or an empty string if no profile was restored.
+func (s *metadataCredentialService) restoreState(ctx context.Context) string {
+	path := s.stateFile()
+	if len(path) < 1 {
+		return ""
+	}
+
+	st, err := readState(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warningf("unable to read service state: %v", err)
+		}
+		return ""
+	}
+
+	if len(st.Profile) < 1 {
+		return ""
+	}
+
+	cfg, cl, err := s.getConfigAndClient(st.Profile)
+	if err != nil {
+		logger.Warningf("unable to restore profile '%s': %v", st.Profile, err)
+		return ""
+	}
+	s.setActive(cfg, cl)
+
+	// the profile remains selected, an authentication error is handled on the first request for credentials
+	if _, err = cl.CredentialsWithContext(ctx); err != nil {
+		logger.Warningf("unable to fetch credentials for restored profile '%s': %v", st.Profile, err)
+	}
+	return st.Profile
+}
+
+func readState(path string) (*serviceState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	st := new(serviceState)
+	if err = json.Unmarshal(data, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// writeState writes the state to a temporary file which replaces the state file, so a service stopped while writing
+// the file never leaves a partially written state behind.
+func writeState(path string, st *serviceState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+func TestMetadataCredentialService_State(t *testing.T) {
+	t.Setenv("AWS_RUNAS_CACHE_DIR", t.TempDir())
+
+	lsnr, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lsnr.Close()
+
+	t.Run("save on profile update", func(t *testing.T) {
+		mcs := mockMetadataCredentialService()
+		mcs.listener = lsnr
+		mcs.awsClient = new(mockAwsClient)
+
+		req := httptest.NewRequest(http.MethodPost, profilePath, bytes.NewBufferString("mockUpdate"))
+		mcs.profileHandler(httptest.NewRecorder(), req)
+
+		st, err := readState(mcs.stateFile())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if st.Profile != "mockUpdate" || st.Updated.IsZero() {
+			t.Errorf("unexpected state: %+v", st)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		mcs := mockMetadataCredentialService()
+		mcs.listener = lsnr
+		mcs.awsClient = new(mockAwsClient)
+
+		if p := mcs.restoreState(context.Background()); p != "mockUpdate" {
+			t.Errorf("unexpected restored profile: %s", p)
+			return
+		}
+
+		if mcs.awsConfig == nil || mcs.awsConfig.ProfileName != "mockUpdate" {
+			t.Error("profile was not restored")
+		}
+	})
+
+	t.Run("restore credential error", func(t *testing.T) {
+		mcs := mockMetadataCredentialService()
+		mcs.listener = lsnr
+		c := mockAwsClient(true)
+		mcs.awsClient = &c
+
+		if p := mcs.restoreState(context.Background()); p != "mockUpdate" || mcs.awsConfig == nil {
+			t.Error("profile was not restored")
+		}
+	})
+
+	t.Run("restore bad config", func(t *testing.T) {
+		mcs := mockMetadataCredentialService()
+		cfg := mockConfigResolver(true)
+		mcs.configResolver = &cfg
+		mcs.listener = lsnr
+
+		if p := mcs.restoreState(context.Background()); len(p) > 0 || mcs.awsConfig != nil {
+			t.Error("unexpected restored profile")
+		}
+	})
+
+	t.Run("custom profile", func(t *testing.T) {
+		mcs := mockMetadataCredentialService()
+		mcs.listener = lsnr
+		mcs.awsConfig = &config.AwsConfig{Region: "us-east-1"}
+		mcs.saveState()
+
+		if p := mcs.restoreState(context.Background()); len(p) > 0 {
+			t.Errorf("unexpected restored profile: %s", p)
+		}
+	})
+
+	t.Run("multi-user", func(t *testing.T) {
+		mcs := mockMetadataCredentialService()
+		mcs.listener = lsnr
+		mcs.options.MultiUser = true
+
+		if f := mcs.stateFile(); len(f) > 0 {
+			t.Errorf("unexpected state file: %s", f)
+		}
+	})
+
+	t.Run("no state", func(t *testing.T) {
+		other, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer other.Close()
+
+		mcs := mockMetadataCredentialService()
+		mcs.listener = other
+
+		if _, err = os.Stat(mcs.stateFile()); !os.IsNotExist(err) {
+			t.Error("unexpected state file")
+		}
+
+		if p := mcs.restoreState(context.Background()); len(p) > 0 {
+			t.Errorf("unexpected restored profile: %s", p)
+		}
+	})
+}
@@ -55,61 +55,120 @@ function postProfile() {
 	xhr.onreadystatechange = function () {
 		if (this.readyState === 4) {
 			document.getElementById("roles").value = profile;
+			document.getElementById("saml-roles").value = "";
+			handleProfileResponse(this, "profile POST");
+		}
+	};
+	
+	xhr.open("POST", "{{.profile_ep}}");
+	xhr.send(profile);
+	return false
+}
 
-			if (this.status === 200) {
-				// data is empty, and message element doesn't exist
-				// maybe we should just kill this? we can only know the AWS STS cred expiration, but I can already
-				// imagine people asking to display saml/oidc cred expiration, which we won't (can't?) do
-				// it was originally meant to show folks the approx time when mfa would be needed again, and that is
-				// now unknowable if the profile uses external auth.
-				//let data = this.responseText;
-				//document.getElementById("message").innerHTML = "Credentials will expire on <i>" + data + "</i>"
-
-				let o = JSON.parse(this.responseText);
-
-				if (o.client_id !== "") {
-					document.getElementById("adv-type").value = "oidc";
-				} else if (o.auth_url !== "") {
-					document.getElementById("adv-type").value = "saml";
-				} else {
-					document.getElementById("adv-type").value = "iam";
-				}
+function handleProfileResponse(xhr, desc) {
+	if (xhr.status === 200) {
+		// data is empty, and message element doesn't exist
+		// maybe we should just kill this? we can only know the AWS STS cred expiration, but I can already
+		// imagine people asking to display saml/oidc cred expiration, which we won't (can't?) do
+		// it was originally meant to show folks the approx time when mfa would be needed again, and that is
+		// now unknowable if the profile uses external auth.
+		//let data = xhr.responseText;
+		//document.getElementById("message").innerHTML = "Credentials will expire on <i>" + data + "</i>"
+
+		let o = JSON.parse(xhr.responseText);
+
+		if (o.client_id !== "") {
+			document.getElementById("adv-type").value = "oidc";
+		} else if (o.auth_url !== "") {
+			document.getElementById("adv-type").value = "saml";
+		} else {
+			document.getElementById("adv-type").value = "iam";
+		}
 
-				updateAdvancedForm(document.getElementById("adv-type").selectedOptions, o.source_profile);
+		updateAdvancedForm(document.getElementById("adv-type").selectedOptions, o.source_profile);
+
+		document.getElementById("role-arn").value = o.role_arn;
+		document.getElementById("source-profile").value = o.source_profile;
+		document.getElementById("external-id").value = o.external_id;
+		document.getElementById("auth-url").value = o.auth_url;
+		document.getElementById("username").value = o.username;
+		document.getElementById("jump-role").value = o.jump_role;
+		document.getElementById("client-id").value = o.client_id;
+		document.getElementById("redirect-uri").value = o.redirect_uri;
+	} else if (xhr.status === 401) {
+		let o = JSON.parse(xhr.responseText);
+		if (o.username || o.username === "") {
+			let elems = document.getElementById("cred-form").elements;
+			elems["username"].value = o.username;
 
-				document.getElementById("role-arn").value = o.role_arn;
-				document.getElementById("source-profile").value = o.source_profile;
-				document.getElementById("external-id").value = o.external_id;
-				document.getElementById("auth-url").value = o.auth_url;
-				document.getElementById("username").value = o.username;
-				document.getElementById("jump-role").value = o.jump_role;
-				document.getElementById("client-id").value = o.client_id;
-				document.getElementById("redirect-uri").value = o.redirect_uri;
-			} else if (this.status === 401) {
-				let o = JSON.parse(this.responseText);
-				if (o.username || o.username === "") {
-					let elems = document.getElementById("cred-form").elements;
-					elems["username"].value = o.username;
+			document.getElementById("cred-modal").style.display = 'block';
 
-					document.getElementById("cred-modal").style.display = 'block';
+			elems["username"].focus();
+			if (o.username.length > 0) {
+				elems["password"].focus();
+			}
+		} else {
+			document.getElementById("mfa-modal").style.display = 'block';
+			document.getElementById("mfa").focus();
+		}
+	} else {
+		console.log(desc + " returned " + xhr.status + ": " + xhr.responseText);
+		show_alert("Error", xhr.responseText);
+	}
+}
 
-					elems["username"].focus();
-					if (o.username.length > 0) {
-						elems["password"].focus();
-					}
-				} else {
-					document.getElementById("mfa-modal").style.display = 'block';
-					document.getElementById("mfa").focus();
-				}
+let samlRoleList = document.getElementById("saml-roles")
+samlRoleList.addEventListener("mousedown", function () {
+	const xhr = new XMLHttpRequest();
+
+	xhr.onreadystatechange = function () {
+		if (this.readyState === 4) {
+			let sel = document.getElementById("saml-roles");
+			let cur = sel.value;
+			sel.length = 1;
+			for (let g of sel.querySelectorAll("optgroup")) {
+				g.remove();
+			}
+
+			if (this.status === 200) {
+				JSON.parse(this.responseText).forEach(function (acct, idx, _) {
+					let grp = document.createElement("optgroup");
+					grp.label = acct.alias ? acct.alias + " (" + acct.account_id + ")" : acct.account_id;
+
+					acct.roles.forEach(function (val, idx, _) {
+						grp.appendChild(new Option(val.substring(val.indexOf(":role/") + 6), val));
+					});
+					sel.appendChild(grp);
+				});
+				sel.value = cur;
 			} else {
-				console.log("profile POST returned " + this.status + ": " + this.responseText);
-				show_alert("Error", this.responseText);
+				// not a SAML profile, nothing to show
+				console.log("saml-roles returned " + this.status + ": " + this.responseText);
 			}
 		}
 	};
-	
-	xhr.open("POST", "{{.profile_ep}}");
-	xhr.send(profile);
+
+	xhr.open("GET", "{{.saml_ep}}");
+	xhr.send();
+	return false;
+})
+
+samlRoleList.onchange = postSamlRole;
+function postSamlRole() {
+	let role = document.getElementById("saml-roles").value;
+	if (role === "") {
+		return false;
+	}
+
+	const xhr = new XMLHttpRequest();
+	xhr.onreadystatechange = function () {
+		if (this.readyState === 4) {
+			handleProfileResponse(this, "saml-roles POST");
+		}
+	};
+
+	xhr.open("POST", "{{.saml_ep}}");
+	xhr.send(role);
 	return false
 }
 
@@ -118,7 +177,11 @@ document.getElementById("refresh").addEventListener("click", function () {
 	xhr.onreadystatechange = function () {
 		if (this.readyState === 4) {
 			if (this.status === 200) {
-				postProfile()
+				if (document.getElementById("saml-roles").value !== "") {
+					postSamlRole()
+				} else {
+					postProfile()
+				}
 			} else {
 				console.log("refresh returned " + this.status + ": " + this.responseText);
 			}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+)
+
+// UnixPrefix is the prefix of a unix domain socket address, addresses starting with it are served using the socket at
+// the path following the prefix instead of a TCP port.
+const UnixPrefix = "unix:"
+
+// IsUnixAddr returns true if addr is the path of a unix domain socket, prefixed with UnixPrefix.
+func IsUnixAddr(addr string) bool {
+	return strings.HasPrefix(addr, UnixPrefix)
+}
+
+// listenUnix creates the unix domain socket at path, replacing a socket left behind by a service which did not exit
+// cleanly.  Only the user running the service is allowed to connect to the socket, unless shared is true.
+func listenUnix(path string, shared bool) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, errors.New("socket path exists and is not a socket")
+		}
+
+		if c, err := net.Dial("unix", path); err == nil {
+			_ = c.Close()
+			return nil, errors.New("socket is in use by another process")
+		}
+		_ = os.Remove(path)
+	}
+
+	// create the socket so only this user can connect, access for other users is only granted after it exists
+	var lsnr net.Listener
+	err := withUmask(0o177, func() (e error) {
+		lsnr, e = net.Listen("unix", path)
+		return e
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var mode os.FileMode = 0600
+	if shared {
+		mode = 0666
+	}
+
+	if err = os.Chmod(path, mode); err != nil {
+		_ = lsnr.Close()
+		return nil, err
+	}
+	return lsnr, nil
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestIsUnixAddr(t *testing.T) {
+	t.Run("unix", func(t *testing.T) {
+		if !IsUnixAddr("unix:/run/aws-runas.sock") {
+			t.Error("unix socket address not detected")
+		}
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		if IsUnixAddr("127.0.0.1:12319") {
+			t.Error("tcp address detected as unix socket")
+		}
+	})
+}
+
+func Test_listenUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on windows")
+	}
+
+	t.Run("private", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "runas.sock")
+		l, err := listenUnix(path, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+
+		if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+			t.Errorf("unexpected socket mode: %v", fi.Mode())
+		}
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "runas.sock")
+		l, err := listenUnix(path, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+
+		if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0666 {
+			t.Errorf("unexpected socket mode: %v", fi.Mode())
+		}
+	})
+
+	t.Run("in use", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "runas.sock")
+		l, err := listenUnix(path, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+
+		if _, err = listenUnix(path, false); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("not a socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "runas.sock")
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := listenUnix(path, false); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
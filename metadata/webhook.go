@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/shared"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// WebhookEventCredentialsIssued is the webhook event sent when credentials are served to a client.
+	WebhookEventCredentialsIssued = "credentials_issued"
+	// WebhookEventRefreshFailed is the webhook event sent when credentials requested by a client can not be retrieved.
+	WebhookEventRefreshFailed = "credential_refresh_failed"
+	// WebhookEventAuthFailed is the webhook event sent when authentication with the identity provider, or MFA, fails.
+	WebhookEventAuthFailed = "auth_failed"
+
+	// WebhookSignatureHeader is the http header with the hex encoded HMAC-SHA256 of the request body, prefixed with "sha256=".
+	WebhookSignatureHeader = "X-AwsRunas-Signature"
+	// WebhookEventHeader is the http header with the name of the event in the request body.
+	WebhookEventHeader = "X-AwsRunas-Event"
+
+	webhookTimeout = 10 * time.Second
+)
+
+// WebhookEvent is the JSON body sent to the webhook for each event.
+type WebhookEvent struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Event       string    `json:"event"`
+	Profile     string    `json:"profile,omitempty"`
+	RoleArn     string    `json:"role_arn,omitempty"`
+	Username    string    `json:"username,omitempty"`
+	AccessKeyId string    `json:"access_key_id,omitempty"`
+	Expiration  time.Time `json:"expiration,omitzero"`
+	Caller      string    `json:"caller,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// webhookNotifier sends events to the webhook in the background, so a slow or unavailable webhook
+// never delays serving credentials.  A nil webhookNotifier silently discards all events.
+type webhookNotifier struct {
+	url    string
+	secret []byte
+	client *http.Client
+	wg     sync.WaitGroup
+
+	// last is the credential state of each profile last sent to the webhook, guarded by mu
+	mu   sync.Mutex
+	last map[string]string
+}
+
+func newWebhookNotifier(u, secret string) (*webhookNotifier, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook url: %w", err)
+	}
+
+	if parsed.Scheme != "https" || len(parsed.Host) < 1 {
+		return nil, errors.New("webhook url must be an https url")
+	}
+
+	return &webhookNotifier{
+		url:    u,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: webhookTimeout, Transport: shared.DefaultTransport()},
+		last:   make(map[string]string),
+	}, nil
+}
+
+// notify sends the event to the webhook without waiting for the result, failures are logged.
+func (n *webhookNotifier) notify(ev *WebhookEvent) {
+	if n == nil {
+		return
+	}
+
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now().UTC()
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		if err := n.send(context.Background(), ev); err != nil {
+			logger.Warningf("unable to send %s webhook event: %v", ev.Event, err)
+		}
+	}()
+}
+
+// notifyCredentials sends a credential event only when the credential state of the profile changes, which is when
+// the credentials are refreshed (the access key changes), or when getting them starts or stops failing.  SDK clients
+// poll the service for credentials, and every request would otherwise send an identical event.
+func (n *webhookNotifier) notifyCredentials(ev *WebhookEvent) {
+	if n == nil {
+		return
+	}
+
+	key := ev.Profile + " " + ev.RoleArn
+	state := ev.Event + " " + ev.AccessKeyId
+
+	n.mu.Lock()
+	changed := n.last[key] != state
+	n.last[key] = state
+	n.mu.Unlock()
+
+	if changed {
+		n.notify(ev)
+	}
+}
+
+// wait blocks until all events passed to notify have been sent.
+func (n *webhookNotifier) wait() {
+	if n != nil {
+		n.wg.Wait()
+	}
+}
+
+func (n *webhookNotifier) send(ctx context.Context, ev *WebhookEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, ev.Event)
+
+	if len(n.secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+webhookSignature(n.secret, body))
+	}
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook returned http status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// webhookSignature returns the hex encoded HMAC-SHA256 of body, using secret as the key.
+func webhookSignature(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// credentialEvent builds the event for credentials served, or not served if err is non-nil, to the http request caller.
+func credentialEvent(cfg *config.AwsConfig, creds *credentials.Credentials, r *http.Request, err error) *WebhookEvent {
+	ev := &WebhookEvent{Event: WebhookEventCredentialsIssued, Caller: auditCaller(r)}
+
+	if cfg != nil {
+		ev.Profile = cfg.ProfileName
+		ev.RoleArn = cfg.RoleArn
+	}
+
+	if err != nil {
+		ev.Event = WebhookEventRefreshFailed
+		ev.Error = err.Error()
+	} else if creds != nil {
+		ev.AccessKeyId = creds.AccessKeyId
+		ev.Expiration = creds.Expiration.UTC()
+	}
+	return ev
+}
+
+// authFailedEvent builds the event for a failed identity provider or MFA authentication attempt by the http request caller.
+func authFailedEvent(cfg *config.AwsConfig, r *http.Request, err error) *WebhookEvent {
+	ev := &WebhookEvent{Event: WebhookEventAuthFailed, Caller: auditCaller(r), Error: err.Error()}
+
+	if cfg != nil {
+		ev.Profile = cfg.ProfileName
+		ev.RoleArn = cfg.RoleArn
+		ev.Username = cfg.SamlUsername
+		if len(cfg.WebIdentityClientId) > 0 {
+			ev.Username = cfg.WebIdentityUsername
+		}
+	}
+	return ev
+}
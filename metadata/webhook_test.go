@@ -0,0 +1,245 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/shared"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewWebhookNotifier(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		n, err := newWebhookNotifier("https://siem.example.com/events", "secret")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if n.url != "https://siem.example.com/events" || string(n.secret) != "secret" {
+			t.Error("data mismatch")
+		}
+
+		if n.client.Transport != shared.DefaultTransport() {
+			t.Error("webhook does not use the shared transport")
+		}
+	})
+
+	t.Run("http", func(t *testing.T) {
+		if _, err := newWebhookNotifier("http://siem.example.com/events", ""); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("no host", func(t *testing.T) {
+		if _, err := newWebhookNotifier("https:///events", ""); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := newWebhookNotifier("https://siem.example.com/%zz", ""); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestWebhookNotifier_send(t *testing.T) {
+	var body []byte
+	var hdr http.Header
+	status := http.StatusNoContent
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		hdr = r.Header
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	n, _ := newWebhookNotifier(srv.URL, "secret")
+	n.client = srv.Client()
+
+	t.Run("signed", func(t *testing.T) {
+		ev := &WebhookEvent{Event: WebhookEventCredentialsIssued, Profile: "mock", AccessKeyId: "mockAK"}
+		if err := n.send(context.Background(), ev); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if hdr.Get(WebhookEventHeader) != WebhookEventCredentialsIssued {
+			t.Errorf("unexpected event header: %s", hdr.Get(WebhookEventHeader))
+		}
+
+		if hdr.Get(WebhookSignatureHeader) != "sha256="+webhookSignature([]byte("secret"), body) {
+			t.Errorf("invalid signature: %s", hdr.Get(WebhookSignatureHeader))
+		}
+
+		var got WebhookEvent
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if got.Profile != "mock" || got.AccessKeyId != "mockAK" {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		n2, _ := newWebhookNotifier(srv.URL, "")
+		n2.client = srv.Client()
+
+		if err := n2.send(context.Background(), &WebhookEvent{Event: WebhookEventAuthFailed}); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if _, ok := hdr[WebhookSignatureHeader]; ok {
+			t.Error("found unexpected signature header")
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		status = http.StatusForbidden
+		defer func() { status = http.StatusNoContent }()
+
+		if err := n.send(context.Background(), &WebhookEvent{Event: WebhookEventAuthFailed}); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestWebhookNotifier_notify(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		var n *webhookNotifier
+		n.notify(new(WebhookEvent))
+		n.wait()
+	})
+
+	t.Run("good", func(t *testing.T) {
+		ch := make(chan WebhookEvent, 1)
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			var ev WebhookEvent
+			_ = json.NewDecoder(r.Body).Decode(&ev)
+			ch <- ev
+		}))
+		defer srv.Close()
+
+		n, _ := newWebhookNotifier(srv.URL, "")
+		n.client = srv.Client()
+		n.notify(&WebhookEvent{Event: WebhookEventRefreshFailed})
+		n.wait()
+
+		ev := <-ch
+		if ev.Event != WebhookEventRefreshFailed || ev.Timestamp.IsZero() {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+	})
+}
+
+func TestMetadataCredentialService_webhook(t *testing.T) {
+	events := make(chan WebhookEvent, 4)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var ev WebhookEvent
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		events <- ev
+	}))
+	defer srv.Close()
+
+	mcs := mockMetadataCredentialService()
+	mcs.webhook, _ = newWebhookNotifier(srv.URL, "secret")
+	mcs.webhook.client = srv.Client()
+
+	cfg, _ := mcs.configResolver.Config("mock")
+	mcs.awsConfig = cfg
+
+	t.Run("issued", func(t *testing.T) {
+		mcs.awsClient = new(mockAwsClient)
+
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s%s", ec2CredPath, cfg.ProfileName), http.NoBody)
+		req.Header.Set("User-Agent", "mock-sdk")
+		mcs.ec2CredHandler(httptest.NewRecorder(), req)
+		mcs.webhook.wait()
+
+		ev := <-events
+		if ev.Event != WebhookEventCredentialsIssued || ev.Profile != cfg.ProfileName || ev.AccessKeyId != "mockAK" ||
+			ev.Expiration.IsZero() || ev.Caller != req.RemoteAddr+" mock-sdk" {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		// the same credentials served again are not a new event
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s%s", ec2CredPath, cfg.ProfileName), http.NoBody)
+		mcs.ec2CredHandler(httptest.NewRecorder(), req)
+		mcs.webhook.wait()
+
+		if len(events) > 0 {
+			t.Errorf("unexpected event: %+v", <-events)
+		}
+	})
+
+	t.Run("refresh failed", func(t *testing.T) {
+		c := mockAwsClient(true)
+		mcs.awsClient = &c
+
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s%s", ec2CredPath, cfg.ProfileName), http.NoBody)
+			mcs.ec2CredHandler(httptest.NewRecorder(), req)
+		}
+		mcs.webhook.wait()
+
+		ev := <-events
+		if ev.Event != WebhookEventRefreshFailed || len(ev.Error) < 1 || len(ev.AccessKeyId) > 0 {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+
+		if len(events) > 0 {
+			t.Errorf("repeated failure sent another event: %+v", <-events)
+		}
+	})
+}
+
+func Test_authFailedEvent(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, authPath, http.NoBody)
+
+	t.Run("saml", func(t *testing.T) {
+		cfg := &config.AwsConfig{ProfileName: "p", SamlUsername: "saml-user", WebIdentityUsername: "saml-user"}
+		ev := authFailedEvent(cfg, r, errors.New("bad password"))
+
+		if ev.Event != WebhookEventAuthFailed || ev.Username != "saml-user" || ev.Error != "bad password" {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+	})
+
+	t.Run("oidc", func(t *testing.T) {
+		cfg := &config.AwsConfig{ProfileName: "p", WebIdentityClientId: "id", WebIdentityUsername: "oidc-user"}
+		if ev := authFailedEvent(cfg, r, errors.New("x")); ev.Username != "oidc-user" {
+			t.Errorf("unexpected username: %s", ev.Username)
+		}
+	})
+
+	t.Run("nil config", func(t *testing.T) {
+		if ev := authFailedEvent(nil, r, errors.New("x")); len(ev.Profile) > 0 || ev.Caller != r.RemoteAddr {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+	})
+}
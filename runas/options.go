@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package runas
+
+import (
+	"net/http"
+)
+
+// Option is a function which configures the behavior of the Client returned by New.
+type Option func(*options)
+
+type options struct {
+	mfaProvider      func() (string, error)
+	mfaCode          string
+	credProvider     func(user, password string) (string, string, error)
+	roleSelector     func(roles []string, last string) (string, error)
+	passwordProvider func() (string, error)
+	cache            bool
+	logger           Logger
+	transport        http.RoundTripper
+	resolver         Resolver
+}
+
+// WithMFAProvider sets the function called to obtain a multi-factor authentication code when one is required.
+// The default prompts for the code on stdin.
+func WithMFAProvider(f func() (string, error)) Option {
+	return func(o *options) {
+		o.mfaProvider = f
+	}
+}
+
+// WithMFACode sets a static multi-factor authentication code to use for the first credential request.
+func WithMFACode(code string) Option {
+	return func(o *options) {
+		o.mfaCode = code
+	}
+}
+
+// WithCredentialProvider sets the function called to obtain a username and password for authentication with an
+// external identity provider.  The function is passed any known username and password values.  The default prompts
+// for the values on stdin.
+func WithCredentialProvider(f func(user, password string) (string, string, error)) Option {
+	return func(o *options) {
+		o.credProvider = f
+	}
+}
+
+// WithRoleSelector sets the function called to pick one of the roles when the role of a SAML profile is a pattern
+// matching multiple roles.  The function is passed the matching roles, and the role last selected for the profile (or
+// an empty string), which is a good default.  The default prompts for the selection on stdin, and a nil function makes
+// a pattern matching multiple roles an error.
+func WithRoleSelector(f func(roles []string, last string) (string, error)) Option {
+	return func(o *options) {
+		o.roleSelector = f
+	}
+}
+
+// WithPasswordChangeProvider sets the function called to obtain a new password when the external identity provider
+// requires an expired password to be changed.  The default prompts for the new password on stdin.
+func WithPasswordChangeProvider(f func() (string, error)) Option {
+	return func(o *options) {
+		o.passwordProvider = f
+	}
+}
+
+// WithCache enables or disables the caching of credentials to the local filesystem.  Caching is enabled by default.
+func WithCache(enabled bool) Option {
+	return func(o *options) {
+		o.cache = enabled
+	}
+}
+
+// WithLogger sets the logger used by the Client.  By default, nothing is logged.
+func WithLogger(l Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithTransport sets the http.RoundTripper used for all requests made to AWS and external identity providers.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = rt
+	}
+}
+
+// WithResolver sets the Resolver used to look up the configuration for the profile passed to New.  The default
+// uses the standard AWS configuration files and environment variables.
+func WithResolver(r Resolver) Option {
+	return func(o *options) {
+		o.resolver = r
+	}
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+// Package runas provides a small API for embedding aws-runas credential handling in other Go programs.  The types and
+// functions in this package follow semantic versioning, and are the supported means of using aws-runas as a library.
+// The other packages in this module may change without notice between minor releases.
+package runas
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go/logging"
+
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/client/external"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/credentials/helpers"
+	"github.com/mmmorris1975/aws-runas/identity"
+	"github.com/mmmorris1975/aws-runas/shared"
+)
+
+// Resolver looks up the configuration and credentials for a profile.
+type Resolver interface {
+	Config(profile string) (*config.AwsConfig, error)
+	Credentials(profile string) (*config.AwsCredentials, error)
+}
+
+// Writer changes the configuration and credentials of profiles.
+type Writer interface {
+	CreateProfile(profile string, values map[string]string) error
+	DeleteProfile(profile string) error
+	SetConfig(profile string, values map[string]string) error
+	RemoveConfig(profile string, keys ...string) error
+	SetCredentials(profile string, values map[string]string) error
+	RemoveCredentials(profile string, keys ...string) error
+}
+
+// Logger receives the log messages of a Client.
+type Logger interface {
+	Debugf(string, ...any)
+	Infof(string, ...any)
+	Warningf(string, ...any)
+	Errorf(string, ...any)
+}
+
+// Backend is the source of the credentials and identity information provided by a Client.
+type Backend interface {
+	CredentialsWithContext(ctx context.Context) (*credentials.Credentials, error)
+	Refresh(ctx context.Context) (*credentials.Credentials, error)
+	ExpiresAt() time.Time
+	IdentityWithContext(ctx context.Context) (*identity.Identity, error)
+	RolesWithContext(ctx context.Context) (*identity.Roles, error)
+	ConfigProvider() aws.Config
+}
+
+// Client retrieves AWS credentials for a single profile.
+type Client struct {
+	profile string
+	client  Backend
+}
+
+// New returns a Client for the named profile, which may also be a role ARN.  The profile is resolved using the
+// standard AWS configuration files and environment variables, unless a different resolver is set using WithResolver.
+func New(profile string, opts ...Option) (*Client, error) {
+	o := &options{
+		mfaProvider:      helpers.NewMfaTokenProvider(os.Stdin).ReadInput,
+		credProvider:     helpers.NewUserPasswordInputProvider(os.Stdin).ReadInput,
+		roleSelector:     helpers.NewRoleSelectionInputProvider(os.Stdin).ReadInput,
+		passwordProvider: helpers.NewPasswordChangeInputProvider(os.Stdin).ReadInput,
+		cache:            true,
+		logger:           new(shared.DefaultLogger),
+		resolver:         config.DefaultResolver,
+	}
+
+	for _, f := range opts {
+		f(o)
+	}
+
+	if o.resolver == nil {
+		return nil, errors.New("invalid configuration resolver")
+	}
+
+	cfg, err := o.resolver.Config(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.MfaType) < 1 {
+		cfg.MfaType = external.MfaTypeAuto
+	}
+
+	if len(o.mfaCode) > 0 {
+		cfg.MfaCode = o.mfaCode
+	}
+
+	clientOpts := &client.Options{
+		EnableCache:             o.cache,
+		MfaInputProvider:        o.mfaProvider,
+		CredentialInputProvider: o.credProvider,
+		PasswordChangeProvider:  o.passwordProvider,
+		RoleSelectionProvider:   o.roleSelector,
+		Logger:                  o.logger,
+		AwsLogLevel:             logging.Warn,
+		CommandCredentials:      new(config.AwsCredentials),
+		Transport:               o.transport,
+		IdentityDetails:         true,
+	}
+
+	var cl client.AwsClient
+	cl, err = client.NewClientFactory(o.resolver, clientOpts).Get(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{profile: profile, client: cl}, nil
+}
+
+// NewWithClient returns a Client for the named profile, which gets credentials using the provided Backend instead of
+// a client built from the profile configuration.  This allows programs to test their use of the Client with fakes,
+// like those in the runastest package.
+func NewWithClient(profile string, c Backend) *Client {
+	return &Client{profile: profile, client: c}
+}
+
+// Profile returns the name of the profile used to create the Client.
+func (c *Client) Profile() string {
+	return c.profile
+}
+
+// Credentials returns the AWS credentials for the profile, using cached credentials if they are still valid.
+func (c *Client) Credentials(ctx context.Context) (aws.Credentials, error) {
+	creds, err := c.client.CredentialsWithContext(ctx)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	return creds.Value(), nil
+}
+
+// Refresh discards any cached AWS credentials for the profile, and returns a new set of credentials.
+func (c *Client) Refresh(ctx context.Context) (aws.Credentials, error) {
+	creds, err := c.client.Refresh(ctx)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	return creds.Value(), nil
+}
+
+// ExpiresAt returns the expiration time of the most recently retrieved credentials.  The zero time is returned if
+// credentials have not been retrieved.
+func (c *Client) ExpiresAt() time.Time {
+	return c.client.ExpiresAt()
+}
+
+// Identity describes the identity used with a profile.  Username and Provider identify the user to the source of the
+// profile's credentials, which may be an external identity provider.  Arn and Account are the AWS caller identity of
+// the profile's credentials, and Organization is the AWS Organizations ID of the account, if it could be found.
+type Identity struct {
+	Username     string
+	Provider     string
+	Arn          string
+	Account      string
+	Organization string
+}
+
+// Identity returns the identity used with the profile.  Getting the AWS caller details may require credentials for
+// the profile, which are retrieved if necessary.  The organization ID is only available if the credentials are
+// allowed to describe the organization.
+func (c *Client) Identity(ctx context.Context) (*Identity, error) {
+	id, err := c.client.IdentityWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{
+		Username:     id.Username,
+		Provider:     id.Provider,
+		Arn:          id.Arn,
+		Account:      id.Account,
+		Organization: id.Organization,
+	}, nil
+}
+
+// Roles returns the ARNs of the roles available to the identity used with the profile.
+func (c *Client) Roles(ctx context.Context) ([]string, error) {
+	roles, err := c.client.RolesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return *roles, nil
+}
+
+// AwsConfig returns an aws.Config for the profile, suitable for creating AWS SDK service clients.  The credentials
+// in the returned configuration are refreshed automatically.
+func (c *Client) AwsConfig() aws.Config {
+	return c.client.ConfigProvider()
+}
+
+// ConfigWriter returns a Writer which changes the standard AWS configuration and credentials files (or the
+// files named by the AWS_CONFIG_FILE and AWS_SHARED_CREDENTIALS_FILE environment variables).  Changes keep any
+// comments, and the order of the profiles and settings in the files.
+func ConfigWriter() Writer {
+	return config.DefaultResolver
+}
@@ -0,0 +1,235 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package runas
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/config"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/identity"
+	"github.com/mmmorris1975/aws-runas/shared"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		// use a role ARN as the profile, so the AWS SDK does not look for the profile in the config file
+		c, err := New(mockRoleArn, WithResolver(new(mockResolver)), WithCache(false), WithMFACode("123456"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if c.Profile() != mockRoleArn || c.client == nil {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("resolver error", func(t *testing.T) {
+		r := mockResolver(true)
+		if _, err := New("mock", WithResolver(&r)); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("nil resolver", func(t *testing.T) {
+		if _, err := New("mock", WithResolver(nil)); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestOptions(t *testing.T) {
+	o := new(options)
+	WithRoleSelector(func(roles []string, last string) (string, error) { return roles[0], nil })(o)
+	WithPasswordChangeProvider(func() (string, error) { return "newPassword", nil })(o)
+
+	if r, _ := o.roleSelector([]string{mockRoleArn}, ""); r != mockRoleArn {
+		t.Error("data mismatch")
+	}
+
+	if p, _ := o.passwordProvider(); p != "newPassword" {
+		t.Error("data mismatch")
+	}
+
+	// the internal types must keep satisfying the interfaces of this package
+	var _ Resolver = config.DefaultResolver
+	var _ Writer = config.DefaultResolver
+	var _ Logger = new(shared.DefaultLogger)
+	var _ Backend = client.AwsClient(nil)
+}
+
+func TestConfigWriter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config")
+	t.Setenv("AWS_CONFIG_FILE", file)
+
+	if err := ConfigWriter().SetConfig("dev", map[string]string{"region": "eu-west-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.DefaultIniLoader.Config("dev", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Region != "eu-west-1" {
+		t.Error("data mismatch")
+	}
+}
+
+func TestNewWithClient(t *testing.T) {
+	c := NewWithClient("mock", new(mockAwsClient))
+	if c.Profile() != "mock" {
+		t.Error("profile mismatch")
+		return
+	}
+
+	if _, err := c.Credentials(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClient_Credentials(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		c := &Client{client: new(mockAwsClient)}
+		creds, err := c.Credentials(context.Background())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if creds.AccessKeyID != "mockAK" || !c.ExpiresAt().Equal(creds.Expires) {
+			t.Error("data mismatch")
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		m := mockAwsClient(true)
+		c := &Client{client: &m}
+		if _, err := c.Credentials(context.Background()); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestClient_Refresh(t *testing.T) {
+	c := &Client{client: new(mockAwsClient)}
+	if _, err := c.Refresh(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClient_Identity(t *testing.T) {
+	c := &Client{client: new(mockAwsClient)}
+	id, err := c.Identity(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if id.Account != "123456789012" {
+		t.Error("data mismatch")
+	}
+}
+
+func TestClient_Roles(t *testing.T) {
+	c := &Client{client: new(mockAwsClient)}
+	roles, err := c.Roles(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(roles) != 1 {
+		t.Error("data mismatch")
+	}
+}
+
+const mockRoleArn = "arn:aws:iam::123456789012:role/mock"
+
+type mockResolver bool
+
+func (r *mockResolver) Config(profile string) (*config.AwsConfig, error) {
+	if *r {
+		return nil, errors.New("error")
+	}
+
+	return &config.AwsConfig{
+		Region:          "us-east-1",
+		RoleSessionName: "mockSession",
+		ProfileName:     profile,
+	}, nil
+}
+
+func (r *mockResolver) Credentials(string) (*config.AwsCredentials, error) {
+	return new(config.AwsCredentials), nil
+}
+
+type mockAwsClient bool
+
+var mockExpiration = time.Now().Add(1 * time.Hour)
+
+func (m *mockAwsClient) Identity() (*identity.Identity, error) {
+	return m.IdentityWithContext(context.Background())
+}
+
+func (m *mockAwsClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	return &identity.Identity{Arn: mockRoleArn, Account: "123456789012"}, nil
+}
+
+func (m *mockAwsClient) Roles() (*identity.Roles, error) {
+	return m.RolesWithContext(context.Background())
+}
+
+func (m *mockAwsClient) RolesWithContext(context.Context) (*identity.Roles, error) {
+	return &identity.Roles{mockRoleArn}, nil
+}
+
+func (m *mockAwsClient) Credentials() (*credentials.Credentials, error) {
+	return m.CredentialsWithContext(context.Background())
+}
+
+func (m *mockAwsClient) CredentialsWithContext(context.Context) (*credentials.Credentials, error) {
+	if *m {
+		return nil, errors.New("error")
+	}
+
+	return &credentials.Credentials{
+		AccessKeyId:     "mockAK",
+		SecretAccessKey: "mockSK",
+		Token:           "mockToken",
+		Expiration:      mockExpiration,
+	}, nil
+}
+
+func (m *mockAwsClient) ConfigProvider() aws.Config {
+	return aws.Config{}
+}
+
+func (m *mockAwsClient) ClearCache() error {
+	return nil
+}
+
+func (m *mockAwsClient) ExpiresAt() time.Time {
+	return mockExpiration
+}
+
+func (m *mockAwsClient) Refresh(ctx context.Context) (*credentials.Credentials, error) {
+	return m.CredentialsWithContext(ctx)
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package runastest
+
+import (
+	"sync"
+
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+// CredentialCache is a fake credentials.CredentialCacher which keeps the credentials in memory.  The cache is safe for
+// concurrent use.
+type CredentialCache struct {
+	// Err is returned by the Store and Clear methods.
+	Err error
+
+	mu     sync.Mutex
+	creds  *credentials.Credentials
+	stores int
+}
+
+// NewCredentialCache returns an empty CredentialCache.
+func NewCredentialCache() *CredentialCache {
+	return new(CredentialCache)
+}
+
+// Load returns the cached credentials, or nil if the cache is empty.
+func (c *CredentialCache) Load() *credentials.Credentials {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.creds
+}
+
+// Store saves cred in the cache, unless Err is set.
+func (c *CredentialCache) Store(cred *credentials.Credentials) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stores++
+	if c.Err != nil {
+		return c.Err
+	}
+	c.creds = cred
+	return nil
+}
+
+// Clear empties the cache, unless Err is set.
+func (c *CredentialCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Err != nil {
+		return c.Err
+	}
+	c.creds = nil
+	return nil
+}
+
+// StoreCalls returns the number of times Store was called.
+func (c *CredentialCache) StoreCalls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stores
+}
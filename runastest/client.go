@@ -0,0 +1,322 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package runastest
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/mmmorris1975/aws-runas/client/external"
+	"github.com/mmmorris1975/aws-runas/credentials"
+	"github.com/mmmorris1975/aws-runas/identity"
+	"github.com/mmmorris1975/aws-runas/runas"
+)
+
+// AwsClient is a fake client.AwsClient (and client.SamlAssertionClient) which returns the values set in its fields.
+// If Err is set, it is returned by every method which can return an error.  The fields may be changed between calls,
+// and the client is safe for concurrent use.
+type AwsClient struct {
+	// Creds are the credentials returned by the Credentials and Refresh methods.
+	Creds *credentials.Credentials
+	// Ident is the identity returned by the Identity methods.
+	Ident *identity.Identity
+	// RoleList is the list of roles returned by the Roles methods.
+	RoleList identity.Roles
+	// Saml is the assertion returned by the SamlAssertion methods.
+	Saml *credentials.SamlAssertion
+	// Region is the region of the aws.Config returned by ConfigProvider.
+	Region string
+	// Err is returned by all methods which can return an error.
+	Err error
+
+	mu        sync.Mutex
+	calls     int
+	cleared   int
+	refreshes int
+}
+
+// NewAwsClient returns an AwsClient providing the default fake data, credentials valid for 1 hour, and the identity
+// and roles matching the assertion from NewSamlAssertion.
+func NewAwsClient() *AwsClient {
+	id := &identity.Identity{IdentityType: "user", Provider: "runastest", Username: Username, Account: AccountId,
+		Arn: "arn:aws:sts::" + AccountId + ":assumed-role/RunasTest/" + Username}
+
+	return &AwsClient{
+		Creds:    NewCredentials(1 * time.Hour),
+		Ident:    id,
+		RoleList: identity.Roles{RoleArn},
+		Saml:     NewSamlAssertion(Username),
+		Region:   "us-east-1",
+	}
+}
+
+// NewRunasClient returns a runas.Client for profile, which uses a new AwsClient to get credentials.  The AwsClient is
+// also returned, so its fields can be changed to control the values provided by the runas.Client.
+func NewRunasClient(profile string) (*runas.Client, *AwsClient) {
+	c := NewAwsClient()
+	return runas.NewWithClient(profile, c), c
+}
+
+// Credentials calls CredentialsWithContext using a background context.
+func (c *AwsClient) Credentials() (*credentials.Credentials, error) {
+	return c.CredentialsWithContext(context.Background())
+}
+
+// CredentialsWithContext returns Creds, or Err if set.
+func (c *AwsClient) CredentialsWithContext(context.Context) (*credentials.Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls++
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return c.Creds, nil
+}
+
+// ConfigProvider returns an aws.Config for Region, which uses the credentials from this client.
+func (c *AwsClient) ConfigProvider() aws.Config {
+	return aws.Config{
+		Region: c.Region,
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			creds, err := c.CredentialsWithContext(ctx)
+			if err != nil {
+				return aws.Credentials{}, err
+			}
+			return creds.Value(), nil
+		}),
+	}
+}
+
+// ClearCache records the call, and returns Err.
+func (c *AwsClient) ClearCache() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cleared++
+	return c.Err
+}
+
+// ExpiresAt returns the expiration time of Creds, or the zero time if unset.
+func (c *AwsClient) ExpiresAt() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Creds == nil {
+		return time.Time{}
+	}
+	return c.Creds.Expiration
+}
+
+// Refresh records the call, and returns the same values as CredentialsWithContext.
+func (c *AwsClient) Refresh(ctx context.Context) (*credentials.Credentials, error) {
+	c.mu.Lock()
+	c.refreshes++
+	c.mu.Unlock()
+
+	return c.CredentialsWithContext(ctx)
+}
+
+// Identity calls IdentityWithContext using a background context.
+func (c *AwsClient) Identity() (*identity.Identity, error) {
+	return c.IdentityWithContext(context.Background())
+}
+
+// IdentityWithContext returns Ident, or Err if set.
+func (c *AwsClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return c.Ident, nil
+}
+
+// Roles calls RolesWithContext using a background context.
+func (c *AwsClient) Roles() (*identity.Roles, error) {
+	return c.RolesWithContext(context.Background())
+}
+
+// RolesWithContext returns RoleList, or Err if set.
+func (c *AwsClient) RolesWithContext(context.Context) (*identity.Roles, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	roles := c.RoleList
+	return &roles, nil
+}
+
+// SamlAssertion calls SamlAssertionWithContext using a background context.
+func (c *AwsClient) SamlAssertion() (*credentials.SamlAssertion, error) {
+	return c.SamlAssertionWithContext(context.Background())
+}
+
+// SamlAssertionWithContext returns Saml, or Err if set.
+func (c *AwsClient) SamlAssertionWithContext(context.Context) (*credentials.SamlAssertion, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return c.Saml, nil
+}
+
+// CredentialsCalls returns the number of times credentials were requested from the client, including refreshes.
+func (c *AwsClient) CredentialsCalls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+// RefreshCalls returns the number of times Refresh was called.
+func (c *AwsClient) RefreshCalls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refreshes
+}
+
+// ClearCacheCalls returns the number of times ClearCache was called.
+func (c *AwsClient) ClearCacheCalls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cleared
+}
+
+// SamlClient is a fake external.SamlClient (and external.WebIdentityClient) which returns the values set in its
+// fields.  If Err is set, it is returned by every method which can return an error.  The fields should not be changed
+// while the client is in use.
+type SamlClient struct {
+	// Ident is the identity returned by the Identity methods.
+	Ident *identity.Identity
+	// Saml is the assertion returned by the SamlAssertion methods, the Roles methods return the roles it contains.
+	Saml *credentials.SamlAssertion
+	// Token is the identity token returned by the IdentityToken methods.
+	Token *credentials.OidcIdentityToken
+	// Err is returned by all methods which can return an error.
+	Err error
+
+	// Authenticated is set after a successful call to Authenticate.
+	Authenticated bool
+	// CookieJar, Transport, and LoginThrottler are the values passed to the matching Set methods.
+	CookieJar      http.CookieJar
+	Transport      http.RoundTripper
+	LoginThrottler external.LoginThrottler
+}
+
+// NewSamlClient returns a SamlClient providing the assertion from NewSamlAssertion, and an identity token valid for
+// 1 hour, for the default fake user.
+func NewSamlClient() *SamlClient {
+	return &SamlClient{
+		Ident: &identity.Identity{IdentityType: "user", Provider: "runastest", Username: Username},
+		Saml:  NewSamlAssertion(Username),
+		Token: NewIdentityToken(Username, 1*time.Hour),
+	}
+}
+
+// Authenticate calls AuthenticateWithContext using a background context.
+func (c *SamlClient) Authenticate() error {
+	return c.AuthenticateWithContext(context.Background())
+}
+
+// AuthenticateWithContext returns Err, setting Authenticated if Err is nil.
+func (c *SamlClient) AuthenticateWithContext(context.Context) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	c.Authenticated = true
+	return nil
+}
+
+// SetCookieJar sets the CookieJar field.
+func (c *SamlClient) SetCookieJar(jar http.CookieJar) {
+	c.CookieJar = jar
+}
+
+// SetTransport sets the Transport field.
+func (c *SamlClient) SetTransport(rt http.RoundTripper) {
+	c.Transport = rt
+}
+
+// SetLoginThrottler sets the LoginThrottler field.
+func (c *SamlClient) SetLoginThrottler(t external.LoginThrottler) {
+	c.LoginThrottler = t
+}
+
+// Identity calls IdentityWithContext using a background context.
+func (c *SamlClient) Identity() (*identity.Identity, error) {
+	return c.IdentityWithContext(context.Background())
+}
+
+// IdentityWithContext returns Ident, or Err if set.
+func (c *SamlClient) IdentityWithContext(context.Context) (*identity.Identity, error) {
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return c.Ident, nil
+}
+
+// Roles calls RolesWithContext using a background context.
+func (c *SamlClient) Roles(user ...string) (*identity.Roles, error) {
+	return c.RolesWithContext(context.Background(), user...)
+}
+
+// RolesWithContext returns the roles found in Saml, or Err if set.
+func (c *SamlClient) RolesWithContext(ctx context.Context, _ ...string) (*identity.Roles, error) {
+	saml, err := c.SamlAssertionWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rd, err := saml.RoleDetails()
+	if err != nil {
+		return nil, err
+	}
+
+	roles := identity.Roles(rd.Roles())
+	return &roles, nil
+}
+
+// SamlAssertion calls SamlAssertionWithContext using a background context.
+func (c *SamlClient) SamlAssertion() (*credentials.SamlAssertion, error) {
+	return c.SamlAssertionWithContext(context.Background())
+}
+
+// SamlAssertionWithContext returns Saml, or Err if set.
+func (c *SamlClient) SamlAssertionWithContext(context.Context) (*credentials.SamlAssertion, error) {
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return c.Saml, nil
+}
+
+// IdentityToken calls IdentityTokenWithContext using a background context.
+func (c *SamlClient) IdentityToken() (*credentials.OidcIdentityToken, error) {
+	return c.IdentityTokenWithContext(context.Background())
+}
+
+// IdentityTokenWithContext returns Token, or Err if set.
+func (c *SamlClient) IdentityTokenWithContext(context.Context) (*credentials.OidcIdentityToken, error) {
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return c.Token, nil
+}
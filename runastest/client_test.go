@@ -0,0 +1,157 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package runastest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mmmorris1975/aws-runas/client"
+	"github.com/mmmorris1975/aws-runas/client/external"
+)
+
+func TestAwsClient(t *testing.T) {
+	var c client.AwsClient = NewAwsClient()
+
+	t.Run("credentials", func(t *testing.T) {
+		creds, err := c.Credentials()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if creds.AccessKeyId != NewCredentials(0).AccessKeyId || !c.ExpiresAt().Equal(creds.Expiration) {
+			t.Errorf("unexpected credentials: %+v", creds)
+		}
+	})
+
+	t.Run("config provider", func(t *testing.T) {
+		v, err := c.ConfigProvider().Credentials.Retrieve(context.Background())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if !v.HasKeys() {
+			t.Error("invalid credentials")
+		}
+	})
+
+	t.Run("identity", func(t *testing.T) {
+		id, err := c.Identity()
+		if err != nil || id.Username != Username {
+			t.Errorf("unexpected identity: %+v %v", id, err)
+		}
+
+		roles, err := c.Roles()
+		if err != nil || len(*roles) != 1 || (*roles)[0] != RoleArn {
+			t.Errorf("unexpected roles: %v %v", roles, err)
+		}
+	})
+
+	t.Run("saml assertion", func(t *testing.T) {
+		saml, err := c.(client.SamlAssertionClient).SamlAssertion()
+		if err != nil || len(*saml) < 1 {
+			t.Errorf("unexpected saml assertion: %v", err)
+		}
+	})
+
+	t.Run("calls", func(t *testing.T) {
+		ac := NewAwsClient()
+		_, _ = ac.Credentials()
+		_, _ = ac.Refresh(context.Background())
+		_ = ac.ClearCache()
+
+		if ac.CredentialsCalls() != 2 || ac.RefreshCalls() != 1 || ac.ClearCacheCalls() != 1 {
+			t.Error("unexpected call counts")
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		ac := NewAwsClient()
+		ac.Err = errors.New("error")
+
+		if _, err := ac.Credentials(); err == nil {
+			t.Error("did not receive expected error")
+		}
+
+		if _, err := ac.Identity(); err == nil {
+			t.Error("did not receive expected error")
+		}
+
+		if _, err := ac.ConfigProvider().Credentials.Retrieve(context.Background()); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestSamlClient(t *testing.T) {
+	sc := NewSamlClient()
+
+	var c external.SamlClient = sc
+	var _ external.WebIdentityClient = sc
+
+	t.Run("authenticate", func(t *testing.T) {
+		if err := c.Authenticate(); err != nil || !sc.Authenticated {
+			t.Errorf("not authenticated: %v", err)
+		}
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		roles, err := c.Roles()
+		if err != nil || len(*roles) != 1 || (*roles)[0] != RoleArn {
+			t.Errorf("unexpected roles: %v %v", roles, err)
+		}
+	})
+
+	t.Run("identity token", func(t *testing.T) {
+		tok, err := sc.IdentityToken()
+		if err != nil || tok.IsExpired() {
+			t.Errorf("unexpected identity token: %v", err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		ec := NewSamlClient()
+		ec.Err = errors.New("error")
+
+		if err := ec.Authenticate(); err == nil || ec.Authenticated {
+			t.Error("did not receive expected error")
+		}
+
+		if _, err := ec.Roles(); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestNewRunasClient(t *testing.T) {
+	rc, c := NewRunasClient("test")
+
+	v, err := rc.Credentials(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if rc.Profile() != "test" || v.AccessKeyID != c.Creds.AccessKeyId {
+		t.Errorf("unexpected credentials: %+v", v)
+	}
+
+	c.Err = errors.New("error")
+	if _, err = rc.Refresh(context.Background()); err == nil {
+		t.Error("did not receive expected error")
+	}
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package runastest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+// Resolver is a fake config.Resolver (and config.Writer) which returns the configuration and credentials set in its
+// maps, keyed by profile name.  Changes made using the Writer methods are recorded in the ConfigValues and
+// CredentialValues maps, and do not change the resolved configuration or credentials.  The resolver is safe for
+// concurrent use.
+type Resolver struct {
+	// Configs is the configuration returned for each profile.
+	Configs map[string]*config.AwsConfig
+	// Creds is the credentials returned for each profile.  An empty AwsCredentials is returned for unknown profiles.
+	Creds map[string]*config.AwsCredentials
+	// ConfigValues are the configuration values set for each profile using the Writer methods.
+	ConfigValues map[string]map[string]string
+	// CredentialValues are the credential values set for each profile using the Writer methods.
+	CredentialValues map[string]map[string]string
+	// Err is returned by all methods.
+	Err error
+
+	mu sync.Mutex
+}
+
+// NewResolver returns a Resolver for the provided configurations, which are keyed by their ProfileName.
+func NewResolver(cfgs ...*config.AwsConfig) *Resolver {
+	r := &Resolver{
+		Configs:          make(map[string]*config.AwsConfig),
+		Creds:            make(map[string]*config.AwsCredentials),
+		ConfigValues:     make(map[string]map[string]string),
+		CredentialValues: make(map[string]map[string]string),
+	}
+
+	for _, c := range cfgs {
+		r.Configs[c.ProfileName] = c
+	}
+	return r
+}
+
+// Config returns a copy of the configuration for profile, or an error if the profile is unknown.
+func (r *Resolver) Config(profile string) (*config.AwsConfig, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	c, ok := r.Configs[profile]
+	if !ok {
+		return nil, fmt.Errorf("profile %s not found", profile)
+	}
+
+	cfg := *c
+	cfg.ProfileName = profile
+	return &cfg, nil
+}
+
+// Credentials returns a copy of the credentials for profile.
+func (r *Resolver) Credentials(profile string) (*config.AwsCredentials, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	creds := new(config.AwsCredentials)
+	if c, ok := r.Creds[profile]; ok {
+		*creds = *c
+	}
+	return creds, nil
+}
+
+// CreateProfile adds an empty configuration for profile, and records the values.
+func (r *Resolver) CreateProfile(profile string, values map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err != nil {
+		return r.Err
+	}
+
+	if _, ok := r.Configs[profile]; ok {
+		return fmt.Errorf("profile %s already exists", profile)
+	}
+	r.Configs[profile] = &config.AwsConfig{ProfileName: profile}
+	setValues(r.ConfigValues, profile, values)
+	return nil
+}
+
+// DeleteProfile removes the configuration, and any recorded values, for profile.
+func (r *Resolver) DeleteProfile(profile string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err != nil {
+		return r.Err
+	}
+
+	if _, ok := r.Configs[profile]; !ok {
+		return fmt.Errorf("profile %s not found", profile)
+	}
+	delete(r.Configs, profile)
+	delete(r.ConfigValues, profile)
+	return nil
+}
+
+// SetConfig records the configuration values for profile.
+func (r *Resolver) SetConfig(profile string, values map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err != nil {
+		return r.Err
+	}
+	setValues(r.ConfigValues, profile, values)
+	return nil
+}
+
+// RemoveConfig removes the recorded configuration values for profile.
+func (r *Resolver) RemoveConfig(profile string, keys ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err != nil {
+		return r.Err
+	}
+	removeValues(r.ConfigValues, profile, keys...)
+	return nil
+}
+
+// SetCredentials records the credential values for profile.
+func (r *Resolver) SetCredentials(profile string, values map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err != nil {
+		return r.Err
+	}
+	setValues(r.CredentialValues, profile, values)
+	return nil
+}
+
+// RemoveCredentials removes the recorded credential values for profile.
+func (r *Resolver) RemoveCredentials(profile string, keys ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err != nil {
+		return r.Err
+	}
+	removeValues(r.CredentialValues, profile, keys...)
+	return nil
+}
+
+func setValues(m map[string]map[string]string, profile string, values map[string]string) {
+	if m[profile] == nil {
+		m[profile] = make(map[string]string)
+	}
+
+	for k, v := range values {
+		m[profile][k] = v
+	}
+}
+
+func removeValues(m map[string]map[string]string, profile string, keys ...string) {
+	for _, k := range keys {
+		delete(m[profile], k)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package runastest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mmmorris1975/aws-runas/config"
+)
+
+func TestResolver(t *testing.T) {
+	r := NewResolver(&config.AwsConfig{ProfileName: "test", RoleArn: RoleArn})
+	r.Creds["https://idp.example.org/saml"] = &config.AwsCredentials{SamlPassword: "password"}
+
+	var w config.WritableResolver = r
+
+	t.Run("config", func(t *testing.T) {
+		cfg, err := w.Config("test")
+		if err != nil || cfg.RoleArn != RoleArn {
+			t.Errorf("unexpected config: %+v %v", cfg, err)
+			return
+		}
+
+		// changes to the returned config must not affect the resolver
+		cfg.RoleArn = "changed"
+		if r.Configs["test"].RoleArn != RoleArn {
+			t.Error("resolver config modified")
+		}
+	})
+
+	t.Run("unknown config", func(t *testing.T) {
+		if _, err := w.Config("unknown"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		creds, err := w.Credentials("https://idp.example.org/saml")
+		if err != nil || creds.SamlPassword != "password" {
+			t.Errorf("unexpected credentials: %+v %v", creds, err)
+		}
+
+		if creds, err = w.Credentials("unknown"); err != nil || len(creds.SamlPassword) > 0 {
+			t.Errorf("unexpected credentials: %+v %v", creds, err)
+		}
+	})
+
+	t.Run("writer", func(t *testing.T) {
+		if err := w.CreateProfile("new", map[string]string{"region": "us-east-2"}); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if err := w.CreateProfile("new", nil); err == nil {
+			t.Error("did not receive expected error")
+		}
+
+		_ = w.SetCredentials("new", map[string]string{"saml_password": "x", "other": "y"})
+		_ = w.RemoveCredentials("new", "other")
+		if v := r.CredentialValues["new"]; len(v) != 1 || v["saml_password"] != "x" {
+			t.Errorf("unexpected credential values: %v", v)
+		}
+
+		_ = w.SetConfig("new", map[string]string{"mfa_type": "push"})
+		_ = w.RemoveConfig("new", "region")
+		if v := r.ConfigValues["new"]; len(v) != 1 || v["mfa_type"] != "push" {
+			t.Errorf("unexpected config values: %v", v)
+		}
+
+		if err := w.DeleteProfile("new"); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if _, err := w.Config("new"); err == nil {
+			t.Error("profile not deleted")
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		er := NewResolver()
+		er.Err = errors.New("error")
+
+		if _, err := er.Credentials("x"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
+
+func TestCredentialCache(t *testing.T) {
+	c := NewCredentialCache()
+	if c.Load() != nil {
+		t.Error("cache is not empty")
+		return
+	}
+
+	creds := NewCredentials(1 * time.Hour)
+	if err := c.Store(creds); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if c.Load() != creds || c.StoreCalls() != 1 {
+		t.Error("credentials not stored")
+	}
+
+	if err := c.Clear(); err != nil || c.Load() != nil {
+		t.Errorf("cache not cleared: %v", err)
+	}
+
+	c.Err = errors.New("error")
+	if err := c.Store(creds); err == nil || c.Load() != nil {
+		t.Error("did not receive expected error")
+	}
+}
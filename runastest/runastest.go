@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+// Package runastest provides fakes of the aws-runas clients, configuration resolver, and credential cache, along with
+// canned SAML assertions and OIDC identity tokens, so programs embedding aws-runas can be unit tested without talking
+// to AWS or an external identity provider.
+package runastest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/mmmorris1975/aws-runas/credentials"
+)
+
+const (
+	// AccountId is the AWS account ID used by the default fake data.
+	AccountId = "123456789012"
+	// RoleArn is the IAM role ARN used by the default fake data.
+	RoleArn = "arn:aws:iam::123456789012:role/RunasTest"
+	// Username is the user name used by the default fake data.
+	Username = "runastest-user"
+	// Issuer is the issuer ('iss' claim) of the fake OIDC identity tokens.
+	Issuer = "https://idp.runastest.invalid"
+	// ClientId is the audience ('aud' claim) of the fake OIDC identity tokens.
+	ClientId = "runastest"
+)
+
+const samlTemplate = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol">
+<saml2:Assertion xmlns:saml2="urn:oasis:names:tc:SAML:2.0:assertion" ID="runastest" IssueInstant="%s" Version="2.0">
+<saml2:AttributeStatement>
+<saml2:Attribute Name="https://aws.amazon.com/SAML/Attributes/Role">%s</saml2:Attribute>
+<saml2:Attribute Name="https://aws.amazon.com/SAML/Attributes/RoleSessionName"><saml2:AttributeValue>%s</saml2:AttributeValue></saml2:Attribute>
+<saml2:Attribute Name="https://aws.amazon.com/SAML/Attributes/SessionDuration"><saml2:AttributeValue>43200</saml2:AttributeValue></saml2:Attribute>
+</saml2:AttributeStatement>
+</saml2:Assertion>
+</samlp:Response>`
+
+// NewSamlAssertion returns a SAML assertion issued now for username, which authorizes the given role ARNs.  Each role
+// is paired with a saml-provider principal named 'runastest' in the role's account.  If no roles are provided, RoleArn
+// is used.  The assertion is not signed, and is only useful with fakes (AWS will reject it).
+func NewSamlAssertion(username string, roles ...string) *credentials.SamlAssertion {
+	if len(roles) < 1 {
+		roles = []string{RoleArn}
+	}
+
+	sb := new(strings.Builder)
+	for _, r := range roles {
+		acct := AccountId
+		if a, err := arn.Parse(r); err == nil {
+			acct = a.AccountID
+		}
+		sb.WriteString(fmt.Sprintf("<saml2:AttributeValue>%s,arn:aws:iam::%s:saml-provider/runastest</saml2:AttributeValue>", r, acct))
+	}
+
+	doc := fmt.Sprintf(samlTemplate, time.Now().UTC().Format(time.RFC3339), sb.String(), username)
+	saml := credentials.SamlAssertion(base64.StdEncoding.EncodeToString([]byte(doc)))
+	return &saml
+}
+
+// NewIdentityToken returns an OIDC identity token for subject, which expires after ttl.  The token is issued by Issuer
+// for the ClientId audience.  The token is not signed, and is only useful with fakes (AWS will reject it).
+func NewIdentityToken(subject string, ttl time.Duration) *credentials.OidcIdentityToken {
+	now := time.Now()
+	claims := map[string]any{
+		"iss":                Issuer,
+		"aud":                ClientId,
+		"sub":                subject,
+		"preferred_username": subject,
+		"iat":                now.Unix(),
+		"exp":                now.Add(ttl).Unix(),
+	}
+
+	// marshaling a map of strings and numbers never fails
+	header, _ := json.Marshal(map[string]string{"alg": "none", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	t := credentials.OidcIdentityToken(fmt.Sprintf("%s.%s.%s", base64.RawURLEncoding.EncodeToString(header),
+		base64.RawURLEncoding.EncodeToString(payload), base64.RawURLEncoding.EncodeToString([]byte("runastest"))))
+	return &t
+}
+
+// NewCredentials returns a set of fake AWS session credentials which expire after ttl.
+func NewCredentials(ttl time.Duration) *credentials.Credentials {
+	return &credentials.Credentials{
+		AccessKeyId:     "ASIARUNASTEST0000000",
+		SecretAccessKey: "runastestSecretAccessKey0000000000000000",
+		Token:           "runastestSessionToken",
+		Expiration:      time.Now().Add(ttl).Round(time.Second),
+		ProviderName:    "runastest",
+	}
+}
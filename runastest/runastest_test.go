@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package runastest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewSamlAssertion(t *testing.T) {
+	t.Run("default role", func(t *testing.T) {
+		saml := NewSamlAssertion(Username)
+
+		rd, err := saml.RoleDetails()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if r := rd.Roles(); len(r) != 1 || r[0] != RoleArn {
+			t.Errorf("unexpected roles: %v", r)
+		}
+
+		if p := rd.RolePrincipal(RoleArn); p != "arn:aws:iam::123456789012:saml-provider/runastest" {
+			t.Errorf("unexpected principal: %s", p)
+		}
+
+		if u, err := saml.RoleSessionName(); err != nil || u != Username {
+			t.Errorf("unexpected role session name: %s %v", u, err)
+		}
+
+		if exp, err := saml.ExpiresAt(); err != nil || !exp.After(time.Now()) {
+			t.Errorf("unexpected expiration: %s %v", exp, err)
+		}
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		roles := []string{"arn:aws:iam::111111111111:role/Admin", "arn:aws:iam::222222222222:role/Dev"}
+
+		rd, err := NewSamlAssertion("user", roles...).RoleDetails()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(rd.Roles()) != 2 || rd.RolePrincipal(roles[1]) != "arn:aws:iam::222222222222:saml-provider/runastest" {
+			t.Errorf("unexpected role details: %s", rd)
+		}
+	})
+}
+
+func TestNewIdentityToken(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		tok := NewIdentityToken(Username, 1*time.Hour)
+		if tok.IsExpired() {
+			t.Error("token is expired")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		tok := NewIdentityToken(Username, -1*time.Hour)
+		if !tok.IsExpired() {
+			t.Error("token is not expired")
+		}
+	})
+}
+
+func TestNewCredentials(t *testing.T) {
+	creds := NewCredentials(1 * time.Hour)
+	if !creds.Value().HasKeys() || !creds.Expiration.After(time.Now()) {
+		t.Errorf("invalid credentials: %+v", creds)
+	}
+}
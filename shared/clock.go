@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package shared
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ClockSkewThreshold is the smallest difference between the local clock and a server clock which is considered
+// significant.  Smaller differences are ignored, since the Date header used to find a server time only has a
+// resolution of 1 second, and includes network latency.
+const ClockSkewThreshold = 1 * time.Minute
+
+var clockSkew atomic.Int64
+
+// RecordServerTime records the difference between the time reported by a server (usually from the HTTP Date header)
+// and the local time when the response was received.  The skew is returned as the server time minus the local time,
+// and significant is true if the skew is at least ClockSkewThreshold.
+func RecordServerTime(server, local time.Time) (skew time.Duration, significant bool) {
+	if server.IsZero() || local.IsZero() {
+		return 0, false
+	}
+
+	skew = server.Sub(local)
+	significant = skew >= ClockSkewThreshold || skew <= -ClockSkewThreshold
+	if significant {
+		clockSkew.Store(int64(skew))
+	} else {
+		clockSkew.Store(0)
+	}
+	return skew, significant
+}
+
+// ClockSkew returns the most recently recorded significant clock skew, as the server time minus the local time.  Zero
+// is returned if no significant skew has been detected.
+func ClockSkew() time.Duration {
+	return time.Duration(clockSkew.Load())
+}
+
+// LocalTime converts a time provided by a server, like a credential expiration time, to the equivalent time on the
+// local clock, using the most recently recorded clock skew.
+func LocalTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.Add(-ClockSkew())
+}
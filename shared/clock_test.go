@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package shared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordServerTime(t *testing.T) {
+	now := time.Now()
+	defer RecordServerTime(now, now)
+
+	t.Run("insignificant", func(t *testing.T) {
+		if _, ok := RecordServerTime(now.Add(2*time.Second), now); ok {
+			t.Error("small skew reported as significant")
+		}
+
+		if ClockSkew() != 0 {
+			t.Error("small skew was recorded")
+		}
+	})
+
+	t.Run("server ahead", func(t *testing.T) {
+		skew, ok := RecordServerTime(now.Add(10*time.Minute), now)
+		if !ok || skew != 10*time.Minute {
+			t.Errorf("unexpected skew: %s", skew)
+		}
+
+		exp := now.Add(1 * time.Hour)
+		if !LocalTime(exp).Equal(now.Add(50 * time.Minute)) {
+			t.Errorf("unexpected local time: %s", LocalTime(exp))
+		}
+	})
+
+	t.Run("server behind", func(t *testing.T) {
+		if _, ok := RecordServerTime(now.Add(-10*time.Minute), now); !ok {
+			t.Error("skew not reported as significant")
+		}
+
+		if ClockSkew() != -10*time.Minute {
+			t.Errorf("unexpected skew: %s", ClockSkew())
+		}
+	})
+
+	t.Run("zero time", func(t *testing.T) {
+		if _, ok := RecordServerTime(time.Time{}, now); ok {
+			t.Error("zero time reported as significant")
+		}
+
+		if !LocalTime(time.Time{}).IsZero() {
+			t.Error("zero time was adjusted")
+		}
+	})
+}
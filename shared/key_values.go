@@ -0,0 +1,37 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package shared
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseKeyValues returns a comma separated list of key=value pairs as a map.  Whitespace around the keys and values
+// is removed, and empty entries are ignored.  An error is returned for entries without a key.
+func ParseKeyValues(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		if len(strings.TrimSpace(kv)) < 1 {
+			continue
+		}
+
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || len(strings.TrimSpace(k)) < 1 {
+			return nil, fmt.Errorf("invalid entry %s", kv)
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return m, nil
+}
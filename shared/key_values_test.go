@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package shared
+
+import "testing"
+
+func TestParseKeyValues(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		m, err := ParseKeyValues("team = ops, env=ci,")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(m) != 2 || m["team"] != "ops" || m["env"] != "ci" {
+			t.Errorf("unexpected result: %v", m)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if m, err := ParseKeyValues(""); err != nil || len(m) > 0 {
+			t.Errorf("unexpected result: %v, %v", m, err)
+		}
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		if _, err := ParseKeyValues("=v"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+
+	t.Run("missing value", func(t *testing.T) {
+		if _, err := ParseKeyValues("k"); err == nil {
+			t.Error("did not receive expected error")
+		}
+	})
+}
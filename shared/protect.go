@@ -0,0 +1,23 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package shared
+
+import "errors"
+
+// ErrDataProtectionUnsupported is returned when data protection is not available on the current platform.
+var ErrDataProtectionUnsupported = errors.New("data protection is not supported on this platform")
+
+// dataProtectionEntropy is additional data mixed in to the data protection operations, so that data protected by
+// aws-runas can not be unprotected by other programs without knowledge of this value.
+var dataProtectionEntropy = []byte("aws-runas")
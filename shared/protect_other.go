@@ -0,0 +1,29 @@
+//go:build !windows
+
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package shared
+
+// DataProtectionScheme is the name of the platform data protection mechanism, empty if there is none.
+const DataProtectionScheme = ""
+
+// ProtectData is not supported on this platform, and always returns ErrDataProtectionUnsupported.
+func ProtectData(_ []byte) ([]byte, error) {
+	return nil, ErrDataProtectionUnsupported
+}
+
+// UnprotectData is not supported on this platform, and always returns ErrDataProtectionUnsupported.
+func UnprotectData(_ []byte) ([]byte, error) {
+	return nil, ErrDataProtectionUnsupported
+}
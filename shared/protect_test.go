@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package shared
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProtectData(t *testing.T) {
+	data := []byte("some secret data")
+
+	b, err := ProtectData(data)
+	if len(DataProtectionScheme) < 1 {
+		if !errors.Is(err, ErrDataProtectionUnsupported) {
+			t.Errorf("did not receive expected error, got: %v", err)
+		}
+		return
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) == string(data) {
+		t.Error("data was not protected")
+	}
+
+	u, err := UnprotectData(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(u) != string(data) {
+		t.Error("data mismatch")
+	}
+}
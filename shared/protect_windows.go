@@ -0,0 +1,60 @@
+//go:build windows
+
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package shared
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// DataProtectionScheme is the name of the platform data protection mechanism.
+const DataProtectionScheme = "dpapi"
+
+// ProtectData encrypts the data using the Windows Data Protection API (DPAPI), scoped to the current user.
+func ProtectData(data []byte) ([]byte, error) {
+	return dpapi(data, func(in, entropy, out *windows.DataBlob) error {
+		return windows.CryptProtectData(in, nil, entropy, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, out)
+	})
+}
+
+// UnprotectData decrypts data returned by ProtectData.  This will fail if the data was protected by another user.
+func UnprotectData(data []byte) ([]byte, error) {
+	return dpapi(data, func(in, entropy, out *windows.DataBlob) error {
+		return windows.CryptUnprotectData(in, nil, entropy, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, out)
+	})
+}
+
+func dpapi(data []byte, f func(in, entropy, out *windows.DataBlob) error) ([]byte, error) {
+	if len(data) < 1 {
+		return data, nil
+	}
+
+	in := &windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+	entropy := &windows.DataBlob{Size: uint32(len(dataProtectionEntropy)), Data: &dataProtectionEntropy[0]}
+	out := new(windows.DataBlob)
+
+	if err := f(in, entropy, out); err != nil {
+		return nil, err
+	}
+	defer func() {
+		_, _ = windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	}()
+
+	b := make([]byte, out.Size)
+	copy(b, unsafe.Slice(out.Data, out.Size))
+	return b, nil
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package shared
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// secretHeaderRe matches HTTP headers whose whole value is secret, like those in the AWS SDK request logging.
+	secretHeaderRe = regexp.MustCompile(`(?im)^(\s*(?:set-cookie|cookie|authorization|x-amz-security-token)\s*:\s*)(.+)$`)
+
+	// secretFieldRe matches key/value pairs with a secret sounding key, in query strings, JSON, and %+v struct output.
+	secretFieldRe = regexp.MustCompile(`(?i)("?[\w.-]*(?:password|passwd|secret|token|samlresponse|assertion|cookie|` +
+		`code_verifier)[\w.-]*"?\s*[:=]\s*"?)([^\s"&,;}\]]+)`)
+
+	// secretValueRe matches secrets which are recognizable without a key: base64 encoded SAML documents, and JWTs.
+	secretValueRe = regexp.MustCompile(`\b(?:PD94bWwg|PHNhbWxwOl|PHNhbWwyc|PHNhbWw6)[A-Za-z0-9+/]+=*|` +
+		`\beyJ[\w-]+\.[\w-]+\.[\w-]*`)
+
+	// notSecretKeyRe matches keys which look like secrets, but hold information about the secret instead.
+	notSecretKeyRe = regexp.MustCompile(`(?i)(file|url|uri|provider|type|name|source|duration|expiration|expires)"?\s*[:=]`)
+)
+
+// RedactSecrets returns the text with passwords, session tokens, SAML assertions, cookies, and other secret values
+// replaced by the placeholder returned by Redact.  If RevealSecrets is set, the text is returned unchanged.
+func RedactSecrets(text string) string {
+	if RevealSecrets {
+		return text
+	}
+
+	text = secretHeaderRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := secretHeaderRe.FindStringSubmatch(s)
+		return m[1] + Redact(strings.TrimSpace(m[2]))
+	})
+
+	text = secretFieldRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := secretFieldRe.FindStringSubmatch(s)
+		if notSecretKeyRe.MatchString(m[1]) || strings.HasPrefix(m[2], "<redacted") {
+			return s
+		}
+		return m[1] + Redact(m[2])
+	})
+
+	return secretValueRe.ReplaceAllStringFunc(text, Redact)
+}
+
+// redactingLogger is a Logger which removes secrets from messages before passing them to the wrapped Logger.
+type redactingLogger struct {
+	Logger
+}
+
+// NewRedactingLogger returns a Logger which calls RedactSecrets on the formatted messages before they are written to
+// the provided Logger, so the log output can be safely shared.  A logger which already redacts secrets is returned
+// as-is.
+func NewRedactingLogger(l Logger) Logger {
+	if l == nil {
+		l = new(DefaultLogger)
+	}
+
+	if _, ok := l.(*redactingLogger); ok {
+		return l
+	}
+	return &redactingLogger{Logger: l}
+}
+
+// Debugf logs the message, with secrets redacted, at the debug level.
+func (l *redactingLogger) Debugf(format string, v ...any) {
+	l.Logger.Debugf("%s", RedactSecrets(fmt.Sprintf(format, v...)))
+}
+
+// Infof logs the message, with secrets redacted, at the info level.
+func (l *redactingLogger) Infof(format string, v ...any) {
+	l.Logger.Infof("%s", RedactSecrets(fmt.Sprintf(format, v...)))
+}
+
+// Warningf logs the message, with secrets redacted, at the warning level.
+func (l *redactingLogger) Warningf(format string, v ...any) {
+	l.Logger.Warningf("%s", RedactSecrets(fmt.Sprintf(format, v...)))
+}
+
+// Errorf logs the message, with secrets redacted, at the error level.
+func (l *redactingLogger) Errorf(format string, v ...any) {
+	l.Logger.Errorf("%s", RedactSecrets(fmt.Sprintf(format, v...)))
+}
@@ -0,0 +1,30 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package shared
+
+import "fmt"
+
+// RevealSecrets disables the redaction of secret values, like SAML assertions and AWS credentials, in log output.
+// This is only meant for troubleshooting authentication problems, since the log output becomes as sensitive as the
+// secrets it contains.
+var RevealSecrets = false
+
+// Redact returns the secret value for use in log output, replaced by a placeholder unless RevealSecrets is set.
+// Empty values are returned as-is, so it's still possible to see when a secret is missing.
+func Redact(secret string) string {
+	if RevealSecrets || len(secret) < 1 {
+		return secret
+	}
+	return fmt.Sprintf("<redacted %d bytes>", len(secret))
+}
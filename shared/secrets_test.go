@@ -0,0 +1,42 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package shared
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	t.Run("redacted", func(t *testing.T) {
+		if v := Redact("mysecret"); strings.Contains(v, "mysecret") {
+			t.Errorf("secret was not redacted: %s", v)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if v := Redact(""); len(v) > 0 {
+			t.Errorf("unexpected value: %s", v)
+		}
+	})
+
+	t.Run("revealed", func(t *testing.T) {
+		RevealSecrets = true
+		defer func() { RevealSecrets = false }()
+
+		if v := Redact("mysecret"); v != "mysecret" {
+			t.Errorf("secret was not revealed: %s", v)
+		}
+	})
+}
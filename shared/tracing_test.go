@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package shared
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestStartSpan(t *testing.T) {
+	t.Run("good", func(t *testing.T) {
+		ctx, span := StartSpan(context.Background(), "test")
+		if ctx == nil || span == nil {
+			t.Error("invalid span")
+		}
+		EndSpan(span, errors.New("error"))
+	})
+
+	//nolint:staticcheck // testing nil context handling
+	t.Run("nil context", func(t *testing.T) {
+		ctx, span := StartSpan(nil, "test")
+		if ctx == nil || span == nil {
+			t.Error("invalid span")
+		}
+		EndSpan(span, nil)
+	})
+}
+
+func TestStartTracing(t *testing.T) {
+	tp := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(tp)
+
+	sb := new(strings.Builder)
+	shutdown, err := StartTracing(sb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := StartSpan(context.Background(), "test-span")
+	EndSpan(span, nil)
+
+	if err = shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(sb.String(), `"Name":"test-span"`) {
+		t.Errorf("span was not written: %s", sb.String())
+	}
+}
@@ -0,0 +1,219 @@
+/*
+ * Copyright (c) 2021 Michael Morris. All Rights Reserved.
+ *
+ * Licensed under the MIT license (the "License"). You may not use this file except in compliance
+ * with the License. A copy of the License is located at
+ *
+ * https://github.com/mmmorris1975/aws-runas/blob/master/LICENSE
+ *
+ * or in the "license" file accompanying this file. This file is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License
+ * for the specific language governing permissions and limitations under the License.
+ */
+
+package shared
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TransportOptions are the settings for an HTTP transport created by NewTransport.  The zero value provides a transport
+// using connection pooling and HTTP/2, the proxy from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables,
+// and the system certificate pool.
+type TransportOptions struct {
+	// ProxyUrl is the URL of the proxy used for all requests, overriding the proxy environment variables.  HTTP, HTTPS
+	// and SOCKS5 (socks5:// or socks5h:// URLs) proxies are supported.
+	ProxyUrl string
+	// ProxyUsername and ProxyPassword are the credentials for the proxy, if they're not part of ProxyUrl.
+	ProxyUsername string
+	ProxyPassword string
+	// CaBundle is the path to a file of PEM-encoded certificates trusted in addition to the system certificate pool.
+	CaBundle string
+	// DisableHttp2 limits the transport to HTTP/1.1, for networks with devices which don't handle HTTP/2 correctly.
+	DisableHttp2 bool
+	// MaxIdleConnsPerHost is the number of idle connections kept open for each host, 10 if unset.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept open, 90 seconds if unset.
+	IdleConnTimeout time.Duration
+}
+
+// TransportOptionsFromEnv returns the TransportOptions configured by the environment.  The AWS_CA_BUNDLE variable
+// (used by the AWS SDKs and CLI) sets CaBundle, and the RUNAS_HTTP_PROXY, RUNAS_HTTP_PROXY_USERNAME,
+// RUNAS_HTTP_PROXY_PASSWORD, RUNAS_DISABLE_HTTP2, RUNAS_HTTP_MAX_IDLE_CONNS_PER_HOST and RUNAS_HTTP_IDLE_TIMEOUT
+// variables set the remaining options.
+func TransportOptionsFromEnv() (TransportOptions, error) {
+	var err error
+	opts := TransportOptions{
+		ProxyUrl:      os.Getenv("RUNAS_HTTP_PROXY"),
+		ProxyUsername: os.Getenv("RUNAS_HTTP_PROXY_USERNAME"),
+		ProxyPassword: os.Getenv("RUNAS_HTTP_PROXY_PASSWORD"),
+		CaBundle:      os.Getenv("AWS_CA_BUNDLE"),
+	}
+
+	if v, ok := os.LookupEnv("RUNAS_DISABLE_HTTP2"); ok && len(v) > 0 {
+		if opts.DisableHttp2, err = strconv.ParseBool(v); err != nil {
+			return opts, fmt.Errorf("invalid RUNAS_DISABLE_HTTP2 value: %w", err)
+		}
+	}
+
+	if v, ok := os.LookupEnv("RUNAS_HTTP_MAX_IDLE_CONNS_PER_HOST"); ok && len(v) > 0 {
+		if opts.MaxIdleConnsPerHost, err = strconv.Atoi(v); err != nil {
+			return opts, fmt.Errorf("invalid RUNAS_HTTP_MAX_IDLE_CONNS_PER_HOST value: %w", err)
+		}
+	}
+
+	if v, ok := os.LookupEnv("RUNAS_HTTP_IDLE_TIMEOUT"); ok && len(v) > 0 {
+		if opts.IdleConnTimeout, err = time.ParseDuration(v); err != nil {
+			return opts, fmt.Errorf("invalid RUNAS_HTTP_IDLE_TIMEOUT value: %w", err)
+		}
+	}
+
+	return opts, nil
+}
+
+// NewTransport creates an HTTP transport with the provided options.  An error is returned if the proxy URL is invalid,
+// or the CA bundle can not be loaded.
+func NewTransport(opts TransportOptions) (*http.Transport, error) {
+	proxy := http.ProxyFromEnvironment
+	if len(opts.ProxyUrl) > 0 {
+		u, err := proxyUrl(opts)
+		if err != nil {
+			return nil, err
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(opts.CaBundle) > 0 {
+		pool, err := certPool(opts.CaBundle)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if opts.MaxIdleConnsPerHost < 1 {
+		opts.MaxIdleConnsPerHost = 10
+	}
+
+	if opts.IdleConnTimeout <= 0 {
+		opts.IdleConnTimeout = 90 * time.Second
+	}
+
+	t := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsCfg,
+		ForceAttemptHTTP2:     !opts.DisableHttp2,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	if opts.DisableHttp2 {
+		// a non-nil, empty map disables the automatic HTTP/2 upgrade of TLS connections
+		t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	return t, nil
+}
+
+var defaultTransport = struct {
+	sync.Mutex
+	rt http.RoundTripper
+}{}
+
+// DefaultTransport returns the HTTP transport shared by the clients of external services, like identity providers and
+// the AWS sign-in endpoints, so connections are reused across requests.  Unless set with SetDefaultTransport, this is
+// a transport created with the zero value TransportOptions.
+func DefaultTransport() http.RoundTripper {
+	defaultTransport.Lock()
+	defer defaultTransport.Unlock()
+
+	if defaultTransport.rt == nil {
+		// can't fail, since the zero value options have no proxy or CA bundle
+		defaultTransport.rt, _ = NewTransport(TransportOptions{})
+	}
+	return defaultTransport.rt
+}
+
+// SetDefaultTransport replaces the transport returned by DefaultTransport with one created using the provided options.
+// If the transport can not be created, the error is returned and the default transport is unchanged.
+func SetDefaultTransport(opts TransportOptions) error {
+	t, err := NewTransport(opts)
+	if err != nil {
+		return err
+	}
+
+	defaultTransport.Lock()
+	defer defaultTransport.Unlock()
+
+	if old, ok := defaultTransport.rt.(*http.Transport); ok {
+		old.CloseIdleConnections()
+	}
+	defaultTransport.rt = t
+	return nil
+}
+
+// SetDefaultRoundTripper replaces the transport returned by DefaultTransport with rt, for things like offline mode
+// which must keep every client using the shared transport off the network.
+func SetDefaultRoundTripper(rt http.RoundTripper) {
+	defaultTransport.Lock()
+	defer defaultTransport.Unlock()
+
+	if old, ok := defaultTransport.rt.(*http.Transport); ok && old != rt {
+		old.CloseIdleConnections()
+	}
+	defaultTransport.rt = rt
+}
+
+// proxyUrl parses and validates the proxy URL, adding the proxy credentials if the URL doesn't have any.  The URL isn't
+// part of the returned errors, since it may contain a password.
+func proxyUrl(opts TransportOptions) (*url.URL, error) {
+	u, err := url.Parse(opts.ProxyUrl)
+	if err != nil || len(u.Host) < 1 {
+		return nil, errors.New("invalid proxy url")
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", u.Scheme)
+	}
+
+	if u.User == nil && len(opts.ProxyUsername) > 0 {
+		u.User = url.UserPassword(opts.ProxyUsername, opts.ProxyPassword)
+	}
+	return u, nil
+}
+
+func certPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates found in CA bundle " + path)
+	}
+	return pool, nil
+}